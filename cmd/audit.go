@@ -0,0 +1,80 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// auditArgs returns the arguments of cmd, the ones passed on the command line or, if none, the ones set with
+// environment variables, see BindEnv.
+func auditArgs(cmd *cobra.Command) []string {
+	if args := cmd.Flags().Args(); len(args) > 0 {
+		return args
+	}
+
+	args, _ := argsFromEnv(cmd.Use)
+	return args
+}
+
+// auditCommand returns the command line reproducing the run of cmd: the flags set, on the command line or with
+// environment variables, and the arguments, without --audit-file.
+func auditCommand(cmd *cobra.Command) []string {
+	command := []string{cmd.Name()}
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if flag.Name == "audit-file" {
+			return
+		}
+
+		if values, ok := flag.Value.(pflag.SliceValue); ok {
+			for _, value := range values.GetSlice() {
+				command = append(command, "--"+flag.Name+"="+value)
+			}
+
+			return
+		}
+
+		command = append(command, "--"+flag.Name+"="+flag.Value.String())
+	})
+
+	return append(append(command, "--"), auditArgs(cmd)...)
+}
+
+// auditInputPaths returns the paths of the files and the directories read by the run of cmd: the ones of the path
+// arguments, named like template-path in the usage of cmd, of the file flags, like --config-file, and of --include-dir.
+func auditInputPaths(cmd *cobra.Command) []string {
+	var paths []string
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		input := strings.HasSuffix(flag.Name, "-file") || flag.Name == "include-dir"
+		if input && flag.Name != "audit-file" && flag.Value.String() != "" {
+			paths = append(paths, flag.Value.String())
+		}
+	})
+
+	args := auditArgs(cmd)
+	for i, name := range strings.Fields(cmd.Use)[1:] {
+		if i >= len(args) {
+			break
+		}
+
+		if strings.HasSuffix(name, "...") {
+			// the variadic argument is the last one
+			if strings.HasSuffix(strings.TrimSuffix(name, "..."), "-path") {
+				paths = append(paths, args[i:]...)
+			}
+
+			break
+		}
+
+		if strings.HasSuffix(name, "-path") {
+			paths = append(paths, args[i])
+		}
+	}
+
+	return paths
+}
@@ -0,0 +1,82 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+)
+
+var benchEvents uint64
+
+func BenchCmd() *cobra.Command {
+	benchCmd := &cobra.Command{
+		Use:   "bench fields-definition-path",
+		Short: "Benchmark the template engines",
+		Long:  "Generate the same events of all the fields of a fields definition without a template and with the placeholder and gotext engines, reporting the events per second and the allocations of each",
+		Args: func(cmd *cobra.Command, args []string) error {
+			var errs []error
+			if len(args) != 1 {
+				return errors.New("you must pass the fields definition path")
+			}
+
+			fieldsDefinitionPath = args[0]
+			if fieldsDefinitionPath == "" {
+				errs = append(errs, errors.New("you must provide a not empty fields definition path argument"))
+			}
+
+			if benchEvents == 0 {
+				errs = append(errs, errors.New("you must provide a positive --events flag value"))
+			}
+
+			if len(errs) > 0 {
+				return multierr.Combine(errs...)
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			flds, err := fields.LoadFieldsWithTemplate(cmd.Context(), fieldsDefinitionPath)
+			if err != nil {
+				return err
+			}
+
+			results, err := genlib.Bench(cfg, flds, benchEvents)
+			if err != nil {
+				return err
+			}
+
+			return printBenchResults(cmd.OutOrStdout(), results)
+		},
+	}
+
+	benchCmd.Flags().StringVarP(&configFile, "config-file", "c", "", "path to config file for generator settings")
+	benchCmd.Flags().Uint64VarP(&benchEvents, "events", "n", 100000, "number of events to generate with each engine")
+	return benchCmd
+}
+
+// printBenchResults writes a table of the throughput and the allocations of each engine.
+func printBenchResults(w io.Writer, results []genlib.BenchResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENGINE\tEVENTS\tDURATION\tEVENTS/SEC\tBYTES/EVENT\tALLOCS/EVENT\tALLOC BYTES/EVENT")
+	for _, result := range results {
+		bytesPerEvent := float64(result.Bytes) / float64(result.Events)
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%.0f\t%.1f\t%.1f\t%.1f\n", result.Engine, result.Events, result.Duration, result.EventsPerSecond(), bytesPerEvent, result.AllocsPerEvent(), result.AllocBytesPerEvent())
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,200 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/multierr"
+)
+
+var diffFromVersion string
+var diffToVersion string
+var diffTemplatePaths []string
+
+func DiffCmd() *cobra.Command {
+	diffCmd := &cobra.Command{
+		Use:   "diff integration data_stream from-version to-version",
+		Short: "Diff the fields of two versions of a package",
+		Long:  "Report the fields of a data stream added, removed and retyped between two versions of a package downloaded from a package registry, and the issues the upgrade introduces in a config file and in templates, optionally generating a corpus for each version",
+		Args: func(cmd *cobra.Command, args []string) error {
+			var errs []error
+			if len(args) != 4 {
+				return errors.New("you must pass the integration package, the data stream and the two package versions")
+			}
+
+			if packageRegistryBaseURL == "" {
+				errs = append(errs, errors.New("you must provide a not empty --package-registry-base-url flag value"))
+			}
+
+			integrationPackage = args[0]
+			if integrationPackage == "" {
+				errs = append(errs, errors.New("you must provide a not empty integration argument"))
+			}
+
+			dataStream = args[1]
+			if dataStream == "" {
+				errs = append(errs, errors.New("you must provide a not empty data stream argument"))
+			}
+
+			diffFromVersion = args[2]
+			diffToVersion = args[3]
+			if diffFromVersion == "" || diffToVersion == "" {
+				errs = append(errs, errors.New("you must provide not empty package version arguments"))
+			}
+
+			errs = append(errs, validateTotSize()...)
+
+			if len(errs) > 0 {
+				return multierr.Combine(errs...)
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadConfig(configFile)
+			if err != nil {
+				return err
+			}
+
+			loadOpts := []fields.LoadOption{fields.WithCacheDir(viper.GetString("fields_cache_location"))}
+			if offline {
+				loadOpts = append(loadOpts, fields.WithOffline())
+			}
+
+			from, err := fields.LoadFields(cmd.Context(), packageRegistryBaseURL, integrationPackage, dataStream, diffFromVersion, loadOpts...)
+			if err != nil {
+				return fmt.Errorf("cannot load the fields of version %s: %w", diffFromVersion, err)
+			}
+
+			to, err := fields.LoadFields(cmd.Context(), packageRegistryBaseURL, integrationPackage, dataStream, diffToVersion, loadOpts...)
+			if err != nil {
+				return fmt.Errorf("cannot load the fields of version %s: %w", diffToVersion, err)
+			}
+
+			fc, err := corpus.NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "", templateType, includeDirOptions()...)
+			if err != nil {
+				return err
+			}
+
+			upgradeIssues, err := fc.UpgradeIssues(from, to, diffTemplatePaths)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Data stream %s of package %s from %s to %s:\n", dataStream, integrationPackage, diffFromVersion, diffToVersion)
+			printFieldsDiff(cmd.OutOrStdout(), genlib.DiffFields(from, to))
+			printUpgradeIssues(cmd.OutOrStdout(), upgradeIssues)
+
+			if totSize != "" {
+				if err := generateDiffCorpora(cmd, cfg); err != nil {
+					return err
+				}
+			}
+
+			if upgradeIssues.Len() > 0 {
+				issuesErr := &issuesError{}
+				issuesErr.add(configFile, upgradeIssues.Config)
+				for _, templatePath := range upgradeIssues.TemplatePaths() {
+					issuesErr.add(templatePath, upgradeIssues.Templates[templatePath])
+				}
+
+				return issuesErr
+			}
+
+			return nil
+		},
+	}
+
+	diffCmd.Flags().StringVarP(&packageRegistryBaseURL, "package-registry-base-url", "r", "https://epr.elastic.co/", "base url of the package registry with schema")
+	diffCmd.Flags().StringVarP(&configFile, "config-file", "c", "", "path to the config file to check against the fields of the new version")
+	diffCmd.Flags().StringSliceVar(&diffTemplatePaths, "template", nil, "paths of the templates to check against the fields of the new version")
+	diffCmd.Flags().StringVarP(&templateType, "template-type", "y", "placeholder", "either 'placeholder', 'gotext' or 'structured'")
+	diffCmd.Flags().StringVarP(&totSize, "tot-size", "t", "", "total size of the corpus to generate for each version with the config file, no corpora if not provided")
+	addIncludeDirFlag(diffCmd)
+	diffCmd.Flags().BoolVar(&offline, "offline", false, "load the fields from the cache of the ones downloaded by previous runs only, failing if they are not there, instead of from the package registry")
+	addReportFormatFlag(diffCmd)
+	return diffCmd
+}
+
+// generateDiffCorpora generates a corpus of totSize for each of the two versions, with the fields cached already,
+// for example to check that the corpora of the old version are still ingested by the new one.
+func generateDiffCorpora(cmd *cobra.Command, cfg config.Config) error {
+	opts := []corpus.GeneratorCorpusOption{corpus.WithFieldsCache(viper.GetString("fields_cache_location"))}
+	if offline {
+		opts = append(opts, corpus.WithOffline())
+	}
+
+	fc, err := corpus.NewGenerator(cfg, afero.NewOsFs(), viper.GetString("corpora_location"), opts...)
+	if err != nil {
+		return err
+	}
+
+	for _, version := range []string{diffFromVersion, diffToVersion} {
+		payloadFilename, err := fc.Generate(cmd.Context(), packageRegistryBaseURL, integrationPackage, dataStream, version, totSize)
+		if err != nil {
+			return fmt.Errorf("cannot generate the corpus of version %s: %w", version, err)
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "Corpus of version %s generated: %s\n", version, payloadFilename)
+	}
+
+	return nil
+}
+
+// printFieldsDiff writes the fields added, removed and retyped, with their type.
+func printFieldsDiff(w io.Writer, diff genlib.FieldsDiff) {
+	if diff.Empty() {
+		fmt.Fprintln(w, "No fields changed")
+		return
+	}
+
+	fmt.Fprintf(w, "Added fields: %d\n", len(diff.Added))
+	for _, field := range diff.Added {
+		fmt.Fprintf(w, "  %s (%s)\n", field.Name, genlib.FieldTypeName(field))
+	}
+
+	fmt.Fprintf(w, "Removed fields: %d\n", len(diff.Removed))
+	for _, field := range diff.Removed {
+		fmt.Fprintf(w, "  %s (%s)\n", field.Name, genlib.FieldTypeName(field))
+	}
+
+	fmt.Fprintf(w, "Retyped fields: %d\n", len(diff.Retyped))
+	for _, field := range diff.Retyped {
+		fmt.Fprintf(w, "  %s: %s to %s\n", field.Name, field.From, field.To)
+	}
+}
+
+// printUpgradeIssues writes the issues of the config file, if any, and of each template.
+func printUpgradeIssues(w io.Writer, upgradeIssues corpus.UpgradeIssues) {
+	if configFile != "" {
+		printIssues(w, "Config file "+configFile, upgradeIssues.Config)
+	}
+
+	for _, templatePath := range upgradeIssues.TemplatePaths() {
+		printIssues(w, "Template "+templatePath, upgradeIssues.Templates[templatePath])
+	}
+}
+
+func printIssues(w io.Writer, title string, issues []string) {
+	if len(issues) == 0 {
+		fmt.Fprintf(w, "%s: no issues\n", title)
+		return
+	}
+
+	fmt.Fprintf(w, "%s: %d issues\n", title, len(issues))
+	for _, issue := range issues {
+		fmt.Fprintln(w, "  "+issue)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/cmd"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	packageFields := map[string]string{
+		"1.2.0": "- name: nginx.access.user_name\n  type: keyword\n- name: nginx.access.bytes\n  type: long\n- name: nginx.access.agent\n  type: keyword\n",
+		"1.3.0": "- name: nginx.access.user_name\n  type: keyword\n- name: nginx.access.bytes\n  type: keyword\n- name: nginx.access.method\n  type: keyword\n",
+	}
+
+	archives := make(map[string][]byte)
+	for version, content := range packageFields {
+		var archive bytes.Buffer
+		zw := zip.NewWriter(&archive)
+		w, err := zw.Create("nginx-" + version + "/data_stream/access/fields/fields.yml")
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		archives[version] = archive.Bytes()
+	}
+
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for version, archive := range archives {
+			switch r.URL.Path {
+			case "/package/nginx/" + version:
+				_, _ = w.Write([]byte(`{"download": "/epr/nginx/nginx-` + version + `.zip"}`))
+				return
+			case "/epr/nginx/nginx-" + version + ".zip":
+				_, _ = w.Write(archive)
+				return
+			}
+		}
+
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(registry.Close)
+
+	viper.Set("fields_cache_location", t.TempDir())
+	t.Cleanup(func() {
+		viper.Set("fields_cache_location", nil)
+	})
+
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "template.json")
+	require.NoError(t, os.WriteFile(templatePath, []byte(`{"user":"{{.nginx.access.user_name}}","bytes":{{.nginx.access.bytes}},"agent":"{{.nginx.access.agent}}"}`), 0644))
+	configPath := filepath.Join(dir, "config.yml")
+	require.NoError(t, os.WriteFile(configPath, []byte("- name: nginx.access.bytes\n  range: 10\n- name: nginx.access.agent\n  enum: [\"curl\"]\n"), 0644))
+
+	var out bytes.Buffer
+	rootCmd := cmd.RootCmd()
+	rootCmd.AddCommand(cmd.DiffCmd())
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	rootCmd.SetArgs([]string{"diff", "nginx", "access", "1.2.0", "1.3.0", "-r", registry.URL, "--template", templatePath, "-c", configPath})
+
+	require.EqualError(t, rootCmd.ExecuteContext(context.Background()), "4 issues found", out.String())
+	require.Contains(t, out.String(), `Data stream access of package nginx from 1.2.0 to 1.3.0:
+Added fields: 1
+  nginx.access.method (keyword)
+Removed fields: 1
+  nginx.access.agent (keyword)
+Retyped fields: 1
+  nginx.access.bytes: long to keyword
+Config file `+configPath+`: 2 issues
+  line 3: field nginx.access.agent: not found in the fields definition
+  line 1: field nginx.access.bytes: range ignored for type keyword, it applies to numeric types only
+Template `+templatePath+`: 2 issues
+  field nginx.access.agent: referenced by the template but not found in the fields definition
+  field nginx.access.bytes: value of type keyword is not quoted in the template, it is generated as invalid JSON
+`)
+
+	// the issues are reported as JSON to stderr with --report-format json
+	var stderr bytes.Buffer
+	out.Reset()
+	rootCmd = cmd.RootCmd()
+	rootCmd.AddCommand(cmd.DiffCmd())
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&stderr)
+	rootCmd.SetArgs([]string{"diff", "nginx", "access", "1.2.0", "1.3.0", "-r", registry.URL, "--template", templatePath, "-c", configPath, "--report-format", "json"})
+
+	require.EqualError(t, rootCmd.ExecuteContext(context.Background()), "4 issues found")
+	require.JSONEq(t, `{"command":"diff","errors":[
+		{"file":"`+configPath+`","line":3,"field":"nginx.access.agent","reason":"not found in the fields definition"},
+		{"file":"`+configPath+`","line":1,"field":"nginx.access.bytes","reason":"range ignored for type keyword, it applies to numeric types only"},
+		{"file":"`+templatePath+`","field":"nginx.access.agent","reason":"referenced by the template but not found in the fields definition"},
+		{"file":"`+templatePath+`","field":"nginx.access.bytes","reason":"value of type keyword is not quoted in the template, it is generated as invalid JSON"}
+	]}`, stderr.String())
+
+	// --tot-size is checked before diffing
+	out.Reset()
+	rootCmd = cmd.RootCmd()
+	rootCmd.AddCommand(cmd.DiffCmd())
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	rootCmd.SetArgs([]string{"diff", "nginx", "access", "1.2.0", "1.3.0", "-r", registry.URL, "-t", "1XB"})
+
+	require.EqualError(t, rootCmd.ExecuteContext(context.Background()), "you must provide a valid --tot-size flag value, like 1GB, got 1XB")
+	require.NotContains(t, out.String(), "Data stream access")
+
+	// the corpora of both versions are generated with --tot-size
+	location := t.TempDir()
+	viper.Set("corpora_location", location)
+	t.Cleanup(func() {
+		viper.Set("corpora_location", nil)
+	})
+
+	out.Reset()
+	rootCmd = cmd.RootCmd()
+	rootCmd.AddCommand(cmd.DiffCmd())
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	rootCmd.SetArgs([]string{"diff", "nginx", "access", "1.2.0", "1.3.0", "-r", registry.URL, "-t", "1KB"})
+
+	require.NoError(t, rootCmd.ExecuteContext(context.Background()), out.String())
+	for _, version := range []string{"1.2.0", "1.3.0"} {
+		require.Contains(t, out.String(), "Corpus of version "+version+" generated: ")
+		matches, err := filepath.Glob(filepath.Join(location, "*-nginx-access-"+version+"*"))
+		require.NoError(t, err)
+		require.Len(t, matches, 1)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// EnvPrefix prefixes the names of the environment variables the flags and the arguments can be set with.
+const EnvPrefix = "CORPUS_GENERATOR_"
+
+// BindEnv lets the flags and the arguments of cmd and of its subcommands be set with environment variables, so
+// that the tool can run without any command line argument, like in a container:
+//   - a flag is set with the variable named after it, like CORPUS_GENERATOR_TOT_SIZE for --tot-size, unless
+//     passed on the command line
+//   - the arguments named in the usage of the command are set with the variables named after them, like
+//     CORPUS_GENERATOR_TEMPLATE_PATH, unless any is passed on the command line; the values of a variadic
+//     argument are separated by commas
+//   - the content of the file of a path argument or of a file flag can be inlined in the variable named after
+//     it without the suffix, like CORPUS_GENERATOR_TEMPLATE or CORPUS_GENERATOR_CONFIG: it is written to a
+//     temporary file whose path the argument or the flag is set to
+func BindEnv(cmd *cobra.Command) {
+	for _, subCmd := range cmd.Commands() {
+		BindEnv(subCmd)
+	}
+
+	if cmd.Args == nil {
+		return
+	}
+
+	// the flags are set before the arguments are validated, since their validation includes the flags
+	validateArgs := cmd.Args
+	cmd.Args = func(cmd *cobra.Command, args []string) error {
+		if err := setFlagsFromEnv(cmd.Flags()); err != nil {
+			return err
+		}
+
+		if len(args) == 0 {
+			var err error
+			args, err = argsFromEnv(cmd.Use)
+			if err != nil {
+				return err
+			}
+		}
+
+		return validateArgs(cmd, args)
+	}
+}
+
+// envName returns the name of the environment variable of the flag or the argument called name.
+func envName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// setFlagsFromEnv sets the flags not passed on the command line with their environment variables, if set.
+func setFlagsFromEnv(flags *pflag.FlagSet) error {
+	var err error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if err != nil || flag.Changed {
+			return
+		}
+
+		value, ok, inlineErr := lookupEnv(flag.Name, "-file")
+		if inlineErr != nil {
+			err = inlineErr
+			return
+		}
+
+		if !ok {
+			return
+		}
+
+		if setErr := flags.Set(flag.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q of %s: %w", value, envName(flag.Name), setErr)
+		}
+	})
+
+	return err
+}
+
+// argsFromEnv returns the arguments named in use, the usage of a command, from their environment variables,
+// or none if any of them is not set.
+func argsFromEnv(use string) ([]string, error) {
+	var args []string
+	for _, name := range strings.Fields(use)[1:] {
+		variadic := strings.HasSuffix(name, "...")
+		name = strings.TrimSuffix(name, "...")
+
+		value, ok, err := lookupEnv(name, "-path")
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return nil, nil
+		}
+
+		if variadic {
+			args = append(args, strings.Split(value, ",")...)
+		} else {
+			args = append(args, value)
+		}
+	}
+
+	return args, nil
+}
+
+// lookupEnv returns the value of the environment variable of the flag or the argument called name, or, if its
+// name has pathSuffix, the path of a temporary file with the content inlined in the variable named after it
+// without pathSuffix.
+func lookupEnv(name, pathSuffix string) (string, bool, error) {
+	if value, ok := os.LookupEnv(envName(name)); ok {
+		return value, true, nil
+	}
+
+	if !strings.HasSuffix(name, pathSuffix) {
+		return "", false, nil
+	}
+
+	inlineName := strings.TrimSuffix(name, pathSuffix)
+	content, ok := os.LookupEnv(envName(inlineName))
+	if !ok {
+		return "", false, nil
+	}
+
+	dir, err := os.MkdirTemp("", "corpus-generator-")
+	if err != nil {
+		return "", false, err
+	}
+
+	path := filepath.Join(dir, inlineName)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", false, err
+	}
+
+	return path, true, nil
+}
@@ -0,0 +1,110 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/cmd"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+type envTestCmd struct {
+	args       []string
+	totSize    string
+	configFile string
+	fields     []string
+}
+
+func newEnvTestCmd(t *testing.T, args ...string) (*cobra.Command, *envTestCmd) {
+	t.Helper()
+
+	var got envTestCmd
+	rootCmd := cmd.RootCmd()
+	testCmd := &cobra.Command{
+		Use: "test template-path sample-path...",
+		Args: func(cmd *cobra.Command, args []string) error {
+			got.args = args
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+	}
+
+	testCmd.Flags().StringVarP(&got.totSize, "tot-size", "t", "", "")
+	testCmd.Flags().StringVarP(&got.configFile, "config-file", "c", "", "")
+	testCmd.Flags().StringSliceVar(&got.fields, "fields", nil, "")
+	rootCmd.AddCommand(testCmd)
+	cmd.BindEnv(rootCmd)
+	rootCmd.SetArgs(append([]string{"test"}, args...))
+
+	return rootCmd, &got
+}
+
+func TestBindEnv(t *testing.T) {
+	t.Setenv("CORPUS_GENERATOR_TOT_SIZE", "1MB")
+	t.Setenv("CORPUS_GENERATOR_FIELDS", "host.name,user.name")
+	t.Setenv("CORPUS_GENERATOR_TEMPLATE_PATH", "template.tpl")
+	t.Setenv("CORPUS_GENERATOR_SAMPLE_PATH", "a.json,b.json")
+
+	rootCmd, got := newEnvTestCmd(t)
+	require.NoError(t, rootCmd.Execute())
+	require.Equal(t, "1MB", got.totSize)
+	require.Equal(t, []string{"host.name", "user.name"}, got.fields)
+	require.Equal(t, []string{"template.tpl", "a.json", "b.json"}, got.args)
+	require.Empty(t, got.configFile)
+}
+
+func TestBindEnvCommandLine(t *testing.T) {
+	t.Setenv("CORPUS_GENERATOR_TOT_SIZE", "1MB")
+	t.Setenv("CORPUS_GENERATOR_TEMPLATE_PATH", "template.tpl")
+	t.Setenv("CORPUS_GENERATOR_SAMPLE_PATH", "a.json")
+
+	// the command line takes precedence over the environment
+	rootCmd, got := newEnvTestCmd(t, "-t", "2MB", "other.tpl", "c.json")
+	require.NoError(t, rootCmd.Execute())
+	require.Equal(t, "2MB", got.totSize)
+	require.Equal(t, []string{"other.tpl", "c.json"}, got.args)
+}
+
+func TestBindEnvMissingArgs(t *testing.T) {
+	t.Setenv("CORPUS_GENERATOR_TEMPLATE_PATH", "template.tpl")
+
+	// the arguments are all taken from the environment or none is
+	rootCmd, got := newEnvTestCmd(t)
+	require.NoError(t, rootCmd.Execute())
+	require.Empty(t, got.args)
+}
+
+func TestBindEnvInline(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("CORPUS_GENERATOR_CONFIG", "fields:\n  - name: host.name\n    value: web-1\n")
+	t.Setenv("CORPUS_GENERATOR_TEMPLATE", "{{.host.name}}")
+	t.Setenv("CORPUS_GENERATOR_SAMPLE_PATH", "a.json")
+
+	rootCmd, got := newEnvTestCmd(t)
+	require.NoError(t, rootCmd.Execute())
+	require.Len(t, got.args, 2)
+	require.Equal(t, "a.json", got.args[1])
+
+	template, err := os.ReadFile(got.args[0])
+	require.NoError(t, err)
+	require.Equal(t, "{{.host.name}}", string(template))
+
+	config, err := os.ReadFile(got.configFile)
+	require.NoError(t, err)
+	require.Equal(t, "fields:\n  - name: host.name\n    value: web-1\n", string(config))
+}
+
+func TestBindEnvInvalidFlag(t *testing.T) {
+	rootCmd, _ := newEnvTestCmd(t)
+	rootCmd.Commands()[0].Flags().Int("shards", 0, "")
+	t.Setenv("CORPUS_GENERATOR_SHARDS", "many")
+
+	require.ErrorContains(t, rootCmd.Execute(), `invalid value "many" of CORPUS_GENERATOR_SHARDS`)
+}
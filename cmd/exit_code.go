@@ -0,0 +1,32 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+)
+
+const (
+	ExitCodeCompleted = 0
+	ExitCodeFailure   = 1
+	// ExitCodeInterrupted is returned when the generation has been stopped by a signal, leaving a
+	// partial but consistent corpus: it matches EX_TEMPFAIL from sysexits.h, so that job
+	// schedulers can tell it apart from a failure and retry.
+	ExitCodeInterrupted = 75
+)
+
+// ExitCode maps the error returned by the executed command to the process exit code.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitCodeCompleted
+	case errors.Is(err, corpus.ErrInterrupted):
+		return ExitCodeInterrupted
+	default:
+		return ExitCodeFailure
+	}
+}
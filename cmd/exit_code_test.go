@@ -0,0 +1,21 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/cmd"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitCode(t *testing.T) {
+	require.Equal(t, cmd.ExitCodeCompleted, cmd.ExitCode(nil))
+	require.Equal(t, cmd.ExitCodeFailure, cmd.ExitCode(errors.New("failure")))
+	require.Equal(t, cmd.ExitCodeInterrupted, cmd.ExitCode(fmt.Errorf("%w: context canceled", corpus.ErrInterrupted)))
+}
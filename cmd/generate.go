@@ -7,8 +7,10 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"strings"
+
 	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
-	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sink"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -18,6 +20,18 @@ import (
 var integrationPackage string
 var dataStream string
 var packageVersion string
+var idStrategy string
+var idDuplicatePercentage int
+var duplicateSameID bool
+var bulkIndex string
+var dataStreamType string
+var namespace string
+var namespaces int
+var namespaceFields []string
+var opType string
+var pipeline string
+var offline bool
+var allDataStreams bool
 
 func GenerateCmd() *cobra.Command {
 	generateCmd := &cobra.Command{
@@ -26,7 +40,13 @@ func GenerateCmd() *cobra.Command {
 		Long:  "Generate a bulk request corpus for a given integration data stream downloaded from a package registry",
 		Args: func(cmd *cobra.Command, args []string) error {
 			var errs []error
-			if len(args) != 3 {
+			if allDataStreams {
+				if len(args) != 2 {
+					return errors.New("you must pass the integration package and the package version with --all-data-streams")
+				}
+
+				args = []string{args[0], "", args[1]}
+			} else if len(args) != 3 {
 				return errors.New("you must pass the integration package the data stream and the package vesion")
 			}
 
@@ -34,8 +54,8 @@ func GenerateCmd() *cobra.Command {
 				errs = append(errs, errors.New("you must provide a not empty --package-registry-base-url flag value"))
 			}
 
-			if totSize == "" {
-				errs = append(errs, errors.New("you must provide a not empty --tot-size flag value"))
+			if totSize == "" && events == 0 && rate == "" && soak == 0 {
+				errs = append(errs, errors.New("you must provide a not empty --tot-size flag value, unless --events, --rate or --soak is set"))
 			}
 
 			integrationPackage = args[0]
@@ -44,15 +64,16 @@ func GenerateCmd() *cobra.Command {
 			}
 
 			dataStream = args[1]
-			if dataStream == "" {
-				errs = append(errs, errors.New("you must provide a not empty data stream argument"))
-			}
+			errs = append(errs, validateDataStreams()...)
 
 			packageVersion = args[2]
 			if packageVersion == "" {
 				errs = append(errs, errors.New("you must provide a not empty package version argument"))
 			}
 
+			errs = append(errs, validateBulkActionFlags(cmd)...)
+			errs = append(errs, validateGeneratorCorpusFlags()...)
+
 			if len(errs) > 0 {
 				return multierr.Combine(errs...)
 			}
@@ -62,29 +83,193 @@ func GenerateCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 
 			location := viper.GetString("corpora_location")
-			cfg, err := config.LoadConfig(configFile)
+			cfg, err := loadConfig()
 			if err != nil {
 				return err
 			}
 
-			fc, err := corpus.NewGenerator(cfg, afero.NewOsFs(), location)
+			opts := append(bulkActionOptions(generatorCorpusOptions(cmd)), corpus.WithFieldsCache(viper.GetString("fields_cache_location")))
+			if offline {
+				opts = append(opts, corpus.WithOffline())
+			}
+
+			fc, err := corpus.NewGenerator(cfg, afero.NewOsFs(), location, opts...)
 			if err != nil {
 				return err
 			}
 
-			payloadFilename, err := fc.Generate(packageRegistryBaseURL, integrationPackage, dataStream, packageVersion, totSize)
-			if err != nil {
+			ctx, cancel := generationContext(cmd)
+			defer cancel()
+
+			if dataStreams := dataStreamsArg(); len(dataStreams) != 1 {
+				payloadFilenames, err := fc.GenerateDataStreams(ctx, packageRegistryBaseURL, integrationPackage, dataStreams, packageVersion, totSize)
+				printGeneratedDataStreams(cmd, payloadFilenames, err)
+
 				return err
 			}
 
-			fmt.Println("File generated:", payloadFilename)
+			payloadFilename, err := fc.Generate(ctx, packageRegistryBaseURL, integrationPackage, dataStream, packageVersion, totSize)
+			printGenerated(cmd, payloadFilename, err)
 
-			return nil
+			return err
 		},
 	}
 
 	generateCmd.Flags().StringVarP(&packageRegistryBaseURL, "package-registry-base-url", "r", "https://epr.elastic.co/", "base url of the package registry with schema")
 	generateCmd.Flags().StringVarP(&configFile, "config-file", "c", "", "path to config file for generator settings")
 	generateCmd.Flags().StringVarP(&totSize, "tot-size", "t", "", "total size of the corpus to generate")
+	generateCmd.Flags().BoolVar(&offline, "offline", false, "load the fields from the cache of the ones downloaded by previous runs only, failing if they are not there, instead of from the package registry")
+	generateCmd.Flags().BoolVar(&allDataStreams, "all-data-streams", false, "generate a corpus for each data stream of the package, without passing the data stream argument")
+	addBulkActionFlags(generateCmd)
+	addGeneratorCorpusFlags(generateCmd)
+	addReportFormatFlag(generateCmd)
 	return generateCmd
 }
+
+// validateDataStreams validates the data stream argument, a comma separated list of data streams, unless
+// --all-data-streams is set.
+func validateDataStreams() []error {
+	if allDataStreams {
+		if dataStream != "" {
+			return []error{errors.New("you must not pass the data stream argument with --all-data-streams")}
+		}
+	} else {
+		for _, name := range strings.Split(dataStream, ",") {
+			if name == "" {
+				return []error{errors.New("you must provide a not empty data stream argument")}
+			}
+		}
+
+		if !strings.Contains(dataStream, ",") {
+			return nil
+		}
+	}
+
+	// the data streams are generated one after the other, each to its own file
+	var errs []error
+	if strings.HasPrefix(output, sink.LumberjackScheme) {
+		errs = append(errs, errors.New("--output lumberjack://host:port flag value cannot be used with more than one data stream"))
+	}
+
+	if rate != "" && duration == 0 {
+		errs = append(errs, errors.New("--rate flag requires --duration with more than one data stream"))
+	}
+
+	return errs
+}
+
+// dataStreamsArg returns the data streams of the data stream argument, none with --all-data-streams.
+func dataStreamsArg() []string {
+	if allDataStreams {
+		return nil
+	}
+
+	return strings.Split(dataStream, ",")
+}
+
+// addBulkActionFlags adds the flags of the bulk request actions preceding the events of the data streams.
+func addBulkActionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&idStrategy, "id-strategy", "", "_id of the bulk request actions: uuid, hash:field,... like hash:host.name,@timestamp, or timestamp-sequence; no _id if not provided")
+	cmd.Flags().IntVar(&idDuplicatePercentage, "id-duplicate-percentage", 0, "percentage of the events whose _id is one of the last ones generated by --id-strategy, to benchmark version conflicts and deduplication")
+	cmd.Flags().BoolVar(&duplicateSameID, "duplicate-same-id", false, "write the events duplicated by --duplicate-percentage with the bulk request action, and so the _id set by --id-strategy, of the ones they duplicate")
+	cmd.Flags().StringVar(&bulkIndex, "bulk-index", "", "target index or data stream of the bulk request actions, instead of <data-stream-type>-<package>.<data stream>-<namespace>")
+	cmd.Flags().StringVar(&dataStreamType, "data-stream-type", "metrics", "type of the target data stream of the bulk request actions, like logs")
+	cmd.Flags().StringVar(&namespace, "namespace", "default", "namespace of the target data stream of the bulk request actions")
+	cmd.Flags().IntVar(&namespaces, "namespaces", 1, "count of the namespaces the events are spread across in turn, <namespace>_1 to <namespace>_N, in the target of the bulk request actions and in --namespace-fields, to benchmark clusters with many data streams")
+	cmd.Flags().StringSliceVar(&namespaceFields, "namespace-fields", []string{"data_stream.namespace"}, "fields of the events set to the namespace they are spread to by --namespaces, when present")
+	cmd.Flags().StringVar(&opType, "op-type", corpus.OpTypeCreate, "operation of the bulk request actions: create, the only one accepted by data streams, or index")
+	cmd.Flags().StringVar(&pipeline, "pipeline", "", "ingest pipeline of the bulk request actions, the default one of the target if not provided")
+}
+
+// validateBulkActionFlags validates the flags of the bulk request actions, which require --format bulk when set.
+func validateBulkActionFlags(cmd *cobra.Command) []error {
+	var errs []error
+	if dataStreamType == "" {
+		errs = append(errs, errors.New("you must provide a not empty --data-stream-type flag value"))
+	}
+
+	if namespace == "" {
+		errs = append(errs, errors.New("you must provide a not empty --namespace flag value"))
+	}
+
+	if namespaces < 1 {
+		errs = append(errs, errors.New("you must provide a positive --namespaces flag value"))
+	} else if namespaces > 1 && bulkIndex != "" {
+		errs = append(errs, errors.New("--namespaces flag cannot be used with --bulk-index, the events are spread across the data streams of the namespaces"))
+	}
+
+	if err := corpus.ValidateOpType(opType); err != nil {
+		errs = append(errs, fmt.Errorf("you must provide a valid --op-type flag value: %w", err))
+	}
+
+	if idStrategy != "" {
+		if _, err := corpus.ParseIDStrategy(idStrategy); err != nil {
+			errs = append(errs, fmt.Errorf("you must provide a valid --id-strategy flag value: %w", err))
+		}
+	}
+
+	if idDuplicatePercentage < 0 || idDuplicatePercentage > 100 {
+		errs = append(errs, errors.New("--id-duplicate-percentage flag value must be between 0 and 100"))
+	} else if idDuplicatePercentage > 0 && idStrategy == "" {
+		errs = append(errs, errors.New("--id-duplicate-percentage flag requires --id-strategy"))
+	}
+
+	if duplicateSameID && (duplicatePercentage == 0 || idStrategy == "") {
+		errs = append(errs, errors.New("--duplicate-same-id flag requires --duplicate-percentage and --id-strategy"))
+	}
+
+	var changed []string
+	for _, name := range []string{"id-strategy", "id-duplicate-percentage", "duplicate-same-id", "bulk-index", "data-stream-type", "namespace", "namespaces", "namespace-fields", "op-type", "pipeline"} {
+		if cmd.Flags().Changed(name) {
+			changed = append(changed, "--"+name)
+		}
+	}
+
+	flags := strings.Join(changed, ", ") + " flag"
+	if len(changed) > 1 {
+		flags += "s"
+	}
+
+	if otlp != "" && len(changed) > 0 {
+		errs = append(errs, fmt.Errorf("%s cannot be used with --otlp, the OTLP export requests have no bulk request actions", flags))
+	} else if envelope == "" && format != "" && format != corpus.FormatBulk && len(changed) > 0 {
+		errs = append(errs, fmt.Errorf("%s must be used with the bulk request actions of --format bulk", flags))
+	}
+
+	if envelope != "" {
+		// the target of the bulk request actions is the default data stream of the envelope, the rest does not apply
+		for _, name := range []string{"id-strategy", "id-duplicate-percentage", "duplicate-same-id", "namespaces", "namespace-fields", "op-type", "pipeline"} {
+			if cmd.Flags().Changed(name) {
+				errs = append(errs, fmt.Errorf("--%s flag cannot be used with --envelope, the enveloped events have no bulk request actions", name))
+			}
+		}
+	}
+
+	return errs
+}
+
+// bulkActionOptions appends the options of the bulk request actions flags to opts.
+func bulkActionOptions(opts []corpus.GeneratorCorpusOption) []corpus.GeneratorCorpusOption {
+	opts = append(opts, corpus.WithBulkAction(corpus.BulkAction{
+		Index:           bulkIndex,
+		Type:            dataStreamType,
+		Namespace:       namespace,
+		OpType:          opType,
+		Pipeline:        pipeline,
+		Namespaces:      namespaces,
+		NamespaceFields: namespaceFields,
+	}))
+
+	if idStrategy == "" {
+		return opts
+	}
+
+	strategy, _ := corpus.ParseIDStrategy(idStrategy)
+	strategy.DuplicatePercentage = idDuplicatePercentage
+	opts = append(opts, corpus.WithIDStrategy(strategy))
+	if duplicateSameID {
+		opts = append(opts, corpus.WithDuplicates(corpus.Duplicates{Percentage: duplicatePercentage, SameID: true}))
+	}
+
+	return opts
+}
@@ -1,5 +1,803 @@
 package cmd
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sink"
+	"github.com/spf13/cobra"
+)
+
 var packageRegistryBaseURL string
 var configFile string
 var totSize string
+var events uint64
+var timeRangeFrom string
+var timeRangeTo string
+
+var seed int64
+var telemetryElasticsearchURL string
+var telemetryIndex string
+var traceFields uint64
+var strict bool
+var tsdb bool
+var tsdbSeries int
+var apm bool
+var apmMaxSpans int
+var ecsRealism bool
+var noJSONEscape bool
+var syntheticSource bool
+var jsonKeys string
+var otlp string
+var format string
+var extension string
+var otlpResourcePrefixes []string
+var envelope string
+var envelopeDataStream string
+var floatPrecision int
+var malformedPercentage float64
+var duplicatePercentage int
+var lateArrivalPercentage float64
+var maxLateness time.Duration
+var output string
+var filter string
+var sequencesFile string
+var rate string
+var duration time.Duration
+var expectedResults []string
+var lumberjackBatchSize int
+var maxFileSize string
+var maxEventsPerFile uint64
+var progress time.Duration
+var statsOutput string
+var timeout time.Duration
+var soak time.Duration
+var soakInterval time.Duration
+var soakWarmup time.Duration
+var soakMaxGrowth float64
+var auditFile string
+var manifest bool
+var resume string
+var referenceTime string
+var gzipOutput bool
+var writeBuffers int
+var writeBatchSize string
+
+// stdoutOutput is the --output flag value streaming the corpus to stdout
+const stdoutOutput = "-"
+
+// lumberjackTimeout is the timeout connecting to the lumberjack input and waiting for each window to be acknowledged
+const lumberjackTimeout = 30 * time.Second
+
+var filterMiddleware genlib.Middleware
+var sequences []genlib.Sequence
+var resumeCheckpoint corpus.Checkpoint
+var rateValue float64
+var lumberjackAddress string
+var httpOutput bool
+var objectStorageOutput bool
+var maxFileSizeValue uint64
+var writeBatchSizeValue uint64
+var expectedAggregations []corpus.ExpectedAggregation
+
+// auditOption is shared by the generators of the run, so that its audit record holds all their corpora
+var auditOption corpus.GeneratorCorpusOption
+
+var timeRangeFromValue time.Time
+var referenceTimeValue time.Time
+var timeRangeToValue time.Time
+
+// addGeneratorCorpusFlags adds the flags shared by the commands generating a corpus.
+func addGeneratorCorpusFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&timeRangeFrom, "time-range-from", "", "RFC3339 start of the time range the events will be spread across (requires --time-range-to)")
+	cmd.Flags().StringVar(&timeRangeTo, "time-range-to", "", "RFC3339 end of the time range the events will be spread across (requires --time-range-from)")
+	cmd.Flags().Uint64Var(&events, "events", 0, "count of events to generate, stopping at --tot-size if reached first; required by the quotas of the config")
+	cmd.Flags().Int64Var(&seed, "seed", 0, "seed of the random generators, 0 for a random seed")
+	cmd.Flags().Uint64Var(&traceFields, "trace-fields", 0, "trace to stderr how the fields have been generated for one event every N, 0 to disable")
+	cmd.Flags().StringVar(&filter, "filter", "", "text/template expression, like 'eq (field \"event.outcome\") \"failure\"', keeping only the events it evaluates to true for")
+	cmd.Flags().StringVar(&sequencesFile, "sequences-file", "", "path to the file of the sequences of correlated events to rewrite the events as, like a process starting, connecting and ending")
+	cmd.Flags().StringSliceVar(&expectedResults, "expected-results", nil, "aggregations, like terms:host.name, sum:network.bytes or cardinality:user.name, whose results on the corpus are written next to it")
+	cmd.Flags().BoolVar(&strict, "strict", false, "fail on unknown config keys, on config entries referencing fields not in the fields definition and on ignored config settings")
+	cmd.Flags().BoolVar(&tsdb, "tsdb", false, "generate for a TSDB data stream: fail if a dimension field is not generated in every event, and give the counter and gauge metric fields and the timestamps the semantics of their time series")
+	cmd.Flags().IntVar(&tsdbSeries, "tsdb-series", genlib.DefaultTimeSeries, "count of time series of --tsdb: the combinations of values of the dimension fields the events cycle through")
+	cmd.Flags().BoolVar(&apm, "apm", false, "generate APM traces: rewrite the events as transactions followed by their spans, with consistent trace.id, parent.id, timestamps and durations")
+	cmd.Flags().IntVar(&apmMaxSpans, "apm-max-spans", genlib.DefaultAPMMaxSpans, "most spans of the transaction of each trace of --apm")
+	cmd.Flags().StringSliceVar(&plugins, "plugin", nil, "path of a Go plugin exporting an Emitters function, returning custom emitters of the values of fields like JWTs or AWS ARNs, can be repeated")
+	cmd.Flags().BoolVar(&ecsRealism, "ecs-realism", false, "generate realistic values for well-known ECS fields, like user_agent.original or source.ip, unless configured otherwise")
+	cmd.Flags().IntVar(&floatPrecision, "float-precision", -1, "decimal places of the floating point values, unless set by the precision config entry; -1 for the default formatting")
+	cmd.Flags().BoolVar(&noJSONEscape, "no-json-escape", false, "do not escape quotes, backslashes and control characters of the generated string values, for templates of events that are not JSON")
+	cmd.Flags().BoolVar(&syntheticSource, "synthetic-source", false, "write the events the way Elasticsearch reconstructs them with synthetic _source: sorted keys, sorted and deduplicated arrays and numbers rounded to the type of their field")
+	cmd.Flags().StringVar(&jsonKeys, "json-keys", "", "dotted or nested: write the keys of the events as dotted names, like \"host.name\": \"x\", or as nested objects, like \"host\": {\"name\": \"x\"}, instead of as generated")
+	cmd.Flags().StringVar(&format, "format", "", "format of the corpus: bulk, the events preceded by the action of a bulk request, the default for the data streams; ndjson, the events only, the default for the templates; or raw, the events only without escaping the generated values, for plain log lines")
+	cmd.Flags().StringVar(&extension, "extension", "", "extension of the corpus file, like log, instead of ndjson for the data streams, log with --format raw, or the extension of the template")
+	cmd.Flags().StringVar(&otlp, "otlp", "", "logs or metrics: write the events as OTLP/JSON export requests of the given signal, one per line, for the otlpjsonfile receiver of an OpenTelemetry collector")
+	cmd.Flags().StringSliceVar(&otlpResourcePrefixes, "otlp-resource-prefixes", genlib.DefaultOTLPMapping().ResourcePrefixes, "prefixes of the fields written with --otlp as attributes of the resource, instead of the log record or the data points")
+	cmd.Flags().StringVar(&envelope, "envelope", "", "beat[@version], like filebeat@8.15.0: wrap the events in the publishing envelope of the given Beat or of the Elastic Agent, with the @metadata and data_stream fields, to replay them through a shipper or Logstash")
+	cmd.Flags().StringVar(&envelopeDataStream, "envelope-data-stream", "", "type-dataset-namespace data stream the events written with --envelope are routed to, the target of the bulk request actions for the data streams")
+	cmd.Flags().Float64Var(&malformedPercentage, "malformed-percentage", 0, "percentage of the events, from 0 to 100, to break on purpose as invalid JSON, with a field of the wrong type or without @timestamp, to test the handling of the failures of the ingest pipelines and the dead letter queues")
+	cmd.Flags().Float64Var(&lateArrivalPercentage, "late-arrival-percentage", 0, "percentage of the events, from 0 to 100, whose @timestamp is moved back by up to --max-lateness, so that they arrive after the events following them, to test the look-back of TSDB and the handling of latency")
+	cmd.Flags().DurationVar(&maxLateness, "max-lateness", 10*time.Minute, "most time the @timestamp of the events of --late-arrival-percentage is moved back by")
+	cmd.Flags().IntVar(&duplicatePercentage, "duplicate-percentage", 0, "percentage of the events, from 0 to 100, written again verbatim as one of the last ones generated, drawn at random, to benchmark deduplication and fingerprint processors")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "stop the generation after the given duration, keeping the partial corpus like on SIGINT, 0 to disable")
+	cmd.Flags().DurationVar(&soak, "soak", 0, "generate for the given duration while checking the memory usage does not grow, 0 to disable")
+	cmd.Flags().DurationVar(&soakInterval, "soak-interval", time.Minute, "interval of the memory usage samples of --soak, written to stderr")
+	cmd.Flags().DurationVar(&soakWarmup, "soak-warmup", 5*time.Minute, "time after the start of --soak the memory usage baseline is sampled at")
+	cmd.Flags().Float64Var(&soakMaxGrowth, "soak-max-growth", 0.5, "growth of the memory usage over the baseline failing --soak, 0.5 for 50%")
+	cmd.Flags().StringVar(&resume, "resume", "", "path of an interrupted corpus file to resume the generation of from its checkpoint, with the same flags as the interrupted run")
+	cmd.Flags().StringVar(&auditFile, "audit-file", "", "path of the file to write the audit record of the run to as JSON, with the seed, the hashes of the inputs and of the corpus, for the rerun command to reproduce it")
+	cmd.Flags().BoolVar(&manifest, "manifest", false, "write next to the corpus its manifest as JSON, with the config, the hashes of the fields definition and of the template, the seed, the count of events, the size and the duration of the generation, uploaded alongside it with --output s3:// or gs://")
+	// set by the rerun command to the reference time of the recorded run
+	cmd.Flags().StringVar(&referenceTime, "reference-time", "", "RFC3339 time the date fields are generated in the hour before, instead of the current time")
+	_ = cmd.Flags().MarkHidden("reference-time")
+
+	addOutputFlags(cmd)
+}
+
+// addOutputFlags adds the flags shared by the commands writing a corpus.
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&telemetryElasticsearchURL, "telemetry-elasticsearch-url", "", "url of the Elasticsearch cluster to index the run summary into, credentials can be provided in the url")
+	cmd.Flags().StringVar(&telemetryIndex, "telemetry-index", "corpus-generator-telemetry", "index to index the run summary into")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "set to - to stream the corpus to stdout, to lumberjack://host:port to ship the events to a Logstash or Elastic Agent lumberjack input, to an http(s):// url to send them in POST requests, to the bulk API of Elasticsearch for the urls ending with /_bulk, or to s3://bucket/prefix/ or gs://bucket/prefix/ to upload the corpus to object storage, instead of writing it to a file in the corpora location")
+	cmd.Flags().BoolVar(&gzipOutput, "gzip", false, "compress the corpus file with gzip, adding the .gz extension to its name")
+	cmd.Flags().IntVar(&writeBuffers, "write-buffers", 4, "number of buffers of --write-batch-size bytes of events queued to be written by a dedicated goroutine, so that slow disks and networks do not stall the generation; 0 to write synchronously")
+	cmd.Flags().StringVar(&writeBatchSize, "write-batch-size", "1MiB", "size of the batches of events the corpus file is written in, and of the buffers of --write-buffers, like 64KB: smaller batches take less memory, at the cost of more writes")
+	cmd.Flags().StringVar(&maxFileSize, "max-file-size", "", "split the corpus into numbered files of at most the given size, like 1GB")
+	cmd.Flags().Uint64Var(&maxEventsPerFile, "max-events-per-file", 0, "split the corpus into numbered files of at most the given number of events, 0 for no limit")
+	cmd.Flags().IntVar(&lumberjackBatchSize, "lumberjack-batch-size", 2048, "number of events shipped in each window with --output lumberjack://host:port")
+	cmd.Flags().DurationVar(&progress, "progress", 10*time.Second, "interval of the progress lines written to stderr, 0 to disable")
+	cmd.Flags().StringVar(&statsOutput, "stats-output", "", "path of the file to write the stats of the run to as JSON, including the cardinality achieved for each field")
+	cmd.Flags().StringVar(&rate, "rate", "", "generate events in real time at the given rate, like 500/s, 100/m or 10/h, until interrupted or --duration elapses")
+	cmd.Flags().DurationVar(&duration, "duration", 0, "duration of the generation when --rate is set, 0 to generate until interrupted")
+}
+
+// loadConfig loads the config file provided by the --config-file flag.
+func loadConfig() (config.Config, error) {
+	return config.LoadConfig(configFile, configLoadOptions()...)
+}
+
+// configLoadOptions returns the options loading the config according to the flags.
+func configLoadOptions() []config.LoadOption {
+	var opts []config.LoadOption
+	if strict {
+		opts = append(opts, config.WithStrict())
+	}
+
+	return opts
+}
+
+// validateGeneratorCorpusFlags validates the flags added by addGeneratorCorpusFlags.
+func validateGeneratorCorpusFlags() []error {
+	// the custom emitters of the plugins are registered before the config and the fields are checked
+	errs := loadPlugins()
+
+	errs = append(errs, validateTotSize()...)
+
+	errs = append(errs, validateTimeRange()...)
+
+	errs = append(errs, validateRate()...)
+
+	maxFileSizeValue = 0
+	if maxFileSize != "" {
+		var err error
+		if maxFileSizeValue, err = humanize.ParseBytes(maxFileSize); err != nil || maxFileSizeValue == 0 {
+			errs = append(errs, fmt.Errorf("you must provide a positive --max-file-size flag value, like 1GB, got %s", maxFileSize))
+		}
+	}
+
+	errs = append(errs, validateOutput()...)
+
+	if manifest && output != "" && !objectStorageOutput {
+		errs = append(errs, errors.New("--manifest flag can only be used with --output s3:// or gs://, the events shipped one by one have no corpus to write it next to"))
+	}
+
+	errs = append(errs, validateFloatPrecision()...)
+
+	referenceTimeValue = time.Time{}
+	if referenceTime != "" {
+		var err error
+		if referenceTimeValue, err = time.Parse(time.RFC3339Nano, referenceTime); err != nil {
+			errs = append(errs, fmt.Errorf("you must provide a RFC3339 --reference-time flag value: %w", err))
+		}
+	}
+
+	if format != "" {
+		if err := corpus.ValidateFormat(format); err != nil {
+			errs = append(errs, fmt.Errorf("you must provide a valid --format flag value: %w", err))
+		}
+	}
+
+	// the raw format does not escape the generated values either
+	rawValues := noJSONEscape || format == corpus.FormatRaw
+	if syntheticSource && rawValues {
+		errs = append(errs, errors.New("--synthetic-source flag cannot be used with --no-json-escape or --format raw, it requires events that are JSON objects"))
+	}
+
+	if jsonKeys != "" {
+		if jsonKeys != genlib.JSONKeysDotted && jsonKeys != genlib.JSONKeysNested {
+			errs = append(errs, fmt.Errorf("--json-keys flag value can only be %s or %s", genlib.JSONKeysDotted, genlib.JSONKeysNested))
+		}
+
+		if rawValues || syntheticSource || otlp != "" {
+			errs = append(errs, errors.New("--json-keys flag cannot be used with --no-json-escape, --format raw, --synthetic-source or --otlp"))
+		}
+	}
+
+	if malformedPercentage < 0 || malformedPercentage > 100 {
+		errs = append(errs, errors.New("you must provide a --malformed-percentage flag value between 0 and 100"))
+	}
+
+	if lateArrivalPercentage < 0 || lateArrivalPercentage > 100 {
+		errs = append(errs, errors.New("you must provide a --late-arrival-percentage flag value between 0 and 100"))
+	} else if lateArrivalPercentage > 0 && rawValues {
+		errs = append(errs, errors.New("--late-arrival-percentage flag cannot be used with --no-json-escape or --format raw, it requires events that are JSON objects"))
+	}
+
+	if maxLateness < time.Millisecond {
+		errs = append(errs, errors.New("you must provide a --max-lateness flag value of a millisecond at least"))
+	}
+
+	if duplicatePercentage < 0 || duplicatePercentage > 100 {
+		errs = append(errs, errors.New("you must provide a --duplicate-percentage flag value between 0 and 100"))
+	}
+
+	if tsdb && rawValues {
+		errs = append(errs, errors.New("--tsdb flag cannot be used with --no-json-escape or --format raw, it requires events that are JSON objects"))
+	}
+
+	sequences = nil
+	if sequencesFile != "" {
+		var err error
+		if sequences, err = genlib.LoadSequences(sequencesFile); err != nil {
+			errs = append(errs, fmt.Errorf("you must provide a valid --sequences-file flag value: %w", err))
+		} else if rawValues {
+			errs = append(errs, errors.New("--sequences-file flag cannot be used with --no-json-escape or --format raw, it requires events that are JSON objects"))
+		}
+	}
+
+	if apm && (rawValues || tsdb || otlp != "") {
+		errs = append(errs, errors.New("--apm flag cannot be used with --no-json-escape, --format raw, --tsdb or --otlp"))
+	}
+
+	if apmMaxSpans <= 0 {
+		errs = append(errs, errors.New("--apm-max-spans flag value must be positive"))
+	} else if !apm && apmMaxSpans != genlib.DefaultAPMMaxSpans {
+		errs = append(errs, errors.New("--apm-max-spans flag requires --apm"))
+	}
+
+	if tsdbSeries <= 0 {
+		errs = append(errs, errors.New("--tsdb-series flag value must be positive"))
+	} else if !tsdb && tsdbSeries != genlib.DefaultTimeSeries {
+		errs = append(errs, errors.New("--tsdb-series flag requires --tsdb"))
+	}
+
+	if otlp != "" {
+		if otlp != genlib.OTLPLogs && otlp != genlib.OTLPMetrics {
+			errs = append(errs, fmt.Errorf("--otlp flag value can only be %s or %s", genlib.OTLPLogs, genlib.OTLPMetrics))
+		}
+
+		if rawValues || syntheticSource {
+			errs = append(errs, errors.New("--otlp flag cannot be used with --no-json-escape, --format raw or --synthetic-source"))
+		}
+
+		if format == corpus.FormatBulk {
+			errs = append(errs, errors.New("--otlp flag cannot be used with --format bulk, the OTLP export requests have no bulk request actions"))
+		}
+	}
+
+	if envelope != "" {
+		if _, err := genlib.ParseEnvelope(envelope); err != nil {
+			errs = append(errs, fmt.Errorf("you must provide a valid --envelope flag value: %w", err))
+		}
+
+		if envelopeDataStream != "" {
+			if _, err := genlib.NewEnvelope(genlib.Envelope{DataStream: envelopeDataStream}); err != nil {
+				errs = append(errs, fmt.Errorf("you must provide a valid --envelope-data-stream flag value: %w", err))
+			}
+		}
+
+		if rawValues || otlp != "" {
+			errs = append(errs, errors.New("--envelope flag cannot be used with --no-json-escape, --format raw or --otlp"))
+		}
+
+		if format == corpus.FormatBulk {
+			errs = append(errs, errors.New("--envelope flag cannot be used with --format bulk, the enveloped events have no bulk request actions"))
+		}
+	} else if envelopeDataStream != "" {
+		errs = append(errs, errors.New("--envelope-data-stream flag requires --envelope"))
+	}
+
+	if resume != "" {
+		var err error
+		if resumeCheckpoint, err = corpus.LoadCheckpoint(resume); err != nil {
+			errs = append(errs, fmt.Errorf("you must provide a valid --resume flag value: %w", err))
+		}
+
+		if output != "" || gzipOutput || maxFileSize != "" || maxEventsPerFile > 0 || rate != "" || soak > 0 || auditFile != "" {
+			errs = append(errs, errors.New("--resume flag cannot be used with --output, --gzip, --max-file-size, --max-events-per-file, --rate, --soak or --audit-file"))
+		}
+	}
+
+	if timeout < 0 {
+		errs = append(errs, errors.New("you must provide a not negative --timeout flag value"))
+	}
+
+	errs = append(errs, validateSoak()...)
+
+	filterMiddleware = nil
+	if filter != "" {
+		var err error
+		if filterMiddleware, err = genlib.NewFilter(filter); err != nil {
+			errs = append(errs, fmt.Errorf("you must provide a valid --filter flag value: %w", err))
+		}
+	}
+
+	expectedAggregations = nil
+	for _, s := range expectedResults {
+		aggregation, err := corpus.ParseExpectedAggregation(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("you must provide a valid --expected-results flag value: %w", err))
+			continue
+		}
+
+		expectedAggregations = append(expectedAggregations, aggregation)
+	}
+
+	if telemetryElasticsearchURL != "" && telemetryIndex == "" {
+		errs = append(errs, errors.New("you must provide a not empty --telemetry-index flag value"))
+	}
+
+	auditOption = nil
+	return errs
+}
+
+// generationContext returns the context of the generation of cmd: done on SIGINT or SIGTERM, and after --timeout if
+// set, the generation stopping with a partial corpus.
+func generationContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(cmd.Context(), timeout)
+	}
+
+	return context.WithCancel(cmd.Context())
+}
+
+// validateOutput parses the output flag, and checks it is compatible with the flags of the corpus file.
+func validateOutput() []error {
+	var errs []error
+	lumberjackAddress = ""
+	httpOutput = strings.HasPrefix(output, "http://") || strings.HasPrefix(output, "https://")
+	objectStorageOutput = strings.HasPrefix(output, sink.S3Scheme) || strings.HasPrefix(output, sink.GCSScheme)
+	if strings.HasPrefix(output, sink.LumberjackScheme) {
+		var err error
+		if lumberjackAddress, err = sink.ParseLumberjackAddress(output); err != nil {
+			errs = append(errs, fmt.Errorf("you must provide a valid --output flag value: %w", err))
+		}
+
+		if lumberjackBatchSize <= 0 {
+			errs = append(errs, errors.New("you must provide a positive --lumberjack-batch-size flag value"))
+		}
+	} else if httpOutput {
+		if _, err := newHTTPOutputSink(); err != nil {
+			errs = append(errs, fmt.Errorf("you must provide a valid --output flag value: %w", err))
+		}
+	} else if objectStorageOutput {
+		if _, err := newObjectStorageOutputSink(); err != nil {
+			errs = append(errs, fmt.Errorf("you must provide a valid --output flag value: %w", err))
+		}
+	} else if output != "" && output != stdoutOutput {
+		errs = append(errs, errors.New("--output flag value can only be -, lumberjack://host:port, an http(s):// url or an s3:// or gs:// bucket url"))
+	}
+
+	if (maxFileSize != "" || maxEventsPerFile > 0) && output != "" {
+		errs = append(errs, errors.New("--max-file-size and --max-events-per-file flags cannot be used with --output"))
+	}
+
+	if writeBuffers < 0 {
+		errs = append(errs, errors.New("you must provide a not negative --write-buffers flag value"))
+	}
+
+	writeBatchSizeValue = 0
+	if writeBatchSize != "" {
+		var err error
+		if writeBatchSizeValue, err = humanize.ParseBytes(writeBatchSize); err != nil || writeBatchSizeValue == 0 || writeBatchSizeValue > math.MaxInt32 {
+			errs = append(errs, fmt.Errorf("you must provide a positive --write-batch-size flag value up to 2GB, like 64KB, got %s", writeBatchSize))
+		}
+	}
+
+	if gzipOutput && (output != "" || maxFileSize != "" || maxEventsPerFile > 0) {
+		errs = append(errs, errors.New("--gzip flag cannot be used with --output, --max-file-size and --max-events-per-file"))
+	}
+
+	if len(expectedResults) > 0 && output != "" {
+		errs = append(errs, errors.New("--expected-results flag cannot be used with --output, the results are written next to the corpus file"))
+	}
+
+	return errs
+}
+
+// newHTTPOutputSink returns the sink of an http(s):// --output flag value: the bulk API of Elasticsearch for the
+// urls ending with /_bulk, an HTTP endpoint otherwise.
+func newHTTPOutputSink() (sink.Sink, error) {
+	u, err := url.Parse(output)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(u.Path, "/_bulk") {
+		u.Path = strings.TrimSuffix(u.Path, "/_bulk")
+		return sink.NewElasticsearch(u.String())
+	}
+
+	return sink.NewHTTP(output)
+}
+
+// newObjectStorageOutputSink returns the sink of an s3:// or gs:// --output flag value, with the credentials of the
+// environment: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_REGION for S3, or for an S3
+// compatible service at AWS_ENDPOINT_URL, and the HMAC key GCS_HMAC_ACCESS_KEY_ID and GCS_HMAC_SECRET for Cloud Storage.
+func newObjectStorageOutputSink() (sink.Sink, error) {
+	var opts []sink.ObjectStorageOption
+	if strings.HasPrefix(output, sink.GCSScheme) {
+		opts = append(opts, sink.WithCredentials(os.Getenv("GCS_HMAC_ACCESS_KEY_ID"), os.Getenv("GCS_HMAC_SECRET"), ""))
+		return sink.NewObjectStorage(output, opts...)
+	}
+
+	opts = append(opts, sink.WithCredentials(os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")))
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		opts = append(opts, sink.WithRegion(region))
+	}
+
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("AWS_ENDPOINT_URL: %w", err)
+		}
+
+		opts = append(opts, sink.WithEndpoint(u))
+	}
+
+	return sink.NewObjectStorage(output, opts...)
+}
+
+// validateTotSize checks the tot-size flag, if set: a size, like 1GB.
+func validateTotSize() []error {
+	if totSize == "" {
+		return nil
+	}
+
+	if _, err := humanize.ParseBytes(totSize); err != nil {
+		return []error{fmt.Errorf("you must provide a valid --tot-size flag value, like 1GB, got %s", totSize)}
+	}
+
+	return nil
+}
+
+// validateRate parses the rate flag: events per second, minute or hour, like 500/s.
+func validateRate() []error {
+	rateValue = 0
+	if rate == "" {
+		if duration > 0 {
+			return []error{errors.New("--duration flag requires --rate")}
+		}
+
+		return nil
+	}
+
+	var errs []error
+	if timeRangeFrom != "" || timeRangeTo != "" {
+		errs = append(errs, errors.New("--rate flag cannot be used with --time-range-from and --time-range-to"))
+	}
+
+	if duration < 0 {
+		errs = append(errs, errors.New("you must provide a positive --duration flag value"))
+	}
+
+	count, unit, found := strings.Cut(rate, "/")
+	if !found {
+		unit = "s"
+	}
+
+	units := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+	per, ok := units[unit]
+	value, err := strconv.ParseFloat(count, 64)
+	if !ok || err != nil || value <= 0 {
+		return append(errs, fmt.Errorf("you must provide a --rate flag value like 500/s, 100/m or 10/h, got %s", rate))
+	}
+
+	rateValue = value / per.Seconds()
+	return errs
+}
+
+// validateTimeRange parses the time range flags, they must be either both empty or both valid with from before to.
+func validateTimeRange() []error {
+	timeRangeFromValue, timeRangeToValue = time.Time{}, time.Time{}
+	if timeRangeFrom == "" && timeRangeTo == "" {
+		return nil
+	}
+
+	if timeRangeFrom == "" || timeRangeTo == "" {
+		return []error{errors.New("you must provide both --time-range-from and --time-range-to flag values")}
+	}
+
+	var errs []error
+	var err error
+	timeRangeFromValue, err = time.Parse(time.RFC3339, timeRangeFrom)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("you must provide a RFC3339 --time-range-from flag value: %w", err))
+	}
+
+	timeRangeToValue, err = time.Parse(time.RFC3339, timeRangeTo)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("you must provide a RFC3339 --time-range-to flag value: %w", err))
+	}
+
+	if len(errs) == 0 && !timeRangeFromValue.Before(timeRangeToValue) {
+		errs = append(errs, errors.New("--time-range-from flag value must be before --time-range-to flag value"))
+	}
+
+	return errs
+}
+
+// printGeneratedDataStreams reports the files the corpora of the data streams have been written to, err is the one
+// of the generation of the last of them.
+func printGeneratedDataStreams(cmd *cobra.Command, payloadFilenames []string, err error) {
+	for i, payloadFilename := range payloadFilenames {
+		if i == len(payloadFilenames)-1 {
+			printGenerated(cmd, payloadFilename, err)
+		} else {
+			printGenerated(cmd, payloadFilename, nil)
+		}
+	}
+}
+
+// printGenerated reports the file the corpus has been written to, and its expected results file if any, unless it has been streamed to stdout.
+func printGenerated(cmd *cobra.Command, payloadFilename string, err error) {
+	if output == stdoutOutput || err != nil && !errors.Is(err, corpus.ErrInterrupted) {
+		return
+	}
+
+	if lumberjackAddress != "" || httpOutput {
+		fmt.Fprintln(cmd.OutOrStdout(), "Events shipped to:", payloadFilename)
+		return
+	}
+
+	what, generated := "File", payloadFilename
+	if maxFileSizeValue > 0 || maxEventsPerFile > 0 {
+		what, generated = "Files", corpus.ChunksPattern(payloadFilename)
+	}
+
+	if err != nil {
+		fmt.Fprintln(cmd.OutOrStdout(), what+" partially generated:", generated)
+	} else {
+		fmt.Fprintln(cmd.OutOrStdout(), what+" generated:", generated)
+	}
+
+	if len(expectedAggregations) > 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "Expected results generated:", corpus.ExpectedResultsFilename(payloadFilename))
+	}
+}
+
+func generatorCorpusOptions(cmd *cobra.Command) []corpus.GeneratorCorpusOption {
+	opts := []corpus.GeneratorCorpusOption{corpus.WithSeed(seed), corpus.WithWarnings(cmd.ErrOrStderr())}
+	opts = append(opts, includeDirOptions()...)
+	if events > 0 {
+		opts = append(opts, corpus.WithEvents(events))
+	}
+
+	if !timeRangeFromValue.IsZero() {
+		opts = append(opts, corpus.WithTimeRange(timeRangeFromValue, timeRangeToValue))
+	}
+
+	if telemetryElasticsearchURL != "" {
+		opts = append(opts, corpus.WithTelemetry(telemetryElasticsearchURL, telemetryIndex))
+	}
+
+	if strict {
+		opts = append(opts, corpus.WithStrict())
+	}
+
+	if tsdb {
+		opts = append(opts, corpus.WithTSDB(), corpus.WithTSDBSeries(tsdbSeries))
+	}
+
+	if apm {
+		opts = append(opts, corpus.WithAPM(), corpus.WithAPMMaxSpans(apmMaxSpans))
+	}
+
+	if ecsRealism {
+		opts = append(opts, corpus.WithECSRealism())
+	}
+
+	if noJSONEscape {
+		opts = append(opts, corpus.WithoutJSONEscape())
+	}
+
+	if format != "" {
+		opts = append(opts, corpus.WithFormat(format))
+	}
+
+	if extension != "" {
+		opts = append(opts, corpus.WithExtension(extension))
+	}
+
+	if floatPrecision >= 0 {
+		opts = append(opts, corpus.WithFloatPrecision(floatPrecision))
+	}
+
+	if syntheticSource {
+		opts = append(opts, corpus.WithSyntheticSource())
+	}
+
+	if jsonKeys != "" {
+		opts = append(opts, corpus.WithJSONKeys(jsonKeys))
+	}
+
+	if otlp != "" {
+		mapping := genlib.DefaultOTLPMapping()
+		mapping.ResourcePrefixes = otlpResourcePrefixes
+		opts = append(opts, corpus.WithOTLP(otlp, mapping))
+	}
+
+	if envelope != "" {
+		// validated by validateGeneratorCorpusFlags
+		env, _ := genlib.ParseEnvelope(envelope)
+		env.DataStream = envelopeDataStream
+		opts = append(opts, corpus.WithEnvelope(env))
+	}
+
+	if malformedPercentage > 0 {
+		opts = append(opts, corpus.WithMalformedPercentage(malformedPercentage))
+	}
+
+	if lateArrivalPercentage > 0 {
+		opts = append(opts, corpus.WithLateArrivals(lateArrivalPercentage, maxLateness))
+	}
+
+	if duplicatePercentage > 0 {
+		opts = append(opts, corpus.WithDuplicates(corpus.Duplicates{Percentage: duplicatePercentage}))
+	}
+
+	if rateValue > 0 {
+		opts = append(opts, corpus.WithRate(rateValue, duration))
+	}
+
+	if filterMiddleware != nil {
+		opts = append(opts, corpus.WithMiddlewares(filterMiddleware))
+	}
+
+	if len(sequences) > 0 {
+		opts = append(opts, corpus.WithSequences(sequences...))
+	}
+
+	if len(expectedAggregations) > 0 {
+		opts = append(opts, corpus.WithExpectedResults(expectedAggregations...))
+	}
+
+	if output == stdoutOutput {
+		opts = append(opts, corpus.WithSink(sink.NewWriter(stdoutOutput, cmd.OutOrStdout())))
+	}
+
+	if httpOutput {
+		// validated by validateOutput
+		httpSink, _ := newHTTPOutputSink()
+		opts = append(opts, corpus.WithSink(httpSink))
+	}
+
+	if objectStorageOutput {
+		// validated by validateOutput
+		objectStorageSink, _ := newObjectStorageOutputSink()
+		opts = append(opts, corpus.WithSink(objectStorageSink))
+	}
+
+	if gzipOutput {
+		opts = append(opts, corpus.WithGzip())
+	}
+
+	if writeBuffers > 0 {
+		opts = append(opts, corpus.WithAsyncWrites(writeBuffers))
+	}
+
+	if writeBatchSizeValue > 0 {
+		opts = append(opts, corpus.WithWriteBatchSize(int(writeBatchSizeValue)))
+	}
+
+	if maxFileSizeValue > 0 || maxEventsPerFile > 0 {
+		opts = append(opts, corpus.WithSplit(maxFileSizeValue, maxEventsPerFile))
+	}
+
+	if lumberjackAddress != "" {
+		opts = append(opts, corpus.WithSink(sink.NewLumberjack(lumberjackAddress, lumberjackBatchSize, lumberjackTimeout)))
+	}
+
+	if progress > 0 {
+		opts = append(opts, corpus.WithProgress(cmd.ErrOrStderr(), progress))
+	}
+
+	if !referenceTimeValue.IsZero() {
+		opts = append(opts, corpus.WithReferenceTime(referenceTimeValue))
+	}
+
+	if auditFile != "" {
+		if auditOption == nil {
+			auditOption = corpus.WithAudit(auditFile, auditCommand(cmd), auditInputPaths(cmd))
+		}
+
+		opts = append(opts, auditOption)
+	}
+
+	if manifest {
+		var configContent []byte
+		if configFile != "" {
+			// already loaded, it exists
+			configContent, _ = os.ReadFile(configFile)
+		}
+
+		opts = append(opts, corpus.WithManifest(configContent))
+	}
+
+	if statsOutput != "" {
+		opts = append(opts, corpus.WithStats(statsOutput))
+	}
+
+	if traceFields > 0 {
+		opts = append(opts, corpus.WithFieldsTracing(traceFields, cmd.ErrOrStderr()))
+	}
+
+	if soak > 0 {
+		opts = append(opts, corpus.WithSoak(cmd.ErrOrStderr(), corpus.SoakOptions{
+			Duration:  soak,
+			Interval:  soakInterval,
+			Warmup:    soakWarmup,
+			MaxGrowth: soakMaxGrowth,
+		}))
+	}
+
+	// the seed of the checkpoint overrides the one of --seed
+	if resume != "" {
+		opts = append(opts, corpus.WithResume(resume, resumeCheckpoint))
+	}
+
+	return opts
+}
+
+func validateFloatPrecision() []error {
+	if floatPrecision < -1 {
+		return []error{errors.New("you must provide a positive --float-precision flag value, or -1 for the default formatting")}
+	}
+
+	return nil
+}
+
+func validateSoak() []error {
+	if soak == 0 {
+		return nil
+	}
+
+	var errs []error
+	if soak < 0 {
+		errs = append(errs, errors.New("you must provide a positive --soak flag value"))
+	}
+
+	if soakInterval <= 0 {
+		errs = append(errs, errors.New("you must provide a positive --soak-interval flag value"))
+	}
+
+	if soakWarmup < 0 {
+		errs = append(errs, errors.New("you must provide a positive --soak-warmup flag value"))
+	}
+
+	if soakMaxGrowth <= 0 {
+		errs = append(errs, errors.New("you must provide a positive --soak-max-growth flag value"))
+	}
+
+	return errs
+}
@@ -0,0 +1,94 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/multierr"
+)
+
+var packagePath string
+
+func GenerateFromPackageCmd() *cobra.Command {
+	generateFromPackageCmd := &cobra.Command{
+		Use:   "generate-from-package package-path data_stream",
+		Short: "Generate a corpus",
+		Long:  "Generate a bulk request corpus for a given data stream of a local integration package, either its source directory or its zip archive",
+		Args: func(cmd *cobra.Command, args []string) error {
+			var errs []error
+			if allDataStreams {
+				if len(args) != 1 {
+					return errors.New("you must pass the package path with --all-data-streams")
+				}
+
+				args = []string{args[0], ""}
+			} else if len(args) != 2 {
+				return errors.New("you must pass the package path and the data stream")
+			}
+
+			if totSize == "" && events == 0 && rate == "" && soak == 0 {
+				errs = append(errs, errors.New("you must provide a not empty --tot-size flag value, unless --events, --rate or --soak is set"))
+			}
+
+			packagePath = args[0]
+			if packagePath == "" {
+				errs = append(errs, errors.New("you must provide a not empty package path argument"))
+			}
+
+			dataStream = args[1]
+			errs = append(errs, validateDataStreams()...)
+
+			errs = append(errs, validateBulkActionFlags(cmd)...)
+			errs = append(errs, validateGeneratorCorpusFlags()...)
+
+			if len(errs) > 0 {
+				return multierr.Combine(errs...)
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			location := viper.GetString("corpora_location")
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			fc, err := corpus.NewGenerator(cfg, afero.NewOsFs(), location, bulkActionOptions(generatorCorpusOptions(cmd))...)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := generationContext(cmd)
+			defer cancel()
+
+			if dataStreams := dataStreamsArg(); len(dataStreams) != 1 {
+				payloadFilenames, err := fc.GenerateFromPackageDataStreams(ctx, packagePath, dataStreams, totSize)
+				printGeneratedDataStreams(cmd, payloadFilenames, err)
+
+				return err
+			}
+
+			payloadFilename, err := fc.GenerateFromPackage(ctx, packagePath, dataStream, totSize)
+			printGenerated(cmd, payloadFilename, err)
+
+			return err
+		},
+	}
+
+	generateFromPackageCmd.Flags().StringVarP(&configFile, "config-file", "c", "", "path to config file for generator settings")
+	generateFromPackageCmd.Flags().StringVarP(&totSize, "tot-size", "t", "", "total size of the corpus to generate")
+	generateFromPackageCmd.Flags().BoolVar(&allDataStreams, "all-data-streams", false, "generate a corpus for each data stream of the package, without passing the data stream argument")
+	addBulkActionFlags(generateFromPackageCmd)
+	addGeneratorCorpusFlags(generateFromPackageCmd)
+	addReportFormatFlag(generateFromPackageCmd)
+	return generateFromPackageCmd
+}
@@ -0,0 +1,104 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/multierr"
+)
+
+var assetsDir string
+var samplePaths []string
+
+func GenerateFromSampleCmd() *cobra.Command {
+	generateFromSampleCmd := &cobra.Command{
+		Use:   "generate-from-sample sample-path...",
+		Short: "Generate a corpus from sample documents",
+		Long:  "Infer a structured template, a fields definition and a config from sample JSON documents, and generate a bulk request corpus of similar documents with them",
+		Args: func(cmd *cobra.Command, args []string) error {
+			var errs []error
+			if len(args) == 0 {
+				return errors.New("you must pass at least a sample path")
+			}
+
+			samplePaths = args
+			for _, samplePath := range samplePaths {
+				if samplePath == "" {
+					errs = append(errs, errors.New("you must provide not empty sample path arguments"))
+					break
+				}
+			}
+
+			errs = append(errs, validateGeneratorCorpusFlags()...)
+
+			if len(errs) > 0 {
+				return multierr.Combine(errs...)
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs := afero.NewOsFs()
+			assets, err := corpus.InferFromSamples(fs, samplePaths)
+			if err != nil {
+				return err
+			}
+
+			dir := assetsDir
+			if dir == "" {
+				dir = filepath.Dir(samplePaths[0])
+			}
+
+			name := strings.TrimSuffix(filepath.Base(samplePaths[0]), filepath.Ext(samplePaths[0]))
+			templatePath, fieldsDefinitionPath, configPath, err := assets.Write(fs, dir, name)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), "Template generated:", templatePath)
+			fmt.Fprintln(cmd.OutOrStdout(), "Fields definition generated:", fieldsDefinitionPath)
+			fmt.Fprintln(cmd.OutOrStdout(), "Config generated:", configPath)
+
+			// the assets can be reviewed and tuned before generating the corpus with generate-with-template
+			if totSize == "" && events == 0 && rate == "" && soak == 0 {
+				return nil
+			}
+
+			configFile = configPath
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			location := viper.GetString("corpora_location")
+			fc, err := corpus.NewGeneratorWithTemplate(cfg, fs, location, "structured", generatorCorpusOptions(cmd)...)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := generationContext(cmd)
+			defer cancel()
+
+			payloadFilename, err := fc.GenerateWithTemplate(ctx, templatePath, fieldsDefinitionPath, totSize)
+			printGenerated(cmd, payloadFilename, err)
+
+			return err
+		},
+	}
+
+	generateFromSampleCmd.Flags().StringVar(&assetsDir, "assets-dir", "", "directory the template, the fields definition and the config are written to, the directory of the first sample by default")
+	generateFromSampleCmd.Flags().StringVarP(&totSize, "tot-size", "t", "", "total size of the corpus to generate, no corpus is generated unless it, --events, --rate or --soak is set")
+	addGeneratorCorpusFlags(generateFromSampleCmd)
+	addReportFormatFlag(generateFromSampleCmd)
+	return generateFromSampleCmd
+}
@@ -0,0 +1,109 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sink"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/multierr"
+)
+
+var scenarioPath string
+
+func GenerateScenarioCmd() *cobra.Command {
+	generateScenarioCmd := &cobra.Command{
+		Use:   "generate-scenario scenario-path",
+		Short: "Generate the corpora of a scenario",
+		Long:  "Generate the corpora described by the entries of a scenario file, each with its template, fields definition, config, size and output",
+		Args: func(cmd *cobra.Command, args []string) error {
+			var errs []error
+			if len(args) != 1 {
+				return errors.New("you must pass the scenario path")
+			}
+
+			scenarioPath = args[0]
+			if scenarioPath == "" {
+				errs = append(errs, errors.New("you must provide a not empty scenario path argument"))
+			}
+
+			errs = append(errs, validateGeneratorCorpusFlags()...)
+			if resume != "" {
+				errs = append(errs, errors.New("--resume flag cannot be used with scenarios, only a single corpus can be resumed"))
+			}
+
+			if len(errs) > 0 {
+				return multierr.Combine(errs...)
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			scenario, err := corpus.LoadScenario(scenarioPath)
+			if err != nil {
+				return err
+			}
+
+			if auditFile != "" {
+				auditOption = corpus.WithAudit(auditFile, auditCommand(cmd), append(auditInputPaths(cmd), scenario.Paths()...))
+			}
+
+			location := viper.GetString("corpora_location")
+			outputFlag := output
+
+			ctx, cancel := generationContext(cmd)
+			defer cancel()
+
+			for _, entry := range scenario {
+				// the entries are generated with the flags of the command, their output takes precedence over --output
+				configFile, templateType, totSize, output = entry.Config, entry.TemplateType, entry.TotSize, outputFlag
+				var opts []corpus.GeneratorCorpusOption
+				if entry.Events > 0 {
+					opts = append(opts, corpus.WithEvents(entry.Events))
+				}
+
+				if entry.Output == stdoutOutput || strings.HasPrefix(entry.Output, sink.LumberjackScheme) {
+					output = entry.Output
+				} else if entry.Output != "" {
+					output = ""
+					opts = append(opts, corpus.WithFilename(entry.Output))
+				}
+
+				if errs := validateOutput(); len(errs) > 0 {
+					return fmt.Errorf("scenario entry %s: %w", entry.Name, multierr.Combine(errs...))
+				}
+
+				cfg, err := loadConfig()
+				if err != nil {
+					return fmt.Errorf("scenario entry %s: %w", entry.Name, err)
+				}
+
+				fc, err := corpus.NewGeneratorWithTemplate(cfg, afero.NewOsFs(), location, templateType, append(generatorCorpusOptions(cmd), opts...)...)
+				if err != nil {
+					return fmt.Errorf("scenario entry %s: %w", entry.Name, err)
+				}
+
+				payloadFilename, err := fc.GenerateWithTemplate(ctx, entry.Template, entry.Fields, totSize)
+				printGenerated(cmd, payloadFilename, err)
+				if err != nil {
+					return fmt.Errorf("scenario entry %s: %w", entry.Name, err)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	addIncludeDirFlag(generateScenarioCmd)
+	addGeneratorCorpusFlags(generateScenarioCmd)
+	addReportFormatFlag(generateScenarioCmd)
+	return generateScenarioCmd
+}
@@ -6,9 +6,7 @@ package cmd
 
 import (
 	"errors"
-	"fmt"
 	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
-	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -18,21 +16,28 @@ import (
 var templateType string
 
 var templatePath string
+var includeDir string
 var fieldsDefinitionPath string
+var specFile string
+var spec corpus.Spec
 
 func GenerateWithTemplateCmd() *cobra.Command {
 	generateWithTemplateCmd := &cobra.Command{
 		Use:   "generate-with-template template-path fields-definition-path",
 		Short: "Generate a corpus",
-		Long:  "Generate a bulk request corpus given a template path and a fields definition path",
+		Long:  "Generate a bulk request corpus given a template path and a fields definition path, or a corpus spec file",
 		Args: func(cmd *cobra.Command, args []string) error {
+			if specFile != "" {
+				return validateSpecFlags(cmd, args)
+			}
+
 			var errs []error
 			if len(args) != 2 {
 				return errors.New("you must pass the template path and the fields definition path")
 			}
 
-			if totSize == "" {
-				errs = append(errs, errors.New("you must provide a not empty --tot-size flag value"))
+			if totSize == "" && events == 0 && rate == "" && soak == 0 {
+				errs = append(errs, errors.New("you must provide a not empty --tot-size flag value, unless --events, --rate or --soak is set"))
 			}
 
 			templatePath = args[0]
@@ -45,6 +50,8 @@ func GenerateWithTemplateCmd() *cobra.Command {
 				errs = append(errs, errors.New("you must provide a not empty fields definition path argument"))
 			}
 
+			errs = append(errs, validateGeneratorCorpusFlags()...)
+
 			if len(errs) > 0 {
 				return multierr.Combine(errs...)
 			}
@@ -52,31 +59,107 @@ func GenerateWithTemplateCmd() *cobra.Command {
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-
 			location := viper.GetString("corpora_location")
-			cfg, err := config.LoadConfig(configFile)
-			if err != nil {
-				return err
+			if specFile != "" {
+				return generateFromSpec(cmd, location)
 			}
 
-			fc, err := corpus.NewGeneratorWithTemplate(cfg, afero.NewOsFs(), location, templateType)
+			cfg, err := loadConfig()
 			if err != nil {
 				return err
 			}
 
-			payloadFilename, err := fc.GenerateWithTemplate(templatePath, fieldsDefinitionPath, totSize)
+			fc, err := corpus.NewGeneratorWithTemplate(cfg, afero.NewOsFs(), location, templateType, generatorCorpusOptions(cmd)...)
 			if err != nil {
 				return err
 			}
 
-			fmt.Println("File generated:", payloadFilename)
+			ctx, cancel := generationContext(cmd)
+			defer cancel()
 
-			return nil
+			payloadFilename, err := fc.GenerateWithTemplate(ctx, templatePath, fieldsDefinitionPath, totSize)
+			printGenerated(cmd, payloadFilename, err)
+
+			return err
 		},
 	}
 
 	generateWithTemplateCmd.Flags().StringVarP(&configFile, "config-file", "c", "", "path to config file for generator settings")
-	generateWithTemplateCmd.Flags().StringVarP(&templateType, "template-type", "y", "placeholder", "either 'placeholder' or 'gotext'")
+	generateWithTemplateCmd.Flags().StringVarP(&templateType, "template-type", "y", "placeholder", "either 'placeholder', 'gotext' or 'structured'")
 	generateWithTemplateCmd.Flags().StringVarP(&totSize, "tot-size", "t", "", "total size of the corpus to generate")
+	generateWithTemplateCmd.Flags().StringVar(&specFile, "spec-file", "", "path to a corpus spec file, with the fields definition and the config inline, the template path and the output options, instead of the arguments")
+	addIncludeDirFlag(generateWithTemplateCmd)
+	addGeneratorCorpusFlags(generateWithTemplateCmd)
+	addReportFormatFlag(generateWithTemplateCmd)
 	return generateWithTemplateCmd
 }
+
+// addIncludeDirFlag adds the flag of the directory of the partials included by the templates.
+func addIncludeDirFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&includeDir, "include-dir", "", "directory of the partials the templates include with {{ template \"name\" }}, each one named after its file without the extension, like name.json")
+}
+
+// includeDirOptions returns the option expanding the partials of --include-dir, if set.
+func includeDirOptions() []corpus.GeneratorCorpusOption {
+	if includeDir == "" {
+		return nil
+	}
+
+	return []corpus.GeneratorCorpusOption{corpus.WithIncludeDir(includeDir)}
+}
+
+// validateSpecFlags validates the flags of a run generating the corpus of --spec-file, loading it.
+func validateSpecFlags(cmd *cobra.Command, args []string) error {
+	var errs []error
+	if len(args) > 0 {
+		errs = append(errs, errors.New("you must not pass the template path and the fields definition path with --spec-file"))
+	}
+
+	if configFile != "" || cmd.Flags().Changed("template-type") {
+		errs = append(errs, errors.New("--config-file and --template-type flags cannot be used with --spec-file, the spec provides them"))
+	}
+
+	errs = append(errs, validateGeneratorCorpusFlags()...)
+	if len(errs) > 0 {
+		return multierr.Combine(errs...)
+	}
+
+	var err error
+	if spec, err = corpus.LoadSpec(specFile, configLoadOptions()...); err != nil {
+		return err
+	}
+
+	if totSize == "" && events == 0 && rate == "" && soak == 0 && spec.TotSize == "" && spec.Events == 0 {
+		return errors.New("you must provide tot_size or events in the spec, or a not empty --tot-size flag value, unless --events, --rate or --soak is set")
+	}
+
+	return nil
+}
+
+// generateFromSpec generates the corpus of --spec-file, the flags set override the options of the spec.
+func generateFromSpec(cmd *cobra.Command, location string) error {
+	if totSize != "" {
+		spec.TotSize = totSize
+	}
+
+	if !cmd.Flags().Changed("seed") {
+		seed = spec.Seed
+	}
+
+	if auditFile != "" {
+		auditOption = corpus.WithAudit(auditFile, auditCommand(cmd), append(auditInputPaths(cmd), spec.Template))
+	}
+
+	fc, err := corpus.NewFromSpec(spec, afero.NewOsFs(), location, generatorCorpusOptions(cmd)...)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := generationContext(cmd)
+	defer cancel()
+
+	payloadFilename, err := fc.GenerateFromSpec(ctx)
+	printGenerated(cmd, payloadFilename, err)
+
+	return err
+}
@@ -0,0 +1,122 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/cmd"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateWithTemplateTimeout(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "template.json")
+	require.NoError(t, os.WriteFile(templatePath, []byte(`{"alpha":"{{.alpha}}"}`), 0644))
+	fieldsPath := filepath.Join(dir, "fields.yml")
+	require.NoError(t, os.WriteFile(fieldsPath, []byte("- name: alpha\n  type: keyword\n"), 0644))
+
+	viper.Set("corpora_location", dir)
+	t.Cleanup(func() {
+		viper.Set("corpora_location", nil)
+	})
+
+	var out bytes.Buffer
+	rootCmd := cmd.RootCmd()
+	rootCmd.AddCommand(cmd.GenerateWithTemplateCmd())
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(io.Discard)
+	rootCmd.SetArgs([]string{"generate-with-template", templatePath, fieldsPath, "-t", "100GB", "--timeout", "200ms", "--output", "-"})
+
+	start := time.Now()
+	err := rootCmd.ExecuteContext(context.Background())
+	require.ErrorIs(t, err, corpus.ErrInterrupted)
+	require.Equal(t, cmd.ExitCodeInterrupted, cmd.ExitCode(err))
+	require.Less(t, time.Since(start), 10*time.Second)
+	require.NotEmpty(t, out.Bytes())
+	require.Equal(t, byte('\n'), out.Bytes()[out.Len()-1], "the partial corpus ends with a complete event")
+}
+
+func TestGenerateWithTemplateReportFormatJSON(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "template.json")
+	require.NoError(t, os.WriteFile(templatePath, []byte("{\n  \"alpha\": \"{{.alpha}}\",\n  \"beta\": \"{{.beta}}\"\n}"), 0644))
+	fieldsPath := filepath.Join(dir, "fields.yml")
+	require.NoError(t, os.WriteFile(fieldsPath, []byte("- name: alpha\n  type: keyword\n- name: beta\n  type: keyword\n"), 0644))
+	configPath := filepath.Join(dir, "config.yml")
+	require.NoError(t, os.WriteFile(configPath, []byte("- name: beta\n  rules:\n    - when:\n        field: gamma\n        equals: x\n      then:\n        value: y\n"), 0644))
+
+	viper.Set("corpora_location", dir)
+	t.Cleanup(func() {
+		viper.Set("corpora_location", nil)
+	})
+
+	var stderr bytes.Buffer
+	rootCmd := cmd.RootCmd()
+	rootCmd.AddCommand(cmd.GenerateWithTemplateCmd())
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(&stderr)
+	rootCmd.SetArgs([]string{"generate-with-template", templatePath, fieldsPath, "-t", "1KB", "-c", configPath, "--report-format", "json"})
+
+	require.Error(t, rootCmd.ExecuteContext(context.Background()))
+	require.JSONEq(t, `{"command":"generate-with-template","errors":[
+		{"file":"`+templatePath+`","line":3,"column":12,"field":"beta","reason":"rules require field gamma to precede it in the template"}
+	]}`, stderr.String())
+
+	// the errors of the config file are located at their line
+	require.NoError(t, os.WriteFile(configPath, []byte("- name: alpha\n  value: a\n- name: beta\n  range: -1\n"), 0644))
+	stderr.Reset()
+	rootCmd = cmd.RootCmd()
+	rootCmd.AddCommand(cmd.GenerateWithTemplateCmd())
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(&stderr)
+	rootCmd.SetArgs([]string{"generate-with-template", templatePath, fieldsPath, "-t", "1KB", "-c", configPath, "--report-format", "json"})
+
+	require.Error(t, rootCmd.ExecuteContext(context.Background()))
+	require.JSONEq(t, `{"command":"generate-with-template","errors":[
+		{"file":"`+configPath+`","line":3,"field":"beta","reason":"range and fuzziness must be positive"}
+	]}`, stderr.String())
+}
+
+func TestGenerateWithTemplateFlagsReset(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "template.json")
+	require.NoError(t, os.WriteFile(templatePath, []byte(`{"alpha":"{{.alpha}}"}`), 0644))
+	fieldsPath := filepath.Join(dir, "fields.yml")
+	require.NoError(t, os.WriteFile(fieldsPath, []byte("- name: alpha\n  type: keyword\n"), 0644))
+
+	viper.Set("corpora_location", dir)
+	t.Cleanup(func() {
+		viper.Set("corpora_location", nil)
+	})
+
+	run := func(args ...string) ([]byte, error) {
+		var out bytes.Buffer
+		rootCmd := cmd.RootCmd()
+		rootCmd.AddCommand(cmd.GenerateWithTemplateCmd())
+		rootCmd.SetOut(&out)
+		rootCmd.SetErr(io.Discard)
+		rootCmd.SetArgs(append([]string{"generate-with-template", templatePath, fieldsPath, "--output", "-"}, args...))
+		err := rootCmd.ExecuteContext(context.Background())
+		return out.Bytes(), err
+	}
+
+	// --rate cannot be used with the time range, the flags are parsed all the same
+	_, err := run("-t", "1KB", "--filter", "false", "--rate", "1/h", "--time-range-from", "2024-01-01T00:00:00Z", "--time-range-to", "2024-01-02T00:00:00Z", "--timeout", "200ms")
+	require.Error(t, err)
+
+	// the values of the flags of the previous run are not kept
+	out, err := run("-t", "1KB", "--timeout", "5s")
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(out), 1000)
+}
@@ -0,0 +1,355 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sink"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// maxInteractiveChoices is the number of packages found by a search above which the search must be refined
+const maxInteractiveChoices = 30
+
+// maxPreviewFields is the number of fields of a data stream previewed
+const maxPreviewFields = 20
+
+var errInteractiveAborted = errors.New("interactive mode aborted")
+
+func InteractiveCmd() *cobra.Command {
+	interactiveCmd := &cobra.Command{
+		Use:   "interactive",
+		Short: "Assemble a generation run interactively",
+		Long:  "Walk through choosing a package and a data stream from the package registry, previewing their fields and setting the common flags, then generate the corpus",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p := prompter{in: bufio.NewScanner(cmd.InOrStdin()), out: cmd.OutOrStdout()}
+			loadOpts := []fields.LoadOption{fields.WithCacheDir(viper.GetString("fields_cache_location"))}
+
+			pkg, err := p.choosePackage(cmd)
+			if err != nil {
+				return err
+			}
+
+			dataStreams, err := fields.LoadDataStreams(cmd.Context(), packageRegistryBaseURL, pkg.Name, pkg.Version, loadOpts...)
+			if err != nil {
+				return fmt.Errorf("cannot load the data streams of package %s: %w", pkg.Name, err)
+			}
+
+			options := dataStreams
+			if len(dataStreams) > 1 {
+				options = append(options[:len(options):len(options)], "all the data streams")
+			}
+
+			choice, err := p.choose("Data stream", options)
+			if err != nil {
+				return err
+			}
+
+			selected := dataStreams
+			if choice < len(dataStreams) {
+				selected = dataStreams[choice : choice+1]
+			}
+
+			for _, dataStream := range selected {
+				flds, err := fields.LoadFields(cmd.Context(), packageRegistryBaseURL, pkg.Name, dataStream, pkg.Version, loadOpts...)
+				if err != nil {
+					return fmt.Errorf("cannot load the fields of data stream %s: %w", dataStream, err)
+				}
+
+				previewFields(p.out, dataStream, flds)
+			}
+
+			generateArgs := []string{"generate", pkg.Name}
+			if len(selected) > 1 {
+				generateArgs = append(generateArgs, pkg.Version, "--all-data-streams")
+			} else {
+				generateArgs = append(generateArgs, selected[0], pkg.Version)
+			}
+
+			generateArgs = append(generateArgs, "--package-registry-base-url="+packageRegistryBaseURL)
+			flagArgs, err := p.askGenerateFlags()
+			if err != nil {
+				return err
+			}
+
+			generateArgs = append(generateArgs, flagArgs...)
+			fmt.Fprintln(p.out, "Command:", cmd.Root().Name(), quoteArgs(generateArgs))
+
+			generate, err := p.confirm("Generate the corpus now?", true)
+			if err != nil || !generate {
+				return err
+			}
+
+			return executeRoot(cmd, generateArgs)
+		},
+	}
+
+	interactiveCmd.Flags().StringVarP(&packageRegistryBaseURL, "package-registry-base-url", "r", "https://epr.elastic.co/", "base url of the package registry with schema")
+	return interactiveCmd
+}
+
+// choosePackage searches the package registry until a package is chosen among the ones found.
+func (p prompter) choosePackage(cmd *cobra.Command) (fields.PackageSummary, error) {
+	for {
+		query, err := p.ask("Search the package registry for a package", "", nil)
+		if err != nil {
+			return fields.PackageSummary{}, err
+		}
+
+		packages, err := fields.SearchPackages(cmd.Context(), packageRegistryBaseURL, query)
+		if err != nil {
+			return fields.PackageSummary{}, fmt.Errorf("cannot search the package registry: %w", err)
+		}
+
+		switch {
+		case len(packages) == 0:
+			fmt.Fprintln(p.out, "No package found")
+			continue
+		case len(packages) > maxInteractiveChoices:
+			fmt.Fprintf(p.out, "%d packages found, refine the search\n", len(packages))
+			continue
+		}
+
+		options := make([]string, 0, len(packages))
+		for _, pkg := range packages {
+			options = append(options, fmt.Sprintf("%s %s: %s", pkg.Name, pkg.Version, pkg.Title))
+		}
+
+		choice, err := p.choose("Package", options)
+		if err != nil {
+			return fields.PackageSummary{}, err
+		}
+
+		return packages[choice], nil
+	}
+}
+
+// askGenerateFlags asks the values of the common flags of the generate command, returning the ones set.
+func (p prompter) askGenerateFlags() ([]string, error) {
+	var flagArgs []string
+	answer, err := p.ask("Total size of the corpus", "10MB", func(answer string) error {
+		if size, err := humanize.ParseBytes(answer); err != nil || size == 0 {
+			return errors.New("provide a positive size, like 10MB or 1GB")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	flagArgs = append(flagArgs, "--tot-size="+answer)
+
+	answer, err = p.ask("Config file, empty for none", "", func(answer string) error {
+		if answer == "" {
+			return nil
+		}
+
+		_, err := os.Stat(answer)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if answer != "" {
+		flagArgs = append(flagArgs, "--config-file="+answer)
+	}
+
+	var from time.Time
+	answer, err = p.ask("RFC3339 start of the time range of the events, empty for the hour before the current time", "", func(answer string) error {
+		if answer == "" {
+			return nil
+		}
+
+		var err error
+		from, err = time.Parse(time.RFC3339, answer)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if answer != "" {
+		flagArgs = append(flagArgs, "--time-range-from="+answer)
+		answer, err = p.ask("RFC3339 end of the time range of the events", "", func(answer string) error {
+			to, err := time.Parse(time.RFC3339, answer)
+			if err == nil && !to.After(from) {
+				err = errors.New("provide a time after the start of the time range")
+			}
+
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		flagArgs = append(flagArgs, "--time-range-to="+answer)
+	}
+
+	answer, err = p.ask("Seed of the random generators, empty for a random one", "", func(answer string) error {
+		if answer == "" {
+			return nil
+		}
+
+		_, err := strconv.ParseInt(answer, 10, 64)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if answer != "" {
+		flagArgs = append(flagArgs, "--seed="+answer)
+	}
+
+	ecs, err := p.confirm("Generate realistic values for well-known ECS fields?", false)
+	if err != nil {
+		return nil, err
+	}
+
+	if ecs {
+		flagArgs = append(flagArgs, "--ecs-realism")
+	}
+
+	answer, err = p.ask("Output: empty for a file in the corpora location, - for stdout or lumberjack://host:port", "", func(answer string) error {
+		if strings.HasPrefix(answer, sink.LumberjackScheme) {
+			_, err := sink.ParseLumberjackAddress(answer)
+			return err
+		}
+
+		if answer != "" && answer != stdoutOutput {
+			return errors.New("provide either nothing, - or lumberjack://host:port")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if answer != "" {
+		flagArgs = append(flagArgs, "--output="+answer)
+	}
+
+	return flagArgs, nil
+}
+
+// previewFields writes the first fields of the data stream, with their type.
+func previewFields(w io.Writer, dataStream string, flds fields.Fields) {
+	fmt.Fprintf(w, "Data stream %s has %d fields:\n", dataStream, len(flds))
+	for i, field := range flds {
+		if i == maxPreviewFields {
+			fmt.Fprintf(w, "  ... and %d more\n", len(flds)-maxPreviewFields)
+			break
+		}
+
+		fmt.Fprintf(w, "  %s (%s)\n", field.Name, field.Type)
+	}
+}
+
+// quoteArgs joins args in a command line, quoting the ones with spaces.
+func quoteArgs(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if strings.ContainsAny(arg, " \t\"'") {
+			arg = strconv.Quote(arg)
+		}
+
+		quoted = append(quoted, arg)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// prompter asks the questions of the interactive mode, reading the answers line by line.
+type prompter struct {
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+// ask asks question until the answer, or defaultAnswer if empty, is validated by validate, if provided.
+func (p prompter) ask(question, defaultAnswer string, validate func(answer string) error) (string, error) {
+	for {
+		if defaultAnswer != "" {
+			fmt.Fprintf(p.out, "%s [%s]: ", question, defaultAnswer)
+		} else {
+			fmt.Fprintf(p.out, "%s: ", question)
+		}
+
+		if !p.in.Scan() {
+			if err := p.in.Err(); err != nil {
+				return "", err
+			}
+
+			return "", errInteractiveAborted
+		}
+
+		answer := strings.TrimSpace(p.in.Text())
+		if answer == "" {
+			answer = defaultAnswer
+		}
+
+		if validate != nil {
+			if err := validate(answer); err != nil {
+				fmt.Fprintln(p.out, "invalid answer:", err)
+				continue
+			}
+		}
+
+		return answer, nil
+	}
+}
+
+// choose lists the options and asks to choose one of them, returning its index.
+func (p prompter) choose(question string, options []string) (int, error) {
+	for i, option := range options {
+		fmt.Fprintf(p.out, "  %d) %s\n", i+1, option)
+	}
+
+	var choice int
+	_, err := p.ask(question, "1", func(answer string) error {
+		n, err := strconv.Atoi(answer)
+		if err != nil || n < 1 || n > len(options) {
+			return fmt.Errorf("choose a number between 1 and %d", len(options))
+		}
+
+		choice = n - 1
+		return nil
+	})
+
+	return choice, err
+}
+
+// confirm asks a yes or no question.
+func (p prompter) confirm(question string, defaultYes bool) (bool, error) {
+	defaultAnswer := "n"
+	if defaultYes {
+		defaultAnswer = "y"
+	}
+
+	answer, err := p.ask(question, defaultAnswer, func(answer string) error {
+		switch strings.ToLower(answer) {
+		case "y", "yes", "n", "no":
+			return nil
+		default:
+			return errors.New("answer y or n")
+		}
+	})
+
+	return strings.HasPrefix(strings.ToLower(answer), "y"), err
+}
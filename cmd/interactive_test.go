@@ -0,0 +1,116 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/cmd"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func newInteractiveTestRegistry(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, content := range map[string]string{
+		"nginx-1.2.0/manifest.yml":                            "name: nginx\nversion: 1.2.0\n",
+		"nginx-1.2.0/data_stream/access/fields/fields.yml":    "- name: nginx.access.user_name\n  type: keyword\n- name: nginx.access.bytes\n  type: long\n",
+		"nginx-1.2.0/data_stream/error/fields/fields.yml":     "- name: nginx.error.level\n  type: keyword\n",
+		"nginx-1.2.0/data_stream/error/fields/ecs-fields.yml": "- name: message\n  type: text\n",
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, zw.Close())
+
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/search":
+			_, _ = w.Write([]byte(`[{"name": "nginx", "title": "Nginx", "version": "1.2.0"}, {"name": "apache", "title": "Apache HTTP Server", "version": "1.3.0"}]`))
+		case "/package/nginx/1.2.0":
+			_, _ = w.Write([]byte(`{"download": "/epr/nginx/nginx-1.2.0.zip", "data_streams": [{"path": "error"}, {"path": "access"}]}`))
+		case "/epr/nginx/nginx-1.2.0.zip":
+			_, _ = w.Write(archive.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(registry.Close)
+
+	return registry
+}
+
+func TestInteractive(t *testing.T) {
+	registry := newInteractiveTestRegistry(t)
+	location := t.TempDir()
+	viper.Set("corpora_location", location)
+	viper.Set("fields_cache_location", t.TempDir())
+	t.Cleanup(func() {
+		viper.Set("corpora_location", nil)
+		viper.Set("fields_cache_location", nil)
+	})
+
+	answers := strings.Join([]string{
+		"ngin", // package search
+		"",     // nginx, the only package found
+		"4",    // not a data stream
+		"1",    // access
+		"5KB",  // total size
+		"",     // no config file
+		"",     // no time range
+		"42",   // seed
+		"",     // no ECS realism
+		"",     // output to a file
+		"",     // generate
+	}, "\n") + "\n"
+
+	var out bytes.Buffer
+	rootCmd := cmd.RootCmd()
+	rootCmd.AddCommand(cmd.GenerateCmd())
+	rootCmd.AddCommand(cmd.InteractiveCmd())
+	rootCmd.SetIn(strings.NewReader(answers))
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	rootCmd.SetArgs([]string{"interactive", "-r", registry.URL})
+
+	require.NoError(t, rootCmd.ExecuteContext(context.Background()), out.String())
+	require.Contains(t, out.String(), "  1) nginx 1.2.0: Nginx\n")
+	require.Contains(t, out.String(), "invalid answer: choose a number between 1 and 3\n")
+	require.Contains(t, out.String(), "Data stream access has 2 fields:\n  nginx.access.bytes (long)\n  nginx.access.user_name (keyword)\n")
+	require.Contains(t, out.String(), "Command: elastic-integration-corpus-generator-tool generate nginx access 1.2.0 --package-registry-base-url="+registry.URL+" --tot-size=5KB --seed=42\n")
+
+	corpora, err := os.ReadDir(location)
+	require.NoError(t, err)
+	require.Len(t, corpora, 1)
+	require.True(t, strings.HasSuffix(corpora[0].Name(), "-nginx-access-1.2.0.ndjson"))
+	require.Contains(t, out.String(), "File generated: "+filepath.Join(location, corpora[0].Name()))
+}
+
+func TestInteractiveAborted(t *testing.T) {
+	registry := newInteractiveTestRegistry(t)
+
+	rootCmd := cmd.RootCmd()
+	rootCmd.AddCommand(cmd.InteractiveCmd())
+	rootCmd.SetIn(strings.NewReader("apache\n"))
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetErr(&bytes.Buffer{})
+	rootCmd.SetArgs([]string{"interactive", "-r", registry.URL})
+
+	require.ErrorContains(t, rootCmd.ExecuteContext(context.Background()), "interactive mode aborted")
+}
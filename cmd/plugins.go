@@ -0,0 +1,54 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"fmt"
+	"plugin"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+)
+
+// pluginEmittersSymbol is the symbol of the Go plugins of --plugin returning their custom emitters, a function
+// like:
+//
+//	func Emitters() []genlib.Emitter
+const pluginEmittersSymbol = "Emitters"
+
+var plugins []string
+
+// loadPlugins loads the Go plugins of the --plugin flag and registers their custom emitters: the plugins must be
+// built with the same Go version and dependencies as the tool, with go build -buildmode=plugin.
+func loadPlugins() []error {
+	var errs []error
+	for _, path := range plugins {
+		// opening a plugin again returns the one already loaded
+		p, err := plugin.Open(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("you must provide a --plugin flag value that is a Go plugin: %w", err))
+			continue
+		}
+
+		symbol, err := p.Lookup(pluginEmittersSymbol)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s: %w", path, err))
+			continue
+		}
+
+		emittersFunc, ok := symbol.(func() []genlib.Emitter)
+		if !ok {
+			errs = append(errs, fmt.Errorf("plugin %s: %s must be a func() []genlib.Emitter, got %T", path, pluginEmittersSymbol, symbol))
+			continue
+		}
+
+		for _, emitter := range emittersFunc() {
+			if err := genlib.RegisterEmitter(emitter); err != nil {
+				errs = append(errs, fmt.Errorf("plugin %s: %w", path, err))
+			}
+		}
+	}
+
+	return errs
+}
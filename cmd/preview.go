@@ -0,0 +1,99 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sink"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+)
+
+var previewEvents uint64
+
+func PreviewCmd() *cobra.Command {
+	previewCmd := &cobra.Command{
+		Use:   "preview template-path fields-definition-path",
+		Short: "Preview a corpus",
+		Long:  "Print to stdout a few events generated given a template path and a fields definition path, without writing any file",
+		Args: func(cmd *cobra.Command, args []string) error {
+			var errs []error
+			if len(args) != 2 {
+				return errors.New("you must pass the template path and the fields definition path")
+			}
+
+			templatePath = args[0]
+			if templatePath == "" {
+				errs = append(errs, errors.New("you must provide a not empty template path argument"))
+			}
+
+			fieldsDefinitionPath = args[1]
+			if fieldsDefinitionPath == "" {
+				errs = append(errs, errors.New("you must provide a not empty fields definition path argument"))
+			}
+
+			if previewEvents == 0 {
+				errs = append(errs, errors.New("you must provide a positive --events flag value"))
+			}
+
+			errs = append(errs, validateFloatPrecision()...)
+
+			if len(errs) > 0 {
+				return multierr.Combine(errs...)
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			opts := []corpus.GeneratorCorpusOption{
+				corpus.WithSeed(seed),
+				corpus.WithWarnings(cmd.ErrOrStderr()),
+				corpus.WithEvents(previewEvents),
+				corpus.WithSink(sink.NewWriter(stdoutOutput, cmd.OutOrStdout())),
+			}
+
+			opts = append(opts, includeDirOptions()...)
+
+			if strict {
+				opts = append(opts, corpus.WithStrict())
+			}
+
+			if noJSONEscape {
+				opts = append(opts, corpus.WithoutJSONEscape())
+			}
+
+			if floatPrecision >= 0 {
+				opts = append(opts, corpus.WithFloatPrecision(floatPrecision))
+			}
+
+			// nothing is written to the corpora location
+			fc, err := corpus.NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "", templateType, opts...)
+			if err != nil {
+				return err
+			}
+
+			_, err = fc.GenerateWithTemplate(cmd.Context(), templatePath, fieldsDefinitionPath, "")
+			return err
+		},
+	}
+
+	previewCmd.Flags().StringVarP(&configFile, "config-file", "c", "", "path to config file for generator settings")
+	previewCmd.Flags().StringVarP(&templateType, "template-type", "y", "placeholder", "either 'placeholder', 'gotext' or 'structured'")
+	previewCmd.Flags().Uint64VarP(&previewEvents, "events", "n", 5, "number of events to generate")
+	previewCmd.Flags().Int64Var(&seed, "seed", 0, "seed of the random generators, 0 for a random seed")
+	previewCmd.Flags().BoolVar(&strict, "strict", false, "fail on unknown config keys, on config entries referencing fields not in the fields definition and on ignored config settings")
+	previewCmd.Flags().IntVar(&floatPrecision, "float-precision", -1, "decimal places of the floating point values, unless set by the precision config entry; -1 for the default formatting")
+	addIncludeDirFlag(previewCmd)
+	previewCmd.Flags().BoolVar(&noJSONEscape, "no-json-escape", false, "do not escape quotes, backslashes and control characters of the generated string values, for templates of events that are not JSON")
+	return previewCmd
+}
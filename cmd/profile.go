@@ -0,0 +1,81 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+)
+
+var profileIndex string
+var profileElasticsearchURL string
+var profileTimeField string
+var profileOutput string
+
+func ProfileCmd() *cobra.Command {
+	profileCmd := &cobra.Command{
+		Use:   "profile index",
+		Short: "Profile an Elasticsearch index",
+		Long:  "Write a config reproducing the distribution of the values of the fields of an existing Elasticsearch index",
+		Args: func(cmd *cobra.Command, args []string) error {
+			var errs []error
+			if len(args) != 1 {
+				return errors.New("you must pass the index")
+			}
+
+			profileIndex = args[0]
+			if profileIndex == "" {
+				errs = append(errs, errors.New("you must provide a not empty index argument"))
+			}
+
+			if profileElasticsearchURL == "" {
+				errs = append(errs, errors.New("you must provide a not empty --elasticsearch-url flag value"))
+			}
+
+			if len(errs) > 0 {
+				return multierr.Combine(errs...)
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profile, err := corpus.ProfileIndex(cmd.Context(), profileElasticsearchURL, profileIndex, profileTimeField)
+			if err != nil {
+				return err
+			}
+
+			if profileOutput == "" {
+				return profile.WriteConfig(cmd.OutOrStdout())
+			}
+
+			f, err := os.Create(profileOutput)
+			if err != nil {
+				return err
+			}
+
+			if err := profile.WriteConfig(f); err != nil {
+				f.Close()
+				return err
+			}
+
+			if err := f.Close(); err != nil {
+				return err
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), "Config generated:", profileOutput)
+			return nil
+		},
+	}
+
+	profileCmd.Flags().StringVar(&profileElasticsearchURL, "elasticsearch-url", "", "url of the Elasticsearch cluster the index is in, credentials can be provided in the url")
+	profileCmd.Flags().StringVar(&profileTimeField, "time-field", "@timestamp", "date field the event rate of the index is profiled with")
+	profileCmd.Flags().StringVarP(&profileOutput, "output", "o", "", "path of the config file to write, stdout by default")
+	return profileCmd
+}
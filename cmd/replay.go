@@ -0,0 +1,81 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/multierr"
+)
+
+var corpusPath string
+var replayStart string
+var dateFields []string
+
+func ReplayCmd() *cobra.Command {
+	replayCmd := &cobra.Command{
+		Use:   "replay corpus-path",
+		Short: "Replay a corpus",
+		Long:  "Replay an existing NDJSON corpus with its date fields shifted to a new time window",
+		Args: func(cmd *cobra.Command, args []string) error {
+			var errs []error
+			if len(args) != 1 {
+				return errors.New("you must pass the corpus path")
+			}
+
+			corpusPath = args[0]
+			if corpusPath == "" {
+				errs = append(errs, errors.New("you must provide a not empty corpus path argument"))
+			}
+
+			if replayStart != "" {
+				if _, err := time.Parse(time.RFC3339, replayStart); err != nil {
+					errs = append(errs, fmt.Errorf("you must provide a RFC3339 --start flag value: %w", err))
+				}
+			}
+
+			if len(dateFields) == 0 {
+				errs = append(errs, errors.New("you must provide a not empty --date-fields flag value"))
+			}
+
+			errs = append(errs, validateGeneratorCorpusFlags()...)
+
+			if len(errs) > 0 {
+				return multierr.Combine(errs...)
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			location := viper.GetString("corpora_location")
+			fc, err := corpus.NewGenerator(corpus.Config{}, afero.NewOsFs(), location, generatorCorpusOptions(cmd)...)
+			if err != nil {
+				return err
+			}
+
+			start := time.Now()
+			if replayStart != "" {
+				start, _ = time.Parse(time.RFC3339, replayStart)
+			}
+
+			payloadFilename, err := fc.Replay(cmd.Context(), corpusPath, start, dateFields)
+			printGenerated(cmd, payloadFilename, err)
+
+			return err
+		},
+	}
+
+	replayCmd.Flags().StringVar(&replayStart, "start", "", "RFC3339 time the first event is shifted to, the current time if not provided")
+	replayCmd.Flags().StringSliceVar(&dateFields, "date-fields", []string{"@timestamp"}, "date fields to shift, all by the offset of the first one of the first event")
+	addOutputFlags(replayCmd)
+	return replayCmd
+}
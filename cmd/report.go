@@ -0,0 +1,129 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+)
+
+const (
+	reportFormatText = "text"
+	reportFormatJSON = "json"
+)
+
+var reportFormat string
+
+// issueRegex splits an issue or the message of an error in: line of the config file if any, field if any, and reason
+var issueRegex = regexp.MustCompile(`(?s)^(?:line (\d+): )?(?:field ([^ :]+): )?(.*)$`)
+
+// report is the JSON report of the errors a command fails with, for CI jobs to surface them without parsing text.
+type report struct {
+	Command string        `json:"command"`
+	Errors  []reportEntry `json:"errors"`
+}
+
+type reportEntry struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// issuesError is the error of a command finding issues, each one an entry of the report.
+type issuesError struct {
+	entries []reportEntry
+}
+
+func (e *issuesError) Error() string {
+	return fmt.Sprintf("%d issues found", len(e.entries))
+}
+
+// add adds the issues of the file at path, the config file ones being located at their line.
+func (e *issuesError) add(path string, issues []string) {
+	for _, issue := range issues {
+		e.entries = append(e.entries, issueEntry(path, issue))
+	}
+}
+
+// issueEntry returns the entry of issue, found in the file at path.
+func issueEntry(path, issue string) reportEntry {
+	m := issueRegex.FindStringSubmatch(issue)
+	entry := reportEntry{File: path, Field: m[2], Reason: m[3]}
+	entry.Line, _ = strconv.Atoi(m[1])
+	return entry
+}
+
+// addReportFormatFlag adds the flag of the format of the errors cmd fails with, reporting them as JSON to stderr
+// instead of the text of the error with --report-format json, whether they are the ones of the arguments or of the run.
+func addReportFormatFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&reportFormat, "report-format", reportFormatText, "format of the errors the command fails with: text, or json to write to stderr a JSON object with the file, the line, the field and the reason of each one")
+
+	validateArgs, runE := cmd.Args, cmd.RunE
+	cmd.Args = func(cmd *cobra.Command, args []string) error {
+		if reportFormat != reportFormatText && reportFormat != reportFormatJSON {
+			return errors.New("--report-format flag value must be either 'text' or 'json'")
+		}
+
+		return writeReport(cmd, validateArgs(cmd, args))
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return writeReport(cmd, runE(cmd, args))
+	}
+}
+
+// writeReport writes the JSON report of err, if any, with --report-format json, in place of the text of err.
+func writeReport(cmd *cobra.Command, err error) error {
+	if err == nil || reportFormat != reportFormatJSON {
+		return err
+	}
+
+	cmd.SilenceErrors = true
+	if encodeErr := json.NewEncoder(cmd.ErrOrStderr()).Encode(report{Command: cmd.Name(), Errors: reportEntries(err)}); encodeErr != nil {
+		return multierr.Append(err, encodeErr)
+	}
+
+	return err
+}
+
+// reportEntries returns the entries of the errors combined in err: the issues of an issuesError, the location of a
+// genlib.TemplateError, and the line of the config file and the field the other ones start with, if any.
+func reportEntries(err error) []reportEntry {
+	var entries []reportEntry
+	for _, err := range multierr.Errors(err) {
+		var issuesErr *issuesError
+		var templateErr *genlib.TemplateError
+		switch {
+		case errors.As(err, &issuesErr):
+			entries = append(entries, issuesErr.entries...)
+		case errors.As(err, &templateErr):
+			entries = append(entries, reportEntry{
+				File:   templateErr.Name,
+				Line:   templateErr.Line,
+				Column: templateErr.Column,
+				Field:  templateErr.Field,
+				Reason: templateErr.Err.Error(),
+			})
+		default:
+			entry := issueEntry("", err.Error())
+			if entry.Line > 0 {
+				entry.File = configFile
+			}
+
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,120 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/version"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+)
+
+var auditPath string
+
+func RerunCmd() *cobra.Command {
+	rerunCmd := &cobra.Command{
+		Use:   "rerun audit-path",
+		Short: "Rerun a recorded run",
+		Long:  "Reproduce exactly the corpora of a run recorded with --audit-file, checking its inputs have not changed and the reproduced corpora match the recorded ones",
+		Args: func(cmd *cobra.Command, args []string) error {
+			var errs []error
+			if len(args) != 1 {
+				return errors.New("you must pass the audit path")
+			}
+
+			auditPath = args[0]
+			if auditPath == "" {
+				errs = append(errs, errors.New("you must provide a not empty audit path argument"))
+			}
+
+			if len(errs) > 0 {
+				return multierr.Combine(errs...)
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			record, err := corpus.LoadAuditRecord(auditPath)
+			if err != nil {
+				return err
+			}
+
+			if err := record.Verify(); err != nil {
+				return fmt.Errorf("cannot reproduce the run: %w", err)
+			}
+
+			currentVersion := version.Tag
+			if currentVersion == "" {
+				currentVersion = "devel"
+			}
+
+			if record.Version != currentVersion || record.CommitHash != version.CommitHash {
+				fmt.Fprintf(cmd.ErrOrStderr(), "warning: the run was recorded by version %s (%s), rerunning with version %s (%s)\n", record.Version, record.CommitHash, currentVersion, version.CommitHash)
+			}
+
+			// the recorded command line holds all the flags of the run, the environment must not add any
+			for _, env := range os.Environ() {
+				if name, _, _ := strings.Cut(env, "="); strings.HasPrefix(name, EnvPrefix) {
+					_ = os.Unsetenv(name)
+				}
+			}
+
+			dir, err := os.MkdirTemp("", "corpus-generator-rerun-")
+			if err != nil {
+				return err
+			}
+
+			defer os.RemoveAll(dir)
+
+			rerunAuditPath := filepath.Join(dir, "audit.json")
+			rerunArgs := append([]string{
+				record.Command[0],
+				"--seed=" + strconv.FormatInt(record.Seed, 10),
+				"--reference-time=" + record.ReferenceTime.Format(time.RFC3339Nano),
+				"--audit-file=" + rerunAuditPath,
+			}, record.Command[1:]...)
+
+			if err := executeRoot(cmd, rerunArgs); err != nil {
+				return err
+			}
+
+			rerun, err := corpus.LoadAuditRecord(rerunAuditPath)
+			if err != nil {
+				return err
+			}
+
+			if err := record.Compare(rerun); err != nil {
+				return fmt.Errorf("corpus not reproduced: %w", err)
+			}
+
+			for _, reproduced := range rerun.Corpora {
+				fmt.Fprintln(cmd.OutOrStdout(), "Corpus reproduced:", reproduced.Filename)
+			}
+
+			return nil
+		},
+	}
+
+	return rerunCmd
+}
+
+// executeRoot executes the root command of cmd with args, the error is reported once, by cmd.
+func executeRoot(cmd *cobra.Command, args []string) error {
+	rootCmd := cmd.Root()
+	silenceErrors := rootCmd.SilenceErrors
+	rootCmd.SilenceErrors = true
+	rootCmd.SetArgs(args)
+	err := rootCmd.ExecuteContext(cmd.Context())
+	rootCmd.SilenceErrors = silenceErrors
+	return err
+}
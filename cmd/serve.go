@@ -0,0 +1,113 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/server"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+// serveShutdownTimeout is the time the requests being served are given to complete once the command is interrupted
+const serveShutdownTimeout = 10 * time.Second
+
+var serveAddress string
+var serveGRPCAddress string
+var serveMaxEvents uint64
+
+func ServeCmd() *cobra.Command {
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the generation of events over HTTP",
+		Long:  "Start an HTTP server generating the events of the template, the fields definition and the config of each request, as NDJSON: POST /generate responds with the count of events requested, POST /stream streams them with chunked encoding until the client disconnects. With --grpc-address the events are streamed by a gRPC service as well, in batches",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return errors.New("serve takes no arguments")
+			}
+
+			if serveMaxEvents == 0 {
+				return errors.New("you must provide a positive --max-events flag value")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ln, err := net.Listen("tcp", serveAddress)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			srv := &http.Server{
+				Handler: server.New(server.WithMaxEvents(serveMaxEvents)),
+				// the streams end when the command is interrupted
+				BaseContext: func(net.Listener) context.Context { return ctx },
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Listening on http://%s\n", ln.Addr())
+
+			served := make(chan error, 2)
+			go func() {
+				served <- srv.Serve(ln)
+			}()
+
+			var grpcSrv *grpc.Server
+			if serveGRPCAddress != "" {
+				grpcLn, err := net.Listen("tcp", serveGRPCAddress)
+				if err != nil {
+					_ = srv.Close()
+					return err
+				}
+
+				grpcSrv = server.NewGRPC(serveMaxEvents)
+				fmt.Fprintf(cmd.OutOrStdout(), "Listening for gRPC on %s\n", grpcLn.Addr())
+				go func() {
+					served <- grpcSrv.Serve(grpcLn)
+				}()
+			}
+
+			select {
+			case err = <-served:
+			case <-ctx.Done():
+			}
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+			defer cancel()
+
+			if grpcSrv != nil {
+				// the streams of the gRPC service are not ended by the interruption, they are cut once the shutdown times out
+				stopped := make(chan struct{})
+				go func() {
+					grpcSrv.GracefulStop()
+					close(stopped)
+				}()
+
+				select {
+				case <-stopped:
+				case <-shutdownCtx.Done():
+					grpcSrv.Stop()
+				}
+			}
+
+			if shutdownErr := srv.Shutdown(shutdownCtx); err == nil {
+				err = shutdownErr
+			}
+
+			return err
+		},
+	}
+
+	serveCmd.Flags().StringVarP(&serveAddress, "address", "a", "localhost:8080", "host:port to listen on, :8080 to listen on all the interfaces")
+	serveCmd.Flags().StringVar(&serveGRPCAddress, "grpc-address", "", "host:port the gRPC service streaming the events in batches listens on, disabled if empty")
+	serveCmd.Flags().Uint64Var(&serveMaxEvents, "max-events", server.DefaultMaxEvents, "most events of a request to the generate endpoint, and of a call of the gRPC service")
+	return serveCmd
+}
@@ -0,0 +1,67 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+)
+
+var splitField string
+var splitShards int
+
+func SplitByFieldCmd() *cobra.Command {
+	splitByFieldCmd := &cobra.Command{
+		Use:   "split-by-field corpus-path",
+		Short: "Split a corpus by field value",
+		Long:  "Split an NDJSON corpus into files next to it, one for each value of a field or for each shard of its values",
+		Args: func(cmd *cobra.Command, args []string) error {
+			var errs []error
+			if len(args) != 1 {
+				return errors.New("you must pass the corpus path")
+			}
+
+			corpusPath = args[0]
+			if corpusPath == "" {
+				errs = append(errs, errors.New("you must provide a not empty corpus path argument"))
+			}
+
+			if splitField == "" {
+				errs = append(errs, errors.New("you must provide a not empty --field flag value"))
+			}
+
+			if splitShards < 0 {
+				errs = append(errs, errors.New("you must provide a positive --shards flag value"))
+			}
+
+			if len(errs) > 0 {
+				return multierr.Combine(errs...)
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filenames, err := corpus.SplitByField(afero.NewOsFs(), corpusPath, splitField, splitShards)
+			if err != nil {
+				return err
+			}
+
+			for _, filename := range filenames {
+				fmt.Fprintln(cmd.OutOrStdout(), "File generated:", filename)
+			}
+
+			return nil
+		},
+	}
+
+	splitByFieldCmd.Flags().StringVarP(&splitField, "field", "f", "", "field whose value keys the file of each event")
+	splitByFieldCmd.Flags().IntVar(&splitShards, "shards", 0, "number of files the values of the field are hashed into, 0 for a file for each value")
+	return splitByFieldCmd
+}
@@ -0,0 +1,125 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+)
+
+var coverageReport bool
+
+func ValidateCmd() *cobra.Command {
+	validateCmd := &cobra.Command{
+		Use:   "validate template-path fields-definition-path",
+		Short: "Validate a template",
+		Long:  "Check a template against a fields definition and a config file, reporting unknown fields, unused fields, type mismatches and config issues without generating anything",
+		Args: func(cmd *cobra.Command, args []string) error {
+			var errs []error
+			if len(args) != 2 {
+				return errors.New("you must pass the template path and the fields definition path")
+			}
+
+			templatePath = args[0]
+			if templatePath == "" {
+				errs = append(errs, errors.New("you must provide a not empty template path argument"))
+			}
+
+			fieldsDefinitionPath = args[1]
+			if fieldsDefinitionPath == "" {
+				errs = append(errs, errors.New("you must provide a not empty fields definition path argument"))
+			}
+
+			if len(errs) > 0 {
+				return multierr.Combine(errs...)
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// unknown config keys are issues as well
+			cfg, err := config.LoadConfig(configFile, config.WithStrict())
+			if err != nil {
+				return err
+			}
+
+			fc, err := corpus.NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "", templateType, includeDirOptions()...)
+			if err != nil {
+				return err
+			}
+
+			if coverageReport {
+				coverage, err := fc.TemplateCoverage(cmd.Context(), templatePath, fieldsDefinitionPath)
+				if err != nil {
+					return err
+				}
+
+				printCoverage(cmd.OutOrStdout(), coverage)
+				return nil
+			}
+
+			issues, err := fc.ValidateTemplate(cmd.Context(), templatePath, fieldsDefinitionPath)
+			if err != nil {
+				return err
+			}
+
+			issuesErr := &issuesError{}
+			for _, issue := range issues {
+				fmt.Fprintln(cmd.OutOrStdout(), issue)
+				// the issues of the config file are located at their line, the other ones are the template ones
+				entry := issueEntry(templatePath, issue)
+				if entry.Line > 0 {
+					entry.File = configFile
+				}
+
+				issuesErr.entries = append(issuesErr.entries, entry)
+			}
+
+			if len(issues) > 0 {
+				return issuesErr
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), "No issues found")
+			return nil
+		},
+	}
+
+	validateCmd.Flags().StringVarP(&configFile, "config-file", "c", "", "path to config file for generator settings")
+	validateCmd.Flags().StringVarP(&templateType, "template-type", "y", "placeholder", "either 'placeholder', 'gotext' or 'structured'")
+	addIncludeDirFlag(validateCmd)
+	validateCmd.Flags().BoolVar(&coverageReport, "coverage", false, "report the fields of the definition covered and ignored by the template, and the fields referenced by the template and not defined, instead of the issues")
+	addReportFormatFlag(validateCmd)
+	return validateCmd
+}
+
+// printCoverage writes the fields covered, ignored and unbound by the template, with their percentages.
+func printCoverage(w io.Writer, coverage genlib.TemplateCoverage) {
+	definitionFields := len(coverage.Covered) + len(coverage.Ignored)
+	sections := []struct {
+		title   string
+		fields  []string
+		total   int
+		percent float64
+	}{
+		{"Covered fields", coverage.Covered, definitionFields, coverage.CoveredPercent()},
+		{"Ignored fields", coverage.Ignored, definitionFields, coverage.IgnoredPercent()},
+		{"Unbound template fields", coverage.Unbound, coverage.Referenced, coverage.UnboundPercent()},
+	}
+
+	for _, section := range sections {
+		fmt.Fprintf(w, "%s: %d/%d (%.1f%%)\n", section.title, len(section.fields), section.total, section.percent)
+		for _, field := range section.fields {
+			fmt.Fprintln(w, "  "+field)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+	"github.com/spf13/afero"
+	"go.uber.org/multierr"
+)
+
+// WithAudit writes to filename the audit record of the run, with what it takes to reproduce its corpora exactly:
+// command is the command line of the run, and inputPaths the files and directories it reads, whose hashes are
+// recorded. The date fields are generated relative to the reference time of the run, see WithReferenceTime.
+// The generators the option is applied to share the audit record, which holds the corpora of all of them.
+func WithAudit(filename string, command []string, inputPaths []string) GeneratorCorpusOption {
+	record := &AuditRecord{Command: command}
+	return func(gc *GeneratorCorpus) {
+		gc.audit = record
+		gc.auditFilename = filename
+		gc.auditInputPaths = inputPaths
+	}
+}
+
+// WithReferenceTime generates the date fields in the hour before t, instead of before the current time, unless
+// a time range is set.
+func WithReferenceTime(t time.Time) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.referenceTime = t
+	}
+}
+
+// AuditRecord records a run, with what it takes to reproduce its corpora exactly.
+type AuditRecord struct {
+	Version       string    `json:"version"`
+	CommitHash    string    `json:"commit_hash"`
+	Seed          int64     `json:"seed"`
+	ReferenceTime time.Time `json:"reference_time"`
+	// Command is the command line of the run, without the executable
+	Command []string `json:"command"`
+	// Inputs are the sha256 of the files and the directories read by the run, like the config file, by path
+	Inputs          map[string]string `json:"inputs,omitempty"`
+	EmitterVersions map[string]int    `json:"emitter_versions"`
+	Corpora         []AuditCorpus     `json:"corpora"`
+}
+
+// AuditCorpus records a corpus generated by a run.
+type AuditCorpus struct {
+	Filename string `json:"filename"`
+	// SHA256 is the one of the content of the corpus, of all its files when split, empty when it has been shipped
+	SHA256 string `json:"sha256,omitempty"`
+	Events uint64 `json:"events"`
+	Bytes  uint64 `json:"bytes"`
+}
+
+// LoadAuditRecord loads the audit record written by a run to filename.
+func LoadAuditRecord(filename string) (AuditRecord, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return AuditRecord{}, err
+	}
+
+	var record AuditRecord
+	if err := json.Unmarshal(content, &record); err != nil {
+		return AuditRecord{}, fmt.Errorf("audit file %s: %w", filename, err)
+	}
+
+	if len(record.Command) == 0 {
+		return AuditRecord{}, fmt.Errorf("audit file %s: no command recorded", filename)
+	}
+
+	return record, nil
+}
+
+// Verify checks that the run can be reproduced: the inputs must be unchanged and the emitters of the values of the
+// field types at the recorded versions.
+func (r AuditRecord) Verify() error {
+	var errs []error
+	for _, path := range sortedKeys(r.Inputs) {
+		sum, err := hashInput(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("input %s: %w", path, err))
+			continue
+		}
+
+		if sum != r.Inputs[path] {
+			errs = append(errs, fmt.Errorf("input %s changed since the run: sha256 %s, recorded %s", path, sum, r.Inputs[path]))
+		}
+	}
+
+	versions := genlib.EmitterVersions()
+	for _, fieldType := range sortedKeys(r.EmitterVersions) {
+		if versions[fieldType] != r.EmitterVersions[fieldType] {
+			errs = append(errs, fmt.Errorf("emitter of %s fields at version %d, recorded %d", fieldType, versions[fieldType], r.EmitterVersions[fieldType]))
+		}
+	}
+
+	return multierr.Combine(errs...)
+}
+
+// Compare checks that the corpora of the rerun of the run are the same as the ones recorded.
+func (r AuditRecord) Compare(rerun AuditRecord) error {
+	if len(rerun.Corpora) != len(r.Corpora) {
+		return fmt.Errorf("%d corpora generated, recorded %d", len(rerun.Corpora), len(r.Corpora))
+	}
+
+	var errs []error
+	for i, recorded := range r.Corpora {
+		got := rerun.Corpora[i]
+		if got.SHA256 != recorded.SHA256 || got.Events != recorded.Events || got.Bytes != recorded.Bytes {
+			errs = append(errs, fmt.Errorf("corpus %s differs from %s: sha256 %s, %d events and %d bytes, recorded %s, %d events and %d bytes",
+				got.Filename, recorded.Filename, got.SHA256, got.Events, got.Bytes, recorded.SHA256, recorded.Events, recorded.Bytes))
+		}
+	}
+
+	return multierr.Combine(errs...)
+}
+
+// initAudit sets the seed and the reference time of the dates, unless provided, to the ones of the audit record,
+// the same for all the generators sharing it, so that the rerun generates the same corpora.
+func (gc *GeneratorCorpus) initAudit() {
+	if gc.audit == nil {
+		return
+	}
+
+	if gc.seed == 0 {
+		if gc.audit.Seed == 0 {
+			gc.audit.Seed = time.Now().UnixNano()
+		}
+
+		gc.seed = gc.audit.Seed
+	}
+
+	if gc.referenceTime.IsZero() {
+		if gc.audit.ReferenceTime.IsZero() {
+			gc.audit.ReferenceTime = time.Now().UTC()
+		}
+
+		gc.referenceTime = gc.audit.ReferenceTime
+	}
+}
+
+// writeAudit adds the corpus of the run to the audit record and writes it to the file provided by WithAudit.
+// The inputs are hashed by the first corpus of the run.
+func (gc GeneratorCorpus) writeAudit(summary RunSummary) error {
+	if gc.audit.Inputs == nil && len(gc.auditInputPaths) > 0 {
+		gc.audit.Inputs = make(map[string]string, len(gc.auditInputPaths))
+		for _, path := range gc.auditInputPaths {
+			sum, err := hashInput(path)
+			if err != nil {
+				return fmt.Errorf("cannot write audit: %w", err)
+			}
+
+			gc.audit.Inputs[path] = sum
+		}
+	}
+
+	gc.audit.Version = summary.Version
+	gc.audit.CommitHash = summary.CommitHash
+	gc.audit.Seed = gc.seed
+	gc.audit.ReferenceTime = gc.referenceTime
+	gc.audit.EmitterVersions = genlib.EmitterVersions()
+	gc.audit.Corpora = append(gc.audit.Corpora, AuditCorpus{
+		Filename: summary.Filename,
+		SHA256:   summary.SHA256,
+		Events:   summary.Events,
+		Bytes:    summary.Bytes,
+	})
+
+	body, err := json.MarshalIndent(gc.audit, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := afero.WriteFile(gc.fs, gc.auditFilename, append(body, '\n'), corpusPerm); err != nil {
+		return fmt.Errorf("cannot write audit: %w", err)
+	}
+
+	return nil
+}
+
+// hashInput returns the sha256 of the file at path, or of the relative paths and the contents of the files of the
+// directory at path.
+func hashInput(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	if !info.IsDir() {
+		if err := hashFile(h, path); err != nil {
+			return "", err
+		}
+
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	// filepath.Walk visits the files in lexical order
+	err = filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		name, err := filepath.Rel(path, filePath)
+		if err != nil {
+			return err
+		}
+
+		h.Write([]byte(filepath.ToSlash(name)))
+		h.Write([]byte{0})
+		return hashFile(h, filePath)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(h hash.Hash, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	_, err = io.Copy(h, f)
+	return err
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// hashingSink is a Sink hashing the content written to it.
+type hashingSink struct {
+	Sink
+	hash hash.Hash
+}
+
+func (s hashingSink) Write(p []byte) (int, error) {
+	n, err := s.Sink.Write(p)
+	s.hash.Write(p[:n])
+	return n, err
+}
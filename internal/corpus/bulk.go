@@ -0,0 +1,92 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The operations of the bulk request actions, see BulkAction.
+const (
+	// OpTypeCreate indexes the event only if it does not exist already, the only one accepted by data streams
+	OpTypeCreate = "create"
+	// OpTypeIndex indexes the event, replacing the existing one with the same _id
+	OpTypeIndex = "index"
+)
+
+// BulkAction is the metadata of the bulk request action preceding each event of the corpora of the data streams.
+type BulkAction struct {
+	// Index is the target index or data stream, <Type>-<package>.<data stream>-<Namespace> if empty
+	Index string
+	// Type is the type of the target data stream, metrics if empty
+	Type string
+	// Namespace is the namespace of the target data stream, default if empty
+	Namespace string
+	// OpType is either OpTypeCreate, the default, or OpTypeIndex
+	OpType string
+	// Pipeline is the ingest pipeline the events go through, the default one of the target if empty
+	Pipeline string
+	// Namespaces spreads the events in turn across as many namespaces, <Namespace>_1 to <Namespace>_<Namespaces>,
+	// if more than 1: it cannot be used with Index
+	Namespaces int
+	// NamespaceFields are the fields of the events set to the namespace they are spread to, like data_stream.namespace,
+	// if present
+	NamespaceFields []string
+}
+
+// ValidateOpType returns an error if opType is not one of the operations of the bulk request actions.
+func ValidateOpType(opType string) error {
+	switch opType {
+	case OpTypeCreate, OpTypeIndex:
+		return nil
+	default:
+		return fmt.Errorf("unknown op type %s, must be either %s or %s", opType, OpTypeCreate, OpTypeIndex)
+	}
+}
+
+// WithBulkAction sets the metadata of the bulk request actions, instead of creating the events in the
+// metrics-<package>.<data stream>-default data stream. The _id is set with WithIDStrategy.
+func WithBulkAction(action BulkAction) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.bulkAction = action
+	}
+}
+
+// bulkIndex returns the target of the bulk request actions of dataStream of integrationPackage.
+func (gc GeneratorCorpus) bulkIndex(integrationPackage, dataStream string) string {
+	action := gc.bulkAction
+	if action.Index != "" {
+		return action.Index
+	}
+
+	if action.Type == "" {
+		action.Type = "metrics"
+	}
+
+	if action.Namespace == "" {
+		action.Namespace = "default"
+	}
+
+	return action.Type + "-" + integrationPackage + "." + dataStream + "-" + action.Namespace
+}
+
+// createPayload returns the bulk request action preceding each event of dataStream of integrationPackage.
+func (gc GeneratorCorpus) createPayload(integrationPackage, dataStream string) []byte {
+	opType := gc.bulkAction.OpType
+	if opType == "" {
+		opType = OpTypeCreate
+	}
+
+	// strings are always marshalled
+	index, _ := json.Marshal(gc.bulkIndex(integrationPackage, dataStream))
+	meta := `"_index": ` + string(index)
+	if gc.bulkAction.Pipeline != "" {
+		pipeline, _ := json.Marshal(gc.bulkAction.Pipeline)
+		meta += `, "pipeline": ` + string(pipeline)
+	}
+
+	return []byte(`{ "` + opType + `" : { ` + meta + ` } }` + "\n")
+}
@@ -0,0 +1,110 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// checkpointExtension is the extension added to the path of a corpus file for the path of its checkpoint
+const checkpointExtension = ".checkpoint"
+
+// checkpointInterval is the interval the checkpoint of a corpus file is written at during its generation
+const checkpointInterval = 10 * time.Second
+
+// Checkpoint is the progress of the generation of a corpus file, written next to it while it is generated and when
+// the generation is interrupted, so that it can be resumed with WithResume: the events and the bytes of the corpus
+// written so far, and the seed of the random generators.
+type Checkpoint struct {
+	Seed   int64  `json:"seed"`
+	Events uint64 `json:"events"`
+	Bytes  uint64 `json:"bytes"`
+}
+
+// LoadCheckpoint loads the checkpoint of the corpus file at corpusPath.
+func LoadCheckpoint(corpusPath string) (Checkpoint, error) {
+	content, err := os.ReadFile(corpusPath + checkpointExtension)
+	if errors.Is(err, os.ErrNotExist) {
+		return Checkpoint{}, fmt.Errorf("no checkpoint of %s, either it is complete or it was not written to a file", corpusPath)
+	}
+
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(content, &checkpoint); err != nil {
+		return Checkpoint{}, fmt.Errorf("checkpoint of %s: %w", corpusPath, err)
+	}
+
+	return checkpoint, nil
+}
+
+// checkpointer writes the checkpoints of the corpus file at path, every checkpointInterval and at the end of an
+// interrupted generation, and removes them at the end of a complete one. Its zero value writes none.
+type checkpointer struct {
+	fs   afero.Fs
+	path string
+	seed int64
+	last time.Time
+}
+
+// newCheckpointer returns the checkpointer of the corpus written to sink: the corpora written by the sinks other than
+// files, split, compressed or not, and the ones generated in live or soak mode have no checkpoints.
+func (gc GeneratorCorpus) newCheckpointer(sink Sink) checkpointer {
+	if gc.sink != nil || gc.maxFileSize > 0 || gc.maxEventsPerFile > 0 || gc.gzip || gc.rate > 0 || gc.soakOptions != nil {
+		return checkpointer{}
+	}
+
+	return checkpointer{fs: gc.fs, path: sink.Name() + checkpointExtension, seed: gc.seed, last: time.Now()}
+}
+
+// due tells if the checkpoint interval has elapsed since the last checkpoint.
+func (c *checkpointer) due() bool {
+	return c.fs != nil && time.Since(c.last) >= checkpointInterval
+}
+
+// write flushes sink and writes the checkpoint of the events and the bytes written to it so far.
+func (c *checkpointer) write(sink Sink, events, bytes uint64) error {
+	if c.fs == nil {
+		return nil
+	}
+
+	if err := sink.Flush(); err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(Checkpoint{Seed: c.seed, Events: events, Bytes: bytes})
+	if err != nil {
+		return err
+	}
+
+	// the checkpoint is replaced at once, so that it is never found half written
+	if err := afero.WriteFile(c.fs, c.path+".tmp", content, corpusPerm); err != nil {
+		return err
+	}
+
+	c.last = time.Now()
+	return c.fs.Rename(c.path+".tmp", c.path)
+}
+
+// remove removes the checkpoint of a complete corpus, if any.
+func (c *checkpointer) remove() error {
+	if c.fs == nil {
+		return nil
+	}
+
+	if err := c.fs.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"math/rand"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+)
+
+// Duplicates is how the events generated are written again, verbatim, to benchmark deduplication and fingerprint
+// processors.
+type Duplicates struct {
+	// Percentage is the percentage of the events that are one of the last ones generated, drawn at random, instead of
+	// a new one
+	Percentage int
+	// SameID writes the duplicated events with the bulk request action, and so the _id, of the ones they duplicate,
+	// instead of a new one, see WithIDStrategy
+	SameID bool
+}
+
+// WithDuplicates writes duplicates.Percentage of the events as one of the last 1024 ones generated, verbatim.
+func WithDuplicates(duplicates Duplicates) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.duplicates = duplicates
+	}
+}
+
+// duplicate is an event generated, with its payload with the bulk request action, when its _id is kept, or the
+// traces of its fields otherwise, for the observers to account for it again
+type duplicate struct {
+	event   []byte
+	payload []byte
+	traces  []genlib.FieldTrace
+}
+
+// duplicator draws the events written again, among the last ones generated in a ring of maxDuplicateCandidates.
+type duplicator struct {
+	duplicates Duplicates
+	rand       *rand.Rand
+	candidates []duplicate
+	// count is the number of events written again
+	count uint64
+}
+
+// newDuplicator returns the duplicator of the events of the corpus, nil if there are no duplicates.
+func (gc GeneratorCorpus) newDuplicator() *duplicator {
+	if gc.duplicates.Percentage <= 0 {
+		return nil
+	}
+
+	return &duplicator{
+		duplicates: gc.duplicates,
+		// the duplicates do not change the values generated for the seed
+		rand: rand.New(rand.NewSource(gc.seed)),
+	}
+}
+
+// draw returns the event to write again instead of a new one, if any.
+func (d *duplicator) draw() (duplicate, bool) {
+	if d == nil || len(d.candidates) == 0 || d.rand.Intn(100) >= d.duplicates.Percentage {
+		return duplicate{}, false
+	}
+
+	d.count++
+	return d.candidates[d.rand.Intn(len(d.candidates))], true
+}
+
+// add makes the n-th event a candidate to be written again, with its payload if it keeps its _id, with the traces of
+// its fields otherwise: a duplicate with a new _id is a new document.
+func (d *duplicator) add(n uint64, event, payload []byte, traces []genlib.FieldTrace) {
+	if d == nil {
+		return
+	}
+
+	i := len(d.candidates)
+	if i < maxDuplicateCandidates {
+		d.candidates = append(d.candidates, duplicate{})
+	} else {
+		i = int(n % maxDuplicateCandidates)
+	}
+
+	candidate := &d.candidates[i]
+	candidate.event = append(candidate.event[:0], event...)
+	if d.duplicates.SameID {
+		candidate.payload = append(candidate.payload[:0], payload...)
+	} else {
+		candidate.traces = append(candidate.traces[:0], traces...)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+	"github.com/spf13/afero"
+)
+
+const (
+	// AggregationTerms counts the events per value of the field
+	AggregationTerms = "terms"
+	// AggregationSum sums the values of the field
+	AggregationSum = "sum"
+	// AggregationCardinality counts the distinct values of the field
+	AggregationCardinality = "cardinality"
+)
+
+// expectedResultsExt is the extension appended to the corpus filename for the expected results file
+const expectedResultsExt = ".expected.json"
+
+// ExpectedAggregation is an aggregation whose result on the generated corpus is computed during the generation.
+type ExpectedAggregation struct {
+	Type  string
+	Field string
+}
+
+// String returns the aggregation in the type:field form, naming it in the expected results.
+func (a ExpectedAggregation) String() string {
+	return a.Type + ":" + a.Field
+}
+
+// ParseExpectedAggregation parses an aggregation in the type:field form, like terms:host.name.
+func ParseExpectedAggregation(s string) (ExpectedAggregation, error) {
+	aggregationType, field, found := strings.Cut(s, ":")
+	if !found || len(field) == 0 {
+		return ExpectedAggregation{}, fmt.Errorf("aggregation %s: must be in the type:field form", s)
+	}
+
+	switch aggregationType {
+	case AggregationTerms, AggregationSum, AggregationCardinality:
+	default:
+		return ExpectedAggregation{}, fmt.Errorf("aggregation %s: type must be one of %s, %s and %s", s, AggregationTerms, AggregationSum, AggregationCardinality)
+	}
+
+	return ExpectedAggregation{Type: aggregationType, Field: field}, nil
+}
+
+// WithExpectedResults computes the results of the aggregations on the generated corpus,
+// writing them next to the corpus in a file with the same name and the .expected.json extension.
+func WithExpectedResults(aggregations ...ExpectedAggregation) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.expectedAggregations = aggregations
+	}
+}
+
+// ExpectedResultsFilename returns the name of the expected results file of the corpus written to payloadFilename.
+func ExpectedResultsFilename(payloadFilename string) string {
+	return payloadFilename + expectedResultsExt
+}
+
+// ExpectedResults are the results of the aggregations on the generated corpus, to be compared with
+// the results of the same aggregations once the corpus is ingested.
+type ExpectedResults struct {
+	Events       uint64                               `json:"events"`
+	Aggregations map[string]ExpectedAggregationResult `json:"aggregations"`
+}
+
+// ExpectedAggregationResult is the result of an aggregation: Buckets for terms, Value otherwise.
+type ExpectedAggregationResult struct {
+	Buckets map[string]uint64 `json:"buckets,omitempty"`
+	Value   *float64          `json:"value,omitempty"`
+	// Capped is set when the field has more distinct values than the tracked ones: the values not tracked are
+	// missing from Buckets, and the cardinality is a lower bound
+	Capped bool `json:"capped,omitempty"`
+}
+
+// expectedResults accumulates the values of the fields of the generated events.
+type expectedResults struct {
+	aggregations []ExpectedAggregation
+	events       uint64
+	buckets      map[string]map[string]uint64
+	sums         map[string]float64
+	capped       map[string]bool
+	// values is reused across events
+	values map[string]string
+}
+
+// newExpectedResults returns the accumulator of the expected results, nil if no aggregation is requested.
+func (gc GeneratorCorpus) newExpectedResults() *expectedResults {
+	if len(gc.expectedAggregations) == 0 {
+		return nil
+	}
+
+	return &expectedResults{
+		aggregations: gc.expectedAggregations,
+		buckets:      make(map[string]map[string]uint64),
+		sums:         make(map[string]float64),
+		capped:       make(map[string]bool),
+		values:       make(map[string]string),
+	}
+}
+
+// add accounts for the values of the fields of an event, as traced while generating it.
+func (r *expectedResults) add(traces []genlib.FieldTrace) error {
+	r.events++
+
+	values := r.values
+	for k := range values {
+		delete(values, k)
+	}
+
+	for _, trace := range traces {
+		// a null or omitted field has no value to account for, as it is not indexed
+		if trace.Sparse != "" {
+			continue
+		}
+
+		// a field can be generated more than once in an event, the first value is the one accounted for
+		if _, ok := values[trace.Field]; !ok {
+			values[trace.Field] = trace.Value
+		}
+	}
+
+	for _, aggregation := range r.aggregations {
+		value, ok := values[aggregation.Field]
+		if !ok {
+			continue
+		}
+
+		name := aggregation.String()
+		switch aggregation.Type {
+		case AggregationSum:
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("aggregation %s: cannot sum value %q", name, value)
+			}
+
+			r.sums[name] += n
+		default:
+			buckets, ok := r.buckets[name]
+			if !ok {
+				buckets = make(map[string]uint64)
+				r.buckets[name] = buckets
+			}
+
+			// the distinct values tracked are bounded, as for the stats
+			if _, ok := buckets[value]; !ok && len(buckets) >= maxTrackedCardinality {
+				r.capped[name] = true
+				continue
+			}
+
+			buckets[value]++
+		}
+	}
+
+	return nil
+}
+
+func (r *expectedResults) results() ExpectedResults {
+	results := ExpectedResults{
+		Events:       r.events,
+		Aggregations: make(map[string]ExpectedAggregationResult, len(r.aggregations)),
+	}
+
+	for _, aggregation := range r.aggregations {
+		name := aggregation.String()
+		var value float64
+		switch aggregation.Type {
+		case AggregationTerms:
+			results.Aggregations[name] = ExpectedAggregationResult{Buckets: r.buckets[name], Capped: r.capped[name]}
+			continue
+		case AggregationSum:
+			value = r.sums[name]
+		case AggregationCardinality:
+			value = float64(len(r.buckets[name]))
+		}
+
+		results.Aggregations[name] = ExpectedAggregationResult{Value: &value, Capped: r.capped[name]}
+	}
+
+	return results
+}
+
+// writeExpectedResults writes the expected results next to the corpus file named filename in the corpora location.
+func (gc GeneratorCorpus) writeExpectedResults(expected *expectedResults, filename string) error {
+	body, err := json.MarshalIndent(expected.results(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := gc.fs.MkdirAll(gc.location, corpusLocPerm); err != nil {
+		return fmt.Errorf("cannot generate corpus location folder: %v", err)
+	}
+
+	if err := afero.WriteFile(gc.fs, ExpectedResultsFilename(path.Join(gc.location, filename)), append(body, '\n'), corpusPerm); err != nil {
+		return fmt.Errorf("cannot write expected results: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The formats of a corpus, see WithFormat.
+const (
+	// FormatBulk writes each event preceded by the action of a bulk request, the default for the data streams
+	FormatBulk = "bulk"
+	// FormatNDJSON writes the events one per line, the default for the templates
+	FormatNDJSON = "ndjson"
+	// FormatRaw writes the events one per line without escaping the generated string values, for plain log lines
+	FormatRaw = "raw"
+)
+
+// ErrBulkFormatWithTemplate is returned generating a corpus from a template in FormatBulk: the index of the bulk
+// request actions is the one of a data stream.
+var ErrBulkFormatWithTemplate = errors.New("the bulk format cannot be used with a template, it requires the index of a data stream")
+
+// ValidateFormat returns an error if format is not one of the formats of a corpus.
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatBulk, FormatNDJSON, FormatRaw:
+		return nil
+	default:
+		return fmt.Errorf("unknown format %s, must be one of %s, %s or %s", format, FormatBulk, FormatNDJSON, FormatRaw)
+	}
+}
+
+// WithFormat writes the corpus in format, either FormatBulk, FormatNDJSON or FormatRaw, instead of the default one:
+// FormatBulk for the data streams and FormatNDJSON for the templates.
+func WithFormat(format string) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.format = format
+		if format == FormatRaw {
+			gc.noJSONEscape = true
+		}
+	}
+}
+
+// WithExtension names the corpus file with extension, like log, instead of ndjson for the data streams, log for
+// FormatRaw, or the extension of the template.
+func WithExtension(extension string) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.extension = "." + strings.TrimPrefix(extension, ".")
+	}
+}
+
+// withBulkActions returns true if the events of the data streams are preceded by the bulk request actions.
+func (gc GeneratorCorpus) withBulkActions() bool {
+	return gc.format == "" || gc.format == FormatBulk
+}
+
+// dataStreamExtension returns the extension of the corpus files of the data streams.
+func (gc GeneratorCorpus) dataStreamExtension() string {
+	switch {
+	case gc.extension != "":
+		return gc.extension
+	case gc.format == FormatRaw:
+		return ".log"
+	default:
+		return ".ndjson"
+	}
+}
@@ -7,11 +7,16 @@ package corpus
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/dustin/go-humanize"
+	"io"
+	"math"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -19,14 +24,20 @@ import (
 	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
 	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
 	"github.com/spf13/afero"
+	"go.uber.org/multierr"
 )
 
 const (
 	templateTypeCustom = iota
 	templateTypeGoText
+	templateTypeStructured
 )
 
-var ErrNotValidTemplate = errors.New("please, pass --template-type as one of 'placeholder' or 'gotext'")
+var ErrNotValidTemplate = errors.New("please, pass --template-type as one of 'placeholder', 'gotext' or 'structured'")
+
+// ErrInterrupted is returned when the generation is stopped before reaching the requested size:
+// the partial corpus is closed and ends with a complete event.
+var ErrInterrupted = errors.New("generation interrupted")
 
 type Config = config.Config
 type Fields = fields.Fields
@@ -35,34 +46,281 @@ type Fields = fields.Fields
 // It's used to allow replacing the value with a known one during testing.
 type timestamp func() int64
 
-func NewGenerator(config Config, fs afero.Fs, location string) (GeneratorCorpus, error) {
-	return GeneratorCorpus{
+type GeneratorCorpusOption func(*GeneratorCorpus)
+
+// WithTimeRange spreads the generated events evenly between from and to:
+// all the date fields of an event share the same timestamp.
+func WithTimeRange(from, to time.Time) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.timeRangeFrom = from
+		gc.timeRangeTo = to
+	}
+}
+
+// WithSeed sets the seed of the random generators: 0 means a random seed.
+func WithSeed(seed int64) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.seed = seed
+	}
+}
+
+// WithTelemetry indexes the summary of the run as a document in the index of the Elasticsearch cluster at elasticsearchURL.
+func WithTelemetry(elasticsearchURL, index string) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.telemetryURL = elasticsearchURL
+		gc.telemetryIndex = index
+	}
+}
+
+// WithFieldsTracing writes to w, as a JSON line, how each field has been generated for one event every n.
+func WithFieldsTracing(n uint64, w io.Writer) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.traceFieldsEvery = n
+		gc.traceFieldsWriter = w
+	}
+}
+
+// WithMiddlewares passes each generated event through the middlewares before writing it, see genlib.WithMiddlewares.
+func WithMiddlewares(middlewares ...genlib.Middleware) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.middlewares = append(gc.middlewares, middlewares...)
+	}
+}
+
+// WithSequences rewrites the events as the steps of sequences of correlated events, see genlib.NewSequences:
+// after the middlewares, for events that are JSON objects.
+func WithSequences(sequences ...genlib.Sequence) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.sequences = append(gc.sequences, sequences...)
+	}
+}
+
+// WithResume resumes the generation of the corpus file at corpusPath from its checkpoint, see LoadCheckpoint: the file
+// is truncated to the bytes of the checkpoint, the events already written are generated again with the seed of the
+// checkpoint to restore the state of the random generators, without being written, and the next ones are appended.
+// The corpus must be generated with the same inputs and options as the interrupted run.
+func WithResume(corpusPath string, checkpoint Checkpoint) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.resume = &checkpoint
+		gc.location = filepath.Dir(corpusPath)
+		gc.filename = filepath.Base(corpusPath)
+		gc.seed = checkpoint.Seed
+	}
+}
+
+// WithEvents stops the generation after n events, or when reaching the total size if provided first:
+// the total size is optional then.
+func WithEvents(n uint64) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.events = n
+	}
+}
+
+// WithFilename writes the corpus to the file named filename in the corpora location, instead of one named after
+// the template or the data stream and the time of the run.
+func WithFilename(filename string) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.filename = filename
+	}
+}
+
+// WithSink writes the corpus to sink, instead of a file in the corpora location.
+func WithSink(sink Sink) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.sink = sink
+	}
+}
+
+// WithWarnings writes to w the settings of the config that are ignored when generating the fields.
+func WithWarnings(w io.Writer) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.warningsWriter = w
+	}
+}
+
+// WithStrict fails the generation if the config references fields not present in the fields definition,
+// or has settings that are ignored when generating the fields.
+func WithStrict() GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.strict = true
+	}
+}
+
+// WithTSDB generates corpora for TSDB data streams: the generation fails if a dimension field is not referenced
+// by the template or can be null or omitted, and without a template the dimensions come first in each event.
+// The events belong to a bounded count of time series, see WithTSDBSeries, whose metrics and timestamps are
+// rewritten by genlib.NewTimeSeries.
+func WithTSDB() GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.tsdb = true
+	}
+}
+
+// WithTSDBSeries sets the count of time series of the events generated with WithTSDB, instead of
+// genlib.DefaultTimeSeries: the combinations of values of the dimension fields.
+func WithTSDBSeries(series int) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.tsdbSeries = series
+	}
+}
+
+// WithAPM rewrites the events as the transactions and the spans of APM traces, see genlib.NewAPMTraces: after the
+// middlewares and the sequences, for events that are JSON objects. Each trace has at most the count of spans set by
+// WithAPMMaxSpans.
+func WithAPM() GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.apm = true
+	}
+}
+
+// WithAPMMaxSpans sets the most spans of the traces generated with WithAPM, instead of genlib.DefaultAPMMaxSpans.
+func WithAPMMaxSpans(maxSpans int) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.apmMaxSpans = maxSpans
+	}
+}
+
+// WithECSRealism generates the well-known ECS fields, like user_agent.original or source.ip, with realistic values,
+// unless the config sets how their values are generated.
+func WithECSRealism() GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.ecsRealism = true
+	}
+}
+
+// WithoutJSONEscape writes the generated string values as they are, instead of escaping their quotes, backslashes
+// and control characters for JSON strings: for templates of events that are not JSON.
+func WithoutJSONEscape() GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.noJSONEscape = true
+	}
+}
+
+// WithSyntheticSource writes the events the way Elasticsearch reconstructs them with synthetic _source,
+// see genlib.NewSyntheticSource: after the middlewares, for events that are JSON objects.
+func WithSyntheticSource() GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.syntheticSource = true
+	}
+}
+
+// WithJSONKeys writes the keys of the events as dotted names or as nested objects, either genlib.JSONKeysDotted or
+// genlib.JSONKeysNested, see genlib.NewJSONKeys: after the middlewares, for events that are JSON objects.
+func WithJSONKeys(keys string) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.jsonKeys = keys
+	}
+}
+
+// WithOTLP writes the events as OTLP/JSON export requests of signal, either genlib.OTLPLogs or genlib.OTLPMetrics,
+// see genlib.NewOTLP: after the middlewares, for events that are JSON objects, without the bulk request actions.
+func WithOTLP(signal string, mapping genlib.OTLPMapping) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.otlpSignal = signal
+		gc.otlpMapping = mapping
+	}
+}
+
+// WithEnvelope wraps the events in the publishing envelope of the Beats and the Elastic Agent, see genlib.NewEnvelope:
+// after the middlewares, without the bulk request actions. The data stream of the envelope of the corpora of the
+// data streams defaults to the target of their bulk request actions.
+func WithEnvelope(envelope genlib.Envelope) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.envelope = envelope
+	}
+}
+
+// WithLateArrivals moves back the @timestamp of a percentage of the events, from 0 to 100, by up to maxLateness, see
+// genlib.NewLateArrivals: after the middlewares, for events that are JSON objects.
+func WithLateArrivals(percentage float64, maxLateness time.Duration) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.lateArrivalPercentage = percentage
+		gc.maxLateness = maxLateness
+	}
+}
+
+// WithMalformedPercentage breaks a percentage of the events, from 0 to 100, see genlib.NewMalformed: last, after the
+// middlewares and the envelope, without the bulk request actions.
+func WithMalformedPercentage(percentage float64) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.malformedPercentage = percentage
+	}
+}
+
+// WithFloatPrecision writes the values of floating point fields with precision decimal places, and never in scientific
+// notation, unless the config sets the precision of the field.
+func WithFloatPrecision(precision int) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.floatPrecision = &precision
+	}
+}
+
+// WithFieldsCache caches the fields downloaded from the package registry in dir, see fields.WithCacheDir.
+func WithFieldsCache(dir string) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.fieldsLoadOptions = append(gc.fieldsLoadOptions, fields.WithCacheDir(dir))
+	}
+}
+
+// WithOffline loads the fields from the cache of WithFieldsCache only, never from the package registry.
+func WithOffline() GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.fieldsLoadOptions = append(gc.fieldsLoadOptions, fields.WithOffline())
+	}
+}
+
+func NewGenerator(config Config, fs afero.Fs, location string, opts ...GeneratorCorpusOption) (GeneratorCorpus, error) {
+	gc := GeneratorCorpus{
 		config:       config,
 		fs:           fs,
 		templateType: templateTypeCustom,
 		location:     location,
 		timestamp:    time.Now().Unix,
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(&gc)
+	}
+
+	gc.initAudit()
+	if gc.seed == 0 {
+		gc.seed = time.Now().UnixNano()
+	}
+
+	return gc, nil
 }
 
-func NewGeneratorWithTemplate(config Config, fs afero.Fs, location, templateType string) (GeneratorCorpus, error) {
+func NewGeneratorWithTemplate(config Config, fs afero.Fs, location, templateType string, opts ...GeneratorCorpusOption) (GeneratorCorpus, error) {
 
 	var templateTypeValue int
 	if templateType == "placeholder" {
 		templateTypeValue = templateTypeCustom
 	} else if templateType == "gotext" {
 		templateTypeValue = templateTypeGoText
+	} else if templateType == "structured" {
+		templateTypeValue = templateTypeStructured
 	} else {
 		return GeneratorCorpus{}, ErrNotValidTemplate
 	}
 
-	return GeneratorCorpus{
+	gc := GeneratorCorpus{
 		config:       config,
 		fs:           fs,
 		templateType: templateTypeValue,
 		location:     location,
 		timestamp:    time.Now().Unix,
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(&gc)
+	}
+
+	gc.initAudit()
+	if gc.seed == 0 {
+		gc.seed = time.Now().UnixNano()
+	}
+
+	return gc, nil
 }
 
 // TestNewGenerator sets up a GeneratorCorpus configured to be used in testing.
@@ -77,8 +335,90 @@ type GeneratorCorpus struct {
 	fs           afero.Fs
 	location     string
 	templateType int
+	includeDir   string
 	// timestamp allow overriding value in tests
 	timestamp timestamp
+
+	timeRangeFrom time.Time
+	timeRangeTo   time.Time
+
+	seed int64
+
+	telemetryURL   string
+	telemetryIndex string
+
+	traceFieldsEvery  uint64
+	traceFieldsWriter io.Writer
+
+	strict         bool
+	warningsWriter io.Writer
+
+	sink           Sink
+	gzip           bool
+	asyncBuffers   int
+	writeBatchSize int
+	filename       string
+
+	middlewares []genlib.Middleware
+	sequences   []genlib.Sequence
+
+	rate     float64
+	duration time.Duration
+
+	expectedAggregations []ExpectedAggregation
+
+	maxFileSize      uint64
+	maxEventsPerFile uint64
+
+	progressWriter   io.Writer
+	progressInterval time.Duration
+	statsFilename    string
+
+	events uint64
+	// resume is the checkpoint of the corpus file whose generation is resumed, if any
+	resume *Checkpoint
+
+	idStrategy IDStrategy
+	bulkAction BulkAction
+
+	tsdb            bool
+	tsdbSeries      int
+	apm             bool
+	apmMaxSpans     int
+	ecsRealism      bool
+	noJSONEscape    bool
+	syntheticSource bool
+	jsonKeys        string
+	format          string
+	extension       string
+	otlpSignal      string
+	envelope        genlib.Envelope
+	otlpMapping     genlib.OTLPMapping
+	// malformedPercentage is the percentage of the events broken on purpose
+	malformedPercentage float64
+	duplicates          Duplicates
+	// lateArrivalPercentage is the percentage of the events whose @timestamp is moved back by up to maxLateness
+	lateArrivalPercentage float64
+	maxLateness           time.Duration
+	// floatPrecision is nil to keep the default formatting of the floating point values
+	floatPrecision *int
+
+	soakWriter  io.Writer
+	soakOptions *SoakOptions
+
+	fieldsLoadOptions []fields.LoadOption
+
+	referenceTime time.Time
+	// audit is shared by the copies of the generator, it records all the corpora of the run
+	audit           *AuditRecord
+	auditFilename   string
+	auditInputPaths []string
+
+	manifest       bool
+	manifestConfig []byte
+
+	// spec is the corpus spec the generator was set up with by NewFromSpec, if any
+	spec *Spec
 }
 
 func (gc GeneratorCorpus) Location() string {
@@ -88,35 +428,129 @@ func (gc GeneratorCorpus) Location() string {
 // bulkPayloadFilename computes the bulkPayloadFilename for the corpus to be generated.
 // To provide unique names the provided slug is prepended with current timestamp.
 func (gc GeneratorCorpus) bulkPayloadFilename(integrationPackage, dataStream, packageVersion string) string {
+	if gc.filename != "" {
+		return gc.filename
+	}
+
 	slug := integrationPackage + "-" + dataStream + "-" + packageVersion
-	filename := fmt.Sprintf("%d-%s.ndjson", gc.timestamp(), sanitizeFilename(slug))
+	filename := fmt.Sprintf("%d-%s%s", gc.timestamp(), sanitizeFilename(slug), sanitizeFilename(gc.dataStreamExtension()))
 	return filename
 }
 
 // bulkPayloadFilenameWithTemplate computes the bulkPayloadFilename for the corpus to be generated.
 // To provide unique names the provided slug is prepended with current timestamp.
 func (gc GeneratorCorpus) bulkPayloadFilenameWithTemplate(templatePath string) string {
+	if gc.filename != "" {
+		return gc.filename
+	}
+
 	slug := path.Base(templatePath)
 	ext := path.Ext(templatePath)
 	slug = slug[0 : len(slug)-len(ext)]
+	if gc.extension != "" {
+		ext = gc.extension
+	}
+
 	filename := fmt.Sprintf("%d-%s%s", gc.timestamp(), sanitizeFilename(slug), sanitizeFilename(ext))
 	return filename
 }
 
+// validateConfig reports the settings of the config that are ignored when generating flds.
+// In strict mode they are errors, as well as config entries referencing fields not in flds.
+func (gc GeneratorCorpus) validateConfig(flds Fields) error {
+	warnings := genlib.ConfigWarnings(gc.config, flds)
+	if !gc.strict {
+		if gc.warningsWriter != nil {
+			for _, warning := range warnings {
+				fmt.Fprintln(gc.warningsWriter, "warning:", warning)
+			}
+		}
+
+		return nil
+	}
+
+	fieldNames := make([]string, 0, len(flds))
+	for _, field := range flds {
+		fieldNames = append(fieldNames, field.Name)
+	}
+
+	err := gc.config.ValidateFields(fieldNames)
+	for _, warning := range warnings {
+		err = multierr.Append(err, errors.New(warning))
+	}
+
+	return err
+}
+
+// fieldsTrace is the trace of the fields of an event, identified by its position in the corpus
+type fieldsTrace struct {
+	Event  uint64              `json:"event"`
+	Fields []genlib.FieldTrace `json:"fields"`
+}
+
 var corpusLocPerm = os.FileMode(0770)
 var corpusPerm = os.FileMode(0660)
 
-func (gc GeneratorCorpus) eventsPayloadFromFields(template []byte, fields Fields, totSize uint64, createPayload []byte, f afero.File) error {
+func (gc GeneratorCorpus) eventsPayloadFromFields(ctx context.Context, template []byte, fields Fields, totSize uint64, createPayload []byte, sink Sink, observers []fieldsObserver, summary *RunSummary) error {
+
+	// the cardinalities relative to the size of the corpus are scaled to the count of events and to the total size
+	cfg, err := gc.config.ScaleCardinalities(gc.events, totSize)
+	if err != nil {
+		return err
+	}
+
+	if gc.ecsRealism {
+		cfg = genlib.ECSRealism(cfg, fields)
+	}
+
+	if gc.floatPrecision != nil {
+		cfg = genlib.FloatPrecision(cfg, fields, *gc.floatPrecision)
+	}
+
+	if gc.tsdb {
+		series := gc.tsdbSeries
+		if series <= 0 {
+			series = genlib.DefaultTimeSeries
+		}
+
+		cfg = genlib.TimeSeriesDimensions(cfg, fields, series)
+	}
+
+	// the middlewares are given the renamed fields, as the generators
+	cfg, fields = genlib.RenameFields(cfg, fields)
+
+	if cfg.HasQuotas() {
+		if len(gc.middlewares) > 0 {
+			return errors.New("the quotas of the values of enum cannot be met when middlewares drop events")
+		}
+
+		if len(gc.sequences) > 0 {
+			return errors.New("the quotas of the values of enum cannot be met when sequences rewrite events")
+		}
+
+		if gc.apm {
+			return errors.New("the quotas of the values of enum cannot be met when APM traces rewrite events")
+		}
+
+		if err := cfg.CheckQuotas(gc.events); err != nil {
+			return err
+		}
+	}
 
 	var evgen genlib.Generator
-	var err error
 	if len(template) == 0 {
-		evgen, err = genlib.NewGenerator(gc.config, fields)
+		if gc.tsdb {
+			fields = genlib.SortDimensionsFirst(fields)
+		}
+
+		evgen, err = genlib.NewGenerator(cfg, fields)
 	} else {
 		if gc.templateType == templateTypeCustom {
-			evgen, err = genlib.NewGeneratorWithCustomTemplate(template, gc.config, fields)
+			evgen, err = genlib.NewGeneratorWithCustomTemplate(template, cfg, fields)
 		} else if gc.templateType == templateTypeGoText {
-			evgen, err = genlib.NewGeneratorWithTextTemplate(template, gc.config, fields)
+			evgen, err = genlib.NewGeneratorWithTextTemplate(template, cfg, fields)
+		} else if gc.templateType == templateTypeStructured {
+			evgen, err = genlib.NewGeneratorWithStructuredTemplate(template, cfg, fields)
 		} else {
 			return ErrNotValidTemplate
 		}
@@ -127,7 +561,98 @@ func (gc GeneratorCorpus) eventsPayloadFromFields(template []byte, fields Fields
 		return err
 	}
 
+	if gc.tsdb {
+		if err := genlib.CheckDimensions(cfg, evgen, fields); err != nil {
+			return err
+		}
+	}
+
+	evgen = genlib.WithMiddlewares(evgen, gc.middlewares...)
+	if len(gc.sequences) > 0 {
+		evgen = genlib.WithMiddlewares(evgen, genlib.NewSequences(gc.sequences))
+	}
+
+	if gc.apm {
+		maxSpans := gc.apmMaxSpans
+		if maxSpans <= 0 {
+			maxSpans = genlib.DefaultAPMMaxSpans
+		}
+
+		evgen = genlib.WithMiddlewares(evgen, genlib.NewAPMTraces(maxSpans))
+	}
+
+	if gc.tsdb {
+		evgen = genlib.WithMiddlewares(evgen, genlib.NewTimeSeries(fields))
+	}
+
+	if gc.syntheticSource {
+		evgen = genlib.WithMiddlewares(evgen, genlib.NewSyntheticSource(fields))
+	}
+
+	if gc.jsonKeys != "" {
+		jsonKeys, err := genlib.NewJSONKeys(gc.jsonKeys)
+		if err != nil {
+			return err
+		}
+
+		evgen = genlib.WithMiddlewares(evgen, jsonKeys)
+	}
+
+	if gc.lateArrivalPercentage > 0 {
+		lateArrivals, err := genlib.NewLateArrivals(gc.lateArrivalPercentage, gc.maxLateness)
+		if err != nil {
+			return err
+		}
+
+		evgen = genlib.WithMiddlewares(evgen, lateArrivals)
+	}
+
+	if gc.otlpSignal != "" {
+		otlp, err := genlib.NewOTLP(gc.otlpSignal, fields, gc.otlpMapping)
+		if err != nil {
+			return err
+		}
+
+		evgen = genlib.WithMiddlewares(evgen, otlp)
+		// the OTLP export requests are not indexed with bulk requests
+		createPayload = nil
+	}
+
+	if gc.envelope.Beat != "" {
+		envelope, err := genlib.NewEnvelope(gc.envelope)
+		if err != nil {
+			return err
+		}
+
+		evgen = genlib.WithMiddlewares(evgen, envelope)
+		// the events are shipped by a Beat or the Elastic Agent, not indexed with bulk requests
+		createPayload = nil
+	}
+
+	if gc.malformedPercentage > 0 {
+		malformed, err := genlib.NewMalformed(gc.malformedPercentage)
+		if err != nil {
+			return err
+		}
+
+		evgen = genlib.WithMiddlewares(evgen, malformed)
+	}
+
+	genlib.InitGeneratorRandSeed(gc.seed)
 	state := genlib.NewGenState()
+	state.SetRawValues(gc.noJSONEscape)
+	state.SetReferenceTime(gc.referenceTime)
+	state.SetEvents(gc.events)
+
+	if gc.audit != nil {
+		if _, ok := sink.(eventSink); !ok {
+			corpusHash := sha256.New()
+			sink = hashingSink{Sink: sink, hash: corpusHash}
+			defer func() {
+				summary.SHA256 = hex.EncodeToString(corpusHash.Sum(nil))
+			}()
+		}
+	}
 
 	var buf *bytes.Buffer
 	if len(template) == 0 {
@@ -136,102 +661,477 @@ func (gc GeneratorCorpus) eventsPayloadFromFields(template []byte, fields Fields
 		buf = bytes.NewBufferString("")
 	}
 
+	actions, err := gc.newBulkActions(createPayload)
+	if err != nil {
+		return err
+	}
+
+	if actions != nil {
+		defer func() {
+			summary.DuplicateIDs = actions.duplicates
+		}()
+	}
+
+	duplicates := gc.newDuplicator()
+	if duplicates != nil {
+		defer func() {
+			summary.DuplicateEvents = duplicates.count
+		}()
+	}
+
+	timeRangeSpan := gc.timeRangeTo.Sub(gc.timeRangeFrom)
+
+	var traceFields *json.Encoder
+	if gc.traceFieldsEvery > 0 {
+		traceFields = json.NewEncoder(gc.traceFieldsWriter)
+	}
+
+	progress := gc.newProgressReporter(totSize)
+	soak := gc.newSoakMonitor()
+	defer soak.end()
+
+	checkpoints := gc.newCheckpointer(sink)
+
+	start := time.Now()
 	var currentSize uint64
-	for currentSize < totSize {
+	for totSize == 0 || currentSize < totSize {
+		if gc.events > 0 && summary.Events >= gc.events {
+			break
+		}
+
+		if soak.done() {
+			break
+		}
+
+		if err := ctx.Err(); err != nil {
+			_ = evgen.Close()
+			if (gc.rate > 0 || gc.soakOptions != nil) && totSize == 0 && gc.events == 0 {
+				return nil
+			}
+
+			// interrupted while generating again the events already in the resumed corpus, its checkpoint still holds
+			checkpoint := Checkpoint{Events: summary.Events, Bytes: currentSize}
+			if gc.resume != nil && summary.Events < gc.resume.Events {
+				checkpoint = *gc.resume
+			}
+
+			if err := checkpoints.write(sink, checkpoint.Events, checkpoint.Bytes); err != nil {
+				return err
+			}
+
+			return fmt.Errorf("%w: %v", ErrInterrupted, err)
+		}
+
+		if gc.rate > 0 {
+			due, err := gc.waitNextEvent(ctx, start, summary.Events, sink)
+			if err != nil {
+				return err
+			}
+
+			if !due {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				break
+			}
+
+			state.SetEventTime(time.Now())
+		}
+
 		buf.Truncate(len(createPayload))
 
-		if err := evgen.Emit(state, buf); err != nil {
+		if !gc.timeRangeFrom.IsZero() {
+			// the position of the event in the time range follows the progress towards totSize, or towards the events
+			// count, or through the soak run
+			var progress float64
+			switch {
+			case totSize > 0:
+				progress = float64(currentSize) / float64(totSize)
+			case gc.events > 0:
+				progress = float64(summary.Events) / float64(gc.events)
+			case gc.soakOptions != nil && gc.soakOptions.Duration > 0:
+				progress = math.Min(float64(time.Since(start))/float64(gc.soakOptions.Duration), 1)
+			}
+			state.SetEventTime(gc.timeRangeFrom.Add(time.Duration(progress * float64(timeRangeSpan))))
+		}
+
+		// the duplicated events are not generated, they are not traced, and they are observed with the traces of the
+		// event they duplicate, unless they keep its _id and overwrite it
+		duplicated, isDuplicate := duplicates.draw()
+		tracing := !isDuplicate && gc.traceFieldsEvery > 0 && summary.Events%gc.traceFieldsEvery == 0
+		// the observers are given the traces of the fields
+		state.SetTracing(tracing || len(observers) > 0)
+
+		if isDuplicate {
+			buf.Write(duplicated.event)
+		} else if err := evgen.Emit(state, buf); err != nil {
+			return err
+		}
+
+		if tracing {
+			if err := traceFields.Encode(fieldsTrace{Event: summary.Events, Fields: state.Traces()}); err != nil {
+				return err
+			}
+		}
+
+		traces := state.Traces()
+		if isDuplicate {
+			traces = duplicated.traces
+		}
+
+		for i := 0; i < len(observers) && (!isDuplicate || !gc.duplicates.SameID); i++ {
+			if err := observers[i].add(traces); err != nil {
+				return err
+			}
+		}
+
+		payload := buf
+		// the events already in the resumed corpus are generated again to restore the state of the generators,
+		// but not written
+		resumed := gc.resume != nil && summary.Events < gc.resume.Events
+		if events, ok := sink.(eventSink); ok {
+			err = events.WriteEvent(buf.Bytes()[len(createPayload):])
+		} else {
+			buf.WriteByte('\n')
+			if isDuplicate && duplicated.payload != nil {
+				payload = bytes.NewBuffer(duplicated.payload)
+			} else if actions != nil {
+				if payload, err = actions.withAction(summary.Events, buf.Bytes()[len(createPayload):]); err != nil {
+					return err
+				}
+			}
+
+			if !resumed {
+				_, err = sink.Write(payload.Bytes())
+			}
+		}
+
+		if err != nil {
 			return err
 		}
 
-		buf.WriteByte('\n')
+		if !isDuplicate {
+			duplicates.add(summary.Events, bytes.TrimSuffix(buf.Bytes()[len(createPayload):], []byte("\n")), payload.Bytes(), traces)
+		}
+
+		currentSize += uint64(payload.Len())
+		summary.Events += 1
+		if resumed && summary.Events == gc.resume.Events {
+			// the size of the events generated again can differ, like the ones of the dates after the current time
+			currentSize = gc.resume.Bytes
+		}
 
-		if _, err = f.Write(buf.Bytes()); err != nil {
+		summary.Bytes = currentSize
+		progress.update(summary)
+		if err := soak.check(summary.Events); err != nil {
+			_ = evgen.Close()
 			return err
 		}
 
-		currentSize += uint64(buf.Len())
+		// the size of the events generated again can differ from the one of the events in the resumed corpus, that
+		// are checkpointed already
+		if checkpoints.due() && !resumed {
+			if err := checkpoints.write(sink, summary.Events, currentSize); err != nil {
+				return err
+			}
+		}
 	}
 
-	return evgen.Close()
+	if err := evgen.Close(); err != nil {
+		return err
+	}
+
+	return checkpoints.remove()
 }
 
 // Generate generates a bulk request corpus and persist it to file.
-func (gc GeneratorCorpus) Generate(packageRegistryBaseURL, integrationPackage, dataStream, packageVersion, totSize string) (string, error) {
-	totSizeInBytes, err := humanize.ParseBytes(totSize)
+// When ctx is done the generation stops and the partial corpus filename is returned alongside ErrInterrupted.
+func (gc GeneratorCorpus) Generate(ctx context.Context, packageRegistryBaseURL, integrationPackage, dataStream, packageVersion, totSize string) (string, error) {
+	totSizeInBytes, err := gc.parseTotSize(totSize)
+	if err != nil {
+		return "", err
+	}
+
+	flds, err := fields.LoadFields(ctx, packageRegistryBaseURL, integrationPackage, dataStream, packageVersion, gc.fieldsLoadOptions...)
 	if err != nil {
-		return "", fmt.Errorf("cannot generate corpus location folder: %v", err)
+		return "", err
 	}
-	if err := gc.fs.MkdirAll(gc.location, corpusLocPerm); err != nil {
-		return "", fmt.Errorf("cannot generate corpus location folder: %v", err)
+
+	if err := gc.validateConfig(flds); err != nil {
+		return "", err
 	}
 
-	payloadFilename := path.Join(gc.location, gc.bulkPayloadFilename(integrationPackage, dataStream, packageVersion))
-	f, err := gc.fs.OpenFile(payloadFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, corpusPerm)
+	return gc.generateFromFields(ctx, flds, integrationPackage, dataStream, packageVersion, totSize, totSizeInBytes, map[string]string{
+		"package_registry_base_url": packageRegistryBaseURL,
+	})
+}
+
+// GenerateFromPackage generates a bulk request corpus for a data stream of the local package at packagePath, either
+// its source directory or its zip archive, and persist it to file.
+// When ctx is done the generation stops and the partial corpus filename is returned alongside ErrInterrupted.
+func (gc GeneratorCorpus) GenerateFromPackage(ctx context.Context, packagePath, dataStream, totSize string) (string, error) {
+	totSizeInBytes, err := gc.parseTotSize(totSize)
 	if err != nil {
 		return "", err
 	}
 
-	ctx := context.Background()
-	flds, err := fields.LoadFields(ctx, packageRegistryBaseURL, integrationPackage, dataStream, packageVersion)
+	flds, manifest, err := fields.LoadFieldsFromPackage(ctx, packagePath, dataStream)
 	if err != nil {
 		return "", err
 	}
 
-	createPayload := []byte(`{ "create" : { "_index": "metrics-` + integrationPackage + `.` + dataStream + `-default" } }` + "\n")
+	if err := gc.validateConfig(flds); err != nil {
+		return "", err
+	}
+
+	return gc.generateFromFields(ctx, flds, manifest.Name, dataStream, manifest.Version, totSize, totSizeInBytes, map[string]string{
+		"package_path": packagePath,
+	})
+}
+
+// GenerateDataStreams generates a bulk request corpus for each of the data streams of the integration package,
+// or for all of them if none is provided, sharing the config, and persist them to files, one for each data stream.
+// The fields of all the data streams are loaded before generating any corpus.
+// When ctx is done the generation stops and the filenames of the corpora generated so far, the last one partial,
+// are returned alongside ErrInterrupted.
+func (gc GeneratorCorpus) GenerateDataStreams(ctx context.Context, packageRegistryBaseURL, integrationPackage string, dataStreams []string, packageVersion, totSize string) ([]string, error) {
+	totSizeInBytes, err := gc.parseTotSize(totSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dataStreams) == 0 {
+		if dataStreams, err = fields.LoadDataStreams(ctx, packageRegistryBaseURL, integrationPackage, packageVersion, gc.fieldsLoadOptions...); err != nil {
+			return nil, err
+		}
+	}
+
+	fldsByDataStream := make([]Fields, 0, len(dataStreams))
+	for _, dataStream := range dataStreams {
+		flds, err := fields.LoadFields(ctx, packageRegistryBaseURL, integrationPackage, dataStream, packageVersion, gc.fieldsLoadOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("data stream %s: %w", dataStream, err)
+		}
+
+		fldsByDataStream = append(fldsByDataStream, flds)
+	}
 
-	err = gc.eventsPayloadFromFields(nil, flds, totSizeInBytes, createPayload, f)
+	return gc.generateDataStreams(ctx, fldsByDataStream, integrationPackage, dataStreams, packageVersion, totSize, totSizeInBytes, map[string]string{
+		"package_registry_base_url": packageRegistryBaseURL,
+	})
+}
+
+// GenerateFromPackageDataStreams generates a bulk request corpus for each of the data streams of the local package
+// at packagePath, or for all of them if none is provided, like GenerateDataStreams.
+func (gc GeneratorCorpus) GenerateFromPackageDataStreams(ctx context.Context, packagePath string, dataStreams []string, totSize string) ([]string, error) {
+	totSizeInBytes, err := gc.parseTotSize(totSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dataStreams) == 0 {
+		if dataStreams, err = fields.LoadDataStreamsFromPackage(packagePath); err != nil {
+			return nil, err
+		}
+	}
+
+	var manifest fields.PackageManifest
+	fldsByDataStream := make([]Fields, 0, len(dataStreams))
+	for _, dataStream := range dataStreams {
+		var flds Fields
+		flds, manifest, err = fields.LoadFieldsFromPackage(ctx, packagePath, dataStream)
+		if err != nil {
+			return nil, err
+		}
+
+		fldsByDataStream = append(fldsByDataStream, flds)
+	}
+
+	return gc.generateDataStreams(ctx, fldsByDataStream, manifest.Name, dataStreams, manifest.Version, totSize, totSizeInBytes, map[string]string{
+		"package_path": packagePath,
+	})
+}
+
+// generateDataStreams generates the corpus of each of the data streams, one after the other, from their fields in
+// fldsByDataStream. The config is validated once against the fields of all the data streams: in strict mode a
+// config entry must reference a field of any of them.
+func (gc GeneratorCorpus) generateDataStreams(ctx context.Context, fldsByDataStream []Fields, integrationPackage string, dataStreams []string, packageVersion, totSize string, totSizeInBytes uint64, runArgs map[string]string) ([]string, error) {
+	if gc.resume != nil {
+		return nil, errors.New("the corpora of several data streams cannot be resumed, only a single corpus can")
+	}
+
+	var allFlds Fields
+	seen := make(map[string]struct{})
+	for _, flds := range fldsByDataStream {
+		for _, field := range flds {
+			if _, ok := seen[field.Name]; !ok {
+				seen[field.Name] = struct{}{}
+				allFlds = append(allFlds, field)
+			}
+		}
+	}
+
+	if err := gc.validateConfig(allFlds); err != nil {
+		return nil, err
+	}
+
+	payloadFilenames := make([]string, 0, len(dataStreams))
+	for i, dataStream := range dataStreams {
+		dataStreamRunArgs := make(map[string]string, len(runArgs))
+		for k, v := range runArgs {
+			dataStreamRunArgs[k] = v
+		}
+
+		payloadFilename, err := gc.generateFromFields(ctx, fldsByDataStream[i], integrationPackage, dataStream, packageVersion, totSize, totSizeInBytes, dataStreamRunArgs)
+		if payloadFilename != "" {
+			payloadFilenames = append(payloadFilenames, payloadFilename)
+		}
+
+		if err != nil {
+			return payloadFilenames, err
+		}
+	}
+
+	return payloadFilenames, nil
+}
+
+// generateFromFields generates the corpus of flds, the fields of dataStream of the integration package,
+// runArgs are added to the arguments of the run summary.
+func (gc GeneratorCorpus) generateFromFields(ctx context.Context, flds Fields, integrationPackage, dataStream, packageVersion, totSize string, totSizeInBytes uint64, runArgs map[string]string) (string, error) {
+	bulkPayloadFilename := gc.bulkPayloadFilename(integrationPackage, dataStream, packageVersion)
+	sink, err := gc.openSink(ctx, bulkPayloadFilename)
 	if err != nil {
 		return "", err
 	}
 
-	if err := f.Close(); err != nil {
+	payloadFilename := sink.Name()
+
+	runArgs["integration"] = integrationPackage
+	runArgs["data_stream"] = dataStream
+	runArgs["package_version"] = packageVersion
+	runArgs["tot_size"] = totSize
+	summary := gc.newRunSummary(runArgs)
+
+	var createPayload []byte
+	if gc.withBulkActions() {
+		createPayload = gc.createPayload(integrationPackage, dataStream)
+	}
+
+	if gc.envelope.Beat != "" && gc.envelope.DataStream == "" {
+		gc.envelope.DataStream = gc.bulkIndex(integrationPackage, dataStream)
+	}
+
+	expected := gc.newExpectedResults()
+	cardinalities := gc.newFieldCardinalities()
+	err = gc.eventsPayloadFromFields(ctx, nil, flds, totSizeInBytes, createPayload, sink, fieldsObservers(expected, cardinalities), &summary)
+	if closeErr := closeSink(sink, err); closeErr != nil && err == nil {
+		err = closeErr
+	}
+
+	if expected != nil && (err == nil || errors.Is(err, ErrInterrupted)) {
+		if writeErr := gc.writeExpectedResults(expected, bulkPayloadFilename); writeErr != nil {
+			return "", writeErr
+		}
+	}
+
+	if err != nil && !errors.Is(err, ErrInterrupted) {
 		return "", err
 	}
 
+	if endErr := gc.endRun(&summary, payloadFilename, err != nil, cardinalities); endErr != nil {
+		return payloadFilename, multierr.Append(err, endErr)
+	}
+
+	if gc.manifest {
+		if manifestErr := gc.writeManifest(bulkPayloadFilename, summary, flds, nil); manifestErr != nil {
+			return payloadFilename, multierr.Append(err, manifestErr)
+		}
+	}
+
 	return payloadFilename, err
 }
 
 // GenerateWithTemplate generates a template based corpus and persist it to file.
-func (gc GeneratorCorpus) GenerateWithTemplate(templatePath, fieldsDefinitionPath, totSize string) (string, error) {
-	totSizeInBytes, err := humanize.ParseBytes(totSize)
+// When ctx is done the generation stops and the partial corpus filename is returned alongside ErrInterrupted.
+func (gc GeneratorCorpus) GenerateWithTemplate(ctx context.Context, templatePath, fieldsDefinitionPath, totSize string) (string, error) {
+	flds, err := fields.LoadFieldsWithTemplate(ctx, fieldsDefinitionPath)
 	if err != nil {
-		return "", fmt.Errorf("cannot generate corpus location folder: %v", err)
+		return "", err
 	}
-	if err := gc.fs.MkdirAll(gc.location, corpusLocPerm); err != nil {
-		return "", fmt.Errorf("cannot generate corpus location folder: %v", err)
+
+	return gc.generateWithTemplate(ctx, templatePath, flds, map[string]string{"fields_definition_path": fieldsDefinitionPath}, totSize)
+}
+
+// generateWithTemplate generates the corpus of the template at templatePath from flds, runArgs are the arguments
+// of the run besides the template path, its type and the total size.
+func (gc GeneratorCorpus) generateWithTemplate(ctx context.Context, templatePath string, flds Fields, runArgs map[string]string, totSize string) (string, error) {
+	if gc.format == FormatBulk {
+		return "", ErrBulkFormatWithTemplate
 	}
 
-	payloadFilename := path.Join(gc.location, gc.bulkPayloadFilenameWithTemplate(templatePath))
-	f, err := gc.fs.OpenFile(payloadFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, corpusPerm)
+	totSizeInBytes, err := gc.parseTotSize(totSize)
 	if err != nil {
 		return "", err
 	}
 
-	template, err := os.ReadFile(templatePath)
+	template, err := gc.readTemplate(templatePath)
 	if err != nil {
 		return "", err
 	}
 
-	if len(template) == 0 {
-		return "", errors.New("you must provide a non empty template content")
+	if err := gc.validateConfig(flds); err != nil {
+		return "", err
 	}
 
-	ctx := context.Background()
-	flds, err := fields.LoadFieldsWithTemplate(ctx, fieldsDefinitionPath)
+	bulkPayloadFilename := gc.bulkPayloadFilenameWithTemplate(templatePath)
+	sink, err := gc.openSink(ctx, bulkPayloadFilename)
 	if err != nil {
 		return "", err
 	}
 
-	err = gc.eventsPayloadFromFields(template, flds, totSizeInBytes, nil, f)
-	if err != nil {
-		return "", err
+	payloadFilename := sink.Name()
+
+	templateType := "placeholder"
+	if gc.templateType == templateTypeGoText {
+		templateType = "gotext"
+	} else if gc.templateType == templateTypeStructured {
+		templateType = "structured"
 	}
 
-	if err := f.Close(); err != nil {
+	runArgs["template_path"] = templatePath
+	runArgs["template_type"] = templateType
+	runArgs["tot_size"] = totSize
+	summary := gc.newRunSummary(runArgs)
+
+	expected := gc.newExpectedResults()
+	cardinalities := gc.newFieldCardinalities()
+	err = gc.eventsPayloadFromFields(ctx, template, flds, totSizeInBytes, nil, sink, fieldsObservers(expected, cardinalities), &summary)
+	err = genlib.SetTemplateName(err, templatePath)
+	if closeErr := closeSink(sink, err); closeErr != nil && err == nil {
+		err = closeErr
+	}
+
+	if expected != nil && (err == nil || errors.Is(err, ErrInterrupted)) {
+		if writeErr := gc.writeExpectedResults(expected, bulkPayloadFilename); writeErr != nil {
+			return "", writeErr
+		}
+	}
+
+	if err != nil && !errors.Is(err, ErrInterrupted) {
 		return "", err
 	}
 
+	if endErr := gc.endRun(&summary, payloadFilename, err != nil, cardinalities); endErr != nil {
+		return payloadFilename, multierr.Append(err, endErr)
+	}
+
+	if gc.manifest {
+		if manifestErr := gc.writeManifest(bulkPayloadFilename, summary, flds, template); manifestErr != nil {
+			return payloadFilename, multierr.Append(err, manifestErr)
+		}
+	}
+
 	return payloadFilename, err
 }
 
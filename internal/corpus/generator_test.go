@@ -5,10 +5,35 @@
 package corpus
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sink"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFilename(t *testing.T) {
@@ -40,3 +65,2168 @@ func TestSanitizeFilename(t *testing.T) {
 		}
 	}
 }
+
+func TestGenerateWithTemplate_timeRange(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"@timestamp":"{{.timestamp}}","event.created":"{{.created}}"}`, `- name: timestamp
+  type: date
+- name: created
+  type: date
+`)
+
+	from := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(7 * 24 * time.Hour)
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "testdata", "placeholder", WithTimeRange(from, to))
+	require.NoError(t, err)
+
+	payloadFilename, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "10KB")
+	require.NoError(t, err)
+
+	f, err := fs.Open(payloadFilename)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var previous time.Time
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event map[string]string
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		require.Equal(t, event["@timestamp"], event["event.created"])
+
+		ts, err := time.Parse(time.RFC3339Nano, event["@timestamp"])
+		require.NoError(t, err)
+		require.False(t, ts.Before(from), "timestamp %s before time range", ts)
+		require.True(t, ts.Before(to), "timestamp %s after time range", ts)
+		require.False(t, ts.Before(previous), "timestamp %s not in order", ts)
+		previous = ts
+	}
+	require.NoError(t, scanner.Err())
+	require.True(t, previous.After(from.Add(6*24*time.Hour)), "events not spread across the time range")
+}
+
+func TestGenerateWithTemplate_interrupted(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}"}`, `- name: alpha
+  type: keyword
+`)
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "testdata", "placeholder")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	payloadFilename, err := fc.GenerateWithTemplate(ctx, templatePath, fieldsDefinitionPath, "10KB")
+	require.ErrorIs(t, err, ErrInterrupted)
+
+	exists, err := afero.Exists(fs, payloadFilename)
+	require.NoError(t, err)
+	require.True(t, exists, "partial corpus must be kept")
+}
+
+func TestGenerateWithTemplate_seed(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}","beta":{{.beta}}}`, `- name: alpha
+  type: keyword
+- name: beta
+  type: long
+`)
+
+	generate := func(seed int64) []byte {
+		fs := afero.NewMemMapFs()
+		fc, err := NewGeneratorWithTemplate(Config{}, fs, "testdata", "placeholder", WithSeed(seed))
+		require.NoError(t, err)
+
+		payloadFilename, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "10KB")
+		require.NoError(t, err)
+
+		content, err := afero.ReadFile(fs, payloadFilename)
+		require.NoError(t, err)
+		return content
+	}
+
+	require.Equal(t, generate(42), generate(42))
+	require.NotEqual(t, generate(42), generate(43))
+}
+
+func TestGenerateWithTemplate_telemetry(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}"}`, `- name: alpha
+  type: keyword
+`)
+
+	var summary RunSummary
+	var requestPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestPath = r.URL.Path
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&summary))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "testdata", "placeholder", WithSeed(42), WithTelemetry(server.URL, "telemetry"))
+	require.NoError(t, err)
+
+	payloadFilename, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "10KB")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, payloadFilename)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/telemetry/_doc", requestPath)
+	assert.Equal(t, int64(42), summary.Seed)
+	assert.Equal(t, payloadFilename, summary.Filename)
+	assert.Equal(t, uint64(len(content)), summary.Bytes)
+	assert.Equal(t, uint64(bytes.Count(content, []byte("\n"))), summary.Events)
+	assert.Equal(t, "10KB", summary.Params["tot_size"])
+	assert.False(t, summary.Interrupted)
+}
+
+func TestGenerateWithTemplate_fieldsTracing(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}"}`, `- name: alpha
+  type: keyword
+`)
+
+	var traces bytes.Buffer
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "testdata", "placeholder", WithFieldsTracing(10, &traces))
+	require.NoError(t, err)
+
+	payloadFilename, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "10KB")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, payloadFilename)
+	require.NoError(t, err)
+	events := bytes.Split(bytes.TrimSpace(content), []byte("\n"))
+
+	scanner := bufio.NewScanner(&traces)
+	var n int
+	for ; scanner.Scan(); n++ {
+		var trace fieldsTrace
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &trace))
+		require.Equal(t, uint64(n*10), trace.Event)
+		require.Len(t, trace.Fields, 1)
+		assert.Equal(t, "keyword", trace.Fields[0].Emitter)
+		assert.Equal(t, string(events[trace.Event]), `{"alpha":"`+trace.Fields[0].Value+`"}`)
+	}
+
+	assert.Equal(t, (len(events)+9)/10, n)
+}
+
+func TestGenerateWithTemplate_strict(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}"}`, `- name: alpha
+  type: keyword
+`)
+
+	_, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enumm: [\"a\"]"), config.WithStrict())
+	require.ErrorContains(t, err, "line 2: field alpha: unknown config key enumm")
+
+	_, err = config.LoadConfigFromYaml([]byte("- name: alpha\n  rules:\n    - when: {field: beta, equal: b}"), config.WithStrict())
+	require.ErrorContains(t, err, "line 3: field alpha: unknown config key rules.0.when.equal")
+
+	_, err = config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"a\"]\n- name: beta\n  range: {min: 1}"))
+	require.ErrorContains(t, err, "line 4: ")
+
+	_, err = config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"a\"]\n- name: beta\n  fuzziness: -1"))
+	require.EqualError(t, err, "line 3: field beta: range and fuzziness must be positive")
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"a\"]\n- name: alhpa\n  enum: [\"b\"]"), config.WithStrict())
+	require.NoError(t, err)
+
+	fc, err := NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "testdata", "placeholder")
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "1KB")
+	require.NoError(t, err)
+
+	fc, err = NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "testdata", "placeholder", WithStrict())
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "1KB")
+	require.ErrorContains(t, err, "line 3: field alhpa: not found in the fields definition")
+}
+
+func TestGenerateWithTemplate_warnings(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}"}`, `- name: alpha
+  type: keyword
+`)
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  range: 10"))
+	require.NoError(t, err)
+
+	var warnings bytes.Buffer
+	fc, err := NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "testdata", "placeholder", WithWarnings(&warnings))
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "1KB")
+	require.NoError(t, err)
+	assert.Equal(t, "warning: line 1: field alpha: range ignored for type keyword, it applies to numeric types only\n", warnings.String())
+
+	fc, err = NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "testdata", "placeholder", WithStrict())
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "1KB")
+	require.ErrorContains(t, err, "field alpha: range ignored for type keyword")
+}
+
+func TestGenerateWithTemplate_sink(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}"}`, `- name: alpha
+  type: keyword
+`)
+
+	var out bytes.Buffer
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "testdata", "placeholder", WithSink(sink.NewWriter("-", &out)))
+	require.NoError(t, err)
+
+	payloadFilename, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "10KB")
+	require.NoError(t, err)
+	assert.Equal(t, "-", payloadFilename)
+	assert.GreaterOrEqual(t, out.Len(), 10*1000)
+
+	exists, err := afero.DirExists(fs, "testdata")
+	require.NoError(t, err)
+	assert.False(t, exists, "no file must be written")
+}
+
+func TestGenerateWithTemplate_gzip(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}"}`, `- name: alpha
+  type: keyword
+`)
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "testdata", "placeholder", WithGzip(), WithEvents(3))
+	require.NoError(t, err)
+
+	payloadFilename, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "")
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(payloadFilename, "-template.ndjson.gz"), payloadFilename)
+
+	f, err := fs.Open(payloadFilename)
+	require.NoError(t, err)
+
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	content, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(content)), "\n"), 3)
+}
+
+func TestGenerateWithTemplate_middlewares(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}"}`, `- name: alpha
+  type: keyword
+`)
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "testdata", "placeholder", WithMiddlewares(func(doc []byte) ([]byte, error) {
+		return []byte(`{"beta":true}`), nil
+	}))
+	require.NoError(t, err)
+
+	payloadFilename, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "1KB")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, payloadFilename)
+	require.NoError(t, err)
+	for _, event := range bytes.Split(bytes.TrimSpace(content), []byte("\n")) {
+		assert.Equal(t, `{"beta":true}`, string(event))
+	}
+}
+
+func TestGenerateWithTemplate_rate(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}"}`, `- name: alpha
+  type: keyword
+`)
+
+	var out bytes.Buffer
+	fc, err := NewGeneratorWithTemplate(Config{}, afero.NewMemMapFs(), "testdata", "placeholder", WithSink(sink.NewWriter("-", &out)), WithRate(200, 100*time.Millisecond))
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	assert.Equal(t, 20, bytes.Count(out.Bytes(), []byte("\n")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	fc, err = NewGeneratorWithTemplate(Config{}, afero.NewMemMapFs(), "testdata", "placeholder", WithRate(1000, 0))
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(ctx, templatePath, fieldsDefinitionPath, "")
+	require.NoError(t, err, "live generation without a size ends when the context is done")
+
+	fc, err = NewGeneratorWithTemplate(Config{}, afero.NewMemMapFs(), "testdata", "placeholder")
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(ctx, templatePath, fieldsDefinitionPath, "")
+	require.Error(t, err)
+}
+
+func TestGenerateWithTemplate_expectedResults(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"host":"{{.host}}","bytes":{{.bytes}},"user":"{{.user}}"}`, `- name: host
+  type: keyword
+- name: bytes
+  type: long
+- name: user
+  type: keyword
+`)
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: host\n  enum: [\"a\", \"b\", \"c\"]\n- name: bytes\n  range: 1000\n- name: user\n  cardinality: 7"))
+	require.NoError(t, err)
+
+	aggregations := make([]ExpectedAggregation, 0, 3)
+	for _, s := range []string{"terms:host", "sum:bytes", "cardinality:user"} {
+		aggregation, err := ParseExpectedAggregation(s)
+		require.NoError(t, err)
+		aggregations = append(aggregations, aggregation)
+	}
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(cfg, fs, "testdata", "placeholder", WithExpectedResults(aggregations...))
+	require.NoError(t, err)
+
+	payloadFilename, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "10KB")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, payloadFilename)
+	require.NoError(t, err)
+
+	var events uint64
+	hosts := make(map[string]uint64)
+	users := make(map[string]struct{})
+	var sum float64
+	for _, line := range bytes.Split(bytes.TrimSpace(content), []byte("\n")) {
+		var event struct {
+			Host  string  `json:"host"`
+			Bytes float64 `json:"bytes"`
+			User  string  `json:"user"`
+		}
+
+		require.NoError(t, json.Unmarshal(line, &event))
+		events++
+		hosts[event.Host]++
+		users[event.User] = struct{}{}
+		sum += event.Bytes
+	}
+
+	content, err = afero.ReadFile(fs, ExpectedResultsFilename(payloadFilename))
+	require.NoError(t, err)
+
+	var expected ExpectedResults
+	require.NoError(t, json.Unmarshal(content, &expected))
+	assert.Equal(t, events, expected.Events)
+	assert.Equal(t, hosts, expected.Aggregations["terms:host"].Buckets)
+	assert.Equal(t, sum, *expected.Aggregations["sum:bytes"].Value)
+	assert.Equal(t, float64(len(users)), *expected.Aggregations["cardinality:user"].Value)
+
+	_, err = ParseExpectedAggregation("avg:bytes")
+	require.ErrorContains(t, err, "type must be one of terms, sum and cardinality")
+}
+
+func TestGenerateWithTemplate_expectedResultsDuplicates(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"host":"{{.host}}"}`, `- name: host
+  type: keyword
+`)
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: host\n  cardinality: 50"))
+	require.NoError(t, err)
+
+	aggregation, err := ParseExpectedAggregation("terms:host")
+	require.NoError(t, err)
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(cfg, fs, "testdata", "placeholder", WithExpectedResults(aggregation), WithDuplicates(Duplicates{Percentage: 30}), WithSeed(42))
+	require.NoError(t, err)
+
+	payloadFilename, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "10KB")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, payloadFilename)
+	require.NoError(t, err)
+
+	// the duplicated events are new documents, accounted for as the events they duplicate
+	var events uint64
+	hosts := make(map[string]uint64)
+	for _, line := range bytes.Split(bytes.TrimSpace(content), []byte("\n")) {
+		var event struct {
+			Host string `json:"host"`
+		}
+
+		require.NoError(t, json.Unmarshal(line, &event))
+		events++
+		hosts[event.Host]++
+	}
+
+	content, err = afero.ReadFile(fs, ExpectedResultsFilename(payloadFilename))
+	require.NoError(t, err)
+
+	var expected ExpectedResults
+	require.NoError(t, json.Unmarshal(content, &expected))
+	assert.Equal(t, events, expected.Events)
+	assert.Equal(t, hosts, expected.Aggregations["terms:host"].Buckets)
+}
+
+func TestGenerateWithTemplate_expectedResultsSparse(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"host":"{{.host}}","bytes":{{.bytes}}}`, `- name: host
+  type: keyword
+- name: bytes
+  type: long
+`)
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: host\n  enum: [\"a\", \"b\"]\n  null_percentage: 30\n  omit_percentage: 30\n- name: bytes\n  range: 1000\n  omit_percentage: 50"))
+	require.NoError(t, err)
+
+	aggregations := make([]ExpectedAggregation, 0, 2)
+	for _, s := range []string{"terms:host", "sum:bytes"} {
+		aggregation, err := ParseExpectedAggregation(s)
+		require.NoError(t, err)
+		aggregations = append(aggregations, aggregation)
+	}
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(cfg, fs, "testdata", "placeholder", WithExpectedResults(aggregations...))
+	require.NoError(t, err)
+
+	payloadFilename, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "10KB")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, payloadFilename)
+	require.NoError(t, err)
+
+	var events uint64
+	hosts := make(map[string]uint64)
+	var sum float64
+	for _, line := range bytes.Split(bytes.TrimSpace(content), []byte("\n")) {
+		var event struct {
+			Host  *string  `json:"host"`
+			Bytes *float64 `json:"bytes"`
+		}
+
+		require.NoError(t, json.Unmarshal(line, &event))
+		events++
+		if event.Host != nil {
+			hosts[*event.Host]++
+		}
+
+		if event.Bytes != nil {
+			sum += *event.Bytes
+		}
+	}
+
+	content, err = afero.ReadFile(fs, ExpectedResultsFilename(payloadFilename))
+	require.NoError(t, err)
+
+	var expected ExpectedResults
+	require.NoError(t, json.Unmarshal(content, &expected))
+	assert.Equal(t, events, expected.Events)
+	assert.Equal(t, hosts, expected.Aggregations["terms:host"].Buckets)
+	assert.Equal(t, sum, *expected.Aggregations["sum:bytes"].Value)
+	assert.Less(t, uint64(0), events-hosts["a"]-hosts["b"])
+}
+
+func TestGenerateWithTemplate_lumberjack(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}"}`, `- name: alpha
+  type: keyword
+`)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan []string, 1)
+	go func() {
+		events, _ := serveLumberjack(listener)
+		received <- events
+	}()
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "testdata", "placeholder", WithSink(sink.NewLumberjack(listener.Addr().String(), 10, time.Second)))
+	require.NoError(t, err)
+
+	payloadFilename, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "1KB")
+	require.NoError(t, err)
+	assert.Equal(t, "lumberjack://"+listener.Addr().String(), payloadFilename)
+
+	// the last partial window is shipped on close
+	var size int
+	events := <-received
+	for _, event := range events {
+		size += len(event)
+		var doc map[string]string
+		require.NoError(t, json.Unmarshal([]byte(event), &doc))
+		assert.Contains(t, doc, "alpha")
+	}
+
+	assert.GreaterOrEqual(t, size, 1000)
+	assert.Less(t, size-len(events[len(events)-1]), 1000)
+
+	// the next corpus is shipped on a new connection
+	go func() {
+		events, _ := serveLumberjack(listener)
+		received <- events
+	}()
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "1KB")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, len(<-received), len(events)-1)
+
+	_, err = sink.ParseLumberjackAddress("lumberjack://localhost")
+	require.ErrorContains(t, err, "must be in the lumberjack://host:port form")
+}
+
+func TestLumberjack_reconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// the first connection is closed without acking the window
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			_ = conn.Close()
+		}
+	}()
+
+	s := sink.NewLumberjack(listener.Addr().String(), 10, time.Second)
+	require.NoError(t, s.Open(context.Background(), "corpus"))
+	_, err = s.Write([]byte(`{"alpha":"a"}` + "\n"))
+	require.NoError(t, err)
+	require.ErrorContains(t, s.Flush(), "cannot ship events to lumberjack input")
+
+	// the window is sent again on a new connection
+	received := make(chan []string, 1)
+	go func() {
+		events, _ := serveLumberjack(listener)
+		received <- events
+	}()
+
+	require.NoError(t, s.Close())
+	assert.Equal(t, []string{`{"alpha":"a"}`}, <-received)
+}
+
+// serveLumberjack accepts a lumberjack v2 connection, acking the windows of compressed frames until the connection is closed.
+func serveLumberjack(listener net.Listener) ([]string, error) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	var events []string
+	r := bufio.NewReader(conn)
+	for {
+		header := make([]byte, 12)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return events, nil
+		}
+
+		count := binary.BigEndian.Uint32(header[2:6])
+		compressed := make([]byte, binary.BigEndian.Uint32(header[8:12]))
+		if _, err := io.ReadFull(r, compressed); err != nil {
+			return events, err
+		}
+
+		zr, err := zlib.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return events, err
+		}
+
+		frames, err := io.ReadAll(zr)
+		if err != nil {
+			return events, err
+		}
+
+		for len(frames) > 0 {
+			size := binary.BigEndian.Uint32(frames[6:10])
+			events = append(events, string(frames[10:10+size]))
+			frames = frames[10+size:]
+		}
+
+		ack := []byte{'2', 'A', 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(ack[2:], count)
+		if _, err := conn.Write(ack); err != nil {
+			return events, err
+		}
+	}
+}
+
+func TestGenerateWithTemplate_split(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}"}`, `- name: alpha
+  type: keyword
+`)
+
+	// batches smaller than the chunks and than the events do not split the events across chunks
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "testdata", "placeholder", WithSplit(1000, 0), WithWriteBatchSize(16))
+	require.NoError(t, err)
+
+	payloadFilename, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "10KB")
+	require.NoError(t, err)
+
+	chunks, err := afero.Glob(fs, ChunksPattern(payloadFilename))
+	require.NoError(t, err)
+	require.Greater(t, len(chunks), 10)
+	assert.Equal(t, chunkFilename(payloadFilename, 1), chunks[0])
+
+	var size int
+	for _, chunk := range chunks {
+		content, err := afero.ReadFile(fs, chunk)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(content), 1000)
+		assert.True(t, bytes.HasSuffix(content, []byte("\n")), "chunks must end with a complete event")
+		size += len(content)
+	}
+
+	assert.GreaterOrEqual(t, size, 10*1000)
+
+	fs = afero.NewMemMapFs()
+	fc, err = NewGeneratorWithTemplate(Config{}, fs, "testdata", "placeholder", WithSplit(0, 7))
+	require.NoError(t, err)
+
+	payloadFilename, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "1KB")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, chunkFilename(payloadFilename, 1))
+	require.NoError(t, err)
+	assert.Equal(t, 7, bytes.Count(content, []byte("\n")))
+}
+
+func TestGenerateWithTemplate_includeDir(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}",{{ template "host" }}}`, `- name: alpha
+  type: keyword
+- name: host.name
+  type: keyword
+`)
+
+	includeDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(includeDir, "host.json"), []byte(`"host":{"name":"{{.host.name}}"}`+"\n"), 0644))
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "testdata", "placeholder", WithIncludeDir(includeDir))
+	require.NoError(t, err)
+
+	payloadFilename, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "1KB")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, payloadFilename)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	for _, line := range lines {
+		var event struct {
+			Alpha string `json:"alpha"`
+			Host  struct {
+				Name string `json:"name"`
+			} `json:"host"`
+		}
+
+		require.NoError(t, json.Unmarshal([]byte(line), &event), line)
+		assert.NotEmpty(t, event.Host.Name, "the fields of the partial are generated")
+	}
+
+	issues, err := fc.ValidateTemplate(context.Background(), templatePath, fieldsDefinitionPath)
+	require.NoError(t, err)
+	assert.Empty(t, issues, "the fields referenced by the partial are not unused")
+}
+
+// abortableSink records how the sink it wraps is opened, closed and aborted.
+type abortableSink struct {
+	Sink
+	opened, closed, aborted bool
+}
+
+func (s *abortableSink) Open(ctx context.Context, name string) error {
+	s.opened = true
+	return s.Sink.Open(ctx, name)
+}
+
+func (s *abortableSink) Close() error {
+	s.closed = true
+	return s.Sink.Close()
+}
+
+func (s *abortableSink) Abort() error {
+	s.aborted = true
+	return nil
+}
+
+func TestGenerateWithTemplate_sinkAborted(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{index "ab" 5}}"}`, `- name: alpha
+  type: keyword
+`)
+
+	generate := func(templatePath string) (*abortableSink, error) {
+		s := &abortableSink{Sink: sink.NewWriter("-", io.Discard)}
+		fc, err := NewGeneratorWithTemplate(Config{}, afero.NewMemMapFs(), "testdata", "gotext", WithSink(s))
+		require.NoError(t, err)
+
+		_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "1KB")
+		return s, err
+	}
+
+	// the sink is not opened when the template cannot be read
+	s, err := generate(templatePath + ".missing")
+	require.Error(t, err)
+	assert.False(t, s.opened)
+
+	// the sink is aborted, not closed, when the generation fails
+	s, err = generate(templatePath)
+	require.ErrorContains(t, err, "index out of range")
+	assert.True(t, s.opened)
+	assert.True(t, s.aborted)
+	assert.False(t, s.closed)
+}
+
+func TestGenerateWithTemplate_stats(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}","beta":"{{.beta}}"}`, `- name: alpha
+  type: keyword
+- name: beta
+  type: keyword
+`)
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  cardinality:\n    distinct: 5\n- name: beta\n  enum: [\"a\"]"))
+	require.NoError(t, err)
+
+	var progress bytes.Buffer
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(cfg, fs, "testdata", "placeholder", WithStats("stats.json"), WithProgress(&progress, time.Nanosecond))
+	require.NoError(t, err)
+
+	payloadFilename, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "10KB")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, "stats.json")
+	require.NoError(t, err)
+
+	var stats RunStats
+	require.NoError(t, json.Unmarshal(content, &stats))
+	assert.Equal(t, payloadFilename, stats.Filename)
+	assert.NotZero(t, stats.Events)
+	assert.Equal(t, map[string]FieldStats{"alpha": {Cardinality: 5}, "beta": {Cardinality: 1}}, stats.Fields)
+
+	lines := strings.Split(strings.TrimSpace(progress.String()), "\n")
+	require.Greater(t, len(lines), 1)
+	assert.True(t, strings.HasPrefix(lines[0], "progress: "))
+	assert.True(t, strings.HasSuffix(lines[0], "%"))
+	assert.True(t, strings.HasPrefix(lines[len(lines)-1], "progress: done, "))
+}
+
+func TestGenerateWithTemplate_statsSparse(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}","beta":"{{.beta}}"}`, `- name: alpha
+  type: keyword
+- name: beta
+  type: keyword
+`)
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  cardinality:\n    distinct: 5\n  null_percentage: 30\n  omit_percentage: 30\n- name: beta\n  enum: [\"a\"]\n  null_percentage: 50"))
+	require.NoError(t, err)
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(cfg, fs, "testdata", "placeholder", WithStats("stats.json"))
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "10KB")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, "stats.json")
+	require.NoError(t, err)
+
+	var stats RunStats
+	require.NoError(t, json.Unmarshal(content, &stats))
+	assert.Equal(t, map[string]FieldStats{"alpha": {Cardinality: 5}, "beta": {Cardinality: 1}}, stats.Fields)
+}
+
+func TestGenerateWithTemplate_audit(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}","timestamp":"{{.timestamp}}"}`, `- name: alpha
+  type: keyword
+- name: timestamp
+  type: date
+`)
+
+	generate := func(opts ...GeneratorCorpusOption) AuditRecord {
+		fs := afero.NewMemMapFs()
+		opts = append(opts, WithAudit("audit.json", []string{"generate-with-template", "--", templatePath, fieldsDefinitionPath}, []string{templatePath, fieldsDefinitionPath}))
+		fc, err := NewGeneratorWithTemplate(Config{}, fs, "testdata", "placeholder", opts...)
+		require.NoError(t, err)
+
+		_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "10KB")
+		require.NoError(t, err)
+
+		content, err := afero.ReadFile(fs, "audit.json")
+		require.NoError(t, err)
+
+		var record AuditRecord
+		require.NoError(t, json.Unmarshal(content, &record))
+		return record
+	}
+
+	record := generate(WithSeed(42))
+	require.Equal(t, int64(42), record.Seed)
+	require.False(t, record.ReferenceTime.IsZero())
+	require.Len(t, record.Inputs, 2)
+	require.Len(t, record.Corpora, 1)
+	require.NotEmpty(t, record.Corpora[0].SHA256)
+	require.NoError(t, record.Verify())
+
+	// the date fields are relative to the recorded reference time, the corpus is the same whenever it is rerun
+	rerun := generate(WithSeed(record.Seed), WithReferenceTime(record.ReferenceTime))
+	require.NoError(t, record.Compare(rerun))
+
+	rerun = generate(WithSeed(43), WithReferenceTime(record.ReferenceTime))
+	require.Error(t, record.Compare(rerun))
+
+	require.NoError(t, os.WriteFile(templatePath, []byte(`{"alpha":"{{.alpha}}"}`), 0644))
+	require.ErrorContains(t, record.Verify(), "changed since the run")
+}
+
+func TestGenerateWithTemplate_manifest(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}"}`, `- name: alpha
+  type: keyword
+`)
+
+	configContent := []byte("- name: alpha\n  enum: [\"a\", \"b\"]\n")
+	cfg, err := config.LoadConfigFromYaml(configContent)
+	require.NoError(t, err)
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(cfg, fs, "testdata", "placeholder", WithSeed(42), WithEvents(10), WithManifest(configContent))
+	require.NoError(t, err)
+
+	payloadFilename, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, ManifestFilename(payloadFilename))
+	require.NoError(t, err)
+
+	var manifest Manifest
+	require.NoError(t, json.Unmarshal(content, &manifest))
+	assert.Equal(t, payloadFilename, manifest.Filename)
+	assert.Equal(t, string(configContent), manifest.Config)
+	assert.Equal(t, int64(42), manifest.Seed)
+	assert.Equal(t, uint64(10), manifest.Events)
+	assert.NotZero(t, manifest.Bytes)
+	assert.Equal(t, templatePath, manifest.Params["template_path"])
+	assert.Len(t, manifest.FieldsSHA256, 64)
+
+	template, err := os.ReadFile(templatePath)
+	require.NoError(t, err)
+	templateSum := sha256.Sum256(template)
+	assert.Equal(t, hex.EncodeToString(templateSum[:]), manifest.TemplateSHA256)
+}
+
+func TestGenerateWithTemplate_events(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}"}`, `- name: alpha
+  type: keyword
+`)
+
+	var out bytes.Buffer
+	fc, err := NewGeneratorWithTemplate(Config{}, afero.NewMemMapFs(), "testdata", "placeholder", WithSink(sink.NewWriter("-", &out)), WithEvents(3))
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "")
+	require.NoError(t, err)
+	assert.Equal(t, 3, bytes.Count(out.Bytes(), []byte("\n")))
+
+	out.Reset()
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "10B")
+	require.NoError(t, err)
+	assert.Equal(t, 1, bytes.Count(out.Bytes(), []byte("\n")), "the total size is reached first")
+}
+
+func TestGenerateWithTemplate_quotas(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"event.action":"{{.event.action}}"}`, `- name: event.action
+  type: keyword
+`)
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: event.action\n  enum: [\"logon\", \"logon-failed\"]\n  quotas:\n    logon-failed: 25"))
+	require.NoError(t, err)
+
+	fc, err := NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "testdata", "placeholder")
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "1KB")
+	require.EqualError(t, err, "line 1: field event.action: quotas require the count of events to generate")
+
+	var out bytes.Buffer
+	fc, err = NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "testdata", "placeholder", WithSink(sink.NewWriter("-", &out)), WithEvents(200))
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "")
+	require.NoError(t, err)
+	assert.Equal(t, 200, bytes.Count(out.Bytes(), []byte("\n")))
+	assert.Equal(t, 25, bytes.Count(out.Bytes(), []byte("logon-failed")))
+
+	fc, err = NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "testdata", "placeholder", WithEvents(200), WithMiddlewares(func(doc []byte) ([]byte, error) {
+		return doc, nil
+	}))
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "")
+	require.EqualError(t, err, "the quotas of the values of enum cannot be met when middlewares drop events")
+}
+
+func TestGenerateWithTemplate_relativeCardinality(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}"}`, `- name: alpha
+  type: keyword
+`)
+
+	distinct := func(out []byte) int {
+		values := make(map[string]struct{})
+		for _, line := range bytes.Split(bytes.TrimSpace(out), []byte("\n")) {
+			values[string(line)] = struct{}{}
+		}
+
+		return len(values)
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  cardinality:\n    per_million_events: 10000"))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	fc, err := NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "testdata", "placeholder", WithSink(sink.NewWriter("-", &out)), WithEvents(1000))
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "")
+	require.NoError(t, err)
+	assert.Equal(t, 10, distinct(out.Bytes()))
+
+	cfg, err = config.LoadConfigFromYaml([]byte("- name: alpha\n  cardinality:\n    per_gb: 1000000"))
+	require.NoError(t, err)
+
+	out.Reset()
+	fc, err = NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "testdata", "placeholder", WithSink(sink.NewWriter("-", &out)))
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "20KB")
+	require.NoError(t, err)
+	assert.Equal(t, 20, distinct(out.Bytes()))
+
+	fc, err = NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "testdata", "placeholder", WithEvents(1000))
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "")
+	require.EqualError(t, err, "line 1: field alpha: cardinality per_gb requires the total size of the corpus")
+}
+
+func TestGenerateWithTemplate_sequences(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"@timestamp":"{{.@timestamp}}","event.action":"{{.event.action}}","process.pid":{{.process.pid}}}`, `- name: '@timestamp'
+  type: date
+- name: event.action
+  type: keyword
+- name: process.pid
+  type: long
+`)
+
+	sequences := []genlib.Sequence{{
+		Name:       "process",
+		Steps:      []genlib.SequenceStep{{Fields: map[string]interface{}{"event.action": "start"}}, {Fields: map[string]interface{}{"event.action": "end"}}},
+		Correlate:  []string{"process.pid"},
+		Percentage: 100,
+	}}
+
+	// the pids of the sequences are distinct
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: process.pid\n  range: 1000000000"))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	fc, err := NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "testdata", "placeholder", WithSink(sink.NewWriter("-", &out)), WithEvents(100), WithSequences(sequences...))
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "")
+	require.NoError(t, err)
+
+	started := make(map[json.Number]time.Time)
+	decoder := json.NewDecoder(&out)
+	decoder.UseNumber()
+	for decoder.More() {
+		var event struct {
+			Timestamp time.Time   `json:"@timestamp"`
+			Action    string      `json:"event.action"`
+			PID       json.Number `json:"process.pid"`
+		}
+
+		require.NoError(t, decoder.Decode(&event))
+		switch event.Action {
+		case "start":
+			started[event.PID] = event.Timestamp
+		case "end":
+			require.Contains(t, started, event.PID, "the end of a sequence has the pid of its start")
+			assert.True(t, event.Timestamp.After(started[event.PID]), "the end of a sequence follows its start")
+		default:
+			t.Errorf("expected every event to be a step of the sequence, got action %s", event.Action)
+		}
+	}
+
+	cfg, err = config.LoadConfigFromYaml([]byte("- name: event.action\n  enum: [\"start\"]\n  quotas:\n    start: 10"))
+	require.NoError(t, err)
+
+	fc, err = NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "testdata", "placeholder", WithEvents(100), WithSequences(sequences...))
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "")
+	require.EqualError(t, err, "the quotas of the values of enum cannot be met when sequences rewrite events")
+}
+
+func TestGenerateWithTemplate_resume(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}","beta":{{.beta}}}`, `- name: alpha
+  type: keyword
+- name: beta
+  type: long
+`)
+
+	location := t.TempDir()
+	fc, err := NewGeneratorWithTemplate(Config{}, afero.NewOsFs(), location, "placeholder", WithSeed(42), WithEvents(100), WithFilename("complete.ndjson"))
+	require.NoError(t, err)
+
+	completePath, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "")
+	require.NoError(t, err)
+	require.NoFileExists(t, completePath+checkpointExtension, "the checkpoint of a complete corpus is removed")
+
+	// the generation is interrupted after 40 events
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var generated int
+	fc, err = NewGeneratorWithTemplate(Config{}, afero.NewOsFs(), location, "placeholder", WithSeed(42), WithEvents(100), WithFilename("resumed.ndjson"), WithMiddlewares(func(doc []byte) ([]byte, error) {
+		if generated += 1; generated == 40 {
+			cancel()
+		}
+
+		return doc, nil
+	}))
+	require.NoError(t, err)
+
+	resumedPath, err := fc.GenerateWithTemplate(ctx, templatePath, fieldsDefinitionPath, "")
+	require.ErrorIs(t, err, ErrInterrupted)
+
+	checkpoint, err := LoadCheckpoint(resumedPath)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), checkpoint.Seed)
+	assert.Equal(t, uint64(40), checkpoint.Events)
+
+	// an event written after the checkpoint is cut
+	file, err := os.OpenFile(resumedPath, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"alpha":"half`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	// interrupted again while generating the events already in the corpus, the checkpoint is left as it is
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	generated = 0
+	fc, err = NewGeneratorWithTemplate(Config{}, afero.NewOsFs(), "elsewhere", "placeholder", WithEvents(100), WithResume(resumedPath, checkpoint), WithMiddlewares(func(doc []byte) ([]byte, error) {
+		if generated += 1; generated == 20 {
+			cancel()
+		}
+
+		return doc, nil
+	}))
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(ctx, templatePath, fieldsDefinitionPath, "")
+	require.ErrorIs(t, err, ErrInterrupted)
+
+	interrupted, err := LoadCheckpoint(resumedPath)
+	require.NoError(t, err)
+	assert.Equal(t, checkpoint, interrupted)
+
+	fc, err = NewGeneratorWithTemplate(Config{}, afero.NewOsFs(), "elsewhere", "placeholder", WithEvents(100), WithResume(resumedPath, checkpoint))
+	require.NoError(t, err)
+
+	path, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "")
+	require.NoError(t, err)
+	assert.Equal(t, resumedPath, path)
+
+	complete, err := os.ReadFile(completePath)
+	require.NoError(t, err)
+	resumed, err := os.ReadFile(resumedPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(complete), string(resumed), "the resumed corpus is the one of an uninterrupted run")
+	assert.NoFileExists(t, resumedPath+checkpointExtension)
+
+	_, err = LoadCheckpoint(resumedPath)
+	assert.Error(t, err)
+
+	fc, err = NewGeneratorWithTemplate(Config{}, afero.NewOsFs(), location, "placeholder", WithEvents(100), WithGzip(), WithResume(resumedPath, checkpoint))
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "")
+	assert.EqualError(t, err, "only the corpus files, neither split nor compressed, generated without rate, soak and audit, can be resumed")
+}
+
+func TestGenerateWithTemplate_soak(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}","beta":{{.beta}}}`, `- name: alpha
+  type: keyword
+- name: beta
+  type: long
+`)
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  cardinality:\n    distinct: 100"))
+	require.NoError(t, err)
+
+	var soak bytes.Buffer
+	fc, err := NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "testdata", "placeholder", WithSink(sink.NewWriter("-", io.Discard)),
+		WithSoak(&soak, SoakOptions{Duration: 300 * time.Millisecond, Interval: 20 * time.Millisecond, Warmup: 50 * time.Millisecond, MaxGrowth: 0.5}))
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+
+	lines := strings.Split(strings.TrimSpace(soak.String()), "\n")
+	require.Greater(t, len(lines), 3)
+	assert.Contains(t, lines[0], "warming up")
+	assert.Contains(t, soak.String(), "baseline heap")
+	assert.True(t, strings.HasPrefix(lines[len(lines)-1], "soak: ended after "))
+
+	assert.False(t, exceedsGrowth(1<<20, 4<<20, 0.5), "growth within the slack")
+	assert.True(t, exceedsGrowth(64<<20, 128<<20, 0.5))
+	assert.False(t, exceedsGrowth(64<<20, 90<<20, 0.5))
+}
+
+func TestGenerateWithTemplate_soakTimeRange(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"@timestamp":"{{.timestamp}}"}`, `- name: timestamp
+  type: date
+`)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	// without a total size nor an events count the events are spread across the time range through the soak run
+	var out bytes.Buffer
+	fc, err := NewGeneratorWithTemplate(Config{}, afero.NewMemMapFs(), "testdata", "placeholder", WithSink(sink.NewWriter("-", &out)), WithTimeRange(from, to),
+		WithSoak(io.Discard, SoakOptions{Duration: 100 * time.Millisecond, Interval: 20 * time.Millisecond, MaxGrowth: 100}))
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "")
+	require.NoError(t, err)
+
+	var previous time.Time
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var event map[string]string
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		ts, err := time.Parse(time.RFC3339Nano, event["@timestamp"])
+		require.NoError(t, err)
+		require.False(t, ts.Before(from), "timestamp %s before time range", ts)
+		require.False(t, ts.After(to), "timestamp %s after time range", ts)
+		require.False(t, ts.Before(previous), "timestamp %s not in order", ts)
+		previous = ts
+	}
+
+	require.True(t, previous.After(from.Add(12*time.Hour)), "events not spread across the time range")
+}
+
+func TestGenerate_idStrategy(t *testing.T) {
+	flds := Fields{{Name: "host.name", Type: "keyword"}, {Name: "bytes", Type: "long"}}
+	createPayload := []byte(`{ "create" : { "_index": "metrics-aws.ec2-default" } }` + "\n")
+
+	generate := func(strategy string, seed int64) [][]byte {
+		idStrategy, err := ParseIDStrategy(strategy)
+		require.NoError(t, err)
+
+		var out bytes.Buffer
+		fc, err := NewGenerator(Config{}, afero.NewMemMapFs(), "testdata", WithIDStrategy(idStrategy), WithSeed(seed), WithEvents(4))
+		require.NoError(t, err)
+
+		fc.timestamp = func() int64 { return 1647345675 }
+		ws := sink.NewWriter("-", &out)
+		require.NoError(t, fc.eventsPayloadFromFields(context.Background(), nil, flds, 0, createPayload, ws, nil, &RunSummary{}))
+		require.NoError(t, ws.Close())
+
+		lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		require.Len(t, lines, 8)
+
+		var ids [][]byte
+		for i := 0; i < len(lines); i += 2 {
+			var action map[string]map[string]string
+			require.NoError(t, json.Unmarshal(lines[i], &action))
+			assert.Equal(t, "metrics-aws.ec2-default", action["create"]["_index"])
+			ids = append(ids, []byte(action["create"]["_id"]))
+		}
+
+		return ids
+	}
+
+	ids := generate("timestamp-sequence", 42)
+	assert.Equal(t, [][]byte{[]byte("1647345675-0"), []byte("1647345675-1"), []byte("1647345675-2"), []byte("1647345675-3")}, ids)
+
+	ids = generate("uuid", 42)
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, string(ids[0]))
+	assert.NotEqual(t, ids[0], ids[1])
+	assert.Equal(t, ids, generate("uuid", 42), "the same seed gives the same ids")
+
+	ids = generate("hash:host.name,bytes", 42)
+	assert.Len(t, ids[0], 40)
+	assert.Equal(t, ids, generate("hash:host.name,bytes", 42), "the same events give the same ids")
+	assert.NotEqual(t, ids, generate("hash:host.name,bytes", 43))
+
+	_, err := ParseIDStrategy("hash")
+	require.ErrorContains(t, err, "must be in the hash:field,... form")
+	_, err = ParseIDStrategy("sequence")
+	require.ErrorContains(t, err, "must be one of uuid, hash:field,... and timestamp-sequence")
+}
+
+func TestGenerate_idDuplicates(t *testing.T) {
+	var out bytes.Buffer
+	strategy := IDStrategy{Type: IDStrategyUUID, DuplicatePercentage: 30}
+	fc, err := NewGenerator(Config{}, afero.NewMemMapFs(), "testdata", WithIDStrategy(strategy), WithSeed(42), WithEvents(200))
+	require.NoError(t, err)
+
+	ws := sink.NewWriter("-", &out)
+	summary := RunSummary{}
+	createPayload := []byte(`{ "create" : { "_index": "metrics-aws.ec2-default" } }` + "\n")
+	require.NoError(t, fc.eventsPayloadFromFields(context.Background(), nil, Fields{{Name: "bytes", Type: "long"}}, 0, createPayload, ws, nil, &summary))
+	require.NoError(t, ws.Close())
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 400)
+
+	ids := make(map[string]struct{})
+	for i := 0; i < len(lines); i += 2 {
+		var action map[string]map[string]string
+		require.NoError(t, json.Unmarshal(lines[i], &action))
+		ids[action["create"]["_id"]] = struct{}{}
+	}
+
+	// the UUIDs are unique, the only repeated ones are the duplicates
+	assert.Equal(t, uint64(200-len(ids)), summary.DuplicateIDs)
+	assert.InDelta(t, 60, summary.DuplicateIDs, 25)
+}
+
+func TestGenerate_duplicates(t *testing.T) {
+	generate := func(sameID bool) ([][]byte, RunSummary) {
+		var out bytes.Buffer
+		fc, err := NewGenerator(Config{}, afero.NewMemMapFs(), "testdata", WithIDStrategy(IDStrategy{Type: IDStrategyUUID}), WithDuplicates(Duplicates{Percentage: 30, SameID: sameID}), WithSeed(42), WithEvents(200))
+		require.NoError(t, err)
+
+		ws := sink.NewWriter("-", &out)
+		summary := RunSummary{}
+		createPayload := []byte(`{ "create" : { "_index": "metrics-aws.ec2-default" } }` + "\n")
+		require.NoError(t, fc.eventsPayloadFromFields(context.Background(), nil, Fields{{Name: "source.ip", Type: "ip"}}, 0, createPayload, ws, nil, &summary))
+		require.NoError(t, ws.Close())
+
+		lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		require.Len(t, lines, 400)
+		assert.InDelta(t, 60, summary.DuplicateEvents, 25)
+		return lines, summary
+	}
+
+	// the duplicated events keep their _id
+	lines, summary := generate(true)
+	ids := make(map[string]string)
+	for i := 0; i < len(lines); i += 2 {
+		if event, ok := ids[string(lines[i])]; ok {
+			assert.Equal(t, event, string(lines[i+1]))
+		}
+
+		ids[string(lines[i])] = string(lines[i+1])
+	}
+
+	assert.Equal(t, uint64(200-len(ids)), summary.DuplicateEvents)
+
+	// the duplicated events get a new _id
+	lines, summary = generate(false)
+	ids = make(map[string]string)
+	events := make(map[string]struct{})
+	for i := 0; i < len(lines); i += 2 {
+		ids[string(lines[i])] = string(lines[i+1])
+		events[string(lines[i+1])] = struct{}{}
+	}
+
+	assert.Len(t, ids, 200)
+	assert.Equal(t, uint64(200-len(events)), summary.DuplicateEvents)
+}
+
+func TestGenerate_bulkAction(t *testing.T) {
+	testCases := []struct {
+		action   BulkAction
+		expected string
+	}{
+		{
+			action:   BulkAction{},
+			expected: `{ "create" : { "_index": "metrics-aws.ec2-default" } }`,
+		},
+		{
+			action:   BulkAction{Type: "logs", Namespace: "prod", OpType: OpTypeCreate, Pipeline: "logs-aws.ec2@custom"},
+			expected: `{ "create" : { "_index": "logs-aws.ec2-prod", "pipeline": "logs-aws.ec2@custom" } }`,
+		},
+		{
+			action:   BulkAction{Index: "ec2-\"benchmark\"", Type: "logs", OpType: OpTypeIndex},
+			expected: `{ "index" : { "_index": "ec2-\"benchmark\"" } }`,
+		},
+	}
+
+	for _, tc := range testCases {
+		fc, err := NewGenerator(Config{}, afero.NewMemMapFs(), "testdata", WithBulkAction(tc.action))
+		require.NoError(t, err)
+
+		assert.Equal(t, tc.expected+"\n", string(fc.createPayload("aws", "ec2")))
+	}
+
+	var out bytes.Buffer
+	fc, err := NewGenerator(Config{}, afero.NewMemMapFs(), "testdata", WithBulkAction(BulkAction{Index: "ec2", OpType: OpTypeIndex, Pipeline: "ec2"}), WithIDStrategy(IDStrategy{Type: IDStrategyTimestampSequence}), WithEvents(1))
+	require.NoError(t, err)
+
+	fc.timestamp = func() int64 { return 1647345675 }
+	ws := sink.NewWriter("-", &out)
+	require.NoError(t, fc.eventsPayloadFromFields(context.Background(), nil, Fields{{Name: "bytes", Type: "long"}}, 0, fc.createPayload("aws", "ec2"), ws, nil, &RunSummary{}))
+	require.NoError(t, ws.Close())
+	assert.True(t, strings.HasPrefix(out.String(), `{"index":{"_id":"1647345675-0","_index":"ec2","pipeline":"ec2"}}`+"\n"), out.String())
+
+	require.ErrorContains(t, ValidateOpType("update"), "unknown op type update, must be either create or index")
+}
+
+func TestGenerate_namespaces(t *testing.T) {
+	var out bytes.Buffer
+	action := BulkAction{Type: "logs", Namespace: "prod", Namespaces: 3, NamespaceFields: []string{"data_stream.namespace"}}
+	fc, err := NewGenerator(Config{}, afero.NewMemMapFs(), "testdata", WithBulkAction(action), WithEvents(6))
+	require.NoError(t, err)
+
+	ws := sink.NewWriter("-", &out)
+	flds := Fields{{Name: "data_stream.namespace", Type: "constant_keyword"}, {Name: "bytes", Type: "long"}}
+	require.NoError(t, fc.eventsPayloadFromFields(context.Background(), nil, flds, 0, fc.createPayload("aws", "ec2"), ws, nil, &RunSummary{}))
+	require.NoError(t, ws.Close())
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 12)
+	for i := 0; i < len(lines); i += 2 {
+		namespace := "prod_" + strconv.Itoa(i/2%3+1)
+		var action map[string]map[string]string
+		require.NoError(t, json.Unmarshal([]byte(lines[i]), &action))
+		assert.Equal(t, "logs-aws.ec2-"+namespace, action["create"]["_index"])
+
+		var event struct {
+			DataStream struct {
+				Namespace string `json:"namespace"`
+			} `json:"data_stream"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(lines[i+1]), &event))
+		assert.Equal(t, namespace, event.DataStream.Namespace)
+	}
+
+	_, err = newNamespaceSpreader(BulkAction{Index: "ec2", Namespaces: 3}, "ec2")
+	require.ErrorContains(t, err, "the events cannot be spread across namespaces with a bulk index")
+}
+
+func TestGenerate_envelope(t *testing.T) {
+	var out bytes.Buffer
+	envelope := genlib.Envelope{Beat: "filebeat", Version: "8.15.0", DataStream: "logs-aws.ec2-default"}
+	fc, err := NewGenerator(Config{}, afero.NewMemMapFs(), "testdata", WithEnvelope(envelope), WithEvents(2))
+	require.NoError(t, err)
+
+	// the bulk request actions are dropped
+	ws := sink.NewWriter("-", &out)
+	require.NoError(t, fc.eventsPayloadFromFields(context.Background(), nil, Fields{{Name: "bytes", Type: "long"}}, 0, fc.createPayload("aws", "ec2"), ws, nil, &RunSummary{}))
+	require.NoError(t, ws.Close())
+
+	events := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, events, 2)
+	for _, event := range events {
+		assert.Regexp(t, `^\{"@metadata":\{"beat":"filebeat","raw_index":"logs-aws.ec2-default","type":"_doc","version":"8.15.0"\},"@timestamp":"[^"]+","bytes":-?[0-9]+,"data_stream":\{"dataset":"aws.ec2","namespace":"default","type":"logs"\}\}$`, event)
+	}
+
+	fc, err = NewGenerator(Config{}, afero.NewMemMapFs(), "testdata", WithBulkAction(BulkAction{Type: "logs", Namespace: "prod"}))
+	require.NoError(t, err)
+	assert.Equal(t, "logs-aws.ec2-prod", fc.bulkIndex("aws", "ec2"))
+}
+
+func TestGenerateWithTemplate_tsdb(t *testing.T) {
+	fieldsDefinition := `- name: host.name
+  type: keyword
+  dimension: true
+- name: labels.*
+  type: object
+  object_type: keyword
+  dimension: true
+- name: cpu.pct
+  type: double
+`
+
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"host.name":"{{.host.name}}","cpu.pct":{{.cpu.pct}}}`, fieldsDefinition)
+	fc, err := NewGeneratorWithTemplate(Config{}, afero.NewMemMapFs(), "testdata", "placeholder", WithTSDB())
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "1KB")
+	require.EqualError(t, err, "field labels.*: dimension not referenced by the template")
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: labels.*\n  object_keys: [\"env\"]\n- name: labels.env\n  omit_percentage: 10"))
+	require.NoError(t, err)
+
+	templatePath, fieldsDefinitionPath = writeTemplateAssets(t, `{"host.name":"{{.host.name}}","labels.env":"{{.labels.env}}","cpu.pct":{{.cpu.pct}}}`, fieldsDefinition)
+	fc, err = NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "testdata", "placeholder", WithTSDB())
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "1KB")
+	require.EqualError(t, err, "field labels.env: dimension cannot have null_percentage or omit_percentage")
+
+	cfg, err = config.LoadConfigFromYaml([]byte("- name: labels.*\n  object_keys: [\"env\"]"))
+	require.NoError(t, err)
+
+	fc, err = NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "testdata", "placeholder", WithTSDB())
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "1KB")
+	require.NoError(t, err)
+
+	// without a template the dimensions come first, after @timestamp, nested as objects
+	var out bytes.Buffer
+	fc, err = NewGenerator(Config{}, afero.NewMemMapFs(), "testdata", WithTSDB(), WithEvents(10))
+	require.NoError(t, err)
+
+	flds := Fields{{Name: "cpu.pct", Type: "double"}, {Name: "host.name", Type: "keyword", Dimension: true}, {Name: "labels.*", Type: "object", ObjectType: "keyword", Dimension: true}}
+	ws := sink.NewWriter("-", &out)
+	require.NoError(t, fc.eventsPayloadFromFields(context.Background(), nil, flds, 0, nil, ws, nil, &RunSummary{}))
+	require.NoError(t, ws.Close())
+
+	for _, event := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+		assert.Regexp(t, `^\{ "@timestamp": "[^"]+","host": \{ "name": "[^"]+" },"labels": \{ "[^"]+": "[^"]+".* },"cpu": \{ "pct": [0-9.]+ } }$`, string(event))
+	}
+
+	// the events belong to a bounded count of time series, whose counters never decrease
+	out.Reset()
+	fc, err = NewGenerator(Config{}, afero.NewMemMapFs(), "testdata", WithTSDB(), WithTSDBSeries(3), WithEvents(30))
+	require.NoError(t, err)
+
+	flds = Fields{{Name: "host.name", Type: "keyword", Dimension: true}, {Name: "network.in.bytes", Type: "long", MetricType: "counter"}}
+	ws = sink.NewWriter("-", &out)
+	require.NoError(t, fc.eventsPayloadFromFields(context.Background(), nil, flds, 0, nil, ws, nil, &RunSummary{}))
+	require.NoError(t, ws.Close())
+
+	counters := make(map[string]int64)
+	for _, event := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+		var sample struct {
+			Host struct {
+				Name string `json:"name"`
+			} `json:"host"`
+			Network struct {
+				In struct {
+					Bytes int64 `json:"bytes"`
+				} `json:"in"`
+			} `json:"network"`
+		}
+		require.NoError(t, json.Unmarshal(event, &sample))
+
+		host, counter := sample.Host.Name, sample.Network.In.Bytes
+		assert.GreaterOrEqual(t, counter, counters[host], string(event))
+		counters[host] = counter
+	}
+
+	assert.Len(t, counters, 3)
+}
+
+func TestValidateTemplate(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}","count":"{{.count}}","host":{{.host}},"unknown":"{{.unknown}}"}`, `- name: alpha
+  type: keyword
+- name: count
+  type: long
+- name: host
+  type: ip
+- name: unused
+  type: keyword
+`)
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  range: 10\n- name: beta\n  enum: [\"b\"]"))
+	require.NoError(t, err)
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(cfg, fs, "testdata", "placeholder")
+	require.NoError(t, err)
+
+	issues, err := fc.ValidateTemplate(context.Background(), templatePath, fieldsDefinitionPath)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"field unknown: referenced by the template but not found in the fields definition",
+		"field unused: not referenced by the template",
+		"field count: value of type long is quoted in the template, it is generated as a string",
+		"field host: value of type ip is not quoted in the template, it is generated as invalid JSON",
+		"line 3: field beta: not found in the fields definition",
+		"line 1: field alpha: range ignored for type keyword, it applies to numeric types only",
+	}, issues)
+
+	exists, err := afero.DirExists(fs, "testdata")
+	require.NoError(t, err)
+	assert.False(t, exists, "nothing is generated")
+
+	templatePath, fieldsDefinitionPath = writeTemplateAssets(t, "{\n  \"alpha\": \"{{ shout .alpha }}\"\n}", "- name: alpha\n  type: keyword\n")
+	fc, err = NewGeneratorWithTemplate(config.Config{}, fs, "testdata", "gotext")
+	require.NoError(t, err)
+
+	_, err = fc.ValidateTemplate(context.Background(), templatePath, fieldsDefinitionPath)
+	require.EqualError(t, err, "template "+templatePath+`:2:16: function "shout" not defined`)
+}
+
+func TestTemplateCoverage(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}","count":{{.count}},"unknown":"{{.unknown}}"}`, `- name: alpha
+  type: keyword
+- name: count
+  type: long
+- name: unused
+  type: keyword
+`)
+
+	fc, err := NewGeneratorWithTemplate(config.Config{}, afero.NewMemMapFs(), "testdata", "placeholder")
+	require.NoError(t, err)
+
+	coverage, err := fc.TemplateCoverage(context.Background(), templatePath, fieldsDefinitionPath)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alpha", "count"}, coverage.Covered)
+	assert.Equal(t, []string{"unused"}, coverage.Ignored)
+	assert.Equal(t, []string{"unknown"}, coverage.Unbound)
+	assert.InDelta(t, 66.7, coverage.CoveredPercent(), 0.1)
+}
+
+func TestLoadScenario(t *testing.T) {
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{"alpha":"{{.alpha}}"}`, "- name: alpha\n  type: keyword\n")
+	dir := filepath.Dir(templatePath)
+
+	scenarioPath := filepath.Join(dir, "scenario.yml")
+	require.NoError(t, os.WriteFile(scenarioPath, []byte(`- name: logs
+  template: template.ndjson
+  fields: fields.yml
+  tot_size: 1KB
+  output: logs.ndjson
+- template: template.ndjson
+  template_type: gotext
+  fields: `+fieldsDefinitionPath+`
+  events: 10
+  output: "-"
+`), 0644))
+
+	scenario, err := LoadScenario(scenarioPath)
+	require.NoError(t, err)
+	require.Equal(t, Scenario{
+		{Name: "logs", Template: templatePath, TemplateType: "placeholder", Fields: fieldsDefinitionPath, TotSize: "1KB", Output: "logs.ndjson"},
+		{Name: "template.ndjson", Template: templatePath, TemplateType: "gotext", Fields: fieldsDefinitionPath, Events: 10, Output: "-"},
+	}, scenario)
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "testdata", scenario[0].TemplateType, WithFilename(scenario[0].Output))
+	require.NoError(t, err)
+
+	payloadFilename, err := fc.GenerateWithTemplate(context.Background(), scenario[0].Template, scenario[0].Fields, scenario[0].TotSize)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join("testdata", "logs.ndjson"), payloadFilename)
+
+	require.NoError(t, os.WriteFile(scenarioPath, []byte(`- template: template.ndjson
+  fields: fields.yml
+  template_type: jinja
+- template: template.ndjson
+  fields: fields.yml
+  tot_size: 1KB
+  output: logs.ndjson
+- template: template.ndjson
+  fields: fields.yml
+  events: 1
+  output: logs.ndjson
+`), 0644))
+
+	_, err = LoadScenario(scenarioPath)
+	require.ErrorContains(t, err, "template_type must be one of")
+	require.ErrorContains(t, err, "you must provide either tot_size or events")
+	require.ErrorContains(t, err, "are written to the same output logs.ndjson")
+}
+
+func TestLoadSpec(t *testing.T) {
+	templatePath, _ := writeTemplateAssets(t, `{"alpha":"{{.alpha}}","beta":{{.beta}}}`, "")
+	dir := filepath.Dir(templatePath)
+
+	specPath := filepath.Join(dir, "spec.yml")
+	require.NoError(t, os.WriteFile(specPath, []byte(`template: template.ndjson
+fields:
+  - name: alpha
+    type: keyword
+  - name: beta
+    type: long
+config:
+  - name: alpha
+    enum: ["a", "b"]
+  - name: beta
+    range: 10
+events: 20
+output: spec.ndjson
+seed: 42
+`), 0644))
+
+	spec, err := LoadSpec(specPath)
+	require.NoError(t, err)
+	assert.Equal(t, templatePath, spec.Template)
+	assert.Equal(t, "placeholder", spec.TemplateType)
+	assert.Equal(t, Fields{{Name: "alpha", Type: "keyword"}, {Name: "beta", Type: "long"}}, spec.Fields)
+	assert.Equal(t, 8, spec.Config.Line("alpha"), "the lines of the config are the ones in the spec file")
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewFromSpec(spec, fs, "testdata")
+	require.NoError(t, err)
+
+	payloadFilename, err := fc.GenerateFromSpec(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join("testdata", "spec.ndjson"), payloadFilename)
+
+	payload, err := afero.ReadFile(fs, payloadFilename)
+	require.NoError(t, err)
+	require.Equal(t, 20, bytes.Count(payload, []byte("\n")))
+	for _, line := range bytes.Split(bytes.TrimSpace(payload), []byte("\n")) {
+		var event struct {
+			Alpha string `json:"alpha"`
+			Beta  int    `json:"beta"`
+		}
+
+		require.NoError(t, json.Unmarshal(line, &event))
+		assert.Contains(t, []string{"a", "b"}, event.Alpha)
+		assert.LessOrEqual(t, event.Beta, 10)
+	}
+
+	// the same seed generates the same corpus
+	fc, err = NewFromSpec(spec, fs, "testdata", WithFilename("again.ndjson"))
+	require.NoError(t, err)
+
+	payloadFilename, err = fc.GenerateFromSpec(context.Background())
+	require.NoError(t, err)
+	again, err := afero.ReadFile(fs, payloadFilename)
+	require.NoError(t, err)
+	assert.Equal(t, payload, again)
+
+	require.NoError(t, os.WriteFile(specPath, []byte(`template_type: jinja
+fields:
+  - name: alpha
+    type: keyword
+config:
+  - name: alpha
+    range: -1
+unknown: true
+`), 0644))
+
+	_, err = LoadSpec(specPath)
+	require.ErrorContains(t, err, "field unknown not found")
+
+	require.NoError(t, os.WriteFile(specPath, []byte(`template_type: jinja
+fields:
+  - name: alpha
+    type: keyword
+config:
+  - name: alpha
+    range: -1
+`), 0644))
+
+	_, err = LoadSpec(specPath)
+	require.ErrorContains(t, err, "config: line 6: field alpha: range and fuzziness must be positive")
+	require.ErrorContains(t, err, "you must provide the template")
+	require.ErrorContains(t, err, "template_type must be one of")
+
+	_, err = TestNewGenerator().GenerateFromSpec(context.Background())
+	require.EqualError(t, err, "the generator was not set up with a spec, see NewFromSpec")
+}
+
+func TestReplay(t *testing.T) {
+	corpusPath := filepath.Join(t.TempDir(), "corpus.ndjson")
+	require.NoError(t, os.WriteFile(corpusPath, []byte(`{ "create" : { "_index": "logs-default" } }
+{"@timestamp":"2022-01-01T10:00:00.000Z","event":{"created":"2022-01-01T09:59:59.5+01:00"},"message":"first"}
+{ "create" : { "_index": "logs-default" } }
+{"@timestamp":"2022-01-01T10:00:01.000Z","event.created":"2022-01-01T10:00:00Z","message":"second"}
+`), 0644))
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGenerator(Config{}, fs, "testdata")
+	require.NoError(t, err)
+
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	payloadFilename, err := fc.Replay(context.Background(), corpusPath, start, []string{"@timestamp", "event.created"})
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, payloadFilename)
+	require.NoError(t, err)
+	assert.Equal(t, `{ "create" : { "_index": "logs-default" } }
+{"@timestamp":"2030-01-01T00:00:00.000Z","event":{"created":"2029-12-31T23:59:59.5+01:00"},"message":"first"}
+{ "create" : { "_index": "logs-default" } }
+{"@timestamp":"2030-01-01T00:00:01.000Z","event.created":"2030-01-01T00:00:00Z","message":"second"}
+`, string(content))
+
+	require.NoError(t, os.WriteFile(corpusPath, []byte(`{"@timestamp":"yesterday"}`), 0644))
+	_, err = fc.Replay(context.Background(), corpusPath, start, []string{"@timestamp"})
+	require.ErrorContains(t, err, "line 1: field @timestamp")
+}
+
+func TestSplitByField(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "corpora/corpus.ndjson", []byte(`{ "create" : { "_index": "logs-default" } }
+{"host":{"name":"web-01"},"message":"first"}
+{ "create" : { "_index": "logs-default" } }
+{"host.name":"db/01","message":"second"}
+{ "create" : { "_index": "logs-default" } }
+{"message":"third"}
+{ "create" : { "_index": "logs-default" } }
+{"host":{"name":"web-01"},"message":"fourth"}
+`), 0644))
+
+	filenames, err := SplitByField(fs, "corpora/corpus.ndjson", "host.name", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"corpora/corpus-db-01.ndjson", "corpora/corpus-missing.ndjson", "corpora/corpus-web-01.ndjson"}, filenames)
+
+	content, err := afero.ReadFile(fs, "corpora/corpus-web-01.ndjson")
+	require.NoError(t, err)
+	assert.Equal(t, `{ "create" : { "_index": "logs-default" } }
+{"host":{"name":"web-01"},"message":"first"}
+{ "create" : { "_index": "logs-default" } }
+{"host":{"name":"web-01"},"message":"fourth"}
+`, string(content))
+
+	filenames, err = SplitByField(fs, "corpora/corpus.ndjson", "message", 2)
+	require.NoError(t, err)
+	var events int
+	for _, filename := range filenames {
+		assert.Contains(t, []string{"corpora/corpus-shard0.ndjson", "corpora/corpus-shard1.ndjson"}, filename)
+		content, err := afero.ReadFile(fs, filename)
+		require.NoError(t, err)
+		events += bytes.Count(content, []byte("\n")) / 2
+	}
+
+	assert.Equal(t, 4, events)
+
+	_, err = SplitByField(fs, "corpora/corpus.ndjson", "host", 0)
+	require.ErrorContains(t, err, "line 2: field host: cannot split by a non scalar value")
+}
+
+func TestInferFromSamples(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "samples/access.ndjson", []byte(`{"@timestamp":"2024-01-02T10:00:00.123Z","event":{"dataset":"nginx.access"},"source":{"ip":"10.0.0.1"},"http.response.status_code":200,"tags":["a","b"],"error":null}
+{"@timestamp":"2024-01-02T10:00:01Z","event":{"dataset":"nginx.access"},"source":{"ip":"2001:db8::1"},"http.response.status_code":404,"tags":["a"],"error":{"message":"boom"}}
+`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "samples/more.json", []byte(`[
+  {"@timestamp":"2024-01-02T10:00:02Z","event":{"dataset":"nginx.access"},"source":{"ip":"10.0.0.2"},"http.response.status_code":200,"tags":[],"location":{"lat":48.85,"lon":2.35},"bytes":1.25},
+  {"@timestamp":"2024-01-02T10:00:03Z","event":{"dataset":"nginx.access"},"source":{"ip":"10.0.0.3"},"http.response.status_code":200,"tags":["b"],"location":{"lat":40.7,"lon":-74},"bytes":12}
+]`), 0644))
+
+	assets, err := InferFromSamples(fs, []string{"samples/access.ndjson", "samples/more.json"})
+	require.NoError(t, err)
+
+	assert.Equal(t, `{
+  "@timestamp": "{{.@timestamp}}",
+  "event": {
+    "dataset": "{{.event.dataset}}"
+  },
+  "source": {
+    "ip": "{{.source.ip}}"
+  },
+  "http.response.status_code": "{{.http.response.status_code}}",
+  "tags": "{{.tags}}",
+  "error": {
+    "message": "{{.error.message}}"
+  },
+  "location": "{{.location}}",
+  "bytes": "{{.bytes}}"
+}
+`, string(assets.Template))
+
+	assert.Equal(t, `- name: '@timestamp'
+  type: date
+- name: event.dataset
+  type: keyword
+- name: source.ip
+  type: ip
+- name: http.response.status_code
+  type: long
+- name: tags
+  type: keyword
+- name: error.message
+  type: keyword
+  example: boom
+- name: location
+  type: geo_point
+- name: bytes
+  type: double
+`, string(assets.FieldsDefinition))
+
+	assert.Equal(t, `- name: event.dataset
+  value: nginx.access
+- name: source.ip
+  ipv6_percentage: 25
+- name: http.response.status_code
+  range: 1000
+- name: tags
+  enum:
+    - a
+    - b
+  array_max: 2
+- name: error.message
+  omit_percentage: 75
+- name: location
+  geo_format: object
+  omit_percentage: 50
+- name: bytes
+  range: 100
+  precision: 2
+  omit_percentage: 50
+`, string(assets.Config))
+
+	cfg, err := config.LoadConfigFromYaml(assets.Config, config.WithStrict())
+	require.NoError(t, err)
+	fieldCfg, _ := cfg.GetField("tags")
+	assert.Equal(t, 2, fieldCfg.ArrayMax)
+
+	templatePath, fieldsDefinitionPath, configPath, err := assets.Write(fs, "assets", "access")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("assets", "access.template.json"), templatePath)
+	assert.Equal(t, filepath.Join("assets", "access.fields.yml"), fieldsDefinitionPath)
+	assert.Equal(t, filepath.Join("assets", "access.conf.yml"), configPath)
+
+	require.NoError(t, afero.WriteFile(fs, "samples/mixed.ndjson", []byte(`{"a":{"b":1}}
+{"a":2}
+`), 0644))
+	_, err = InferFromSamples(fs, []string{"samples/mixed.ndjson"})
+	require.ErrorContains(t, err, "field a: both objects and values found in the samples")
+
+	require.NoError(t, afero.WriteFile(fs, "samples/scalar.ndjson", []byte(`{"a":1}
+"b"
+`), 0644))
+	_, err = InferFromSamples(fs, []string{"samples/scalar.ndjson"})
+	require.ErrorContains(t, err, "samples/scalar.ndjson: document 2: not a JSON object")
+}
+
+func TestProfileIndex(t *testing.T) {
+	var histogramInterval string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/logs-web/_mapping":
+			_, _ = io.WriteString(w, `{"logs-web":{"mappings":{"properties":{
+"@timestamp":{"type":"date"},
+"event":{"properties":{"dataset":{"type":"constant_keyword"}}},
+"host":{"properties":{"name":{"type":"keyword"}}},
+"http":{"properties":{"status":{"type":"long"}}},
+"message":{"type":"text","fields":{"keyword":{"type":"keyword"}}},
+"source":{"properties":{"ip":{"type":"ip"}}},
+"user":{"properties":{"id":{"type":"keyword"}}}}}}}`)
+		case "/logs-web/_search":
+			var body struct {
+				Aggs map[string]struct {
+					DateHistogram struct {
+						FixedInterval string `json:"fixed_interval"`
+					} `json:"date_histogram"`
+				} `json:"aggs"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+			if rate, ok := body.Aggs["rate"]; ok {
+				histogramInterval = rate.DateHistogram.FixedInterval
+				_, _ = io.WriteString(w, `{"hits":{"total":{"value":1000}},"aggregations":{"rate":{"buckets":[{"key":1704067200000,"doc_count":50},{"key":1704067210000,"doc_count":8}]}}}`)
+				return
+			}
+
+			assert.Len(t, body.Aggs, 17, "the text field is not profiled")
+			_, _ = io.WriteString(w, `{"hits":{"total":{"value":1000}},"aggregations":{
+"time_range":{"count":1000,"min":1704067200000,"max":1704068200000},
+"missing_0":{"doc_count":0},
+"missing_1":{"doc_count":0},"terms_1":{"sum_other_doc_count":0,"buckets":[{"key":"web.access","doc_count":1000}]},"cardinality_1":{"value":1},
+"missing_2":{"doc_count":200},"terms_2":{"sum_other_doc_count":0,"buckets":[{"key":"web-01","doc_count":600},{"key":"web-02","doc_count":200}]},"cardinality_2":{"value":2},
+"missing_3":{"doc_count":0},"stats_3":{"count":1000,"min":200,"max":503},"cardinality_3":{"value":5},
+"missing_4":{"doc_count":0},"terms_4":{"sum_other_doc_count":900,"buckets":[{"key":"10.0.0.1","doc_count":100}]},"cardinality_4":{"value":300},
+"missing_5":{"doc_count":0},"terms_5":{"sum_other_doc_count":980,"buckets":[{"key":"u1","doc_count":20}]},"cardinality_5":{"value":1000}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	profile, err := ProfileIndex(context.Background(), server.URL, "logs-web", "@timestamp")
+	require.NoError(t, err)
+	assert.Equal(t, "10s", histogramInterval)
+	assert.Equal(t, uint64(1000), profile.Docs)
+	assert.InDelta(t, 1., profile.AvgRate, 0.001)
+	assert.InDelta(t, 5., profile.PeakRate, 0.001)
+
+	var buf bytes.Buffer
+	require.NoError(t, profile.WriteConfig(&buf))
+	assert.True(t, strings.HasPrefix(buf.String(), `# profile of index logs-web: 1000 documents from 2024-01-01T00:00:00Z to 2024-01-01T00:16:40Z
+# event rate: 1/s on average, 5/s at peak
+`), buf.String())
+
+	cfg, err := config.LoadConfigFromYaml(buf.Bytes(), config.WithStrict())
+	require.NoError(t, err)
+
+	fieldCfg, _ := cfg.GetField("event.dataset")
+	assert.Equal(t, "web.access", fieldCfg.Value)
+
+	fieldCfg, _ = cfg.GetField("host.name")
+	assert.Len(t, fieldCfg.Enum, 100)
+	assert.Equal(t, "web-01", fieldCfg.Enum[74])
+	assert.Equal(t, "web-02", fieldCfg.Enum[75])
+	assert.Equal(t, 20, fieldCfg.OmitPercentage)
+
+	fieldCfg, _ = cfg.GetField("http.status")
+	assert.Equal(t, 504, fieldCfg.Range.Magnitude)
+	assert.Equal(t, 5, fieldCfg.Cardinality.Distinct)
+
+	fieldCfg, _ = cfg.GetField("source.ip")
+	assert.Equal(t, 300, fieldCfg.Cardinality.Distinct)
+
+	_, ok := cfg.GetField("user.id")
+	assert.False(t, ok, "unique values are not configured")
+
+	_, err = ProfileIndex(context.Background(), server.URL, "missing", "@timestamp")
+	require.ErrorContains(t, err, "index missing: cannot get the mapping: unexpected status code 404")
+}
+
+// writeTemplateAssets writes the template and the fields definition to a temporary dir, returning their paths.
+func writeTemplateAssets(t *testing.T, template, fieldsDefinition string) (string, string) {
+	dir := t.TempDir()
+
+	templatePath := filepath.Join(dir, "template.ndjson")
+	require.NoError(t, os.WriteFile(templatePath, []byte(template), 0644))
+
+	fieldsDefinitionPath := filepath.Join(dir, "fields.yml")
+	require.NoError(t, os.WriteFile(fieldsDefinitionPath, []byte(fieldsDefinition), 0644))
+
+	return templatePath, fieldsDefinitionPath
+}
+
+func writePackage(t *testing.T, dir string) {
+	files := map[string]string{
+		"manifest.yml": "name: nginx\ntitle: Nginx\nversion: 1.2.0\n",
+		"data_stream/access/fields/base-fields.yml": "- name: data_stream.dataset\n  type: constant_keyword\n",
+		"data_stream/access/fields/fields.yml":      "- name: nginx.access\n  type: group\n  fields:\n    - name: user_name\n      type: keyword\n",
+		"data_stream/error/fields/fields.yml":       "- name: nginx.error.level\n  type: keyword\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func writePackageZip(t *testing.T, dir, zipPath string) {
+	f, err := os.Create(zipPath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	require.NoError(t, filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		w, err := zw.Create("nginx-1.2.0/" + filepath.ToSlash(name))
+		if err != nil {
+			return err
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(content)
+		return err
+	}))
+	require.NoError(t, zw.Close())
+}
+
+func TestGenerateFromPackage(t *testing.T) {
+	packageDir := filepath.Join(t.TempDir(), "nginx")
+	writePackage(t, packageDir)
+
+	packageZip := filepath.Join(t.TempDir(), "nginx-1.2.0.zip")
+	writePackageZip(t, packageDir, packageZip)
+
+	for _, packagePath := range []string{packageDir, packageZip} {
+		fc := TestNewGenerator()
+		payloadFilename, err := fc.GenerateFromPackage(context.Background(), packagePath, "access", "2KB")
+		require.NoError(t, err, packagePath)
+		require.Equal(t, filepath.Join("testdata", "1647345675-nginx-access-1.2.0.ndjson"), payloadFilename)
+
+		f, err := fc.fs.Open(payloadFilename)
+		require.NoError(t, err)
+
+		scanner := bufio.NewScanner(f)
+		require.True(t, scanner.Scan())
+		require.Contains(t, scanner.Text(), `"create"`)
+		require.True(t, scanner.Scan())
+
+		var event map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		// the dotted names are nested objects
+		require.IsType(t, map[string]interface{}{}, event["nginx"], packagePath)
+		nginx := event["nginx"].(map[string]interface{})
+		require.Contains(t, nginx["access"], "user_name", packagePath)
+		require.NotContains(t, nginx, "error", packagePath)
+		require.Contains(t, event["data_stream"], "dataset", packagePath)
+		require.Contains(t, event, "@timestamp", packagePath)
+		require.NoError(t, f.Close())
+	}
+
+	_, err := TestNewGenerator().GenerateFromPackage(context.Background(), packageDir, "missing", "2KB")
+	require.ErrorIs(t, err, fields.ErrNotFound)
+}
+
+func TestGenerate_format(t *testing.T) {
+	packageDir := filepath.Join(t.TempDir(), "nginx")
+	writePackage(t, packageDir)
+
+	testCases := []struct {
+		opts             []GeneratorCorpusOption
+		expectedFilename string
+		bulkActions      bool
+	}{
+		{expectedFilename: "1647345675-nginx-access-1.2.0.ndjson", bulkActions: true},
+		{opts: []GeneratorCorpusOption{WithFormat(FormatBulk)}, expectedFilename: "1647345675-nginx-access-1.2.0.ndjson", bulkActions: true},
+		{opts: []GeneratorCorpusOption{WithFormat(FormatNDJSON)}, expectedFilename: "1647345675-nginx-access-1.2.0.ndjson"},
+		{opts: []GeneratorCorpusOption{WithFormat(FormatRaw)}, expectedFilename: "1647345675-nginx-access-1.2.0.log"},
+		{opts: []GeneratorCorpusOption{WithFormat(FormatRaw), WithExtension("txt")}, expectedFilename: "1647345675-nginx-access-1.2.0.txt"},
+	}
+
+	for _, tc := range testCases {
+		fc, err := NewGenerator(Config{}, afero.NewMemMapFs(), "testdata", tc.opts...)
+		require.NoError(t, err)
+		fc.timestamp = func() int64 { return 1647345675 }
+
+		payloadFilename, err := fc.GenerateFromPackage(context.Background(), packageDir, "access", "1KB")
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join("testdata", tc.expectedFilename), payloadFilename)
+
+		content, err := afero.ReadFile(fc.fs, payloadFilename)
+		require.NoError(t, err)
+		require.Equal(t, tc.bulkActions, strings.HasPrefix(string(content), `{ "create"`), tc.expectedFilename)
+	}
+
+	templatePath, fieldsDefinitionPath := writeTemplateAssets(t, `{{.alpha}} "quoted"`, "- name: alpha\n  type: keyword\n")
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "testdata", "placeholder", WithFormat(FormatRaw), WithExtension(".log"))
+	require.NoError(t, err)
+	fc.timestamp = func() int64 { return 1647345675 }
+
+	payloadFilename, err := fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "1KB")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join("testdata", "1647345675-template.log"), payloadFilename)
+
+	fc, err = NewGeneratorWithTemplate(Config{}, fs, "testdata", "placeholder", WithFormat(FormatBulk))
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplate(context.Background(), templatePath, fieldsDefinitionPath, "1KB")
+	require.ErrorIs(t, err, ErrBulkFormatWithTemplate)
+}
+
+func TestGenerate_fieldsCache(t *testing.T) {
+	packageDir := filepath.Join(t.TempDir(), "nginx")
+	writePackage(t, packageDir)
+
+	packageZip := filepath.Join(t.TempDir(), "nginx-1.2.0.zip")
+	writePackageZip(t, packageDir, packageZip)
+
+	var requests int
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/package/nginx/1.2.0":
+			_, _ = w.Write([]byte(`{"download": "/epr/nginx/nginx-1.2.0.zip"}`))
+		case "/epr/nginx/nginx-1.2.0.zip":
+			http.ServeFile(w, r, packageZip)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer registry.Close()
+
+	cacheDir := t.TempDir()
+	for _, opts := range [][]GeneratorCorpusOption{
+		{WithFieldsCache(cacheDir)},
+		{WithFieldsCache(cacheDir)},
+		{WithFieldsCache(cacheDir), WithOffline()},
+	} {
+		fc, err := NewGenerator(Config{}, afero.NewMemMapFs(), "testdata", opts...)
+		require.NoError(t, err)
+
+		_, err = fc.Generate(context.Background(), registry.URL, "nginx", "access", "1.2.0", "1KB")
+		require.NoError(t, err)
+	}
+
+	// the fields are downloaded once
+	require.Equal(t, 2, requests)
+	require.FileExists(t, filepath.Join(cacheDir, "nginx", "1.2.0", "access.yml"))
+
+	fc, err := NewGenerator(Config{}, afero.NewMemMapFs(), "testdata", WithFieldsCache(cacheDir), WithOffline())
+	require.NoError(t, err)
+
+	_, err = fc.Generate(context.Background(), registry.URL, "nginx", "error", "1.2.0", "1KB")
+	require.ErrorIs(t, err, fields.ErrCacheMiss)
+	require.Equal(t, 2, requests)
+}
+
+func TestGenerateDataStreams(t *testing.T) {
+	packageDir := filepath.Join(t.TempDir(), "nginx")
+	writePackage(t, packageDir)
+
+	packageZip := filepath.Join(t.TempDir(), "nginx-1.2.0.zip")
+	writePackageZip(t, packageDir, packageZip)
+
+	var requests int
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/package/nginx/1.2.0":
+			_, _ = w.Write([]byte(`{"download": "/epr/nginx/nginx-1.2.0.zip", "data_streams": [{"path": "error"}, {"path": "access"}]}`))
+		case "/epr/nginx/nginx-1.2.0.zip":
+			http.ServeFile(w, r, packageZip)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer registry.Close()
+
+	expected := []string{
+		filepath.Join("testdata", "1647345675-nginx-access-1.2.0.ndjson"),
+		filepath.Join("testdata", "1647345675-nginx-error-1.2.0.ndjson"),
+	}
+
+	// the config is shared by all the data streams, in strict mode it can reference the fields of any of them
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: nginx.access.user_name\n  enum: [alice]\n- name: nginx.error.level\n  enum: [warn]"))
+	require.NoError(t, err)
+
+	for _, packagePath := range []string{packageDir, packageZip} {
+		fc := TestNewGenerator()
+		fc.config = cfg
+		fc.strict = true
+
+		payloadFilenames, err := fc.GenerateFromPackageDataStreams(context.Background(), packagePath, nil, "1KB")
+		require.NoError(t, err, packagePath)
+		require.Equal(t, expected, payloadFilenames, packagePath)
+
+		for _, payloadFilename := range payloadFilenames {
+			content, err := afero.ReadFile(fc.fs, payloadFilename)
+			require.NoError(t, err)
+			require.NotEmpty(t, content)
+		}
+
+		fc.strict = false
+		payloadFilenames, err = fc.GenerateFromPackageDataStreams(context.Background(), packagePath, []string{"error"}, "1KB")
+		require.NoError(t, err, packagePath)
+		require.Equal(t, expected[1:], payloadFilenames, packagePath)
+	}
+
+	cacheDir := t.TempDir()
+	for _, opts := range [][]GeneratorCorpusOption{
+		{WithFieldsCache(cacheDir)},
+		{WithFieldsCache(cacheDir), WithOffline()},
+	} {
+		fc, err := NewGenerator(cfg, afero.NewMemMapFs(), "testdata", append(opts, WithStrict())...)
+		require.NoError(t, err)
+		fc.timestamp = func() int64 { return 1647345675 }
+
+		payloadFilenames, err := fc.GenerateDataStreams(context.Background(), registry.URL, "nginx", nil, "1.2.0", "1KB")
+		require.NoError(t, err)
+		require.Equal(t, expected, payloadFilenames)
+	}
+
+	// the data streams and their fields are downloaded once
+	require.Equal(t, 5, requests)
+
+	_, err = TestNewGenerator().GenerateFromPackageDataStreams(context.Background(), packageDir, []string{"access", "missing"}, "1KB")
+	require.ErrorIs(t, err, fields.ErrNotFound)
+}
@@ -0,0 +1,204 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+const (
+	// IDStrategyUUID sets a random UUID as _id, reproducible with the seed
+	IDStrategyUUID = "uuid"
+	// IDStrategyHash sets the hash of the values of some fields as _id, the same values always give the same _id
+	IDStrategyHash = "hash"
+	// IDStrategyTimestampSequence sets the timestamp of the run and the position of the event in the corpus as _id
+	IDStrategyTimestampSequence = "timestamp-sequence"
+)
+
+// maxDuplicateCandidates is the number of the last _id generated that the duplicated ones are drawn from
+const maxDuplicateCandidates = 1024
+
+// IDStrategy is how the _id of the bulk request action of each event is generated.
+type IDStrategy struct {
+	Type string
+	// Fields are the fields whose values are hashed by IDStrategyHash
+	Fields []string
+	// DuplicatePercentage is the percentage of the events whose _id is one of the last ones generated, drawn at random,
+	// instead of the one of the strategy
+	DuplicatePercentage int
+}
+
+// ParseIDStrategy parses an _id strategy: uuid, timestamp-sequence or hash:field,... like hash:host.name,@timestamp.
+func ParseIDStrategy(s string) (IDStrategy, error) {
+	strategyType, fields, _ := strings.Cut(s, ":")
+	switch strategyType {
+	case IDStrategyUUID, IDStrategyTimestampSequence:
+		if len(fields) > 0 {
+			return IDStrategy{}, fmt.Errorf("id strategy %s: %s does not take fields", s, strategyType)
+		}
+
+		return IDStrategy{Type: strategyType}, nil
+	case IDStrategyHash:
+		if len(fields) == 0 {
+			return IDStrategy{}, fmt.Errorf("id strategy %s: must be in the %s:field,... form", s, IDStrategyHash)
+		}
+
+		return IDStrategy{Type: strategyType, Fields: strings.Split(fields, ",")}, nil
+	}
+
+	return IDStrategy{}, fmt.Errorf("id strategy %s: must be one of %s, %s:field,... and %s", s, IDStrategyUUID, IDStrategyHash, IDStrategyTimestampSequence)
+}
+
+// WithIDStrategy sets the _id of the bulk request action of each event according to strategy.
+// It applies to the corpora with bulk request actions only, the ones generated from integration package fields.
+func WithIDStrategy(strategy IDStrategy) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.idStrategy = strategy
+	}
+}
+
+// bulkActions makes the bulk request action of each event, with the _id set by the strategy of WithIDStrategy, and
+// the target of its namespace when spread across the namespaces of BulkAction.Namespaces.
+type bulkActions struct {
+	strategy     IDStrategy
+	op           string
+	meta         map[string]interface{}
+	namespaces   *namespaceSpreader
+	rand         *rand.Rand
+	runTimestamp int64
+	payload      bytes.Buffer
+	// generated are the last _id generated, in a ring of maxDuplicateCandidates
+	generated  []string
+	duplicates uint64
+}
+
+// newBulkActions returns the maker of the bulk request actions based on createPayload, nil if there is no _id strategy
+// and the events are not spread across namespaces.
+func (gc GeneratorCorpus) newBulkActions(createPayload []byte) (*bulkActions, error) {
+	if (len(gc.idStrategy.Type) == 0 && gc.bulkAction.Namespaces <= 1) || len(createPayload) == 0 {
+		return nil, nil
+	}
+
+	var action map[string]map[string]interface{}
+	if err := json.Unmarshal(createPayload, &action); err != nil {
+		return nil, err
+	}
+
+	actions := &bulkActions{
+		strategy:     gc.idStrategy,
+		rand:         rand.New(rand.NewSource(gc.seed)),
+		runTimestamp: gc.timestamp(),
+	}
+
+	for op, meta := range action {
+		actions.op, actions.meta = op, meta
+	}
+
+	if gc.bulkAction.Namespaces > 1 {
+		index, _ := actions.meta["_index"].(string)
+		namespaces, err := newNamespaceSpreader(gc.bulkAction, index)
+		if err != nil {
+			return nil, err
+		}
+
+		actions.namespaces = namespaces
+	}
+
+	return actions, nil
+}
+
+// withAction returns the payload of the n-th event: its bulk request action with the _id and the target of its
+// namespace, and the event itself, with the fields of its namespace set.
+func (a *bulkActions) withAction(n uint64, event []byte) (*bytes.Buffer, error) {
+	if a.namespaces != nil {
+		var err error
+		if event, err = a.namespaces.spread(n, event); err != nil {
+			return nil, err
+		}
+
+		a.meta["_index"] = a.namespaces.index(n)
+	}
+
+	if len(a.strategy.Type) > 0 {
+		id, err := a.nextID(n, event)
+		if err != nil {
+			return nil, err
+		}
+
+		a.meta["_id"] = id
+	}
+
+	action, err := json.Marshal(map[string]interface{}{a.op: a.meta})
+	if err != nil {
+		return nil, err
+	}
+
+	a.payload.Reset()
+	a.payload.Write(action)
+	a.payload.WriteByte('\n')
+	a.payload.Write(event)
+	return &a.payload, nil
+}
+
+// nextID returns the _id of the n-th event: either a new one, or one of the last ones generated
+// for DuplicatePercentage of the events.
+func (a *bulkActions) nextID(n uint64, event []byte) (string, error) {
+	if a.strategy.DuplicatePercentage > 0 && len(a.generated) > 0 && a.rand.Intn(100) < a.strategy.DuplicatePercentage {
+		a.duplicates++
+		return a.generated[a.rand.Intn(len(a.generated))], nil
+	}
+
+	id, err := a.id(n, event)
+	if err != nil {
+		return "", err
+	}
+
+	if a.strategy.DuplicatePercentage > 0 {
+		if len(a.generated) < maxDuplicateCandidates {
+			a.generated = append(a.generated, id)
+		} else {
+			a.generated[n%maxDuplicateCandidates] = id
+		}
+	}
+
+	return id, nil
+}
+
+func (a *bulkActions) id(n uint64, event []byte) (string, error) {
+	switch a.strategy.Type {
+	case IDStrategyUUID:
+		var b [16]byte
+		_, _ = a.rand.Read(b[:])
+		// version 4, variant RFC 4122
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+	case IDStrategyHash:
+		var doc map[string]interface{}
+		if err := json.Unmarshal(event, &doc); err != nil {
+			return "", fmt.Errorf("event %d: not a JSON object: %v", n, err)
+		}
+
+		h := sha1.New()
+		for _, field := range a.strategy.Fields {
+			value, err := json.Marshal(lookupPath(doc, field))
+			if err != nil {
+				return "", err
+			}
+
+			fmt.Fprintf(h, "%s=%s\n", field, value)
+		}
+
+		return hex.EncodeToString(h.Sum(nil)), nil
+	default:
+		return fmt.Sprintf("%d-%d", a.runTimestamp, n), nil
+	}
+}
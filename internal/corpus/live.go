@@ -0,0 +1,72 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// WithRate generates eventsPerSecond events per second in real time, with the current timestamp, until the
+// context is done or duration elapses: a zero duration never elapses. Without a total size the generation
+// is not limited in size, and the context being done ends it without ErrInterrupted.
+func WithRate(eventsPerSecond float64, duration time.Duration) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.rate = eventsPerSecond
+		gc.duration = duration
+	}
+}
+
+// parseTotSize parses the total size of the corpus to generate: it can be empty in live mode and in soak runs,
+// for no limit, or when the events count is limited.
+func (gc GeneratorCorpus) parseTotSize(totSize string) (uint64, error) {
+	if len(totSize) == 0 {
+		if gc.rate > 0 || gc.events > 0 || gc.soakOptions != nil {
+			return 0, nil
+		}
+
+		return 0, errors.New("you must provide a total size of the corpus, unless a rate, an events count or a soak run is set")
+	}
+
+	totSizeInBytes, err := humanize.ParseBytes(totSize)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse total size of the corpus: %v", err)
+	}
+
+	return totSizeInBytes, nil
+}
+
+// waitNextEvent waits until the event n is due according to the rate, flushing the sink meanwhile.
+// It returns false if the event would be due after the duration elapsed, or the context is done.
+func (gc GeneratorCorpus) waitNextEvent(ctx context.Context, start time.Time, n uint64, sink Sink) (bool, error) {
+	offset := time.Duration(float64(n) / gc.rate * float64(time.Second))
+	if gc.duration > 0 && offset >= gc.duration {
+		return false, nil
+	}
+
+	wait := time.Until(start.Add(offset))
+	if wait <= 0 {
+		return true, nil
+	}
+
+	// the events written so far are made available while waiting
+	if err := sink.Flush(); err != nil {
+		return false, err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false, nil
+	case <-timer.C:
+		return true, nil
+	}
+}
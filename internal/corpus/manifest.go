@@ -0,0 +1,129 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/spf13/afero"
+)
+
+const manifestExt = ".manifest.json"
+
+// WithManifest writes next to each corpus its manifest, describing how it has been generated, so that the corpora
+// stored away from the run, like in a bucket, can be told apart and generated again: config is the content of the
+// config file the generator is set up with, if any. With WithSink the manifest is written to the sink as well,
+// under its own name, which fits the sinks storing named objects only.
+func WithManifest(config []byte) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.manifest = true
+		gc.manifestConfig = config
+	}
+}
+
+// ManifestFilename returns the filename of the manifest of the corpus with the given filename.
+func ManifestFilename(payloadFilename string) string {
+	return payloadFilename + manifestExt
+}
+
+// Manifest describes how a corpus has been generated.
+type Manifest struct {
+	Version    string `json:"version"`
+	CommitHash string `json:"commit_hash"`
+	Filename   string `json:"filename"`
+	// Config is the content of the config file, empty without one
+	Config string `json:"config,omitempty"`
+	// FieldsSHA256 is the one of the definitions of the fields the events are generated from
+	FieldsSHA256 string `json:"fields_sha256"`
+	// TemplateSHA256 is the one of the template the events are generated from, after expanding its partials
+	TemplateSHA256  string            `json:"template_sha256,omitempty"`
+	Seed            int64             `json:"seed"`
+	Params          map[string]string `json:"params"`
+	Events          uint64            `json:"events"`
+	Bytes           uint64            `json:"bytes"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Interrupted     bool              `json:"interrupted,omitempty"`
+}
+
+// newManifest returns the manifest of the corpus of the run of summary, generated from flds and template, if any.
+func (gc GeneratorCorpus) newManifest(summary RunSummary, flds Fields, template []byte) (Manifest, error) {
+	fieldsDefinitions, err := json.Marshal(flds)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	fieldsSum := sha256.Sum256(fieldsDefinitions)
+	manifest := Manifest{
+		Version:         summary.Version,
+		CommitHash:      summary.CommitHash,
+		Filename:        summary.Filename,
+		Config:          string(gc.manifestConfig),
+		FieldsSHA256:    hex.EncodeToString(fieldsSum[:]),
+		Seed:            summary.Seed,
+		Params:          summary.Params,
+		Events:          summary.Events,
+		Bytes:           summary.Bytes,
+		DurationSeconds: summary.DurationSeconds,
+		Interrupted:     summary.Interrupted,
+	}
+
+	if len(template) > 0 {
+		templateSum := sha256.Sum256(template)
+		manifest.TemplateSHA256 = hex.EncodeToString(templateSum[:])
+	}
+
+	return manifest, nil
+}
+
+// writeManifest writes the manifest of the corpus named filename to the sink provided by WithSink, if any,
+// otherwise next to the corpus file in the corpora location.
+func (gc GeneratorCorpus) writeManifest(filename string, summary RunSummary, flds Fields, template []byte) error {
+	manifest, err := gc.newManifest(summary, flds, template)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	body = append(body, '\n')
+	if gc.sink == nil {
+		if err := gc.fs.MkdirAll(gc.location, corpusLocPerm); err != nil {
+			return fmt.Errorf("cannot generate corpus location folder: %v", err)
+		}
+
+		if err := afero.WriteFile(gc.fs, ManifestFilename(path.Join(gc.location, filename)), body, corpusPerm); err != nil {
+			return fmt.Errorf("cannot write manifest: %w", err)
+		}
+
+		return nil
+	}
+
+	// the run context could be already cancelled, the manifest of interrupted runs is written as well
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+	defer cancel()
+
+	if err := gc.sink.Open(ctx, ManifestFilename(filename)); err != nil {
+		return fmt.Errorf("cannot write manifest: %w", err)
+	}
+
+	_, err = gc.sink.Write(body)
+	if closeErr := gc.sink.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot write manifest to %s: %w", gc.sink.Name(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// namespaceSpreader spreads the events in turn across the namespaces of BulkAction.Namespaces.
+type namespaceSpreader struct {
+	namespaces []string
+	// indices are the targets of the bulk request actions of the events of each namespace
+	indices []string
+	fields  []string
+	buf     bytes.Buffer
+}
+
+// newNamespaceSpreader returns the spreader of the events across the namespaces of action, index being the target of
+// the bulk request actions of its namespace.
+func newNamespaceSpreader(action BulkAction, index string) (*namespaceSpreader, error) {
+	if action.Index != "" {
+		return nil, errors.New("the events cannot be spread across namespaces with a bulk index")
+	}
+
+	namespace := action.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	if !strings.HasSuffix(index, "-"+namespace) {
+		return nil, fmt.Errorf("the target %s of the bulk request actions is not in the %s namespace", index, namespace)
+	}
+
+	s := &namespaceSpreader{fields: action.NamespaceFields}
+	for i := 1; i <= action.Namespaces; i++ {
+		spread := fmt.Sprintf("%s_%d", namespace, i)
+		s.namespaces = append(s.namespaces, spread)
+		s.indices = append(s.indices, strings.TrimSuffix(index, namespace)+spread)
+	}
+
+	return s, nil
+}
+
+// index returns the target of the bulk request action of the n-th event.
+func (s *namespaceSpreader) index(n uint64) string {
+	return s.indices[n%uint64(len(s.indices))]
+}
+
+// spread returns the n-th event with its fields of NamespaceFields set to its namespace, when present. The events
+// that are not JSON objects are left as they are.
+func (s *namespaceSpreader) spread(n uint64, event []byte) ([]byte, error) {
+	if len(s.fields) == 0 {
+		return event, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(event))
+	decoder.UseNumber()
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil || doc == nil {
+		return event, nil
+	}
+
+	namespace := s.namespaces[n%uint64(len(s.namespaces))]
+	var changed bool
+	for _, field := range s.fields {
+		if setPath(doc, field, namespace) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return event, nil
+	}
+
+	s.buf.Reset()
+	encoder := json.NewEncoder(&s.buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+
+	// the encoder ends the event with a newline, as the event itself
+	if !bytes.HasSuffix(event, []byte("\n")) {
+		s.buf.Truncate(s.buf.Len() - 1)
+	}
+
+	return s.buf.Bytes(), nil
+}
+
+// setPath sets the value at the dotted path of doc, if present, like lookupPath.
+func setPath(doc map[string]interface{}, dottedPath string, value interface{}) bool {
+	if _, ok := doc[dottedPath]; ok {
+		doc[dottedPath] = value
+		return true
+	}
+
+	for i := 0; i < len(dottedPath); i++ {
+		if dottedPath[i] != '.' {
+			continue
+		}
+
+		if nested, ok := doc[dottedPath[:i]].(map[string]interface{}); ok {
+			if setPath(nested, dottedPath[i+1:], value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,392 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// maxProfileEnum is the cardinality of the keyword fields profiled as an enum, weighted by the frequency of the values
+	maxProfileEnum = 20
+	// profileEnumEntries is the count of the entries the weighted enums are made of
+	profileEnumEntries = 100
+	// profileHistogramBuckets is the count of the buckets of the date histogram the event rate is profiled with
+	profileHistogramBuckets = 100
+)
+
+// IndexProfile is the distribution of the values of the fields of an Elasticsearch index, see ProfileIndex.
+type IndexProfile struct {
+	Index string
+	Docs  uint64
+	// From and To bound the values of the time field, they are zero if the index has no time field
+	From time.Time
+	To   time.Time
+	// AvgRate and PeakRate are the events per second on average and in the busiest bucket of the date histogram
+	AvgRate  float64
+	PeakRate float64
+	Fields   []FieldProfile
+}
+
+// FieldProfile is the distribution of the values of a field of an Elasticsearch index.
+type FieldProfile struct {
+	Name string
+	// Type is the type of the field in the mapping of the index
+	Type string
+	// Missing is the count of the documents without a value for the field
+	Missing     uint64
+	Cardinality uint64
+	// Terms are the most frequent values of keyword, ip and boolean fields, complete if their cardinality is low
+	Terms         []TermCount
+	TermsComplete bool
+	// Min and Max bound the values of numeric fields, they are nil if the field has no value
+	Min *float64
+	Max *float64
+}
+
+// TermCount is a value of a field with the count of the documents having it.
+type TermCount struct {
+	Value string
+	Count uint64
+}
+
+// profileConfigEntry is an entry of the config reproducing the profile of a field.
+type profileConfigEntry struct {
+	Name           string              `yaml:"name"`
+	Value          interface{}         `yaml:"value,omitempty"`
+	Enum           []string            `yaml:"enum,flow,omitempty"`
+	Cardinality    *profileCardinality `yaml:"cardinality,omitempty"`
+	Range          int                 `yaml:"range,omitempty"`
+	OmitPercentage int                 `yaml:"omit_percentage,omitempty"`
+}
+
+type profileCardinality struct {
+	Distinct uint64 `yaml:"distinct"`
+}
+
+type profileBucket struct {
+	Key         interface{} `json:"key"`
+	KeyAsString string      `json:"key_as_string"`
+	DocCount    uint64      `json:"doc_count"`
+}
+
+type profileAggregation struct {
+	Buckets          []profileBucket `json:"buckets"`
+	SumOtherDocCount uint64          `json:"sum_other_doc_count"`
+	DocCount         uint64          `json:"doc_count"`
+	Value            float64         `json:"value"`
+	Count            uint64          `json:"count"`
+	Min              *float64        `json:"min"`
+	Max              *float64        `json:"max"`
+}
+
+type profileSearchResponse struct {
+	Hits struct {
+		Total struct {
+			Value uint64 `json:"value"`
+		} `json:"total"`
+	} `json:"hits"`
+	Aggregations map[string]profileAggregation `json:"aggregations"`
+}
+
+type profileMapping struct {
+	Type       string                    `json:"type"`
+	Properties map[string]profileMapping `json:"properties"`
+}
+
+// ProfileIndex queries the index, or the indices matching the pattern, of the Elasticsearch cluster at
+// elasticsearchURL for the distribution of the values of its fields: terms and cardinality aggregations
+// for keyword-like fields, stats aggregations for numeric ones, and a date histogram of timeField for the
+// event rate. Credentials for basic auth can be provided as part of the url.
+func ProfileIndex(ctx context.Context, elasticsearchURL, index, timeField string) (IndexProfile, error) {
+	var mappings map[string]struct {
+		Mappings profileMapping `json:"mappings"`
+	}
+
+	if err := elasticsearchRequest(ctx, elasticsearchURL, http.MethodGet, path.Join(index, "_mapping"), nil, &mappings); err != nil {
+		return IndexProfile{}, fmt.Errorf("index %s: cannot get the mapping: %w", index, err)
+	}
+
+	// the indices matching a pattern are sorted for the type of the fields to be deterministic
+	indices := make([]string, 0, len(mappings))
+	for name := range mappings {
+		indices = append(indices, name)
+	}
+
+	sort.Strings(indices)
+
+	types := make(map[string]string)
+	for _, name := range indices {
+		flattenProfileMapping("", mappings[name].Mappings.Properties, types)
+	}
+
+	profile := IndexProfile{Index: index}
+	for name, fieldType := range types {
+		if profileFieldKind(fieldType) != profileKindNone {
+			profile.Fields = append(profile.Fields, FieldProfile{Name: name, Type: fieldType})
+		}
+	}
+
+	if len(profile.Fields) == 0 {
+		return IndexProfile{}, fmt.Errorf("index %s: no aggregatable fields found in the mapping", index)
+	}
+
+	sort.Slice(profile.Fields, func(i, j int) bool { return profile.Fields[i].Name < profile.Fields[j].Name })
+
+	aggs := make(map[string]interface{})
+	for i, field := range profile.Fields {
+		aggs[fmt.Sprintf("missing_%d", i)] = map[string]interface{}{"missing": map[string]interface{}{"field": field.Name}}
+		switch profileFieldKind(field.Type) {
+		case profileKindTerms:
+			aggs[fmt.Sprintf("terms_%d", i)] = map[string]interface{}{"terms": map[string]interface{}{"field": field.Name, "size": maxProfileEnum}}
+			aggs[fmt.Sprintf("cardinality_%d", i)] = map[string]interface{}{"cardinality": map[string]interface{}{"field": field.Name}}
+		case profileKindStats:
+			aggs[fmt.Sprintf("stats_%d", i)] = map[string]interface{}{"stats": map[string]interface{}{"field": field.Name}}
+			aggs[fmt.Sprintf("cardinality_%d", i)] = map[string]interface{}{"cardinality": map[string]interface{}{"field": field.Name}}
+		}
+	}
+
+	timeFieldType := types[timeField]
+	if timeFieldType == "date" || timeFieldType == "date_nanos" {
+		aggs["time_range"] = map[string]interface{}{"stats": map[string]interface{}{"field": timeField}}
+	}
+
+	var resp profileSearchResponse
+	body := map[string]interface{}{"size": 0, "track_total_hits": true, "aggs": aggs}
+	if err := elasticsearchRequest(ctx, elasticsearchURL, http.MethodPost, path.Join(index, "_search"), body, &resp); err != nil {
+		return IndexProfile{}, fmt.Errorf("index %s: cannot aggregate the fields: %w", index, err)
+	}
+
+	profile.Docs = resp.Hits.Total.Value
+	for i := range profile.Fields {
+		field := &profile.Fields[i]
+		field.Missing = resp.Aggregations[fmt.Sprintf("missing_%d", i)].DocCount
+		field.Cardinality = uint64(resp.Aggregations[fmt.Sprintf("cardinality_%d", i)].Value)
+
+		if stats, ok := resp.Aggregations[fmt.Sprintf("stats_%d", i)]; ok {
+			field.Min, field.Max = stats.Min, stats.Max
+		}
+
+		terms, ok := resp.Aggregations[fmt.Sprintf("terms_%d", i)]
+		if !ok {
+			continue
+		}
+
+		field.TermsComplete = terms.SumOtherDocCount == 0
+		for _, bucket := range terms.Buckets {
+			value := bucket.KeyAsString
+			if value == "" {
+				value = fmt.Sprint(bucket.Key)
+			}
+
+			field.Terms = append(field.Terms, TermCount{Value: value, Count: bucket.DocCount})
+		}
+	}
+
+	timeRange, ok := resp.Aggregations["time_range"]
+	if !ok || timeRange.Min == nil || timeRange.Max == nil {
+		return profile, nil
+	}
+
+	profile.From = time.UnixMilli(int64(*timeRange.Min)).UTC()
+	profile.To = time.UnixMilli(int64(*timeRange.Max)).UTC()
+	span := profile.To.Sub(profile.From)
+	if span <= 0 {
+		return profile, nil
+	}
+
+	profile.AvgRate = float64(timeRange.Count) / span.Seconds()
+
+	interval := (span / profileHistogramBuckets).Truncate(time.Second)
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	var histogramResp profileSearchResponse
+	body = map[string]interface{}{
+		"size": 0,
+		"aggs": map[string]interface{}{
+			"rate": map[string]interface{}{"date_histogram": map[string]interface{}{"field": timeField, "fixed_interval": strconv.Itoa(int(interval.Seconds())) + "s"}},
+		},
+	}
+
+	if err := elasticsearchRequest(ctx, elasticsearchURL, http.MethodPost, path.Join(index, "_search"), body, &histogramResp); err != nil {
+		return IndexProfile{}, fmt.Errorf("index %s: cannot get the date histogram of %s: %w", index, timeField, err)
+	}
+
+	for _, bucket := range histogramResp.Aggregations["rate"].Buckets {
+		if rate := float64(bucket.DocCount) / interval.Seconds(); rate > profile.PeakRate {
+			profile.PeakRate = rate
+		}
+	}
+
+	return profile, nil
+}
+
+const (
+	profileKindNone = iota
+	profileKindTerms
+	profileKindStats
+	profileKindMissing
+)
+
+// profileFieldKind returns how the values of a field of the type are profiled: dates and geo points are profiled
+// for the missing values only, the types not aggregatable, like text, are not profiled.
+func profileFieldKind(fieldType string) int {
+	switch fieldType {
+	case "keyword", "constant_keyword", "wildcard", "ip", "boolean":
+		return profileKindTerms
+	case "long", "integer", "short", "byte", "unsigned_long", "double", "float", "half_float", "scaled_float":
+		return profileKindStats
+	case "date", "date_nanos", "geo_point":
+		return profileKindMissing
+	}
+
+	return profileKindNone
+}
+
+// flattenProfileMapping collects the types of the fields in properties by their dotted name, leaving the multi-fields out.
+func flattenProfileMapping(prefix string, properties map[string]profileMapping, types map[string]string) {
+	for name, mapping := range properties {
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		if len(mapping.Properties) > 0 {
+			flattenProfileMapping(name, mapping.Properties, types)
+			continue
+		}
+
+		if _, ok := types[name]; !ok && mapping.Type != "" && mapping.Type != "object" && mapping.Type != "nested" && mapping.Type != "alias" {
+			types[name] = mapping.Type
+		}
+	}
+}
+
+// WriteConfig writes the config reproducing the profile to w, as YAML: keyword fields with a low cardinality are
+// weighted enums, the others have the cardinality of the index, numeric fields the range of their values and
+// the fields missing from some documents an omit_percentage. The event rate is reported in the leading comments.
+func (p IndexProfile) WriteConfig(w io.Writer) error {
+	fmt.Fprintf(w, "# profile of index %s: %d documents", p.Index, p.Docs)
+	if !p.From.IsZero() {
+		fmt.Fprintf(w, " from %s to %s", p.From.Format(time.RFC3339), p.To.Format(time.RFC3339))
+	}
+
+	fmt.Fprintln(w)
+	if p.AvgRate > 0 {
+		fmt.Fprintf(w, "# event rate: %s/s on average, %s/s at peak\n", formatProfileRate(p.AvgRate), formatProfileRate(p.PeakRate))
+	}
+
+	entries := make([]profileConfigEntry, 0, len(p.Fields))
+	for _, field := range p.Fields {
+		if entry := field.configEntry(p.Docs); !entry.isEmpty() {
+			entries = append(entries, entry)
+		}
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(entries); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// configEntry returns the config entry reproducing the profile of the field in an index of docs documents.
+func (f FieldProfile) configEntry(docs uint64) profileConfigEntry {
+	entry := profileConfigEntry{Name: f.Name}
+	if docs == 0 {
+		return entry
+	}
+
+	entry.OmitPercentage = int(math.Round(100 * float64(f.Missing) / float64(docs)))
+	present := docs - f.Missing
+	if present == 0 {
+		return entry
+	}
+
+	switch profileFieldKind(f.Type) {
+	case profileKindTerms:
+		if f.Type == "boolean" {
+			break
+		}
+
+		if f.Type != "ip" && f.TermsComplete && len(f.Terms) == 1 {
+			entry.Value = f.Terms[0].Value
+			break
+		}
+
+		if f.Type != "ip" && f.TermsComplete && len(f.Terms) > 0 {
+			entry.Enum = weightedEnum(f.Terms)
+			break
+		}
+
+		if f.Cardinality > 0 && f.Cardinality < present {
+			entry.Cardinality = &profileCardinality{Distinct: f.Cardinality}
+		}
+	case profileKindStats:
+		if f.Min == nil || f.Max == nil {
+			break
+		}
+
+		if *f.Min == *f.Max {
+			entry.Value = *f.Min
+			break
+		}
+
+		if *f.Min >= 0 {
+			entry.Range = int(math.Floor(*f.Max)) + 1
+		}
+
+		if f.Cardinality > 0 && f.Cardinality < present {
+			entry.Cardinality = &profileCardinality{Distinct: f.Cardinality}
+		}
+	}
+
+	return entry
+}
+
+// isEmpty tells whether the entry sets nothing but the name of the field.
+func (e profileConfigEntry) isEmpty() bool {
+	return e.Value == nil && len(e.Enum) == 0 && e.Cardinality == nil && e.Range == 0 && e.OmitPercentage == 0
+}
+
+// weightedEnum returns the values of terms repeated in proportion to their count, each at least once.
+func weightedEnum(terms []TermCount) []string {
+	var total uint64
+	for _, term := range terms {
+		total += term.Count
+	}
+
+	var enum []string
+	for _, term := range terms {
+		n := int(math.Round(profileEnumEntries * float64(term.Count) / float64(total)))
+		if n < 1 {
+			n = 1
+		}
+
+		for i := 0; i < n; i++ {
+			enum = append(enum, term.Value)
+		}
+	}
+
+	return enum
+}
+
+// formatProfileRate formats an events per second rate with up to 2 decimal places.
+func formatProfileRate(rate float64) string {
+	return strconv.FormatFloat(math.Round(rate*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,82 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// WithProgress writes to w a line with the progress of the run every interval, and one with its totals at its end.
+func WithProgress(w io.Writer, interval time.Duration) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.progressWriter = w
+		gc.progressInterval = interval
+	}
+}
+
+// progressReporter writes the progress lines of a run towards totSize, zero if the size is not known.
+type progressReporter struct {
+	w          io.Writer
+	interval   time.Duration
+	totSize    uint64
+	start      time.Time
+	lastReport time.Time
+}
+
+// newProgressReporter returns the reporter of the progress of the run, nil if not enabled.
+func (gc GeneratorCorpus) newProgressReporter(totSize uint64) *progressReporter {
+	if gc.progressWriter == nil || gc.progressInterval <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	return &progressReporter{
+		w:          gc.progressWriter,
+		interval:   gc.progressInterval,
+		totSize:    totSize,
+		start:      now,
+		lastReport: now,
+	}
+}
+
+// update writes the progress line if interval elapsed since the last one.
+func (p *progressReporter) update(summary *RunSummary) {
+	if p == nil {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(p.lastReport) < p.interval {
+		return
+	}
+
+	p.lastReport = now
+
+	line := fmt.Sprintf("progress: %d events, %s, %.0f events/s", summary.Events, humanize.Bytes(summary.Bytes), float64(summary.Events)/now.Sub(p.start).Seconds())
+	if p.totSize > 0 {
+		line += fmt.Sprintf(", %.0f%%", 100*float64(summary.Bytes)/float64(p.totSize))
+	}
+
+	fmt.Fprintln(p.w, line)
+}
+
+// reportEnd writes the line with the totals of the completed summary.
+func (gc GeneratorCorpus) reportEnd(summary *RunSummary) {
+	if gc.progressWriter == nil || gc.progressInterval <= 0 {
+		return
+	}
+
+	status := "done"
+	if summary.Interrupted {
+		status = "interrupted"
+	}
+
+	fmt.Fprintf(gc.progressWriter, "progress: %s, %d events, %s in %s, %.0f events/s\n", status, summary.Events, humanize.Bytes(summary.Bytes),
+		time.Duration(summary.DurationSeconds*float64(time.Second)).Round(time.Millisecond), summary.EventsPerSecond)
+}
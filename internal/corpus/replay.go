@@ -0,0 +1,232 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// replayPayloadFilename computes the filename of the replayed corpus.
+// To provide unique names the name of the replayed corpus is prepended with current timestamp.
+func (gc GeneratorCorpus) replayPayloadFilename(corpusPath string) string {
+	return fmt.Sprintf("%d-replay-%s", gc.timestamp(), sanitizeFilename(path.Base(corpusPath)))
+}
+
+// Replay re-emits the events of the NDJSON corpus at corpusPath with the RFC3339 values of dateFields
+// shifted by the same offset, so that the first event is at start. Bulk request actions are re-emitted as they are.
+// When ctx is done the replay stops and the partial corpus filename is returned alongside ErrInterrupted.
+func (gc GeneratorCorpus) Replay(ctx context.Context, corpusPath string, start time.Time, dateFields []string) (string, error) {
+	f, err := os.Open(corpusPath)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	sink, err := gc.openSink(ctx, gc.replayPayloadFilename(corpusPath))
+	if err != nil {
+		return "", err
+	}
+
+	payloadFilename := sink.Name()
+
+	summary := gc.newRunSummary(map[string]string{
+		"corpus_path": corpusPath,
+		"start":       start.Format(time.RFC3339),
+		"date_fields": strings.Join(dateFields, ","),
+	})
+
+	err = gc.replayEvents(ctx, bufio.NewReader(f), start, dateFields, sink, &summary)
+	if closeErr := closeSink(sink, err); closeErr != nil && err == nil {
+		err = closeErr
+	}
+
+	if err != nil && !errors.Is(err, ErrInterrupted) {
+		return "", err
+	}
+
+	if endErr := gc.endRun(&summary, payloadFilename, err != nil, nil); endErr != nil {
+		return payloadFilename, multierr.Append(err, endErr)
+	}
+
+	return payloadFilename, err
+}
+
+func (gc GeneratorCorpus) replayEvents(ctx context.Context, r *bufio.Reader, start time.Time, dateFields []string, sink Sink, summary *RunSummary) error {
+	var offset time.Duration
+	var offsetSet bool
+	var action []byte
+
+	progress := gc.newProgressReporter(0)
+	begin := time.Now()
+	for lineNumber := 1; ; lineNumber++ {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInterrupted, err)
+		}
+
+		line, readErr := r.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			if readErr == io.EOF {
+				return nil
+			}
+
+			continue
+		}
+
+		var doc map[string]interface{}
+		if err := json.Unmarshal(line, &doc); err != nil {
+			return fmt.Errorf("line %d: not a JSON object: %v", lineNumber, err)
+		}
+
+		if isBulkAction(doc) {
+			action = append(action[:0], line...)
+			continue
+		}
+
+		// the values are replaced in a single pass, for a shifted value not to be shifted again
+		var replacements []string
+		for _, dateField := range dateFields {
+			value, ok := lookupPath(doc, dateField).(string)
+			if !ok {
+				continue
+			}
+
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return fmt.Errorf("line %d: field %s: %v", lineNumber, dateField, err)
+			}
+
+			if !offsetSet {
+				offset = start.Sub(t)
+				offsetSet = true
+			}
+
+			replacements = append(replacements, quoteJSON(value), quoteJSON(t.Add(offset).Format(rfc3339Layout(value))))
+		}
+
+		if len(replacements) > 0 {
+			line = []byte(strings.NewReplacer(replacements...).Replace(string(line)))
+		}
+
+		if gc.rate > 0 {
+			due, err := gc.waitNextEvent(ctx, begin, summary.Events, sink)
+			if err != nil {
+				return err
+			}
+
+			if !due {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				return nil
+			}
+		}
+
+		var payload []byte
+		if events, ok := sink.(eventSink); ok {
+			payload = line
+			if err := events.WriteEvent(payload); err != nil {
+				return err
+			}
+		} else {
+			if len(action) > 0 {
+				payload = append(append(payload, action...), '\n')
+			}
+
+			payload = append(append(payload, line...), '\n')
+			if _, err := sink.Write(payload); err != nil {
+				return err
+			}
+		}
+
+		action = action[:0]
+		summary.Events += 1
+		summary.Bytes += uint64(len(payload))
+		progress.update(summary)
+
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+// isBulkAction tells whether doc is the action line of a bulk request, like { "create" : { "_index": "logs" } }.
+func isBulkAction(doc map[string]interface{}) bool {
+	if len(doc) != 1 {
+		return false
+	}
+
+	for _, action := range []string{"create", "index"} {
+		if _, ok := doc[action].(map[string]interface{}); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookupPath returns the value of the dotted path in doc, be it made of nested objects or of keys with dots.
+func lookupPath(doc map[string]interface{}, dottedPath string) interface{} {
+	if value, ok := doc[dottedPath]; ok {
+		return value
+	}
+
+	for i := 0; i < len(dottedPath); i++ {
+		if dottedPath[i] != '.' {
+			continue
+		}
+
+		if nested, ok := doc[dottedPath[:i]].(map[string]interface{}); ok {
+			if value := lookupPath(nested, dottedPath[i+1:]); value != nil {
+				return value
+			}
+		}
+	}
+
+	return nil
+}
+
+// rfc3339Layout returns the RFC3339 layout of value, with as many fractional second digits as value.
+func rfc3339Layout(value string) string {
+	const secondsEnd = len("2006-01-02T15:04:05")
+	layout := "2006-01-02T15:04:05"
+	if len(value) > secondsEnd && value[secondsEnd] == '.' {
+		digits := 0
+		for _, c := range value[secondsEnd+1:] {
+			if c < '0' || c > '9' {
+				break
+			}
+
+			digits++
+		}
+
+		layout += "." + strings.Repeat("0", digits)
+	}
+
+	return layout + "Z07:00"
+}
+
+func quoteJSON(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
@@ -0,0 +1,634 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// maxSampleEnum is the count of distinct values of a string field, repeated across the samples, inferred as an enum
+const maxSampleEnum = 10
+
+// sampleGenerators are the realistic generators inferred for the string fields whose values all match their pattern
+var sampleGenerators = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"uuid", regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)},
+	{"mac_address", regexp.MustCompile(`^[0-9a-fA-F]{2}([:-][0-9a-fA-F]{2}){5}$`)},
+	{"email", regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[a-zA-Z]+$`)},
+	{"url", regexp.MustCompile(`^https?://\S+$`)},
+	{"user_agent", regexp.MustCompile(`^Mozilla/\d`)},
+}
+
+// SampleAssets are the structured template, the fields definition and the config inferred from sample documents.
+type SampleAssets struct {
+	Template         []byte
+	FieldsDefinition []byte
+	Config           []byte
+}
+
+// sampleObject is a JSON object of a sample, keeping the order of its members.
+type sampleObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// sampleNode collects the values found at a path of the sample documents.
+type sampleNode struct {
+	path string
+	// objects is the count of the values that are objects, keys are their members in order of first appearance
+	objects  int
+	keys     []string
+	children map[string]*sampleNode
+	parents  []*sampleNode
+	// seen is the count of the objects the node is a member of
+	seen  int
+	nulls int
+	// values are the scalar values, including the ones of arrays
+	values    []interface{}
+	arrays    []int
+	geoPoints int
+	// nested is the first array of objects or of arrays, copied as is
+	nested []interface{}
+}
+
+// sampleInference merges the sample documents into the nodes of their paths.
+type sampleInference struct {
+	root  *sampleNode
+	nodes map[string]*sampleNode
+	order []*sampleNode
+}
+
+// sampleField is an entry of the inferred fields definition.
+type sampleField struct {
+	Name    string `yaml:"name"`
+	Type    string `yaml:"type"`
+	Example string `yaml:"example,omitempty"`
+}
+
+// sampleConfigEntry is an entry of the inferred config.
+type sampleConfigEntry struct {
+	Name           string      `yaml:"name"`
+	Value          interface{} `yaml:"value,omitempty"`
+	Enum           []string    `yaml:"enum,omitempty"`
+	Range          int         `yaml:"range,omitempty"`
+	Precision      *int        `yaml:"precision,omitempty"`
+	Generator      string      `yaml:"generator,omitempty"`
+	IPv6Percentage int         `yaml:"ipv6_percentage,omitempty"`
+	GeoFormat      string      `yaml:"geo_format,omitempty"`
+	ArrayMin       int         `yaml:"array_min,omitempty"`
+	ArrayMax       int         `yaml:"array_max,omitempty"`
+	NullPercentage int         `yaml:"null_percentage,omitempty"`
+	OmitPercentage int         `yaml:"omit_percentage,omitempty"`
+}
+
+// InferFromSamples infers the structured template, the fields definition and the config generating documents similar
+// to the ones in the files at samplePaths: each file holds a JSON document, a JSON array of documents, or a stream of
+// them like NDJSON. The type and the shape of the values of each field are inferred from the values across the samples.
+func InferFromSamples(fs afero.Fs, samplePaths []string) (SampleAssets, error) {
+	inference := &sampleInference{
+		root:  &sampleNode{children: make(map[string]*sampleNode)},
+		nodes: make(map[string]*sampleNode),
+	}
+
+	for _, samplePath := range samplePaths {
+		if err := inference.addFile(fs, samplePath); err != nil {
+			return SampleAssets{}, err
+		}
+	}
+
+	if inference.root.objects == 0 {
+		return SampleAssets{}, errors.New("no sample documents found")
+	}
+
+	var fieldsDefinition []sampleField
+	configEntries := make([]sampleConfigEntry, 0)
+	for _, node := range inference.order {
+		if node.objects > 0 {
+			if len(node.values) > 0 || node.geoPoints > 0 || len(node.arrays) > 0 {
+				return SampleAssets{}, fmt.Errorf("field %s: both objects and values found in the samples", node.path)
+			}
+
+			continue
+		}
+
+		if strings.ContainsAny(node.path, "{} \t\r\n") {
+			return SampleAssets{}, fmt.Errorf("field %s: cannot be referenced by a template placeholder", node.path)
+		}
+
+		field, entry := node.infer()
+		fieldsDefinition = append(fieldsDefinition, field)
+		if !entry.isEmpty() {
+			configEntries = append(configEntries, entry)
+		}
+	}
+
+	var template bytes.Buffer
+	writeSampleTemplate(&template, inference.root, "")
+	template.WriteByte('\n')
+
+	fieldsDefinitionContent, err := yaml.Marshal(fieldsDefinition)
+	if err != nil {
+		return SampleAssets{}, err
+	}
+
+	configContent, err := yaml.Marshal(configEntries)
+	if err != nil {
+		return SampleAssets{}, err
+	}
+
+	return SampleAssets{
+		Template:         template.Bytes(),
+		FieldsDefinition: fieldsDefinitionContent,
+		Config:           configContent,
+	}, nil
+}
+
+// Write writes the assets to dir, named after name like name.template.json, returning the paths of the template,
+// of the fields definition and of the config.
+func (a SampleAssets) Write(fs afero.Fs, dir, name string) (string, string, string, error) {
+	if err := fs.MkdirAll(dir, 0755); err != nil {
+		return "", "", "", err
+	}
+
+	templatePath := filepath.Join(dir, name+".template.json")
+	fieldsDefinitionPath := filepath.Join(dir, name+".fields.yml")
+	configPath := filepath.Join(dir, name+".conf.yml")
+
+	if err := afero.WriteFile(fs, templatePath, a.Template, 0644); err != nil {
+		return "", "", "", err
+	}
+
+	if err := afero.WriteFile(fs, fieldsDefinitionPath, a.FieldsDefinition, 0644); err != nil {
+		return "", "", "", err
+	}
+
+	if err := afero.WriteFile(fs, configPath, a.Config, 0644); err != nil {
+		return "", "", "", err
+	}
+
+	return templatePath, fieldsDefinitionPath, configPath, nil
+}
+
+// isEmpty tells whether the entry sets nothing but the name of the field.
+func (e sampleConfigEntry) isEmpty() bool {
+	return e.Value == nil && len(e.Enum) == 0 && e.Range == 0 && e.Precision == nil && e.Generator == "" && e.IPv6Percentage == 0 &&
+		e.GeoFormat == "" && e.ArrayMax == 0 && e.NullPercentage == 0 && e.OmitPercentage == 0
+}
+
+// addFile merges the documents of the sample file at samplePath.
+func (s *sampleInference) addFile(fs afero.Fs, samplePath string) error {
+	f, err := fs.Open(samplePath)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+	for i := 1; dec.More(); i++ {
+		doc, err := decodeSampleValue(dec)
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s: document %d: %w", samplePath, i, err)
+		}
+
+		docs, ok := doc.([]interface{})
+		if !ok {
+			docs = []interface{}{doc}
+		}
+
+		for _, doc := range docs {
+			if _, ok := doc.(sampleObject); !ok {
+				return fmt.Errorf("%s: document %d: not a JSON object", samplePath, i)
+			}
+
+			s.add(s.root, doc)
+		}
+	}
+
+	return nil
+}
+
+// decodeSampleValue decodes the next JSON value, keeping the order of the members of the objects.
+func decodeSampleValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+
+	switch delim {
+	case '{':
+		obj := sampleObject{values: make(map[string]interface{})}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			key, _ := keyTok.(string)
+			value, err := decodeSampleValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			if _, ok := obj.values[key]; !ok {
+				obj.keys = append(obj.keys, key)
+			}
+
+			obj.values[key] = value
+		}
+
+		_, err = dec.Token()
+		return obj, err
+	case '[':
+		arr := make([]interface{}, 0)
+		for dec.More() {
+			value, err := decodeSampleValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			arr = append(arr, value)
+		}
+
+		_, err = dec.Token()
+		return arr, err
+	}
+
+	return nil, fmt.Errorf("unexpected %s", delim)
+}
+
+// add merges the value found at the path of node.
+func (s *sampleInference) add(node *sampleNode, value interface{}) {
+	node.seen++
+
+	switch v := value.(type) {
+	case nil:
+		node.nulls++
+	case sampleObject:
+		if isSampleGeoPoint(v) {
+			node.geoPoints++
+			return
+		}
+
+		node.objects++
+		for _, key := range v.keys {
+			s.add(s.child(node, key), v.values[key])
+		}
+	case []interface{}:
+		node.arrays = append(node.arrays, len(v))
+		for _, elem := range v {
+			switch elem.(type) {
+			case nil:
+			case sampleObject, []interface{}:
+				if node.nested == nil {
+					node.nested = v
+				}
+			default:
+				node.values = append(node.values, elem)
+			}
+		}
+	default:
+		node.values = append(node.values, v)
+	}
+}
+
+// child returns the node of the member key of the objects of node: members with the same path, like the ones
+// of dotted keys and of nested objects, share the node.
+func (s *sampleInference) child(node *sampleNode, key string) *sampleNode {
+	if child, ok := node.children[key]; ok {
+		return child
+	}
+
+	path := key
+	if node.path != "" {
+		path = node.path + "." + key
+	}
+
+	child, ok := s.nodes[path]
+	if !ok {
+		child = &sampleNode{path: path, children: make(map[string]*sampleNode)}
+		s.nodes[path] = child
+		s.order = append(s.order, child)
+	}
+
+	child.parents = append(child.parents, node)
+	node.children[key] = child
+	node.keys = append(node.keys, key)
+	return child
+}
+
+// presence returns the share of the documents with count values at the path of the node: the objects the node
+// is a member of can be missing or null themselves.
+func (n *sampleNode) presence(count int) float64 {
+	if len(n.parents) == 0 {
+		return 1
+	}
+
+	var parentObjects int
+	for _, parent := range n.parents {
+		parentObjects += parent.objects
+	}
+
+	return float64(count) / float64(parentObjects) * n.parents[0].presence(n.parents[0].objects)
+}
+
+// isSampleGeoPoint tells whether obj is a geo point, an object with the lat and lon numbers only.
+func isSampleGeoPoint(obj sampleObject) bool {
+	if len(obj.keys) != 2 {
+		return false
+	}
+
+	_, latOk := obj.values["lat"].(json.Number)
+	_, lonOk := obj.values["lon"].(json.Number)
+	return latOk && lonOk
+}
+
+// infer returns the fields definition entry and the config entry of the values of a leaf node.
+func (n *sampleNode) infer() (sampleField, sampleConfigEntry) {
+	field := sampleField{Name: n.path}
+	entry := sampleConfigEntry{Name: n.path}
+
+	entry.NullPercentage = percentage(n.nulls, n.seen)
+	entry.OmitPercentage = int(math.Round(100 * (1 - n.presence(n.seen))))
+	if entry.NullPercentage+entry.OmitPercentage > 100 {
+		entry.NullPercentage = 100 - entry.OmitPercentage
+	}
+
+	if n.nested != nil {
+		field.Type = genlib.FieldTypeKeyword
+		entry.Value = plainSampleValue(n.nested)
+		return field, entry
+	}
+
+	if n.geoPoints > 0 {
+		field.Type = genlib.FieldTypeGeoPoint
+		entry.GeoFormat = genlib.GeoFormatObject
+		return field, entry
+	}
+
+	if len(n.arrays) > 0 {
+		entry.ArrayMin, entry.ArrayMax = n.arrays[0], n.arrays[0]
+		for _, l := range n.arrays {
+			if l < entry.ArrayMin {
+				entry.ArrayMin = l
+			}
+
+			if l > entry.ArrayMax {
+				entry.ArrayMax = l
+			}
+		}
+
+		if entry.ArrayMax == 0 {
+			entry.ArrayMin = 0
+		}
+	}
+
+	field.Type = inferSampleType(n.values)
+	if len(n.values) == 0 {
+		entry.NullPercentage = 100 - entry.OmitPercentage
+		return field, entry
+	}
+
+	distinct := distinctSampleValues(n.values)
+	if len(n.values) > 1 && len(distinct) == 1 && len(n.arrays) == 0 && field.Type != genlib.FieldTypeDate {
+		entry.Value = plainSampleValue(n.values[0])
+		return field, entry
+	}
+
+	switch field.Type {
+	case genlib.FieldTypeKeyword:
+		if len(distinct) <= maxSampleEnum && len(distinct) < len(n.values) {
+			entry.Enum = distinct
+			break
+		}
+
+		if entry.Generator = inferSampleGenerator(n.values); entry.Generator == "" {
+			field.Example = fmt.Sprint(n.values[0])
+		}
+	case genlib.FieldTypeLong:
+		entry.Range = sampleRange(n.values)
+	case genlib.FieldTypeDouble:
+		entry.Range = sampleRange(n.values)
+		if precision := samplePrecision(n.values); precision > 0 {
+			entry.Precision = &precision
+		}
+	case genlib.FieldTypeIP:
+		var ipv6 int
+		for _, v := range n.values {
+			if net.ParseIP(v.(string)).To4() == nil {
+				ipv6++
+			}
+		}
+
+		entry.IPv6Percentage = percentage(ipv6, len(n.values))
+	}
+
+	return field, entry
+}
+
+// inferSampleType returns the field type of values: numbers mixed with strings or booleans are keywords,
+// and integers mixed with floating point numbers are doubles.
+func inferSampleType(values []interface{}) string {
+	var bools, ints, floats, strs, dates, ips int
+	for _, v := range values {
+		switch v := v.(type) {
+		case bool:
+			bools++
+		case json.Number:
+			if strings.ContainsAny(string(v), ".eE") {
+				floats++
+			} else {
+				ints++
+			}
+		case string:
+			strs++
+			if _, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				dates++
+			} else if net.ParseIP(v) != nil {
+				ips++
+			}
+		}
+	}
+
+	switch n := len(values); {
+	case n == 0:
+		return genlib.FieldTypeKeyword
+	case bools == n:
+		return genlib.FieldTypeBool
+	case ints == n:
+		return genlib.FieldTypeLong
+	case ints+floats == n:
+		return genlib.FieldTypeDouble
+	case dates == n:
+		return genlib.FieldTypeDate
+	case ips == n:
+		return genlib.FieldTypeIP
+	}
+
+	return genlib.FieldTypeKeyword
+}
+
+// inferSampleGenerator returns the realistic generator whose pattern all the values match, if any.
+func inferSampleGenerator(values []interface{}) string {
+	for _, generator := range sampleGenerators {
+		matches := true
+		for _, v := range values {
+			if !generator.pattern.MatchString(fmt.Sprint(v)) {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return generator.name
+		}
+	}
+
+	return ""
+}
+
+// distinctSampleValues returns the distinct values, as strings in order of first appearance.
+func distinctSampleValues(values []interface{}) []string {
+	seen := make(map[string]struct{})
+	var distinct []string
+	for _, v := range values {
+		s := fmt.Sprint(v)
+		if _, ok := seen[s]; ok {
+			continue
+		}
+
+		seen[s] = struct{}{}
+		distinct = append(distinct, s)
+	}
+
+	return distinct
+}
+
+// sampleRange returns the power of ten above the greatest of the numbers, zero if any is negative.
+func sampleRange(values []interface{}) int {
+	var max float64
+	for _, v := range values {
+		f, err := v.(json.Number).Float64()
+		if err != nil || f < 0 {
+			return 0
+		}
+
+		max = math.Max(max, f)
+	}
+
+	r := 1.
+	for r <= max && r < math.MaxInt32 {
+		r *= 10
+	}
+
+	return int(r)
+}
+
+// samplePrecision returns the greatest count of decimal places of the numbers.
+func samplePrecision(values []interface{}) int {
+	var precision int
+	for _, v := range values {
+		s := string(v.(json.Number))
+		if strings.ContainsAny(s, "eE") {
+			continue
+		}
+
+		if i := strings.IndexByte(s, '.'); i >= 0 && len(s)-i-1 > precision {
+			precision = len(s) - i - 1
+		}
+	}
+
+	return precision
+}
+
+// plainSampleValue converts a sample value to the plain values serialized into the config.
+func plainSampleValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case sampleObject:
+		m := make(map[string]interface{}, len(v.keys))
+		for _, key := range v.keys {
+			m[key] = plainSampleValue(v.values[key])
+		}
+
+		return m
+	case []interface{}:
+		arr := make([]interface{}, len(v))
+		for i, elem := range v {
+			arr[i] = plainSampleValue(elem)
+		}
+
+		return arr
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+
+		f, _ := v.Float64()
+		return f
+	}
+
+	return value
+}
+
+// writeSampleTemplate writes the structured template of node, indented by indent: objects are written member by
+// member, the leaves as a placeholder of their field.
+func writeSampleTemplate(buf *bytes.Buffer, node *sampleNode, indent string) {
+	if node.objects == 0 {
+		placeholder, _ := json.Marshal("{{." + node.path + "}}")
+		buf.Write(placeholder)
+		return
+	}
+
+	buf.WriteString("{\n")
+	for i, key := range node.keys {
+		encodedKey, _ := json.Marshal(key)
+		buf.WriteString(indent + "  ")
+		buf.Write(encodedKey)
+		buf.WriteString(": ")
+		writeSampleTemplate(buf, node.children[key], indent+"  ")
+		if i < len(node.keys)-1 {
+			buf.WriteByte(',')
+		}
+
+		buf.WriteByte('\n')
+	}
+
+	buf.WriteString(indent + "}")
+}
+
+// percentage returns part of total as a rounded percentage, zero if total is zero.
+func percentage(part, total int) int {
+	if total == 0 || part <= 0 {
+		return 0
+	}
+
+	return int(math.Round(100 * float64(part) / float64(total)))
+}
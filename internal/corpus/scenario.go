@@ -0,0 +1,157 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sink"
+	"github.com/elastic/go-ucfg/yaml"
+	"go.uber.org/multierr"
+)
+
+// Scenario describes the corpora of a workload, like the logs, the metrics and the traces of a benchmark,
+// generated in one run.
+type Scenario []ScenarioEntry
+
+// ScenarioEntry describes a corpus of a Scenario, generated from a template.
+type ScenarioEntry struct {
+	// Name identifies the entry in the errors, the template file name if not provided
+	Name         string `config:"name"`
+	Template     string `config:"template"`
+	TemplateType string `config:"template_type"`
+	Fields       string `config:"fields"`
+	Config       string `config:"config"`
+	TotSize      string `config:"tot_size"`
+	Events       uint64 `config:"events"`
+	// Output is the name of the file the corpus is written to in the corpora location, - to stream it to stdout or
+	// lumberjack://host:port to ship its events, the file named after the template and the time of the run if empty
+	Output string `config:"output"`
+}
+
+// Paths returns the paths of the files the entries of the scenario are generated from.
+func (s Scenario) Paths() []string {
+	var paths []string
+	for _, entry := range s {
+		paths = append(paths, entry.Template, entry.Fields)
+		if entry.Config != "" {
+			paths = append(paths, entry.Config)
+		}
+	}
+
+	return paths
+}
+
+// LoadScenario loads the scenario file at scenarioPath, a list of entries. The paths of the files of the entries
+// are relative to the directory of the scenario file, unless absolute.
+func LoadScenario(scenarioPath string) (Scenario, error) {
+	content, err := os.ReadFile(scenarioPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := yaml.NewConfig(content)
+	if err != nil {
+		return nil, fmt.Errorf("scenario %s: %w", scenarioPath, err)
+	}
+
+	var scenario Scenario
+	if err := cfg.Unpack(&scenario); err != nil {
+		return nil, fmt.Errorf("scenario %s: %w", scenarioPath, err)
+	}
+
+	if len(scenario) == 0 {
+		return nil, fmt.Errorf("scenario %s has no entry", scenarioPath)
+	}
+
+	dir := filepath.Dir(scenarioPath)
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+
+		return filepath.Join(dir, path)
+	}
+
+	var errs []error
+	outputs := make(map[string]string, len(scenario))
+	for i := range scenario {
+		entry := &scenario[i]
+		if entry.Name == "" {
+			entry.Name = filepath.Base(entry.Template)
+		}
+
+		if entry.TemplateType == "" {
+			entry.TemplateType = "placeholder"
+		}
+
+		entry.Template = resolve(entry.Template)
+		entry.Fields = resolve(entry.Fields)
+		entry.Config = resolve(entry.Config)
+
+		if err := entry.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("scenario %s: entry %d (%s): %w", scenarioPath, i, entry.Name, err))
+			continue
+		}
+
+		if entry.Output == "" || entry.Output == "-" || strings.HasPrefix(entry.Output, sink.LumberjackScheme) {
+			continue
+		}
+
+		if other, ok := outputs[entry.Output]; ok {
+			errs = append(errs, fmt.Errorf("scenario %s: entries %s and %s are written to the same output %s", scenarioPath, other, entry.Name, entry.Output))
+		}
+
+		outputs[entry.Output] = entry.Name
+	}
+
+	if len(errs) > 0 {
+		return nil, multierr.Combine(errs...)
+	}
+
+	return scenario, nil
+}
+
+func (e ScenarioEntry) validate() error {
+	var errs []error
+	if e.Template == "" {
+		errs = append(errs, errors.New("you must provide the template"))
+	}
+
+	if e.Fields == "" {
+		errs = append(errs, errors.New("you must provide the fields"))
+	}
+
+	switch e.TemplateType {
+	case "placeholder", "gotext", "structured":
+	default:
+		errs = append(errs, fmt.Errorf("template_type must be one of 'placeholder', 'gotext' or 'structured', got %s", e.TemplateType))
+	}
+
+	if e.TotSize == "" && e.Events == 0 {
+		errs = append(errs, errors.New("you must provide either tot_size or events"))
+	}
+
+	if e.TotSize != "" {
+		if _, err := humanize.ParseBytes(e.TotSize); err != nil {
+			errs = append(errs, fmt.Errorf("tot_size must be a size like 1GB, got %s", e.TotSize))
+		}
+	}
+
+	if strings.HasPrefix(e.Output, sink.LumberjackScheme) {
+		if _, err := sink.ParseLumberjackAddress(e.Output); err != nil {
+			errs = append(errs, fmt.Errorf("output: %w", err))
+		}
+	} else if strings.ContainsAny(e.Output, `/\`) {
+		errs = append(errs, fmt.Errorf("output must be a file name, - or lumberjack://host:port, got %s", e.Output))
+	}
+
+	return multierr.Combine(errs...)
+}
@@ -0,0 +1,101 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"context"
+	"errors"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sink"
+)
+
+// Sink is the destination the corpus is written to, see sink.Sink.
+type Sink = sink.Sink
+
+// eventSink is implemented by the sinks shipping the events rather than writing a corpus, see sink.EventWriter.
+type eventSink = sink.EventWriter
+
+// WithGzip compresses the corpus files in the corpora location with gzip, adding the .gz extension to their name.
+// It applies neither to the chunks of WithSplit nor to the sink of WithSink.
+func WithGzip() GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.gzip = true
+	}
+}
+
+// DefaultWriteBatchSize is the size of the batches the corpus files are written in, and of the buffers of
+// WithAsyncWrites, unless set otherwise with WithWriteBatchSize
+const DefaultWriteBatchSize = 1 << 20
+
+// WithAsyncWrites writes the corpus from a dedicated goroutine through a queue of buffers of the write batch size,
+// 1MB by default, so that a slow disk or network does not stall the generation until they are all full. It applies neither to the sinks shipping
+// the events one by one, like the lumberjack one, nor to the chunks of WithSplit.
+func WithAsyncWrites(buffers int) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.asyncBuffers = buffers
+	}
+}
+
+// WithWriteBatchSize writes the corpus files in batches of size bytes, instead of DefaultWriteBatchSize, and sets the
+// size of the buffers of WithAsyncWrites: smaller batches take less memory, at the cost of more system calls.
+// It does not apply to the sink of WithSink.
+func WithWriteBatchSize(size int) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.writeBatchSize = size
+	}
+}
+
+// batchSize returns the size of the batches of the corpus files and of the buffers of WithAsyncWrites.
+func (gc GeneratorCorpus) batchSize() int {
+	if gc.writeBatchSize > 0 {
+		return gc.writeBatchSize
+	}
+
+	return DefaultWriteBatchSize
+}
+
+// openSink opens the sink the corpus named filename is written to: the one provided by WithSink, if any,
+// otherwise the file named filename in the corpora location, or its chunks with WithSplit.
+func (gc GeneratorCorpus) openSink(ctx context.Context, filename string) (Sink, error) {
+	if gc.resume != nil && (gc.sink != nil || gc.maxFileSize > 0 || gc.maxEventsPerFile > 0 || gc.gzip || gc.rate > 0 || gc.soakOptions != nil || gc.audit != nil) {
+		return nil, errors.New("only the corpus files, neither split nor compressed, generated without rate, soak and audit, can be resumed")
+	}
+
+	s := gc.sink
+	switch {
+	case s != nil:
+	case gc.resume != nil:
+		s = sink.NewBuffered(sink.NewAppendFile(gc.fs, gc.location, int64(gc.resume.Bytes)), gc.batchSize())
+	case gc.maxFileSize > 0 || gc.maxEventsPerFile > 0:
+		s = newSplitSink(gc.fs, gc.location, gc.maxFileSize, gc.maxEventsPerFile, gc.batchSize())
+	case gc.gzip:
+		// the compressed blocks are written in small pieces, they are batched as well
+		s = sink.NewGzip(sink.NewBuffered(sink.NewFile(gc.fs, gc.location), gc.batchSize()))
+	default:
+		s = sink.NewBuffered(sink.NewFile(gc.fs, gc.location), gc.batchSize())
+	}
+
+	_, events := s.(eventSink)
+	_, split := s.(*splitSink)
+	if gc.asyncBuffers > 0 && !events && !split {
+		s = sink.NewAsync(s, gc.asyncBuffers, gc.batchSize())
+	}
+
+	if err := s.Open(ctx, filename); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// closeSink closes s once the corpus is generated, or aborts it if the generation failed with err, other than being
+// interrupted, so that the failed corpus is not uploaded as a whole, see sink.Aborter.
+func closeSink(s Sink, err error) error {
+	if err != nil && !errors.Is(err, ErrInterrupted) {
+		return sink.Abort(s)
+	}
+
+	return s.Close()
+}
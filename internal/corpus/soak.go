@@ -0,0 +1,212 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// soakGrowthSlack is the growth of the memory usage over the baseline that is never reported,
+// so that the noise of small heaps is not taken for a leak.
+const soakGrowthSlack = 16 << 20
+
+// ErrUnboundedGrowth is returned when the memory usage of a soak run grows over the baseline by more than its MaxGrowth.
+var ErrUnboundedGrowth = errors.New("unbounded memory growth")
+
+// SoakOptions are the settings of a soak run, see WithSoak.
+type SoakOptions struct {
+	// Duration of the run, zero to stop at the total size or events count, or when live mode ends
+	Duration time.Duration
+	// Interval between the samples of the memory usage
+	Interval time.Duration
+	// Warmup is the time after the start of the run the baseline is sampled at, once the caches and pools are filled
+	Warmup time.Duration
+	// MaxGrowth is the growth of the live heap, or of the RSS, over the baseline that fails the run: 0.5 for 50%
+	MaxGrowth float64
+}
+
+// WithSoak generates continuously for the duration of opts, sampling the memory usage every interval and writing it to w:
+// the run fails with ErrUnboundedGrowth if the live heap, or the RSS, grows by more than MaxGrowth over the baseline
+// sampled after the warmup. Without a total size the generation is not limited in size.
+func WithSoak(w io.Writer, opts SoakOptions) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.soakWriter = w
+		gc.soakOptions = &opts
+	}
+}
+
+// memSample is the memory usage of the process at a point of a soak run.
+type memSample struct {
+	heap uint64
+	// rss is zero where it cannot be read
+	rss        uint64
+	numGC      uint32
+	pauseTotal time.Duration
+}
+
+// soakMonitor samples the memory usage of a soak run and checks its growth.
+type soakMonitor struct {
+	w          io.Writer
+	opts       SoakOptions
+	start      time.Time
+	lastSample time.Time
+	baseline   *memSample
+	maxGrowth  float64
+}
+
+// newSoakMonitor returns the monitor of the soak run, nil if not enabled.
+func (gc GeneratorCorpus) newSoakMonitor() *soakMonitor {
+	if gc.soakOptions == nil {
+		return nil
+	}
+
+	now := time.Now()
+	return &soakMonitor{
+		w:          gc.soakWriter,
+		opts:       *gc.soakOptions,
+		start:      now,
+		lastSample: now,
+	}
+}
+
+// done tells whether the duration of the soak run elapsed.
+func (m *soakMonitor) done() bool {
+	return m != nil && m.opts.Duration > 0 && time.Since(m.start) >= m.opts.Duration
+}
+
+// check samples the memory usage if interval elapsed since the last sample: the first sample after the warmup
+// is the baseline, the following ones fail the run if grown by more than MaxGrowth over it.
+func (m *soakMonitor) check(events uint64) error {
+	if m == nil {
+		return nil
+	}
+
+	now := time.Now()
+	if now.Sub(m.lastSample) < m.opts.Interval {
+		return nil
+	}
+
+	m.lastSample = now
+	elapsed := now.Sub(m.start).Round(time.Second)
+	sample := sampleMemory()
+	if m.baseline == nil {
+		if now.Sub(m.start) < m.opts.Warmup {
+			m.report("soak: %s, %d events, warming up, heap %s, rss %s, %d GCs", elapsed, events, humanize.Bytes(sample.heap), humanize.Bytes(sample.rss), sample.numGC)
+			return nil
+		}
+
+		m.baseline = &sample
+		m.report("soak: %s, %d events, baseline heap %s, rss %s, %d GCs", elapsed, events, humanize.Bytes(sample.heap), humanize.Bytes(sample.rss), sample.numGC)
+		return nil
+	}
+
+	heapGrowth := growth(m.baseline.heap, sample.heap)
+	rssGrowth := growth(m.baseline.rss, sample.rss)
+	if heapGrowth > m.maxGrowth {
+		m.maxGrowth = heapGrowth
+	}
+
+	if rssGrowth > m.maxGrowth {
+		m.maxGrowth = rssGrowth
+	}
+
+	m.report("soak: %s, %d events, heap %s (%+.0f%%), rss %s (%+.0f%%), %d GCs, %s GC pauses", elapsed, events,
+		humanize.Bytes(sample.heap), 100*heapGrowth, humanize.Bytes(sample.rss), 100*rssGrowth,
+		sample.numGC-m.baseline.numGC, (sample.pauseTotal - m.baseline.pauseTotal).Round(time.Microsecond))
+
+	if exceedsGrowth(m.baseline.heap, sample.heap, m.opts.MaxGrowth) {
+		return fmt.Errorf("%w: heap grew from %s to %s after %s", ErrUnboundedGrowth, humanize.Bytes(m.baseline.heap), humanize.Bytes(sample.heap), elapsed)
+	}
+
+	if exceedsGrowth(m.baseline.rss, sample.rss, m.opts.MaxGrowth) {
+		return fmt.Errorf("%w: rss grew from %s to %s after %s", ErrUnboundedGrowth, humanize.Bytes(m.baseline.rss), humanize.Bytes(sample.rss), elapsed)
+	}
+
+	return nil
+}
+
+// end writes the line with the outcome of the soak run.
+func (m *soakMonitor) end() {
+	if m == nil {
+		return
+	}
+
+	if m.baseline == nil {
+		m.report("soak: ended within the warmup, memory growth not checked")
+		return
+	}
+
+	m.report("soak: ended after %s, max memory growth %+.0f%%", time.Since(m.start).Round(time.Second), 100*m.maxGrowth)
+}
+
+func (m *soakMonitor) report(format string, args ...interface{}) {
+	if m.w == nil {
+		return
+	}
+
+	fmt.Fprintf(m.w, format+"\n", args...)
+}
+
+// growth returns the growth of value over baseline, as a ratio.
+func growth(baseline, value uint64) float64 {
+	if baseline == 0 {
+		return 0
+	}
+
+	return float64(value)/float64(baseline) - 1
+}
+
+// exceedsGrowth tells whether value grew over baseline by more than maxGrowth, and by more than soakGrowthSlack.
+func exceedsGrowth(baseline, value uint64, maxGrowth float64) bool {
+	if baseline == 0 || value < baseline+soakGrowthSlack {
+		return false
+	}
+
+	return growth(baseline, value) > maxGrowth
+}
+
+// sampleMemory collects the garbage and samples the live heap, and the RSS where available.
+func sampleMemory() memSample {
+	runtime.GC()
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	return memSample{
+		heap:       stats.HeapAlloc,
+		rss:        readRSS(),
+		numGC:      stats.NumGC,
+		pauseTotal: time.Duration(stats.PauseTotalNs),
+	}
+}
+
+// readRSS returns the resident set size of the process from procfs, zero if not available.
+func readRSS() uint64 {
+	statm, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0
+	}
+
+	fields := bytes.Fields(statm)
+	if len(fields) < 2 {
+		return 0
+	}
+
+	pages, err := strconv.ParseUint(string(fields[1]), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return pages * uint64(os.Getpagesize())
+}
@@ -0,0 +1,237 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
+	"github.com/spf13/afero"
+	"go.uber.org/multierr"
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is the self-contained definition of a corpus, versioned as one file: the fields definition and the config
+// inline, the template and the output options.
+type Spec struct {
+	// Template is the path of the template
+	Template     string
+	TemplateType string
+	Fields       Fields
+	Config       Config
+	TotSize      string
+	Events       uint64
+	Format       string
+	// Output is the name of the file the corpus is written to in the corpora location, the file named after the
+	// template and the time of the run if empty
+	Output string
+	// Seed is the seed of the random generators, 0 for a random seed
+	Seed int64
+
+	// path is the path of the spec file, if loaded from one
+	path string
+}
+
+// specFile is the content of a spec file: the fields definition and the config are loaded from their own sections.
+type specFile struct {
+	Template     string    `yaml:"template"`
+	TemplateType string    `yaml:"template_type"`
+	Fields       yaml.Node `yaml:"fields"`
+	Config       yaml.Node `yaml:"config"`
+	TotSize      string    `yaml:"tot_size"`
+	Events       uint64    `yaml:"events"`
+	Format       string    `yaml:"format"`
+	Output       string    `yaml:"output"`
+	Seed         int64     `yaml:"seed"`
+}
+
+// LoadSpec loads the corpus spec file at specPath, its config is loaded with opts. The path of the template is
+// relative to the directory of the spec file, unless absolute.
+func LoadSpec(specPath string, opts ...config.LoadOption) (Spec, error) {
+	content, err := os.ReadFile(specPath)
+	if err != nil {
+		return Spec{}, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	var file specFile
+	if err := decoder.Decode(&file); err != nil {
+		return Spec{}, fmt.Errorf("spec %s: %w", specPath, err)
+	}
+
+	spec := Spec{
+		Template:     file.Template,
+		TemplateType: file.TemplateType,
+		TotSize:      file.TotSize,
+		Events:       file.Events,
+		Format:       file.Format,
+		Output:       file.Output,
+		Seed:         file.Seed,
+		path:         specPath,
+	}
+
+	if spec.TemplateType == "" {
+		spec.TemplateType = "placeholder"
+	}
+
+	if spec.Template != "" && !filepath.IsAbs(spec.Template) {
+		spec.Template = filepath.Join(filepath.Dir(specPath), spec.Template)
+	}
+
+	var errs []error
+	if fieldsContent := specSection(content, file.Fields); len(fieldsContent) > 0 {
+		if spec.Fields, err = fields.LoadFieldsFromYaml(fieldsContent); err != nil {
+			errs = append(errs, fmt.Errorf("fields: %w", err))
+		}
+	}
+
+	if configContent := specSection(content, file.Config); len(configContent) > 0 {
+		// the value files of the inline config are relative to the spec file, like the template
+		opts = append([]config.LoadOption{config.WithBaseDir(filepath.Dir(specPath))}, opts...)
+		if spec.Config, err = config.LoadConfigFromYaml(configContent, opts...); err != nil {
+			errs = append(errs, fmt.Errorf("config: %w", err))
+		}
+	}
+
+	if err := spec.validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return Spec{}, fmt.Errorf("spec %s: %w", specPath, multierr.Combine(errs...))
+	}
+
+	return spec, nil
+}
+
+// specSection returns the content of the section of node in the spec file content, with what precedes it blanked,
+// so that the lines of its errors are the ones in the spec file. It returns nil if there is no such section.
+func specSection(content []byte, node yaml.Node) []byte {
+	if node.Kind == 0 || node.Line == 0 {
+		return nil
+	}
+
+	lines := bytes.Split(content, []byte("\n"))
+	section := make([]byte, 0, len(content))
+	for i, line := range lines {
+		switch {
+		case i < node.Line-1:
+		case i == node.Line-1 && node.Column-1 <= len(line):
+			section = append(section, bytes.Repeat([]byte(" "), node.Column-1)...)
+			section = append(section, line[node.Column-1:]...)
+		default:
+			// the section ends at the next top level key
+			if len(line) > 0 && !bytes.HasPrefix(line, []byte(" ")) && !bytes.HasPrefix(line, []byte("-")) && !bytes.HasPrefix(line, []byte("#")) {
+				return section
+			}
+
+			section = append(section, line...)
+		}
+
+		section = append(section, '\n')
+	}
+
+	return section
+}
+
+func (s Spec) validate() error {
+	var errs []error
+	if s.Template == "" {
+		errs = append(errs, errors.New("you must provide the template"))
+	}
+
+	if len(s.Fields) == 0 {
+		errs = append(errs, errors.New("you must provide the fields"))
+	}
+
+	switch s.TemplateType {
+	case "placeholder", "gotext", "structured":
+	default:
+		errs = append(errs, fmt.Errorf("template_type must be one of 'placeholder', 'gotext' or 'structured', got %s", s.TemplateType))
+	}
+
+	if s.TotSize != "" {
+		if _, err := humanize.ParseBytes(s.TotSize); err != nil {
+			errs = append(errs, fmt.Errorf("tot_size must be a size like 1GB, got %s", s.TotSize))
+		}
+	}
+
+	if s.Format != "" {
+		if err := ValidateFormat(s.Format); err != nil {
+			errs = append(errs, fmt.Errorf("format: %w", err))
+		}
+	}
+
+	if strings.ContainsAny(s.Output, `/\`) {
+		errs = append(errs, fmt.Errorf("output must be a file name, got %s", s.Output))
+	}
+
+	return multierr.Combine(errs...)
+}
+
+// NewFromSpec sets up a GeneratorCorpus generating the corpus of spec with GenerateFromSpec: the options of the spec
+// are applied first, opts can override them.
+func NewFromSpec(spec Spec, fs afero.Fs, location string, opts ...GeneratorCorpusOption) (GeneratorCorpus, error) {
+	if spec.TemplateType == "" {
+		spec.TemplateType = "placeholder"
+	}
+
+	if err := spec.validate(); err != nil {
+		return GeneratorCorpus{}, err
+	}
+
+	var specOpts []GeneratorCorpusOption
+	if spec.Events > 0 {
+		specOpts = append(specOpts, WithEvents(spec.Events))
+	}
+
+	if spec.Format != "" {
+		specOpts = append(specOpts, WithFormat(spec.Format))
+	}
+
+	if spec.Output != "" {
+		specOpts = append(specOpts, WithFilename(spec.Output))
+	}
+
+	if spec.Seed != 0 {
+		specOpts = append(specOpts, WithSeed(spec.Seed))
+	}
+
+	gc, err := NewGeneratorWithTemplate(spec.Config, fs, location, spec.TemplateType, append(specOpts, opts...)...)
+	if err != nil {
+		return GeneratorCorpus{}, err
+	}
+
+	gc.spec = &spec
+	return gc, nil
+}
+
+// GenerateFromSpec generates the corpus of the spec the GeneratorCorpus was set up with by NewFromSpec, of the total
+// size of the spec, if any, and persists it to file.
+func (gc GeneratorCorpus) GenerateFromSpec(ctx context.Context) (string, error) {
+	if gc.spec == nil {
+		return "", errors.New("the generator was not set up with a spec, see NewFromSpec")
+	}
+
+	if gc.spec.TotSize == "" && gc.events == 0 && gc.rate == 0 && gc.soakOptions == nil {
+		return "", errors.New("you must provide either tot_size or events")
+	}
+
+	runArgs := map[string]string{}
+	if gc.spec.path != "" {
+		runArgs["spec_path"] = gc.spec.path
+	}
+
+	return gc.generateWithTemplate(ctx, gc.spec.Template, gc.spec.Fields, runArgs, gc.spec.TotSize)
+}
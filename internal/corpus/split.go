@@ -0,0 +1,153 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// WithSplit writes the corpus in numbered chunks, like 1672731603-corpus-0001.ndjson, of at most maxFileSize bytes
+// and maxEvents events each: zero means no limit. An event is never split across chunks, so that
+// an event bigger than maxFileSize is written to a chunk on its own.
+func WithSplit(maxFileSize, maxEvents uint64) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.maxFileSize = maxFileSize
+		gc.maxEventsPerFile = maxEvents
+	}
+}
+
+// ChunksPattern returns the glob pattern matching the chunks of the corpus split by WithSplit into payloadFilename.
+func ChunksPattern(payloadFilename string) string {
+	ext := path.Ext(payloadFilename)
+	return strings.TrimSuffix(payloadFilename, ext) + "-*" + ext
+}
+
+// chunkFilename returns the name of the n-th chunk of the corpus split into name.
+func chunkFilename(name string, n int) string {
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s-%04d%s", strings.TrimSuffix(name, ext), n, ext)
+}
+
+// splitSink is a Sink writing each event to the current chunk, opening the next one when the current one is full.
+// The events are written to the chunks in batches of batchSize bytes.
+type splitSink struct {
+	fs          afero.Fs
+	dir         string
+	name        string
+	maxFileSize uint64
+	maxEvents   uint64
+	batchSize   int
+
+	chunks int
+	file   afero.File
+	w      *bufio.Writer
+	size   uint64
+	events uint64
+}
+
+func newSplitSink(fs afero.Fs, dir string, maxFileSize, maxEvents uint64, batchSize int) *splitSink {
+	return &splitSink{
+		fs:          fs,
+		dir:         dir,
+		maxFileSize: maxFileSize,
+		maxEvents:   maxEvents,
+		batchSize:   batchSize,
+	}
+}
+
+// Open starts splitting the corpus named name, its first chunk is created on the first write.
+func (s *splitSink) Open(ctx context.Context, name string) error {
+	if err := s.fs.MkdirAll(s.dir, corpusLocPerm); err != nil {
+		return fmt.Errorf("cannot generate corpus location folder: %v", err)
+	}
+
+	s.name = path.Join(s.dir, name)
+	s.chunks = 0
+	s.file = nil
+	return nil
+}
+
+// Flush writes the current batch to the current chunk, if any.
+func (s *splitSink) Flush() error {
+	if s.file == nil {
+		return nil
+	}
+
+	return s.w.Flush()
+}
+
+// Name returns the name the corpus is split into, the one of the chunks without their number.
+func (s *splitSink) Name() string {
+	return s.name
+}
+
+// Write writes p, an event, to the current chunk.
+func (s *splitSink) Write(p []byte) (int, error) {
+	full := s.maxFileSize > 0 && s.size > 0 && s.size+uint64(len(p)) > s.maxFileSize ||
+		s.maxEvents > 0 && s.events >= s.maxEvents
+	if s.file == nil || full {
+		if err := s.nextChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := s.w.Write(p)
+	s.size += uint64(n)
+	s.events++
+	return n, err
+}
+
+func (s *splitSink) nextChunk() error {
+	if s.file != nil {
+		if err := s.closeChunk(); err != nil {
+			return err
+		}
+	}
+
+	s.chunks++
+	file, err := s.fs.OpenFile(chunkFilename(s.name, s.chunks), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, corpusPerm)
+	if err != nil {
+		return err
+	}
+
+	s.file = file
+	if s.w == nil {
+		s.w = bufio.NewWriterSize(file, s.batchSize)
+	} else {
+		s.w.Reset(file)
+	}
+
+	s.size = 0
+	s.events = 0
+	return nil
+}
+
+// Close closes the current chunk: an empty corpus is written to an empty first chunk.
+func (s *splitSink) Close() error {
+	if s.file == nil {
+		if err := s.nextChunk(); err != nil {
+			return err
+		}
+	}
+
+	return s.closeChunk()
+}
+
+// closeChunk writes the current batch to the current chunk and closes it.
+func (s *splitSink) closeChunk() error {
+	err := s.w.Flush()
+	if closeErr := s.file.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,147 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"go.uber.org/multierr"
+)
+
+// maxSplitFiles bounds the files a corpus is split into by field value, to not exhaust the file descriptors
+const maxSplitFiles = 1000
+
+// missingKey is the key of the events without the field a corpus is split by
+const missingKey = "missing"
+
+// SplitByField splits the NDJSON corpus at corpusPath into files next to it, one for each value of field, like
+// corpus-web-01.ndjson: events without the field go to corpus-missing.ndjson. With shards greater than zero
+// the values are hashed into that many files instead, like corpus-shard3.ndjson. Bulk request actions are
+// written to the file of the event following them. The paths of the files are returned sorted.
+func SplitByField(fs afero.Fs, corpusPath, field string, shards int) ([]string, error) {
+	f, err := fs.Open(corpusPath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	ext := path.Ext(corpusPath)
+	base := strings.TrimSuffix(corpusPath, ext)
+
+	files := make(map[string]afero.File)
+	writers := make(map[string]*bufio.Writer)
+	err = splitEvents(bufio.NewReader(f), field, shards, func(key string, payload []byte) error {
+		w, ok := writers[key]
+		if !ok {
+			if len(writers) == maxSplitFiles {
+				return fmt.Errorf("field %s: more than %d distinct values, shards must be used", field, maxSplitFiles)
+			}
+
+			file, err := fs.OpenFile(base+"-"+key+ext, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, corpusPerm)
+			if err != nil {
+				return err
+			}
+
+			files[key] = file
+			w = bufio.NewWriter(file)
+			writers[key] = w
+		}
+
+		_, err := w.Write(payload)
+		return err
+	})
+
+	filenames := make([]string, 0, len(files))
+	for key, file := range files {
+		err = multierr.Append(err, writers[key].Flush())
+		err = multierr.Append(err, file.Close())
+		filenames = append(filenames, file.Name())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(filenames)
+	return filenames, nil
+}
+
+// splitEvents reads the events of a corpus, calling write with the key of the file of each one and its payload,
+// made of the event and the bulk request action preceding it, if any.
+func splitEvents(r *bufio.Reader, field string, shards int, write func(key string, payload []byte) error) error {
+	var action []byte
+	for lineNumber := 1; ; lineNumber++ {
+		line, readErr := r.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			if readErr == io.EOF {
+				return nil
+			}
+
+			continue
+		}
+
+		var doc map[string]interface{}
+		if err := json.Unmarshal(line, &doc); err != nil {
+			return fmt.Errorf("line %d: not a JSON object: %v", lineNumber, err)
+		}
+
+		if isBulkAction(doc) {
+			action = append(append(action[:0], line...), '\n')
+			continue
+		}
+
+		key := missingKey
+		switch value := lookupPath(doc, field).(type) {
+		case nil:
+		case map[string]interface{}, []interface{}:
+			return fmt.Errorf("line %d: field %s: cannot split by a non scalar value", lineNumber, field)
+		default:
+			key = splitKey(fmt.Sprint(value), shards)
+		}
+
+		payload := append(append(action, line...), '\n')
+		if err := write(key, payload); err != nil {
+			return err
+		}
+
+		action = action[:0]
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+// splitKey returns the key of the file of value: the value itself, safe for filenames, or its shard.
+func splitKey(value string, shards int) string {
+	if shards > 0 {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(value))
+		return fmt.Sprintf("shard%d", h.Sum32()%uint32(shards))
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+
+		return '-'
+	}, value)
+}
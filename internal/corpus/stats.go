@@ -0,0 +1,128 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+	"github.com/spf13/afero"
+)
+
+// maxTrackedCardinality bounds the distinct values tracked for each field, to bound the memory of long runs.
+const maxTrackedCardinality = 1000000
+
+// WithStats writes to filename the summary of the run as JSON, alongside the cardinality achieved for each field.
+func WithStats(filename string) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.statsFilename = filename
+	}
+}
+
+// FieldStats are the statistics of the values generated for a field.
+type FieldStats struct {
+	Cardinality uint64 `json:"cardinality"`
+	// CardinalityCapped is set when the field has more distinct values than the tracked ones, Cardinality is a lower bound then
+	CardinalityCapped bool `json:"cardinality_capped,omitempty"`
+}
+
+// RunStats are the statistics of a run.
+type RunStats struct {
+	RunSummary
+	Fields map[string]FieldStats `json:"fields,omitempty"`
+}
+
+// fieldsObserver is given the traces of the fields of each generated event.
+type fieldsObserver interface {
+	add(traces []genlib.FieldTrace) error
+}
+
+// fieldsObservers returns the observers that are enabled, the fields are traced only when there are any.
+func fieldsObservers(expected *expectedResults, cardinalities *fieldCardinalities) []fieldsObserver {
+	var observers []fieldsObserver
+	if expected != nil {
+		observers = append(observers, expected)
+	}
+
+	if cardinalities != nil {
+		observers = append(observers, cardinalities)
+	}
+
+	return observers
+}
+
+// fieldCardinalities tracks the distinct values of each field.
+type fieldCardinalities struct {
+	values map[string]map[string]struct{}
+	capped map[string]bool
+}
+
+// newFieldCardinalities returns the tracker of the distinct values of the fields, nil if no stats are requested.
+func (gc GeneratorCorpus) newFieldCardinalities() *fieldCardinalities {
+	if len(gc.statsFilename) == 0 {
+		return nil
+	}
+
+	return &fieldCardinalities{
+		values: make(map[string]map[string]struct{}),
+		capped: make(map[string]bool),
+	}
+}
+
+func (c *fieldCardinalities) add(traces []genlib.FieldTrace) error {
+	for _, trace := range traces {
+		// a null or omitted field is not a distinct value of the field
+		if trace.Sparse != "" {
+			continue
+		}
+
+		values, ok := c.values[trace.Field]
+		if !ok {
+			values = make(map[string]struct{})
+			c.values[trace.Field] = values
+		}
+
+		if _, ok := values[trace.Value]; ok {
+			continue
+		}
+
+		if len(values) >= maxTrackedCardinality {
+			c.capped[trace.Field] = true
+			continue
+		}
+
+		values[trace.Value] = struct{}{}
+	}
+
+	return nil
+}
+
+func (c *fieldCardinalities) stats() map[string]FieldStats {
+	if c == nil {
+		return nil
+	}
+
+	stats := make(map[string]FieldStats, len(c.values))
+	for field, values := range c.values {
+		stats[field] = FieldStats{Cardinality: uint64(len(values)), CardinalityCapped: c.capped[field]}
+	}
+
+	return stats
+}
+
+// writeStats writes the stats of the run to the file provided by WithStats.
+func (gc GeneratorCorpus) writeStats(summary RunSummary, cardinalities *fieldCardinalities) error {
+	body, err := json.MarshalIndent(RunStats{RunSummary: summary, Fields: cardinalities.stats()}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := afero.WriteFile(gc.fs, gc.statsFilename, append(body, '\n'), corpusPerm); err != nil {
+		return fmt.Errorf("cannot write stats: %w", err)
+	}
+
+	return nil
+}
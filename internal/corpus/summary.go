@@ -0,0 +1,161 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/version"
+)
+
+const telemetryTimeout = 30 * time.Second
+
+// RunSummary describes a corpus generation run.
+type RunSummary struct {
+	Timestamp       time.Time         `json:"@timestamp"`
+	Version         string            `json:"version"`
+	CommitHash      string            `json:"commit_hash"`
+	Seed            int64             `json:"seed"`
+	Params          map[string]string `json:"params"`
+	Filename        string            `json:"filename"`
+	Events          uint64            `json:"events"`
+	Bytes           uint64            `json:"bytes"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	EventsPerSecond float64           `json:"events_per_second"`
+	BytesPerSecond  float64           `json:"bytes_per_second"`
+	Interrupted     bool              `json:"interrupted"`
+	// SHA256 is the one of the content of the corpus, with WithAudit
+	SHA256 string `json:"sha256,omitempty"`
+	// DuplicateIDs is the number of events whose _id is a duplicate, see IDStrategy.DuplicatePercentage
+	DuplicateIDs uint64 `json:"duplicate_ids,omitempty"`
+	// DuplicateEvents is the number of events written again, see WithDuplicates
+	DuplicateEvents uint64 `json:"duplicate_events,omitempty"`
+}
+
+func (gc GeneratorCorpus) newRunSummary(params map[string]string) RunSummary {
+	if !gc.timeRangeFrom.IsZero() {
+		params["time_range_from"] = gc.timeRangeFrom.Format(time.RFC3339)
+		params["time_range_to"] = gc.timeRangeTo.Format(time.RFC3339)
+	}
+
+	if gc.rate > 0 {
+		params["rate"] = strconv.FormatFloat(gc.rate, 'f', -1, 64)
+		params["duration"] = gc.duration.String()
+	}
+
+	v := version.Tag
+	if v == "" {
+		v = "devel"
+	}
+
+	return RunSummary{
+		Timestamp:  time.Now().UTC(),
+		Version:    v,
+		CommitHash: version.CommitHash,
+		Seed:       gc.seed,
+		Params:     params,
+	}
+}
+
+// endRun completes the summary of the run, reports it alongside the cardinalities of the fields, if tracked,
+// and indexes it in the telemetry index, if configured.
+func (gc GeneratorCorpus) endRun(summary *RunSummary, payloadFilename string, interrupted bool, cardinalities *fieldCardinalities) error {
+	summary.Filename = payloadFilename
+	summary.Interrupted = interrupted
+	summary.DurationSeconds = time.Since(summary.Timestamp).Seconds()
+	if summary.DurationSeconds > 0 {
+		summary.EventsPerSecond = float64(summary.Events) / summary.DurationSeconds
+		summary.BytesPerSecond = float64(summary.Bytes) / summary.DurationSeconds
+	}
+
+	gc.reportEnd(summary)
+
+	if len(gc.statsFilename) > 0 {
+		if err := gc.writeStats(*summary, cardinalities); err != nil {
+			return err
+		}
+	}
+
+	if gc.audit != nil {
+		if err := gc.writeAudit(*summary); err != nil {
+			return err
+		}
+	}
+
+	if len(gc.telemetryURL) == 0 {
+		return nil
+	}
+
+	// the run context could be already cancelled, the summary of interrupted runs is indexed as well
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+	defer cancel()
+
+	if err := indexRunSummary(ctx, gc.telemetryURL, gc.telemetryIndex, *summary); err != nil {
+		return fmt.Errorf("corpus generated in %s, but cannot index run summary: %w", payloadFilename, err)
+	}
+
+	return nil
+}
+
+// indexRunSummary indexes the summary as a document in the index of the Elasticsearch cluster at elasticsearchURL.
+// Credentials for basic auth can be provided as part of the url.
+func indexRunSummary(ctx context.Context, elasticsearchURL, index string, summary RunSummary) error {
+	return elasticsearchRequest(ctx, elasticsearchURL, http.MethodPost, path.Join(index, "_doc"), summary, nil)
+}
+
+// elasticsearchRequest sends body, serialized as JSON if not nil, to the API at apiPath of the Elasticsearch cluster
+// at elasticsearchURL, and deserializes the JSON response into out, if not nil.
+func elasticsearchRequest(ctx context.Context, elasticsearchURL, method, apiPath string, body, out interface{}) error {
+	u, err := url.Parse(elasticsearchURL)
+	if err != nil {
+		return err
+	}
+
+	u.Path = path.Join(u.Path, apiPath)
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reqBody)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,213 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
+	"go.uber.org/multierr"
+)
+
+// ValidateTemplate checks the template at templatePath against the fields definition at fieldsDefinitionPath
+// and the config, without generating any event. It returns the issues found: fields referenced by the template
+// and not defined, defined fields not referenced, values quoted against their type, config entries referencing
+// fields not defined and ignored config settings.
+func (gc GeneratorCorpus) ValidateTemplate(ctx context.Context, templatePath, fieldsDefinitionPath string) ([]string, error) {
+	gen, flds, err := gc.loadTemplateGenerator(ctx, templatePath, fieldsDefinitionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(genlib.LintTemplate(gen, flds), gc.configIssues(flds)...), nil
+}
+
+// UpgradeIssues are the issues the upgrade of a fields definition introduces in the config and in templates.
+type UpgradeIssues struct {
+	Config []string
+	// Templates are the issues of the templates, by path
+	Templates map[string][]string
+}
+
+// Len returns the count of issues.
+func (u UpgradeIssues) Len() int {
+	n := len(u.Config)
+	for _, issues := range u.Templates {
+		n += len(issues)
+	}
+
+	return n
+}
+
+// TemplatePaths returns the paths of the templates, sorted.
+func (u UpgradeIssues) TemplatePaths() []string {
+	templatePaths := make([]string, 0, len(u.Templates))
+	for templatePath := range u.Templates {
+		templatePaths = append(templatePaths, templatePath)
+	}
+
+	sort.Strings(templatePaths)
+	return templatePaths
+}
+
+// UpgradeIssues returns the issues the config, and the templates at templatePaths, have with the fields definition
+// to and do not have with the fields definition from: config entries referencing removed fields or with settings
+// ignored for the new type of their field, template references to removed fields and values quoted against the new
+// type of their field. A template that cannot be parsed with to is an issue as well.
+func (gc GeneratorCorpus) UpgradeIssues(from, to genlib.Fields, templatePaths []string) (UpgradeIssues, error) {
+	upgradeIssues := UpgradeIssues{
+		Config:    newIssues(gc.configIssues(from), gc.configIssues(to)),
+		Templates: make(map[string][]string, len(templatePaths)),
+	}
+
+	for _, templatePath := range templatePaths {
+		template, err := gc.readTemplate(templatePath)
+		if err != nil {
+			return UpgradeIssues{}, err
+		}
+
+		gen, err := gc.newTemplateGenerator(template, from)
+		if err != nil {
+			return UpgradeIssues{}, genlib.SetTemplateName(err, templatePath)
+		}
+
+		fromIssues := genlib.LintReferences(gen)
+		gen, err = gc.newTemplateGenerator(template, to)
+		if err != nil {
+			upgradeIssues.Templates[templatePath] = []string{genlib.SetTemplateName(err, templatePath).Error()}
+			continue
+		}
+
+		upgradeIssues.Templates[templatePath] = newIssues(fromIssues, genlib.LintReferences(gen))
+	}
+
+	return upgradeIssues, nil
+}
+
+// configIssues returns the config entries referencing fields not in flds and the config settings ignored for flds.
+func (gc GeneratorCorpus) configIssues(flds genlib.Fields) []string {
+	fieldNames := make([]string, 0, len(flds))
+	for _, field := range flds {
+		fieldNames = append(fieldNames, field.Name)
+	}
+
+	var issues []string
+	for _, err := range multierr.Errors(gc.config.ValidateFields(fieldNames)) {
+		issues = append(issues, err.Error())
+	}
+
+	return append(issues, genlib.ConfigWarnings(gc.config, flds)...)
+}
+
+// newIssues returns the issues of to that are not in from.
+func newIssues(from, to []string) []string {
+	known := make(map[string]struct{}, len(from))
+	for _, issue := range from {
+		known[issue] = struct{}{}
+	}
+
+	var issues []string
+	for _, issue := range to {
+		if _, ok := known[issue]; !ok {
+			issues = append(issues, issue)
+		}
+	}
+
+	return issues
+}
+
+// TemplateCoverage returns the coverage of the fields definition at fieldsDefinitionPath by the template at
+// templatePath, without generating any event.
+func (gc GeneratorCorpus) TemplateCoverage(ctx context.Context, templatePath, fieldsDefinitionPath string) (genlib.TemplateCoverage, error) {
+	gen, flds, err := gc.loadTemplateGenerator(ctx, templatePath, fieldsDefinitionPath)
+	if err != nil {
+		return genlib.TemplateCoverage{}, err
+	}
+
+	return genlib.Coverage(gen, flds), nil
+}
+
+// loadTemplateGenerator returns the generator of the template at templatePath and the fields definition at
+// fieldsDefinitionPath.
+func (gc GeneratorCorpus) loadTemplateGenerator(ctx context.Context, templatePath, fieldsDefinitionPath string) (genlib.Generator, genlib.Fields, error) {
+	template, err := gc.readTemplate(templatePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	flds, err := fields.LoadFieldsWithTemplate(ctx, fieldsDefinitionPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gen, err := gc.newTemplateGenerator(template, flds)
+	if err != nil {
+		return nil, nil, genlib.SetTemplateName(err, templatePath)
+	}
+
+	return gen, flds, nil
+}
+
+// WithIncludeDir expands the actions of the templates including a partial, like {{ template "header" }}, with the
+// partials loaded from the files of dir, see genlib.ExpandTemplatePartials.
+func WithIncludeDir(dir string) GeneratorCorpusOption {
+	return func(gc *GeneratorCorpus) {
+		gc.includeDir = dir
+	}
+}
+
+// readTemplate returns the content of the template at templatePath, which must not be empty, with the partials of
+// WithIncludeDir expanded.
+func (gc GeneratorCorpus) readTemplate(templatePath string) ([]byte, error) {
+	template, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(template) == 0 {
+		return nil, errors.New("you must provide a non empty template content")
+	}
+
+	if gc.includeDir == "" {
+		return template, nil
+	}
+
+	partials, err := genlib.LoadTemplatePartials(gc.includeDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load the partials of the templates: %w", err)
+	}
+
+	template, err = genlib.ExpandTemplatePartials(template, partials)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", templatePath, err)
+	}
+
+	return template, nil
+}
+
+// newTemplateGenerator returns the generator of template, of the template type of gc, with flds.
+// The size of the corpus is unknown: the cardinalities relative to it are scaled to a million events and to 1GB.
+func (gc GeneratorCorpus) newTemplateGenerator(template []byte, flds genlib.Fields) (genlib.Generator, error) {
+	cfg, err := gc.config.ScaleCardinalities(1000*1000, 1000*1000*1000)
+	if err != nil {
+		return nil, err
+	}
+
+	switch gc.templateType {
+	case templateTypeCustom:
+		return genlib.NewGeneratorWithCustomTemplate(template, cfg, flds)
+	case templateTypeGoText:
+		return genlib.NewGeneratorWithTextTemplate(template, cfg, flds)
+	case templateTypeStructured:
+		return genlib.NewGeneratorWithStructuredTemplate(template, cfg, flds)
+	default:
+		return nil, ErrNotValidTemplate
+	}
+}
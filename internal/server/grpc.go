@@ -0,0 +1,105 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/server/generatorpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultBatchSize is the size the batches of events of the gRPC streams are sent at, unless set by the request
+	DefaultBatchSize = 64 << 10
+	// maxBatchSize bounds the size of the batches, below the 4MB default limit of the messages received by the clients
+	maxBatchSize = 1 << 20
+)
+
+// NewGRPC returns a gRPC server serving the Generator service of generatorpb, streaming the events generated from
+// the template, the fields definition and the config of each call in batches of whole events, one per line.
+// The events of a call are at most maxEvents, unless the call streams them until it is canceled. As with Server,
+// the calls share the random generators.
+func NewGRPC(maxEvents uint64, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(opts...)
+	generatorpb.RegisterGeneratorServer(s, generatorService{maxEvents: maxEvents})
+	return s
+}
+
+type generatorService struct {
+	generatorpb.UnimplementedGeneratorServer
+	maxEvents uint64
+}
+
+func (g generatorService) StreamEvents(in *generatorpb.StreamEventsRequest, stream generatorpb.Generator_StreamEventsServer) error {
+	batchSize := int(in.GetBatchSize())
+	if batchSize == 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	if batchSize > maxBatchSize {
+		return status.Errorf(codes.InvalidArgument, "batch_size must be at most %d", maxBatchSize)
+	}
+
+	if in.GetEvents() > g.maxEvents {
+		return status.Errorf(codes.InvalidArgument, "events must be at most %d", g.maxEvents)
+	}
+
+	if len(in.GetFields()) == 0 {
+		return status.Error(codes.InvalidArgument, "fields must be provided")
+	}
+
+	reader, err := newReader(Request{
+		Template:     in.GetTemplate(),
+		TemplateType: in.GetTemplateType(),
+		Fields:       in.GetFields(),
+		Config:       in.GetConfig(),
+		Seed:         in.GetSeed(),
+		Events:       in.GetEvents(),
+	})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	defer reader.Close()
+
+	// the batch is sent once it holds batchSize bytes of whole events, or more when an event does not fit
+	lines := bufio.NewReaderSize(reader, batchSize)
+	batch := &generatorpb.EventBatch{Events: make([]byte, 0, batchSize)}
+	for {
+		line, err := lines.ReadSlice('\n')
+		batch.Events = append(batch.Events, line...)
+		switch {
+		case err == nil:
+			batch.Count += 1
+		case errors.Is(err, io.EOF):
+			if len(batch.Events) == 0 {
+				return nil
+			}
+		case !errors.Is(err, bufio.ErrBufferFull):
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		if (err == nil && len(batch.Events) >= batchSize) || errors.Is(err, io.EOF) {
+			if err := stream.Send(batch); err != nil {
+				return err
+			}
+
+			batch.Events, batch.Count = batch.Events[:0], 0
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err := stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package server serves the generation of events over HTTP, for CI pipelines and other services to request
+// corpora on demand without sharing a filesystem with the generator.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
+)
+
+// DefaultMaxEvents is the most events of a request to the generate endpoint, unless set otherwise with WithMaxEvents.
+const DefaultMaxEvents = 1000000
+
+const (
+	// maxRequestSize bounds the size of the body of the requests, holding the template, the fields and the config
+	maxRequestSize = 10 << 20
+	// chunkSize is the size of the chunks the events are streamed in
+	chunkSize         = 32 << 10
+	ndjsonContentType = "application/x-ndjson"
+)
+
+// seededReferenceTime is the time the date fields of the requests with a seed are generated in the hour before,
+// instead of the current time, so that the seed reproduces them whenever the request is served.
+var seededReferenceTime = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// generation guards the random generators shared by the requests, which are not safe to seed while they are drawn
+// from: the seeding of a request excludes the generation of the events of the others.
+var generation sync.RWMutex
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithMaxEvents sets the most events of a request to the generate endpoint, instead of DefaultMaxEvents.
+func WithMaxEvents(n uint64) Option {
+	return func(s *Server) {
+		s.maxEvents = n
+	}
+}
+
+// Request is the body of the requests to the endpoints, as JSON: the template, the fields definition and the
+// config are the contents of the files the generate-with-template command is given.
+type Request struct {
+	Template string `json:"template"`
+	// TemplateType is placeholder, the default, gotext or structured
+	TemplateType string `json:"template_type"`
+	Fields       string `json:"fields"`
+	Config       string `json:"config"`
+	// Seed seeds the random generators, unless 0: the date fields are then generated in the hour before
+	// 2024-01-01T00:00:00Z, instead of the current time
+	Seed int64 `json:"seed"`
+	// Events is the count of events to generate, required by the generate endpoint, and ending the stream of the
+	// stream endpoint unless 0
+	Events uint64 `json:"events"`
+}
+
+// Server serves the generation of events from the template, the fields definition and the config of each request,
+// as NDJSON:
+//   - POST /generate responds with the count of events of the request.
+//   - POST /stream streams the events, with chunked encoding, until the client disconnects or the count of events
+//     of the request, if any, is reached.
+//
+// The requests share the random generators: the seed of a request reproduces its events only when it is the only
+// one being served. The requests with a seed are seeded one at a time, while no events are being generated.
+type Server struct {
+	maxEvents uint64
+	mux       *http.ServeMux
+}
+
+// New returns a Server.
+func New(opts ...Option) *Server {
+	s := &Server{maxEvents: DefaultMaxEvents, mux: http.NewServeMux()}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.mux.HandleFunc("/generate", s.handleGenerate)
+	s.mux.HandleFunc("/stream", s.handleStream)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if req.Events == 0 || req.Events > s.maxEvents {
+		http.Error(w, fmt.Sprintf("events must be between 1 and %d", s.maxEvents), http.StatusBadRequest)
+		return
+	}
+
+	streamEvents(w, r, req, false)
+}
+
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	streamEvents(w, r, req, true)
+}
+
+// decodeRequest decodes the body of r, responding with the error if it is not a valid request.
+func decodeRequest(w http.ResponseWriter, r *http.Request) (Request, bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return Request{}, false
+	}
+
+	var req Request
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return Request{}, false
+	}
+
+	if len(req.Fields) == 0 {
+		http.Error(w, "invalid request: fields must be provided", http.StatusBadRequest)
+		return Request{}, false
+	}
+
+	return req, true
+}
+
+// eventsReader reads the events of a CorpusReader, holding generation for reading while generating them.
+type eventsReader struct {
+	*genlib.CorpusReader
+}
+
+func (r eventsReader) Read(p []byte) (int, error) {
+	generation.RLock()
+	defer generation.RUnlock()
+	return r.CorpusReader.Read(p)
+}
+
+// newReader returns the reader of the events of req.
+func newReader(req Request) (eventsReader, error) {
+	flds, err := fields.LoadFieldsFromYaml([]byte(req.Fields))
+	if err != nil {
+		return eventsReader{}, fmt.Errorf("fields: %w", err)
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte(req.Config))
+	if err != nil {
+		return eventsReader{}, fmt.Errorf("config: %w", err)
+	}
+
+	opts := []genlib.CorpusReaderOption{genlib.WithMaxEvents(req.Events)}
+	if len(req.TemplateType) > 0 {
+		opts = append(opts, genlib.WithTemplateType(req.TemplateType))
+	}
+
+	if req.Seed != 0 {
+		opts = append(opts, genlib.WithReferenceTime(seededReferenceTime))
+	}
+
+	// the reader is created holding generation as well, binding the fields can draw from the random generators
+	if req.Seed != 0 {
+		generation.Lock()
+		defer generation.Unlock()
+		genlib.InitGeneratorRandSeed(req.Seed)
+	} else {
+		generation.RLock()
+		defer generation.RUnlock()
+	}
+
+	reader, err := genlib.NewCorpusReader(cfg, flds, []byte(req.Template), opts...)
+	if err != nil {
+		return eventsReader{}, err
+	}
+
+	return eventsReader{reader}, nil
+}
+
+// streamEvents writes the events of req to w, flushing each chunk when flush is set. The first chunk is generated
+// before responding, so that the errors of the template are reported with a Bad Request status; the response of
+// a generation failing afterwards is aborted, for the client not to mistake it for a complete one.
+func streamEvents(w http.ResponseWriter, r *http.Request, req Request, flush bool) {
+	reader, err := newReader(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	defer reader.Close()
+
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, chunkSize)
+	for first := true; ; first = false {
+		n, err := io.ReadFull(reader, buf)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			if first {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			panic(http.ErrAbortHandler)
+		}
+
+		if first {
+			w.Header().Set("Content-Type", ndjsonContentType)
+		}
+
+		if _, writeErr := w.Write(buf[:n]); writeErr != nil || err != nil {
+			// the client is gone, or the events are all written
+			return
+		}
+
+		if flush && flusher != nil {
+			flusher.Flush()
+		}
+
+		if r.Context().Err() != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/server/generatorpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testFields = `- name: alpha
+  type: keyword
+- name: beta
+  type: long
+`
+
+func post(t *testing.T, u string, req Request) *http.Response {
+	body, err := json.Marshal(req)
+	require.NoError(t, err)
+
+	resp, err := http.Post(u, "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestGenerate(t *testing.T) {
+	srv := httptest.NewServer(New(WithMaxEvents(100)))
+	defer srv.Close()
+
+	resp := post(t, srv.URL+"/generate", Request{
+		Template:     `{"alpha":"{{generate "alpha"}}","beta":{{generate "beta"}}}`,
+		TemplateType: "gotext",
+		Fields:       testFields,
+		Config:       "- name: alpha\n  enum: [\"a\"]\n",
+		Events:       10,
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, ndjsonContentType, resp.Header.Get("Content-Type"))
+
+	content, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	require.Len(t, lines, 10)
+	for _, line := range lines {
+		var event map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		assert.Equal(t, "a", event["alpha"])
+	}
+
+	// the seed reproduces the events
+	generate := func() string {
+		resp := post(t, srv.URL+"/generate", Request{Fields: testFields, Seed: 42, Events: 5})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		content, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	// including the @timestamp of the events generated without a template, a few milliseconds apart
+	first := generate()
+	assert.Contains(t, first, `"@timestamp": "2023-12-31T23:`)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, first, generate())
+}
+
+func TestGenerate_concurrentSeeds(t *testing.T) {
+	s := New()
+
+	// the seeding of a request must not race with the generation of the others, as reported by go test -race: the
+	// handler is served directly, the synchronization of an HTTP server would hide the race
+	var wg sync.WaitGroup
+	for seed := int64(1); seed <= 4; seed++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			for i := int64(0); i < 50; i++ {
+				body, err := json.Marshal(Request{Template: `{"alpha":"{{.alpha}}","beta":{{.beta}}}`, Fields: testFields, Seed: seed*100 + i, Events: 10})
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				w := httptest.NewRecorder()
+				s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/generate", bytes.NewReader(body)))
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Equal(t, 10, strings.Count(w.Body.String(), "\n"))
+			}
+		}(seed)
+	}
+
+	wg.Wait()
+}
+
+func TestGenerate_invalid(t *testing.T) {
+	srv := httptest.NewServer(New(WithMaxEvents(100)))
+	defer srv.Close()
+
+	testCases := []struct {
+		name     string
+		req      Request
+		expected string
+	}{
+		{name: "no events", req: Request{Fields: testFields}, expected: "events must be between 1 and 100"},
+		{name: "too many events", req: Request{Fields: testFields, Events: 101}, expected: "events must be between 1 and 100"},
+		{name: "no fields", req: Request{Events: 1}, expected: "fields must be provided"},
+		{name: "invalid config", req: Request{Fields: testFields, Config: "- name: alpha\n  range: -1\n", Events: 1}, expected: "config: "},
+		{name: "invalid template type", req: Request{Template: "{{.alpha}}", TemplateType: "jinja", Fields: testFields, Events: 1}, expected: "template type must be one of"},
+		{name: "invalid template", req: Request{Template: "{{.alpha", TemplateType: "gotext", Fields: testFields, Events: 1}, expected: "unclosed action"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := post(t, srv.URL+"/generate", tc.req)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			content, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(content), tc.expected)
+		})
+	}
+
+	resp, err := http.Get(srv.URL + "/generate")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestStream(t *testing.T) {
+	srv := httptest.NewServer(New())
+	defer srv.Close()
+
+	// endless, until the client disconnects
+	resp := post(t, srv.URL+"/stream", Request{Template: `{"alpha":"{{.alpha}}"}`, Fields: testFields})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"chunked"}, resp.TransferEncoding)
+
+	scanner := bufio.NewScanner(resp.Body)
+	for i := 0; i < 10000; i++ {
+		require.True(t, scanner.Scan(), scanner.Err())
+		assert.True(t, strings.HasPrefix(scanner.Text(), `{"alpha":"`))
+	}
+
+	require.NoError(t, resp.Body.Close())
+
+	resp = post(t, srv.URL+"/stream", Request{Fields: testFields, Events: 3})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	content, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(content), "\n"))
+}
+
+func TestGRPCStreamEvents(t *testing.T) {
+	ln := bufconn.Listen(1 << 20)
+	srv := NewGRPC(1000)
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Stop()
+
+	conn, err := grpc.Dial("bufconn", grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return ln.DialContext(ctx)
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := generatorpb.NewGeneratorClient(conn)
+
+	stream, err := client.StreamEvents(context.Background(), &generatorpb.StreamEventsRequest{
+		Template:  `{"alpha":"{{.alpha}}"}`,
+		Fields:    testFields,
+		Events:    1000,
+		BatchSize: 1024,
+	})
+	require.NoError(t, err)
+
+	var events, batches uint64
+	for {
+		batch, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+
+		require.NoError(t, err)
+		batches++
+		// the batches hold whole events
+		require.True(t, bytes.HasSuffix(batch.Events, []byte("\n")))
+		lines := strings.Split(strings.TrimSuffix(string(batch.Events), "\n"), "\n")
+		require.Len(t, lines, int(batch.Count))
+		for _, line := range lines {
+			assert.True(t, strings.HasPrefix(line, `{"alpha":"`))
+		}
+
+		events += batch.Count
+	}
+
+	assert.Equal(t, uint64(1000), events)
+	assert.Greater(t, batches, uint64(1))
+
+	// endless, until the client cancels the call
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err = client.StreamEvents(ctx, &generatorpb.StreamEventsRequest{Fields: testFields})
+	require.NoError(t, err)
+	for events = 0; events < 100000; {
+		batch, err := stream.Recv()
+		require.NoError(t, err)
+		events += batch.Count
+	}
+
+	cancel()
+
+	stream, err = client.StreamEvents(context.Background(), &generatorpb.StreamEventsRequest{Template: "{{.alpha", TemplateType: "gotext", Fields: testFields})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, err.Error(), "unclosed action")
+
+	stream, err = client.StreamEvents(context.Background(), &generatorpb.StreamEventsRequest{Fields: testFields, Events: 1001})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, err.Error(), "events must be at most 1000")
+}
+
+// testEventsStream counts the events sent to it by the Generator service.
+type testEventsStream struct {
+	generatorpb.Generator_StreamEventsServer
+	events uint64
+}
+
+func (s *testEventsStream) Send(batch *generatorpb.EventBatch) error {
+	s.events += batch.Count
+	return nil
+}
+
+func (s *testEventsStream) Context() context.Context {
+	return context.Background()
+}
+
+func TestGRPCStreamEvents_concurrentSeeds(t *testing.T) {
+	service := generatorService{maxEvents: DefaultMaxEvents}
+
+	// as for the generate endpoint, the service is called directly for go test -race to report the races
+	var wg sync.WaitGroup
+	for seed := int64(1); seed <= 4; seed++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			for i := int64(0); i < 50; i++ {
+				stream := &testEventsStream{}
+				err := service.StreamEvents(&generatorpb.StreamEventsRequest{Template: `{"alpha":"{{.alpha}}"}`, Fields: testFields, Seed: seed*100 + i, Events: 10}, stream)
+				assert.NoError(t, err)
+				assert.Equal(t, uint64(10), stream.events)
+			}
+		}(seed)
+	}
+
+	wg.Wait()
+}
@@ -32,6 +32,9 @@ func setDefaults() {
 	viper.SetDefault("corpora_location", path.Join(
 		os.ExpandEnv(viper.GetString("corpora_root")),
 		viper.GetString("corpora_path")))
+
+	// the fields downloaded from the package registry are cached there
+	viper.SetDefault("fields_cache_location", path.Join(viper.GetString("cache_dir"), "elastic-integration-corpus-generator-tool", "fields"))
 }
 
 func setConstants() {
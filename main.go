@@ -5,7 +5,10 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/elastic/elastic-integration-corpus-generator-tool/cmd"
 	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/settings"
@@ -14,13 +17,34 @@ import (
 func main() {
 	settings.Init()
 
+	// SIGINT and SIGTERM stop the generation gracefully, a second signal terminates the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	rootCmd := cmd.RootCmd()
+	rootCmd.AddCommand(cmd.BenchCmd())
 	rootCmd.AddCommand(cmd.GenerateCmd())
+	rootCmd.AddCommand(cmd.GenerateFromPackageCmd())
 	rootCmd.AddCommand(cmd.GenerateWithTemplateCmd())
+	rootCmd.AddCommand(cmd.GenerateFromSampleCmd())
+	rootCmd.AddCommand(cmd.GenerateScenarioCmd())
+	rootCmd.AddCommand(cmd.DiffCmd())
+	rootCmd.AddCommand(cmd.InteractiveCmd())
+	rootCmd.AddCommand(cmd.PreviewCmd())
+	rootCmd.AddCommand(cmd.ProfileCmd())
+	rootCmd.AddCommand(cmd.ReplayCmd())
+	rootCmd.AddCommand(cmd.RerunCmd())
+	rootCmd.AddCommand(cmd.ServeCmd())
+	rootCmd.AddCommand(cmd.SplitByFieldCmd())
+	rootCmd.AddCommand(cmd.ValidateCmd())
 	rootCmd.AddCommand(cmd.VersionCmd())
+	cmd.BindEnv(rootCmd)
+
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
 
-	err := rootCmd.Execute()
-	if err != nil {
-		os.Exit(1)
-	}
+	os.Exit(cmd.ExitCode(err))
 }
@@ -0,0 +1,220 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAPMMaxSpans is the most spans of the transaction of a trace, unless set otherwise
+const DefaultAPMMaxSpans = 5
+
+// apmCopiedPrefixes are the prefixes of the fields of a transaction its spans get the values of
+var apmCopiedPrefixes = []string{"agent.", "host.", "service."}
+
+const (
+	// apmMinDuration and apmMaxDuration bound the duration in microseconds of the transactions without a generated one
+	apmMinDuration = 1000
+	apmMaxDuration = 1000000
+)
+
+// NewAPMTraces returns a Middleware rewriting the JSON events as the documents of APM traces: each trace is a
+// transaction followed by its spans, between 1 and maxSpans of them, one per event. The documents of a trace share
+// its trace.id and transaction.id, and the parent.id of a span is the id of the transaction or of the span it is
+// nested in.
+//
+// The transaction keeps its generated transaction.duration.us, if positive, and gets one between 1ms and 1s
+// otherwise. The spans are laid out within the time of their parent: the children of a transaction or of a span do
+// not overlap, so their durations sum up to the one of their parent at most, and their @timestamp and timestamp.us
+// follow the ones of their parent. The spans get the agent, host and service fields of their transaction, and
+// neither the transaction documents have span fields nor the span documents have transaction fields other than
+// transaction.id.
+func NewAPMTraces(maxSpans int) Middleware {
+	var trace *apmTrace
+	var buf bytes.Buffer
+	return func(doc []byte) ([]byte, error) {
+		decoder := json.NewDecoder(bytes.NewReader(doc))
+		decoder.UseNumber()
+		var event map[string]interface{}
+		if err := decoder.Decode(&event); err != nil {
+			return nil, fmt.Errorf("cannot rewrite as a document of an APM trace a document that is not a JSON object: %w", err)
+		}
+
+		if trace == nil || trace.next == len(trace.spans) {
+			trace = startAPMTrace(event, maxSpans)
+		} else {
+			trace.nextSpan(event)
+		}
+
+		buf.Reset()
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(event); err != nil {
+			return nil, err
+		}
+
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+	}
+}
+
+// apmTrace is the trace being written: its ids, the start of its transaction, the values of the fields its spans
+// get, its spans and the index of the next one.
+type apmTrace struct {
+	traceID       string
+	transactionID string
+	start         time.Time
+	copied        map[string]interface{}
+	spans         []apmSpan
+	next          int
+}
+
+// apmSpan is a span of a trace, its offset and its duration in microseconds from the start of the transaction.
+type apmSpan struct {
+	id       string
+	parentID string
+	offset   int64
+	duration int64
+}
+
+// startAPMTrace lays out a trace of at most maxSpans spans, and rewrites event as its transaction.
+func startAPMTrace(event map[string]interface{}, maxSpans int) *apmTrace {
+	trace := &apmTrace{traceID: newAPMID(16), transactionID: newAPMID(8), copied: make(map[string]interface{})}
+
+	duration := apmMinDuration + rand.Int63n(apmMaxDuration-apmMinDuration+1)
+	if obj, key, found := lookupPath(event, "transaction.duration.us"); found {
+		if number, ok := obj[key].(json.Number); ok {
+			if generated, err := number.Int64(); err == nil && generated > 0 {
+				duration = generated
+			}
+		}
+	}
+
+	trace.spans = layoutAPMSpans(trace.transactionID, 0, duration, 1+rand.Intn(maxSpans))
+
+	removeField(event, "span")
+	removeField(event, "parent.id")
+	fields := map[string]interface{}{
+		"processor.event":                "transaction",
+		"trace.id":                       trace.traceID,
+		"transaction.id":                 trace.transactionID,
+		"transaction.duration.us":        json.Number(strconv.FormatInt(duration, 10)),
+		"transaction.sampled":            true,
+		"transaction.span_count.started": len(trace.spans),
+	}
+
+	if obj, key, found := lookupPath(event, "@timestamp"); found {
+		if t, ok := parseTimestamp(obj[key]); ok {
+			trace.start = t
+			fields["timestamp.us"] = json.Number(strconv.FormatInt(t.UnixMicro(), 10))
+		}
+	}
+
+	setFields(event, fields)
+
+	flat := make(map[string]interface{})
+	flattenObject(event, "", flat)
+	for name, value := range flat {
+		for _, prefix := range apmCopiedPrefixes {
+			if strings.HasPrefix(name, prefix) {
+				trace.copied[name] = value
+			}
+		}
+	}
+
+	return trace
+}
+
+// nextSpan rewrites event as the next span of the trace.
+func (t *apmTrace) nextSpan(event map[string]interface{}) {
+	span := t.spans[t.next]
+	t.next += 1
+
+	removeField(event, "transaction")
+	fields := map[string]interface{}{
+		"processor.event":  "span",
+		"trace.id":         t.traceID,
+		"transaction.id":   t.transactionID,
+		"parent.id":        span.parentID,
+		"span.id":          span.id,
+		"span.duration.us": json.Number(strconv.FormatInt(span.duration, 10)),
+	}
+
+	if obj, key, found := lookupPath(event, "@timestamp"); found && !t.start.IsZero() {
+		start := t.start.Add(time.Duration(span.offset) * time.Microsecond)
+		obj[key] = formatTimestamp(start, obj[key])
+		fields["timestamp.us"] = json.Number(strconv.FormatInt(start.UnixMicro(), 10))
+	}
+
+	setFields(event, t.copied)
+	setFields(event, fields)
+}
+
+// layoutAPMSpans lays out n spans within the time from start to end of their parent, in microseconds, at most one
+// per microsecond: its direct children get disjoint parts of that time, and the spans left are nested within them.
+// The spans are returned in the order they start, each one after its parent.
+func layoutAPMSpans(parentID string, start, end int64, n int) []apmSpan {
+	if int64(n) > end-start {
+		n = int(end - start)
+	}
+
+	if n <= 0 {
+		return nil
+	}
+
+	children := 1 + rand.Intn(n)
+	nested := n - children
+	slot := (end - start) / int64(children)
+	spans := make([]apmSpan, 0, n)
+	for i := 0; i < children; i++ {
+		slotStart := start + int64(i)*slot
+		// a random margin at both ends of the slot, a quarter of it at most
+		spanStart := slotStart + rand.Int63n(slot/4+1)
+		spanEnd := slotStart + slot - rand.Int63n(slot/4+1)
+
+		m := nested
+		if i < children-1 {
+			m = rand.Intn(nested + 1)
+		}
+
+		nested -= m
+		span := apmSpan{id: newAPMID(8), parentID: parentID, offset: spanStart, duration: spanEnd - spanStart}
+		spans = append(spans, span)
+		spans = append(spans, layoutAPMSpans(span.id, spanStart, spanEnd, m)...)
+	}
+
+	return spans
+}
+
+// newAPMID returns a random id of size bytes, hex encoded.
+func newAPMID(size int) string {
+	id := make([]byte, size)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// removeField removes the field at the dotted path name from obj, with the fields under it, whether the path is made
+// of nested objects, of dotted keys or of both. The objects left empty are removed too.
+func removeField(obj map[string]interface{}, name string) {
+	for key, value := range obj {
+		switch {
+		case key == name || strings.HasPrefix(key, name+"."):
+			delete(obj, key)
+		case strings.HasPrefix(name, key+"."):
+			if child, ok := value.(map[string]interface{}); ok {
+				removeField(child, name[len(key)+1:])
+				if len(child) == 0 {
+					delete(obj, key)
+				}
+			}
+		}
+	}
+}
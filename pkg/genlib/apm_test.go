@@ -0,0 +1,140 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_NewAPMTraces(t *testing.T) {
+	type apmDocument struct {
+		Timestamp      time.Time `json:"@timestamp"`
+		ProcessorEvent string    `json:"processor.event"`
+		TraceID        string    `json:"trace.id"`
+		TransactionID  string    `json:"transaction.id"`
+		ParentID       string    `json:"parent.id"`
+		SpanID         string    `json:"span.id"`
+		Transaction    struct {
+			Name     string `json:"name"`
+			Duration int64  `json:"duration.us"`
+		} `json:"transaction"`
+		TransactionDuration int64 `json:"transaction.duration.us"`
+		SpanCount           int   `json:"transaction.span_count.started"`
+		SpanDuration        int64 `json:"span.duration.us"`
+		Service             struct {
+			Name string `json:"name"`
+		} `json:"service"`
+	}
+
+	InitGeneratorRandSeed(42)
+	middleware := NewAPMTraces(4)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var transaction apmDocument
+	var spans []apmDocument
+	traces := make(map[string]bool)
+	check := func() {
+		if len(spans) != transaction.SpanCount {
+			t.Errorf("expected %d spans of transaction %s, got %d", transaction.SpanCount, transaction.TransactionID, len(spans))
+		}
+
+		// the ends and the summed durations of the children of the transaction and of the spans, by parent id
+		ends := map[string]time.Time{transaction.TransactionID: transaction.Timestamp.Add(time.Duration(transaction.TransactionDuration) * time.Microsecond)}
+		starts := map[string]time.Time{transaction.TransactionID: transaction.Timestamp}
+		durations := map[string]int64{transaction.TransactionID: transaction.TransactionDuration}
+		children := make(map[string]int64)
+		for _, span := range spans {
+			end, ok := ends[span.ParentID]
+			if !ok {
+				t.Fatalf("expected the parent %s of span %s to come before it", span.ParentID, span.SpanID)
+			}
+
+			spanEnd := span.Timestamp.Add(time.Duration(span.SpanDuration) * time.Microsecond)
+			if span.Timestamp.Before(starts[span.ParentID]) || spanEnd.After(end) {
+				t.Errorf("expected span %s within its parent %s", span.SpanID, span.ParentID)
+			}
+
+			children[span.ParentID] += span.SpanDuration
+			starts[span.SpanID] = span.Timestamp
+			ends[span.SpanID] = spanEnd
+			durations[span.SpanID] = span.SpanDuration
+		}
+
+		for parentID, sum := range children {
+			if sum > durations[parentID] {
+				t.Errorf("expected the durations of the children of %s to sum up to %d at most, got %d", parentID, durations[parentID], sum)
+			}
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		doc := fmt.Sprintf(`{"@timestamp":"%s","service":{"name":"service-%d"},"transaction":{"name":"GET /%d"},"span.id":"generated"}`, start.Add(time.Duration(i)*time.Second).Format(time.RFC3339), i, i)
+		rewritten, err := middleware([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var document apmDocument
+		if err := json.Unmarshal(rewritten, &document); err != nil {
+			t.Fatal(err)
+		}
+
+		switch document.ProcessorEvent {
+		case "transaction":
+			if transaction.TransactionID != "" {
+				check()
+			}
+
+			if traces[document.TraceID] || len(document.TraceID) != 32 || len(document.TransactionID) != 16 {
+				t.Errorf("expected a new trace id and a transaction id, got %s", string(rewritten))
+			}
+
+			if document.SpanID != "" || document.Transaction.Name == "" || document.TransactionDuration < 1000 || document.TransactionDuration > 1000000 {
+				t.Errorf("expected a transaction without span fields and with a duration between 1ms and 1s, got %s", string(rewritten))
+			}
+
+			traces[document.TraceID] = true
+			transaction = document
+			spans = nil
+		case "span":
+			if document.TraceID != transaction.TraceID || document.TransactionID != transaction.TransactionID {
+				t.Errorf("expected the span in trace %s of transaction %s, got %s", transaction.TraceID, transaction.TransactionID, string(rewritten))
+			}
+
+			if document.Transaction.Name != "" || document.Service.Name != transaction.Service.Name {
+				t.Errorf("expected the span without transaction fields and with the service of its transaction, got %s", string(rewritten))
+			}
+
+			spans = append(spans, document)
+		default:
+			t.Fatalf("expected either a transaction or a span, got %s", string(rewritten))
+		}
+	}
+
+	if len(traces) < 100 {
+		t.Errorf("expected traces of 4 spans at most, got %d traces", len(traces))
+	}
+
+	rewritten, err := NewAPMTraces(1)([]byte(`{"transaction":{"duration.us":2500}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var document apmDocument
+	if err := json.Unmarshal(rewritten, &document); err != nil {
+		t.Fatal(err)
+	}
+
+	if document.Transaction.Duration != 2500 {
+		t.Errorf("expected the generated duration of the transaction to be kept, got %s", string(rewritten))
+	}
+
+	if _, err := middleware([]byte("GET / 200")); err == nil {
+		t.Errorf("expected error for a document that is not a JSON object")
+	}
+}
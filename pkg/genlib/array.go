@@ -0,0 +1,139 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"math/rand"
+)
+
+// isArray tells whether the config of the field sets the length of arrays of values.
+func isArray(fieldCfg ConfigField) bool {
+	return fieldCfg.ArrayMax > 0
+}
+
+// isArrayField tells whether the values of the field are generated as arrays: hardcoded values and objects are not.
+func isArrayField(fieldCfg ConfigField, field Field) bool {
+	if !isArray(fieldCfg) || fieldCfg.Value != nil || len(field.Value) > 0 {
+		return false
+	}
+
+	switch field.Type {
+	case FieldTypeObject, FieldTypeNested, FieldTypeFlattened:
+		return false
+	}
+
+	return true
+}
+
+// arrayFieldNames returns the names of the fields whose values are generated as arrays.
+func arrayFieldNames(cfg Config, fields Fields) map[string]struct{} {
+	names := make(map[string]struct{})
+	for _, field := range fields {
+		if fieldCfg, _ := cfg.GetField(field.Name); isArrayField(fieldCfg, field) {
+			names[field.Name] = struct{}{}
+		}
+	}
+
+	return names
+}
+
+// drawArrayLen returns the length of the next array of values of the field, between array_min and array_max.
+func drawArrayLen(fieldCfg ConfigField) int {
+	return fieldCfg.ArrayMin + rand.Intn(fieldCfg.ArrayMax-fieldCfg.ArrayMin+1)
+}
+
+// emitArrayElements calls emit for each of the n values of an array: the counter of the event is spread across them,
+// so that the fields with cardinality take distinct values of their pool within the array.
+func emitArrayElements(state *GenState, fieldCfg ConfigField, n int, emit func(i int) error) error {
+	counter := state.counter
+	defer func() { state.counter = counter }()
+
+	for i := 0; i < n; i++ {
+		state.counter = counter*uint64(fieldCfg.ArrayMax) + uint64(i)
+		if err := emit(i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// arrayValue is an array of values of a field, as returned to text and structured templates.
+type arrayValue []interface{}
+
+// MarshalJSON serializes the values as in structured templates.
+func (a arrayValue) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, v := range a {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		buf.Write(marshalStructured(v))
+	}
+
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// String renders the array as JSON in text templates, it can still be ranged over.
+func (a arrayValue) String() string {
+	b, _ := a.MarshalJSON()
+	return string(b)
+}
+
+// makeArrayStub writes the values of the bound function as a JSON array, quoting them if the template quotes the field.
+func makeArrayStub(quoted bool, fieldCfg ConfigField, boundF emitFNotReturn) emitFNotReturn {
+	return func(state *GenState, buf *bytes.Buffer) error {
+		buf.WriteByte('[')
+		err := emitArrayElements(state, fieldCfg, drawArrayLen(fieldCfg), func(i int) error {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			if quoted {
+				buf.WriteByte('"')
+			}
+
+			if err := boundF(state, buf); err != nil {
+				return err
+			}
+
+			if quoted {
+				buf.WriteByte('"')
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		buf.WriteByte(']')
+		return nil
+	}
+}
+
+// makeArrayStubWithReturn returns the values of the bound function as an arrayValue.
+func makeArrayStubWithReturn(fieldCfg ConfigField, boundF EmitF) EmitF {
+	return func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
+		n := drawArrayLen(fieldCfg)
+		values := make(arrayValue, 0, n)
+		err := emitArrayElements(state, fieldCfg, n, func(int) error {
+			value, err := boundF(state, buf)
+			if err != nil {
+				return err
+			}
+
+			values = append(values, value)
+			return nil
+		})
+
+		return values, err
+	}
+}
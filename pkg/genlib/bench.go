@@ -0,0 +1,147 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"runtime"
+	"time"
+)
+
+// The engines compared by Bench
+const (
+	BenchEngineNoTemplate  = "no template"
+	BenchEnginePlaceholder = TemplateTypePlaceholder
+	BenchEngineGoText      = TemplateTypeGoText
+)
+
+// benchSeed is the seed of the random generators of each engine, so that they all generate the same values
+const benchSeed = 1
+
+// benchReferenceTime is the reference time of the date fields of each engine, for the same reason
+var benchReferenceTime = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// BenchResult is the time and the allocations an engine took to generate a count of events.
+type BenchResult struct {
+	Engine     string
+	Events     uint64
+	Bytes      uint64
+	Duration   time.Duration
+	Allocs     uint64
+	AllocBytes uint64
+}
+
+// EventsPerSecond returns the events generated per second.
+func (r BenchResult) EventsPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+
+	return float64(r.Events) / r.Duration.Seconds()
+}
+
+// AllocsPerEvent returns the heap allocations per event generated.
+func (r BenchResult) AllocsPerEvent() float64 {
+	if r.Events == 0 {
+		return 0
+	}
+
+	return float64(r.Allocs) / float64(r.Events)
+}
+
+// AllocBytesPerEvent returns the bytes allocated on the heap per event generated.
+func (r BenchResult) AllocBytesPerEvent() float64 {
+	if r.Events == 0 {
+		return 0
+	}
+
+	return float64(r.AllocBytes) / float64(r.Events)
+}
+
+// Bench generates events events of all the fields of flds with cfg, once without a template, and once with each of
+// the placeholder and the gotext engines, from the template the one without a template is generating: the engines
+// generate the same events, from the same seed, and only their time and their allocations differ.
+func Bench(cfg Config, flds Fields, events uint64) ([]BenchResult, error) {
+	cfg, err := cfg.ScaleCardinalities(events, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	flds = sortFieldsByRules(cfg, withTimestamp(flds))
+
+	newGenerators := []struct {
+		engine string
+		new    func() (Generator, error)
+	}{
+		{BenchEngineNoTemplate, func() (Generator, error) {
+			return NewGenerator(cfg, flds)
+		}},
+		{BenchEnginePlaceholder, func() (Generator, error) {
+			template, objectKeysField := generateCustomTemplateFromField(cfg, flds)
+			return NewGeneratorWithCustomTemplate(template, cfg, append(flds, objectKeysField...))
+		}},
+		{BenchEngineGoText, func() (Generator, error) {
+			template, objectKeysField := generateTextTemplateFromField(cfg, flds)
+			return NewGeneratorWithTextTemplate(template, cfg, append(flds, objectKeysField...))
+		}},
+	}
+
+	results := make([]BenchResult, 0, len(newGenerators))
+	for _, newGenerator := range newGenerators {
+		InitGeneratorRandSeed(benchSeed)
+		gen, err := newGenerator.new()
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := benchGenerator(gen, events)
+		if closeErr := gen.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		result.Engine = newGenerator.engine
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// benchGenerator generates events events with gen, discarding them, and measures the time and the allocations taken.
+func benchGenerator(gen Generator, events uint64) (BenchResult, error) {
+	state := NewGenState()
+	state.SetEvents(events)
+	state.SetReferenceTime(benchReferenceTime)
+	var buf bytes.Buffer
+	var written uint64
+
+	// the garbage of the previous engine is not accounted to this one
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := uint64(0); i < events; i++ {
+		buf.Reset()
+		if err := gen.Emit(state, &buf); err != nil {
+			return BenchResult{}, err
+		}
+
+		written += uint64(buf.Len())
+	}
+
+	duration := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	return BenchResult{
+		Events:     events,
+		Bytes:      written,
+		Duration:   duration,
+		Allocs:     after.Mallocs - before.Mallocs,
+		AllocBytes: after.TotalAlloc - before.TotalAlloc,
+	}, nil
+}
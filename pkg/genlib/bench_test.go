@@ -0,0 +1,40 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+func Test_Bench(t *testing.T) {
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: event.action\n  enum: [\"start\", \"end\"]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flds := Fields{{Name: "event.action", Type: FieldTypeKeyword}, {Name: "process.pid", Type: FieldTypeLong}, {Name: "source.ip", Type: FieldTypeIP}}
+	results, err := Bench(cfg, flds, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engines := []string{BenchEngineNoTemplate, BenchEnginePlaceholder, BenchEngineGoText}
+	if len(results) != len(engines) {
+		t.Fatalf("expected a result per engine, got %d", len(results))
+	}
+
+	for i, result := range results {
+		if result.Engine != engines[i] || result.Events != 100 || result.Bytes == 0 || result.Duration <= 0 {
+			t.Errorf("expected 100 events generated by %s, got %+v", engines[i], result)
+		}
+	}
+
+	// the same seed and the same template generate the same events
+	if results[0].Bytes != results[1].Bytes {
+		t.Errorf("expected the same bytes without a template and with the placeholder engine, got %d and %d", results[0].Bytes, results[1].Bytes)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+// churn replaces the values of a pool of distinct values over the time of the events: every period, the values of
+// replaced slots of the pool, the ones holding the oldest values, get new values. The pool is a window of values
+// sliding along the periods, each slot holding the value of its generation, the position of the value in the window.
+type churn struct {
+	every    time.Duration
+	replaced int
+	values   int
+}
+
+// churnState is the time the churn of a pool starts at, the time of the first event using it, and the generation
+// of the value held by each slot of the pool, -1 if not known yet.
+type churnState struct {
+	start       time.Time
+	generations []int
+}
+
+// newChurn returns the churn of a pool of values distinct values, nil if c is not set.
+func newChurn(c config.Churn, values int) *churn {
+	if !c.IsSet() || values <= 0 {
+		return nil
+	}
+
+	replaced := (values*c.Percentage + 99) / 100
+	return &churn{every: c.Every, replaced: replaced, values: values}
+}
+
+// churnPoolName is the name the churn state of the pool poolName is cached under.
+func churnPoolName(poolName string) string {
+	return poolName + "#churn"
+}
+
+// renew tells whether the value held by slot idx of the pool poolName must be replaced by a new value at the time of
+// the event being emitted. The values never churn when the events have no time, without a time range or a rate.
+func (c *churn) renew(state *GenState, poolName string, idx int) bool {
+	if c == nil || state.eventTime.IsZero() {
+		return false
+	}
+
+	cs, ok := state.prevCache[churnPoolName(poolName)].(*churnState)
+	if !ok {
+		cs = &churnState{start: state.eventTime, generations: make([]int, c.values)}
+		for i := range cs.generations {
+			cs.generations[i] = -1
+		}
+
+		state.prevCache[churnPoolName(poolName)] = cs
+	}
+
+	var periods int
+	if elapsed := state.eventTime.Sub(cs.start); elapsed > 0 {
+		periods = int(elapsed / c.every)
+	}
+
+	// the window starts at offset, the slot holds the value of the window congruent to it
+	offset := periods * c.replaced
+	generation := offset + ((idx-offset)%c.values+c.values)%c.values
+
+	previous := cs.generations[idx]
+	cs.generations[idx] = generation
+	return previous >= 0 && previous != generation
+}
+
+// renewValue replaces the value at idx of va with a new value of boundF, distinct from the other values when found in
+// a few tries.
+func renewValue(state *GenState, va []bytes.Buffer, idx int, boundF emitFNotReturn) error {
+	var tmp bytes.Buffer
+	for i := 0; i < 11; i++ {
+		tmp.Reset()
+		if err := boundF(state, &tmp); err != nil {
+			return err
+		}
+
+		if !isDupeByteSlice(va, tmp.Bytes()) {
+			break
+		}
+	}
+
+	va[idx] = tmp
+	return nil
+}
+
+// renewValueWithReturn is renewValue for the generators using the values of the fields.
+func renewValueWithReturn(state *GenState, va []interface{}, idx int, boundF EmitF) error {
+	var value interface{}
+	var tmp bytes.Buffer
+	for i := 0; i < 11; i++ {
+		tmp.Reset()
+		var err error
+		if value, err = boundF(state, &tmp); err != nil {
+			return err
+		}
+
+		if !isDupeInterface(va, value) {
+			break
+		}
+	}
+
+	va[idx] = value
+	return nil
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// bytesPerGB is the size of the GB of the per_gb cardinality, the one of the total size of the corpus
+const bytesPerGB = 1000 * 1000 * 1000
+
+// Cardinality is the count of distinct values generated for a field.
+// It can be set either as an integer, the legacy per-mille form, or as an object with the absolute count of distinct values:
+//
+//	cardinality: 100
+//	cardinality:
+//	  distinct: 37
+//
+// or relative to the size of the corpus, as a count of distinct values per million events or per GB, see Scale:
+//
+//	cardinality:
+//	  per_million_events: 500
+type Cardinality struct {
+	// PerMille generates ceil(1000/PerMille) distinct values
+	PerMille int `config:"per_mille"`
+	Distinct int `config:"distinct"`
+	// PerMillionEvents generates PerMillionEvents distinct values per million events of the corpus
+	PerMillionEvents int `config:"per_million_events"`
+	// PerGB generates PerGB distinct values per GB of the total size of the corpus
+	PerGB int `config:"per_gb"`
+}
+
+// Unpack implements ucfg.Unpacker, accepting both the integer and the object forms.
+func (c *Cardinality) Unpack(v interface{}) error {
+	switch v := v.(type) {
+	case int64:
+		c.PerMille = int(v)
+	case uint64:
+		c.PerMille = int(v)
+	case map[string]interface{}:
+		for key, value := range v {
+			n, err := toInt(value)
+			if err != nil {
+				return fmt.Errorf("cardinality %s: %w", key, err)
+			}
+
+			switch key {
+			case "per_mille":
+				c.PerMille = n
+			case "distinct":
+				c.Distinct = n
+			case "per_million_events":
+				c.PerMillionEvents = n
+			case "per_gb":
+				c.PerGB = n
+			default:
+				return fmt.Errorf("unknown cardinality key %s", key)
+			}
+		}
+
+		if len(v) > 1 {
+			return fmt.Errorf("cardinality per_mille, distinct, per_million_events and per_gb are mutually exclusive")
+		}
+	default:
+		return fmt.Errorf("cardinality must be an integer or an object, got %v", v)
+	}
+
+	return nil
+}
+
+// IsSet tells if the cardinality is set, either absolute or relative to the size of the corpus.
+func (c Cardinality) IsSet() bool {
+	return c.Values() > 0 || c.Relative()
+}
+
+// Relative tells if the cardinality is relative to the size of the corpus, and must be scaled to it with Scale.
+func (c Cardinality) Relative() bool {
+	return c.PerMillionEvents > 0 || c.PerGB > 0
+}
+
+// Scale returns the cardinality relative to the size of the corpus as the absolute count of distinct values for a
+// corpus of events events and of totSize bytes, rounded up, either of them 0 if unknown. The absolute cardinality
+// is returned as it is.
+func (c Cardinality) Scale(events, totSize uint64) (Cardinality, error) {
+	var distinct float64
+	switch {
+	case c.PerMillionEvents > 0:
+		if events == 0 {
+			return c, errors.New("cardinality per_million_events requires the count of events of the corpus")
+		}
+
+		distinct = float64(c.PerMillionEvents) * float64(events) / 1e6
+	case c.PerGB > 0:
+		if totSize == 0 {
+			return c, errors.New("cardinality per_gb requires the total size of the corpus")
+		}
+
+		distinct = float64(c.PerGB) * float64(totSize) / bytesPerGB
+	default:
+		return c, nil
+	}
+
+	return Cardinality{Distinct: int(math.Max(1, math.Ceil(distinct)))}, nil
+}
+
+// String returns the count of distinct values of the cardinality, or the relative cardinality.
+func (c Cardinality) String() string {
+	switch {
+	case c.PerMillionEvents > 0:
+		return fmt.Sprintf("%d per million events", c.PerMillionEvents)
+	case c.PerGB > 0:
+		return fmt.Sprintf("%d per GB", c.PerGB)
+	default:
+		return strconv.Itoa(c.Values())
+	}
+}
+
+// Values returns the count of distinct values to generate, 0 if the cardinality is not set or relative to the size
+// of the corpus.
+func (c Cardinality) Values() int {
+	if c.Distinct > 0 {
+		return c.Distinct
+	}
+
+	if c.PerMille > 0 {
+		return int(math.Ceil(1000. / float64(c.PerMille)))
+	}
+
+	return 0
+}
+
+func toInt(v interface{}) (int, error) {
+	switch v := v.(type) {
+	case int64:
+		return int(v), nil
+	case uint64:
+		return int(v), nil
+	default:
+		return 0, fmt.Errorf("must be an integer, got %v", v)
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Churn is the turnover of the distinct values of a field with a cardinality, or of the entities, over the time of
+// the events, like the pods replaced in a cluster: every Every, Percentage percent of the values are replaced by new
+// ones, the oldest first.
+//
+//	churn:
+//	  percentage: 10
+//	  every: 1h
+type Churn struct {
+	Percentage int           `config:"percentage"`
+	Every      time.Duration `config:"every"`
+}
+
+// IsSet tells if the churn is set.
+func (c Churn) IsSet() bool {
+	return c.Percentage > 0
+}
+
+// String returns the churn in its config form.
+func (c Churn) String() string {
+	return fmt.Sprintf("%d%% every %s", c.Percentage, c.Every)
+}
+
+// validateChurn checks the churn of the values of c.
+func validateChurn(c ConfigField) error {
+	if c.Churn.Percentage < 0 || c.Churn.Percentage > 100 {
+		return errors.New("churn percentage must be between 0 and 100")
+	}
+
+	if !c.Churn.IsSet() {
+		return nil
+	}
+
+	if c.Churn.Every <= 0 {
+		return errors.New("churn every must be a positive duration, like 1h")
+	}
+
+	if !c.Cardinality.IsSet() && len(c.Entity) == 0 {
+		return errors.New("churn requires cardinality or entity")
+	}
+
+	return nil
+}
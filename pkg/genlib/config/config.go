@@ -1,26 +1,121 @@
 package config
 
 import (
+	"fmt"
 	"github.com/elastic/go-ucfg/yaml"
 	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 )
 
+// maxVocabulary is the largest vocabulary of the sentence generator
+const maxVocabulary = 1000000
+
 type Config struct {
 	m map[string]ConfigField
+	// lines are the lines of the config entries in the config file, by field name
+	lines map[string]int
+	// aliases are the names of the fields the aliases set by the config entries refer to, by alias
+	aliases map[string]string
 }
 
 type ConfigField struct {
 	Name        string      `config:"name"`
 	Fuzziness   int         `config:"fuzziness"`
-	Range       int         `config:"range"`
-	Cardinality int         `config:"cardinality"`
+	Range       Range       `config:"range"`
+	Cardinality Cardinality `config:"cardinality"`
 	Enum        []string    `config:"enum"`
-	ObjectKeys  []string    `config:"object_keys"`
-	Value       interface{} `config:"value"`
+	// Weights are the relative weights of the values of Enum, in the same order, the values are drawn evenly without them
+	Weights []int `config:"weights"`
+	// ValueFile is the path of a file of the values of Enum, one per line, or of the values and of their Weights as
+	// CSV records if it ends with .csv, for the lists of values too long to be inlined, like the hostnames of an inventory
+	ValueFile      string      `config:"value_file"`
+	ObjectKeys     []string    `config:"object_keys"`
+	Value          interface{} `config:"value"`
+	NullPercentage int         `config:"null_percentage"`
+	OmitPercentage int         `config:"omit_percentage"`
+	Rules          []Rule      `config:"rules"`
+	// Generator is the name of a realistic generator of the values of string fields, like user_agent or url
+	Generator string `config:"generator"`
+	// WordsMin and WordsMax are the bounds of the count of words of the values of the sentence generator
+	WordsMin int `config:"words_min"`
+	WordsMax int `config:"words_max"`
+	// Vocabulary is the count of distinct words of the values of the sentence generator
+	Vocabulary int `config:"vocabulary"`
+	// IPv6Percentage is the percentage of the values of ip fields that are IPv6 addresses
+	IPv6Percentage int `config:"ipv6_percentage"`
+	// CIDR are the subnets the values of ip fields are drawn from, like 10.0.0.0/8 or 2001:db8::/32
+	CIDR []string `config:"cidr"`
+	// CardinalityPerCIDR applies the cardinality to each subnet in CIDR, instead of to the field
+	CardinalityPerCIDR bool `config:"cardinality_per_cidr"`
+	// Precision is the count of decimal places of the values of floating point fields
+	Precision *int `config:"precision"`
+	// Decimals is an alias of Precision, set to it when the config is loaded
+	Decimals *int `config:"decimals"`
+	// GeoFormat is the format of the values of geo_point fields: string, object, geohash or wkt
+	GeoFormat string `config:"geo_format"`
+	// GeoClusters are the areas the values of geo_point fields are clustered around
+	GeoClusters []GeoCluster `config:"geo_clusters"`
+	// ArrayMin and ArrayMax are the bounds of the length of the arrays of values of the field, no arrays if ArrayMax is zero
+	ArrayMin int `config:"array_min"`
+	ArrayMax int `config:"array_max"`
+	// Reroll generates a new value for each reference of the field in the template, instead of the same value for all the references in an event
+	Reroll bool `config:"reroll"`
+	// Entity is the name of the entity the field is an attribute of, like host: an entity is drawn for each event, and
+	// the attributes of the same entity always have the same values. The largest cardinality of the fields is the
+	// count of entities, the attributes with a lower one share their values among the entities
+	Entity string `config:"entity"`
+	// Expression derives the values of the field from the values of other fields in the same event, or in the
+	// previous one, like event.end - event.start: the expressions are arithmetic only, made of field names, numbers,
+	// the + - * / operators, parentheses and the rand, int, uint, abs, min, max and previous functions, without
+	// comparisons, conditionals or strings, see the README
+	Expression string `config:"expression"`
+	// CopyFrom is the name of the field whose value in the same event is copied as the value of the field, like
+	// source.ip for related.ip
+	CopyFrom string `config:"copy_from"`
+	// Quotas are the exact counts of events some values of Enum are drawn for by the end of the run, by value
+	Quotas map[string]int `config:"quotas"`
+	// Escape is how the generated string values are escaped: json, the default, none, or xml, escaping them as XML
+	// character data before the JSON escaping
+	Escape string `config:"escape"`
+	// Rename is the name the field is referenced by in the templates and written under in the events, instead of its
+	// name in the fields definition, like the name of the original vendor field for an ECS field
+	Rename string `config:"rename"`
+	// Alias are other names the field can be referenced by in the templates, writing the same value in an event
+	Alias []string `config:"alias"`
+	// Skew is the popularity of the distinct values of the field, or of the entities of its entity, drawn evenly
+	// without it
+	Skew Skew `config:"skew"`
+	// Churn is the turnover of the distinct values of the field, or of the entities of its entity, over the time of
+	// the events
+	Churn Churn `config:"churn"`
+}
+
+// GeoCluster is an area the values of a geo_point field are clustered around: a well-known city, or a centroid.
+type GeoCluster struct {
+	City string  `config:"city"`
+	Lat  float64 `config:"lat"`
+	Lon  float64 `config:"lon"`
+	// Radius is the radius of the area in kilometers
+	Radius float64 `config:"radius"`
+}
+
+// Rule overrides the config of a field in the events where the condition is met.
+type Rule struct {
+	When Condition   `config:"when"`
+	Then ConfigField `config:"then"`
+}
+
+// Condition is met when the value generated for Field in the same event is equal to Equals.
+type Condition struct {
+	Field  string      `config:"field"`
+	Equals interface{} `config:"equals"`
 }
 
-func LoadConfig(configFile string) (Config, error) {
+func LoadConfig(configFile string, opts ...LoadOption) (Config, error) {
 	if len(configFile) == 0 {
 		return Config{}, nil
 	}
@@ -35,34 +130,615 @@ func LoadConfig(configFile string) (Config, error) {
 		return Config{}, err
 	}
 
-	return LoadConfigFromYaml(data)
+	// the value files are relative to the config file, unless set otherwise
+	opts = append([]LoadOption{WithBaseDir(filepath.Dir(configFile))}, opts...)
+	return LoadConfigFromYaml(data, opts...)
 }
 
-func LoadConfigFromYaml(c []byte) (Config, error) {
+func LoadConfigFromYaml(c []byte, opts ...LoadOption) (Config, error) {
+	var options loadOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
 
 	cfg, err := yaml.NewConfig(c)
 	if err != nil {
 		return Config{}, err
 	}
 
+	pos := parsePositions(c)
+	if options.strict {
+		if err := checkUnknownKeys(pos); err != nil {
+			return Config{}, err
+		}
+	}
+
 	var cfgList []ConfigField
 	err = cfg.Unpack(&cfgList)
 	if err != nil {
-		return Config{}, err
+		return Config{}, pos.unpackError(err)
 	}
 
 	outCfg := Config{
-		m: make(map[string]ConfigField),
+		m:     make(map[string]ConfigField),
+		lines: make(map[string]int),
 	}
 
-	for _, c := range cfgList {
+	for i, c := range cfgList {
+		if c, err = loadValueFile(c, options.baseDir); err != nil {
+			return Config{}, pos.entryError(i, "field %s: %v", c.Name, err)
+		}
+
+		if c.NullPercentage < 0 || c.OmitPercentage < 0 || c.NullPercentage+c.OmitPercentage > 100 {
+			return Config{}, pos.entryError(i, "field %s: null_percentage and omit_percentage must be positive and sum up to 100 at most", c.Name)
+		}
+
+		if c.Cardinality.PerMille < 0 || c.Cardinality.Distinct < 0 || c.Cardinality.PerMillionEvents < 0 || c.Cardinality.PerGB < 0 {
+			return Config{}, pos.entryError(i, "field %s: cardinality must be positive", c.Name)
+		}
+
+		if err := validateRange(c); err != nil {
+			return Config{}, pos.entryError(i, "field %s: %v", c.Name, err)
+		}
+
+		if c, err = resolveDecimals(c); err != nil {
+			return Config{}, pos.entryError(i, "field %s: %v", c.Name, err)
+		}
+
+		if err := validateEnum(c); err != nil {
+			return Config{}, pos.entryError(i, "field %s: %v", c.Name, err)
+		}
+
+		if err := validateQuotas(c); err != nil {
+			return Config{}, pos.entryError(i, "field %s: %v", c.Name, err)
+		}
+
+		if len(c.CopyFrom) > 0 && (c.CopyFrom == c.Name || c.Value != nil || len(c.Enum) > 0 || c.Cardinality.IsSet() || len(c.Entity) > 0 || len(c.Rules) > 0 || c.ArrayMax > 0 || len(c.Expression) > 0) {
+			return Config{}, pos.entryError(i, "field %s: copy_from cannot be the field itself, nor be combined with value, enum, cardinality, entity, rules, array_min, array_max or expression", c.Name)
+		}
+
+		if len(c.Expression) > 0 && (c.Value != nil || len(c.Enum) > 0 || c.Cardinality.IsSet() || len(c.Entity) > 0 || len(c.Rules) > 0 || c.ArrayMax > 0) {
+			return Config{}, pos.entryError(i, "field %s: expression cannot be combined with value, enum, cardinality, entity, rules, array_min or array_max", c.Name)
+		}
+
+		if err := validateIP(c); err != nil {
+			return Config{}, pos.entryError(i, "field %s: %v", c.Name, err)
+		}
+
+		if err := validateSentence(c); err != nil {
+			return Config{}, pos.entryError(i, "field %s: %v", c.Name, err)
+		}
+
+		if err := validateGeo(c); err != nil {
+			return Config{}, pos.entryError(i, "field %s: %v", c.Name, err)
+		}
+
+		if err := validateEscape(c); err != nil {
+			return Config{}, pos.entryError(i, "field %s: %v", c.Name, err)
+		}
+
+		if err := validateSkew(c); err != nil {
+			return Config{}, pos.entryError(i, "field %s: %v", c.Name, err)
+		}
+
+		if err := validateChurn(c); err != nil {
+			return Config{}, pos.entryError(i, "field %s: %v", c.Name, err)
+		}
+
+		if c.ArrayMin < 0 || c.ArrayMin > c.ArrayMax {
+			return Config{}, pos.entryError(i, "field %s: array_min and array_max must be positive, with array_min not greater than array_max", c.Name)
+		}
+
+		for j, rule := range c.Rules {
+			if len(rule.When.Field) == 0 || rule.When.Equals == nil {
+				return Config{}, pos.entryError(i, "field %s: rule %d must provide when field and equals", c.Name, j)
+			}
+
+			if rule.When.Field == c.Name {
+				return Config{}, pos.entryError(i, "field %s: rule %d cannot depend on the field itself", c.Name, j)
+			}
+
+			if len(rule.Then.Rules) > 0 || rule.Then.NullPercentage > 0 || rule.Then.OmitPercentage > 0 || rule.Then.ArrayMin > 0 || rule.Then.ArrayMax > 0 || rule.Then.Reroll || len(rule.Then.Entity) > 0 || len(rule.Then.Quotas) > 0 || len(rule.Then.Expression) > 0 || len(rule.Then.CopyFrom) > 0 || len(rule.Then.Escape) > 0 || len(rule.Then.Rename) > 0 || len(rule.Then.Alias) > 0 {
+				return Config{}, pos.entryError(i, "field %s: rule %d then cannot provide rules, null_percentage, omit_percentage, array_min, array_max, reroll, entity, quotas, expression, copy_from, escape, rename or alias", c.Name, j)
+			}
+
+			if c.Rules[j].Then, err = loadValueFile(rule.Then, options.baseDir); err != nil {
+				return Config{}, pos.entryError(i, "field %s: rule %d %v", c.Name, j, err)
+			}
+
+			rule = c.Rules[j]
+			if err := validateRange(rule.Then); err != nil {
+				return Config{}, pos.entryError(i, "field %s: rule %d %v", c.Name, j, err)
+			}
+
+			if c.Rules[j].Then, err = resolveDecimals(rule.Then); err != nil {
+				return Config{}, pos.entryError(i, "field %s: rule %d %v", c.Name, j, err)
+			}
+
+			rule = c.Rules[j]
+
+			if err := validateEnum(rule.Then); err != nil {
+				return Config{}, pos.entryError(i, "field %s: rule %d %v", c.Name, j, err)
+			}
+
+			if err := validateIP(rule.Then); err != nil {
+				return Config{}, pos.entryError(i, "field %s: rule %d %v", c.Name, j, err)
+			}
+
+			if err := validateSentence(rule.Then); err != nil {
+				return Config{}, pos.entryError(i, "field %s: rule %d %v", c.Name, j, err)
+			}
+
+			if err := validateGeo(rule.Then); err != nil {
+				return Config{}, pos.entryError(i, "field %s: rule %d %v", c.Name, j, err)
+			}
+
+			if err := validateSkew(rule.Then); err != nil {
+				return Config{}, pos.entryError(i, "field %s: rule %d %v", c.Name, j, err)
+			}
+
+			if err := validateChurn(rule.Then); err != nil {
+				return Config{}, pos.entryError(i, "field %s: rule %d %v", c.Name, j, err)
+			}
+		}
+
 		outCfg.m[c.Name] = c
+		outCfg.lines[c.Name] = pos.line(strconv.Itoa(i))
+	}
+
+	if err := validateEntities(cfgList, pos); err != nil {
+		return Config{}, err
+	}
+
+	if outCfg.aliases, err = loadAliases(cfgList, pos); err != nil {
+		return Config{}, err
 	}
 
 	return outCfg, nil
 }
 
+// loadAliases returns the names of the fields the aliases of the config entries refer to, by alias, checking that
+// the new names of the renamed fields and the aliases are not the names of other config entries, nor taken twice.
+func loadAliases(cfgList []ConfigField, pos positions) (map[string]string, error) {
+	taken := make(map[string]bool, len(cfgList))
+	for _, c := range cfgList {
+		taken[c.Name] = true
+	}
+
+	aliases := make(map[string]string)
+	for i, c := range cfgList {
+		fieldName := c.Name
+		if len(c.Rename) > 0 {
+			if taken[c.Rename] {
+				return nil, pos.entryError(i, "field %s: rename %s is the name of a field, a rename or an alias", c.Name, c.Rename)
+			}
+
+			fieldName = c.Rename
+			taken[fieldName] = true
+		}
+
+		for _, alias := range c.Alias {
+			if taken[alias] {
+				return nil, pos.entryError(i, "field %s: alias %s is the name of a field, a rename or an alias", c.Name, alias)
+			}
+
+			aliases[alias] = fieldName
+			taken[alias] = true
+		}
+	}
+
+	return aliases, nil
+}
+
+// validateEntities checks that at least one of the attributes of each entity sets its cardinality, the count of
+// entities, and that the attributes agree on the cardinalities relative to the size of the corpus, that cannot be
+// compared before being scaled, and on the skew and the churn of the entities.
+func validateEntities(cfgList []ConfigField, pos positions) error {
+	cardinalities := make(map[string]Cardinality)
+	for i, c := range cfgList {
+		cardinality := c.Cardinality
+		if len(c.Entity) == 0 || !cardinality.IsSet() {
+			continue
+		}
+
+		if entities, ok := cardinalities[c.Entity]; ok && (entities.Relative() || cardinality.Relative()) && entities.String() != cardinality.String() {
+			return pos.entryError(i, "field %s: cardinality %s of entity %s differs from the one of its other fields, %s, as relative to the size of the corpus", c.Name, cardinality, c.Entity, entities)
+		}
+
+		cardinalities[c.Entity] = cardinality
+	}
+
+	skews := make(map[string]Skew)
+	for i, c := range cfgList {
+		if len(c.Entity) == 0 || !c.Skew.IsSet() {
+			continue
+		}
+
+		if skew, ok := skews[c.Entity]; ok && skew.String() != c.Skew.String() {
+			return pos.entryError(i, "field %s: skew %s of entity %s differs from the one of its other fields, %s", c.Name, c.Skew, c.Entity, skew)
+		}
+
+		skews[c.Entity] = c.Skew
+	}
+
+	churns := make(map[string]Churn)
+	for i, c := range cfgList {
+		if len(c.Entity) == 0 || !c.Churn.IsSet() {
+			continue
+		}
+
+		if churn, ok := churns[c.Entity]; ok && churn != c.Churn {
+			return pos.entryError(i, "field %s: churn %s of entity %s differs from the one of its other fields, %s", c.Name, c.Churn, c.Entity, churn)
+		}
+
+		churns[c.Entity] = c.Churn
+	}
+
+	for i, c := range cfgList {
+		if _, ok := cardinalities[c.Entity]; len(c.Entity) > 0 && !ok {
+			return pos.entryError(i, "field %s: entity %s must have the count of entities set as the cardinality of one of its fields", c.Name, c.Entity)
+		}
+	}
+
+	return nil
+}
+
+// EntityCardinality returns the count of entities of entity, the largest cardinality of its fields, 0 if not an
+// entity.
+func (c Config) EntityCardinality(entity string) int {
+	var entities int
+	for _, fieldCfg := range c.m {
+		if fieldCfg.Entity == entity && fieldCfg.Cardinality.Values() > entities {
+			entities = fieldCfg.Cardinality.Values()
+		}
+	}
+
+	return entities
+}
+
 func (c Config) GetField(fieldName string) (ConfigField, bool) {
 	v, ok := c.m[fieldName]
 	return v, ok
 }
+
+// WithField returns a copy of the config where the config of fieldCfg.Name is replaced by fieldCfg.
+func (c Config) WithField(fieldCfg ConfigField) Config {
+	m := make(map[string]ConfigField, len(c.m)+1)
+	for k, v := range c.m {
+		m[k] = v
+	}
+
+	m[fieldCfg.Name] = fieldCfg
+	return Config{m: m, lines: c.lines, aliases: c.aliases}
+}
+
+// EntitySkew returns the skew of the entities of entity, set on its fields, unset if none sets it.
+func (c Config) EntitySkew(entity string) Skew {
+	for _, fieldCfg := range c.m {
+		if fieldCfg.Entity == entity && fieldCfg.Skew.IsSet() {
+			return fieldCfg.Skew
+		}
+	}
+
+	return Skew{}
+}
+
+// EntityChurn returns the churn of the entities of entity, set on its fields, unset if none sets it.
+func (c Config) EntityChurn(entity string) Churn {
+	for _, fieldCfg := range c.m {
+		if fieldCfg.Entity == entity && fieldCfg.Churn.IsSet() {
+			return fieldCfg.Churn
+		}
+	}
+
+	return Churn{}
+}
+
+// Resolve returns the name of the field alias is an alias of, or alias itself if it is not an alias.
+func (c Config) Resolve(alias string) string {
+	if fieldName, ok := c.aliases[alias]; ok {
+		return fieldName
+	}
+
+	return alias
+}
+
+// Renamed returns a copy of the config where the config entries with rename are keyed by their new name, and the
+// rules and the copies referencing them reference their new name, with the names of the renamed fields in the
+// fields definition by their new name.
+func (c Config) Renamed() (Config, map[string]string) {
+	renames := make(map[string]string)
+	for name, fieldCfg := range c.m {
+		if len(fieldCfg.Rename) > 0 {
+			renames[name] = fieldCfg.Rename
+		}
+	}
+
+	if len(renames) == 0 {
+		return c, renames
+	}
+
+	rename := func(name string) string {
+		if newName, ok := renames[name]; ok {
+			return newName
+		}
+
+		return name
+	}
+
+	m := make(map[string]ConfigField, len(c.m))
+	lines := make(map[string]int, len(c.lines))
+	for name, fieldCfg := range c.m {
+		fieldCfg.Name = rename(name)
+		fieldCfg.Rename = ""
+		fieldCfg.CopyFrom = rename(fieldCfg.CopyFrom)
+		if len(fieldCfg.Rules) > 0 {
+			rules := make([]Rule, len(fieldCfg.Rules))
+			for i, rule := range fieldCfg.Rules {
+				rule.When.Field = rename(rule.When.Field)
+				rules[i] = rule
+			}
+
+			fieldCfg.Rules = rules
+		}
+
+		m[fieldCfg.Name] = fieldCfg
+		lines[fieldCfg.Name] = c.lines[name]
+	}
+
+	return Config{m: m, lines: lines, aliases: c.aliases}, renames
+}
+
+// ScaleCardinalities returns a copy of the config where the cardinalities relative to the size of the corpus, of the
+// fields and of their rules, are scaled to a corpus of events events and of totSize bytes, see Cardinality.Scale.
+func (c Config) ScaleCardinalities(events, totSize uint64) (Config, error) {
+	m := make(map[string]ConfigField, len(c.m))
+	for name, fieldCfg := range c.m {
+		cardinality, err := fieldCfg.Cardinality.Scale(events, totSize)
+		if err != nil {
+			return Config{}, c.fieldError(name, "%w", err)
+		}
+
+		fieldCfg.Cardinality = cardinality
+		if len(fieldCfg.Rules) > 0 {
+			rules := make([]Rule, len(fieldCfg.Rules))
+			for i, rule := range fieldCfg.Rules {
+				if rule.Then.Cardinality, err = rule.Then.Cardinality.Scale(events, totSize); err != nil {
+					return Config{}, c.fieldError(name, "rule %d: %w", i, err)
+				}
+
+				rules[i] = rule
+			}
+
+			fieldCfg.Rules = rules
+		}
+
+		m[name] = fieldCfg
+	}
+
+	return Config{m: m, lines: c.lines, aliases: c.aliases}, nil
+}
+
+// Line returns the line of the config entry of fieldName in the config file, 0 if unknown.
+func (c Config) Line(fieldName string) int {
+	return c.lines[fieldName]
+}
+
+// fieldError returns the error of the config entry of fieldName, prefixed by its line if known.
+func (c Config) fieldError(fieldName, format string, args ...interface{}) error {
+	err := fmt.Errorf("field %s: "+format, append([]interface{}{fieldName}, args...)...)
+	if line := c.Line(fieldName); line > 0 {
+		return fmt.Errorf("line %d: %w", line, err)
+	}
+
+	return err
+}
+
+// Matches tells if value, the value generated for the field of the condition, meets the condition.
+func (c Condition) Matches(value string) bool {
+	return fmt.Sprint(c.Equals) == value
+}
+
+// validateEnum checks the weights of the values of enum.
+func validateEnum(c ConfigField) error {
+	if len(c.Weights) == 0 {
+		return nil
+	}
+
+	if len(c.Weights) != len(c.Enum) {
+		return fmt.Errorf("weights must provide a weight for each value of enum")
+	}
+
+	var total int
+	for _, weight := range c.Weights {
+		if weight < 0 {
+			return fmt.Errorf("weights must be positive")
+		}
+
+		total += weight
+	}
+
+	if total == 0 {
+		return fmt.Errorf("weights must not be all zero")
+	}
+
+	return nil
+}
+
+// validateQuotas checks the quotas of the values of enum: each value of the field must be drawn once per event, for
+// the quotas to be counted in events.
+func validateQuotas(c ConfigField) error {
+	if len(c.Quotas) == 0 {
+		return nil
+	}
+
+	if c.Value != nil || c.Cardinality.IsSet() || len(c.Entity) > 0 || len(c.Rules) > 0 || c.NullPercentage > 0 || c.OmitPercentage > 0 || c.ArrayMax > 0 || c.Reroll {
+		return fmt.Errorf("quotas cannot be combined with value, cardinality, entity, rules, null_percentage, omit_percentage, array_min, array_max or reroll")
+	}
+
+	values := make(map[string]bool, len(c.Enum))
+	for _, value := range c.Enum {
+		if values[value] {
+			return fmt.Errorf("quotas require the values of enum to be distinct, %s is repeated", value)
+		}
+
+		values[value] = true
+	}
+
+	for value, quota := range c.Quotas {
+		if !values[value] {
+			return fmt.Errorf("quotas value %s is not a value of enum", value)
+		}
+
+		if quota < 0 {
+			return fmt.Errorf("quotas must be positive")
+		}
+	}
+
+	return nil
+}
+
+// CheckQuotas checks that the quotas of the values of enum of the fields can be met in a run of events events: they
+// must not sum up to more than events, and to exactly events for the fields whose values all have a quota.
+func (c Config) CheckQuotas(events uint64) error {
+	names := make([]string, 0, len(c.m))
+	for name, fieldCfg := range c.m {
+		if len(fieldCfg.Quotas) > 0 {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		fieldCfg := c.m[name]
+		if events == 0 {
+			return c.fieldError(name, "quotas require the count of events to generate")
+		}
+
+		var total uint64
+		for _, quota := range fieldCfg.Quotas {
+			total += uint64(quota)
+		}
+
+		if total > events {
+			return c.fieldError(name, "quotas sum up to %d, more than the %d events to generate", total, events)
+		}
+
+		if total < events && !fieldCfg.hasUnquotedValues() {
+			return c.fieldError(name, "quotas sum up to %d, less than the %d events to generate, and no other value of enum can be drawn", total, events)
+		}
+	}
+
+	return nil
+}
+
+// HasQuotas tells if any field has quotas of the values of enum.
+func (c Config) HasQuotas() bool {
+	for _, fieldCfg := range c.m {
+		if len(fieldCfg.Quotas) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasUnquotedValues tells if some values of enum without a quota can be drawn, having a weight when weights are set.
+func (c ConfigField) hasUnquotedValues() bool {
+	for i, value := range c.Enum {
+		if _, ok := c.Quotas[value]; ok {
+			continue
+		}
+
+		if len(c.Weights) == 0 || c.Weights[i] > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateIP checks the settings of the values of ip fields.
+func validateIP(c ConfigField) error {
+	if c.IPv6Percentage < 0 || c.IPv6Percentage > 100 {
+		return fmt.Errorf("ipv6_percentage must be between 0 and 100")
+	}
+
+	// the subnets set the IP versions of the values
+	if len(c.CIDR) > 0 && c.IPv6Percentage > 0 {
+		return fmt.Errorf("cidr and ipv6_percentage are mutually exclusive, the subnets of cidr set the IP versions")
+	}
+
+	for _, cidr := range c.CIDR {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("cidr %s is not a valid subnet, like 10.0.0.0/8 or 2001:db8::/32", cidr)
+		}
+	}
+
+	return nil
+}
+
+// resolveDecimals returns c with its decimals set as its precision, and checks the precision.
+func resolveDecimals(c ConfigField) (ConfigField, error) {
+	if c.Decimals != nil {
+		if c.Precision != nil {
+			return c, fmt.Errorf("decimals and precision are mutually exclusive, decimals is an alias of precision")
+		}
+
+		c.Precision, c.Decimals = c.Decimals, nil
+	}
+
+	if c.Precision != nil && *c.Precision < 0 {
+		return c, fmt.Errorf("precision must be positive")
+	}
+
+	return c, nil
+}
+
+// validateSentence checks the settings of the values of the sentence generator.
+func validateSentence(c ConfigField) error {
+	if c.WordsMin < 0 || c.WordsMax < 0 || c.Vocabulary < 0 {
+		return fmt.Errorf("words_min, words_max and vocabulary must be positive")
+	}
+
+	if c.WordsMax > 0 && c.WordsMin > c.WordsMax {
+		return fmt.Errorf("words_min must not be greater than words_max")
+	}
+
+	if c.Vocabulary > maxVocabulary {
+		return fmt.Errorf("vocabulary must be %d at most", maxVocabulary)
+	}
+
+	return nil
+}
+
+// validateEscape checks the escaping of the values.
+func validateEscape(c ConfigField) error {
+	switch c.Escape {
+	case "", "json", "none", "xml":
+		return nil
+	default:
+		return fmt.Errorf("escape must be one of json, none and xml, got %s", c.Escape)
+	}
+}
+
+// validateGeo checks the settings of the values of geo_point fields.
+func validateGeo(c ConfigField) error {
+	switch c.GeoFormat {
+	case "", "string", "object", "geohash", "wkt":
+	default:
+		return fmt.Errorf("geo_format must be one of string, object, geohash and wkt, got %s", c.GeoFormat)
+	}
+
+	for j, cluster := range c.GeoClusters {
+		if cluster.Lat < -90 || cluster.Lat > 90 || cluster.Lon < -180 || cluster.Lon > 180 {
+			return fmt.Errorf("geo_clusters %d lat must be between -90 and 90, and lon between -180 and 180", j)
+		}
+
+		if cluster.Radius < 0 {
+			return fmt.Errorf("geo_clusters %d radius must be positive", j)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/go-ucfg"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// positions locates the config entries and their keys in the YAML document, for the error messages
+type positions struct {
+	root *yamlv3.Node
+}
+
+// parsePositions parses the YAML document c, the errors are ignored since the document is parsed by ucfg as well.
+func parsePositions(c []byte) positions {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(c, &doc); err != nil || len(doc.Content) == 0 {
+		return positions{}
+	}
+
+	return positions{root: doc.Content[0]}
+}
+
+// line returns the line of the node at path, made of mapping keys and sequence indexes, 0 if not found.
+// The line of a mapping value is the one of its key.
+func (p positions) line(path ...string) int {
+	node := p.root
+	if node == nil {
+		return 0
+	}
+
+	line := node.Line
+	for _, element := range path {
+		switch node.Kind {
+		case yamlv3.SequenceNode:
+			i, err := strconv.Atoi(element)
+			if err != nil || i < 0 || i >= len(node.Content) {
+				return 0
+			}
+
+			node = node.Content[i]
+			line = node.Line
+		case yamlv3.MappingNode:
+			found := false
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == element {
+					line = node.Content[i].Line
+					node = node.Content[i+1]
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return 0
+			}
+		default:
+			return 0
+		}
+	}
+
+	return line
+}
+
+// entryError returns the error of the config entry at index i, prefixed by its line if known.
+func (p positions) entryError(i int, format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	if line := p.line(strconv.Itoa(i)); line > 0 {
+		return fmt.Errorf("line %d: %w", line, err)
+	}
+
+	return err
+}
+
+// unpackError prefixes the error of unpacking the config with the line of the key it refers to, if known.
+func (p positions) unpackError(err error) error {
+	var ucfgErr ucfg.Error
+	if !errors.As(err, &ucfgErr) || len(ucfgErr.Path()) == 0 {
+		return err
+	}
+
+	if line := p.line(strings.Split(ucfgErr.Path(), ".")...); line > 0 {
+		return fmt.Errorf("line %d: %w", line, err)
+	}
+
+	return err
+}
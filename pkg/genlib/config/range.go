@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Range bounds the values generated for a numeric field.
+// It can be set either as an integer, the legacy form generating the values from 0 to it, excluded, or as an object
+// with the bounds of the values, both included, for the values to fall in the bounds meaningful for the field:
+//
+//	range: 100
+//	range:
+//	  min: 1
+//	  max: 65535
+type Range struct {
+	// Magnitude generates the values from 0 to Magnitude, excluded
+	Magnitude int
+	Min       float64 `config:"min"`
+	Max       float64 `config:"max"`
+	// Bounded tells the range is set with Min and Max
+	Bounded bool
+}
+
+// Unpack implements ucfg.Unpacker, accepting both the integer and the object forms.
+func (r *Range) Unpack(v interface{}) error {
+	switch v := v.(type) {
+	case int64:
+		r.Magnitude = int(v)
+	case uint64:
+		r.Magnitude = int(v)
+	case map[string]interface{}:
+		min, hasMin := v["min"]
+		max, hasMax := v["max"]
+		if !hasMin || !hasMax || len(v) > 2 {
+			return fmt.Errorf("range must provide both min and max only")
+		}
+
+		var err error
+		if r.Min, err = toFloat(min); err != nil {
+			return fmt.Errorf("range min: %w", err)
+		}
+
+		if r.Max, err = toFloat(max); err != nil {
+			return fmt.Errorf("range max: %w", err)
+		}
+
+		r.Bounded = true
+	default:
+		return fmt.Errorf("range must be an integer or an object, got %v", v)
+	}
+
+	return nil
+}
+
+// IsSet tells if the range is set, in either form.
+func (r Range) IsSet() bool {
+	return r.Magnitude > 0 || r.Bounded
+}
+
+// Contains tells whether v is within the bounds of the range, always true for the legacy form.
+func (r Range) Contains(v float64) bool {
+	return !r.Bounded || (v >= r.Min && v <= r.Max)
+}
+
+// validateRange checks the range of the values of c.
+func validateRange(c ConfigField) error {
+	if c.Range.Magnitude < 0 || c.Fuzziness < 0 {
+		return fmt.Errorf("range and fuzziness must be positive")
+	}
+
+	if c.Range.Bounded && c.Range.Min > c.Range.Max {
+		return fmt.Errorf("range min must not be greater than max")
+	}
+
+	return nil
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("must be a number, got %v", v)
+	}
+}
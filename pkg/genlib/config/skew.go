@@ -0,0 +1,97 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Skew is the popularity of the distinct values of a field with a cardinality, or of the entities, so that a handful
+// of values are drawn for most events, like the few hosts generating most of the logs in production. It is set
+// either as the exponent of a Zipf distribution, drawing the n-th value with a probability proportional to 1/n^zipf:
+//
+//	skew:
+//	  zipf: 1.2
+//
+// or as the percentages of the events drawing the top values, the other values sharing the rest evenly:
+//
+//	skew:
+//	  top: [50, 20]
+type Skew struct {
+	Zipf float64 `config:"zipf"`
+	Top  []int   `config:"top"`
+}
+
+// IsSet tells if the skew is set, in either form.
+func (s Skew) IsSet() bool {
+	return s.Zipf > 0 || len(s.Top) > 0
+}
+
+// String returns the skew in its config form.
+func (s Skew) String() string {
+	if len(s.Top) > 0 {
+		return fmt.Sprintf("top %v", s.Top)
+	}
+
+	return fmt.Sprintf("zipf %g", s.Zipf)
+}
+
+// Weights returns the relative weights of drawing each of n values, or entities, in order.
+func (s Skew) Weights(n int) []float64 {
+	weights := make([]float64, n)
+	if len(s.Top) == 0 {
+		for i := range weights {
+			weights[i] = 1 / math.Pow(float64(i+1), s.Zipf)
+		}
+
+		return weights
+	}
+
+	rest := 100
+	for i, percentage := range s.Top {
+		rest -= percentage
+		if i < n {
+			weights[i] = float64(percentage)
+		}
+	}
+
+	for i := len(s.Top); i < n; i++ {
+		weights[i] = float64(rest) / float64(n-len(s.Top))
+	}
+
+	return weights
+}
+
+// validateSkew checks the skew of the values of c.
+func validateSkew(c ConfigField) error {
+	if c.Skew.Zipf < 0 {
+		return errors.New("skew zipf must be positive")
+	}
+
+	if !c.Skew.IsSet() {
+		return nil
+	}
+
+	if c.Skew.Zipf > 0 && len(c.Skew.Top) > 0 {
+		return errors.New("skew zipf and top are mutually exclusive")
+	}
+
+	var total int
+	for _, percentage := range c.Skew.Top {
+		if percentage <= 0 {
+			return errors.New("skew top percentages must be positive")
+		}
+
+		total += percentage
+	}
+
+	if total > 100 {
+		return fmt.Errorf("skew top percentages must sum up to 100 at most, got %d", total)
+	}
+
+	if !c.Cardinality.IsSet() && len(c.Entity) == 0 {
+		return errors.New("skew requires cardinality or entity")
+	}
+
+	return nil
+}
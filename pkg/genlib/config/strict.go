@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.uber.org/multierr"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+type loadOptions struct {
+	strict  bool
+	baseDir string
+}
+
+type LoadOption func(*loadOptions)
+
+// WithStrict makes the loading fail on unknown config keys, instead of ignoring them.
+func WithStrict() LoadOption {
+	return func(o *loadOptions) {
+		o.strict = true
+	}
+}
+
+// WithBaseDir resolves the relative paths of the value_file config entries against dir, instead of the working
+// directory: LoadConfig resolves them against the directory of the config file.
+func WithBaseDir(dir string) LoadOption {
+	return func(o *loadOptions) {
+		o.baseDir = dir
+	}
+}
+
+// unknownKey is a key of a config entry not matching a ConfigField key
+type unknownKey struct {
+	path string
+	line int
+}
+
+// checkUnknownKeys returns an error for each key of the config entries that does not match a ConfigField key,
+// prefixed by the line of the key.
+func checkUnknownKeys(pos positions) error {
+	if pos.root == nil || pos.root.Kind != yamlv3.SequenceNode {
+		return nil
+	}
+
+	var errs []error
+	for _, entry := range pos.root.Content {
+		var name interface{}
+		if nameNode := mappingValue(entry, "name"); nameNode != nil {
+			name = nameNode.Value
+		}
+
+		for _, key := range unknownKeys(entry, reflect.TypeOf(ConfigField{}), "") {
+			errs = append(errs, fmt.Errorf("line %d: field %v: unknown config key %s", key.line, name, key.path))
+		}
+	}
+
+	return multierr.Combine(errs...)
+}
+
+// mappingValue returns the value of key in the mapping node, nil if not found.
+func mappingValue(node *yamlv3.Node, key string) *yamlv3.Node {
+	if node.Kind != yamlv3.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// unknownKeys returns the dotted path of the keys of the mapping node not matching the config tags of the struct type t,
+// checking the nested structs as well.
+func unknownKeys(node *yamlv3.Node, t reflect.Type, prefix string) []unknownKey {
+	if node.Kind != yamlv3.MappingNode {
+		return nil
+	}
+
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		fields[t.Field(i).Tag.Get("config")] = t.Field(i).Type
+	}
+
+	var unknown []unknownKey
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		fieldType, ok := fields[key.Value]
+		if !ok {
+			unknown = append(unknown, unknownKey{path: prefix + key.Value, line: key.Line})
+			continue
+		}
+
+		switch {
+		case fieldType.Kind() == reflect.Struct:
+			unknown = append(unknown, unknownKeys(value, fieldType, prefix+key.Value+".")...)
+		case fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Struct && value.Kind == yamlv3.SequenceNode:
+			for j, item := range value.Content {
+				unknown = append(unknown, unknownKeys(item, fieldType.Elem(), fmt.Sprintf("%s%s.%d.", prefix, key.Value, j))...)
+			}
+		}
+	}
+
+	return unknown
+}
+
+// ValidateFields returns an error for each config entry, or rule condition, referencing a field not in fieldNames.
+// The keys listed in the object_keys of a field are valid field names as well.
+func (c Config) ValidateFields(fieldNames []string) error {
+	known := make(map[string]struct{}, len(fieldNames))
+	for _, fieldName := range fieldNames {
+		known[fieldName] = struct{}{}
+		fieldCfg, _ := c.GetField(fieldName)
+		for _, objectKey := range fieldCfg.ObjectKeys {
+			known[strings.TrimSuffix(fieldName, ".*")+"."+objectKey] = struct{}{}
+		}
+	}
+
+	names := make([]string, 0, len(c.m))
+	for name := range c.m {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if _, ok := known[name]; !ok {
+			errs = append(errs, c.fieldError(name, "not found in the fields definition"))
+		}
+
+		for i, rule := range c.m[name].Rules {
+			if _, ok := known[rule.When.Field]; !ok {
+				errs = append(errs, c.fieldError(name, "rule %d references field %s not found in the fields definition", i, rule.When.Field))
+			}
+		}
+	}
+
+	return multierr.Combine(errs...)
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// loadValueFile returns c with the values of its value_file, if any, loaded as the values of enum, and their weights
+// as weights. The value files ending with .csv are parsed as CSV records of a value and of an optional weight, 1 if
+// not set, so that the values can have commas; the other ones have one value per line. The empty lines are skipped.
+// The relative paths are resolved against baseDir.
+func loadValueFile(c ConfigField, baseDir string) (ConfigField, error) {
+	if len(c.ValueFile) == 0 {
+		return c, nil
+	}
+
+	if len(c.Enum) > 0 || len(c.Weights) > 0 {
+		return c, errors.New("value_file cannot be combined with enum or weights, the values and their weights are the ones of the file")
+	}
+
+	path := os.ExpandEnv(c.ValueFile)
+	if !filepath.IsAbs(path) && len(baseDir) > 0 {
+		path = filepath.Join(baseDir, path)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return c, fmt.Errorf("value_file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		c.Enum, c.Weights, err = parseWeightedValues(content)
+	} else {
+		c.Enum, err = parseValues(content)
+	}
+
+	if err != nil {
+		return c, fmt.Errorf("value_file %s: %w", c.ValueFile, err)
+	}
+
+	if len(c.Enum) == 0 {
+		return c, fmt.Errorf("value_file %s has no values", c.ValueFile)
+	}
+
+	return c, nil
+}
+
+// parseValues returns the values of content, one per line.
+func parseValues(content []byte) ([]string, error) {
+	var values []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, len(content)+1)
+	for scanner.Scan() {
+		if value := strings.TrimSuffix(scanner.Text(), "\r"); len(value) > 0 {
+			values = append(values, value)
+		}
+	}
+
+	return values, scanner.Err()
+}
+
+// parseWeightedValues returns the values of the CSV records of content, and their weights.
+func parseWeightedValues(content []byte) ([]string, []int, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+
+	var values []string
+	var weights []int
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(record) > 2 {
+			return nil, nil, fmt.Errorf("line %d: expected a value and an optional weight, got %d columns", line, len(record))
+		}
+
+		weight := 1
+		if len(record) == 2 {
+			if weight, err = strconv.Atoi(strings.TrimSpace(record[1])); err != nil || weight < 0 {
+				return nil, nil, fmt.Errorf("line %d: weight must be a positive integer, got %s", line, record[1])
+			}
+		}
+
+		values = append(values, record[0])
+		weights = append(weights, weight)
+	}
+
+	return values, weights, nil
+}
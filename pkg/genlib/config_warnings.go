@@ -0,0 +1,222 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ConfigWarnings returns a message for each setting of the config that is silently ignored
+// when generating the fields, because it does not apply to the type of the field or it is overridden by another setting.
+// The messages are prefixed by the line of the config entry, when loaded from a config file.
+func ConfigWarnings(cfg Config, flds Fields) []string {
+	byName := make(map[string]Field, len(flds))
+	for _, field := range flds {
+		byName[field.Name] = field
+
+		// object keys are generated with the type of their object
+		fieldCfg, _ := cfg.GetField(field.Name)
+		for _, objectKey := range fieldCfg.ObjectKeys {
+			objectKeyField := field
+			objectKeyField.Name = replacer.Replace(field.Name) + "." + objectKey
+			objectKeyField.Type = FieldTypeKeyword
+			if len(field.ObjectType) > 0 {
+				objectKeyField.Type = field.ObjectType
+			}
+
+			byName[objectKeyField.Name] = objectKeyField
+		}
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var warnings []string
+	for _, name := range names {
+		fieldCfg, ok := cfg.GetField(name)
+		if !ok {
+			continue
+		}
+
+		prefix := fmt.Sprintf("field %s", name)
+		if line := cfg.Line(name); line > 0 {
+			prefix = fmt.Sprintf("line %d: %s", line, prefix)
+		}
+
+		field := byName[name]
+		for _, warning := range configFieldWarnings(field, fieldCfg) {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", prefix, warning))
+		}
+
+		for i, rule := range fieldCfg.Rules {
+			for _, warning := range configFieldWarnings(field, rule.Then) {
+				warnings = append(warnings, fmt.Sprintf("%s: rule %d: %s", prefix, i, warning))
+			}
+		}
+	}
+
+	return warnings
+}
+
+func configFieldWarnings(field Field, fieldCfg ConfigField) []string {
+	var set []string
+	if fieldCfg.Cardinality.IsSet() {
+		set = append(set, "cardinality")
+	}
+
+	if len(fieldCfg.Enum) > 0 {
+		set = append(set, "enum")
+	}
+
+	if fieldCfg.Range.IsSet() {
+		set = append(set, "range")
+	}
+
+	if fieldCfg.Fuzziness > 0 {
+		set = append(set, "fuzziness")
+	}
+
+	if len(fieldCfg.Generator) > 0 {
+		set = append(set, "generator")
+	}
+
+	if len(fieldCfg.CIDR) > 0 {
+		set = append(set, "cidr")
+	}
+
+	if fieldCfg.IPv6Percentage > 0 {
+		set = append(set, "ipv6_percentage")
+	}
+
+	if fieldCfg.Precision != nil {
+		set = append(set, "precision")
+	}
+
+	if len(fieldCfg.GeoFormat) > 0 {
+		set = append(set, "geo_format")
+	}
+
+	if len(fieldCfg.GeoClusters) > 0 {
+		set = append(set, "geo_clusters")
+	}
+
+	if isArray(fieldCfg) {
+		set = append(set, "array_min and array_max")
+	}
+
+	if len(fieldCfg.Entity) > 0 {
+		set = append(set, "entity")
+	}
+
+	if len(fieldCfg.Escape) > 0 {
+		set = append(set, "escape")
+	}
+
+	if len(field.Value) > 0 {
+		if fieldCfg.Value != nil {
+			set = append(set, "value")
+		}
+
+		if len(set) == 0 {
+			return nil
+		}
+
+		return []string{fmt.Sprintf("%s ignored, the field has a value in the fields definition", strings.Join(set, ", "))}
+	}
+
+	if fieldCfg.Value != nil {
+		if len(set) == 0 {
+			return nil
+		}
+
+		return []string{fmt.Sprintf("%s ignored when value is set", strings.Join(set, ", "))}
+	}
+
+	var warnings []string
+	fieldType := field.Type
+	switch fieldType {
+	case FieldTypeObject, FieldTypeNested, FieldTypeFlattened:
+		// the settings apply to the values of the object
+		fieldType = FieldTypeKeyword
+		if len(field.ObjectType) > 0 {
+			fieldType = field.ObjectType
+		}
+		if isArray(fieldCfg) {
+			warnings = append(warnings, fmt.Sprintf("array_min and array_max ignored for type %s, they do not apply to object types", field.Type))
+		}
+	default:
+		if len(fieldCfg.ObjectKeys) > 0 {
+			warnings = append(warnings, fmt.Sprintf("object_keys ignored for type %s, it applies to object types only", field.Type))
+		}
+	}
+
+	if len(fieldCfg.Enum) > 0 && fieldType != FieldTypeKeyword {
+		warnings = append(warnings, fmt.Sprintf("enum ignored for type %s, it applies to keyword type only", fieldType))
+	}
+
+	if _, custom := customEmitter(fieldCfg, field); custom {
+		// the custom emitters apply to any type
+	} else if len(fieldCfg.Generator) > 0 {
+		if !appliesRealisticGenerator(Field{Type: fieldType}) {
+			warnings = append(warnings, fmt.Sprintf("generator ignored for type %s, it applies to string types only", fieldType))
+		} else if len(fieldCfg.Enum) > 0 {
+			warnings = append(warnings, "generator ignored when enum is set")
+		}
+	}
+
+	if (fieldCfg.WordsMin > 0 || fieldCfg.WordsMax > 0 || fieldCfg.Vocabulary > 0) && fieldCfg.Generator != GeneratorSentence {
+		warnings = append(warnings, "words_min, words_max and vocabulary ignored, they apply to the sentence generator only")
+	}
+
+	if fieldType != FieldTypeIP {
+		if len(fieldCfg.CIDR) > 0 {
+			warnings = append(warnings, fmt.Sprintf("cidr ignored for type %s, it applies to ip type only", fieldType))
+		}
+
+		if fieldCfg.IPv6Percentage > 0 {
+			warnings = append(warnings, fmt.Sprintf("ipv6_percentage ignored for type %s, it applies to ip type only", fieldType))
+		}
+	} else if len(fieldCfg.Generator) > 0 && len(fieldCfg.Enum) == 0 && (len(fieldCfg.CIDR) > 0 || fieldCfg.IPv6Percentage > 0) {
+		warnings = append(warnings, "cidr and ipv6_percentage ignored when generator is set")
+	}
+
+	if fieldCfg.CardinalityPerCIDR && (fieldType != FieldTypeIP || len(fieldCfg.CIDR) == 0 || !fieldCfg.Cardinality.IsSet()) {
+		warnings = append(warnings, "cardinality_per_cidr ignored, it applies to ip type with cidr and cardinality only")
+	}
+
+	if fieldCfg.Precision != nil && !isFloatType(fieldType) {
+		warnings = append(warnings, fmt.Sprintf("precision ignored for type %s, it applies to floating point types only", fieldType))
+	}
+
+	if fieldType != FieldTypeGeoPoint {
+		if len(fieldCfg.GeoFormat) > 0 {
+			warnings = append(warnings, fmt.Sprintf("geo_format ignored for type %s, it applies to geo_point type only", fieldType))
+		}
+
+		if len(fieldCfg.GeoClusters) > 0 {
+			warnings = append(warnings, fmt.Sprintf("geo_clusters ignored for type %s, it applies to geo_point type only", fieldType))
+		}
+	}
+
+	switch fieldType {
+	case FieldTypeDouble, FieldTypeFloat, FieldTypeHalfFloat, FieldTypeScaledFloat, FieldTypeInteger, FieldTypeLong, FieldTypeUnsignedLong:
+	default:
+		if fieldCfg.Range.IsSet() {
+			warnings = append(warnings, fmt.Sprintf("range ignored for type %s, it applies to numeric types only", fieldType))
+		}
+
+		if fieldCfg.Fuzziness > 0 {
+			warnings = append(warnings, fmt.Sprintf("fuzziness ignored for type %s, it applies to numeric types only", fieldType))
+		}
+	}
+
+	return warnings
+}
@@ -0,0 +1,72 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+func Test_ConfigWarnings(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+		{Name: "gamma", Type: FieldTypeDate},
+		{Name: "delta", Type: FieldTypeConstantKeyword, Value: "constant"},
+		{Name: "epsilon.*", Type: FieldTypeObject, ObjectType: FieldTypeLong},
+		{Name: "zeta", Type: FieldTypeIP},
+	}
+
+	testCases := []struct {
+		yaml     string
+		expected []string
+	}{
+		{
+			yaml:     "- name: alpha\n  enum: [\"a\"]\n  cardinality: 100\n- name: beta\n  range: 10\n  fuzziness: 5\n- name: epsilon.*\n  object_keys: [\"one\"]\n- name: epsilon.one\n  range: 10",
+			expected: nil,
+		},
+		{
+			yaml:     "- name: alpha\n  range: 10\n- name: gamma\n  fuzziness: 5\n- name: beta\n  enum: [\"1\"]\n  object_keys: [\"one\"]",
+			expected: []string{"line 1: field alpha: range ignored for type keyword, it applies to numeric types only", "line 5: field beta: object_keys ignored for type long, it applies to object types only", "line 5: field beta: enum ignored for type long, it applies to keyword type only", "line 3: field gamma: fuzziness ignored for type date, it applies to numeric types only"},
+		},
+		{
+			yaml:     "- name: alpha\n  value: a\n  cardinality: 100\n  enum: [\"a\"]\n- name: delta\n  value: b",
+			expected: []string{"line 1: field alpha: cardinality, enum ignored when value is set", "line 5: field delta: value ignored, the field has a value in the fields definition"},
+		},
+		{
+			yaml:     "- name: epsilon.*\n  object_keys: [\"one\"]\n- name: epsilon.one\n  enum: [\"a\"]\n- name: beta\n  rules:\n    - when: {field: alpha, equals: a}\n      then: {enum: [\"a\"]}",
+			expected: []string{"line 5: field beta: rule 0: enum ignored for type long, it applies to keyword type only", "line 3: field epsilon.one: enum ignored for type long, it applies to keyword type only"},
+		},
+		{
+			yaml:     "- name: alpha\n  cidr: [\"10.0.0.0/8\"]\n- name: zeta\n  cidr: [\"10.0.0.0/8\"]\n  cardinality_per_cidr: true",
+			expected: []string{"line 1: field alpha: cidr ignored for type keyword, it applies to ip type only", "line 3: field zeta: cardinality_per_cidr ignored, it applies to ip type with cidr and cardinality only"},
+		},
+		{
+			yaml:     "- name: gamma\n  precision: 2",
+			expected: []string{"line 1: field gamma: precision ignored for type date, it applies to floating point types only"},
+		},
+		{
+			yaml:     "- name: zeta\n  geo_format: wkt\n  geo_clusters:\n    - city: paris",
+			expected: []string{"line 1: field zeta: geo_format ignored for type ip, it applies to geo_point type only", "line 1: field zeta: geo_clusters ignored for type ip, it applies to geo_point type only"},
+		},
+		{
+			yaml:     "- name: epsilon.*\n  array_max: 2\n- name: delta\n  array_max: 2",
+			expected: []string{"line 3: field delta: array_min and array_max ignored, the field has a value in the fields definition", "line 1: field epsilon.*: array_min and array_max ignored for type object, they do not apply to object types"},
+		},
+	}
+
+	for _, tc := range testCases {
+		cfg, err := config.LoadConfigFromYaml([]byte(tc.yaml))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if warnings := ConfigWarnings(cfg, flds); !reflect.DeepEqual(warnings, tc.expected) {
+			t.Errorf("expected warnings %q, got %q", tc.expected, warnings)
+		}
+	}
+}
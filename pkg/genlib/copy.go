@@ -0,0 +1,31 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+)
+
+// bindCopy binds a field with copy_from to the value written for the source field in the event being emitted,
+// escaped already if it is a string.
+func bindCopy(prefix []byte, fieldCfg ConfigField, field Field, fieldMap map[string]emitFNotReturn) error {
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
+		buf.Write(prefix)
+		buf.WriteString(state.eventValues[fieldCfg.CopyFrom])
+		return nil
+	}
+
+	return nil
+}
+
+// bindCopyWithReturn binds a field with copy_from to the value returned for the source field in the event being
+// emitted, nil if the source field has no value.
+func bindCopyWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]EmitF) error {
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
+		return state.eventReturnValues[fieldCfg.CopyFrom], nil
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+// TemplateCoverage is the coverage of a fields definition by a template
+type TemplateCoverage struct {
+	// Covered are the fields of the definition referenced by the template
+	Covered []string
+	// Ignored are the fields of the definition not referenced by the template
+	Ignored []string
+	// Unbound are the fields referenced by the template and not in the definition
+	Unbound []string
+	// Referenced is the number of fields referenced by the template
+	Referenced int
+}
+
+// Coverage returns the coverage of the fields definition flds by the template of gen. Object fields are
+// covered when one of their keys is referenced.
+func Coverage(gen Generator, flds Fields) TemplateCoverage {
+	var coverage TemplateCoverage
+	referenced := gen.Fields()
+	coverage.Referenced = len(referenced)
+	for _, field := range referenced {
+		if len(field.Field.Type) == 0 {
+			coverage.Unbound = append(coverage.Unbound, field.Field.Name)
+		}
+	}
+
+	for _, field := range flds {
+		if isReferenced(field, referenced) {
+			coverage.Covered = append(coverage.Covered, field.Name)
+		} else {
+			coverage.Ignored = append(coverage.Ignored, field.Name)
+		}
+	}
+
+	return coverage
+}
+
+// CoveredPercent is the percentage of the fields of the definition covered by the template.
+func (c TemplateCoverage) CoveredPercent() float64 {
+	return percent(len(c.Covered), len(c.Covered)+len(c.Ignored))
+}
+
+// IgnoredPercent is the percentage of the fields of the definition ignored by the template.
+func (c TemplateCoverage) IgnoredPercent() float64 {
+	return percent(len(c.Ignored), len(c.Covered)+len(c.Ignored))
+}
+
+// UnboundPercent is the percentage of the fields referenced by the template that are not in the definition.
+func (c TemplateCoverage) UnboundPercent() float64 {
+	return percent(len(c.Unbound), c.Referenced)
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(n) * 100 / float64(total)
+}
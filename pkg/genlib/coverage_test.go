@@ -0,0 +1,47 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+func Test_Coverage(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+		{Name: "delta.*", Type: FieldTypeObject, ObjectType: FieldTypeLong},
+		{Name: "epsilon", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: delta.*\n  object_keys: [\"one\"]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, _ := makeGeneratorWithTextTemplate(t, cfg, flds, []byte(`{{generate "alpha"}} {{generate "delta.one"}} {{generate "zeta"}}`))
+	coverage := Coverage(g, flds)
+
+	expected := TemplateCoverage{
+		Covered:    []string{"alpha", "delta.*"},
+		Ignored:    []string{"beta", "epsilon"},
+		Unbound:    []string{"zeta"},
+		Referenced: 3,
+	}
+	if !reflect.DeepEqual(expected, coverage) {
+		t.Errorf("expected %+v, got %+v", expected, coverage)
+	}
+
+	if coverage.CoveredPercent() != 50 || coverage.IgnoredPercent() != 50 {
+		t.Errorf("expected 50%% covered and ignored, got %.1f%% and %.1f%%", coverage.CoveredPercent(), coverage.IgnoredPercent())
+	}
+
+	if percent := coverage.UnboundPercent(); percent < 33.3 || percent > 33.4 {
+		t.Errorf("expected 33.3%% unbound, got %.1f%%", percent)
+	}
+}
@@ -0,0 +1,491 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fmtTimeLayout is the layout of the dates recorded by the text and structured templates, the one of fmt.Sprint
+const fmtTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// derivedValue is the value of an operand or of the result of an expression: a number, or a date.
+type derivedValue struct {
+	number float64
+	time   time.Time
+	isTime bool
+}
+
+// expression evaluates an expression against the values recorded in the event being emitted, and in the previous one.
+type expression func(values, previous map[string]string) (derivedValue, error)
+
+// expressionFunctions are the functions of the expressions, by name, with their count of arguments.
+var expressionFunctions = map[string]int{
+	"rand":     1,
+	"int":      1,
+	"uint":     1,
+	"abs":      1,
+	"min":      2,
+	"max":      2,
+	"previous": 1,
+}
+
+// parseExpression compiles the arithmetic expression of a derived field, like event.end - event.start, and returns
+// the fields it references in the same event and in the previous one. The expressions are made of field names,
+// numbers, the +, -, * and / operators, parentheses and the calls of expressionFunctions. The difference of two dates
+// is the count of nanoseconds between them, like event.duration, and a count of nanoseconds can be added to a date,
+// or subtracted from it.
+func parseExpression(source string) (expression, []string, []string, error) {
+	p := &expressionParser{source: source}
+	expr, err := p.parseSum()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if p.skipSpaces(); p.pos < len(p.source) {
+		return nil, nil, nil, fmt.Errorf("unexpected %q at offset %d", p.source[p.pos:], p.pos)
+	}
+
+	return expr, p.fields, p.previousFields, nil
+}
+
+// expressionFields returns the fields referenced by the expression of fieldCfg in the same event, none if it is not
+// valid.
+func expressionFields(fieldCfg ConfigField) []string {
+	if len(fieldCfg.Expression) == 0 {
+		return nil
+	}
+
+	_, fields, _, _ := parseExpression(fieldCfg.Expression)
+	return fields
+}
+
+// expressionPreviousFields returns the fields referenced by the expression of fieldCfg in the previous event, with
+// previous, none if it is not valid.
+func expressionPreviousFields(fieldCfg ConfigField) []string {
+	if len(fieldCfg.Expression) == 0 {
+		return nil
+	}
+
+	_, _, previousFields, _ := parseExpression(fieldCfg.Expression)
+	return previousFields
+}
+
+type expressionParser struct {
+	source         string
+	pos            int
+	fields         []string
+	previousFields []string
+}
+
+func (p *expressionParser) skipSpaces() {
+	for p.pos < len(p.source) && p.source[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// parseSum parses the terms added or subtracted.
+func (p *expressionParser) parseSum() (expression, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpaces()
+		if p.pos == len(p.source) || (p.source[p.pos] != '+' && p.source[p.pos] != '-') {
+			return left, nil
+		}
+
+		operator := p.source[p.pos]
+		p.pos++
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+
+		left = binaryExpression(operator, left, right)
+	}
+}
+
+// parseProduct parses the factors multiplied or divided.
+func (p *expressionParser) parseProduct() (expression, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpaces()
+		if p.pos == len(p.source) || (p.source[p.pos] != '*' && p.source[p.pos] != '/') {
+			return left, nil
+		}
+
+		operator := p.source[p.pos]
+		p.pos++
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+
+		left = binaryExpression(operator, left, right)
+	}
+}
+
+// parseFactor parses a number, a field name, a function call, a negated factor or an expression in parentheses.
+func (p *expressionParser) parseFactor() (expression, error) {
+	p.skipSpaces()
+	if p.pos == len(p.source) {
+		return nil, errors.New("unexpected end of the expression")
+	}
+
+	start := p.pos
+	switch c := p.source[p.pos]; {
+	case c == '(':
+		p.pos++
+		expr, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.skipSpaces(); p.pos == len(p.source) || p.source[p.pos] != ')' {
+			return nil, fmt.Errorf("missing ) closing the ( at offset %d", start)
+		}
+
+		p.pos++
+		return expr, nil
+	case c == '-':
+		p.pos++
+		operand, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+
+		return binaryExpression('-', constantExpression(0), operand), nil
+	case c >= '0' && c <= '9':
+		for p.pos < len(p.source) && (p.source[p.pos] >= '0' && p.source[p.pos] <= '9' || p.source[p.pos] == '.') {
+			p.pos++
+		}
+
+		number, err := strconv.ParseFloat(p.source[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at offset %d", p.source[start:p.pos], start)
+		}
+
+		return constantExpression(number), nil
+	case isFieldNameByte(c):
+		for p.pos < len(p.source) && isFieldNameByte(p.source[p.pos]) {
+			p.pos++
+		}
+
+		fieldName := p.source[start:p.pos]
+		if p.skipSpaces(); p.pos < len(p.source) && p.source[p.pos] == '(' {
+			return p.parseCall(fieldName, start)
+		}
+
+		p.fields = append(p.fields, fieldName)
+		return func(values, _ map[string]string) (derivedValue, error) {
+			return parseDerivedValue(fieldName, values[fieldName])
+		}, nil
+	default:
+		return nil, fmt.Errorf("unexpected %q at offset %d", c, start)
+	}
+}
+
+// parseCall parses the arguments of the call of the function name at offset start, up to the closing parenthesis.
+func (p *expressionParser) parseCall(name string, start int) (expression, error) {
+	arity, ok := expressionFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s at offset %d", name, start)
+	}
+
+	// the opening parenthesis
+	p.pos++
+	if name == "previous" {
+		return p.parsePrevious(start)
+	}
+
+	var args []expression
+	for {
+		arg, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, arg)
+		p.skipSpaces()
+		if p.pos < len(p.source) && p.source[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+
+		if p.pos == len(p.source) || p.source[p.pos] != ')' {
+			return nil, fmt.Errorf("missing ) closing the call of %s at offset %d", name, start)
+		}
+
+		p.pos++
+		break
+	}
+
+	if len(args) != arity {
+		return nil, fmt.Errorf("%s at offset %d takes %d arguments, got %d", name, start, arity, len(args))
+	}
+
+	return callExpression(name, args), nil
+}
+
+// parsePrevious parses the field name argument of previous, quoted or not, and the closing parenthesis.
+func (p *expressionParser) parsePrevious(start int) (expression, error) {
+	p.skipSpaces()
+	var fieldName string
+	if p.pos < len(p.source) && p.source[p.pos] == '"' {
+		quoted, err := strconv.QuotedPrefix(p.source[p.pos:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid field name of previous at offset %d: %w", p.pos, err)
+		}
+
+		fieldName, _ = strconv.Unquote(quoted)
+		p.pos += len(quoted)
+	} else {
+		nameStart := p.pos
+		for p.pos < len(p.source) && isFieldNameByte(p.source[p.pos]) {
+			p.pos++
+		}
+
+		fieldName = p.source[nameStart:p.pos]
+	}
+
+	if p.skipSpaces(); len(fieldName) == 0 || p.pos == len(p.source) || p.source[p.pos] != ')' {
+		return nil, fmt.Errorf("previous at offset %d takes the name of a field", start)
+	}
+
+	p.pos++
+	p.previousFields = append(p.previousFields, fieldName)
+	return func(_, previous map[string]string) (derivedValue, error) {
+		return parseDerivedValue(fieldName, previous[fieldName])
+	}, nil
+}
+
+// callExpression returns the expression applying the function name to the values of args. The functions apply to
+// numbers only, and rand of a count lower than 1 is zero.
+func callExpression(name string, args []expression) expression {
+	return func(values, previous map[string]string) (derivedValue, error) {
+		numbers := make([]float64, len(args))
+		for i, arg := range args {
+			v, err := arg(values, previous)
+			if err != nil {
+				return v, err
+			}
+
+			if v.isTime {
+				return v, fmt.Errorf("cannot apply %s to dates, only to numbers", name)
+			}
+
+			numbers[i] = v.number
+		}
+
+		switch name {
+		case "rand":
+			if n := int64(numbers[0]); n > 0 {
+				return derivedValue{number: float64(rand.Int63n(n))}, nil
+			}
+
+			return derivedValue{}, nil
+		case "int":
+			return derivedValue{number: math.Trunc(numbers[0])}, nil
+		case "uint":
+			return derivedValue{number: math.Max(0, math.Trunc(numbers[0]))}, nil
+		case "abs":
+			return derivedValue{number: math.Abs(numbers[0])}, nil
+		case "min":
+			return derivedValue{number: math.Min(numbers[0], numbers[1])}, nil
+		default:
+			return derivedValue{number: math.Max(numbers[0], numbers[1])}, nil
+		}
+	}
+}
+
+// isFieldNameByte tells whether c can be part of the name of a field in an expression.
+func isFieldNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '@'
+}
+
+func constantExpression(number float64) expression {
+	return func(_, _ map[string]string) (derivedValue, error) {
+		return derivedValue{number: number}, nil
+	}
+}
+
+// binaryExpression returns the expression applying operator to the values of left and right. The division by zero
+// is zero, since the values of the operands are random.
+func binaryExpression(operator byte, left, right expression) expression {
+	return func(values, previous map[string]string) (derivedValue, error) {
+		l, err := left(values, previous)
+		if err != nil {
+			return l, err
+		}
+
+		r, err := right(values, previous)
+		if err != nil {
+			return r, err
+		}
+
+		switch {
+		case operator == '-' && l.isTime && r.isTime:
+			return derivedValue{number: float64(l.time.Sub(r.time))}, nil
+		case (operator == '+' || operator == '-') && l.isTime && !r.isTime:
+			offset := time.Duration(math.Round(r.number))
+			if operator == '-' {
+				offset = -offset
+			}
+
+			return derivedValue{time: l.time.Add(offset), isTime: true}, nil
+		case operator == '+' && !l.isTime && r.isTime:
+			return derivedValue{time: r.time.Add(time.Duration(math.Round(l.number))), isTime: true}, nil
+		case l.isTime || r.isTime:
+			return l, fmt.Errorf("cannot apply %c to dates, only the difference of two dates, and the sum of a date and of nanoseconds are allowed", operator)
+		}
+
+		switch operator {
+		case '+':
+			return derivedValue{number: l.number + r.number}, nil
+		case '-':
+			return derivedValue{number: l.number - r.number}, nil
+		case '*':
+			return derivedValue{number: l.number * r.number}, nil
+		default:
+			if r.number == 0 {
+				return derivedValue{}, nil
+			}
+
+			return derivedValue{number: l.number / r.number}, nil
+		}
+	}
+}
+
+// checkExpressionOperands returns an error if the expression of a field of fields references a field of fields that
+// is neither a numeric nor a date field, in the same event or in the previous one.
+func checkExpressionOperands(cfg Config, fields Fields) error {
+	types := make(map[string]string, len(fields))
+	for _, field := range fields {
+		types[field.Name] = field.Type
+	}
+
+	for _, field := range fields {
+		fieldCfg, _ := cfg.GetField(field.Name)
+		for _, operand := range append(expressionFields(fieldCfg), expressionPreviousFields(fieldCfg)...) {
+			operandType, ok := types[operand]
+			if !ok {
+				continue
+			}
+
+			switch operandType {
+			case FieldTypeDate, FieldTypeDouble, FieldTypeFloat, FieldTypeHalfFloat, FieldTypeScaledFloat, FieldTypeInteger, FieldTypeLong, FieldTypeUnsignedLong:
+			default:
+				return fmt.Errorf("field %s: expression %s: field %s of type %s is neither a number nor a date", field.Name, fieldCfg.Expression, operand, operandType)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseDerivedValue parses the value recorded for fieldName: a number or a date. The fields without a value in the
+// event, either omitted or null, count as zero.
+func parseDerivedValue(fieldName, value string) (derivedValue, error) {
+	if len(value) == 0 || value == "null" || value == "<nil>" {
+		return derivedValue{}, nil
+	}
+
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		return derivedValue{number: number}, nil
+	}
+
+	// fmt.Sprint appends the reading of the monotonic clock to the dates carrying one
+	if i := strings.Index(value, " m="); i > 0 {
+		value = value[:i]
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, fmtTimeLayout} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return derivedValue{time: t, isTime: true}, nil
+		}
+	}
+
+	return derivedValue{}, fmt.Errorf("value %s of field %s is neither a number nor a date", value, fieldName)
+}
+
+// appendDerived appends the value of a derived field: the dates as timestamps, the numbers rounded to integers for the
+// integer fields, and with the precision of the config for the others.
+func appendDerived(b []byte, fieldCfg ConfigField, field Field, v derivedValue) []byte {
+	if v.isTime {
+		return appendTimestamp(b, v.time)
+	}
+
+	switch field.Type {
+	case FieldTypeInteger, FieldTypeLong, FieldTypeUnsignedLong:
+		return strconv.AppendInt(b, int64(math.Round(v.number)), 10)
+	}
+
+	precision := -1
+	if fieldCfg.Precision != nil {
+		precision = *fieldCfg.Precision
+	}
+
+	return strconv.AppendFloat(b, v.number, 'f', precision, 64)
+}
+
+func bindDerived(prefix []byte, fieldCfg ConfigField, field Field, fieldMap map[string]emitFNotReturn) error {
+	expr, _, _, err := parseExpression(fieldCfg.Expression)
+	if err != nil {
+		return fmt.Errorf("field %s: expression %s: %w", field.Name, fieldCfg.Expression, err)
+	}
+
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
+		v, err := expr(state.eventValues, state.previousValues)
+		if err != nil {
+			return err
+		}
+
+		buf.Write(prefix)
+		var b [64]byte
+		buf.Write(appendDerived(b[:0], fieldCfg, field, v))
+		return nil
+	}
+
+	return nil
+}
+
+func bindDerivedWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]EmitF) error {
+	expr, _, _, err := parseExpression(fieldCfg.Expression)
+	if err != nil {
+		return fmt.Errorf("field %s: expression %s: %w", field.Name, fieldCfg.Expression, err)
+	}
+
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
+		v, err := expr(state.eventValues, state.previousValues)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case v.isTime:
+			return v.time, nil
+		case field.Type == FieldTypeInteger || field.Type == FieldTypeLong || field.Type == FieldTypeUnsignedLong:
+			return int(math.Round(v.number)), nil
+		default:
+			return returnFloat(fieldCfg, v.number), nil
+		}
+	}
+
+	return nil
+}
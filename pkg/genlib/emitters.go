@@ -0,0 +1,140 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Emitter is a custom emitter of the values of fields, for domain-specific values the field types and the realistic
+// generators do not cover, like JWTs or AWS ARNs: it is registered with RegisterEmitter by the tools embedding the
+// generator, or by the Go plugins loaded by the command line tool.
+type Emitter struct {
+	// Name is the name the emitter is selected by with the generator config entry of a field
+	Name string
+	// Fields are the names of the fields generated with the emitter unless their config sets a generator or an enum
+	Fields []string
+	// Types are the types of the fields generated with the emitter, as Fields: the names of Fields take precedence
+	Types []string
+	// Emit returns the value of field for the event being generated: a string, escaped as the other generated values,
+	// a number or a boolean. It draws from the global math/rand source, for the values to be reproduced from the
+	// seed, see InitGeneratorRandSeed
+	Emit func(field Field) interface{}
+}
+
+var (
+	emittersMu sync.RWMutex
+	// emitters are the custom emitters registered, by name
+	emitters = map[string]Emitter{}
+)
+
+// RegisterEmitter registers the custom emitter, replacing the one registered with the same name, if any: the
+// generators created afterwards bind the fields it applies to to it. It fails if the name is the one of a realistic
+// generator.
+func RegisterEmitter(emitter Emitter) error {
+	if emitter.Name == "" {
+		return errors.New("emitter name must not be empty")
+	}
+
+	if emitter.Emit == nil {
+		return fmt.Errorf("emitter %s: Emit must not be nil", emitter.Name)
+	}
+
+	if _, ok := realisticGenerators[emitter.Name]; ok {
+		return fmt.Errorf("emitter %s: the name is the one of a realistic generator", emitter.Name)
+	}
+
+	emittersMu.Lock()
+	defer emittersMu.Unlock()
+	emitters[emitter.Name] = emitter
+	return nil
+}
+
+// Emitters returns the names of the custom emitters registered, sorted.
+func Emitters() []string {
+	emittersMu.RLock()
+	defer emittersMu.RUnlock()
+	return sortedEmitterNames()
+}
+
+// customEmitter returns the custom emitter field is generated with, if any: the one named by the generator config
+// entry, else the one registered for the name of field, else for its type, unless an enum or a generator is configured.
+func customEmitter(fieldCfg ConfigField, field Field) (Emitter, bool) {
+	emittersMu.RLock()
+	defer emittersMu.RUnlock()
+	if len(emitters) == 0 {
+		return Emitter{}, false
+	}
+
+	if len(fieldCfg.Generator) > 0 {
+		emitter, ok := emitters[fieldCfg.Generator]
+		return emitter, ok
+	}
+
+	if len(fieldCfg.Enum) > 0 {
+		return Emitter{}, false
+	}
+
+	// the emitters are visited by name, for the same one to apply when several are registered for the same type
+	var byType Emitter
+	var typeMatched bool
+	for _, name := range sortedEmitterNames() {
+		emitter := emitters[name]
+		for _, fieldName := range emitter.Fields {
+			if fieldName == field.Name {
+				return emitter, true
+			}
+		}
+
+		for _, fieldType := range emitter.Types {
+			if fieldType == field.Type && !typeMatched {
+				byType, typeMatched = emitter, true
+			}
+		}
+	}
+
+	return byType, typeMatched
+}
+
+// sortedEmitterNames returns the names of the custom emitters registered, sorted: the caller holds emittersMu.
+func sortedEmitterNames() []string {
+	names := make([]string, 0, len(emitters))
+	for name := range emitters {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func bindCustomEmitter(prefix []byte, emitter Emitter, field Field, fieldMap map[string]emitFNotReturn) error {
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
+		buf.Write(prefix)
+		switch value := emitter.Emit(field).(type) {
+		case string:
+			buf.WriteString(value)
+		case []byte:
+			buf.Write(value)
+		default:
+			fmt.Fprint(buf, value)
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+func bindCustomEmitterWithReturn(emitter Emitter, field Field, fieldMap map[string]EmitF) error {
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
+		return emitter.Emit(field), nil
+	}
+
+	return nil
+}
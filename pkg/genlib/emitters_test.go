@@ -0,0 +1,132 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+func registerTestEmitter(t *testing.T, emitter Emitter) {
+	if err := RegisterEmitter(emitter); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		emittersMu.Lock()
+		delete(emitters, emitter.Name)
+		emittersMu.Unlock()
+	})
+}
+
+func Test_RegisterEmitter(t *testing.T) {
+	emit := func(field Field) interface{} { return "x" }
+	if err := RegisterEmitter(Emitter{Emit: emit}); err == nil {
+		t.Errorf("expected an error for an emitter without name")
+	}
+
+	if err := RegisterEmitter(Emitter{Name: "jwt"}); err == nil {
+		t.Errorf("expected an error for an emitter without Emit")
+	}
+
+	if err := RegisterEmitter(Emitter{Name: GeneratorUserAgent, Emit: emit}); err == nil {
+		t.Errorf("expected an error for an emitter named as a realistic generator")
+	}
+
+	registerTestEmitter(t, Emitter{Name: "jwt", Emit: emit})
+	if names := Emitters(); len(names) != 1 || names[0] != "jwt" {
+		t.Errorf("expected the jwt emitter to be registered, got %v", names)
+	}
+}
+
+func Test_CustomEmitterWithCustomTemplate(t *testing.T) {
+	registerTestEmitter(t, Emitter{
+		Name:   "aws_arn",
+		Fields: []string{"aws.arn"},
+		Emit: func(field Field) interface{} {
+			return fmt.Sprintf("arn:aws:iam::%012d:user/%s", rand.Int63n(1e12), field.Name)
+		},
+	})
+	registerTestEmitter(t, Emitter{
+		Name:  "port",
+		Types: []string{FieldTypeLong},
+		Emit:  func(field Field) interface{} { return 1024 + rand.Intn(64511) },
+	})
+
+	flds := []Field{
+		{Name: "aws.arn", Type: FieldTypeKeyword},
+		{Name: "source.port", Type: FieldTypeLong},
+		{Name: "user.arn", Type: FieldTypeKeyword},
+		{Name: "host.name", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: user.arn\n  generator: aws_arn\n- name: host.name\n  enum: [\"web-01\"]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if warnings := ConfigWarnings(cfg, flds); len(warnings) > 0 {
+		t.Errorf("expected no config warnings, got %v", warnings)
+	}
+
+	arnRegex := regexp.MustCompile(`^arn:aws:iam::\d{12}:user/(aws|user)\.arn$`)
+	template := []byte(`{"aws.arn":"{{.aws.arn}}","source.port":{{.source.port}},"user.arn":"{{.user.arn}}","host.name":"{{.host.name}}"}`)
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[interface{}](t, buf.Bytes())
+		if arn, _ := m["aws.arn"].(string); !arnRegex.MatchString(arn) {
+			t.Errorf("aws.arn %v not generated by the aws_arn emitter", m["aws.arn"])
+		}
+
+		if arn, _ := m["user.arn"].(string); !arnRegex.MatchString(arn) {
+			t.Errorf("user.arn %v not generated by the aws_arn emitter", m["user.arn"])
+		}
+
+		if port, _ := m["source.port"].(float64); port < 1024 || port > 65535 {
+			t.Errorf("source.port %v not generated by the port emitter", m["source.port"])
+		}
+
+		if m["host.name"] != "web-01" {
+			t.Errorf("host.name %v not from the enum", m["host.name"])
+		}
+	}
+}
+
+func Test_CustomEmitterWithTextTemplate(t *testing.T) {
+	registerTestEmitter(t, Emitter{
+		Name:   "jwt",
+		Fields: []string{"http.request.headers.authorization"},
+		Emit:   func(field Field) interface{} { return "eyJhbGciOiJIUzI1NiJ9.e30.\"" },
+	})
+
+	flds := []Field{
+		{Name: "http.request.headers.authorization", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"authorization":"{{generate "http.request.headers.authorization"}}"}`)
+	g, state := makeGeneratorWithTextTemplate(t, Config{}, flds, template)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// the values of the custom emitters are escaped as the other generated values
+	m := unmarshalJSONT[string](t, buf.Bytes())
+	if m["authorization"] != "eyJhbGciOiJIUzI1NiJ9.e30.\"" {
+		t.Errorf("authorization %s not generated by the jwt emitter", m["authorization"])
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"math/rand"
+)
+
+// entityPoolName is the name the values of the attribute fieldName of the entities are cached under,
+// so that they do not mix with the previous values of the field.
+func entityPoolName(fieldName string) string {
+	return fieldName + "#entity"
+}
+
+// entityIndex returns the index of the entity drawn for the event being emitted among entities, drawing it on the
+// first attribute of entity emitted in the event, with skewed if the entities are skewed.
+func (s *GenState) entityIndex(entity string, entities int, skewed func() int) int {
+	idx, ok := s.entities[entity]
+	if !ok {
+		if skewed != nil {
+			idx = skewed()
+		} else {
+			idx = rand.Intn(entities)
+		}
+
+		s.entities[entity] = idx
+	}
+
+	return idx
+}
+
+// attributeValues returns the count of distinct values of the attribute fieldCfg of an entity among entities, lower
+// than the count of entities if the attribute sets a lower cardinality, see attributeIndex.
+func attributeValues(fieldCfg ConfigField, entities int) int {
+	if values := fieldCfg.Cardinality.Values(); values > 0 && values < entities {
+		return values
+	}
+
+	return entities
+}
+
+// attributeIndex returns the index of the value of the attribute fieldCfg of the entity at idx among entities: the
+// attributes with a cardinality lower than the count of entities share each of their values among several entities,
+// like the pods of the containers, so that the combinations of the values of the attributes stay consistent.
+func attributeIndex(fieldCfg ConfigField, idx, entities int) int {
+	return idx % attributeValues(fieldCfg, entities)
+}
+
+// bindEntity binds the field as an attribute of its entity: its value is the one of the entity drawn for the event,
+// the values of the entities being generated the first time they are drawn.
+func bindEntity(prefix []byte, cfg Config, field Field, fieldMap map[string]emitFNotReturn, templateFieldMap map[string][]byte) error {
+	fieldCfg, _ := cfg.GetField(field.Name)
+	entities := cfg.EntityCardinality(fieldCfg.Entity)
+	skewed := newSkewedIndex(cfg.EntitySkew(fieldCfg.Entity), entities)
+	churned := newChurn(cfg.EntityChurn(fieldCfg.Entity), attributeValues(fieldCfg, entities))
+
+	if err := bindByType(cfg, field, fieldMap, templateFieldMap); err != nil {
+		return err
+	}
+
+	boundF := fieldMap[field.Name]
+	poolName := entityPoolName(field.Name)
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
+		va, _ := state.prevCache[poolName].([]bytes.Buffer)
+		idx := attributeIndex(fieldCfg, state.entityIndex(fieldCfg.Entity, entities, skewed), entities)
+
+		// the entities get distinct values, unless none is found in a few tries
+		for len(va) <= idx {
+			var tmp bytes.Buffer
+			for i := 0; i < 11; i++ {
+				tmp.Reset()
+				if err := boundF(state, &tmp); err != nil {
+					return err
+				}
+
+				if !isDupeByteSlice(va, tmp.Bytes()) {
+					break
+				}
+			}
+
+			va = append(va, tmp)
+		}
+
+		if churned.renew(state, poolName, idx) {
+			if err := renewValue(state, va, idx, boundF); err != nil {
+				return err
+			}
+		}
+
+		state.prevCache[poolName] = va
+		state.tracePoolEntry(field.Name, idx)
+
+		buf.Write(va[idx].Bytes())
+		return nil
+	}
+
+	return nil
+}
+
+// bindEntityWithReturn is bindEntity for the generators using the values of the fields.
+func bindEntityWithReturn(cfg Config, field Field, fieldMap map[string]EmitF) error {
+	fieldCfg, _ := cfg.GetField(field.Name)
+	entities := cfg.EntityCardinality(fieldCfg.Entity)
+	skewed := newSkewedIndex(cfg.EntitySkew(fieldCfg.Entity), entities)
+	churned := newChurn(cfg.EntityChurn(fieldCfg.Entity), attributeValues(fieldCfg, entities))
+
+	if err := bindByTypeWithReturn(cfg, field, fieldMap); err != nil {
+		return err
+	}
+
+	boundF := fieldMap[field.Name]
+	poolName := entityPoolName(field.Name)
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
+		va, _ := state.prevCache[poolName].([]interface{})
+		idx := attributeIndex(fieldCfg, state.entityIndex(fieldCfg.Entity, entities, skewed), entities)
+
+		// the entities get distinct values, unless none is found in a few tries
+		for len(va) <= idx {
+			var value interface{}
+			var tmp bytes.Buffer
+			for i := 0; i < 11; i++ {
+				tmp.Reset()
+				var err error
+				if value, err = boundF(state, &tmp); err != nil {
+					return nil, err
+				}
+
+				if !isDupeInterface(va, value) {
+					break
+				}
+			}
+
+			va = append(va, value)
+		}
+
+		if churned.renew(state, poolName, idx) {
+			if err := renewValueWithReturn(state, va, idx, boundF); err != nil {
+				return nil, err
+			}
+		}
+
+		state.prevCache[poolName] = va
+		state.tracePoolEntry(field.Name, idx)
+
+		return va[idx], nil
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DefaultEnvelopeVersion is the version of the Beat of the envelopes whose version is not provided
+const DefaultEnvelopeVersion = "8.15.0"
+
+// Envelope is the publishing envelope the Beats and the Elastic Agent wrap the events in before shipping them.
+type Envelope struct {
+	// Beat is the name of the Beat publishing the events, like filebeat
+	Beat string
+	// Version is the version of the Beat
+	Version string
+	// DataStream is the data stream the events are routed to, like logs-nginx.access-default, none if empty
+	DataStream string
+}
+
+// ParseEnvelope parses an envelope in the beat[@version] form, like filebeat@8.15.0, the version defaulting to
+// DefaultEnvelopeVersion.
+func ParseEnvelope(s string) (Envelope, error) {
+	beat, version, found := strings.Cut(s, "@")
+	if beat == "" || found && version == "" {
+		return Envelope{}, fmt.Errorf("envelope %s: must be in the beat[@version] form, like filebeat@%s", s, DefaultEnvelopeVersion)
+	}
+
+	if !found {
+		version = DefaultEnvelopeVersion
+	}
+
+	return Envelope{Beat: beat, Version: version}, nil
+}
+
+// NewEnvelope returns a Middleware wrapping the events in envelope: the @metadata field with the beat, the type and
+// the version, and, with a data stream, the raw_index in @metadata and the data_stream fields routing the events.
+// Events that are not JSON objects, like log lines, are wrapped as the message field of an object.
+func NewEnvelope(envelope Envelope) (Middleware, error) {
+	metadata := map[string]interface{}{
+		"beat":    envelope.Beat,
+		"type":    "_doc",
+		"version": envelope.Version,
+	}
+
+	var dataStream map[string]interface{}
+	if envelope.DataStream != "" {
+		dataStreamType, rest, _ := strings.Cut(envelope.DataStream, "-")
+		i := strings.LastIndex(rest, "-")
+		if dataStreamType == "" || i <= 0 || i == len(rest)-1 {
+			return nil, fmt.Errorf("data stream %s: must be in the type-dataset-namespace form, like logs-nginx.access-default", envelope.DataStream)
+		}
+
+		metadata["raw_index"] = envelope.DataStream
+		dataStream = map[string]interface{}{
+			"type":      dataStreamType,
+			"dataset":   rest[:i],
+			"namespace": rest[i+1:],
+		}
+	}
+
+	var buf bytes.Buffer
+	return func(doc []byte) ([]byte, error) {
+		var event map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(doc))
+		decoder.UseNumber()
+		if err := decoder.Decode(&event); err != nil || event == nil {
+			event = map[string]interface{}{"message": string(bytes.TrimSpace(doc))}
+		}
+
+		event["@metadata"] = metadata
+		if dataStream != nil {
+			event["data_stream"] = dataStream
+		}
+
+		buf.Reset()
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(event); err != nil {
+			return nil, err
+		}
+
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+	}, nil
+}
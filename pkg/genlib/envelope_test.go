@@ -0,0 +1,74 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"testing"
+)
+
+func Test_NewEnvelope(t *testing.T) {
+	testCases := []struct {
+		envelope string
+		doc      string
+		expected string
+	}{
+		{
+			envelope: "filebeat@8.12.0",
+			doc:      `{"message":"GET / 200","bytes":12345678901234567890}`,
+			expected: `{"@metadata":{"beat":"filebeat","raw_index":"logs-nginx.access-default","type":"_doc","version":"8.12.0"},"bytes":12345678901234567890,"data_stream":{"dataset":"nginx.access","namespace":"default","type":"logs"},"message":"GET / 200"}`,
+		},
+		{
+			envelope: "filebeat",
+			doc:      `10.0.0.1 - - [01/Jan/2024:00:00:00 +0000] "GET / HTTP/1.1" 200 612` + "\n",
+			expected: `{"@metadata":{"beat":"filebeat","raw_index":"logs-nginx.access-default","type":"_doc","version":"` + DefaultEnvelopeVersion + `"},"data_stream":{"dataset":"nginx.access","namespace":"default","type":"logs"},"message":"10.0.0.1 - - [01/Jan/2024:00:00:00 +0000] \"GET / HTTP/1.1\" 200 612"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		envelope, err := ParseEnvelope(tc.envelope)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		envelope.DataStream = "logs-nginx.access-default"
+		middleware, err := NewEnvelope(envelope)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		doc, err := middleware([]byte(tc.doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(doc) != tc.expected {
+			t.Errorf("expected %s to be wrapped in envelope %s as %s, got %s", tc.doc, tc.envelope, tc.expected, string(doc))
+		}
+	}
+
+	middleware, err := NewEnvelope(Envelope{Beat: "metricbeat", Version: "8.12.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := middleware([]byte(`{"system":{"cpu":{"total":{"pct":0.5}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := `{"@metadata":{"beat":"metricbeat","type":"_doc","version":"8.12.0"},"system":{"cpu":{"total":{"pct":0.5}}}}`; string(doc) != expected {
+		t.Errorf("expected an envelope without data stream %s, got %s", expected, string(doc))
+	}
+
+	for _, invalid := range []string{"", "@8.12.0", "filebeat@"} {
+		if _, err := ParseEnvelope(invalid); err == nil {
+			t.Errorf("expected error parsing envelope %q", invalid)
+		}
+	}
+
+	if _, err := NewEnvelope(Envelope{Beat: "filebeat", DataStream: "logs-nginx.access"}); err == nil {
+		t.Errorf("expected error for a data stream without namespace")
+	}
+}
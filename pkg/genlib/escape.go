@@ -0,0 +1,245 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+	"unicode/utf8"
+)
+
+const hexDigits = "0123456789abcdef"
+
+const (
+	// EscapeJSON escapes the generated string values as the content of JSON strings, the default
+	EscapeJSON = "json"
+	// EscapeNone writes the generated string values as they are
+	EscapeNone = "none"
+	// EscapeXML escapes the generated string values as XML character data, and then as the content of JSON strings
+	EscapeXML = "xml"
+)
+
+// SetRawValues disables, or enables again, the JSON escaping of the generated string values for the next emitted events:
+// by default quotes, backslashes and control characters are escaped, so that the values can be placed in JSON strings.
+// The values hardcoded with the value config entry are not affected.
+func (s *GenState) SetRawValues(raw bool) {
+	s.rawValues = raw
+}
+
+// escapedFieldNames returns the names of fieldNames whose values are escaped: the ones not hardcoded by the fields definition or the config,
+// unless their escape config entry is none.
+func escapedFieldNames(cfg Config, fields Fields, fieldNames []string) []string {
+	static := make(map[string]struct{})
+	for _, field := range fields {
+		if len(field.Value) > 0 {
+			static[field.Name] = struct{}{}
+		}
+	}
+
+	escaped := make([]string, 0, len(fieldNames))
+	for _, fieldName := range fieldNames {
+		// geo points formatted as objects are written as JSON already, and copies are escaped by their source already
+		if fieldCfg, _ := cfg.GetField(fieldName); fieldCfg.Value != nil || fieldCfg.GeoFormat == GeoFormatObject || len(fieldCfg.CopyFrom) > 0 || fieldCfg.Escape == EscapeNone {
+			continue
+		}
+
+		if _, ok := static[fieldName]; ok {
+			continue
+		}
+
+		escaped = append(escaped, fieldName)
+	}
+
+	return escaped
+}
+
+// makeEscapeStub escapes the value written by the bound function after its prefix of prefixLen bytes, as XML
+// character data first if escapeXML is set.
+func makeEscapeStub(escapeXML bool, prefixLen int, boundF emitFNotReturn) emitFNotReturn {
+	return func(state *GenState, buf *bytes.Buffer) error {
+		offset := buf.Len()
+		if err := boundF(state, buf); err != nil {
+			return err
+		}
+
+		if buf.Len()-offset < prefixLen {
+			return nil
+		}
+
+		// most values have nothing to escape, and do not take a buffer from the pool
+		value := buf.Bytes()[offset+prefixLen:]
+		xmlEscaped := escapeXML && needsXMLEscape(value)
+		jsonEscaped := !state.rawValues && needsJSONEscape(value)
+		if !xmlEscaped && !jsonEscaped {
+			return nil
+		}
+
+		tmp := state.pool.Get().(*bytes.Buffer)
+		defer state.pool.Put(tmp)
+
+		if xmlEscaped {
+			escapeXMLString(buf, offset+prefixLen, tmp)
+		}
+
+		if jsonEscaped {
+			escapeJSONString(buf, offset+prefixLen, tmp)
+		}
+
+		return nil
+	}
+}
+
+// makeEscapeStubWithReturn escapes the string values returned by the bound function, as XML character data first
+// if escapeXML is set.
+func makeEscapeStubWithReturn(escapeXML bool, boundF EmitF) EmitF {
+	return func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
+		value, err := boundF(state, buf)
+		if err != nil {
+			return value, err
+		}
+
+		s, ok := value.(string)
+		if !ok {
+			return value, nil
+		}
+
+		xmlEscaped := escapeXML && needsXMLEscape([]byte(s))
+		jsonEscaped := !state.rawValues && needsJSONEscape([]byte(s))
+		if !xmlEscaped && !jsonEscaped {
+			return value, nil
+		}
+
+		tmp := state.pool.Get().(*bytes.Buffer)
+		defer state.pool.Put(tmp)
+
+		var escaped bytes.Buffer
+		escaped.WriteString(s)
+		if xmlEscaped {
+			escapeXMLString(&escaped, 0, tmp)
+		}
+
+		if jsonEscaped {
+			escapeJSONString(&escaped, 0, tmp)
+		}
+
+		return escaped.String(), nil
+	}
+}
+
+func needsJSONEscape(b []byte) bool {
+	for _, c := range b {
+		if c == '"' || c == '\\' || c < 0x20 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// escapeJSONString escapes in place the bytes of buf after offset, as the content of a JSON string:
+// tmp holds the bytes being escaped, so that it can be reused across values.
+func escapeJSONString(buf *bytes.Buffer, offset int, tmp *bytes.Buffer) {
+	if !needsJSONEscape(buf.Bytes()[offset:]) {
+		return
+	}
+
+	tmp.Reset()
+	tmp.Write(buf.Bytes()[offset:])
+	buf.Truncate(offset)
+	for _, c := range tmp.Bytes() {
+		switch c {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[c>>4])
+				buf.WriteByte(hexDigits[c&0xf])
+				continue
+			}
+
+			buf.WriteByte(c)
+		}
+	}
+}
+
+// unescapeJSONString unescapes in place the bytes of buf after offset, escaped by escapeJSONString:
+// tmp holds the bytes being unescaped, so that it can be reused across values.
+func unescapeJSONString(buf *bytes.Buffer, offset int, tmp *bytes.Buffer) {
+	if bytes.IndexByte(buf.Bytes()[offset:], '\\') < 0 {
+		return
+	}
+
+	tmp.Reset()
+	tmp.Write(buf.Bytes()[offset:])
+	buf.Truncate(offset)
+	b := tmp.Bytes()
+	for i := 0; i < len(b); i++ {
+		if b[i] != '\\' || i == len(b)-1 {
+			buf.WriteByte(b[i])
+			continue
+		}
+
+		i++
+		switch b[i] {
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 't':
+			buf.WriteByte('\t')
+		case 'u':
+			if i+5 <= len(b) {
+				if r, err := strconv.ParseUint(string(b[i+1:i+5]), 16, 16); err == nil {
+					buf.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+
+			buf.WriteString(`\u`)
+		default:
+			buf.WriteByte(b[i])
+		}
+	}
+}
+
+func needsXMLEscape(b []byte) bool {
+	for _, c := range b {
+		switch c {
+		case '<', '>', '&', '"', '\'':
+			return true
+		}
+
+		// control characters are escaped, and invalid UTF-8 replaced
+		if c < 0x20 || c >= utf8.RuneSelf {
+			return true
+		}
+	}
+
+	return false
+}
+
+// escapeXMLString escapes in place the bytes of buf after offset, as XML character data or attribute value, like
+// encoding/xml.EscapeText: tmp holds the bytes being escaped, so that it can be reused across values.
+func escapeXMLString(buf *bytes.Buffer, offset int, tmp *bytes.Buffer) {
+	if !needsXMLEscape(buf.Bytes()[offset:]) {
+		return
+	}
+
+	tmp.Reset()
+	tmp.Write(buf.Bytes()[offset:])
+	buf.Truncate(offset)
+	// writing to a bytes.Buffer does not fail
+	_ = xml.EscapeText(buf, tmp.Bytes())
+}
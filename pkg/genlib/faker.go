@@ -0,0 +1,105 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"embed"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+const (
+	// GeneratorPersonName generates first and last names, like Mary Johnson
+	GeneratorPersonName = "person_name"
+	// GeneratorEmail generates email addresses made of a person name and a domain, like mary.johnson@cedar.com
+	GeneratorEmail = "email"
+	// GeneratorDomain generates domain names, like cedar.com
+	GeneratorDomain = "domain"
+	// GeneratorFilePath generates absolute file paths with an extension, like /var/log/summit.log
+	GeneratorFilePath = "file_path"
+	// GeneratorUUID generates version 4 UUIDs
+	GeneratorUUID = "uuid"
+	// GeneratorMACAddress generates MAC addresses in the ECS format, like 02-42-AC-11-00-02
+	GeneratorMACAddress = "mac_address"
+)
+
+//go:embed wordlists/*.txt
+var wordlistsFS embed.FS
+
+var (
+	firstNames  = loadWordlist("first_names.txt")
+	lastNames   = loadWordlist("last_names.txt")
+	words       = loadWordlist("words.txt")
+	directories = loadWordlist("directories.txt")
+	extensions  = loadWordlist("extensions.txt")
+)
+
+// loadWordlist returns the words of the embedded wordlist name, one per line.
+func loadWordlist(name string) []string {
+	b, err := wordlistsFS.ReadFile("wordlists/" + name)
+	if err != nil {
+		panic(err)
+	}
+
+	return strings.Fields(string(b))
+}
+
+func pick(values []string) string {
+	return values[rand.Intn(len(values))]
+}
+
+func personName() string {
+	return pick(firstNames) + " " + pick(lastNames)
+}
+
+func email() string {
+	local := strings.ToLower(pick(firstNames))
+	switch rand.Intn(3) {
+	case 0:
+		local += "." + strings.ToLower(pick(lastNames))
+	case 1:
+		local = local[:1] + strings.ToLower(pick(lastNames))
+	default:
+		local += fmt.Sprintf("%d", rand.Intn(100))
+	}
+
+	return local + "@" + domain()
+}
+
+func domain() string {
+	return pick(words) + "." + pick(topLevelDomains)
+}
+
+func filePath() string {
+	var path strings.Builder
+	for i := rand.Intn(3) + 1; i > 0; i-- {
+		path.WriteString("/")
+		path.WriteString(pick(directories))
+	}
+
+	path.WriteString("/")
+	path.WriteString(pick(words))
+	path.WriteString(".")
+	path.WriteString(pick(extensions))
+	return path.String()
+}
+
+func uuid() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	// version 4, variant RFC 4122
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func macAddress() string {
+	var b [6]byte
+	_, _ = rand.Read(b[:])
+	// locally administered, unicast
+	b[0] = b[0]&0xfc | 0x02
+	return fmt.Sprintf("%02X-%02X-%02X-%02X-%02X-%02X", b[0], b[1], b[2], b[3], b[4], b[5])
+}
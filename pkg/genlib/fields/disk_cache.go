@@ -0,0 +1,108 @@
+package fields
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cachePath returns the path of the fields of the data stream of the package version in the cache.
+func (o loadOptions) cachePath(integration, dataStream, version string) string {
+	return filepath.Join(o.cacheDir, integration, version, dataStream+".yml")
+}
+
+// getFieldsFiles returns the fields files of the data stream from the cache if any, otherwise downloads them from
+// the package registry unless offline, and adds them to the cache.
+func (o loadOptions) getFieldsFiles(ctx context.Context, baseURL, integration, dataStream, version string) ([]byte, error) {
+	if o.cacheDir != "" {
+		fieldsContent, err := os.ReadFile(o.cachePath(integration, dataStream, version))
+		if err == nil {
+			return fieldsContent, nil
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	if o.offline {
+		return nil, fmt.Errorf("package %s version %s data stream %s: %w", integration, version, dataStream, ErrCacheMiss)
+	}
+
+	fieldsContent, err := getFieldsFiles(ctx, baseURL, integration, dataStream, version)
+	if err != nil || o.cacheDir == "" || len(fieldsContent) == 0 {
+		return fieldsContent, err
+	}
+
+	if err := writeCache(o.cachePath(integration, dataStream, version), fieldsContent); err != nil {
+		return nil, fmt.Errorf("caching the fields: %w", err)
+	}
+
+	return fieldsContent, nil
+}
+
+// dataStreamsCachePath returns the path of the list of the data streams of the package version in the cache:
+// it has no .yml extension not to clash with the fields of a data stream.
+func (o loadOptions) dataStreamsCachePath(integration, version string) string {
+	return filepath.Join(o.cacheDir, integration, version, "data_streams")
+}
+
+// getDataStreams returns the data streams of the package version from the cache if any, otherwise downloads them
+// from the package registry unless offline, and adds them to the cache.
+func (o loadOptions) getDataStreams(ctx context.Context, baseURL, integration, version string) ([]string, error) {
+	if o.cacheDir != "" {
+		content, err := os.ReadFile(o.dataStreamsCachePath(integration, version))
+		if err == nil {
+			return strings.Fields(string(content)), nil
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	if o.offline {
+		return nil, fmt.Errorf("package %s version %s data streams: %w", integration, version, ErrCacheMiss)
+	}
+
+	dataStreams, err := getDataStreams(ctx, baseURL, integration, version)
+	if err != nil || o.cacheDir == "" || len(dataStreams) == 0 {
+		return dataStreams, err
+	}
+
+	if err := writeCache(o.dataStreamsCachePath(integration, version), []byte(strings.Join(dataStreams, "\n")+"\n")); err != nil {
+		return nil, fmt.Errorf("caching the data streams: %w", err)
+	}
+
+	return dataStreams, nil
+}
+
+// writeCache writes content to cachePath, through a temporary file renamed in place,
+// so that concurrent runs never read a partially written file.
+func writeCache(cachePath string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(cachePath), filepath.Base(cachePath)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), cachePath)
+}
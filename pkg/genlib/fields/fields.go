@@ -18,6 +18,15 @@ type Field struct {
 	ObjectType string
 	Example    string
 	Value      string
+	// Description and MetricType are metadata of the field, not affecting the generated values
+	Description string
+	// Unit bounds the values generated for numeric fields, like percent ones in 0-1
+	Unit       string
+	MetricType string
+	// Dimension marks the fields identifying a time series, they are always generated in TSDB mode
+	Dimension bool
+	// ScalingFactor is the scaling factor of the scaled_float fields, their values are the multiples of its inverse
+	ScalingFactor float64
 }
 
 func (fields Fields) merge(fieldsToMerge ...Field) Fields {
@@ -36,6 +45,24 @@ func (fields Fields) merge(fieldsToMerge ...Field) Fields {
 				field.Value = currentField.Value
 			}
 
+			if currentField.Description > field.Description {
+				field.Description = currentField.Description
+			}
+
+			if currentField.Unit > field.Unit {
+				field.Unit = currentField.Unit
+			}
+
+			if currentField.MetricType > field.MetricType {
+				field.MetricType = currentField.MetricType
+			}
+
+			field.Dimension = field.Dimension || currentField.Dimension
+
+			if currentField.ScalingFactor > field.ScalingFactor {
+				field.ScalingFactor = currentField.ScalingFactor
+			}
+
 			merged = true
 			break
 		}
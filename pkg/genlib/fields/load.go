@@ -13,12 +13,15 @@ import (
 	"net/url"
 	"os"
 	"path"
-	"path/filepath"
+	"sort"
 	"strings"
 )
 
 var ErrNotFound = errors.New("Not found")
 
+// ErrCacheMiss is returned by LoadFields in offline mode when the fields are not in the cache
+var ErrCacheMiss = errors.New("fields not in the cache")
+
 const (
 	fieldsSlug        = "fields"
 	packageSlug       = "package"
@@ -28,9 +31,36 @@ const (
 	manifestSlug      = "manifest.yml"
 )
 
-func LoadFields(ctx context.Context, baseURL, integration, dataStream, version string) (Fields, error) {
+type loadOptions struct {
+	cacheDir string
+	offline  bool
+}
+
+type LoadOption func(*loadOptions)
+
+// WithCacheDir caches the fields downloaded from the package registry in dir, keyed by package, version and data stream:
+// since a released package does not change, the cached fields are used from then on.
+func WithCacheDir(dir string) LoadOption {
+	return func(o *loadOptions) {
+		o.cacheDir = dir
+	}
+}
+
+// WithOffline loads the fields from the cache only, failing with ErrCacheMiss if they are not there,
+// instead of downloading them from the package registry.
+func WithOffline() LoadOption {
+	return func(o *loadOptions) {
+		o.offline = true
+	}
+}
+
+func LoadFields(ctx context.Context, baseURL, integration, dataStream, version string, opts ...LoadOption) (Fields, error) {
+	var o loadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
 
-	fieldsContent, err := getFieldsFiles(ctx, baseURL, integration, dataStream, version)
+	fieldsContent, err := o.getFieldsFiles(ctx, baseURL, integration, dataStream, version)
 	if err != nil {
 		return nil, err
 	}
@@ -49,26 +79,46 @@ func LoadFields(ctx context.Context, baseURL, integration, dataStream, version s
 	return normaliseFields(fields)
 }
 
+// LoadDataStreams returns the names of the data streams of the package version in the package registry, sorted.
+func LoadDataStreams(ctx context.Context, baseURL, integration, version string, opts ...LoadOption) ([]string, error) {
+	var o loadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	dataStreams, err := o.getDataStreams(ctx, baseURL, integration, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dataStreams) == 0 {
+		return nil, fmt.Errorf("package %s version %s has no data stream: %w", integration, version, ErrNotFound)
+	}
+
+	return dataStreams, nil
+}
+
 func LoadFieldsWithTemplate(ctx context.Context, fieldYamlPath string) (Fields, error) {
 	fieldsFileContent, err := os.ReadFile(fieldYamlPath)
 	if err != nil {
 		return nil, err
 	}
 
-	var fieldsContent string
+	return loadKeyedFields(keyedFieldsContent(fieldYamlPath, fieldsFileContent))
+}
 
-	key := strings.TrimSuffix(filepath.Base(fieldYamlPath), filepath.Ext(fieldYamlPath))
-	keyEntry := fmt.Sprintf("- key: %s\n  fields:\n", key)
-	for _, line := range strings.Split(string(fieldsFileContent), "\n") {
-		keyEntry += `    ` + line + "\n"
-	}
+// LoadFieldsFromYaml loads the fields definition in fieldsContent, in the format of the fields definition files
+// of the templates: a list of fields.
+func LoadFieldsFromYaml(fieldsContent []byte) (Fields, error) {
+	return loadKeyedFields(keyedFieldsContent("fields.yml", fieldsContent))
+}
 
-	fieldsContent += keyEntry
+func loadKeyedFields(fieldsContent []byte) (Fields, error) {
 	if len(fieldsContent) == 0 {
 		return nil, ErrNotFound
 	}
 
-	fieldsFromYaml, err := loadFieldsFromYaml([]byte(fieldsContent))
+	fieldsFromYaml, err := loadFieldsFromYaml(fieldsContent)
 	if err != nil {
 		return nil, err
 	}
@@ -144,7 +194,7 @@ func getFieldsFiles(ctx context.Context, baseURL, integration, dataStream, versi
 
 	prefixFieldsPath := path.Join(fmt.Sprintf("%s-%s", integration, version), dataStreamSlug, dataStream, fieldsSlug)
 
-	var fieldsContent string
+	var fieldsContent []byte
 	for _, z := range archive.File {
 		if z.FileInfo().IsDir() {
 			continue
@@ -172,16 +222,42 @@ func getFieldsFiles(ctx context.Context, baseURL, integration, dataStream, versi
 		}
 
 		_ = zr.Close()
-		key := strings.TrimSuffix(filepath.Base(fieldsFileName), filepath.Ext(fieldsFileName))
-		keyEntry := fmt.Sprintf("- key: %s\n  fields:\n", key)
-		for _, line := range strings.Split(string(fieldsFileContent), "\n") {
-			keyEntry += `    ` + line + "\n"
-		}
+		fieldsContent = append(fieldsContent, keyedFieldsContent(fieldsFileName, fieldsFileContent)...)
+	}
+
+	return fieldsContent, nil
+}
+
+func getDataStreams(ctx context.Context, baseURL, integration, version string) ([]string, error) {
+	packageURL, err := makePackageURL(baseURL, integration, version)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := getFromURL(ctx, packageURL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	var packagePayload struct {
+		DataStreams []struct {
+			Path string `json:"path"`
+		} `json:"data_streams"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&packagePayload); err != nil {
+		return nil, err
+	}
 
-		fieldsContent += keyEntry
+	dataStreams := make([]string, 0, len(packagePayload.DataStreams))
+	for _, dataStream := range packagePayload.DataStreams {
+		dataStreams = append(dataStreams, dataStream.Path)
 	}
 
-	return []byte(fieldsContent), nil
+	sort.Strings(dataStreams)
+	return dataStreams, nil
 }
 
 func getFromURL(ctx context.Context, srcURL string) (io.ReadCloser, error) {
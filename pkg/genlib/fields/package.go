@@ -0,0 +1,243 @@
+package fields
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/elastic/go-ucfg/yaml"
+)
+
+// PackageManifest is the manifest of a package, at the root of its directory
+type PackageManifest struct {
+	Name    string `config:"name"`
+	Title   string `config:"title"`
+	Version string `config:"version"`
+}
+
+// LoadFieldsFromPackage loads the fields of dataStream from a local package, either its source directory, as laid out
+// in the elastic/integrations repository, or its zip archive, as built by elastic-package or served by the package
+// registry. It returns the manifest of the package alongside the fields.
+// The fields imported from ECS with `external: ecs` are resolved when building the package only: their type is
+// missing in the source directory.
+func LoadFieldsFromPackage(ctx context.Context, packagePath, dataStream string) (Fields, PackageManifest, error) {
+	files, closeFiles, err := openPackageFiles(packagePath)
+	if err != nil {
+		return nil, PackageManifest{}, err
+	}
+
+	defer closeFiles()
+
+	manifest, err := loadPackageManifest(files)
+	if err != nil {
+		return nil, PackageManifest{}, fmt.Errorf("package %s: %w", packagePath, err)
+	}
+
+	fieldsPaths, err := files.glob(path.Join(dataStreamSlug, dataStream, fieldsSlug, "*.yml"))
+	if err != nil {
+		return nil, PackageManifest{}, err
+	}
+
+	var fieldsContent []byte
+	for _, fieldsPath := range fieldsPaths {
+		if err := ctx.Err(); err != nil {
+			return nil, PackageManifest{}, err
+		}
+
+		fieldsFileContent, err := files.read(fieldsPath)
+		if err != nil {
+			return nil, PackageManifest{}, err
+		}
+
+		fieldsContent = append(fieldsContent, keyedFieldsContent(fieldsPath, fieldsFileContent)...)
+	}
+
+	if len(fieldsContent) == 0 {
+		return nil, PackageManifest{}, fmt.Errorf("package %s: data stream %s: %w", packagePath, dataStream, ErrNotFound)
+	}
+
+	fieldsFromYaml, err := loadFieldsFromYaml(fieldsContent)
+	if err != nil {
+		return nil, PackageManifest{}, err
+	}
+
+	fields := collectFields(fieldsFromYaml, "")
+
+	flds, err := normaliseFields(fields)
+	return flds, manifest, err
+}
+
+// LoadDataStreamsFromPackage returns the names of the data streams of the local package at packagePath, either its
+// source directory or its zip archive, that define fields, sorted.
+func LoadDataStreamsFromPackage(packagePath string) ([]string, error) {
+	files, closeFiles, err := openPackageFiles(packagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer closeFiles()
+
+	fieldsPaths, err := files.glob(path.Join(dataStreamSlug, "*", fieldsSlug, "*.yml"))
+	if err != nil {
+		return nil, err
+	}
+
+	var dataStreams []string
+	for _, fieldsPath := range fieldsPaths {
+		// data_stream/name/fields/file.yml
+		dataStream := strings.Split(fieldsPath, "/")[1]
+		if len(dataStreams) == 0 || dataStreams[len(dataStreams)-1] != dataStream {
+			dataStreams = append(dataStreams, dataStream)
+		}
+	}
+
+	if len(dataStreams) == 0 {
+		return nil, fmt.Errorf("package %s has no data stream: %w", packagePath, ErrNotFound)
+	}
+
+	return dataStreams, nil
+}
+
+// openPackageFiles opens the files of the package at packagePath, the returned function closes them.
+func openPackageFiles(packagePath string) (packageFiles, func(), error) {
+	if !strings.EqualFold(filepath.Ext(packagePath), ".zip") {
+		return dirPackageFiles{dir: packagePath}, func() {}, nil
+	}
+
+	archive, err := zip.OpenReader(packagePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return zipPackageFiles{archive: &archive.Reader}, func() { _ = archive.Close() }, nil
+}
+
+func loadPackageManifest(files packageFiles) (PackageManifest, error) {
+	content, err := files.read(manifestSlug)
+	if err != nil {
+		return PackageManifest{}, err
+	}
+
+	cfg, err := yaml.NewConfig(content)
+	if err != nil {
+		return PackageManifest{}, err
+	}
+
+	var manifest PackageManifest
+	if err := cfg.Unpack(&manifest); err != nil {
+		return PackageManifest{}, err
+	}
+
+	if manifest.Name == "" || manifest.Version == "" {
+		return PackageManifest{}, fmt.Errorf("%s must provide the name and the version of the package", manifestSlug)
+	}
+
+	return manifest, nil
+}
+
+// keyedFieldsContent wraps the content of a fields file under a key named after the file,
+// as expected by loadFieldsFromYaml.
+func keyedFieldsContent(fieldsFileName string, fieldsFileContent []byte) []byte {
+	key := strings.TrimSuffix(filepath.Base(fieldsFileName), filepath.Ext(fieldsFileName))
+	keyEntry := fmt.Sprintf("- key: %s\n  fields:\n", key)
+	for _, line := range strings.Split(string(fieldsFileContent), "\n") {
+		keyEntry += `    ` + line + "\n"
+	}
+
+	return []byte(keyEntry)
+}
+
+// packageFiles reads the files of a package, by their slash separated path relative to the root of the package.
+type packageFiles interface {
+	read(name string) ([]byte, error)
+	// glob returns the files matching pattern, sorted
+	glob(pattern string) ([]string, error)
+}
+
+type dirPackageFiles struct {
+	dir string
+}
+
+func (f dirPackageFiles) read(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(f.dir, filepath.FromSlash(name)))
+}
+
+func (f dirPackageFiles) glob(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(f.dir, filepath.FromSlash(pattern)))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(matches))
+	for _, match := range matches {
+		name, err := filepath.Rel(f.dir, match)
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, filepath.ToSlash(name))
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// zipPackageFiles reads the files of a zip archive, whose root is either the root of the package or a single
+// directory containing it, like name-version/ in the archives of the package registry.
+type zipPackageFiles struct {
+	archive *zip.Reader
+}
+
+// root returns the prefix of the paths of the files of the package in the archive.
+func (f zipPackageFiles) root() string {
+	for _, z := range f.archive.File {
+		if z.Name == manifestSlug {
+			return ""
+		}
+
+		if dir, file := path.Split(z.Name); file == manifestSlug && strings.Count(dir, "/") == 1 {
+			return dir
+		}
+	}
+
+	return ""
+}
+
+func (f zipPackageFiles) read(name string) ([]byte, error) {
+	zr, err := f.archive.Open(f.root() + name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+func (f zipPackageFiles) glob(pattern string) ([]string, error) {
+	root := f.root()
+	var names []string
+	for _, z := range f.archive.File {
+		if z.FileInfo().IsDir() || !strings.HasPrefix(z.Name, root) {
+			continue
+		}
+
+		name := strings.TrimPrefix(z.Name, root)
+		matched, err := path.Match(pattern, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if matched {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
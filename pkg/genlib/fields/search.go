@@ -0,0 +1,55 @@
+package fields
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// PackageSummary is a package as listed by the package registry search
+type PackageSummary struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+// SearchPackages returns the latest version of the packages in the package registry whose name or title contain
+// query, ignoring the case, sorted by name. All the packages are returned if query is empty.
+func SearchPackages(ctx context.Context, baseURL, query string) ([]PackageSummary, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path = path.Join(u.Path, searchSlug)
+
+	r, err := getFromURL(ctx, u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	var payload []PackageSummary
+	if err := json.NewDecoder(r).Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	var packages []PackageSummary
+	for _, p := range payload {
+		if strings.Contains(strings.ToLower(p.Name), query) || strings.Contains(strings.ToLower(p.Title), query) {
+			packages = append(packages, p)
+		}
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Name < packages[j].Name
+	})
+
+	return packages, nil
+}
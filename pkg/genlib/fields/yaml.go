@@ -7,12 +7,17 @@ import (
 type yamlFields []yamlField
 
 type yamlField struct {
-	Name       string     `config:"name"`
-	Type       string     `config:"type"`
-	ObjectType string     `config:"object_type"`
-	Value      string     `config:"value"`
-	Example    string     `config:"example"`
-	Fields     yamlFields `config:"fields"`
+	Name          string     `config:"name"`
+	Type          string     `config:"type"`
+	ObjectType    string     `config:"object_type"`
+	Value         string     `config:"value"`
+	Example       string     `config:"example"`
+	Description   string     `config:"description"`
+	Unit          string     `config:"unit"`
+	MetricType    string     `config:"metric_type"`
+	Dimension     bool       `config:"dimension"`
+	ScalingFactor float64    `config:"scaling_factor"`
+	Fields        yamlFields `config:"fields"`
 }
 
 func loadFieldsFromYaml(f []byte) (yamlFields, error) {
@@ -38,10 +43,15 @@ func collectFields(fieldsFromYaml yamlFields, namePrefix string) Fields {
 	fields := make(Fields, 0, len(fieldsFromYaml))
 	for _, fieldFromYaml := range fieldsFromYaml {
 		field := Field{
-			Type:       fieldFromYaml.Type,
-			ObjectType: fieldFromYaml.ObjectType,
-			Example:    fieldFromYaml.Example,
-			Value:      fieldFromYaml.Value,
+			Type:          fieldFromYaml.Type,
+			ObjectType:    fieldFromYaml.ObjectType,
+			Example:       fieldFromYaml.Example,
+			Value:         fieldFromYaml.Value,
+			Description:   fieldFromYaml.Description,
+			Unit:          fieldFromYaml.Unit,
+			MetricType:    fieldFromYaml.MetricType,
+			Dimension:     fieldFromYaml.Dimension,
+			ScalingFactor: fieldFromYaml.ScalingFactor,
 		}
 
 		if len(namePrefix) == 0 {
@@ -0,0 +1,71 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import "sort"
+
+// FieldsDiff is the difference between two fields definitions, each list sorted by field name.
+type FieldsDiff struct {
+	Added   Fields
+	Removed Fields
+	Retyped []RetypedField
+}
+
+// RetypedField is a field whose type differs between two fields definitions.
+type RetypedField struct {
+	Name string
+	From string
+	To   string
+}
+
+// Empty tells whether the fields definitions are the same, as far as the names and the types of their fields go.
+func (d FieldsDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Retyped) == 0
+}
+
+// DiffFields returns the fields added to from in to, the ones removed and the ones whose type, or object_type for
+// the object fields, changed.
+func DiffFields(from, to Fields) FieldsDiff {
+	fromByName := make(map[string]Field, len(from))
+	for _, field := range from {
+		fromByName[field.Name] = field
+	}
+
+	var diff FieldsDiff
+	toNames := make(map[string]struct{}, len(to))
+	for _, field := range to {
+		toNames[field.Name] = struct{}{}
+		previous, ok := fromByName[field.Name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, field)
+		case FieldTypeName(previous) != FieldTypeName(field):
+			diff.Retyped = append(diff.Retyped, RetypedField{Name: field.Name, From: FieldTypeName(previous), To: FieldTypeName(field)})
+		}
+	}
+
+	for _, field := range from {
+		if _, ok := toNames[field.Name]; !ok {
+			diff.Removed = append(diff.Removed, field)
+		}
+	}
+
+	sort.Sort(diff.Added)
+	sort.Sort(diff.Removed)
+	sort.Slice(diff.Retyped, func(i, j int) bool {
+		return diff.Retyped[i].Name < diff.Retyped[j].Name
+	})
+
+	return diff
+}
+
+// FieldTypeName returns the type of field, followed by its object_type for the object fields, like object of long.
+func FieldTypeName(field Field) string {
+	if field.ObjectType != "" {
+		return field.Type + " of " + field.ObjectType
+	}
+
+	return field.Type
+}
@@ -0,0 +1,44 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_DiffFields(t *testing.T) {
+	from := Fields{
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "labels.*", Type: FieldTypeObject, ObjectType: FieldTypeKeyword},
+		{Name: "network.bytes", Type: FieldTypeLong},
+		{Name: "user.name", Type: FieldTypeKeyword},
+	}
+
+	to := Fields{
+		{Name: "user.name", Type: FieldTypeKeyword},
+		{Name: "network.bytes", Type: FieldTypeDouble},
+		{Name: "labels.*", Type: FieldTypeObject, ObjectType: FieldTypeLong},
+		{Name: "event.action", Type: FieldTypeKeyword},
+	}
+
+	diff := DiffFields(from, to)
+	expected := FieldsDiff{
+		Added:   Fields{{Name: "event.action", Type: FieldTypeKeyword}},
+		Removed: Fields{{Name: "host.name", Type: FieldTypeKeyword}},
+		Retyped: []RetypedField{
+			{Name: "labels.*", From: "object of keyword", To: "object of long"},
+			{Name: "network.bytes", From: FieldTypeLong, To: FieldTypeDouble},
+		},
+	}
+
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("expected diff %+v, got %+v", expected, diff)
+	}
+
+	if !DiffFields(from, from).Empty() {
+		t.Errorf("expected no diff between the same fields")
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+)
+
+// NewFilter returns a Middleware keeping the JSON documents for which expression evaluates to true and dropping the others.
+// The expression is a text/template pipeline, without the delimiters, with the sprig functions and a field function
+// returning the value of a field of the document by its dotted path, for example:
+//
+//	eq (field "event.outcome") "failure"
+//
+// The returned Middleware must not be used concurrently.
+func NewFilter(expression string) (Middleware, error) {
+	var doc map[string]interface{}
+
+	templateFns := sprig.HermeticTxtFuncMap()
+	templateFns["field"] = func(path string) interface{} {
+		return lookupField(doc, path)
+	}
+
+	tpl, err := template.New("filter").Funcs(templateFns).Parse("{{" + expression + "}}")
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse filter: %w", err)
+	}
+
+	var buf bytes.Buffer
+	return func(d []byte) ([]byte, error) {
+		decoder := json.NewDecoder(bytes.NewReader(d))
+		decoder.UseNumber()
+		doc = nil
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("cannot filter a document that is not a JSON object: %w", err)
+		}
+
+		buf.Reset()
+		if err := tpl.Execute(&buf, doc); err != nil {
+			return nil, fmt.Errorf("cannot evaluate filter: %w", err)
+		}
+
+		switch strings.TrimSpace(buf.String()) {
+		case "true":
+			return d, nil
+		case "false":
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("filter must evaluate to true or false, got %s", buf.String())
+		}
+	}, nil
+}
+
+// lookupField returns the value at the dotted path in doc, whether the path is split in nested objects or not.
+// Numbers are returned as int64 when they are integers, as float64 otherwise.
+func lookupField(doc map[string]interface{}, path string) interface{} {
+	if v, ok := doc[path]; ok {
+		return normalizeNumber(v)
+	}
+
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+
+		if nested, ok := doc[path[:i]].(map[string]interface{}); ok {
+			if v := lookupField(nested, path[i+1:]); v != nil {
+				return v
+			}
+		}
+	}
+
+	return nil
+}
+
+func normalizeNumber(v interface{}) interface{} {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+
+	f, _ := n.Float64()
+	return f
+}
@@ -0,0 +1,57 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"testing"
+)
+
+func Test_NewFilter(t *testing.T) {
+	testCases := []struct {
+		expression string
+		doc        string
+		keep       bool
+	}{
+		{expression: `eq (field "event.outcome") "failure"`, doc: `{"event.outcome":"failure"}`, keep: true},
+		{expression: `eq (field "event.outcome") "failure"`, doc: `{"event":{"outcome":"success"}}`, keep: false},
+		{expression: `eq (field "event.outcome") "failure"`, doc: `{"event":{"outcome":"failure"}}`, keep: true},
+		{expression: `ge (field "http.response.status_code") 500`, doc: `{"http.response":{"status_code":503}}`, keep: true},
+		{expression: `gt (field "duration") 1.5`, doc: `{"duration":1.25}`, keep: false},
+		{expression: `and (field "ok") (not (field "missing"))`, doc: `{"ok":true}`, keep: true},
+	}
+
+	for _, tc := range testCases {
+		filter, err := NewFilter(tc.expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		doc, err := filter([]byte(tc.doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if (doc != nil) != tc.keep {
+			t.Errorf("expected %s to be kept %t by %s", tc.doc, tc.keep, tc.expression)
+		}
+	}
+
+	filter, err := NewFilter(`field "event.outcome"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := filter([]byte(`{"event.outcome":"failure"}`)); err == nil {
+		t.Errorf("expected error for a filter not evaluating to a boolean")
+	}
+
+	if _, err := filter([]byte(`not json`)); err == nil {
+		t.Errorf("expected error for a document not JSON")
+	}
+
+	if _, err := NewFilter(`eq (field`); err == nil {
+		t.Errorf("expected error for a filter not valid")
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// isFloatType tells whether fieldType is a floating point type.
+func isFloatType(fieldType string) bool {
+	switch fieldType {
+	case FieldTypeDouble, FieldTypeFloat, FieldTypeHalfFloat, FieldTypeScaledFloat:
+		return true
+	}
+
+	return false
+}
+
+// FloatPrecision returns cfg with the precision of the floating point fields of flds, and of the keys of
+// the objects of floating point values, set to precision decimal places, unless their config, or the config
+// of their rules, already sets one.
+func FloatPrecision(cfg Config, flds Fields, precision int) Config {
+	for _, field := range flds {
+		names := []string{field.Name}
+		switch field.Type {
+		case FieldTypeObject, FieldTypeNested, FieldTypeFlattened:
+			if !isFloatType(field.ObjectType) {
+				continue
+			}
+
+			fieldCfg, _ := cfg.GetField(field.Name)
+			for _, objectKey := range fieldCfg.ObjectKeys {
+				names = append(names, replacer.Replace(field.Name)+"."+objectKey)
+			}
+		default:
+			if !isFloatType(field.Type) {
+				continue
+			}
+		}
+
+		for _, name := range names {
+			fieldCfg, _ := cfg.GetField(name)
+			fieldCfg.Name = name
+			if fieldCfg.Precision == nil {
+				fieldCfg.Precision = &precision
+			}
+
+			// the rules replace the config of the field
+			if len(fieldCfg.Rules) > 0 {
+				rules := make([]Rule, len(fieldCfg.Rules))
+				for i, rule := range fieldCfg.Rules {
+					if rule.Then.Precision == nil {
+						rule.Then.Precision = &precision
+					}
+
+					rules[i] = rule
+				}
+
+				fieldCfg.Rules = rules
+			}
+
+			cfg = cfg.WithField(fieldCfg)
+		}
+	}
+
+	return cfg
+}
+
+// scaledFloat returns fieldCfg and the rounding of the values of field: the values of the scaled_float fields with a
+// scaling factor are rounded to the multiples of its inverse, the values Elasticsearch stores, and are written with
+// the decimal places they need, unless a precision is set. The values of the other fields are not rounded.
+func scaledFloat(fieldCfg ConfigField, field Field) (ConfigField, func(float64) float64) {
+	if field.Type != FieldTypeScaledFloat || field.ScalingFactor <= 0 {
+		return fieldCfg, func(v float64) float64 { return v }
+	}
+
+	if fieldCfg.Precision == nil {
+		// the shortest representation of the rounded values
+		shortest := -1
+		fieldCfg.Precision = &shortest
+	}
+
+	scalingFactor := field.ScalingFactor
+	return fieldCfg, func(v float64) float64 {
+		return math.Round(v*scalingFactor) / scalingFactor
+	}
+}
+
+// writeFloat writes v with the precision of the config of its field, with 6 decimal places if not set:
+// floating point values are never written in scientific notation.
+func writeFloat(buf *bytes.Buffer, fieldCfg ConfigField, v float64) {
+	precision := 6
+	if fieldCfg.Precision != nil {
+		precision = *fieldCfg.Precision
+	}
+
+	var b [32]byte
+	buf.Write(strconv.AppendFloat(b[:0], v, 'f', precision, 64))
+}
+
+// returnFloat returns v as the value of a text or structured template: with the precision of the config of its field,
+// if set, as a json.Number, that is rendered with its decimal places and still converts to a number in the templates.
+func returnFloat(fieldCfg ConfigField, v float64) interface{} {
+	if fieldCfg.Precision == nil {
+		return v
+	}
+
+	return json.Number(strconv.FormatFloat(v, 'f', *fieldCfg.Precision, 64))
+}
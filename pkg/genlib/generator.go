@@ -58,113 +58,239 @@ func generateTextTemplateFromField(cfg Config, fields Fields) ([]byte, []Field)
 	return generateTemplateFromField(cfg, fields, textTemplateEngine)
 }
 
-func generateTemplateFromField(cfg Config, fields Fields, templateEngine int) ([]byte, []Field) {
-	if len(fields) == 0 {
-		return nil, nil
+// templateObject is an object of the template generated from the fields definition: its members are either the
+// placeholder of a field or a nested object, in order of appearance.
+type templateObject struct {
+	keys    []string
+	fields  map[string]Field
+	objects map[string]*templateObject
+}
+
+func newTemplateObject() *templateObject {
+	return &templateObject{fields: make(map[string]Field), objects: make(map[string]*templateObject)}
+}
+
+// add adds the placeholder of field under its dotted name, or under the path of nested objects of its name if nested
+// is set. It returns false if the name is taken already, by a field or by an object.
+func (o *templateObject) add(field Field, nested bool) bool {
+	path := []string{field.Name}
+	if nested {
+		path = strings.Split(field.Name, ".")
 	}
 
-	dupes := make(map[string]struct{})
-	objectKeysField := make([]Field, 0, len(fields))
+	for _, key := range path[:len(path)-1] {
+		if _, ok := o.fields[key]; ok {
+			return false
+		}
 
-	templatePrefix := "{ "
-	templateBuffer := bytes.NewBufferString(templatePrefix)
-	for i, field := range fields {
+		object, ok := o.objects[key]
+		if !ok {
+			object = newTemplateObject()
+			o.objects[key] = object
+			o.keys = append(o.keys, key)
+		}
+
+		o = object
+	}
+
+	key := path[len(path)-1]
+	if _, ok := o.fields[key]; ok {
+		return false
+	}
+
+	if _, ok := o.objects[key]; ok {
+		return false
+	}
+
+	o.fields[key] = field
+	o.keys = append(o.keys, key)
+	return true
+}
+
+// fieldNames returns the names of the fields of the object and of its nested objects, in order of appearance in the template.
+func (o *templateObject) fieldNames() []string {
+	var names []string
+	for _, key := range o.keys {
+		if object, ok := o.objects[key]; ok {
+			names = append(names, object.fieldNames()...)
+			continue
+		}
+
+		names = append(names, o.fields[key].Name)
+	}
+
+	return names
+}
+
+// write writes the object to templateBuffer, with the placeholders of its fields for the template engine.
+func (o *templateObject) write(templateBuffer *bytes.Buffer, cfg Config, templateEngine int) {
+	templateBuffer.WriteString("{ ")
+	for i, key := range o.keys {
+		if i > 0 {
+			templateBuffer.WriteString(",")
+		}
+
+		if object, ok := o.objects[key]; ok {
+			fmt.Fprintf(templateBuffer, `"%s": `, key)
+			object.write(templateBuffer, cfg, templateEngine)
+			continue
+		}
+
+		field := o.fields[key]
 		fieldWrap := fieldValueWrapByType(field)
 		if fieldCfg, ok := cfg.GetField(field.Name); ok {
-			if fieldCfg.Value != nil {
+			// geo points formatted as objects are not strings
+			if fieldCfg.Value != nil || (field.Type == FieldTypeGeoPoint && fieldCfg.GeoFormat == GeoFormatObject) {
 				fieldWrap = ""
 			}
 		}
 
-		fieldTrailer := []byte(",")
-		if i == len(fields)-1 {
-			fieldTrailer = []byte(" }")
+		fieldVariableName := fieldNormalizerRegex.ReplaceAllString(field.Name, "") + "Var"
+		var fieldTemplate string
+		if templateEngine == textTemplateEngine {
+			if field.Type == FieldTypeDate {
+				fieldTemplate = fmt.Sprintf(`{{ $%s := generate "%s" }}"%s": %s{{$%s.Format "2006-01-02T15:04:05.999999Z07:00"}}%s`, fieldVariableName, field.Name, key, fieldWrap, fieldVariableName, fieldWrap)
+			} else {
+				fieldTemplate = fmt.Sprintf(`"%s": %s{{generate "%s"}}%s`, key, fieldWrap, field.Name, fieldWrap)
+			}
+		} else if templateEngine == customTemplateEngine {
+			fieldTemplate = fmt.Sprintf(`"%s": %s{{.%s}}%s`, key, fieldWrap, field.Name, fieldWrap)
+		}
+
+		templateBuffer.WriteString(fieldTemplate)
+	}
+
+	templateBuffer.WriteString(" }")
+}
+
+// documentFields returns the fields holding the values of the documents: the multi-fields, indexing the value of
+// another field, are dropped, as well as the fields nested under a field with a value, like message.text under message.
+func documentFields(fields Fields) Fields {
+	values := make(map[string]struct{}, len(fields))
+	for _, field := range fields {
+		if !isObjectKeysField(field) {
+			values[field.Name] = struct{}{}
 		}
+	}
 
-		if strings.HasSuffix(field.Name, ".*") || field.Type == FieldTypeObject || field.Type == FieldTypeNested || field.Type == FieldTypeFlattened {
-			// This is a special case.  We are randomly generating keys on the fly
-			// Will set the json field name as "field.Name.N"
-			N := 5
-			for ii := 0; ii < N; ii++ {
-				// Fire or skip
-				if rand.Int()%2 == 0 {
-					continue
-				}
-
-				if string(fieldTrailer) == "}" && ii < N-1 {
-					fieldTrailer = []byte(",")
-				}
-
-				var try int
-				const maxTries = 10
-				rNoun := randomdata.Noun()
-				_, ok := dupes[rNoun]
-				for ; ok && try < maxTries; try++ {
-					rNoun = randomdata.Noun()
-					_, ok = dupes[rNoun]
-				}
-
-				// If all else fails, use a shortuuid.
-				// Try to avoid this as it is alloc expensive
-				if try >= maxTries {
-					rNoun = shortuuid.New()
-				}
-
-				dupes[rNoun] = struct{}{}
-				var fieldTemplate string
-
-				fieldNameRoot := replacer.Replace(field.Name)
-				fieldVariableName := fieldNormalizerRegex.ReplaceAllString(fmt.Sprintf("%s%s", fieldNameRoot, rNoun), "")
-				fieldVariableName += "Var"
-				if field.Type == FieldTypeDate {
-					if templateEngine == textTemplateEngine {
-						fieldTemplate = fmt.Sprintf(`{{ $%s := generate "%s.%s" }}"%s.%s": %s{{$%s.Format "2006-01-02T15:04:05.999999Z07:00"}}%s%s`, fieldVariableName, fieldNameRoot, rNoun, fieldNameRoot, rNoun, fieldWrap, fieldVariableName, fieldWrap, fieldTrailer)
-					} else if templateEngine == customTemplateEngine {
-						fieldTemplate = fmt.Sprintf(`"%s.%s": %s{{.%s.%s}}%s%s`, fieldNameRoot, rNoun, fieldWrap, fieldNameRoot, rNoun, fieldWrap, fieldTrailer)
-					}
-				} else {
-					if templateEngine == textTemplateEngine {
-						fieldTemplate = fmt.Sprintf(`"%s.%s": %s{{generate "%s.%s"}}%s%s`, fieldNameRoot, rNoun, fieldWrap, fieldNameRoot, rNoun, fieldWrap, fieldTrailer)
-					} else if templateEngine == customTemplateEngine {
-						fieldTemplate = fmt.Sprintf(`"%s.%s": %s{{.%s.%s}}%s%s`, fieldNameRoot, rNoun, fieldWrap, fieldNameRoot, rNoun, fieldWrap, fieldTrailer)
-					}
-				}
-
-				originalFieldName := field.Name
-				field.Name = fieldNameRoot + "." + rNoun
-				objectKeysField = append(objectKeysField, field)
-				field.Name = originalFieldName
-
-				templateBuffer.WriteString(fieldTemplate)
+	documentFields := make(Fields, 0, len(fields))
+	for _, field := range fields {
+		if !hasValueParent(field.Name, values) {
+			documentFields = append(documentFields, field)
+		}
+	}
+
+	return documentFields
+}
+
+// hasValueParent tells whether a parent of the dotted fieldName is in values.
+func hasValueParent(fieldName string, values map[string]struct{}) bool {
+	for i := strings.LastIndexByte(fieldName, '.'); i > 0; i = strings.LastIndexByte(fieldName[:i], '.') {
+		if _, ok := values[fieldName[:i]]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isObjectKeysField tells whether the keys of the values of field are generated on the fly.
+func isObjectKeysField(field Field) bool {
+	return strings.HasSuffix(field.Name, ".*") || field.Type == FieldTypeObject || field.Type == FieldTypeNested || field.Type == FieldTypeFlattened
+}
+
+// generateTemplateFromField generates the template of the documents of fields: the dotted names are nested objects,
+// unless the rules, the expressions or the copies of the fields require an order the objects cannot follow.
+func generateTemplateFromField(cfg Config, fields Fields, templateEngine int) ([]byte, []Field) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	fields = documentFields(fields)
+	for _, nested := range []bool{true, false} {
+		root, objectKeysField := templateObjectFromFields(fields, nested)
+		if nested && checkRulesOrder(nil, cfg, root.fieldNames()) != nil {
+			continue
+		}
+
+		templateBuffer := new(bytes.Buffer)
+		root.write(templateBuffer, cfg, templateEngine)
+		return templateBuffer.Bytes(), objectKeysField
+	}
+
+	return nil, nil
+}
+
+// templateObjectFromFields returns the root object of the template of fields, and the fields of the keys generated
+// on the fly for the object fields.
+func templateObjectFromFields(fields Fields, nested bool) (*templateObject, []Field) {
+	root := newTemplateObject()
+	dupes := make(map[string]struct{})
+	objectKeysField := make([]Field, 0, len(fields))
+	for _, field := range fields {
+		if !isObjectKeysField(field) {
+			root.add(field, nested)
+			continue
+		}
+
+		// This is a special case.  We are randomly generating keys on the fly
+		// Will set the json field name as "field.Name.N"
+		N := 5
+		fired := false
+		for ii := 0; ii < N; ii++ {
+			// Fire or skip, dimensions always fire at least once
+			if rand.Int()%2 == 0 && (!field.Dimension || fired || ii < N-1) {
+				continue
 			}
-		} else {
-			var fieldTemplate string
-			fieldVariableName := fieldNormalizerRegex.ReplaceAllString(field.Name, "")
-			fieldVariableName += "Var"
-			if field.Type == FieldTypeDate {
-				if templateEngine == textTemplateEngine {
-					fieldTemplate = fmt.Sprintf(`{{ $%s := generate "%s" }}"%s": %s{{$%s.Format "2006-01-02T15:04:05.999999Z07:00"}}%s%s`, fieldVariableName, field.Name, field.Name, fieldWrap, fieldVariableName, fieldWrap, fieldTrailer)
-				} else if templateEngine == customTemplateEngine {
-					fieldTemplate = fmt.Sprintf(`"%s": %s{{.%s}}%s%s`, field.Name, fieldWrap, field.Name, fieldWrap, fieldTrailer)
-				}
-			} else {
-				if templateEngine == textTemplateEngine {
-					fieldTemplate = fmt.Sprintf(`"%s": %s{{generate "%s"}}%s%s`, field.Name, fieldWrap, field.Name, fieldWrap, fieldTrailer)
-				} else if templateEngine == customTemplateEngine {
-					fieldTemplate = fmt.Sprintf(`"%s": %s{{.%s}}%s%s`, field.Name, fieldWrap, field.Name, fieldWrap, fieldTrailer)
-				}
+
+			fired = true
+
+			var try int
+			const maxTries = 10
+			rNoun := randomdata.Noun()
+			_, ok := dupes[rNoun]
+			for ; ok && try < maxTries; try++ {
+				rNoun = randomdata.Noun()
+				_, ok = dupes[rNoun]
+			}
+
+			// If all else fails, use a shortuuid.
+			// Try to avoid this as it is alloc expensive
+			if try >= maxTries {
+				rNoun = shortuuid.New()
 			}
 
-			templateBuffer.WriteString(fieldTemplate)
+			dupes[rNoun] = struct{}{}
+
+			objectKeyField := field
+			objectKeyField.Name = replacer.Replace(field.Name) + "." + rNoun
+			if root.add(objectKeyField, nested) {
+				objectKeysField = append(objectKeysField, objectKeyField)
+			}
 		}
 	}
 
-	return templateBuffer.Bytes(), objectKeysField
+	return root, objectKeysField
 }
 
+// NewGenerator returns a generator of whole documents of the fields, without a template: the dotted names of the
+// fields are nested objects, the multi-fields are left to the mappings, and @timestamp is added if not defined.
 func NewGenerator(cfg Config, flds Fields) (Generator, error) {
+	cfg, flds = RenameFields(cfg, flds)
+	flds = sortFieldsByRules(cfg, withTimestamp(flds))
 	template, objectKeysField := generateCustomTemplateFromField(cfg, flds)
 	flds = append(flds, objectKeysField...)
 
 	return NewGeneratorWithCustomTemplate(template, cfg, flds)
 }
+
+// withTimestamp returns flds with a @timestamp date field first, if not defined.
+func withTimestamp(flds Fields) Fields {
+	for _, field := range flds {
+		if field.Name == "@timestamp" {
+			return flds
+		}
+	}
+
+	return append(Fields{{Name: "@timestamp", Type: FieldTypeDate}}, flds...)
+}
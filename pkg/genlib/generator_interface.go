@@ -14,6 +14,7 @@ import (
 	"math"
 	"math/rand"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,6 +27,7 @@ type (
 	Field       = fields.Field
 	Config      = config.Config
 	ConfigField = config.ConfigField
+	Rule        = config.Rule
 )
 
 const (
@@ -50,6 +52,38 @@ const (
 	FieldTypeTimeLayout = "2006-01-02T15:04:05.999999Z07:00"
 )
 
+// emitterVersions are the versions of the emitters of the values of each field type: the version of a type is bumped
+// by any change generating different values for the same seed and config.
+var emitterVersions = map[string]int{
+	FieldTypeBool:            1,
+	FieldTypeKeyword:         1,
+	FieldTypeConstantKeyword: 1,
+	FieldTypeDate:            1,
+	FieldTypeIP:              1,
+	FieldTypeDouble:          1,
+	FieldTypeFloat:           1,
+	FieldTypeHalfFloat:       1,
+	FieldTypeScaledFloat:     1,
+	FieldTypeInteger:         1,
+	FieldTypeLong:            1,
+	FieldTypeUnsignedLong:    1,
+	FieldTypeObject:          1,
+	FieldTypeNested:          1,
+	FieldTypeFlattened:       1,
+	FieldTypeGeoPoint:        1,
+}
+
+// EmitterVersions returns the versions of the emitters of the values of each field type, by type: a corpus is
+// reproduced from the same seed and config only by emitters at the same versions.
+func EmitterVersions() map[string]int {
+	versions := make(map[string]int, len(emitterVersions))
+	for fieldType, version := range emitterVersions {
+		versions[fieldType] = version
+	}
+
+	return versions
+}
+
 var (
 	replacer             = strings.NewReplacer(".*", "")
 	fieldNormalizerRegex = regexp.MustCompile("[^a-zA-Z0-9]")
@@ -65,6 +99,10 @@ type emitFNotReturn func(state *GenState, buf *bytes.Buffer) error
 type Generator interface {
 	Emit(state *GenState, buf *bytes.Buffer) error
 	Close() error
+	// Fields returns the fields referenced by the template, in order of appearance, with the config in effect for them
+	Fields() []ReferencedField
+	// Template returns the template the generator has been compiled from
+	Template() []byte
 }
 
 type GenState struct {
@@ -76,11 +114,62 @@ type GenState struct {
 
 	// previous value cache; necessary for fuzziness, cardinality, etc.
 	prevCache map[string]interface{}
+
+	// timestamp of the event being generated; when zero date fields are
+	// generated in the hour before the reference time
+	eventTime time.Time
+
+	// reference time of the date fields; time.Now() when zero
+	referenceTime time.Time
+
+	// an omitted JSON member left a dangling separator to be trimmed
+	trimSeparator bool
+
+	// values of the fields referenced by rules conditions, expressions and copies in the event being emitted
+	eventValues map[string]string
+
+	// values of the fields referenced by rules conditions in the event being emitted, as generated, before escaping
+	conditionValues map[string]string
+
+	// values of the fields referenced by the expressions and the templates in the previous event emitted, see
+	// previousPrefix
+	previousValues map[string]string
+
+	// values returned for the fields referenced by copies in the event being emitted, with their own type
+	eventReturnValues map[string]interface{}
+
+	// values returned for the fields referenced by the templates in the previous event emitted, with their own type
+	previousReturns map[string]interface{}
+
+	// values of the fields in the event being emitted, reused by their next references in the template
+	memoValues map[string]interface{}
+
+	// indexes of the entities drawn for the event being emitted
+	entities map[string]int
+
+	// count of events to emit, 0 if unknown
+	events uint64
+
+	// generated string values are not JSON escaped
+	rawValues bool
+
+	// fields tracing of the event being emitted
+	tracing          bool
+	traces           []FieldTrace
+	traceAnnotations map[string]FieldTrace
 }
 
 func NewGenState() *GenState {
 	return &GenState{
-		prevCache: make(map[string]interface{}),
+		prevCache:         make(map[string]interface{}),
+		eventValues:       make(map[string]string),
+		conditionValues:   make(map[string]string),
+		previousValues:    make(map[string]string),
+		eventReturnValues: make(map[string]interface{}),
+		previousReturns:   make(map[string]interface{}),
+		memoValues:        make(map[string]interface{}),
+		entities:          make(map[string]int),
+		traceAnnotations:  make(map[string]FieldTrace),
 		pool: sync.Pool{
 			New: func() any {
 				return new(bytes.Buffer)
@@ -93,6 +182,33 @@ func (s *GenState) Inc() {
 	s.counter += 1
 }
 
+// SetEventTime sets the timestamp used by all date fields of the events emitted afterwards,
+// so that they are coordinated within the same event. Passing the zero time restores the default behaviour.
+func (s *GenState) SetEventTime(t time.Time) {
+	s.eventTime = t
+}
+
+// SetReferenceTime sets the time the date fields are generated in the hour before, when no event time is set,
+// instead of the current time, so that the same seed generates the same dates.
+// Passing the zero time restores the default behaviour.
+func (s *GenState) SetReferenceTime(t time.Time) {
+	s.referenceTime = t
+}
+
+// nearTime returns the event time if set, otherwise a random time in the hour before the reference time
+func (s *GenState) nearTime() time.Time {
+	if !s.eventTime.IsZero() {
+		return s.eventTime
+	}
+
+	offset := time.Duration(rand.Intn(FieldTypeTimeRange)*-1) * time.Second
+	if !s.referenceTime.IsZero() {
+		return s.referenceTime.Add(offset)
+	}
+
+	return time.Now().Add(offset)
+}
+
 func bindField(cfg Config, field Field, fieldMapWithReturn map[string]EmitF, fieldMap map[string]emitFNotReturn, templateFieldMap map[string][]byte, withReturn bool) error {
 
 	// Check for hardcoded field value
@@ -114,7 +230,33 @@ func bindField(cfg Config, field Field, fieldMapWithReturn map[string]EmitF, fie
 		}
 	}
 
-	if fieldCfg.Cardinality > 0 {
+	if len(fieldCfg.CopyFrom) > 0 {
+		if withReturn {
+			return bindCopyWithReturn(fieldCfg, field, fieldMapWithReturn)
+		} else {
+			return bindCopy(templateFieldMap[field.Name], fieldCfg, field, fieldMap)
+		}
+	}
+
+	if len(fieldCfg.Expression) > 0 {
+		if withReturn {
+			return bindDerivedWithReturn(fieldCfg, field, fieldMapWithReturn)
+		} else {
+			return bindDerived(templateFieldMap[field.Name], fieldCfg, field, fieldMap)
+		}
+	}
+
+	// the object fields are not entity attributes, their keys are
+	if len(fieldCfg.Entity) > 0 && !strings.HasSuffix(field.Name, ".*") {
+		if withReturn {
+			return bindEntityWithReturn(cfg, field, fieldMapWithReturn)
+		} else {
+			return bindEntity(templateFieldMap[field.Name], cfg, field, fieldMap, templateFieldMap)
+		}
+	}
+
+	// the generator of ip fields applies the cardinality per subnet
+	if fieldCfg.Cardinality.Values() > 0 && !appliesCardinalityPerCIDR(fieldCfg, field) {
 		if withReturn {
 			return bindCardinalityWithReturn(cfg, field, fieldMapWithReturn)
 		} else {
@@ -129,6 +271,80 @@ func bindField(cfg Config, field Field, fieldMapWithReturn map[string]EmitF, fie
 	}
 }
 
+const (
+	sparseValue = iota
+	sparseNull
+	sparseOmit
+)
+
+func isSparse(fieldCfg ConfigField) bool {
+	return fieldCfg.NullPercentage > 0 || fieldCfg.OmitPercentage > 0
+}
+
+// drawSparse decides if the value of a field is generated, null or omitted, according to its config
+func drawSparse(fieldCfg ConfigField) int {
+	if !isSparse(fieldCfg) {
+		return sparseValue
+	}
+
+	r := rand.Intn(100)
+	switch {
+	case r < fieldCfg.NullPercentage:
+		return sparseNull
+	case r < fieldCfg.NullPercentage+fieldCfg.OmitPercentage:
+		return sparseOmit
+	default:
+		return sparseValue
+	}
+}
+
+// bindSparseWithReturn wraps the bound function so that it returns nil for null and omitted values:
+// the template is responsible to render them.
+func bindSparseWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]EmitF) {
+	boundF := fieldMap[field.Name]
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
+		if sparse := drawSparse(fieldCfg); sparse != sparseValue {
+			state.traceSparse(field.Name, sparse)
+			return nil, nil
+		}
+
+		return boundF(state, buf)
+	}
+}
+
+// newEnumDraw returns the function drawing the index of a value of the enum of fieldCfg, evenly or according to its
+// weights, meeting its quotas if any.
+func newEnumDraw(fieldCfg ConfigField) func(state *GenState) int {
+	if len(fieldCfg.Quotas) > 0 {
+		return newQuotaDraw(fieldCfg)
+	}
+
+	draw := newWeightedDraw(len(fieldCfg.Enum), fieldCfg.Weights)
+	return func(*GenState) int {
+		return draw()
+	}
+}
+
+// newWeightedDraw returns the function drawing an index lower than n, evenly or according to weights, one for each index.
+func newWeightedDraw(n int, weights []int) func() int {
+	if len(weights) == 0 {
+		return func() int {
+			return rand.Intn(n)
+		}
+	}
+
+	cumulative := make([]int, 0, len(weights))
+	var total int
+	for _, weight := range weights {
+		total += weight
+		cumulative = append(cumulative, total)
+	}
+
+	return func() int {
+		return sort.SearchInts(cumulative, rand.Intn(total)+1)
+	}
+}
+
 // Check for dupes O(n)
 func isDupeByteSlice(va []bytes.Buffer, dst []byte) bool {
 	var dupe bool
@@ -157,11 +373,19 @@ func bindByType(cfg Config, field Field, fieldMap map[string]emitFNotReturn, tem
 
 	fieldCfg, _ := cfg.GetField(field.Name)
 
+	if emitter, ok := customEmitter(fieldCfg, field); ok {
+		return bindCustomEmitter(templateFieldMap[field.Name], emitter, field, fieldMap)
+	}
+
+	if usesRealisticGenerator(fieldCfg, field) {
+		return bindRealistic(templateFieldMap[field.Name], fieldCfg, field, fieldMap)
+	}
+
 	switch field.Type {
 	case FieldTypeDate:
 		err = bindNearTime(templateFieldMap[field.Name], field, fieldMap)
 	case FieldTypeIP:
-		err = bindIP(templateFieldMap[field.Name], field, fieldMap)
+		err = bindIP(templateFieldMap[field.Name], fieldCfg, field, fieldMap)
 	case FieldTypeDouble, FieldTypeFloat, FieldTypeHalfFloat, FieldTypeScaledFloat:
 		err = bindDouble(templateFieldMap[field.Name], fieldCfg, field, fieldMap)
 	case FieldTypeInteger, FieldTypeLong, FieldTypeUnsignedLong: // TODO: generate > 63 bit values for unsigned_long
@@ -175,7 +399,7 @@ func bindByType(cfg Config, field Field, fieldMap map[string]emitFNotReturn, tem
 	case FieldTypeObject, FieldTypeNested, FieldTypeFlattened:
 		err = bindObject(cfg, fieldCfg, field, fieldMap, templateFieldMap)
 	case FieldTypeGeoPoint:
-		err = bindGeoPoint(templateFieldMap[field.Name], field, fieldMap)
+		err = bindGeoPoint(templateFieldMap[field.Name], fieldCfg, field, fieldMap)
 	default:
 		err = bindWordN(templateFieldMap[field.Name], field, 25, fieldMap)
 	}
@@ -187,11 +411,19 @@ func bindByTypeWithReturn(cfg Config, field Field, fieldMap map[string]EmitF) (e
 
 	fieldCfg, _ := cfg.GetField(field.Name)
 
+	if emitter, ok := customEmitter(fieldCfg, field); ok {
+		return bindCustomEmitterWithReturn(emitter, field, fieldMap)
+	}
+
+	if usesRealisticGenerator(fieldCfg, field) {
+		return bindRealisticWithReturn(fieldCfg, field, fieldMap)
+	}
+
 	switch field.Type {
 	case FieldTypeDate:
 		err = bindNearTimeWithReturn(field, fieldMap)
 	case FieldTypeIP:
-		err = bindIPWithReturn(field, fieldMap)
+		err = bindIPWithReturn(fieldCfg, field, fieldMap)
 	case FieldTypeDouble, FieldTypeFloat, FieldTypeHalfFloat, FieldTypeScaledFloat:
 		err = bindDoubleWithReturn(fieldCfg, field, fieldMap)
 	case FieldTypeInteger, FieldTypeLong, FieldTypeUnsignedLong: // TODO: generate > 63 bit values for unsigned_long
@@ -205,7 +437,7 @@ func bindByTypeWithReturn(cfg Config, field Field, fieldMap map[string]EmitF) (e
 	case FieldTypeObject, FieldTypeNested, FieldTypeFlattened:
 		err = bindObjectWithReturn(cfg, fieldCfg, field, fieldMap)
 	case FieldTypeGeoPoint:
-		err = bindGeoPointWithReturn(field, fieldMap)
+		err = bindGeoPointWithReturn(fieldCfg, field, fieldMap)
 	default:
 		err = bindWordNWithReturn(field, 25, fieldMap)
 	}
@@ -214,11 +446,22 @@ func bindByTypeWithReturn(cfg Config, field Field, fieldMap map[string]EmitF) (e
 }
 
 func makeIntFunc(fieldCfg ConfigField, field Field) func() int {
-	maxValue := fieldCfg.Range
+	maxValue := fieldCfg.Range.Magnitude
+	unit := fieldUnit(fieldCfg, field)
 
 	var dummyFunc func() int
 
 	switch {
+	case fieldCfg.Range.Bounded:
+		// the integer values within the bounds, both included
+		min, max := int(math.Ceil(fieldCfg.Range.Min)), int(math.Floor(fieldCfg.Range.Max))
+		if max < min {
+			max = min
+		}
+
+		dummyFunc = func() int { return min + rand.Intn(max-min+1) }
+	case unit == unitPercent:
+		dummyFunc = func() int { return rand.Intn(maxIntPercent + 1) }
 	case maxValue > 0:
 		dummyFunc = func() int { return rand.Intn(maxValue) }
 	case len(field.Example) == 0:
@@ -231,6 +474,11 @@ func makeIntFunc(fieldCfg ConfigField, field Field) func() int {
 		}
 	}
 
+	if unit == unitDuration {
+		intFunc := dummyFunc
+		dummyFunc = func() int { return intFunc() + 1 }
+	}
+
 	return dummyFunc
 }
 
@@ -305,8 +553,17 @@ func randGeoPoint(buf *bytes.Buffer) error {
 	if long != -180 && long != 180 {
 		longD = rand.Intn(100)
 	}
-	_, err := fmt.Fprintf(buf, "%d.%d,%d.%d", lat, latD, long, longD)
-	return err
+
+	var b [32]byte
+	v := strconv.AppendInt(b[:0], int64(lat), 10)
+	v = append(v, '.')
+	v = strconv.AppendInt(v, int64(latD), 10)
+	v = append(v, ',')
+	v = strconv.AppendInt(v, int64(long), 10)
+	v = append(v, '.')
+	v = strconv.AppendInt(v, int64(longD), 10)
+	buf.Write(v)
+	return nil
 }
 
 func randGeoPointWithReturn() string {
@@ -341,8 +598,10 @@ func bindConstantKeyword(prefix []byte, field Field, fieldMap map[string]emitFNo
 
 func bindKeyword(prefix []byte, fieldCfg ConfigField, field Field, fieldMap map[string]emitFNotReturn) error {
 	if len(fieldCfg.Enum) > 0 {
+		drawEnum := newEnumDraw(fieldCfg)
 		fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
-			idx := rand.Intn(len(fieldCfg.Enum))
+			idx := drawEnum(state)
+			state.traceDraw(field.Name, idx)
 			buf.Write(prefix)
 			buf.WriteString(fieldCfg.Enum[idx])
 			return nil
@@ -418,15 +677,6 @@ func bindBool(prefix []byte, field Field, fieldMap map[string]emitFNotReturn) er
 	return nil
 }
 
-func bindGeoPoint(prefix []byte, field Field, fieldMap map[string]emitFNotReturn) error {
-	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
-		buf.Write(prefix)
-		return randGeoPoint(buf)
-	}
-
-	return nil
-}
-
 func bindWordN(prefix []byte, field Field, n int, fieldMap map[string]emitFNotReturn) error {
 	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
 		buf.Write(prefix)
@@ -439,36 +689,21 @@ func bindWordN(prefix []byte, field Field, n int, fieldMap map[string]emitFNotRe
 
 func bindNearTime(prefix []byte, field Field, fieldMap map[string]emitFNotReturn) error {
 	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
-		offset := time.Duration(rand.Intn(FieldTypeTimeRange)*-1) * time.Second
-		newTime := time.Now().Add(offset)
+		newTime := state.nearTime()
 
 		buf.Write(prefix)
-		buf.WriteString(newTime.Format(FieldTypeTimeLayout))
+		var b [64]byte
+		buf.Write(appendTimestamp(b[:0], newTime))
 		return nil
 	}
 
 	return nil
 }
 
-func bindIP(prefix []byte, field Field, fieldMap map[string]emitFNotReturn) error {
-	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
-		buf.Write(prefix)
-
-		i0 := rand.Intn(255)
-		i1 := rand.Intn(255)
-		i2 := rand.Intn(255)
-		i3 := rand.Intn(255)
-
-		_, err := fmt.Fprintf(buf, "%d.%d.%d.%d", i0, i1, i2, i3)
-		return err
-	}
-
-	return nil
-}
-
 func bindLong(prefix []byte, fieldCfg ConfigField, field Field, fieldMap map[string]emitFNotReturn) error {
 
 	dummyFunc := makeIntFunc(fieldCfg, field)
+	unit := fieldUnit(fieldCfg, field)
 
 	fuzziness := fieldCfg.Fuzziness
 
@@ -491,7 +726,9 @@ func bindLong(prefix []byte, fieldCfg ConfigField, field Field, fieldMap map[str
 			if rand.Int()%2 == 0 {
 				adjustedRatio = 1. + float64(rand.Intn(fuzziness))/100.
 			}
-			dummyInt = int(math.Ceil(float64(previousDummyInt) * adjustedRatio))
+			if fuzzyInt := int(math.Ceil(float64(previousDummyInt) * adjustedRatio)); unit.containsInt(fuzzyInt) && fieldCfg.Range.Contains(float64(fuzzyInt)) {
+				dummyInt = fuzzyInt
+			}
 		}
 		state.prevCache[field.Name] = dummyInt
 		buf.Write(prefix)
@@ -506,34 +743,38 @@ func bindLong(prefix []byte, fieldCfg ConfigField, field Field, fieldMap map[str
 
 func bindDouble(prefix []byte, fieldCfg ConfigField, field Field, fieldMap map[string]emitFNotReturn) error {
 
-	dummyFunc := makeIntFunc(fieldCfg, field)
+	dummyFunc := makeFloatFunc(fieldCfg, field)
+	fieldCfg, round := scaledFloat(fieldCfg, field)
+	unit := fieldUnit(fieldCfg, field)
 
 	fuzziness := fieldCfg.Fuzziness
 
 	if fuzziness <= 0 {
 		fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
-			dummyFloat := float64(dummyFunc()) / rand.Float64()
+			dummyFloat := dummyFunc()
 			buf.Write(prefix)
-			_, err := fmt.Fprintf(buf, "%f", dummyFloat)
-			return err
+			writeFloat(buf, fieldCfg, round(dummyFloat))
+			return nil
 		}
 
 		return nil
 	}
 
 	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
-		dummyFloat := float64(dummyFunc()) / rand.Float64()
+		dummyFloat := dummyFunc()
 		if previousDummyFloat, ok := state.prevCache[field.Name].(float64); ok {
 			adjustedRatio := 1. - float64(rand.Intn(fuzziness))/100.
 			if rand.Int()%2 == 0 {
 				adjustedRatio = 1. + float64(rand.Intn(fuzziness))/100.
 			}
-			dummyFloat = previousDummyFloat * adjustedRatio
+			if fuzzyFloat := previousDummyFloat * adjustedRatio; unit.containsFloat(fuzzyFloat) && fieldCfg.Range.Contains(fuzzyFloat) {
+				dummyFloat = fuzzyFloat
+			}
 		}
 		state.prevCache[field.Name] = dummyFloat
 		buf.Write(prefix)
-		_, err := fmt.Fprintf(buf, "%f", dummyFloat)
-		return err
+		writeFloat(buf, fieldCfg, round(dummyFloat))
+		return nil
 	}
 
 	return nil
@@ -542,7 +783,7 @@ func bindDouble(prefix []byte, fieldCfg ConfigField, field Field, fieldMap map[s
 func bindCardinality(prefix []byte, cfg Config, field Field, fieldMap map[string]emitFNotReturn, templateFieldMap map[string][]byte) error {
 
 	fieldCfg, _ := cfg.GetField(field.Name)
-	cardinality := int(math.Ceil((1000. / float64(fieldCfg.Cardinality))))
+	cardinality := fieldCfg.Cardinality.Values()
 
 	if strings.HasSuffix(field.Name, ".*") {
 		field.Name = replacer.Replace(field.Name)
@@ -555,6 +796,8 @@ func bindCardinality(prefix []byte, cfg Config, field Field, fieldMap map[string
 
 	// We will wrap the function we just generated
 	boundF := fieldMap[field.Name]
+	skewed := newSkewedIndex(fieldCfg.Skew, cardinality)
+	churned := newChurn(fieldCfg.Churn, cardinality)
 
 	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
 		var va []bytes.Buffer
@@ -563,8 +806,16 @@ func bindCardinality(prefix []byte, cfg Config, field Field, fieldMap map[string
 			va = v.([]bytes.Buffer)
 		}
 
+		idx := int(state.counter % uint64(cardinality))
+		// Skewed values are generated up to the one drawn
+		missing := 1
+		if skewed != nil {
+			idx = skewed()
+			missing = idx + 1 - len(va)
+		}
+
 		// Have we rolled over once?  If not, generate a value and cache it.
-		if len(va) < cardinality {
+		for ; missing > 0 && len(va) < cardinality; missing-- {
 
 			// Do college try dupe detection on value;
 			// Allow dupe if no unique value in nTries.
@@ -586,13 +837,19 @@ func bindCardinality(prefix []byte, cfg Config, field Field, fieldMap map[string
 			state.prevCache[field.Name] = va
 		}
 
-		idx := int(state.counter % uint64(cardinality))
-
 		// Safety check; should be a noop
 		if idx >= len(va) {
 			idx = len(va) - 1
 		}
 
+		if churned.renew(state, field.Name, idx) {
+			if err := renewValue(state, va, idx, boundF); err != nil {
+				return err
+			}
+		}
+
+		state.tracePoolEntry(field.Name, idx)
+
 		choice := va[idx]
 		buf.Write(choice.Bytes())
 		return nil
@@ -652,8 +909,10 @@ func bindConstantKeywordWithReturn(field Field, fieldMap map[string]EmitF) error
 
 func bindKeywordWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]EmitF) error {
 	if len(fieldCfg.Enum) > 0 {
+		drawEnum := newEnumDraw(fieldCfg)
 		fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
-			idx := rand.Intn(len(fieldCfg.Enum))
+			idx := drawEnum(state)
+			state.traceDraw(field.Name, idx)
 			return fieldCfg.Enum[idx], nil
 		}
 	} else if len(field.Example) > 0 {
@@ -718,14 +977,6 @@ func bindBoolWithReturn(field Field, fieldMap map[string]EmitF) error {
 	return nil
 }
 
-func bindGeoPointWithReturn(field Field, fieldMap map[string]EmitF) error {
-	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
-		return randGeoPointWithReturn(), nil
-	}
-
-	return nil
-}
-
 func bindWordNWithReturn(field Field, n int, fieldMap map[string]EmitF) error {
 	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
 		return genNounsNWithReturn(rand.Intn(n)), nil
@@ -736,23 +987,7 @@ func bindWordNWithReturn(field Field, n int, fieldMap map[string]EmitF) error {
 
 func bindNearTimeWithReturn(field Field, fieldMap map[string]EmitF) error {
 	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
-		offset := time.Duration(rand.Intn(FieldTypeTimeRange)*-1) * time.Second
-		newTime := time.Now().Add(offset)
-
-		return newTime, nil
-	}
-
-	return nil
-}
-
-func bindIPWithReturn(field Field, fieldMap map[string]EmitF) error {
-	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
-		i0 := rand.Intn(255)
-		i1 := rand.Intn(255)
-		i2 := rand.Intn(255)
-		i3 := rand.Intn(255)
-
-		return fmt.Sprintf("%d.%d.%d.%d", i0, i1, i2, i3), nil
+		return state.nearTime(), nil
 	}
 
 	return nil
@@ -761,6 +996,7 @@ func bindIPWithReturn(field Field, fieldMap map[string]EmitF) error {
 func bindLongWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]EmitF) error {
 
 	dummyFunc := makeIntFunc(fieldCfg, field)
+	unit := fieldUnit(fieldCfg, field)
 
 	fuzziness := fieldCfg.Fuzziness
 
@@ -779,7 +1015,9 @@ func bindLongWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]E
 			if rand.Int()%2 == 0 {
 				adjustedRatio = 1. + float64(rand.Intn(fuzziness))/100.
 			}
-			dummyInt = int(math.Ceil(float64(previousDummyInt) * adjustedRatio))
+			if fuzzyInt := int(math.Ceil(float64(previousDummyInt) * adjustedRatio)); unit.containsInt(fuzzyInt) && fieldCfg.Range.Contains(float64(fuzzyInt)) {
+				dummyInt = fuzzyInt
+			}
 		}
 		state.prevCache[field.Name] = dummyInt
 		return dummyInt, nil
@@ -790,29 +1028,33 @@ func bindLongWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]E
 
 func bindDoubleWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]EmitF) error {
 
-	dummyFunc := makeIntFunc(fieldCfg, field)
+	dummyFunc := makeFloatFunc(fieldCfg, field)
+	fieldCfg, round := scaledFloat(fieldCfg, field)
+	unit := fieldUnit(fieldCfg, field)
 
 	fuzziness := fieldCfg.Fuzziness
 
 	if fuzziness <= 0 {
 		fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
-			return float64(dummyFunc()) / rand.Float64(), nil
+			return returnFloat(fieldCfg, round(dummyFunc())), nil
 		}
 
 		return nil
 	}
 
 	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
-		dummyFloat := float64(dummyFunc()) / rand.Float64()
+		dummyFloat := dummyFunc()
 		if previousDummyFloat, ok := state.prevCache[field.Name].(float64); ok {
 			adjustedRatio := 1. - float64(rand.Intn(fuzziness))/100.
 			if rand.Int()%2 == 0 {
 				adjustedRatio = 1. + float64(rand.Intn(fuzziness))/100.
 			}
-			dummyFloat = previousDummyFloat * adjustedRatio
+			if fuzzyFloat := previousDummyFloat * adjustedRatio; unit.containsFloat(fuzzyFloat) && fieldCfg.Range.Contains(fuzzyFloat) {
+				dummyFloat = fuzzyFloat
+			}
 		}
 		state.prevCache[field.Name] = dummyFloat
-		return dummyFloat, nil
+		return returnFloat(fieldCfg, round(dummyFloat)), nil
 	}
 
 	return nil
@@ -821,7 +1063,7 @@ func bindDoubleWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string
 func bindCardinalityWithReturn(cfg Config, field Field, fieldMap map[string]EmitF) error {
 
 	fieldCfg, _ := cfg.GetField(field.Name)
-	cardinality := int(math.Ceil((1000. / float64(fieldCfg.Cardinality))))
+	cardinality := fieldCfg.Cardinality.Values()
 
 	if strings.HasSuffix(field.Name, ".*") {
 		field.Name = replacer.Replace(field.Name)
@@ -834,6 +1076,8 @@ func bindCardinalityWithReturn(cfg Config, field Field, fieldMap map[string]Emit
 
 	// We will wrap the function we just generated
 	boundFWithReturn := fieldMap[field.Name]
+	skewed := newSkewedIndex(fieldCfg.Skew, cardinality)
+	churned := newChurn(fieldCfg.Churn, cardinality)
 
 	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
 		var va []interface{}
@@ -842,9 +1086,17 @@ func bindCardinalityWithReturn(cfg Config, field Field, fieldMap map[string]Emit
 			va = v.([]interface{})
 		}
 
+		idx := int(state.counter % uint64(cardinality))
+		// Skewed values are generated up to the one drawn
+		missing := 1
+		if skewed != nil {
+			idx = skewed()
+			missing = idx + 1 - len(va)
+		}
+
 		var value interface{}
 		// Have we rolled over once?  If not, generate a value and cache it.
-		if len(va) < cardinality {
+		for ; missing > 0 && len(va) < cardinality; missing-- {
 
 			// Do college try dupe detection on value;
 			// Allow dupe if no unique value in nTries.
@@ -867,13 +1119,19 @@ func bindCardinalityWithReturn(cfg Config, field Field, fieldMap map[string]Emit
 			state.prevCache[field.Name] = va
 		}
 
-		idx := int(state.counter % uint64(cardinality))
-
 		// Safety check; should be a noop
 		if idx >= len(va) {
 			idx = len(va) - 1
 		}
 
+		if churned.renew(state, field.Name, idx) {
+			if err := renewValueWithReturn(state, va, idx, boundFWithReturn); err != nil {
+				return nil, err
+			}
+		}
+
+		state.tracePoolEntry(field.Name, idx)
+
 		choice := va[idx]
 
 		return choice, nil
@@ -3,12 +3,97 @@ package genlib
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
 	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
 )
 
+func Test_NewGenerator(t *testing.T) {
+	flds := Fields{
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "message", Type: FieldTypeKeyword},
+		{Name: "message.text", Type: "match_only_text"},
+		{Name: "host.ip", Type: FieldTypeIP},
+		{Name: "event.duration", Type: FieldTypeLong},
+	}
+
+	g, err := NewGenerator(Config{}, flds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := g.Emit(NewGenState(), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// the dotted names are nested objects, the multi-fields are left out and @timestamp is added
+	var event struct {
+		Timestamp string                 `json:"@timestamp"`
+		Host      map[string]interface{} `json:"host"`
+		Message   string                 `json:"message"`
+		Event     map[string]interface{} `json:"event"`
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatalf("invalid event %s: %v", buf.String(), err)
+	}
+
+	if len(event.Timestamp) == 0 || len(event.Host) != 2 || len(event.Event) != 1 || len(event.Message) == 0 {
+		t.Errorf("unexpected event %s", buf.String())
+	}
+
+	if !bytes.HasPrefix(buf.Bytes(), []byte(`{ "@timestamp": `)) || bytes.Contains(buf.Bytes(), []byte("text")) {
+		t.Errorf("unexpected event %s", buf.String())
+	}
+
+	// the fields are flat when the objects cannot keep the rules conditions before the fields they belong to
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: a.x\n  rules:\n  - when:\n      field: b.y\n      equals: v\n    then:\n      enum: [w]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err = NewGenerator(cfg, Fields{{Name: "@timestamp", Type: FieldTypeDate}, {Name: "a.z", Type: FieldTypeLong}, {Name: "b.y", Type: FieldTypeKeyword}, {Name: "a.x", Type: FieldTypeKeyword}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	if err := g.Emit(NewGenState(), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m := unmarshalJSONT[interface{}](t, buf.Bytes())
+	for _, name := range []string{"@timestamp", "a.z", "b.y", "a.x"} {
+		if _, ok := m[name]; !ok {
+			t.Errorf("expected flat field %s in %s", name, buf.String())
+		}
+	}
+
+	// the renamed fields are written under their new name, and the rules reference them by it
+	cfg, err = config.LoadConfigFromYaml([]byte("- name: b.y\n  rename: vendor_y\n  enum: [v]\n- name: a.x\n  rules:\n  - when:\n      field: b.y\n      equals: v\n    then:\n      enum: [w]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err = NewGenerator(cfg, Fields{{Name: "b.y", Type: FieldTypeKeyword}, {Name: "a.x", Type: FieldTypeKeyword}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	if err := g.Emit(NewGenState(), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m = unmarshalJSONT[interface{}](t, buf.Bytes())
+	if a, ok := m["a"].(map[string]interface{}); m["vendor_y"] != "v" || !ok || a["x"] != "w" {
+		t.Errorf("unexpected event %s", buf.String())
+	}
+}
+
 func Benchmark_GeneratorCustomTemplateJSONContent(b *testing.B) {
 	ctx := context.Background()
 	flds, err := fields.LoadFields(ctx, fields.ProductionBaseURL, "endpoint", "process", "8.2.0")
@@ -6,77 +6,269 @@ package genlib
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"regexp"
 )
 
-var trailingTemplate []byte
+// jsonMemberRegex splits the template chunk preceding the value of a JSON object member in:
+// leading content, members separator, member name and opening quote of string values
+var jsonMemberRegex = regexp.MustCompile(`(?s)^(.*?)(\s*,)?(\s*"[^"]*"\s*:\s*)("?)$`)
 
-// GeneratorWithCustomTemplate is resolved at construction to a slice of emit functions
+// GeneratorWithCustomTemplate is resolved at construction to a slice of emit functions, each one writing the template
+// chunk preceding its field and the value of the field: once the pools of the fields with cardinality are filled,
+// emitting an event of the common field types allocates nothing.
 type GeneratorWithCustomTemplate struct {
 	emitFuncs []emitFNotReturn
+	// tracedEmitFuncs are used instead of emitFuncs when tracing is enabled
+	tracedEmitFuncs  []emitFNotReturn
+	trailingTemplate []byte
+	// orderedFields are the fields of the emit functions, to locate their errors in the template
+	orderedFields []string
+
+	template []byte
+	fields   []ReferencedField
 }
 
-func parseCustomTemplate(template []byte) ([]string, map[string][]byte, []byte) {
-	if len(template) == 0 {
-		return nil, nil, nil
+// jsonMember is the template chunk preceding the value of a JSON object member
+type jsonMember struct {
+	leading   []byte
+	separator []byte
+	name      []byte
+	quoted    bool
+}
+
+func parseJSONMember(prefix []byte) (jsonMember, bool) {
+	match := jsonMemberRegex.FindSubmatch(prefix)
+	if match == nil {
+		return jsonMember{}, false
 	}
 
-	tokenizer := regexp.MustCompile(`([^{]*)({{\.[^}]+}})*`)
-	allIndexes := tokenizer.FindAllSubmatchIndex(template, -1)
+	return jsonMember{
+		leading:   match[1],
+		separator: match[2],
+		name:      match[3],
+		quoted:    len(match[4]) > 0,
+	}, true
+}
 
-	orderedFields := make([]string, 0, len(allIndexes))
-	templateFieldsMap := make(map[string][]byte, len(allIndexes))
+// makeSparseStub wraps the bound function of a field with null or omitted values, or with arrays of values: the stub
+// writes the JSON member itself, so that it can replace the value with null or drop the member altogether.
+func makeSparseStub(fieldName string, member jsonMember, fieldCfg ConfigField, boundF emitFNotReturn) emitFNotReturn {
+	nullPrefix := append(append(append([]byte{}, member.leading...), member.separator...), member.name...)
+	prefix := nullPrefix
+	if member.quoted {
+		prefix = append(append([]byte{}, nullPrefix...), '"')
+	}
 
-	var fieldPrefixBuffer []byte
-	var fieldPrefixPreviousN int
-	var trimTrailingTemplateN int
+	return func(state *GenState, buf *bytes.Buffer) error {
+		sparse := drawSparse(fieldCfg)
+		state.traceSparse(fieldName, sparse)
+		switch sparse {
+		case sparseNull:
+			buf.Write(nullPrefix)
+			buf.WriteString("null")
+			return nil
+		case sparseOmit:
+			buf.Write(member.leading)
+			// without a separator this is the first member: the separator of the next one must go
+			if len(member.separator) == 0 {
+				state.trimSeparator = true
+			}
+			return nil
+		}
 
-	for i, loc := range allIndexes {
-		var fieldName []byte
-		var fieldPrefix []byte
+		buf.Write(prefix)
+		if err := boundF(state, buf); err != nil {
+			return err
+		}
+
+		if member.quoted {
+			buf.WriteByte('"')
+		}
+
+		return nil
+	}
+}
+
+// trimSeparator removes the JSON members separator written in buf after offset, if any.
+// It returns false if nothing but whitespaces has been written after offset.
+func trimSeparator(buf *bytes.Buffer, offset int) bool {
+	b := buf.Bytes()
+	for i := offset; i < len(b); i++ {
+		switch b[i] {
+		case ' ', '\t', '\n', '\r':
+			continue
+		case ',':
+			copy(b[i:], b[i+1:])
+			buf.Truncate(len(b) - 1)
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// prepareMembers finds the JSON members of the fields with null or omitted values, and of the fields with arrays of values.
+// Since their stub writes the whole member, their prefix is dropped from the template fields map,
+// as well as the closing quote of string values from the chunk following them.
+func prepareMembers(template []byte, cfg Config, orderedFields []string, arrayFields map[string]struct{}, templateFieldsMap map[string][]byte, trailingTemplate []byte) (map[string]jsonMember, []byte, error) {
+	members := make(map[string]jsonMember)
+	referenceNames := referenceFieldNames(orderedFields)
+	for i, fieldName := range orderedFields {
+		fieldCfg, _ := cfg.GetField(fieldName)
+		_, array := arrayFields[fieldName]
+		if !isSparse(fieldCfg) && !array {
+			continue
+		}
 
-		if loc[4] > -1 && loc[5] > -1 {
-			fieldName = template[loc[4]+3 : loc[5]-2]
+		settings := "null_percentage and omit_percentage"
+		if !isSparse(fieldCfg) {
+			settings = "array_min and array_max"
 		}
 
-		if loc[2] > -1 && loc[3] > -1 {
-			fieldPrefix = template[loc[2]:loc[3]]
+		if _, ok := members[fieldName]; ok {
+			return nil, nil, placeholderError(template, fieldName, 1, fmt.Errorf("%s require the field to be referenced once in the template", settings))
 		}
 
-		if len(fieldName) == 0 {
-			if template[fieldPrefixPreviousN] == byte(123) {
-				fieldPrefixBuffer = append(fieldPrefixBuffer, byte(123))
+		member, ok := parseJSONMember(templateFieldsMap[fieldName])
+		if !ok {
+			return nil, nil, placeholderError(template, fieldName, 0, fmt.Errorf("%s require the field to be the value of a JSON object member in the template", settings))
+		}
+
+		if member.quoted {
+			next := trailingTemplate
+			if i < len(orderedFields)-1 {
+				next = templateFieldsMap[referenceNames[i+1]]
+			}
+
+			if !bytes.HasPrefix(next, []byte(`"`)) {
+				return nil, nil, placeholderError(template, fieldName, 0, errors.New("missing closing quote in the template"))
+			}
+
+			if i < len(orderedFields)-1 {
+				templateFieldsMap[referenceNames[i+1]] = next[1:]
 			} else {
-				if i == len(allIndexes)-1 {
-					fieldPrefixBuffer = template[trimTrailingTemplateN:]
-				} else {
-					fieldPrefixBuffer = append(fieldPrefixBuffer, fieldPrefix...)
-					fieldPrefixBufferIdx := bytes.Index(template[trimTrailingTemplateN:], fieldPrefixBuffer)
-					if fieldPrefixBufferIdx > 0 {
-						trimTrailingTemplateN += fieldPrefixBufferIdx
-					}
-
-				}
+				trailingTemplate = next[1:]
 			}
-		} else {
-			fieldPrefixBuffer = append(fieldPrefixBuffer, fieldPrefix...)
-			trimTrailingTemplateN = loc[5]
-			templateFieldsMap[string(fieldName)] = fieldPrefixBuffer
-			orderedFields = append(orderedFields, string(fieldName))
-			fieldPrefixBuffer = nil
 		}
 
-		fieldPrefixPreviousN = loc[2]
+		members[fieldName] = member
+		templateFieldsMap[fieldName] = nil
 	}
 
-	return orderedFields, templateFieldsMap, fieldPrefixBuffer
+	return members, trailingTemplate, nil
+}
+
+// parseCustomTemplate returns the fields referenced by template in order, the chunks of template preceding them, keyed by
+// the name of each reference (see referenceFieldName), the chunk following the last one, and the functions the value
+// of each reference is piped to, like {{.message | xmlEscape}}, keyed by the name of the reference as well. The
+// aliases set by cfg are resolved to the names of their fields. The repeat blocks of template, see parseRepeatBlocks,
+// are referenced among the fields under their name, their body is not parsed.
+func parseCustomTemplate(template []byte, cfg Config, blocks []repeatBlock) ([]string, map[string][]byte, []byte, map[string][]string) {
+	if len(template) == 0 {
+		return nil, nil, nil, nil
+	}
 
+	orderedFields := make([]string, 0)
+	templateFieldsMap := make(map[string][]byte)
+	referenceCounts := make(map[string]int)
+	pipelines := make(map[string][]string)
+
+	// chunkStart is the start of the chunk preceding the next placeholder, searchStart where to look for it
+	var chunkStart, searchStart int
+	for {
+		start := bytes.Index(template[searchStart:], []byte("{{."))
+		if start >= 0 {
+			start += searchStart
+		}
+
+		if len(blocks) > 0 && (start < 0 || blocks[0].start < start) {
+			block := blocks[0]
+			blocks = blocks[1:]
+			if block.start > chunkStart {
+				templateFieldsMap[block.name] = template[chunkStart:block.start]
+			}
+
+			orderedFields = append(orderedFields, block.name)
+			chunkStart = block.end
+			searchStart = chunkStart
+			continue
+		}
+
+		if start < 0 {
+			break
+		}
+
+		end := bytes.Index(template[start+3:], []byte("}}"))
+		if end < 0 {
+			break
+		}
+
+		end += start + 3
+		fieldName := template[start+3 : end]
+		// not a placeholder, part of the chunk
+		if len(fieldName) == 0 || bytes.IndexByte(fieldName, '}') >= 0 {
+			searchStart = start + 1
+			continue
+		}
+
+		var fieldPrefix []byte
+		if start > chunkStart {
+			fieldPrefix = template[chunkStart:start]
+		}
+
+		name, pipeline := splitPipeline(string(fieldName))
+		name = cfg.Resolve(name)
+		referenceName := referenceFieldName(name, referenceCounts[name])
+		templateFieldsMap[referenceName] = fieldPrefix
+		if len(pipeline) > 0 {
+			pipelines[referenceName] = pipeline
+		}
+
+		referenceCounts[name]++
+		orderedFields = append(orderedFields, name)
+		chunkStart = end + 2
+		searchStart = chunkStart
+	}
+
+	var trailingTemplate []byte
+	if chunkStart < len(template) {
+		trailingTemplate = template[chunkStart:]
+	}
+
+	return orderedFields, templateFieldsMap, trailingTemplate, pipelines
 }
 
 func NewGeneratorWithCustomTemplate(template []byte, cfg Config, fields Fields) (*GeneratorWithCustomTemplate, error) {
+	cfg, fields = RenameFields(cfg, fields)
+
+	blocks, err := parseRepeatBlocks(template)
+	if err != nil {
+		return nil, err
+	}
+
 	// Parse the template and extract relevant information
-	orderedFields, templateFieldsMap, fieldPrefixBuffer := parseCustomTemplate(template)
-	trailingTemplate = fieldPrefixBuffer
+	orderedFields, templateFieldsMap, trailingTemplate, pipelines := parseCustomTemplate(template, cfg, blocks)
+
+	if err := checkRulesOrder(template, cfg, orderedFields); err != nil {
+		return nil, err
+	}
+
+	arrayFields := arrayFieldNames(cfg, fields)
+	members, trailingTemplate, err := prepareMembers(template, cfg, orderedFields, arrayFields, templateFieldsMap, trailingTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPipelines(template, orderedFields, pipelines, members); err != nil {
+		return nil, err
+	}
+
+	if err := checkExpressionOperands(cfg, fields); err != nil {
+		return nil, err
+	}
 
 	// Preprocess the fields, generating appropriate emit functions
 	fieldMap := make(map[string]emitFNotReturn)
@@ -84,21 +276,204 @@ func NewGeneratorWithCustomTemplate(template []byte, cfg Config, fields Fields)
 		if err := bindField(cfg, field, nil, fieldMap, templateFieldsMap, false); err != nil {
 			return nil, err
 		}
+
+		if err := bindRules(cfg, field, fieldMap, templateFieldsMap); err != nil {
+			return nil, err
+		}
+	}
+
+	// the rules check the values as generated, they are recorded before the escape stubs
+	for fieldName := range ruleConditionFields(cfg, orderedFields) {
+		if boundF, ok := fieldMap[fieldName]; ok {
+			fieldMap[fieldName] = makeConditionStub(fieldName, len(templateFieldsMap[fieldName]), boundF)
+		}
+	}
+
+	for _, fieldName := range escapedFieldNames(cfg, fields, uniqueFieldNames(orderedFields)) {
+		if boundF, ok := fieldMap[fieldName]; ok {
+			fieldCfg, _ := cfg.GetField(fieldName)
+			fieldMap[fieldName] = makeEscapeStub(fieldCfg.Escape == EscapeXML, len(templateFieldsMap[fieldName]), boundF)
+		}
+	}
+
+	// the values are recorded before the stubs of the members wrap them with their prefix, or write null instead
+	previous := previousReferences(cfg, fields, uniqueFieldNames(orderedFields))
+	recorded := conditionFields(cfg, orderedFields)
+	for _, fieldName := range previous {
+		recorded[fieldName] = struct{}{}
+	}
+
+	for fieldName := range recorded {
+		if boundF, ok := fieldMap[fieldName]; ok {
+			fieldMap[fieldName] = makeRecordStub(fieldName, len(templateFieldsMap[fieldName]), boundF)
+		}
+	}
+
+	// the array stub quotes the values, the stub of the member must not
+	for fieldName := range arrayFields {
+		member, ok := members[fieldName]
+		if boundF, found := fieldMap[fieldName]; ok && found {
+			fieldCfg, _ := cfg.GetField(fieldName)
+			fieldMap[fieldName] = makeArrayStub(member.quoted, fieldCfg, boundF)
+			member.quoted = false
+			members[fieldName] = member
+		}
+	}
+
+	// the stubs of the members write the values along with the members, the values are traced before
+	for fieldName, member := range members {
+		if boundF, ok := fieldMap[fieldName]; ok {
+			fieldCfg, _ := cfg.GetField(fieldName)
+			fieldMap[fieldName] = makeSparseStub(fieldName, member, fieldCfg, makeTraceValueStub(fieldName, boundF))
+		}
+	}
+
+	// the fields referenced more than once write the same value for all their references, unless they reroll
+	for fieldName := range memoizedFieldNames(cfg, orderedFields) {
+		if boundF, ok := fieldMap[fieldName]; ok {
+			fieldMap[fieldName] = makeMemoStub(fieldName, len(templateFieldsMap[fieldName]), boundF)
+		}
+	}
+
+	// the references to the values of the previous event write them as recorded, escaped already
+	for reference, fieldName := range previous {
+		fieldMap[reference] = makePreviousStub(fieldName, templateFieldsMap[reference])
+	}
+
+	// the fields of the bodies of the repeat blocks are bound on their own, for each repetition to get its own values
+	var repeatedFields []ReferencedField
+	for _, block := range blocks {
+		gen, err := NewGeneratorWithCustomTemplate(block.body, cfg, fields)
+		if err != nil {
+			return nil, block.locate(template, err)
+		}
+
+		fieldMap[block.name] = makeRepeatStub(block, template, templateFieldsMap[block.name], gen)
+		repeatedFields = append(repeatedFields, gen.fields...)
 	}
 
 	// Roll into slice of emit functions
-	emitFuncs := make([]emitFNotReturn, 0, len(fieldMap))
-	for _, fieldName := range orderedFields {
-		emitFuncs = append(emitFuncs, fieldMap[fieldName])
+	emitters := traceEmitters(cfg, fields, orderedFields)
+	for reference := range previous {
+		emitters[reference] = "previous"
+	}
+
+	emitFuncs := make([]emitFNotReturn, 0, len(orderedFields))
+	tracedEmitFuncs := make([]emitFNotReturn, 0, len(orderedFields))
+	for i, referenceName := range referenceFieldNames(orderedFields) {
+		fieldName := orderedFields[i]
+		emitF := fieldMap[fieldName]
+		if referenceName != fieldName && emitF != nil {
+			emitF = makeReferenceStub(fieldName, templateFieldsMap[referenceName], len(templateFieldsMap[fieldName]), emitF)
+		}
+
+		if pipeline, ok := pipelines[referenceName]; ok && emitF != nil {
+			emitF = makePipelineStub(pipeline, len(templateFieldsMap[referenceName]), isJSONEscaped(cfg, fields, fieldName), emitF)
+		}
+
+		emitFuncs = append(emitFuncs, emitF)
+		if isRepeatBlock(fieldName) {
+			// the fields of the body trace themselves
+			tracedEmitFuncs = append(tracedEmitFuncs, emitF)
+			continue
+		}
+
+		tracedEmitFuncs = append(tracedEmitFuncs, makeTraceStub(fieldName, emitters[fieldName], len(templateFieldsMap[referenceName]), emitF))
 	}
 
-	return &GeneratorWithCustomTemplate{emitFuncs: emitFuncs}, nil
+	return &GeneratorWithCustomTemplate{
+		emitFuncs:        emitFuncs,
+		tracedEmitFuncs:  tracedEmitFuncs,
+		trailingTemplate: trailingTemplate,
+		orderedFields:    orderedFields,
+		template:         template,
+		fields:           appendReferencedFields(referencedFields(cfg, fields, templateFieldNames(orderedFields, previous)), repeatedFields),
+	}, nil
+}
+
+// templateFieldNames returns the fields referenced in orderedFields, without the repeated names, the repeat blocks
+// and the references to the values of the previous event.
+func templateFieldNames(orderedFields []string, previous map[string]string) []string {
+	fieldNames := make([]string, 0, len(orderedFields))
+	for _, fieldName := range uniqueFieldNames(orderedFields) {
+		if _, ok := previous[fieldName]; !ok && !isRepeatBlock(fieldName) {
+			fieldNames = append(fieldNames, fieldName)
+		}
+	}
+
+	return fieldNames
+}
+
+// appendReferencedFields appends to referenced the fields of more that are not referenced already.
+func appendReferencedFields(referenced, more []ReferencedField) []ReferencedField {
+	seen := make(map[string]struct{}, len(referenced))
+	for _, field := range referenced {
+		seen[field.Field.Name] = struct{}{}
+	}
+
+	for _, field := range more {
+		if _, ok := seen[field.Field.Name]; !ok {
+			seen[field.Field.Name] = struct{}{}
+			referenced = append(referenced, field)
+		}
+	}
+
+	return referenced
+}
+
+// checkPipelines returns an error if a reference in orderedFields pipes its value to a function that is not an escape
+// function, or if the value is written with its JSON member, as the values of the fields in members.
+func checkPipelines(template []byte, orderedFields []string, pipelines map[string][]string, members map[string]jsonMember) error {
+	counts := make(map[string]int, len(orderedFields))
+	for i, referenceName := range referenceFieldNames(orderedFields) {
+		fieldName := orderedFields[i]
+		n := counts[fieldName]
+		counts[fieldName]++
+		pipeline, ok := pipelines[referenceName]
+		if !ok {
+			continue
+		}
+
+		if _, ok := members[fieldName]; ok {
+			return placeholderError(template, fieldName, n, errors.New("the values of sparse and array fields cannot be piped to functions"))
+		}
+
+		if err := checkPipeline(pipeline); err != nil {
+			return placeholderError(template, fieldName, n, err)
+		}
+	}
+
+	return nil
+}
+
+// uniqueFieldNames returns fieldNames without the repeated names, keeping the order of the first appearance.
+func uniqueFieldNames(fieldNames []string) []string {
+	seen := make(map[string]struct{}, len(fieldNames))
+	unique := make([]string, 0, len(fieldNames))
+	for _, fieldName := range fieldNames {
+		if _, ok := seen[fieldName]; ok {
+			continue
+		}
+
+		seen[fieldName] = struct{}{}
+		unique = append(unique, fieldName)
+	}
+
+	return unique
 }
 
 func (GeneratorWithCustomTemplate) Close() error {
 	return nil
 }
 
+func (gen GeneratorWithCustomTemplate) Fields() []ReferencedField {
+	return gen.fields
+}
+
+func (gen GeneratorWithCustomTemplate) Template() []byte {
+	return gen.template
+}
+
 func (gen GeneratorWithCustomTemplate) Emit(state *GenState, buf *bytes.Buffer) error {
 	if err := gen.emit(state, buf); err != nil {
 		return err
@@ -110,12 +485,48 @@ func (gen GeneratorWithCustomTemplate) Emit(state *GenState, buf *bytes.Buffer)
 }
 
 func (gen GeneratorWithCustomTemplate) emit(state *GenState, buf *bytes.Buffer) error {
-	for _, f := range gen.emitFuncs {
+	state.resetEventValues()
+	return gen.emitFields(state, buf)
+}
+
+// emitFields writes the template with the values of the fields of the event being emitted, also for each repetition
+// of the body of a repeat block.
+func (gen GeneratorWithCustomTemplate) emitFields(state *GenState, buf *bytes.Buffer) error {
+	emitFuncs := gen.emitFuncs
+	if state.tracing {
+		emitFuncs = gen.tracedEmitFuncs
+	}
+
+	state.trimSeparator = false
+	for i, f := range emitFuncs {
+		trim := state.trimSeparator
+		offset := buf.Len()
 		if err := f(state, buf); err != nil {
-			return err
+			return gen.placeholderError(i, err)
+		}
+
+		if trim && trimSeparator(buf, offset) {
+			state.trimSeparator = false
 		}
 	}
 
-	buf.Write(trailingTemplate)
+	buf.Write(gen.trailingTemplate)
 	return nil
 }
+
+// placeholderError locates err at the placeholder of the i-th emit function in the template. The errors of the repeat
+// blocks are located in their body already.
+func (gen GeneratorWithCustomTemplate) placeholderError(i int, err error) error {
+	fieldName := gen.orderedFields[i]
+	if isRepeatBlock(fieldName) {
+		return err
+	}
+	var n int
+	for _, previous := range gen.orderedFields[:i] {
+		if previous == fieldName {
+			n++
+		}
+	}
+
+	return placeholderError(gen.template, fieldName, n, err)
+}
@@ -2,9 +2,14 @@ package genlib
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"math"
 	"math/rand"
 	"net"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
@@ -156,10 +161,16 @@ func Test_ParseTemplate(t *testing.T) {
 			expectedTemplateFieldsMap: map[string][]byte{"aField": []byte("{"), "anotherField": []byte(" with curly brace as prefix just before a field and { in the middle ")},
 			expectedTrailingTemplate:  []byte(" and { curly brace in trailing with again { curly brace in trailing"),
 		},
+		{
+			template:                  []byte("{{.aField}} {{.anotherField}} and again {{.aField}}"),
+			expectedOrderFields:       []string{"aField", "anotherField", "aField"},
+			expectedTemplateFieldsMap: map[string][]byte{"aField": nil, "anotherField": []byte(" "), "aField#ref1": []byte(" and again ")},
+			expectedTrailingTemplate:  nil,
+		},
 	}
 	for _, testCase := range testCases {
 		t.Run(fmt.Sprintf("with template: %s", string(testCase.template)), func(t *testing.T) {
-			orderedFields, templateFieldsMap, trailingTemplate := parseCustomTemplate(testCase.template)
+			orderedFields, templateFieldsMap, trailingTemplate, _ := parseCustomTemplate(testCase.template, Config{}, nil)
 			if len(orderedFields) != len(testCase.expectedOrderFields) {
 				t.Errorf("Expected equal orderedFields")
 			}
@@ -203,6 +214,22 @@ func Test_EmptyCaseWithCustomTemplate(t *testing.T) {
 	}
 }
 
+func Test_NestedObjectWithCustomTemplate(t *testing.T) {
+	flds := []Field{{Name: "alpha", Type: FieldTypeKeyword}, {Name: "host.name", Type: FieldTypeKeyword}}
+	template := []byte(`{"alpha":"{{.alpha}}","host":{"name":"{{.host.name}}"}}`)
+	g, state := makeGeneratorWithCustomTemplate(t, Config{}, flds, template)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// the braces of the objects after a field are kept
+	if !json.Valid(buf.Bytes()) {
+		t.Errorf("expected a JSON event, got %s", buf.String())
+	}
+}
+
 func Test_CardinalityWithCustomTemplate(t *testing.T) {
 
 	test_CardinalityTWithCustomTemplate[string](t, FieldTypeKeyword)
@@ -270,6 +297,411 @@ func test_CardinalityTWithCustomTemplate[T any](t *testing.T, ty string) {
 	}
 }
 
+func Test_CardinalityDistinctWithCustomTemplate(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeLong,
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  cardinality:\n    distinct: 37\n  range: 10000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":{{.alpha}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, []Field{fld}, template)
+
+	vmap := make(map[int]int)
+	nSpins := 1024
+	for i := 0; i < nSpins; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[int](t, buf.Bytes())
+		vmap[m["alpha"]] += 1
+	}
+
+	if len(vmap) != 37 {
+		t.Errorf("Expected cardinality of 37 got %d", len(vmap))
+	}
+}
+
+func Test_EntityWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "host.ip", Type: FieldTypeIP},
+		{Name: "user.name", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: host.name\n  entity: host\n  cardinality:\n    distinct: 20\n- name: host.ip\n  entity: host\n- name: user.name\n  entity: user\n  cardinality:\n    distinct: 5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"host.name":"{{.host.name}}","host.ip":"{{.host.ip}}","user.name":"{{.user.name}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	hosts := make(map[string]string)
+	users := make(map[string]int)
+	nSpins := 1024
+	for i := 0; i < nSpins; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		if ip, ok := hosts[m["host.name"]]; ok && ip != m["host.ip"] {
+			t.Errorf("Expected host %s to always have ip %s, got %s", m["host.name"], ip, m["host.ip"])
+		}
+
+		hosts[m["host.name"]] = m["host.ip"]
+		users[m["user.name"]] += 1
+	}
+
+	if len(hosts) != 20 {
+		t.Errorf("Expected 20 hosts got %d", len(hosts))
+	}
+
+	if len(users) != 5 {
+		t.Errorf("Expected 5 users got %d", len(users))
+	}
+
+	_, err = config.LoadConfigFromYaml([]byte("- name: host.name\n  entity: host\n  cardinality:\n    distinct: 20\n- name: host.ip\n  entity: host\n  cardinality:\n    per_million_events: 10"))
+	if err == nil {
+		t.Errorf("Expected error for an entity with different cardinalities relative to the size of the corpus")
+	}
+
+	_, err = config.LoadConfigFromYaml([]byte("- name: host.name\n  entity: host"))
+	if err == nil {
+		t.Errorf("Expected error for an entity without cardinality")
+	}
+}
+
+func Test_EntityCompositeKeysWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "container.id", Type: FieldTypeKeyword},
+		{Name: "container.name", Type: FieldTypeKeyword},
+		{Name: "kubernetes.pod.name", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: container.id\n  entity: container\n  cardinality:\n    distinct: 40\n- name: container.name\n  entity: container\n- name: kubernetes.pod.name\n  entity: container\n  cardinality:\n    distinct: 8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"container.id":"{{.container.id}}","container.name":"{{.container.name}}","kubernetes.pod.name":"{{.kubernetes.pod.name}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	// the containers are spread over the pods, each container always in the same pod
+	tuples := make(map[string]string)
+	pods := make(map[string]struct{})
+	for i := 0; i < 2048; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		tuple := m["container.name"] + "/" + m["kubernetes.pod.name"]
+		if previous, ok := tuples[m["container.id"]]; ok && previous != tuple {
+			t.Errorf("Expected container %s to always be %s, got %s", m["container.id"], previous, tuple)
+		}
+
+		tuples[m["container.id"]] = tuple
+		pods[m["kubernetes.pod.name"]] = struct{}{}
+	}
+
+	if len(tuples) != 40 {
+		t.Errorf("Expected 40 containers got %d", len(tuples))
+	}
+
+	if len(pods) != 8 {
+		t.Errorf("Expected 8 pods got %d", len(pods))
+	}
+}
+
+func Test_CardinalitySkewWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "host.ip", Type: FieldTypeIP},
+		{Name: "user.name", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: host.name\n  entity: host\n  cardinality:\n    distinct: 50\n  skew:\n    zipf: 1.5\n- name: host.ip\n  entity: host\n- name: user.name\n  cardinality:\n    distinct: 100\n  skew:\n    top: [60]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"host.name":"{{.host.name}}","host.ip":"{{.host.ip}}","user.name":"{{.user.name}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	hosts := make(map[string]int)
+	users := make(map[string]int)
+	nSpins := 10000
+	for i := 0; i < nSpins; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		hosts[m["host.name"]+"/"+m["host.ip"]] += 1
+		users[m["user.name"]] += 1
+	}
+
+	top := func(counts map[string]int) int {
+		var max int
+		for _, count := range counts {
+			if count > max {
+				max = count
+			}
+		}
+
+		return max
+	}
+
+	// the first of 50 hosts is drawn for about 40% of the events with zipf 1.5, instead of 2%
+	if len(hosts) > 50 || top(hosts) < nSpins*30/100 {
+		t.Errorf("Expected at most 50 hosts, the first one for about 40%% of the events, got %d hosts, the first one for %d events", len(hosts), top(hosts))
+	}
+
+	if len(users) > 100 || top(users) < nSpins*55/100 || top(users) > nSpins*65/100 {
+		t.Errorf("Expected at most 100 users, the first one for about 60%% of the events, got %d users, the first one for %d events", len(users), top(users))
+	}
+
+	for yaml, expected := range map[string]string{
+		"- name: user.name\n  skew:\n    zipf: 1.1":                                                                                           "skew requires cardinality or entity",
+		"- name: user.name\n  cardinality: 10\n  skew:\n    top: [60, 50]":                                                                    "skew top percentages must sum up to 100 at most",
+		"- name: user.name\n  cardinality: 10\n  skew:\n    zipf: 1.1\n    top: [60]":                                                         "skew zipf and top are mutually exclusive",
+		"- name: host.name\n  entity: host\n  cardinality: 10\n  skew:\n    zipf: 1.1\n- name: host.ip\n  entity: host\n  skew:\n    zipf: 2": "skew zipf 2 of entity host differs",
+	} {
+		if _, err := config.LoadConfigFromYaml([]byte(yaml)); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q, got %v", expected, err)
+		}
+	}
+}
+
+func Test_CardinalityChurnWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "kubernetes.pod.uid", Type: FieldTypeIP},
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "host.ip", Type: FieldTypeIP},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: kubernetes.pod.uid\n  cardinality:\n    distinct: 10\n  churn:\n    percentage: 20\n    every: 1h\n- name: host.name\n  entity: host\n  cardinality:\n    distinct: 4\n  churn:\n    percentage: 50\n    every: 1h\n- name: host.ip\n  entity: host"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"kubernetes.pod.uid":"{{.kubernetes.pod.uid}}","host.name":"{{.host.name}}","host.ip":"{{.host.ip}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// the hosts keep their ip until replaced, while the names of the new hosts can be the ones of replaced hosts
+	emit := func(eventTime time.Time) (map[string]struct{}, map[string]struct{}) {
+		pods, hosts := make(map[string]struct{}), make(map[string]struct{})
+		hostIPs := make(map[string]string)
+		state.SetEventTime(eventTime)
+		for i := 0; i < 200; i++ {
+			var buf bytes.Buffer
+			if err := g.Emit(state, &buf); err != nil {
+				t.Fatal(err)
+			}
+
+			m := unmarshalJSONT[string](t, buf.Bytes())
+			if ip, ok := hostIPs[m["host.name"]]; ok && ip != m["host.ip"] {
+				t.Errorf("Expected host %s to always have ip %s, got %s", m["host.name"], ip, m["host.ip"])
+			}
+
+			hostIPs[m["host.name"]] = m["host.ip"]
+			pods[m["kubernetes.pod.uid"]] = struct{}{}
+			hosts[m["host.ip"]] = struct{}{}
+		}
+
+		return pods, hosts
+	}
+
+	common := func(a, b map[string]struct{}) int {
+		var n int
+		for value := range a {
+			if _, ok := b[value]; ok {
+				n++
+			}
+		}
+
+		return n
+	}
+
+	pods, hosts := emit(start)
+	nextPods, nextHosts := emit(start.Add(90 * time.Minute))
+	if len(nextPods) != 10 || common(pods, nextPods) != 8 {
+		t.Errorf("Expected 2 of 10 pods replaced after an hour, got %d pods, %d in common", len(nextPods), common(pods, nextPods))
+	}
+
+	if len(nextHosts) != 4 || common(hosts, nextHosts) != 2 {
+		t.Errorf("Expected 2 of 4 hosts replaced after an hour, got %d hosts, %d in common", len(nextHosts), common(hosts, nextHosts))
+	}
+
+	laterPods, _ := emit(start.Add(5 * time.Hour))
+	if common(pods, laterPods) != 0 {
+		t.Errorf("Expected all the pods replaced after 5 hours, got %d in common", common(pods, laterPods))
+	}
+
+	for yaml, expected := range map[string]string{
+		"- name: user.name\n  churn:\n    percentage: 10\n    every: 1h":                     "churn requires cardinality or entity",
+		"- name: user.name\n  cardinality: 10\n  churn:\n    percentage: 10":                 "churn every must be a positive duration",
+		"- name: user.name\n  cardinality: 10\n  churn:\n    percentage: 110\n    every: 1h": "churn percentage must be between 0 and 100",
+	} {
+		if _, err := config.LoadConfigFromYaml([]byte(yaml)); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q, got %v", expected, err)
+		}
+	}
+}
+
+func Test_EnumQuotasWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "event.action", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: event.action\n  enum: [\"logon\", \"logon-failed\", \"logoff\"]\n  weights: [1, 0, 1]\n  quotas:\n    logon-failed: 100\n    logoff: 3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1024
+	if err := cfg.CheckQuotas(uint64(nSpins)); err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"event.action":"{{.event.action}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+	state.SetEvents(uint64(nSpins))
+
+	actions := make(map[string]int)
+	var lastFailed int
+	for i := 0; i < nSpins; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		actions[m["event.action"]] += 1
+		if m["event.action"] == "logon-failed" {
+			lastFailed = i
+		}
+	}
+
+	if actions["logon-failed"] != 100 || actions["logoff"] != 3 || actions["logon"] != nSpins-103 {
+		t.Errorf("Expected exactly 100 logon-failed and 3 logoff actions, got %v", actions)
+	}
+
+	if lastFailed < nSpins/2 {
+		t.Errorf("Expected the logon-failed actions to be spread across the events, the last one is %d", lastFailed)
+	}
+
+	if err := cfg.CheckQuotas(50); err == nil {
+		t.Errorf("Expected error for quotas summing up to more than the events")
+	}
+
+	for _, yaml := range []string{
+		"- name: event.action\n  enum: [\"logon\"]\n  quotas:\n    logoff: 1",
+		"- name: event.action\n  enum: [\"logon\"]\n  quotas:\n    logon: -1",
+		"- name: event.action\n  enum: [\"logon\"]\n  null_percentage: 10\n  quotas:\n    logon: 1",
+	} {
+		if _, err := config.LoadConfigFromYaml([]byte(yaml)); err == nil {
+			t.Errorf("Expected error for config %s", yaml)
+		}
+	}
+
+	cfg, err = config.LoadConfigFromYaml([]byte("- name: event.action\n  enum: [\"logon\"]\n  quotas:\n    logon: 10"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.CheckQuotas(20); err == nil {
+		t.Errorf("Expected error for quotas of all the values of enum summing up to less than the events")
+	}
+}
+
+func Test_FieldValueFileWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "user_agent.original", Type: FieldTypeKeyword},
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"hostnames.txt":   "web-01\r\nweb-02\n\ndb-01\n",
+		"user_agents.csv": "\"Mozilla/5.0 (KHTML, like Gecko)\",3\ncurl/8.4.0,0\nWget/1.21\n",
+		"config.yml":      "- name: host.name\n  value_file: hostnames.txt\n- name: user_agent.original\n  value_file: user_agents.csv\n",
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the value files are relative to the config file
+	cfg, err := config.LoadConfig(filepath.Join(dir, "config.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"host.name":"{{.host.name}}","user_agent.original":"{{.user_agent.original}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	hostnames := make(map[string]int)
+	userAgents := make(map[string]int)
+	for i := 0; i < 1024; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		hostnames[m["host.name"]] += 1
+		userAgents[m["user_agent.original"]] += 1
+	}
+
+	if len(hostnames) != 3 || hostnames["web-01"] == 0 || hostnames["web-02"] == 0 || hostnames["db-01"] == 0 {
+		t.Errorf("Expected the 3 hostnames of the value file, got %v", hostnames)
+	}
+
+	if len(userAgents) != 2 || userAgents["curl/8.4.0"] > 0 || userAgents["Mozilla/5.0 (KHTML, like Gecko)"] <= userAgents["Wget/1.21"] {
+		t.Errorf("Expected the user agents of the value file drawn according to their weights, got %v", userAgents)
+	}
+
+	for _, yaml := range []string{
+		"- name: host.name\n  value_file: hostnames.txt\n  enum: [\"web-03\"]",
+		"- name: host.name\n  value_file: missing.txt",
+		"- name: host.name\n  value_file: empty.txt",
+		"- name: host.name\n  value_file: invalid.csv",
+	} {
+		files := map[string]string{"empty.txt": "\n\n", "invalid.csv": "web-01,many\n"}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := config.LoadConfigFromYaml([]byte(yaml), config.WithBaseDir(dir)); err == nil {
+			t.Errorf("Expected error for config %s", yaml)
+		}
+	}
+}
+
 func Test_FieldBoolWithCustomTemplate(t *testing.T) {
 	fld := Field{
 		Name: "alpha",
@@ -449,58 +881,375 @@ func Test_FieldDateWithCustomTemplate(t *testing.T) {
 	}
 }
 
-func Test_FieldIPWithCustomTemplate(t *testing.T) {
+func Test_FieldDateEventTimeWithCustomTemplate(t *testing.T) {
 	fld := Field{
 		Name: "alpha",
-		Type: FieldTypeIP,
+		Type: FieldTypeDate,
 	}
 
-	template := []byte(`{"alpha":"{{.alpha}}"}`)
+	template := []byte(`{"alpha":"{{.alpha}}","beta":"{{.beta}}"}`)
 	t.Logf("with template: %s", string(template))
-	nSpins := rand.Intn(1024) + 1
-	for i := 0; i < nSpins; i++ {
+	g, state := makeGeneratorWithCustomTemplate(t, Config{}, []Field{fld, {Name: "beta", Type: FieldTypeDate}}, template)
 
-		b := testSingleTWithCustomTemplate[string](t, fld, nil, template)
+	eventTime := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	state.SetEventTime(eventTime)
 
-		if ip := net.ParseIP(b); ip == nil {
-			t.Errorf("Fail parse ip %s", b)
-		}
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
 	}
-}
-
-func Test_FieldFloatsWithCustomTemplate(t *testing.T) {
-	_testNumericWithCustomTemplate[float64](t, FieldTypeDouble)
-	_testNumericWithCustomTemplate[float32](t, FieldTypeFloat)
-	_testNumericWithCustomTemplate[float32](t, FieldTypeHalfFloat)
-	_testNumericWithCustomTemplate[float64](t, FieldTypeScaledFloat)
-
-}
 
-func Test_FieldIntegersWithCustomTemplate(t *testing.T) {
-	_testNumericWithCustomTemplate[int](t, FieldTypeInteger)
-	_testNumericWithCustomTemplate[int64](t, FieldTypeLong)
-	_testNumericWithCustomTemplate[uint64](t, FieldTypeUnsignedLong)
+	m := unmarshalJSONT[string](t, buf.Bytes())
+	for _, name := range []string{"alpha", "beta"} {
+		if m[name] != eventTime.Format(FieldTypeTimeLayout) {
+			t.Errorf("expected %s to be %s, got %s", name, eventTime.Format(FieldTypeTimeLayout), m[name])
+		}
+	}
 }
 
-func _testNumericWithCustomTemplate[T any](t *testing.T, ty string) {
-	fld := Field{
-		Name: "alpha",
-		Type: ty,
+func Test_FieldSparseWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+		{Name: "gamma", Type: FieldTypeIP},
 	}
 
-	template := []byte(`{"alpha":{{.alpha}}}`)
-	t.Logf("with template: %s", string(template))
-	nSpins := rand.Intn(1024) + 1
-	for i := 0; i < nSpins; i++ {
-		testSingleTWithCustomTemplate[T](t, fld, nil, template)
+	testCases := []struct {
+		yaml     string
+		template string
+	}{
+		{
+			yaml:     "- name: alpha\n  omit_percentage: 100\n- name: gamma\n  null_percentage: 100",
+			template: `{"alpha":"{{.alpha}}", "beta":{{.beta}}, "gamma":"{{.gamma}}"}`,
+		},
+		{
+			yaml:     "- name: alpha\n  omit_percentage: 100\n- name: beta\n  omit_percentage: 100\n- name: gamma\n  omit_percentage: 100",
+			template: `{"alpha":"{{.alpha}}", "beta":{{.beta}}, "gamma":"{{.gamma}}"}`,
+		},
+		{
+			yaml:     "- name: alpha\n  null_percentage: 30\n  omit_percentage: 30\n- name: beta\n  omit_percentage: 50\n- name: gamma\n  omit_percentage: 50",
+			template: `{"alpha":"{{.alpha}}", "beta":{{.beta}}, "gamma":"{{.gamma}}"}`,
+		},
 	}
-}
-
-func testSingleTWithCustomTemplate[T any](t *testing.T, fld Field, yaml []byte, template []byte) T {
-	var err error
-	var cfg Config
 
-	if yaml != nil {
+	for _, tc := range testCases {
+		cfg, err := config.LoadConfigFromYaml([]byte(tc.yaml))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, []byte(tc.template))
+
+		counts := make(map[string]int)
+		nulls := make(map[string]int)
+		nSpins := 1024
+		for i := 0; i < nSpins; i++ {
+			var buf bytes.Buffer
+			if err := g.Emit(state, &buf); err != nil {
+				t.Fatal(err)
+			}
+
+			m := unmarshalJSONT[any](t, buf.Bytes())
+			for k, v := range m {
+				counts[k] += 1
+				if v == nil {
+					nulls[k] += 1
+				}
+			}
+		}
+
+		for _, fld := range flds {
+			fieldCfg, _ := cfg.GetField(fld.Name)
+			expectedOmitted := nSpins * fieldCfg.OmitPercentage / 100
+			omitted := nSpins - counts[fld.Name]
+			if omitted < expectedOmitted-nSpins/10 || omitted > expectedOmitted+nSpins/10 {
+				t.Errorf("%s: expected about %d omitted values, got %d", fld.Name, expectedOmitted, omitted)
+			}
+
+			expectedNulls := nSpins * fieldCfg.NullPercentage / 100
+			if nulls[fld.Name] < expectedNulls-nSpins/10 || nulls[fld.Name] > expectedNulls+nSpins/10 {
+				t.Errorf("%s: expected about %d null values, got %d", fld.Name, expectedNulls, nulls[fld.Name])
+			}
+		}
+	}
+}
+
+func Test_FieldSparseNotJSONWithCustomTemplate(t *testing.T) {
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  omit_percentage: 10"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewGeneratorWithCustomTemplate([]byte(`alpha is {{.alpha}}`), cfg, []Field{{Name: "alpha", Type: FieldTypeKeyword}})
+	if err == nil {
+		t.Errorf("expected error for a field not in a JSON object member")
+	}
+}
+
+func Test_FieldRulesWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"success\", \"failure\"]\n- name: beta\n  enum: [\"200\"]\n  rules:\n    - when:\n        field: alpha\n        equals: failure\n      then:\n        enum: [\"500\", \"503\"]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":"{{.alpha}}","beta":"{{.beta}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	nSpins := 1024
+	for i := 0; i < nSpins; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		if m["alpha"] == "failure" && m["beta"] != "500" && m["beta"] != "503" {
+			t.Errorf("expected beta to be 500 or 503 for failure, got %s", m["beta"])
+		}
+
+		if m["alpha"] == "success" && m["beta"] != "200" {
+			t.Errorf("expected beta to be 200 for success, got %s", m["beta"])
+		}
+	}
+
+	_, err = NewGeneratorWithCustomTemplate([]byte(`{"beta":"{{.beta}}","alpha":"{{.alpha}}"}`), cfg, flds)
+	if err == nil {
+		t.Errorf("expected error for a rule depending on a following field")
+	}
+}
+
+func Test_FieldRulesEscapedWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: ['say \"hi\"', 'C:\\temp']\n- name: beta\n  value: none\n  rules:\n    - when:\n        field: alpha\n        equals: 'say \"hi\"'\n      then:\n        value: quoted\n    - when:\n        field: alpha\n        equals: 'C:\\temp'\n      then:\n        value: backslashed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":"{{.alpha}}","beta":{{.beta}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	expected := map[string]string{`say "hi"`: "quoted", `C:\temp`: "backslashed"}
+	for i := 0; i < 64; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		if m["beta"] != expected[m["alpha"]] {
+			t.Errorf("expected beta to be %s for %s, got %s", expected[m["alpha"]], m["alpha"], m["beta"])
+		}
+	}
+}
+
+func Test_TracingWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"a\", \"b\"]\n- name: beta\n  cardinality: 250"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":"{{.alpha}}","beta":{{.beta}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(state.Traces()) != 0 {
+		t.Errorf("expected no traces with tracing disabled, got %v", state.Traces())
+	}
+
+	state.SetTracing(true)
+	buf.Reset()
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m := unmarshalJSONT[any](t, buf.Bytes())
+	traces := state.Traces()
+	if len(traces) != 2 {
+		t.Fatalf("expected 2 traces, got %d", len(traces))
+	}
+
+	if traces[0].Field != "alpha" || traces[0].Emitter != "keyword.enum" || traces[0].Draw == nil || traces[0].Value != m["alpha"] {
+		t.Errorf("unexpected trace for alpha: %+v", traces[0])
+	}
+
+	if traces[1].Field != "beta" || traces[1].Emitter != "cardinality.long" || traces[1].PoolEntry == nil || *traces[1].PoolEntry != 1 || traces[1].Value != fmt.Sprint(m["beta"]) {
+		t.Errorf("unexpected trace for beta: %+v", traces[1])
+	}
+}
+
+func Test_TracingSparseAndArrayWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "bytes", Type: FieldTypeLong},
+		{Name: "tags", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: host\n  enum: [\"rat\", \"cat\"]\n  null_percentage: 30\n  omit_percentage: 30\n- name: bytes\n  range: 100\n  omit_percentage: 50\n- name: tags\n  enum: [\"a\", \"b\"]\n  array_min: 1\n  array_max: 3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"host":"{{.host}}","bytes":{{.bytes}},"tags":"{{.tags}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	sparse := map[string]int{}
+	for i := 0; i < 200; i++ {
+		state.SetTracing(true)
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		traces := state.Traces()
+		if len(traces) != 3 {
+			t.Fatalf("expected 3 traces, got %d", len(traces))
+		}
+
+		for _, trace := range traces {
+			value, present := m[trace.Field]
+			switch {
+			case !present:
+				if trace.Sparse != TraceSparseOmitted || trace.Value != "" {
+					t.Errorf("unexpected trace for the omitted %s: %+v", trace.Field, trace)
+				}
+			case value == nil:
+				if trace.Sparse != TraceSparseNull || trace.Value != "" {
+					t.Errorf("unexpected trace for the null %s: %+v", trace.Field, trace)
+				}
+			case trace.Field == "tags":
+				encoded, _ := json.Marshal(value)
+				if trace.Sparse != "" || trace.Value != string(encoded) {
+					t.Errorf("unexpected trace for tags %s: %+v", encoded, trace)
+				}
+			default:
+				if trace.Sparse != "" || trace.Value != fmt.Sprint(value) {
+					t.Errorf("unexpected trace for %s %v: %+v", trace.Field, value, trace)
+				}
+			}
+
+			sparse[trace.Sparse]++
+		}
+	}
+
+	if sparse[TraceSparseNull] == 0 || sparse[TraceSparseOmitted] == 0 {
+		t.Errorf("expected null and omitted values to be traced, got %v", sparse)
+	}
+}
+
+func Test_IntrospectionWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+		{Name: "gamma.*", Type: FieldTypeObject, ObjectType: FieldTypeIP},
+		{Name: "delta", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"a\"]\n- name: gamma.*\n  object_keys: [\"one\"]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"beta":{{.beta}},"alpha":"{{.alpha}}","gamma.one":"{{.gamma.one}}","beta2":{{.beta}}}`)
+	t.Logf("with template: %s", string(template))
+	g, _ := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	if string(g.Template()) != string(template) {
+		t.Errorf("expected template %s, got %s", template, g.Template())
+	}
+
+	referenced := g.Fields()
+	if len(referenced) != 3 {
+		t.Fatalf("expected 3 referenced fields, got %d", len(referenced))
+	}
+
+	if referenced[0].Field.Name != "beta" || referenced[0].Emitter != "long" {
+		t.Errorf("unexpected referenced field %+v", referenced[0])
+	}
+
+	if referenced[1].Field.Name != "alpha" || referenced[1].Emitter != "keyword.enum" || len(referenced[1].Config.Enum) != 1 {
+		t.Errorf("unexpected referenced field %+v", referenced[1])
+	}
+
+	if referenced[2].Field.Name != "gamma.one" || referenced[2].Field.Type != FieldTypeIP || referenced[2].Emitter != "ip" {
+		t.Errorf("unexpected referenced field %+v", referenced[2])
+	}
+}
+
+func Test_FieldIPWithCustomTemplate(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeIP,
+	}
+
+	template := []byte(`{"alpha":"{{.alpha}}"}`)
+	t.Logf("with template: %s", string(template))
+	nSpins := rand.Intn(1024) + 1
+	for i := 0; i < nSpins; i++ {
+
+		b := testSingleTWithCustomTemplate[string](t, fld, nil, template)
+
+		if ip := net.ParseIP(b); ip == nil {
+			t.Errorf("Fail parse ip %s", b)
+		}
+	}
+}
+
+func Test_FieldFloatsWithCustomTemplate(t *testing.T) {
+	_testNumericWithCustomTemplate[float64](t, FieldTypeDouble)
+	_testNumericWithCustomTemplate[float32](t, FieldTypeFloat)
+	_testNumericWithCustomTemplate[float32](t, FieldTypeHalfFloat)
+	_testNumericWithCustomTemplate[float64](t, FieldTypeScaledFloat)
+
+}
+
+func Test_FieldIntegersWithCustomTemplate(t *testing.T) {
+	_testNumericWithCustomTemplate[int](t, FieldTypeInteger)
+	_testNumericWithCustomTemplate[int64](t, FieldTypeLong)
+	_testNumericWithCustomTemplate[uint64](t, FieldTypeUnsignedLong)
+}
+
+func _testNumericWithCustomTemplate[T any](t *testing.T, ty string) {
+	fld := Field{
+		Name: "alpha",
+		Type: ty,
+	}
+
+	template := []byte(`{"alpha":{{.alpha}}}`)
+	t.Logf("with template: %s", string(template))
+	nSpins := rand.Intn(1024) + 1
+	for i := 0; i < nSpins; i++ {
+		testSingleTWithCustomTemplate[T](t, fld, nil, template)
+	}
+}
+
+func testSingleTWithCustomTemplate[T any](t *testing.T, fld Field, yaml []byte, template []byte) T {
+	var err error
+	var cfg Config
+
+	if yaml != nil {
 		cfg, err = config.LoadConfigFromYaml(yaml)
 		if err != nil {
 			t.Fatal(err)
@@ -541,3 +1290,824 @@ func makeGeneratorWithCustomTemplate(t *testing.T, cfg Config, fields Fields, te
 
 	return g, NewGenState()
 }
+
+func Test_JSONEscapeWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"say \\\"hi\\\"\\\\\\n\"]\n- name: beta\n  value: \"{\\\"raw\\\": true}\""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":"{{.alpha}}","beta":{{.beta}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != `{"alpha":"say \"hi\"\\\n","beta":"{\"raw\": true}"}` {
+		t.Errorf("unexpected escaped event %s", buf.String())
+	}
+
+	buf.Reset()
+	state.SetRawValues(true)
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != "{\"alpha\":\"say \"hi\"\\\n\",\"beta\":\"{\\\"raw\\\": true}\"}" {
+		t.Errorf("unexpected raw event %s", buf.String())
+	}
+}
+
+func Test_XMLEscapeWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"a<b & \\\"c\\\"\\n\"]\n- name: beta\n  null_percentage: 50"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the XML rendered in a JSON string, like the original event of winlog
+	template := []byte(`{"event.original":"<Event><Data Name=\"Message\">{{.alpha | xmlEscape | jsonEscape}}</Data></Event>","message":"{{.alpha}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m := unmarshalJSONT[string](t, buf.Bytes())
+	if m["event.original"] != `<Event><Data Name="Message">a&lt;b &amp; &#34;c&#34;&#xA;</Data></Event>` {
+		t.Errorf("unexpected event.original %s", m["event.original"])
+	}
+
+	if m["message"] != "a<b & \"c\"\n" {
+		t.Errorf("unexpected message %s", m["message"])
+	}
+
+	testCases := []struct {
+		template string
+		expected string
+	}{
+		{template: `{"alpha":"{{.alpha | yamlEscape}}"}`, expected: `template:1:11: field alpha: function "yamlEscape" not defined, the values can be piped to jsonEscape and xmlEscape`},
+		{template: `{"beta":"{{.beta | xmlEscape}}"}`, expected: "template:1:10: field beta: the values of sparse and array fields cannot be piped to functions"},
+	}
+
+	for _, testCase := range testCases {
+		if _, err := NewGeneratorWithCustomTemplate([]byte(testCase.template), cfg, flds); err == nil || err.Error() != testCase.expected {
+			t.Errorf("expected error %q, got %v", testCase.expected, err)
+		}
+	}
+}
+
+func Test_FieldEscapeWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeKeyword},
+		{Name: "gamma", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"a<\\\"b\\\"\"]\n  escape: none\n- name: beta\n  enum: [\"a<\\\"b\\\"\"]\n  escape: xml\n- name: gamma\n  enum: [\"a<\\\"b\\\"\"]\n  escape: json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{{.alpha}} {{.beta}} {{.gamma}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != `a<"b" a&lt;&#34;b&#34; a<\"b\"` {
+		t.Errorf("unexpected escaped event %s", buf.String())
+	}
+
+	buf.Reset()
+	state.SetRawValues(true)
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != `a<"b" a&lt;&#34;b&#34; a<"b"` {
+		t.Errorf("unexpected raw event %s", buf.String())
+	}
+
+	if _, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  escape: yaml")); err == nil || !strings.Contains(err.Error(), "escape must be one of json, none and xml, got yaml") {
+		t.Errorf("expected escape error, got %v", err)
+	}
+}
+
+func Test_FieldRenameAndAliasWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "source.ip", Type: FieldTypeKeyword},
+		{Name: "event.action", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: source.ip\n  rename: src_ip\n  alias: [client_ip]\n- name: event.action\n  alias: [action]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{{.src_ip}} {{.client_ip}} {{.action}} {{.event.action}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	values := strings.Split(buf.String(), " ")
+	if len(values[0]) == 0 || values[0] != values[1] || len(values[2]) == 0 || values[2] != values[3] {
+		t.Errorf("unexpected event %s", buf.String())
+	}
+
+	for _, yaml := range []string{
+		"- name: source.ip\n  rename: event.action\n- name: event.action",
+		"- name: source.ip\n  alias: [ip]\n- name: event.action\n  alias: [ip]",
+	} {
+		if _, err := config.LoadConfigFromYaml([]byte(yaml)); err == nil || !strings.Contains(err.Error(), "is the name of a field, a rename or an alias") {
+			t.Errorf("expected rename or alias error, got %v", err)
+		}
+	}
+}
+
+func Test_FieldIPCIDRWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "source.ip", Type: FieldTypeIP},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: source.ip\n  cidr: [\"10.0.0.0/30\"]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{{.source.ip}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		// the network and broadcast addresses are left out
+		if buf.String() != "10.0.0.1" && buf.String() != "10.0.0.2" {
+			t.Errorf("source.ip %s not a host address of 10.0.0.0/30", buf.String())
+		}
+	}
+
+	for yaml, expected := range map[string]string{
+		"- name: source.ip\n  cidr: [\"10.0.0.0/33\"]":                       "cidr 10.0.0.0/33 is not a valid subnet",
+		"- name: source.ip\n  cidr: [\"10.0.0.0/8\"]\n  ipv6_percentage: 50": "cidr and ipv6_percentage are mutually exclusive",
+	} {
+		if _, err := config.LoadConfigFromYaml([]byte(yaml)); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q, got %v", expected, err)
+		}
+	}
+}
+
+func Test_FieldPrecisionWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeDouble},
+		{Name: "beta", Type: FieldTypeDouble},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  precision: 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{{.alpha}} {{.beta}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	precisionRegex := regexp.MustCompile(`^\d+\.\d \d+\.\d{6}$`)
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if !precisionRegex.Match(buf.Bytes()) {
+			t.Errorf("unexpected formatting %s", buf.String())
+		}
+	}
+}
+
+func Test_FieldRangeBoundsWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "source.port", Type: FieldTypeLong},
+		{Name: "system.cpu.pct", Type: FieldTypeDouble},
+		{Name: "system.load", Type: FieldTypeScaledFloat, ScalingFactor: 100},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: source.port\n  range:\n    min: 1\n    max: 65535\n- name: system.cpu.pct\n  range:\n    min: 0\n    max: 100\n  fuzziness: 50\n  decimals: 1\n- name: system.load\n  range:\n    min: 0.5\n    max: 4"), config.WithStrict())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"source.port":{{.source.port}},"system.cpu.pct":{{.system.cpu.pct}},"system.load":{{.system.load}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	formatRegex := regexp.MustCompile(`"system.cpu.pct":\d+\.\d,"system.load":\d(\.\d{1,2})?}$`)
+	for i := 0; i < 1000; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if !formatRegex.Match(buf.Bytes()) {
+			t.Errorf("unexpected formatting %s", buf.String())
+		}
+
+		m := unmarshalJSONT[float64](t, buf.Bytes())
+		if m["source.port"] < 1 || m["source.port"] > 65535 || m["source.port"] != float64(int(m["source.port"])) {
+			t.Errorf("source.port %v not an integer within 1 and 65535", m["source.port"])
+		}
+
+		if m["system.cpu.pct"] < 0 || m["system.cpu.pct"] > 100 {
+			t.Errorf("system.cpu.pct %v not within 0 and 100", m["system.cpu.pct"])
+		}
+
+		if m["system.load"] < 0.5 || m["system.load"] > 4 {
+			t.Errorf("system.load %v not within 0.5 and 4", m["system.load"])
+		}
+	}
+
+	for _, yaml := range []string{
+		"- name: source.port\n  range:\n    min: 10\n    max: 1",
+		"- name: source.port\n  range:\n    min: 1",
+		"- name: system.cpu.pct\n  decimals: 1\n  precision: 2",
+	} {
+		if _, err := config.LoadConfigFromYaml([]byte(yaml)); err == nil {
+			t.Errorf("Expected error for config %s", yaml)
+		}
+	}
+}
+
+func Test_FieldDerivedWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "bytes.in", Type: FieldTypeLong},
+		{Name: "bytes.out", Type: FieldTypeLong},
+		{Name: "bytes.total", Type: FieldTypeLong},
+		{Name: "event.start", Type: FieldTypeDate},
+		{Name: "event.end", Type: FieldTypeDate},
+		{Name: "event.duration", Type: FieldTypeLong},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: bytes.in\n  range: 1000\n- name: bytes.out\n  range: 1000\n- name: bytes.total\n  expression: bytes.in + bytes.out\n- name: event.duration\n  expression: event.end - event.start"), config.WithStrict())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"bytes.in":{{.bytes.in}},"bytes.out":{{.bytes.out}},"bytes.total":{{.bytes.total}},"event.start":"{{.event.start}}","event.end":"{{.event.end}}","event.duration":{{.event.duration}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[interface{}](t, buf.Bytes())
+		if m["bytes.total"] != m["bytes.in"].(float64)+m["bytes.out"].(float64) {
+			t.Errorf("bytes.total %v is not the sum of %v and %v", m["bytes.total"], m["bytes.in"], m["bytes.out"])
+		}
+
+		start, err := time.Parse(time.RFC3339Nano, m["event.start"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		end, err := time.Parse(time.RFC3339Nano, m["event.end"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if m["event.duration"] != float64(end.Sub(start)) {
+			t.Errorf("event.duration %v is not the difference of %s and %s", m["event.duration"], end, start)
+		}
+	}
+
+	template = []byte(`{"bytes.total":{{.bytes.total}},"bytes.in":{{.bytes.in}},"bytes.out":{{.bytes.out}}}`)
+	if _, err := NewGeneratorWithCustomTemplate(template, cfg, flds); err == nil || err.Error() != "template:1:16: field bytes.total: expression requires field bytes.in to precede it in the template" {
+		t.Errorf("Expected order error, got %v", err)
+	}
+
+	cfg, err = config.LoadConfigFromYaml([]byte("- name: bytes.total\n  expression: (bytes.in + "))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewGeneratorWithCustomTemplate([]byte(`{{.bytes.total}}`), cfg, flds); err == nil || !strings.Contains(err.Error(), "unexpected end of the expression") {
+		t.Errorf("Expected syntax error, got %v", err)
+	}
+
+	if _, err := config.LoadConfigFromYaml([]byte("- name: bytes.total\n  expression: bytes.in\n  enum: [\"1\"]")); err == nil {
+		t.Error("Expected error combining expression and enum")
+	}
+
+	cfg, err = config.LoadConfigFromYaml([]byte("- name: bytes.total\n  expression: bytes.in + host.name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keywordFlds := append([]Field{{Name: "host.name", Type: FieldTypeKeyword}}, flds...)
+	if _, err := NewGeneratorWithCustomTemplate([]byte(`{{.host.name}} {{.bytes.in}} {{.bytes.total}}`), cfg, keywordFlds); err == nil || err.Error() != "field bytes.total: expression bytes.in + host.name: field host.name of type keyword is neither a number nor a date" {
+		t.Errorf("Expected operand type error, got %v", err)
+	}
+
+	cfg, err = config.LoadConfigFromYaml([]byte("- name: bytes.in\n  value: n/a\n- name: bytes.total\n  expression: bytes.in + 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, state = makeGeneratorWithCustomTemplate(t, cfg, flds, []byte(`{{.bytes.in}} {{.bytes.total}}`))
+	if err := g.Emit(state, &bytes.Buffer{}); err == nil || err.Error() != "template:1:15: field bytes.total: value \"n/a\" of field bytes.in is neither a number nor a date" {
+		t.Errorf("Expected operand value error, got %v", err)
+	}
+}
+
+func Test_FieldDerivedFunctionsWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeLong},
+		{Name: "beta", Type: FieldTypeLong},
+		{Name: "gamma", Type: FieldTypeDouble},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  expression: 'uint(rand(1000)) * 2 + previous(\"alpha\")'\n- name: beta\n  expression: max(alpha - previous(alpha), 10) + min(abs(-3), int(2.7))\n- name: gamma\n  range: 10"), config.WithStrict())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":{{.alpha}},"beta":{{.beta}},"gamma":{{.gamma}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	var previous float64
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[float64](t, buf.Bytes())
+		increment := m["alpha"] - previous
+		if increment < 0 || increment > 1998 || math.Mod(increment, 2) != 0 {
+			t.Fatalf("alpha %v is not the alpha of the previous event %v plus an even number up to 1998", m["alpha"], previous)
+		}
+
+		if m["beta"] != math.Max(increment, 10)+2 {
+			t.Errorf("beta %v is not the max of %v and 10, plus 2", m["beta"], increment)
+		}
+
+		previous = m["alpha"]
+	}
+
+	for expression, expected := range map[string]string{
+		"rand(1, 2)":        "rand at offset 0 takes 1 arguments, got 2",
+		"alpha + sqrt(4)":   "unknown function sqrt at offset 8",
+		"previous()":        "previous at offset 0 takes the name of a field",
+		"max(alpha, 1":      "missing ) closing the call of max at offset 0",
+		"previous(\"alpha)": "invalid field name of previous at offset 9",
+	} {
+		cfg, err := config.LoadConfigFromYaml([]byte("- name: beta\n  expression: '" + expression + "'"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewGeneratorWithCustomTemplate([]byte(`{{.alpha}} {{.beta}}`), cfg, flds); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error %q for %s, got %v", expected, expression, err)
+		}
+	}
+}
+
+func Test_FieldCopyFromWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "source.ip", Type: FieldTypeIP},
+		{Name: "related.ip", Type: FieldTypeIP},
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "host.hostname", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: related.ip\n  copy_from: source.ip\n- name: host.name\n  enum: [\"web \\\"01\\\"\", \"web-02\"]\n- name: host.hostname\n  copy_from: host.name"), config.WithStrict())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"source.ip":"{{.source.ip}}","related.ip":["{{.related.ip}}"],"host.hostname":"{{.host.hostname}}","host.name":"{{.host.name}}"}`)
+	t.Logf("with template: %s", string(template))
+	if _, err := NewGeneratorWithCustomTemplate(template, cfg, flds); err == nil || err.Error() != "template:1:81: field host.hostname: copy_from requires field host.name to precede it in the template" {
+		t.Fatalf("Expected order error, got %v", err)
+	}
+
+	template = []byte(`{"source.ip":"{{.source.ip}}","related.ip":["{{.related.ip}}"],"host.name":"{{.host.name}}","host.hostname":"{{.host.hostname}}"}`)
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[interface{}](t, buf.Bytes())
+		if related := m["related.ip"].([]interface{}); len(related) != 1 || related[0] != m["source.ip"] {
+			t.Errorf("related.ip %v is not a copy of source.ip %v", m["related.ip"], m["source.ip"])
+		}
+
+		if m["host.hostname"] != m["host.name"] {
+			t.Errorf("host.hostname %v is not a copy of host.name %v", m["host.hostname"], m["host.name"])
+		}
+	}
+
+	// the copies of null values are empty
+	cfg, err = config.LoadConfigFromYaml([]byte("- name: host.name\n  null_percentage: 50\n- name: host.hostname\n  copy_from: host.name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, state = makeGeneratorWithCustomTemplate(t, cfg, flds, []byte(`{"host.name":"{{.host.name}}","host.hostname":"{{.host.hostname}}"}`))
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[interface{}](t, buf.Bytes())
+		if m["host.name"] != m["host.hostname"] && (m["host.name"] != nil || m["host.hostname"] != "") {
+			t.Errorf("host.hostname %v is not a copy of host.name %v", m["host.hostname"], m["host.name"])
+		}
+	}
+
+	for _, yaml := range []string{
+		"- name: host.hostname\n  copy_from: host.hostname",
+		"- name: host.hostname\n  copy_from: host.name\n  enum: [\"a\"]",
+	} {
+		if _, err := config.LoadConfigFromYaml([]byte(yaml)); err == nil {
+			t.Errorf("Expected error for config %s", yaml)
+		}
+	}
+}
+
+func Test_FieldGeoPointFormatWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeGeoPoint},
+		{Name: "beta", Type: FieldTypeGeoPoint},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  geo_format: object\n- name: beta\n  geo_clusters:\n    - city: Tokyo\n      radius: 5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":{{.alpha}},"beta":"{{.beta}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[interface{}](t, buf.Bytes())
+		alpha, ok := m["alpha"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("expected alpha to be an object, got %v", m["alpha"])
+		}
+
+		if lat, lon := alpha["lat"].(float64), alpha["lon"].(float64); lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			t.Errorf("expected alpha to be a valid point, got %v", alpha)
+		}
+
+		var lat, lon float64
+		if _, err := fmt.Sscanf(m["beta"].(string), "%f,%f", &lat, &lon); err != nil {
+			t.Fatalf("expected beta to be a lat,lon string, got %s", m["beta"])
+		}
+
+		if lat < 35.6 || lat > 35.75 || lon < 139.55 || lon > 139.75 {
+			t.Errorf("expected beta around Tokyo, got %s", m["beta"])
+		}
+	}
+
+	cfg, err = config.LoadConfigFromYaml([]byte("- name: alpha\n  geo_clusters:\n    - city: atlantis"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewGeneratorWithCustomTemplate(template, cfg, flds); err == nil || !strings.Contains(err.Error(), "unknown city atlantis") {
+		t.Errorf("expected unknown city error, got %v", err)
+	}
+}
+
+func Test_FieldArrayWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+		{Name: "gamma", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"a\\\"b\"]\n  array_min: 3\n  array_max: 3\n- name: beta\n  array_max: 4\n  null_percentage: 50\n- name: gamma\n  array_min: 1\n  array_max: 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha": "{{.alpha}}", "beta": {{.beta}}, "gamma": "{{.gamma}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	var nulls int
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[interface{}](t, buf.Bytes())
+		if alpha := fmt.Sprint(m["alpha"]); alpha != `[a"b a"b a"b]` {
+			t.Errorf("unexpected alpha %s", alpha)
+		}
+
+		if m["beta"] == nil {
+			nulls++
+		} else if beta, ok := m["beta"].([]interface{}); !ok || len(beta) > 4 {
+			t.Errorf("expected at most 4 values of beta, got %v", m["beta"])
+		}
+
+		if gamma, ok := m["gamma"].([]interface{}); !ok || len(gamma) != 1 {
+			t.Errorf("expected 1 value of gamma, got %v", m["gamma"])
+		}
+	}
+
+	if nulls == 0 || nulls == 100 {
+		t.Errorf("expected beta to be null in about half the events, got %d", nulls)
+	}
+
+	if _, err := NewGeneratorWithCustomTemplate([]byte(`{{.gamma}}`), cfg, flds); err == nil || !strings.Contains(err.Error(), "array_min and array_max require") {
+		t.Errorf("expected error for array field not being a JSON member, got %v", err)
+	}
+}
+
+func Test_TemplateErrorWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeKeyword},
+		{Name: "gamma", Type: FieldTypeLong},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte(`- name: beta
+  rules:
+    - when:
+        field: alpha
+        equals: a
+      then:
+        value: b
+- name: gamma
+  array_max: 2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte("{\n  \"beta\": \"{{.beta}}\",\n  \"alpha\": \"{{.alpha}}\"\n}")
+	if _, err := NewGeneratorWithCustomTemplate(template, cfg, flds); err == nil || err.Error() != "template:2:12: field beta: rules require field alpha to precede it in the template" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	template = []byte("{\n  \"gamma\": {{.gamma}},\n  \"again\": {{.gamma}}\n}")
+	if _, err := NewGeneratorWithCustomTemplate(template, cfg, flds); err == nil || err.Error() != "template:3:12: field gamma: array_min and array_max require the field to be referenced once in the template" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func Test_RepeatBlockWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "dns.question.name", Type: FieldTypeKeyword},
+		{Name: "dns.answers.data", Type: FieldTypeIP},
+		{Name: "dns.answers.ttl", Type: FieldTypeLong},
+		{Name: "process.args", Type: FieldTypeIP},
+	}
+
+	template := []byte(`{"dns":{"question":{"name":"{{.dns.question.name}}"},"answers":[{{range .dns.answers count=1-3}}{"data":"{{.dns.answers.data}}","ttl":{{.dns.answers.ttl}}}{{end}}]},"process":{"args":[{{range count=2}}"{{.process.args}}"{{end}}]},"message":"{{range count=3 sep=" "}}{{.dns.answers.data}}{{end}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, config.Config{}, flds, template)
+
+	counts := make(map[int]int)
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		var event struct {
+			DNS struct {
+				Answers []struct {
+					Data string `json:"data"`
+					TTL  *int64 `json:"ttl"`
+				} `json:"answers"`
+			} `json:"dns"`
+			Process struct {
+				Args []string `json:"args"`
+			} `json:"process"`
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+			t.Fatalf("unexpected event %s: %v", buf.String(), err)
+		}
+
+		answers := event.DNS.Answers
+		counts[len(answers)]++
+		if len(answers) < 1 || len(answers) > 3 || len(answers[0].Data) == 0 || answers[0].TTL == nil {
+			t.Errorf("expected 1 to 3 answers, got %s", buf.String())
+		}
+
+		if len(answers) > 1 && answers[0].Data == answers[1].Data {
+			t.Errorf("expected each answer to get its own values, got %s", buf.String())
+		}
+
+		if len(event.Process.Args) != 2 || event.Process.Args[0] == event.Process.Args[1] {
+			t.Errorf("expected 2 different args, got %s", buf.String())
+		}
+
+		if len(strings.Split(event.Message, " ")) != 3 {
+			t.Errorf("expected 3 values separated by spaces in the message, got %s", buf.String())
+		}
+	}
+
+	if len(counts) != 3 {
+		t.Errorf("expected the count of answers to vary from 1 to 3, got %v", counts)
+	}
+
+	referenced := make([]string, 0)
+	for _, field := range g.Fields() {
+		referenced = append(referenced, field.Field.Name)
+	}
+
+	if strings.Join(referenced, ",") != "dns.question.name,dns.answers.data,dns.answers.ttl,process.args" {
+		t.Errorf("unexpected referenced fields %v", referenced)
+	}
+
+	for template, expected := range map[string]string{
+		"[{{range count=3}}{{.process.args}}":                              "template:1:2: range block not closed by {{end}}",
+		"[{{range count=3":                                                 "template:1:2: range block not closed by }}",
+		"[{{range count=3-1}}{{.process.args}}{{end}}]":                    "template:1:2: range count must be N or MIN-MAX, MAX not less than MIN, got 3-1",
+		"[{{range times=3}}{{.process.args}}{{end}}]":                      "template:1:2: unknown range argument times=3, must be count=N or count=MIN-MAX and sep=\"...\"",
+		"[{{range .process.args}}{{.process.args}}{{end}}]":                "template:1:2: range requires a count, like count=3 or count=1-5",
+		"{\n  \"args\": [{{range count=1}}\n{{.unknown | upper}}{{end}}]}": "template:3:1: field unknown: function \"upper\" not defined, the values can be piped to jsonEscape and xmlEscape",
+	} {
+		if _, err := NewGeneratorWithCustomTemplate([]byte(template), config.Config{}, flds); err == nil || err.Error() != expected {
+			t.Errorf("expected error %s for template %s, got %v", expected, template, err)
+		}
+	}
+}
+
+func Test_FieldMemoizedWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: beta\n  reroll: true"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":"{{.alpha}}","beta":{{.beta}},"message":"{{.alpha}} {{.beta}}","alpha_copy":"{{.alpha}}","beta_copy":{{.beta}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	var rerolled int
+	nSpins := 100
+	for i := 0; i < nSpins; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		if m["alpha"] != m["alpha_copy"] || !strings.HasPrefix(m["message"].(string), m["alpha"].(string)+" ") {
+			t.Errorf("expected the same value of alpha for all its references, got %s", buf.String())
+		}
+
+		if m["beta"] != m["beta_copy"] {
+			rerolled++
+		}
+	}
+
+	if rerolled == 0 {
+		t.Errorf("expected beta to be rerolled for each reference")
+	}
+}
+
+func Test_EmitAllocsWithCustomTemplate(t *testing.T) {
+	cfg, err := config.LoadConfigFromYaml([]byte(`- name: InterfaceID
+  cardinality: 10
+- name: Action
+  enum: ["ACCEPT", "REJECT"]
+- name: Ratio
+  precision: 2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flds := Fields{
+		{Name: "Start", Type: FieldTypeDate},
+		{Name: "SrcAddr", Type: FieldTypeIP},
+		{Name: "Bytes", Type: FieldTypeLong},
+		{Name: "Score", Type: FieldTypeDouble},
+		{Name: "Ratio", Type: FieldTypeDouble},
+		{Name: "InterfaceID", Type: FieldTypeKeyword},
+		{Name: "Action", Type: FieldTypeKeyword},
+		{Name: "Host", Type: FieldTypeKeyword},
+		{Name: "Allowed", Type: FieldTypeBool},
+		{Name: "Location", Type: FieldTypeGeoPoint},
+		{Name: "Message", Type: "text"},
+	}
+
+	template := []byte(`{"Start":"{{.Start}}","SrcAddr":"{{.SrcAddr}}","Bytes":{{.Bytes}},"Score":{{.Score}},"Ratio":{{.Ratio}},"InterfaceID":"{{.InterfaceID}}","Action":"{{.Action}}","Host":"{{.Host}}","Allowed":{{.Allowed}},"Location":"{{.Location}}","Message":"{{.Message}}"}`)
+	g, err := NewGeneratorWithCustomTemplate(template, cfg, flds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	state := NewGenState()
+	emit := func() {
+		buf.Reset()
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the pools of the fields with cardinality are filled by the first events
+	for i := 0; i < 100; i++ {
+		emit()
+	}
+
+	if allocs := testing.AllocsPerRun(1000, emit); allocs > 0 {
+		t.Errorf("expected no allocations per event, got %.1f", allocs)
+	}
+}
+
+func Test_FieldPreviousWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "event.sequence", Type: FieldTypeLong},
+		{Name: "host.name", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: event.sequence\n  expression: previous(event.sequence) + 1\n- name: host.name\n  enum: [\"web \\\"01\\\"\", \"web-02\"]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"sequence":{{.event.sequence}},"previous":"{{.previous.event.sequence}}","host":"{{.host.name}}","previous_host":"{{.previous.host.name}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, flds, template)
+
+	var previous map[string]interface{}
+	for i := 0; i < 10; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		// the values of the first event have no previous ones, they are empty
+		if i == 0 && (!strings.HasPrefix(buf.String(), `{"sequence":1,"previous":"",`) || !strings.HasSuffix(buf.String(), `"previous_host":""}`)) {
+			t.Errorf("unexpected first event %s", buf.String())
+		}
+
+		m := unmarshalJSONT[interface{}](t, buf.Bytes())
+		if m["sequence"] != float64(i+1) {
+			t.Errorf("sequence %v of event %d is not %d", m["sequence"], i, i+1)
+		}
+
+		if i > 0 && (m["previous"] != fmt.Sprint(previous["sequence"]) || m["previous_host"] != previous["host"]) {
+			t.Errorf("event %s does not reference the values of the previous one %v", buf.String(), previous)
+		}
+
+		previous = m
+	}
+
+	if fields := g.Fields(); len(fields) != 2 {
+		t.Errorf("expected the references to the previous event not to be referenced fields, got %v", fields)
+	}
+}
@@ -0,0 +1,396 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// structuredPlaceholderRegex matches the references to a field in the values of a structured template
+var structuredPlaceholderRegex = regexp.MustCompile(`{{\s*\.([^}\s]+)\s*}}`)
+
+// structuredEmitF writes a value of the event, it returns false if the value is omitted.
+type structuredEmitF func(gen *GeneratorWithStructuredTemplate, state *GenState, buf *bytes.Buffer) (bool, error)
+
+// GeneratorWithStructuredTemplate generates events from a YAML or JSON template, whose string values can reference
+// the fields with placeholders: the events are serialized as JSON, taking care of quoting and escaping the values.
+// A value made of a placeholder only takes the type of the generated value, while the placeholders in a longer
+// string are interpolated into it. Null and omitted values of a field are rendered as null and as a missing member.
+type GeneratorWithStructuredTemplate struct {
+	emit structuredEmitF
+
+	fieldMap       map[string]EmitF
+	tracedFieldMap map[string]EmitF
+	sparse         map[string]ConfigField
+	// memoized are the fields referenced more than once, generating the same value for all their references
+	memoized map[string]struct{}
+	// resolve resolves the aliases set by the config to the names of their fields
+	resolve func(alias string) string
+	// previous are the references to the values of the previous event, with the names of their fields
+	previous map[string]string
+
+	template []byte
+	fields   []ReferencedField
+}
+
+func NewGeneratorWithStructuredTemplate(tpl []byte, cfg Config, fields Fields) (*GeneratorWithStructuredTemplate, error) {
+	cfg, fields = RenameFields(cfg, fields)
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(tpl, &doc); err != nil {
+		return nil, newYAMLTemplateError(err)
+	}
+
+	if doc.Kind == 0 {
+		return nil, &TemplateError{Err: errors.New("empty")}
+	}
+
+	gen := &GeneratorWithStructuredTemplate{template: tpl, resolve: cfg.Resolve}
+
+	var orderedFields []string
+	emit, err := compileStructuredNode(&doc, &orderedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	orderedFields = resolveFieldNames(cfg, orderedFields)
+
+	if err := checkRulesOrder(tpl, cfg, orderedFields); err != nil {
+		return nil, err
+	}
+
+	if err := checkExpressionOperands(cfg, fields); err != nil {
+		return nil, err
+	}
+
+	// Preprocess the fields, generating appropriate emit functions
+	fieldMap := make(map[string]EmitF)
+	sparse := make(map[string]ConfigField)
+	for _, field := range fields {
+		if err := bindField(cfg, field, fieldMap, nil, nil, true); err != nil {
+			return nil, err
+		}
+
+		if err := bindRulesWithReturn(cfg, field, fieldMap); err != nil {
+			return nil, err
+		}
+
+		fieldCfg, _ := cfg.GetField(field.Name)
+		if isArrayField(fieldCfg, field) {
+			fieldMap[field.Name] = makeArrayStubWithReturn(fieldCfg, fieldMap[field.Name])
+		}
+
+		if isSparse(fieldCfg) {
+			sparse[field.Name] = fieldCfg
+		}
+	}
+
+	for fieldName := range ruleConditionFields(cfg, orderedFields) {
+		if bindF, ok := fieldMap[fieldName]; ok {
+			fieldMap[fieldName] = makeConditionStubWithReturn(fieldName, bindF)
+		}
+	}
+
+	previous := previousReferences(cfg, fields, uniqueFieldNames(orderedFields))
+	recorded := conditionFields(cfg, orderedFields)
+	for _, fieldName := range previous {
+		recorded[fieldName] = struct{}{}
+	}
+
+	for fieldName := range recorded {
+		if bindF, ok := fieldMap[fieldName]; ok {
+			fieldMap[fieldName] = makeRecordStubWithReturn(fieldName, bindF)
+		}
+	}
+
+	// tracedFieldMap is used instead of fieldMap when tracing is enabled
+	emitters := traceEmitters(cfg, fields, orderedFields)
+	tracedFieldMap := make(map[string]EmitF, len(fieldMap))
+	for fieldName, bindF := range fieldMap {
+		tracedFieldMap[fieldName] = makeTraceStubWithReturn(fieldName, emitters[fieldName], bindF)
+	}
+
+	gen.emit = emit
+	gen.fieldMap = fieldMap
+	gen.tracedFieldMap = tracedFieldMap
+	gen.sparse = sparse
+	gen.memoized = memoizedFieldNames(cfg, orderedFields)
+	gen.previous = previous
+	gen.fields = referencedFields(cfg, fields, templateFieldNames(orderedFields, previous))
+
+	return gen, nil
+}
+
+// compileStructuredNode returns the function writing node as JSON, appending the fields it references to orderedFields.
+func compileStructuredNode(node *yaml.Node, orderedFields *[]string) (structuredEmitF, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		return compileStructuredNode(node.Content[0], orderedFields)
+	case yaml.AliasNode:
+		return compileStructuredNode(node.Alias, orderedFields)
+	case yaml.MappingNode:
+		keys := make([][]byte, 0, len(node.Content)/2)
+		values := make([]structuredEmitF, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind != yaml.ScalarNode {
+				return nil, &TemplateError{Line: key.Line, Column: key.Column, Err: errors.New(`keys must be strings, quote the values made of a placeholder, like "{{.field}}"`)}
+			}
+
+			value, err := compileStructuredNode(node.Content[i+1], orderedFields)
+			if err != nil {
+				return nil, err
+			}
+
+			keys = append(keys, append(marshalStructured(key.Value), ':'))
+			values = append(values, value)
+		}
+
+		return func(gen *GeneratorWithStructuredTemplate, state *GenState, buf *bytes.Buffer) (bool, error) {
+			buf.WriteByte('{')
+			written := 0
+			for i, value := range values {
+				offset := buf.Len()
+				if written > 0 {
+					buf.WriteByte(',')
+				}
+
+				buf.Write(keys[i])
+				ok, err := value(gen, state, buf)
+				if err != nil {
+					return false, err
+				}
+
+				if !ok {
+					buf.Truncate(offset)
+					continue
+				}
+
+				written++
+			}
+
+			buf.WriteByte('}')
+			return true, nil
+		}, nil
+	case yaml.SequenceNode:
+		values := make([]structuredEmitF, 0, len(node.Content))
+		for _, item := range node.Content {
+			value, err := compileStructuredNode(item, orderedFields)
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, value)
+		}
+
+		return func(gen *GeneratorWithStructuredTemplate, state *GenState, buf *bytes.Buffer) (bool, error) {
+			buf.WriteByte('[')
+			written := 0
+			for _, value := range values {
+				offset := buf.Len()
+				if written > 0 {
+					buf.WriteByte(',')
+				}
+
+				ok, err := value(gen, state, buf)
+				if err != nil {
+					return false, err
+				}
+
+				if !ok {
+					buf.Truncate(offset)
+					continue
+				}
+
+				written++
+			}
+
+			buf.WriteByte(']')
+			return true, nil
+		}, nil
+	}
+
+	return compileStructuredScalar(node, orderedFields)
+}
+
+func compileStructuredScalar(node *yaml.Node, orderedFields *[]string) (structuredEmitF, error) {
+	locs := structuredPlaceholderRegex.FindAllStringSubmatchIndex(node.Value, -1)
+	if node.Tag != "!!str" || len(locs) == 0 {
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return nil, &TemplateError{Line: node.Line, Column: node.Column, Err: err}
+		}
+
+		static := marshalStructured(value)
+		return func(gen *GeneratorWithStructuredTemplate, state *GenState, buf *bytes.Buffer) (bool, error) {
+			buf.Write(static)
+			return true, nil
+		}, nil
+	}
+
+	// a value made of a placeholder only keeps the type of the generated value
+	if len(locs) == 1 && locs[0][0] == 0 && locs[0][1] == len(node.Value) {
+		fieldName := node.Value[locs[0][2]:locs[0][3]]
+		*orderedFields = append(*orderedFields, fieldName)
+
+		return func(gen *GeneratorWithStructuredTemplate, state *GenState, buf *bytes.Buffer) (bool, error) {
+			value, sparse, err := gen.value(state, fieldName)
+			if err != nil {
+				return false, &TemplateError{Line: node.Line, Column: node.Column, Field: fieldName, Err: err}
+			}
+
+			switch sparse {
+			case sparseOmit:
+				return false, nil
+			case sparseNull:
+				buf.WriteString("null")
+			default:
+				buf.Write(marshalStructured(value))
+			}
+
+			return true, nil
+		}, nil
+	}
+
+	// the placeholders in a longer string are interpolated, null and omitted values as empty strings
+	var literals, fieldNames []string
+	previous := 0
+	for _, loc := range locs {
+		literals = append(literals, node.Value[previous:loc[0]])
+		fieldNames = append(fieldNames, node.Value[loc[2]:loc[3]])
+		previous = loc[1]
+	}
+
+	trailing := node.Value[previous:]
+	*orderedFields = append(*orderedFields, fieldNames...)
+
+	return func(gen *GeneratorWithStructuredTemplate, state *GenState, buf *bytes.Buffer) (bool, error) {
+		var s strings.Builder
+		for i, fieldName := range fieldNames {
+			s.WriteString(literals[i])
+
+			value, sparse, err := gen.value(state, fieldName)
+			if err != nil {
+				return false, &TemplateError{Line: node.Line, Column: node.Column, Field: fieldName, Err: err}
+			}
+
+			if sparse == sparseValue && value != nil {
+				s.WriteString(formatStructured(value))
+			}
+		}
+
+		s.WriteString(trailing)
+		buf.Write(marshalStructured(s.String()))
+		return true, nil
+	}, nil
+}
+
+// structuredValue is the value memoized for a field, or whether it is null or omitted.
+type structuredValue struct {
+	value  interface{}
+	sparse int
+}
+
+// value returns the value generated for fieldName, or whether it is null or omitted.
+// The value of a field not in the fields definition is an empty string.
+func (gen *GeneratorWithStructuredTemplate) value(state *GenState, fieldName string) (interface{}, int, error) {
+	fieldName = gen.resolve(fieldName)
+	_, memoized := gen.memoized[fieldName]
+	if memoized {
+		if memo, ok := state.memoValues[fieldName].(structuredValue); ok {
+			return memo.value, memo.sparse, nil
+		}
+	}
+
+	value, sparse, err := gen.generate(state, fieldName)
+	if err == nil && memoized {
+		state.memoValues[fieldName] = structuredValue{value: value, sparse: sparse}
+	}
+
+	return value, sparse, err
+}
+
+// generate returns a new value for fieldName, or whether it is null or omitted.
+func (gen *GeneratorWithStructuredTemplate) generate(state *GenState, fieldName string) (interface{}, int, error) {
+	if fieldCfg, ok := gen.sparse[fieldName]; ok {
+		if sparse := drawSparse(fieldCfg); sparse != sparseValue {
+			return nil, sparse, nil
+		}
+	}
+
+	bindFs := gen.fieldMap
+	if state.tracing {
+		bindFs = gen.tracedFieldMap
+	}
+
+	bindF, ok := bindFs[fieldName]
+	if !ok {
+		if previousFieldName, ok := gen.previous[fieldName]; ok {
+			return state.previousValue(previousFieldName), sparseValue, nil
+		}
+
+		return "", sparseValue, nil
+	}
+
+	value, err := bindF(state, nil)
+	return value, sparseValue, err
+}
+
+// formatStructured formats a generated value as text, dates with the layout of the events generated without a template.
+func formatStructured(value interface{}) string {
+	if t, ok := value.(time.Time); ok {
+		return t.Format(FieldTypeTimeLayout)
+	}
+
+	return fmt.Sprint(value)
+}
+
+// marshalStructured serializes value as JSON, without escaping the HTML characters.
+func marshalStructured(value interface{}) []byte {
+	if t, ok := value.(time.Time); ok {
+		value = t.Format(FieldTypeTimeLayout)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		// values that cannot be serialized as they are, like maps with non string keys, are serialized as text
+		return marshalStructured(fmt.Sprint(value))
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+func (*GeneratorWithStructuredTemplate) Close() error {
+	return nil
+}
+
+func (gen *GeneratorWithStructuredTemplate) Fields() []ReferencedField {
+	return gen.fields
+}
+
+func (gen *GeneratorWithStructuredTemplate) Template() []byte {
+	return gen.template
+}
+
+func (gen *GeneratorWithStructuredTemplate) Emit(state *GenState, buf *bytes.Buffer) error {
+	state.resetEventValues()
+	if _, err := gen.emit(gen, state, buf); err != nil {
+		return err
+	}
+
+	state.counter += 1
+
+	return nil
+}
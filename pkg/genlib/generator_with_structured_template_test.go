@@ -0,0 +1,291 @@
+package genlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+func Test_YAMLWithStructuredTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+		{Name: "gamma", Type: FieldTypeBool},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"quote \\\" and <tag>\"]\n- name: beta\n  value: 42"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`message: "{{.alpha}} is {{ .beta }}"
+nested:
+  alpha: "{{.alpha}}"
+  beta: "{{.beta}}"
+list: ["static", "{{.gamma}}", 1.5]
+`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithStructuredTemplate(t, cfg, flds, template)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var event struct {
+		Message string `json:"message"`
+		Nested  struct {
+			Alpha string `json:"alpha"`
+			Beta  int    `json:"beta"`
+		} `json:"nested"`
+		List []interface{} `json:"list"`
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatalf("event %s is not valid JSON: %v", buf.String(), err)
+	}
+
+	if !strings.HasPrefix(buf.String(), `{"message":`) {
+		t.Errorf("event %s does not keep the order of the template", buf.String())
+	}
+
+	if event.Message != `quote " and <tag> is 42` {
+		t.Errorf("message %s not interpolated", event.Message)
+	}
+
+	if event.Nested.Alpha != `quote " and <tag>` || event.Nested.Beta != 42 {
+		t.Errorf("nested %+v not generated", event.Nested)
+	}
+
+	if len(event.List) != 3 || event.List[0] != "static" || event.List[2] != 1.5 {
+		t.Errorf("list %v not generated", event.List)
+	}
+
+	if _, ok := event.List[1].(bool); !ok {
+		t.Errorf("list item %v not a bool", event.List[1])
+	}
+}
+
+func Test_JSONWithStructuredTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeDouble},
+	}
+
+	template := []byte(`{"alpha": "{{.alpha}}", "beta": "{{.beta}}", "undefined": "{{.delta}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithStructuredTemplate(t, Config{}, flds, template)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("event %s is not valid JSON: %v", buf.String(), err)
+	}
+
+	if _, ok := m["alpha"].(string); !ok {
+		t.Errorf("alpha %v not a string", m["alpha"])
+	}
+
+	if _, ok := m["beta"].(float64); !ok {
+		t.Errorf("beta %v not a number", m["beta"])
+	}
+
+	if m["undefined"] != "" {
+		t.Errorf("undefined %v not empty", m["undefined"])
+	}
+}
+
+func Test_SparseWithStructuredTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  null_percentage: 100\n- name: beta\n  omit_percentage: 100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha": "{{.alpha}}", "beta": "{{.beta}}", "list": ["{{.beta}}"], "message": "[{{.beta}}]"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithStructuredTemplate(t, cfg, flds, template)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != `{"alpha":null,"list":[],"message":"[]"}` {
+		t.Errorf("unexpected event %s", buf.String())
+	}
+}
+
+func Test_ArrayWithStructuredTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"a\\\"b\"]\n  array_min: 2\n  array_max: 2\n- name: beta\n  array_max: 3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha": "{{.alpha}}", "beta": "{{.beta}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithStructuredTemplate(t, cfg, flds, template)
+
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[[]interface{}](t, buf.Bytes())
+		if !reflect.DeepEqual(m["alpha"], []interface{}{`a"b`, `a"b`}) {
+			t.Errorf("unexpected alpha %v", m["alpha"])
+		}
+
+		if len(m["beta"]) > 3 {
+			t.Errorf("expected at most 3 values of beta, got %v", m["beta"])
+		}
+	}
+}
+
+func Test_InvalidWithStructuredTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+	}
+
+	for _, template := range []string{"alpha: {{.alpha}}", `{"alpha": "{{.alpha}}"`, ""} {
+		if _, err := NewGeneratorWithStructuredTemplate([]byte(template), Config{}, flds); err == nil {
+			t.Errorf("expected error for template %q", template)
+		}
+	}
+}
+
+func Test_FieldRenameAndAliasWithStructuredTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "source.ip", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: source.ip\n  rename: src_ip\n  alias: [client_ip]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"src_ip": "{{.src_ip}}", "client": "ip {{.client_ip}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithStructuredTemplate(t, cfg, flds, template)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var event map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatalf("event %s is not valid JSON: %v", buf.String(), err)
+	}
+
+	if len(event["src_ip"]) == 0 || event["client"] != "ip "+event["src_ip"] {
+		t.Errorf("unexpected event %s", buf.String())
+	}
+}
+
+func makeGeneratorWithStructuredTemplate(t *testing.T, cfg Config, fields Fields, template []byte) (Generator, *GenState) {
+	g, err := NewGeneratorWithStructuredTemplate(template, cfg, fields)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g, NewGenState()
+}
+
+func Test_TemplateErrorWithStructuredTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+	}
+
+	for template, expected := range map[string]string{
+		"alpha: x\n  beta: \"{{.alpha}}\"\n":       "template:2: not a valid YAML or JSON document: mapping values are not allowed in this context",
+		"alpha: \"{{.alpha}}\"\nbeta: {{.beta}}\n": "template:2:8: keys must be strings, quote the values made of a placeholder, like \"{{.field}}\"",
+	} {
+		if _, err := NewGeneratorWithStructuredTemplate([]byte(template), Config{}, flds); err == nil || err.Error() != expected {
+			t.Errorf("unexpected error %v for template %q", err, template)
+		}
+	}
+}
+
+func Test_FieldMemoizedWithStructuredTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  null_percentage: 50\n- name: beta\n  reroll: true"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha": "{{.alpha}}", "beta": "{{.beta}}", "copy": {"alpha": "{{.alpha}}", "beta": "{{.beta}}"}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithStructuredTemplate(t, cfg, flds, template)
+
+	var rerolled int
+	nSpins := 100
+	for i := 0; i < nSpins; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		copied := m["copy"].(map[string]interface{})
+		if m["alpha"] != copied["alpha"] {
+			t.Errorf("expected the same value of alpha for all its references, got %s", buf.String())
+		}
+
+		if m["beta"] != copied["beta"] {
+			rerolled++
+		}
+	}
+
+	if rerolled == 0 {
+		t.Errorf("expected beta to be rerolled for each reference")
+	}
+}
+
+func Test_FieldPreviousWithStructuredTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "event.sequence", Type: FieldTypeLong},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: event.sequence\n  expression: previous(event.sequence) + 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte("sequence: \"{{.event.sequence}}\"\nprevious: \"{{.previous.event.sequence}}\"\n")
+	g, state := makeGeneratorWithStructuredTemplate(t, cfg, flds, template)
+
+	for i, expected := range []string{`{"sequence":1,"previous":""}`, `{"sequence":2,"previous":1}`, `{"sequence":3,"previous":2}`} {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if buf.String() != expected {
+			t.Errorf("expected event %d to be %s, got %s", i, expected, buf.String())
+		}
+	}
+}
@@ -6,64 +6,192 @@ package genlib
 
 import (
 	"bytes"
-	"github.com/Masterminds/sprig/v3"
+	"fmt"
 	"text/template"
-	"time"
+	"text/template/parse"
+
+	"github.com/Masterminds/sprig/v3"
 )
 
 // GeneratorWithTextTemplate
 type GeneratorWithTextTemplate struct {
 	tpl   *template.Template
 	state *GenState
+
+	template []byte
+	fields   []ReferencedField
 }
 
 func NewGeneratorWithTextTemplate(tpl []byte, cfg Config, fields Fields) (*GeneratorWithTextTemplate, error) {
+	cfg, fields = RenameFields(cfg, fields)
+
+	if err := checkExpressionOperands(cfg, fields); err != nil {
+		return nil, err
+	}
+
 	// Preprocess the fields, generating appropriate emit functions
 	fieldMap := make(map[string]EmitF)
 	for _, field := range fields {
 		if err := bindField(cfg, field, fieldMap, nil, nil, true); err != nil {
 			return nil, err
 		}
+
+		if err := bindRulesWithReturn(cfg, field, fieldMap); err != nil {
+			return nil, err
+		}
+
+		fieldCfg, _ := cfg.GetField(field.Name)
+		if isArrayField(fieldCfg, field) {
+			fieldMap[field.Name] = makeArrayStubWithReturn(fieldCfg, fieldMap[field.Name])
+		}
+
+		if isSparse(fieldCfg) {
+			bindSparseWithReturn(fieldCfg, field, fieldMap)
+		}
+	}
+
+	fieldNames := make([]string, 0, len(fieldMap))
+	for fieldName := range fieldMap {
+		fieldNames = append(fieldNames, fieldName)
+	}
+
+	// the pools of fromPool draw new values from the fields, that do not count as the values of the fields in the event
+	pools := make(map[string]EmitF, len(fieldMap))
+	for fieldName, bindF := range fieldMap {
+		pools[fieldName] = bindF
+	}
+
+	// the rules check the values as generated, they are recorded before the escape stubs
+	for fieldName := range ruleConditionFields(cfg, fieldNames) {
+		if bindF, ok := fieldMap[fieldName]; ok {
+			fieldMap[fieldName] = makeConditionStubWithReturn(fieldName, bindF)
+		}
+	}
+
+	for _, fieldName := range escapedFieldNames(cfg, fields, fieldNames) {
+		fieldCfg, _ := cfg.GetField(fieldName)
+		fieldMap[fieldName] = makeEscapeStubWithReturn(fieldCfg.Escape == EscapeXML, fieldMap[fieldName])
+		pools[fieldName] = makeEscapeStubWithReturn(fieldCfg.Escape == EscapeXML, pools[fieldName])
+	}
+
+	for fieldName := range conditionFields(cfg, fieldNames) {
+		if bindF, ok := fieldMap[fieldName]; ok {
+			fieldMap[fieldName] = makeRecordStubWithReturn(fieldName, bindF)
+		}
+	}
+
+	// the fields generate the same value for all their references in an event, unless they reroll
+	for fieldName, bindF := range fieldMap {
+		if fieldCfg, _ := cfg.GetField(fieldName); !fieldCfg.Reroll {
+			fieldMap[fieldName] = makeMemoStubWithReturn(fieldName, bindF)
+		}
+	}
+
+	// tracedFieldMap is used instead of fieldMap when tracing is enabled
+	emitters := traceEmitters(cfg, fields, fieldNames)
+	tracedFieldMap := make(map[string]EmitF, len(fieldMap))
+	for fieldName, bindF := range fieldMap {
+		tracedFieldMap[fieldName] = makeTraceStubWithReturn(fieldName, emitters[fieldName], bindF)
 	}
 
 	t := template.New("generator")
 	t = t.Option("missingkey=error")
 
-	state := NewGenState()
+	gen := &GeneratorWithTextTemplate{state: NewGenState()}
 
 	templateFns := sprig.HermeticTxtFuncMap()
+	addTextTemplateFuncs(templateFns, gen, fields)
 
-	templateFns["timeDuration"] = func(duration int64) time.Duration {
-		return time.Duration(duration)
-	}
+	generate := func(field string) (interface{}, error) {
+		bindFs := fieldMap
+		if gen.state.tracing {
+			bindFs = tracedFieldMap
+		}
 
-	templateFns["generate"] = func(field string) interface{} {
-		bindF, ok := fieldMap[field]
+		bindF, ok := bindFs[cfg.Resolve(field)]
 		if !ok {
-			return ""
+			return "", nil
 		}
 
-		value, err := bindF(state, nil)
-		if err != nil {
-			return ""
+		return bindF(gen.state, nil)
+	}
+
+	templateFns["generate"] = generate
+
+	// generateRaw is generate without the default JSON escaping of the value, for the escape functions to replace it
+	escaped := make(map[string]bool, len(fieldNames))
+	for _, fieldName := range fieldNames {
+		escaped[fieldName] = isJSONEscaped(cfg, fields, fieldName)
+	}
+
+	templateFns["generateRaw"] = func(field string) (interface{}, error) {
+		value, err := generate(field)
+		s, ok := value.(string)
+		if err != nil || !ok || !escaped[cfg.Resolve(field)] || gen.state.rawValues {
+			return value, err
 		}
-		return value
+
+		var buf, tmp bytes.Buffer
+		buf.WriteString(s)
+		unescapeJSONString(&buf, 0, &tmp)
+		return buf.String(), nil
+	}
+
+	// previous returns the value of a field in the previous event, recorded for the fields it is called for
+	templateFns["previous"] = func(field string) interface{} {
+		return gen.state.previousValue(cfg.Resolve(field))
+	}
+
+	templateFns["fromPool"] = func(pool string) (interface{}, error) {
+		bindF, ok := pools[pool]
+		if !ok {
+			return nil, fmt.Errorf("pool %s not found in the config nor in the fields definition", pool)
+		}
+
+		return bindF(gen.state, nil)
 	}
 
 	parsedTpl, err := t.Funcs(templateFns).Parse(string(tpl))
 	if err != nil {
+		return nil, newTextTemplateError(tpl, err)
+	}
+
+	if err := bindPools(tpl, parsedTpl, cfg, pools); err != nil {
 		return nil, err
 	}
 
-	return &GeneratorWithTextTemplate{tpl: parsedTpl, state: state}, nil
+	walkStringCalls(parsedTpl.Tree, "previous", func(fieldName string, _ parse.Pos) {
+		fieldName = cfg.Resolve(fieldName)
+		if bindF, ok := fieldMap[fieldName]; ok {
+			fieldMap[fieldName] = makeRecordStubWithReturn(fieldName, bindF)
+			tracedFieldMap[fieldName] = makeRecordStubWithReturn(fieldName, tracedFieldMap[fieldName])
+		}
+	})
+
+	gen.tpl = parsedTpl
+	gen.template = tpl
+	gen.fields = referencedFields(cfg, fields, uniqueFieldNames(resolveFieldNames(cfg, generatedFieldNames(parsedTpl.Tree))))
+	rawGenerateCalls(parsedTpl.Tree)
+
+	return gen, nil
 }
 
-func (GeneratorWithTextTemplate) Close() error {
+func (*GeneratorWithTextTemplate) Close() error {
 	return nil
 }
 
-func (gen GeneratorWithTextTemplate) Emit(state *GenState, buf *bytes.Buffer) error {
-	state = gen.state
+func (gen *GeneratorWithTextTemplate) Fields() []ReferencedField {
+	return gen.fields
+}
+
+func (gen *GeneratorWithTextTemplate) Template() []byte {
+	return gen.template
+}
+
+func (gen *GeneratorWithTextTemplate) Emit(state *GenState, buf *bytes.Buffer) error {
+	// the template functions read the state from the generator
+	gen.state = state
+	state.resetEventValues()
 	if err := gen.emit(state, buf); err != nil {
 		return err
 	}
@@ -73,11 +201,39 @@ func (gen GeneratorWithTextTemplate) Emit(state *GenState, buf *bytes.Buffer) er
 	return nil
 }
 
-func (gen GeneratorWithTextTemplate) emit(state *GenState, buf *bytes.Buffer) error {
+func (gen *GeneratorWithTextTemplate) emit(state *GenState, buf *bytes.Buffer) error {
 	err := gen.tpl.Execute(buf, nil)
 	if err != nil {
-		return err
+		return newTextTemplateError(gen.template, err)
 	}
 
 	return nil
 }
+
+// bindPools binds the pools passed to fromPool in the parsed template that are not fields, from the config entries
+// named after them, as keyword fields.
+func bindPools(tpl []byte, parsedTpl *template.Template, cfg Config, pools map[string]EmitF) error {
+	var err error
+	walkStringCalls(parsedTpl.Tree, "fromPool", func(pool string, pos parse.Pos) {
+		if _, ok := pools[pool]; ok || err != nil {
+			return
+		}
+
+		if _, ok := cfg.GetField(pool); !ok {
+			line, column := lineColumn(tpl, int(pos))
+			err = &TemplateError{Line: line, Column: column, Err: fmt.Errorf("pool %s not found in the config nor in the fields definition", pool)}
+			return
+		}
+
+		if err = bindField(cfg, Field{Name: pool, Type: FieldTypeKeyword}, pools, nil, nil, true); err != nil {
+			return
+		}
+
+		for _, fieldName := range escapedFieldNames(cfg, nil, []string{pool}) {
+			fieldCfg, _ := cfg.GetField(fieldName)
+			pools[fieldName] = makeEscapeStubWithReturn(fieldCfg.Escape == EscapeXML, pools[fieldName])
+		}
+	})
+
+	return err
+}
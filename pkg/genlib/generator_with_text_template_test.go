@@ -2,9 +2,11 @@ package genlib
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
@@ -105,6 +107,81 @@ func test_CardinalityTWithTextTemplate[T any](t *testing.T, ty string) {
 	}
 }
 
+func Test_CardinalityDistinctWithTextTemplate(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeLong,
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  cardinality:\n    distinct: 37\n  range: 10000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":{{generate "alpha"}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, []Field{fld}, template)
+
+	vmap := make(map[int]int)
+	nSpins := 1024
+	for i := 0; i < nSpins; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[int](t, buf.Bytes())
+		vmap[m["alpha"]] += 1
+	}
+
+	if len(vmap) != 37 {
+		t.Errorf("Expected cardinality of 37 got %d", len(vmap))
+	}
+}
+
+func Test_EntityWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "host.ip", Type: FieldTypeIP},
+		{Name: "user.name", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: host.name\n  entity: host\n  cardinality:\n    distinct: 20\n- name: host.ip\n  entity: host\n- name: user.name\n  entity: user\n  cardinality:\n    distinct: 5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"host.name":"{{generate "host.name"}}","host.ip":"{{generate "host.ip"}}","user.name":"{{generate "user.name"}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	hosts := make(map[string]string)
+	users := make(map[string]int)
+	nSpins := 1024
+	for i := 0; i < nSpins; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		if ip, ok := hosts[m["host.name"]]; ok && ip != m["host.ip"] {
+			t.Errorf("Expected host %s to always have ip %s, got %s", m["host.name"], ip, m["host.ip"])
+		}
+
+		hosts[m["host.name"]] = m["host.ip"]
+		users[m["user.name"]] += 1
+	}
+
+	if len(hosts) != 20 {
+		t.Errorf("Expected 20 hosts got %d", len(hosts))
+	}
+
+	if len(users) != 5 {
+		t.Errorf("Expected 5 users got %d", len(users))
+	}
+}
+
 func Test_FieldBoolWithTextTemplate(t *testing.T) {
 	fld := Field{
 		Name: "alpha",
@@ -284,6 +361,333 @@ func Test_FieldDateWithTextTemplate(t *testing.T) {
 	}
 }
 
+func Test_FieldDateEventTimeWithTextTemplate(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeDate,
+	}
+
+	template := []byte(`{{$alpha := generate "alpha"}}{{$beta := generate "beta"}}{"alpha":"{{$alpha.Format "2006-01-02T15:04:05.999999Z07:00"}}","beta":"{{$beta.Format "2006-01-02T15:04:05.999999Z07:00"}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, Config{}, []Field{fld, {Name: "beta", Type: FieldTypeDate}}, template)
+
+	eventTime := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	state.SetEventTime(eventTime)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m := unmarshalJSONT[string](t, buf.Bytes())
+	for _, name := range []string{"alpha", "beta"} {
+		if m[name] != eventTime.Format(FieldTypeTimeLayout) {
+			t.Errorf("expected %s to be %s, got %s", name, eventTime.Format(FieldTypeTimeLayout), m[name])
+		}
+	}
+}
+
+func Test_FieldSparseWithTextTemplate(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeKeyword,
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  null_percentage: 50"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{{$alpha := generate "alpha"}}{"alpha":{{if $alpha}}"{{$alpha}}"{{else}}null{{end}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, []Field{fld}, template)
+
+	var nulls int
+	nSpins := 1024
+	for i := 0; i < nSpins; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		if m["alpha"] == nil {
+			nulls += 1
+		}
+	}
+
+	if nulls < nSpins*4/10 || nulls > nSpins*6/10 {
+		t.Errorf("expected about %d null values, got %d", nSpins/2, nulls)
+	}
+}
+
+func Test_FieldRulesWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"tcp\", \"dns\"]\n- name: beta\n  range: 10\n  rules:\n    - when:\n        field: alpha\n        equals: dns\n      then:\n        value: 53"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":"{{generate "alpha"}}","beta":{{generate "beta"}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	nSpins := 1024
+	for i := 0; i < nSpins; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		beta := m["beta"].(float64)
+		if m["alpha"] == "dns" && beta != 53 {
+			t.Errorf("expected beta to be 53 for dns, got %v", beta)
+		}
+
+		if m["alpha"] == "tcp" && beta >= 10 {
+			t.Errorf("expected beta to be lower than 10 for tcp, got %v", beta)
+		}
+	}
+}
+
+func Test_FieldRulesEscapedWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: ['say \"hi\"', 'C:\\temp']\n- name: beta\n  value: none\n  rules:\n    - when:\n        field: alpha\n        equals: 'say \"hi\"'\n      then:\n        value: quoted\n    - when:\n        field: alpha\n        equals: 'C:\\temp'\n      then:\n        value: backslashed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":"{{generate "alpha"}}","beta":"{{generate "beta"}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	expected := map[string]string{`say "hi"`: "quoted", `C:\temp`: "backslashed"}
+	for i := 0; i < 64; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		if m["beta"] != expected[m["alpha"]] {
+			t.Errorf("expected beta to be %s for %s, got %s", expected[m["alpha"]], m["alpha"], m["beta"])
+		}
+	}
+}
+
+func Test_TracingWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"a\", \"b\"]\n- name: beta\n  cardinality: 250"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":"{{generate "alpha"}}","beta":{{generate "beta"}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(state.Traces()) != 0 {
+		t.Errorf("expected no traces with tracing disabled, got %v", state.Traces())
+	}
+
+	state.SetTracing(true)
+	buf.Reset()
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m := unmarshalJSONT[any](t, buf.Bytes())
+	traces := state.Traces()
+	if len(traces) != 2 {
+		t.Fatalf("expected 2 traces, got %d", len(traces))
+	}
+
+	if traces[0].Field != "alpha" || traces[0].Emitter != "keyword.enum" || traces[0].Draw == nil || traces[0].Value != m["alpha"] {
+		t.Errorf("unexpected trace for alpha: %+v", traces[0])
+	}
+
+	if traces[1].Field != "beta" || traces[1].Emitter != "cardinality.long" || traces[1].PoolEntry == nil || *traces[1].PoolEntry != 1 || traces[1].Value != fmt.Sprint(m["beta"]) {
+		t.Errorf("unexpected trace for beta: %+v", traces[1])
+	}
+}
+
+func Test_TemplateFuncsWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeDate},
+	}
+
+	template := []byte(`{{$alpha := generate "alpha"}}{"upper":"{{upper $alpha}}","lower":"{{lower $alpha}}","substr":"{{substr 0 1 $alpha}}","add":{{add 1 2}},"time":"{{generate "beta" | formatTime "2006-01-02"}}","uuid":"{{uuidv4}}","choice":"{{randChoice "a" "b"}}","int":{{randInt 10 20}},"eventTime":"{{eventTime | formatTime "15:04"}}","eventIndex":{{eventIndex}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, Config{}, flds, template)
+
+	eventTime := time.Date(2022, 10, 1, 12, 30, 0, 0, time.UTC)
+	state.SetEventTime(eventTime)
+
+	var buf bytes.Buffer
+	for i := 0; i < 2; i++ {
+		buf.Reset()
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := unmarshalJSONT[any](t, buf.Bytes())
+	upper, lower := m["upper"].(string), m["lower"].(string)
+	if upper != strings.ToUpper(lower) || lower != strings.ToLower(upper) {
+		t.Errorf("unexpected upper %s and lower %s", upper, lower)
+	}
+
+	if len(m["substr"].(string)) != 1 || m["add"] != float64(3) {
+		t.Errorf("unexpected substr %v and add %v", m["substr"], m["add"])
+	}
+
+	if m["time"] != "2022-10-01" || m["eventTime"] != "12:30" || m["eventIndex"] != float64(1) {
+		t.Errorf("unexpected time %v, eventTime %v and eventIndex %v", m["time"], m["eventTime"], m["eventIndex"])
+	}
+
+	if uuid := m["uuid"].(string); len(uuid) != 36 || uuid[14] != '4' {
+		t.Errorf("unexpected uuid %s", uuid)
+	}
+
+	if choice := m["choice"]; choice != "a" && choice != "b" {
+		t.Errorf("unexpected choice %v", choice)
+	}
+
+	if n := m["int"].(float64); n < 10 || n >= 20 {
+		t.Errorf("unexpected int %v", n)
+	}
+}
+
+func Test_FieldMetaWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "cpu.pct", Type: FieldTypeDouble, Description: "CPU usage", Unit: "percent", MetricType: "gauge"},
+	}
+
+	template := []byte(`{"unit":"{{meta "cpu.pct" "unit"}}","metric_type":"{{meta "cpu.pct" "metric_type"}}","description":"{{meta "cpu.pct" "description"}}","type":"{{meta "cpu.pct" "type"}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, Config{}, flds, template)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m := unmarshalJSONT[string](t, buf.Bytes())
+	if m["unit"] != "percent" || m["metric_type"] != "gauge" || m["description"] != "CPU usage" || m["type"] != FieldTypeDouble {
+		t.Errorf("unexpected meta %v", m)
+	}
+
+	g, state = makeGeneratorWithTextTemplate(t, Config{}, flds, []byte(`{{meta "cpu.total" "unit"}}`))
+	if err := g.Emit(state, &buf); err == nil || !strings.Contains(err.Error(), "meta: field cpu.total not in the fields definition") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func Test_FieldUnitWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "cpu.pct", Type: FieldTypeScaledFloat, Unit: "percent"},
+		{Name: "disk.pct", Type: FieldTypeLong, Unit: "percent"},
+		{Name: "network.bytes", Type: FieldTypeLong, Unit: "byte"},
+		{Name: "event.duration", Type: FieldTypeLong, Unit: "nanos"},
+		{Name: "response.ms", Type: FieldTypeDouble, Unit: "ms"},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: network.bytes\n  fuzziness: 200\n- name: event.duration\n  range: 1\n- name: response.ms\n  range: 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"cpu.pct":{{generate "cpu.pct"}},"disk.pct":{{generate "disk.pct"}},"network.bytes":{{generate "network.bytes"}},"event.duration":{{generate "event.duration"}},"response.ms":{{generate "response.ms"}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	for i := 0; i < 1000; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[float64](t, buf.Bytes())
+		if m["cpu.pct"] < 0 || m["cpu.pct"] > 1 {
+			t.Errorf("cpu.pct %f not in 0-1", m["cpu.pct"])
+		}
+
+		if m["disk.pct"] < 0 || m["disk.pct"] > 100 {
+			t.Errorf("disk.pct %f not in 0-100", m["disk.pct"])
+		}
+
+		if m["network.bytes"] < 0 {
+			t.Errorf("network.bytes %f negative", m["network.bytes"])
+		}
+
+		if m["event.duration"] != 1 {
+			t.Errorf("event.duration %f not positive", m["event.duration"])
+		}
+
+		if m["response.ms"] <= 0 {
+			t.Errorf("response.ms %f not positive", m["response.ms"])
+		}
+	}
+}
+
+func Test_IntrospectionWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+		{Name: "gamma.*", Type: FieldTypeObject, ObjectType: FieldTypeIP},
+		{Name: "delta", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"a\"]\n- name: gamma.*\n  object_keys: [\"one\"]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"beta":{{generate "beta"}},"alpha":"{{generate "alpha" | upper}}"{{if true}},"gamma.one":"{{generate "gamma.one"}}"{{end}},"beta2":{{generate "beta"}}}`)
+	t.Logf("with template: %s", string(template))
+	g, _ := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	if string(g.Template()) != string(template) {
+		t.Errorf("expected template %s, got %s", template, g.Template())
+	}
+
+	referenced := g.Fields()
+	if len(referenced) != 3 {
+		t.Fatalf("expected 3 referenced fields, got %d", len(referenced))
+	}
+
+	if referenced[0].Field.Name != "beta" || referenced[0].Emitter != "long" {
+		t.Errorf("unexpected referenced field %+v", referenced[0])
+	}
+
+	if referenced[1].Field.Name != "alpha" || referenced[1].Emitter != "keyword.enum" || len(referenced[1].Config.Enum) != 1 {
+		t.Errorf("unexpected referenced field %+v", referenced[1])
+	}
+
+	if referenced[2].Field.Name != "gamma.one" || referenced[2].Field.Type != FieldTypeIP || referenced[2].Emitter != "ip" {
+		t.Errorf("unexpected referenced field %+v", referenced[2])
+	}
+}
+
 func Test_FieldIPWithTextTemplate(t *testing.T) {
 	fld := Field{
 		Name: "alpha",
@@ -376,3 +780,658 @@ func makeGeneratorWithTextTemplate(t *testing.T, cfg Config, fields Fields, temp
 
 	return g, NewGenState()
 }
+
+func Test_FieldRealisticWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "source.ip", Type: FieldTypeKeyword},
+		{Name: "http.request.method", Type: FieldTypeKeyword},
+		{Name: "cloud.provider", Type: FieldTypeKeyword},
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "url.path", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: cloud.provider\n  enum: [\"on-prem\"]\n- name: url.path\n  generator: hostname"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = ECSRealism(cfg, flds)
+	hostnameRegex := regexp.MustCompile(`^[a-z]+-\d{2}$`)
+
+	template := []byte(`{"source.ip":"{{generate "source.ip"}}","http.request.method":"{{generate "http.request.method"}}","cloud.provider":"{{generate "cloud.provider"}}","host.name":"{{generate "host.name"}}","url.path":"{{generate "url.path"}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		if net.ParseIP(m["source.ip"]).To4() == nil {
+			t.Errorf("source.ip %s not an IPv4 address", m["source.ip"])
+		}
+
+		if !strings.Contains(" "+strings.Join(httpMethods, " ")+" ", " "+m["http.request.method"]+" ") {
+			t.Errorf("http.request.method %s not an HTTP method", m["http.request.method"])
+		}
+
+		if m["cloud.provider"] != "on-prem" {
+			t.Errorf("cloud.provider %s not from the enum", m["cloud.provider"])
+		}
+
+		if !hostnameRegex.MatchString(m["host.name"]) || !hostnameRegex.MatchString(m["url.path"]) {
+			t.Errorf("host.name %s or url.path %s not a hostname", m["host.name"], m["url.path"])
+		}
+	}
+}
+
+func Test_FieldUnknownGeneratorWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  generator: beta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":"{{generate "alpha"}}"}`)
+	_, err = NewGeneratorWithTextTemplate(template, cfg, flds)
+	if err == nil || !strings.Contains(err.Error(), "field alpha: unknown generator beta") {
+		t.Errorf("expected unknown generator error, got %v", err)
+	}
+}
+
+func Test_FieldFakerWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "user.full_name", Type: FieldTypeKeyword},
+		{Name: "user.email", Type: FieldTypeKeyword},
+		{Name: "url.domain", Type: FieldTypeKeyword},
+		{Name: "file.path", Type: FieldTypeKeyword},
+		{Name: "event.id", Type: FieldTypeKeyword},
+		{Name: "host.mac", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: user.full_name\n  generator: person_name\n- name: user.email\n  generator: email\n- name: url.domain\n  generator: domain\n- name: file.path\n  generator: file_path\n- name: event.id\n  generator: uuid\n- name: host.mac\n  generator: mac_address"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patterns := map[string]*regexp.Regexp{
+		"user.full_name": regexp.MustCompile(`^[A-Z][a-z]+ [A-Z][a-z]+$`),
+		"user.email":     regexp.MustCompile(`^[a-z0-9.]+@[a-z]+\.[a-z]+$`),
+		"url.domain":     regexp.MustCompile(`^[a-z]+\.[a-z]+$`),
+		"file.path":      regexp.MustCompile(`^(/[a-z0-9]+)+\.[a-z]+$`),
+		"event.id":       regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+		"host.mac":       regexp.MustCompile(`^[0-9A-F]{2}(-[0-9A-F]{2}){5}$`),
+	}
+
+	template := []byte(`{"user.full_name":"{{generate "user.full_name"}}","user.email":"{{generate "user.email"}}","url.domain":"{{generate "url.domain"}}","file.path":"{{generate "file.path"}}","event.id":"{{generate "event.id"}}","host.mac":"{{generate "host.mac"}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		for name, pattern := range patterns {
+			if !pattern.MatchString(m[name]) {
+				t.Errorf("%s %s does not match %s", name, m[name], pattern)
+			}
+		}
+	}
+}
+
+func Test_FieldSentenceWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "message", Type: "text"},
+		{Name: "error.message", Type: "text"},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: message\n  generator: sentence\n  words_min: 8\n  words_max: 12\n  vocabulary: 200\n- name: error.message\n  generator: sentence\n  words_min: 1\n  words_max: 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sentenceRegex := regexp.MustCompile(`^([A-Z][a-z]*(,? [a-z]+)*\. ?)+$`)
+	template := []byte(`{"message":"{{generate "message"}}","error.message":"{{generate "error.message"}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	vocabulary := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		if !sentenceRegex.MatchString(m["message"]) || !sentenceRegex.MatchString(m["error.message"]) {
+			t.Fatalf("message %s or error.message %s not made of sentences", m["message"], m["error.message"])
+		}
+
+		words := strings.Fields(m["message"])
+		if len(words) < 8 || len(words) > 12 || len(strings.Fields(m["error.message"])) != 1 {
+			t.Errorf("message %s not of 8 to 12 words, or error.message %s not of 1 word", m["message"], m["error.message"])
+		}
+
+		for _, word := range words {
+			vocabulary[strings.ToLower(strings.Trim(word, ",."))] += 1
+		}
+	}
+
+	// the words are drawn following Zipf's law: the most frequent one is the first of the vocabulary
+	if len(vocabulary) > 200 || vocabulary["the"] < vocabulary["of"] || vocabulary["of"] < vocabulary["now"] {
+		t.Errorf("expected the words of a vocabulary of 200 words drawn by frequency, got %d words", len(vocabulary))
+	}
+
+	for _, yaml := range []string{
+		"- name: message\n  generator: sentence\n  words_min: 10\n  words_max: 5",
+		"- name: message\n  generator: sentence\n  vocabulary: -1",
+	} {
+		if _, err := config.LoadConfigFromYaml([]byte(yaml)); err == nil {
+			t.Errorf("Expected error for config %s", yaml)
+		}
+	}
+}
+
+func Test_FieldDerivedWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "event.start", Type: FieldTypeDate},
+		{Name: "event.end", Type: FieldTypeDate},
+		{Name: "event.duration", Type: FieldTypeLong},
+		{Name: "event.ingested", Type: FieldTypeDate},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: event.end\n  expression: event.start + 1500000000\n- name: event.duration\n  expression: event.end - event.start\n- name: event.ingested\n  expression: event.end + event.duration / 2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{{$start := generate "event.start"}}{{$end := generate "event.end"}}{"event.start":"{{$start.Format "2006-01-02T15:04:05.000Z07:00"}}","event.end":"{{$end.Format "2006-01-02T15:04:05.000Z07:00"}}","event.duration":{{generate "event.duration"}},"event.ingested":"{{(generate "event.ingested").Format "2006-01-02T15:04:05.000Z07:00"}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[interface{}](t, buf.Bytes())
+		start, _ := time.Parse(time.RFC3339Nano, m["event.start"].(string))
+		end, _ := time.Parse(time.RFC3339Nano, m["event.end"].(string))
+		ingested, _ := time.Parse(time.RFC3339Nano, m["event.ingested"].(string))
+		if end.Sub(start) != 1500*time.Millisecond || ingested.Sub(end) != 750*time.Millisecond || m["event.duration"] != float64(1500000000) {
+			t.Errorf("unexpected derived values in %s", buf.String())
+		}
+	}
+}
+
+func Test_FieldCopyFromWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "event.created", Type: FieldTypeDate},
+		{Name: "@timestamp", Type: FieldTypeDate},
+		{Name: "source.port", Type: FieldTypeLong},
+		{Name: "client.port", Type: FieldTypeLong},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: \"@timestamp\"\n  copy_from: event.created\n- name: client.port\n  copy_from: source.port"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{{$created := generate "event.created"}}{"event.created":"{{$created.Format "2006-01-02T15:04:05.000Z07:00"}}","@timestamp":"{{(generate "@timestamp").Format "2006-01-02T15:04:05.000Z07:00"}}","source.port":{{generate "source.port"}},"client.port":{{generate "client.port"}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[interface{}](t, buf.Bytes())
+		if m["@timestamp"] != m["event.created"] || m["client.port"] != m["source.port"] {
+			t.Errorf("unexpected copies in %s", buf.String())
+		}
+	}
+}
+
+func Test_JSONEscapeWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"say \\\"hi\\\"\\t\"]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":"{{generate "alpha"}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m := unmarshalJSONT[string](t, buf.Bytes())
+	if m["alpha"] != "say \"hi\"\t" {
+		t.Errorf("unexpected alpha %s", m["alpha"])
+	}
+
+	buf.Reset()
+	state.SetRawValues(true)
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != "{\"alpha\":\"say \"hi\"\t\"}" {
+		t.Errorf("unexpected raw event %s", buf.String())
+	}
+}
+
+func Test_XMLEscapeWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"a<b & \\\"c\\\"\\n\"]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the XML rendered in a JSON string, like the original event of winlog
+	template := []byte(`{"event.original":"<Event><Data Name=\"Message\">{{generate "alpha" | xmlEscape | jsonEscape}}</Data><Data>{{jsonEscape (generate "alpha")}}</Data></Event>","message":"{{generate "alpha"}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m := unmarshalJSONT[string](t, buf.Bytes())
+	if m["event.original"] != "<Event><Data Name=\"Message\">a&lt;b &amp; &#34;c&#34;&#xA;</Data><Data>a<b & \"c\"\n</Data></Event>" {
+		t.Errorf("unexpected event.original %s", m["event.original"])
+	}
+
+	if m["message"] != "a<b & \"c\"\n" {
+		t.Errorf("unexpected message %s", m["message"])
+	}
+
+	if fields := g.Fields(); len(fields) != 1 || fields[0].Field.Name != "alpha" {
+		t.Errorf("unexpected referenced fields %v", fields)
+	}
+}
+
+func Test_FieldRenameAndAliasWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "source.ip", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: source.ip\n  rename: src_ip\n  alias: [client_ip]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{{generate "src_ip"}} {{generate "client_ip"}} {{generate "source.ip"}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// the renamed field is not known by its name in the fields definition anymore
+	values := strings.Split(buf.String(), " ")
+	if len(values[0]) == 0 || values[0] != values[1] || len(values[2]) != 0 {
+		t.Errorf("unexpected event %s", buf.String())
+	}
+}
+
+func Test_FieldIPCIDRWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "source.ip", Type: FieldTypeIP},
+		{Name: "destination.ip", Type: FieldTypeIP},
+		{Name: "host.ip", Type: FieldTypeIP},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: source.ip\n  cidr: [\"10.1.0.0/16\", \"2001:db8::/32\"]\n- name: destination.ip\n  ipv6_percentage: 100\n- name: host.ip\n  cidr: [\"192.168.0.0/24\", \"172.16.0.0/12\"]\n  cardinality:\n    distinct: 3\n  cardinality_per_cidr: true"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, v4Subnet, _ := net.ParseCIDR("10.1.0.0/16")
+	_, v6Subnet, _ := net.ParseCIDR("2001:db8::/32")
+	_, hostSubnet1, _ := net.ParseCIDR("192.168.0.0/24")
+	_, hostSubnet2, _ := net.ParseCIDR("172.16.0.0/12")
+
+	template := []byte(`{"source.ip":"{{generate "source.ip"}}","destination.ip":"{{generate "destination.ip"}}","host.ip":"{{generate "host.ip"}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	hostIPs := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		if ip := net.ParseIP(m["source.ip"]); ip == nil || (!v4Subnet.Contains(ip) && !v6Subnet.Contains(ip)) {
+			t.Errorf("source.ip %s not in the subnets", m["source.ip"])
+		}
+
+		if ip := net.ParseIP(m["destination.ip"]); ip == nil || ip.To4() != nil {
+			t.Errorf("destination.ip %s not an IPv6 address", m["destination.ip"])
+		}
+
+		ip := net.ParseIP(m["host.ip"])
+		if ip == nil || (!hostSubnet1.Contains(ip) && !hostSubnet2.Contains(ip)) {
+			t.Errorf("host.ip %s not in the subnets", m["host.ip"])
+		}
+
+		if hostSubnet1.Contains(ip) {
+			hostIPs["192.168.0.0/24"]++
+		} else {
+			hostIPs["172.16.0.0/12"]++
+		}
+
+		hostIPs[m["host.ip"]]++
+	}
+
+	// 3 distinct values per subnet, alongside the counts of the 2 subnets
+	if len(hostIPs) != 8 {
+		t.Errorf("expected 3 distinct host.ip values per subnet, got %v", hostIPs)
+	}
+}
+
+func Test_FieldPrecisionWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeDouble},
+		{Name: "beta", Type: FieldTypeDouble},
+		{Name: "gamma", Type: FieldTypeLong},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  precision: 0\n  range: 1000000000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = FloatPrecision(cfg, flds, 3)
+	if betaCfg, _ := cfg.GetField("beta"); betaCfg.Precision == nil || *betaCfg.Precision != 3 {
+		t.Fatalf("expected precision 3 for beta, got %v", betaCfg.Precision)
+	}
+
+	if _, ok := cfg.GetField("gamma"); ok {
+		t.Fatalf("unexpected config for gamma")
+	}
+
+	template := []byte(`{{generate "alpha"}} {{generate "beta"}} {{generate "beta" | mulf 2}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	precisionRegex := regexp.MustCompile(`^\d+ \d+\.\d{3} \S+$`)
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if !precisionRegex.Match(buf.Bytes()) {
+			t.Errorf("unexpected formatting %s", buf.String())
+		}
+	}
+}
+
+func Test_FieldGeoPointFormatWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeGeoPoint},
+		{Name: "beta", Type: FieldTypeGeoPoint},
+		{Name: "gamma", Type: FieldTypeGeoPoint},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  geo_format: object\n  geo_clusters:\n    - city: london\n      radius: 10\n- name: beta\n  geo_format: geohash\n  geo_clusters:\n    - lat: 48.8566\n      lon: 2.3522\n- name: gamma\n  geo_format: wkt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":{{generate "alpha"}},"beta":"{{generate "beta"}}","gamma":"{{generate "gamma"}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	wktRegex := regexp.MustCompile(`^POINT\(-?\d+\.\d{6} -?\d+\.\d{6}\)$`)
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[interface{}](t, buf.Bytes())
+		alpha, ok := m["alpha"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("expected alpha to be an object, got %v", m["alpha"])
+		}
+
+		// 10 km are less than 0.1 degrees of latitude, and of longitude at the latitude of London
+		if lat, lon := alpha["lat"].(float64), alpha["lon"].(float64); lat < 51.4 || lat > 51.6 || lon < -0.3 || lon > 0.1 {
+			t.Errorf("expected alpha around London, got %v", alpha)
+		}
+
+		// the points 50 km around Paris are in the u0 geohash cell
+		if beta := m["beta"].(string); len(beta) != 9 || !strings.HasPrefix(beta, "u0") {
+			t.Errorf("expected beta to be a geohash around Paris, got %s", beta)
+		}
+
+		if gamma := m["gamma"].(string); !wktRegex.MatchString(gamma) {
+			t.Errorf("expected gamma to be a WKT point, got %s", gamma)
+		}
+	}
+}
+
+func Test_FieldArrayWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeIP},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  cardinality:\n    distinct: 10\n  array_min: 1\n  array_max: 5\n- name: beta\n  array_min: 2\n  array_max: 2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":{{generate "alpha"}},"beta":{{generate "beta"}},"count":{{len (generate "alpha")}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	for i := 0; i < 100; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[interface{}](t, buf.Bytes())
+		alpha, ok := m["alpha"].([]interface{})
+		if !ok || len(alpha) < 1 || len(alpha) > 5 {
+			t.Fatalf("expected 1 to 5 values of alpha, got %v", m["alpha"])
+		}
+
+		// the values of a field with cardinality are distinct within an array, as long as the pool allows
+		seen := make(map[interface{}]struct{})
+		for _, v := range alpha {
+			if _, ok := seen[v]; ok {
+				t.Errorf("unexpected duplicated value in alpha %v", alpha)
+			}
+			seen[v] = struct{}{}
+		}
+
+		beta, ok := m["beta"].([]interface{})
+		if !ok || len(beta) != 2 {
+			t.Fatalf("expected 2 values of beta, got %v", m["beta"])
+		}
+
+		for _, v := range beta {
+			if net.ParseIP(v.(string)) == nil {
+				t.Errorf("expected beta values to be ip addresses, got %v", beta)
+			}
+		}
+	}
+}
+
+func Test_TemplateErrorWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "cpu.pct", Type: FieldTypeDouble},
+	}
+
+	template := []byte("{\n  \"pct\": {{ pct \"cpu.pct\" }}\n}")
+	_, err := NewGeneratorWithTextTemplate(template, Config{}, flds)
+	if err == nil || err.Error() != `template:2:13: function "pct" not defined` {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	template = []byte("{\n  \"pct\": {{generate \"cpu.pct\"}},\n  \"unit\": \"{{meta \"cpu.total\" \"unit\"}}\"\n}")
+	g, state := makeGeneratorWithTextTemplate(t, Config{}, flds, template)
+
+	var buf bytes.Buffer
+	err = g.Emit(state, &buf)
+	var templateErr *TemplateError
+	if !errors.As(err, &templateErr) || templateErr.Line != 3 || templateErr.Field != "cpu.total" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := SetTemplateName(err, "metrics.tpl"); err.Error() != "template metrics.tpl:3:14: field cpu.total: error calling meta: meta: field cpu.total not in the fields definition" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func Test_FieldMemoizedWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: beta\n  reroll: true"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"alpha":"{{generate "alpha"}}","beta":{{generate "beta"}},"alpha_copy":"{{generate "alpha"}}","beta_copy":{{generate "beta"}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	var rerolled int
+	nSpins := 100
+	for i := 0; i < nSpins; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		if m["alpha"] != m["alpha_copy"] {
+			t.Errorf("expected the same value of alpha for all its references, got %s", buf.String())
+		}
+
+		if m["beta"] != m["beta_copy"] {
+			rerolled++
+		}
+	}
+
+	if rerolled == 0 {
+		t.Errorf("expected beta to be rerolled for each reference")
+	}
+}
+
+func Test_FromPoolWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "user.name", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: user.name\n  cardinality:\n    distinct: 2\n- name: usernames\n  enum: [alice, bob, carol]\n  weights: [1, 0, 1]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{"user":"{{generate "user.name"}}","from":"{{fromPool "usernames"}}","to":"{{fromPool "user.name"}}"}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	users := make(map[string]struct{})
+	nSpins := 100
+	for i := 0; i < nSpins; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		if m["from"] != "alice" && m["from"] != "carol" {
+			t.Errorf("unexpected value %s drawn from usernames", m["from"])
+		}
+
+		users[m["user"]] = struct{}{}
+		users[m["to"]] = struct{}{}
+	}
+
+	if len(users) != 2 {
+		t.Errorf("expected the pool of user.name to share its cardinality, got %d values", len(users))
+	}
+
+	template = []byte("{\n  \"from\": \"{{fromPool \"hosts\"}}\"\n}")
+	_, err = NewGeneratorWithTextTemplate(template, cfg, flds)
+	var templateErr *TemplateError
+	if !errors.As(err, &templateErr) || templateErr.Line != 2 || err.Error() != "template:2:14: pool hosts not found in the config nor in the fields definition" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func Test_FieldPreviousWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "system.cpu.total.ticks", Type: FieldTypeLong},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: system.cpu.total.ticks\n  range:\n    min: 1\n    max: 100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := []byte(`{{$ticks := generate "system.cpu.total.ticks"}}{"ticks":{{$ticks}},"total":{{add $ticks (default 0 (previous "system.cpu.total.ticks"))}}}`)
+	t.Logf("with template: %s", string(template))
+	g, state := makeGeneratorWithTextTemplate(t, cfg, flds, template)
+
+	var previous float64
+	for i := 0; i < 10; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[float64](t, buf.Bytes())
+		if m["total"] != m["ticks"]+previous {
+			t.Errorf("total %v of event %d is not the sum of its ticks %v and of the ticks of the previous event %v", m["total"], i, m["ticks"], previous)
+		}
+
+		previous = m["ticks"]
+	}
+
+	// the values of the first event have no previous ones, they are empty
+	template = []byte(`{{generate "system.cpu.total.ticks"}} {{previous "system.cpu.total.ticks"}}|`)
+	g, state = makeGeneratorWithTextTemplate(t, cfg, flds, template)
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(buf.String(), " |") {
+		t.Errorf("expected the previous value of the first event to be empty, got %s", buf.String())
+	}
+}
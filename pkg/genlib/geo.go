@@ -0,0 +1,257 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// GeoFormatString formats geo_point values as "lat,lon" strings, the default
+	GeoFormatString = "string"
+	// GeoFormatObject formats geo_point values as {"lat":..,"lon":..} objects
+	GeoFormatObject = "object"
+	// GeoFormatGeohash formats geo_point values as geohashes
+	GeoFormatGeohash = "geohash"
+	// GeoFormatWKT formats geo_point values as POINT(lon lat) well-known texts
+	GeoFormatWKT = "wkt"
+
+	// defaultGeoClusterRadius is the radius in kilometers of the geo clusters without one
+	defaultGeoClusterRadius = 50.
+	// geohashPrecision is the length of the generated geohashes, about 5 meters
+	geohashPrecision = 9
+	kmPerDegree      = 111.32
+	geohashAlphabet  = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+// geoCities are the centroids of the cities the values of geo_point fields can be clustered around.
+var geoCities = map[string][2]float64{
+	"amsterdam":     {52.3676, 4.9041},
+	"beijing":       {39.9042, 116.4074},
+	"berlin":        {52.5200, 13.4050},
+	"buenos_aires":  {-34.6037, -58.3816},
+	"cairo":         {30.0444, 31.2357},
+	"chicago":       {41.8781, -87.6298},
+	"dubai":         {25.2048, 55.2708},
+	"frankfurt":     {50.1109, 8.6821},
+	"johannesburg":  {-26.2041, 28.0473},
+	"london":        {51.5074, -0.1278},
+	"los_angeles":   {34.0522, -118.2437},
+	"madrid":        {40.4168, -3.7038},
+	"mexico_city":   {19.4326, -99.1332},
+	"mumbai":        {19.0760, 72.8777},
+	"new_york":      {40.7128, -74.0060},
+	"paris":         {48.8566, 2.3522},
+	"san_francisco": {37.7749, -122.4194},
+	"sao_paulo":     {-23.5505, -46.6333},
+	"seoul":         {37.5665, 126.9780},
+	"singapore":     {1.3521, 103.8198},
+	"stockholm":     {59.3293, 18.0686},
+	"sydney":        {-33.8688, 151.2093},
+	"tokyo":         {35.6762, 139.6503},
+	"toronto":       {43.6532, -79.3832},
+}
+
+// GeoCities returns the names of the cities the values of geo_point fields can be clustered around, sorted.
+func GeoCities() []string {
+	names := make([]string, 0, len(geoCities))
+	for name := range geoCities {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// geoPoint is a value of a geo_point field.
+type geoPoint struct {
+	lat, lon float64
+	format   string
+}
+
+// String formats the point, so that it is rendered by text templates as well.
+func (p geoPoint) String() string {
+	var buf bytes.Buffer
+	p.write(&buf)
+	return buf.String()
+}
+
+// MarshalJSON serializes the point in structured templates: as an object or as a string, according to its format.
+func (p geoPoint) MarshalJSON() ([]byte, error) {
+	if p.format == GeoFormatObject {
+		return []byte(p.String()), nil
+	}
+
+	return []byte(strconv.Quote(p.String())), nil
+}
+
+func (p geoPoint) write(buf *bytes.Buffer) {
+	lat := strconv.FormatFloat(p.lat, 'f', 6, 64)
+	lon := strconv.FormatFloat(p.lon, 'f', 6, 64)
+	switch p.format {
+	case GeoFormatObject:
+		fmt.Fprintf(buf, `{"lat":%s,"lon":%s}`, lat, lon)
+	case GeoFormatGeohash:
+		buf.WriteString(geohash(p.lat, p.lon, geohashPrecision))
+	case GeoFormatWKT:
+		fmt.Fprintf(buf, "POINT(%s %s)", lon, lat)
+	default:
+		buf.WriteString(lat)
+		buf.WriteByte(',')
+		buf.WriteString(lon)
+	}
+}
+
+// geoCluster is an area, in kilometers around a centroid, the values of a geo_point field are clustered around.
+type geoCluster struct {
+	lat, lon, radius float64
+}
+
+// geoPointGenerator generates the values of a geo_point field, in its format, anywhere or around its clusters.
+type geoPointGenerator struct {
+	format   string
+	clusters []geoCluster
+}
+
+func newGeoPointGenerator(fieldCfg ConfigField, field Field) (geoPointGenerator, error) {
+	g := geoPointGenerator{format: fieldCfg.GeoFormat}
+	for _, cluster := range fieldCfg.GeoClusters {
+		c := geoCluster{lat: cluster.Lat, lon: cluster.Lon, radius: cluster.Radius}
+		if len(cluster.City) > 0 {
+			centroid, ok := geoCities[strings.ReplaceAll(strings.ToLower(cluster.City), " ", "_")]
+			if !ok {
+				return geoPointGenerator{}, fmt.Errorf("field %s: unknown city %s, must be one of %s", field.Name, cluster.City, strings.Join(GeoCities(), ", "))
+			}
+
+			c.lat, c.lon = centroid[0], centroid[1]
+		}
+
+		if c.radius == 0 {
+			c.radius = defaultGeoClusterRadius
+		}
+
+		g.clusters = append(g.clusters, c)
+	}
+
+	return g, nil
+}
+
+// isDefault tells whether the generator writes the values as the geo_point fields without config.
+func (g geoPointGenerator) isDefault() bool {
+	return (len(g.format) == 0 || g.format == GeoFormatString) && len(g.clusters) == 0
+}
+
+func (g geoPointGenerator) generate() geoPoint {
+	if len(g.clusters) == 0 {
+		return geoPoint{lat: rand.Float64()*180 - 90, lon: rand.Float64()*360 - 180, format: g.format}
+	}
+
+	// the points are denser close to the centroid
+	c := g.clusters[rand.Intn(len(g.clusters))]
+	distance := math.Min(math.Abs(rand.NormFloat64())*c.radius/2, c.radius)
+	bearing := rand.Float64() * 2 * math.Pi
+
+	lat := c.lat + distance*math.Cos(bearing)/kmPerDegree
+	lat = math.Max(-90, math.Min(90, lat))
+
+	lon := c.lon
+	if cos := math.Cos(lat * math.Pi / 180); cos > 0.01 {
+		lon += distance * math.Sin(bearing) / (kmPerDegree * cos)
+	}
+
+	lon = math.Mod(lon+540, 360) - 180
+	return geoPoint{lat: lat, lon: lon, format: g.format}
+}
+
+// geohash encodes the point as a geohash of the given length.
+func geohash(lat, lon float64, length int) string {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+
+	var hash strings.Builder
+	even := true
+	bit, ch := 0, 0
+	for hash.Len() < length {
+		if even {
+			mid := (lonRange[0] + lonRange[1]) / 2
+			if lon >= mid {
+				ch |= 1 << (4 - bit)
+				lonRange[0] = mid
+			} else {
+				lonRange[1] = mid
+			}
+		} else {
+			mid := (latRange[0] + latRange[1]) / 2
+			if lat >= mid {
+				ch |= 1 << (4 - bit)
+				latRange[0] = mid
+			} else {
+				latRange[1] = mid
+			}
+		}
+
+		even = !even
+		if bit < 4 {
+			bit++
+			continue
+		}
+
+		hash.WriteByte(geohashAlphabet[ch])
+		bit, ch = 0, 0
+	}
+
+	return hash.String()
+}
+
+func bindGeoPoint(prefix []byte, fieldCfg ConfigField, field Field, fieldMap map[string]emitFNotReturn) error {
+	g, err := newGeoPointGenerator(fieldCfg, field)
+	if err != nil {
+		return err
+	}
+
+	if g.isDefault() {
+		fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
+			buf.Write(prefix)
+			return randGeoPoint(buf)
+		}
+
+		return nil
+	}
+
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
+		buf.Write(prefix)
+		g.generate().write(buf)
+		return nil
+	}
+
+	return nil
+}
+
+func bindGeoPointWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]EmitF) error {
+	g, err := newGeoPointGenerator(fieldCfg, field)
+	if err != nil {
+		return err
+	}
+
+	if g.isDefault() {
+		fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
+			return randGeoPointWithReturn(), nil
+		}
+
+		return nil
+	}
+
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
+		return g.generate(), nil
+	}
+
+	return nil
+}
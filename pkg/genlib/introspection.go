@@ -0,0 +1,139 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"strings"
+	"text/template/parse"
+)
+
+// ReferencedField is a field referenced by the template of a generator, alongside the config in effect for it.
+type ReferencedField struct {
+	Field  Field
+	Config ConfigField
+	// Emitter describes how the value of the field is generated, as in FieldTrace
+	Emitter string
+}
+
+// referencedFields resolves the names of the fields referenced by a template.
+// Object keys are not part of fields: they are given the type of the values of their object.
+func referencedFields(cfg Config, fields Fields, fieldNames []string) []ReferencedField {
+	byName := make(map[string]Field, len(fields))
+	for _, field := range fields {
+		byName[field.Name] = field
+	}
+
+	referenced := make([]ReferencedField, 0, len(fieldNames))
+	for _, fieldName := range fieldNames {
+		field, ok := byName[fieldName]
+		if !ok {
+			field = objectKeyField(fields, fieldName)
+		}
+
+		fieldCfg, _ := cfg.GetField(fieldName)
+		referenced = append(referenced, ReferencedField{
+			Field:   field,
+			Config:  fieldCfg,
+			Emitter: emitterName(cfg, field),
+		})
+	}
+
+	return referenced
+}
+
+// objectKeyField returns the field of the object key fieldName, a field with just the name if there is no such object.
+func objectKeyField(fields Fields, fieldName string) Field {
+	for _, field := range fields {
+		if field.Type != FieldTypeObject && field.Type != FieldTypeNested && field.Type != FieldTypeFlattened {
+			continue
+		}
+
+		if !strings.HasPrefix(fieldName, replacer.Replace(field.Name)+".") {
+			continue
+		}
+
+		field.Name = fieldName
+		field.Type = FieldTypeKeyword
+		if len(field.ObjectType) > 0 {
+			field.Type = field.ObjectType
+		}
+
+		return field
+	}
+
+	return Field{Name: fieldName}
+}
+
+// generatedFieldNames returns the names of the fields passed to the generate function in the parsed template, in order of appearance.
+func generatedFieldNames(tree *parse.Tree) []string {
+	var fieldNames []string
+	seen := make(map[string]struct{})
+	walkStringCalls(tree, "generate", func(fieldName string, _ parse.Pos) {
+		if _, ok := seen[fieldName]; !ok {
+			seen[fieldName] = struct{}{}
+			fieldNames = append(fieldNames, fieldName)
+		}
+	})
+
+	return fieldNames
+}
+
+// walkStringCalls calls fn for each call of the function funcName with a string literal argument in the parsed
+// template, in order of appearance, with the argument and the position of the call in the template.
+func walkStringCalls(tree *parse.Tree, funcName string, fn func(arg string, pos parse.Pos)) {
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch node := node.(type) {
+		case *parse.ListNode:
+			if node == nil {
+				return
+			}
+
+			for _, n := range node.Nodes {
+				walk(n)
+			}
+		case *parse.ActionNode:
+			walk(node.Pipe)
+		case *parse.IfNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.RangeNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.WithNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.TemplateNode:
+			walk(node.Pipe)
+		case *parse.PipeNode:
+			if node == nil {
+				return
+			}
+
+			for _, cmd := range node.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			if len(node.Args) == 2 {
+				identifier, isIdentifier := node.Args[0].(*parse.IdentifierNode)
+				arg, isString := node.Args[1].(*parse.StringNode)
+				if isIdentifier && isString && identifier.Ident == funcName {
+					fn(arg.Text, node.Position())
+				}
+			}
+
+			for _, arg := range node.Args {
+				walk(arg)
+			}
+		}
+	}
+
+	if tree != nil {
+		walk(tree.Root)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+)
+
+// defaultIPv6Subnets are the subnets of the IPv6 addresses when no cidr is configured: unique local and global unicast.
+var defaultIPv6Subnets = []*net.IPNet{mustParseCIDR("fd00::/8"), mustParseCIDR("2000::/3")}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+
+	return subnet
+}
+
+// ipGenerator generates the values of an ip field: IPv4 addresses by default, IPv6 ones in ipv6Percentage of the values,
+// or addresses drawn from the configured subnets.
+type ipGenerator struct {
+	fieldName      string
+	subnets        []*net.IPNet
+	ipv6Percentage int
+	// cardinality is the count of distinct values of each subnet, when applied per subnet
+	cardinality int
+}
+
+func newIPGenerator(fieldCfg ConfigField, field Field) (ipGenerator, error) {
+	g := ipGenerator{fieldName: field.Name, ipv6Percentage: fieldCfg.IPv6Percentage}
+	for _, cidr := range fieldCfg.CIDR {
+		_, subnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return ipGenerator{}, fmt.Errorf("field %s: cidr %s is not a valid subnet", field.Name, cidr)
+		}
+
+		g.subnets = append(g.subnets, subnet)
+	}
+
+	if appliesCardinalityPerCIDR(fieldCfg, field) {
+		g.cardinality = fieldCfg.Cardinality.Values()
+	}
+
+	return g, nil
+}
+
+// appliesCardinalityPerCIDR tells whether the cardinality of the field applies to each subnet of its cidr, instead of to the field.
+func appliesCardinalityPerCIDR(fieldCfg ConfigField, field Field) bool {
+	return field.Type == FieldTypeIP && fieldCfg.CardinalityPerCIDR && len(fieldCfg.CIDR) > 0 && fieldCfg.Cardinality.Values() > 0
+}
+
+func (g ipGenerator) generate(state *GenState) string {
+	if len(g.subnets) == 0 {
+		if g.ipv6Percentage > 0 && rand.Intn(100) < g.ipv6Percentage {
+			return randomIPInSubnet(defaultIPv6Subnets[rand.Intn(len(defaultIPv6Subnets))])
+		}
+
+		var b [15]byte
+		return string(appendRandomIPv4(b[:0]))
+	}
+
+	if g.cardinality == 0 {
+		return randomIPInSubnet(g.subnets[rand.Intn(len(g.subnets))])
+	}
+
+	// the subnets take turns, each one cycling through its own distinct values
+	var pools [][]string
+	if v, ok := state.prevCache[g.fieldName]; ok {
+		pools = v.([][]string)
+	} else {
+		pools = make([][]string, len(g.subnets))
+	}
+
+	n := int(state.counter % uint64(len(g.subnets)))
+	if len(pools[n]) < g.cardinality {
+		// Allow dupe if no unique value in nTries, as with the cardinality of the other fields.
+		var value string
+		for i := 0; i < 11; i++ {
+			value = randomIPInSubnet(g.subnets[n])
+			if !containsString(pools[n], value) {
+				break
+			}
+		}
+
+		pools[n] = append(pools[n], value)
+		state.prevCache[g.fieldName] = pools
+	}
+
+	idx := int(state.counter/uint64(len(g.subnets))) % g.cardinality
+	if idx >= len(pools[n]) {
+		idx = len(pools[n]) - 1
+	}
+
+	state.tracePoolEntry(g.fieldName, n*g.cardinality+idx)
+	return pools[n][idx]
+}
+
+// write writes a value as generate does, without allocating for the IPv4 addresses of the fields without cidr.
+func (g ipGenerator) write(state *GenState, buf *bytes.Buffer) {
+	if len(g.subnets) == 0 && g.ipv6Percentage == 0 {
+		var b [15]byte
+		buf.Write(appendRandomIPv4(b[:0]))
+		return
+	}
+
+	buf.WriteString(g.generate(state))
+}
+
+// appendRandomIPv4 appends to b a random IPv4 address, none of its bytes being 255.
+func appendRandomIPv4(b []byte) []byte {
+	for i := 0; i < 4; i++ {
+		if i > 0 {
+			b = append(b, '.')
+		}
+
+		b = strconv.AppendInt(b, int64(rand.Intn(255)), 10)
+	}
+
+	return b
+}
+
+// randomIPInSubnet returns a random address of subnet, other than the network and broadcast addresses of IPv4 subnets with room for them.
+func randomIPInSubnet(subnet *net.IPNet) string {
+	network := subnet.IP
+	if ip4 := network.To4(); ip4 != nil {
+		network = ip4
+	}
+
+	ones, bits := subnet.Mask.Size()
+	ip := make(net.IP, len(network))
+	if bits == 8*net.IPv6len || bits-ones < 2 {
+		for j := range ip {
+			ip[j] = network[j] | byte(rand.Intn(256))&^subnet.Mask[j]
+		}
+
+		return ip.String()
+	}
+
+	// the host part is drawn between the network and the broadcast addresses
+	host := 1 + rand.Int63n(1<<(bits-ones)-2)
+	for j := len(ip) - 1; j >= 0; j-- {
+		ip[j] = network[j]&subnet.Mask[j] | byte(host)
+		host >>= 8
+	}
+
+	return ip.String()
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func bindIP(prefix []byte, fieldCfg ConfigField, field Field, fieldMap map[string]emitFNotReturn) error {
+	g, err := newIPGenerator(fieldCfg, field)
+	if err != nil {
+		return err
+	}
+
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
+		buf.Write(prefix)
+		g.write(state, buf)
+		return nil
+	}
+
+	return nil
+}
+
+func bindIPWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]EmitF) error {
+	g, err := newIPGenerator(fieldCfg, field)
+	if err != nil {
+		return err
+	}
+
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
+		return g.generate(state), nil
+	}
+
+	return nil
+}
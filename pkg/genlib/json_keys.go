@@ -0,0 +1,107 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// The representations of the keys of the JSON documents rewritten by NewJSONKeys
+const (
+	// JSONKeysDotted writes the fields as dotted names, like "host.name": "x"
+	JSONKeysDotted = "dotted"
+	// JSONKeysNested writes the fields as nested objects, like "host": {"name": "x"}
+	JSONKeysNested = "nested"
+)
+
+// NewJSONKeys returns a Middleware rewriting the keys of the JSON documents in the representation keys, either
+// JSONKeysDotted or JSONKeysNested, for the consumers expecting one shape or the other, like the ingest pipelines
+// and the clients indexing the documents directly. The objects in arrays are rewritten on their own, and the keys of
+// the rewritten documents are sorted.
+func NewJSONKeys(keys string) (Middleware, error) {
+	var rewrite func(obj map[string]interface{}) map[string]interface{}
+	switch keys {
+	case JSONKeysDotted:
+		rewrite = func(obj map[string]interface{}) map[string]interface{} {
+			dotted := make(map[string]interface{}, len(obj))
+			dotKeys(obj, "", dotted)
+			return dotted
+		}
+	case JSONKeysNested:
+		rewrite = nestKeys
+	default:
+		return nil, fmt.Errorf("JSON keys must be %s or %s, got %s", JSONKeysDotted, JSONKeysNested, keys)
+	}
+
+	var buf bytes.Buffer
+	return func(doc []byte) ([]byte, error) {
+		decoder := json.NewDecoder(bytes.NewReader(doc))
+		decoder.UseNumber()
+		var v map[string]interface{}
+		if err := decoder.Decode(&v); err != nil {
+			return nil, fmt.Errorf("cannot rewrite the keys of a document that is not a JSON object: %w", err)
+		}
+
+		buf.Reset()
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(rewrite(v)); err != nil {
+			return nil, err
+		}
+
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+	}, nil
+}
+
+// dotKeys adds to dotted the fields of obj, the object at path, under their dotted names: the empty objects are kept.
+func dotKeys(obj map[string]interface{}, path string, dotted map[string]interface{}) {
+	for key, value := range obj {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if len(value) == 0 {
+				dotted[joinPath(path, key)] = value
+				continue
+			}
+
+			dotKeys(value, joinPath(path, key), dotted)
+		case []interface{}:
+			dotted[joinPath(path, key)] = rewriteArrayObjects(value, func(obj map[string]interface{}) map[string]interface{} {
+				objDotted := make(map[string]interface{}, len(obj))
+				dotKeys(obj, "", objDotted)
+				return objDotted
+			})
+		default:
+			dotted[joinPath(path, key)] = value
+		}
+	}
+}
+
+// nestKeys returns obj with its dotted keys expanded into nested objects, at any depth.
+func nestKeys(obj map[string]interface{}) map[string]interface{} {
+	nested := expandDottedKeys(obj)
+	for key, value := range nested {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			nested[key] = nestKeys(value)
+		case []interface{}:
+			nested[key] = rewriteArrayObjects(value, nestKeys)
+		}
+	}
+
+	return nested
+}
+
+// rewriteArrayObjects rewrites the objects of arr with rewrite, in place.
+func rewriteArrayObjects(arr []interface{}, rewrite func(obj map[string]interface{}) map[string]interface{}) []interface{} {
+	for i, value := range arr {
+		if obj, ok := value.(map[string]interface{}); ok {
+			arr[i] = rewrite(obj)
+		}
+	}
+
+	return arr
+}
@@ -0,0 +1,51 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"testing"
+)
+
+func Test_NewJSONKeys(t *testing.T) {
+	doc := `{"@timestamp":"2024-01-01T00:00:00Z","host":{"name":"web-01","os.name":"linux"},"host.ip":"10.0.0.1","labels":{},"user.name":null,"spans":[{"span":{"id":"a"}}],"bytes":12345678901234567890}`
+	testCases := []struct {
+		keys     string
+		expected string
+	}{
+		{
+			keys:     JSONKeysDotted,
+			expected: `{"@timestamp":"2024-01-01T00:00:00Z","bytes":12345678901234567890,"host.ip":"10.0.0.1","host.name":"web-01","host.os.name":"linux","labels":{},"spans":[{"span.id":"a"}],"user.name":null}`,
+		},
+		{
+			keys:     JSONKeysNested,
+			expected: `{"@timestamp":"2024-01-01T00:00:00Z","bytes":12345678901234567890,"host":{"ip":"10.0.0.1","name":"web-01","os":{"name":"linux"}},"labels":{},"spans":[{"span":{"id":"a"}}],"user":{"name":null}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		middleware, err := NewJSONKeys(tc.keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rewritten, err := middleware([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(rewritten) != tc.expected {
+			t.Errorf("expected %s keys %s, got %s", tc.keys, tc.expected, string(rewritten))
+		}
+	}
+
+	if _, err := NewJSONKeys("flat"); err == nil || err.Error() != "JSON keys must be dotted or nested, got flat" {
+		t.Errorf("expected error for flat keys, got %v", err)
+	}
+
+	middleware, _ := NewJSONKeys(JSONKeysNested)
+	if _, err := middleware([]byte("GET / 200")); err == nil {
+		t.Error("expected error for a document that is not a JSON object")
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// NewLateArrivals returns a Middleware moving back the @timestamp of a percentage of the JSON documents, from 0 to
+// 100, by up to maxLateness, so that they arrive later than the documents following them in the corpus, like the
+// events of a shipper catching up: the lateness is drawn at random, by the millisecond. The other date fields are left
+// as they are, as the documents without @timestamp.
+func NewLateArrivals(percentage float64, maxLateness time.Duration) (Middleware, error) {
+	if percentage < 0 || percentage > 100 {
+		return nil, fmt.Errorf("late arrival percentage must be between 0 and 100, got %v", percentage)
+	}
+
+	if maxLateness < time.Millisecond {
+		return nil, fmt.Errorf("max lateness must be a millisecond at least, got %s", maxLateness)
+	}
+
+	var buf bytes.Buffer
+	return func(doc []byte) ([]byte, error) {
+		if percentage == 0 || rand.Float64()*100 >= percentage {
+			return doc, nil
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(doc))
+		decoder.UseNumber()
+		var event map[string]interface{}
+		if err := decoder.Decode(&event); err != nil || event == nil {
+			return doc, nil
+		}
+
+		obj, name, found := lookupPath(event, "@timestamp")
+		if !found {
+			return doc, nil
+		}
+
+		t, ok := parseTimestamp(obj[name])
+		if !ok {
+			return doc, nil
+		}
+
+		lateness := time.Duration(1+rand.Int63n(int64(maxLateness/time.Millisecond))) * time.Millisecond
+		obj[name] = formatTimestamp(t.Add(-lateness), obj[name])
+
+		buf.Reset()
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(event); err != nil {
+			return nil, err
+		}
+
+		trimmed := bytes.TrimRight(doc, trailingSpace)
+		return append(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), doc[len(trimmed):]...), nil
+	}, nil
+}
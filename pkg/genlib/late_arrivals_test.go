@@ -0,0 +1,79 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func Test_NewLateArrivals(t *testing.T) {
+	if _, err := NewLateArrivals(101, time.Minute); err == nil {
+		t.Errorf("expected an error for a percentage over 100")
+	}
+
+	if _, err := NewLateArrivals(10, 0); err == nil {
+		t.Errorf("expected an error for a max lateness of 0")
+	}
+
+	InitGeneratorRandSeed(1)
+	middleware, err := NewLateArrivals(20, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reference := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var late int
+	for i := 0; i < 10000; i++ {
+		timestamp := reference.Add(time.Duration(i) * time.Second)
+		doc := `{"@timestamp":"` + timestamp.Format(time.RFC3339Nano) + `","event":{"created":"` + timestamp.Format(time.RFC3339Nano) + `"}}` + "\n"
+		rewritten, err := middleware([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rewritten[len(rewritten)-1] != '\n' {
+			t.Fatalf("expected %q to keep its trailing newline", string(rewritten))
+		}
+
+		var event struct {
+			Timestamp time.Time `json:"@timestamp"`
+			Event     struct {
+				Created time.Time `json:"created"`
+			} `json:"event"`
+		}
+		if err := json.Unmarshal(rewritten, &event); err != nil {
+			t.Fatal(err)
+		}
+
+		if !event.Event.Created.Equal(timestamp) {
+			t.Fatalf("expected event.created of %s to be left as it is, got %s", doc, string(rewritten))
+		}
+
+		lateness := timestamp.Sub(event.Timestamp)
+		if lateness < 0 || lateness > time.Minute {
+			t.Fatalf("expected the @timestamp of %s to be moved back by a minute at most, got %s", doc, string(rewritten))
+		}
+
+		if lateness > 0 {
+			late++
+		}
+	}
+
+	if late < 1800 || late > 2200 {
+		t.Errorf("expected about 2000 documents out of 10000 to arrive late, got %d", late)
+	}
+
+	// the documents without @timestamp are left as they are
+	rewritten, err := middleware([]byte(`{"message":"GET / 200"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(rewritten) != `{"message":"GET / 200"}` {
+		t.Errorf("expected the document without @timestamp to be left as it is, got %s", string(rewritten))
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// placeholderReferenceRegex matches the references to a field in a placeholder template
+	placeholderReferenceRegex = regexp.MustCompile(`{{\.([^}]+)}}`)
+	// generateReferenceRegex matches the actions of a text template whose value is the one generated for a field
+	generateReferenceRegex = regexp.MustCompile(`{{-?\s*generate\s+"([^"]+)"\s*-?}}`)
+)
+
+// LintTemplate returns a message for each issue of the template of gen with the fields definition flds:
+// fields referenced by the template and not in flds, fields in flds not referenced by the template and,
+// for JSON placeholder and gotext templates, fields whose value is quoted, or not, against their type.
+func LintTemplate(gen Generator, flds Fields) []string {
+	referenced := gen.Fields()
+	issues := undefinedIssues(referenced)
+	for _, field := range flds {
+		if !isReferenced(field, referenced) {
+			issues = append(issues, fmt.Sprintf("field %s: not referenced by the template", field.Name))
+		}
+	}
+
+	return append(issues, templateQuotingIssues(gen, referenced)...)
+}
+
+// LintReferences returns the issues of LintTemplate about the references of the template of gen: fields referenced
+// and not in the fields definition gen has been created with, and values quoted, or not, against their type.
+func LintReferences(gen Generator) []string {
+	referenced := gen.Fields()
+	return append(undefinedIssues(referenced), templateQuotingIssues(gen, referenced)...)
+}
+
+// undefinedIssues returns an issue for each field referenced by the template and not in the fields definition.
+func undefinedIssues(referenced []ReferencedField) []string {
+	var issues []string
+	for _, field := range referenced {
+		if len(field.Field.Type) == 0 {
+			issues = append(issues, fmt.Sprintf("field %s: referenced by the template but not found in the fields definition", field.Field.Name))
+		}
+	}
+
+	return issues
+}
+
+// templateQuotingIssues returns the quoting issues of the JSON placeholder and gotext templates.
+func templateQuotingIssues(gen Generator, referenced []ReferencedField) []string {
+	referenceRegex := placeholderReferenceRegex
+	switch gen.(type) {
+	case *GeneratorWithTextTemplate:
+		referenceRegex = generateReferenceRegex
+	case *GeneratorWithStructuredTemplate:
+		// the values of structured templates are quoted according to the generated values
+		return nil
+	}
+
+	return quotingIssues(gen.Template(), referenceRegex, referenced)
+}
+
+// isReferenced tells whether the field, or one of its keys for object fields, is referenced.
+func isReferenced(field Field, referenced []ReferencedField) bool {
+	for _, referencedField := range referenced {
+		if referencedField.Field.Name == field.Name {
+			return true
+		}
+
+		isObject := strings.HasSuffix(field.Name, ".*") || field.Type == FieldTypeObject || field.Type == FieldTypeNested || field.Type == FieldTypeFlattened
+		if isObject && strings.HasPrefix(referencedField.Field.Name, replacer.Replace(field.Name)+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// quotingIssues checks that in a JSON template the string values are quoted and the numeric and boolean ones are not.
+func quotingIssues(template []byte, referenceRegex *regexp.Regexp, referenced []ReferencedField) []string {
+	trimmed := bytes.TrimSpace(template)
+	if !bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("{{")) {
+		return nil
+	}
+
+	types := make(map[string]string, len(referenced))
+	for _, field := range referenced {
+		types[field.Field.Name] = field.Field.Type
+	}
+
+	var issues []string
+	reported := make(map[string]struct{})
+	for _, loc := range referenceRegex.FindAllSubmatchIndex(template, -1) {
+		fieldName := string(template[loc[2]:loc[3]])
+		if _, ok := reported[fieldName]; ok {
+			continue
+		}
+
+		quoted := bytes.HasSuffix(template[:loc[0]], []byte(`"`)) && bytes.HasPrefix(template[loc[1]:], []byte(`"`))
+		fieldType := types[fieldName]
+		switch fieldType {
+		case "":
+			continue
+		case FieldTypeDouble, FieldTypeFloat, FieldTypeHalfFloat, FieldTypeScaledFloat, FieldTypeInteger, FieldTypeLong, FieldTypeUnsignedLong, FieldTypeBool:
+			if !quoted {
+				continue
+			}
+
+			issues = append(issues, fmt.Sprintf("field %s: value of type %s is quoted in the template, it is generated as a string", fieldName, fieldType))
+		case FieldTypeGeoPoint, FieldTypeObject, FieldTypeNested, FieldTypeFlattened:
+			continue
+		default:
+			if quoted {
+				continue
+			}
+
+			issues = append(issues, fmt.Sprintf("field %s: value of type %s is not quoted in the template, it is generated as invalid JSON", fieldName, fieldType))
+		}
+
+		reported[fieldName] = struct{}{}
+	}
+
+	return issues
+}
@@ -0,0 +1,60 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+func Test_LintTemplateWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+		{Name: "gamma", Type: FieldTypeBool},
+		{Name: "delta.*", Type: FieldTypeObject, ObjectType: FieldTypeLong},
+		{Name: "epsilon", Type: FieldTypeKeyword},
+	}
+
+	testCases := []struct {
+		template string
+		expected []string
+	}{
+		{
+			template: `{"alpha":"{{generate "alpha"}}","beta":{{generate "beta"}},"gamma":{{- generate "gamma" -}},"delta":{{generate "delta.one"}},"epsilon":"{{generate "epsilon" | upper}}"}`,
+			expected: nil,
+		},
+		{
+			template: `{"alpha":{{generate "alpha"}},"beta":"{{generate "beta"}}","zeta":"{{generate "zeta"}}"}`,
+			expected: []string{
+				"field zeta: referenced by the template but not found in the fields definition",
+				"field gamma: not referenced by the template",
+				"field delta.*: not referenced by the template",
+				"field epsilon: not referenced by the template",
+				"field alpha: value of type keyword is not quoted in the template, it is generated as invalid JSON",
+				"field beta: value of type long is quoted in the template, it is generated as a string",
+			},
+		},
+		{
+			template: `{{generate "alpha"}} {{generate "beta"}} {{generate "gamma"}} {{generate "delta.one"}} {{generate "epsilon"}}`,
+			expected: nil,
+		},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: delta.*\n  object_keys: [\"one\"]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testCase := range testCases {
+		g, _ := makeGeneratorWithTextTemplate(t, cfg, flds, []byte(testCase.template))
+		issues := LintTemplate(g, flds)
+		if !reflect.DeepEqual(testCase.expected, issues) {
+			t.Errorf("template %s: expected %q, got %q", testCase.template, testCase.expected, issues)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// The ways NewMalformed breaks the documents
+const (
+	// MalformedInvalidJSON truncates the document, so that it is not valid JSON anymore
+	MalformedInvalidJSON = "invalid_json"
+	// MalformedWrongType replaces the value of a field with a value of another type: an object for a string, a
+	// string otherwise
+	MalformedWrongType = "wrong_type"
+	// MalformedMissingField removes the @timestamp field, or another field if the document has none
+	MalformedMissingField = "missing_field"
+)
+
+// trailingSpace is the trailing whitespace of the documents kept by the middlewares rewriting some of them only, so
+// that the documents rewritten are written like the other ones
+const trailingSpace = " \t\r\n"
+
+var malformedKinds = []string{MalformedInvalidJSON, MalformedWrongType, MalformedMissingField}
+
+// malformedValue is the value of the fields broken by MalformedWrongType, for the values that are not strings
+const malformedValue = "malformed"
+
+// NewMalformed returns a Middleware breaking a percentage of the documents, from 0 to 100, to test how the ingest
+// pipelines and the dead letter queues handle the failures: each broken document is broken one of the ways of
+// MalformedInvalidJSON, MalformedWrongType and MalformedMissingField, drawn at random. The documents that are not
+// JSON objects can only be truncated. The other documents are left untouched.
+func NewMalformed(percentage float64) (Middleware, error) {
+	if percentage < 0 || percentage > 100 {
+		return nil, fmt.Errorf("malformed percentage must be between 0 and 100, got %v", percentage)
+	}
+
+	var buf bytes.Buffer
+	return func(doc []byte) ([]byte, error) {
+		if percentage == 0 || rand.Float64()*100 >= percentage {
+			return doc, nil
+		}
+
+		kind := malformedKinds[rand.Intn(len(malformedKinds))]
+		if kind == MalformedInvalidJSON {
+			return truncateDocument(doc), nil
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(doc))
+		decoder.UseNumber()
+		var event map[string]interface{}
+		if err := decoder.Decode(&event); err != nil || event == nil {
+			return truncateDocument(doc), nil
+		}
+
+		leaves := collectLeaves(event, nil)
+		if len(leaves) == 0 {
+			return truncateDocument(doc), nil
+		}
+
+		switch kind {
+		case MalformedWrongType:
+			leaf := leaves[rand.Intn(len(leaves))]
+			if _, ok := leaf.obj[leaf.key].(string); ok {
+				leaf.obj[leaf.key] = map[string]interface{}{malformedValue: true}
+			} else {
+				leaf.obj[leaf.key] = malformedValue
+			}
+		case MalformedMissingField:
+			if obj, key, found := lookupPath(event, "@timestamp"); found {
+				delete(obj, key)
+			} else {
+				leaf := leaves[rand.Intn(len(leaves))]
+				delete(leaf.obj, leaf.key)
+			}
+		}
+
+		buf.Reset()
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(event); err != nil {
+			return nil, err
+		}
+
+		// the broken documents keep their trailing whitespace, like the documents left untouched
+		trimmed := bytes.TrimRight(doc, trailingSpace)
+		return append(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), doc[len(trimmed):]...), nil
+	}, nil
+}
+
+// truncateDocument returns doc cut before its last byte, at a random offset, keeping its trailing whitespace.
+func truncateDocument(doc []byte) []byte {
+	trimmed := bytes.TrimRight(doc, trailingSpace)
+	if len(trimmed) < 2 {
+		return doc[:0]
+	}
+
+	cut := 1 + rand.Intn(len(trimmed)-1)
+	return append(trimmed[:cut:cut], doc[len(trimmed):]...)
+}
+
+// leaf is a field of a document that is not an object, the key of obj
+type leaf struct {
+	obj map[string]interface{}
+	key string
+}
+
+// collectLeaves appends to leaves the fields of obj that are not objects, at any depth, in the order of their keys,
+// so that the same seed breaks the same fields.
+func collectLeaves(obj map[string]interface{}, leaves []leaf) []leaf {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		if child, ok := obj[key].(map[string]interface{}); ok {
+			leaves = collectLeaves(child, leaves)
+			continue
+		}
+
+		leaves = append(leaves, leaf{obj: obj, key: key})
+	}
+
+	return leaves
+}
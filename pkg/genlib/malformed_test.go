@@ -0,0 +1,109 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_NewMalformed(t *testing.T) {
+	const doc = `{"@timestamp":"2024-01-01T00:00:00Z","host":{"name":"alpha","ip":"10.0.0.1"},"bytes":1024}`
+
+	if _, err := NewMalformed(101); err == nil {
+		t.Errorf("expected an error for a percentage over 100")
+	}
+
+	middleware, err := NewMalformed(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		broken, err := middleware([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(broken) != doc {
+			t.Fatalf("expected %s to be left untouched, got %s", doc, string(broken))
+		}
+	}
+
+	InitGeneratorRandSeed(1)
+	middleware, err = NewMalformed(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := map[string]int{}
+	for i := 0; i < 300; i++ {
+		broken, err := middleware([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var event map[string]interface{}
+		if err := json.Unmarshal(broken, &event); err != nil {
+			kinds[MalformedInvalidJSON]++
+			continue
+		}
+
+		if _, ok := event["@timestamp"]; !ok {
+			kinds[MalformedMissingField]++
+			continue
+		}
+
+		host := event["host"].(map[string]interface{})
+		_, nameIsObject := host["name"].(map[string]interface{})
+		_, ipIsObject := host["ip"].(map[string]interface{})
+		_, timestampIsObject := event["@timestamp"].(map[string]interface{})
+		if nameIsObject || ipIsObject || timestampIsObject || event["bytes"] == malformedValue {
+			kinds[MalformedWrongType]++
+			continue
+		}
+
+		t.Fatalf("expected %s to be broken, got %s", doc, string(broken))
+	}
+
+	for _, kind := range malformedKinds {
+		if kinds[kind] == 0 {
+			t.Errorf("expected some documents to be broken with %s", kind)
+		}
+	}
+
+	// the documents that are not JSON objects are truncated
+	broken, err := middleware([]byte("GET / 200\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(broken) >= len("GET / 200\n") || broken[len(broken)-1] != '\n' {
+		t.Errorf("expected the document to be truncated, got %q", string(broken))
+	}
+
+	// about the percentage of the documents are broken
+	InitGeneratorRandSeed(1)
+	middleware, err = NewMalformed(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	for i := 0; i < 10000; i++ {
+		broken, err := middleware([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(broken) != doc {
+			count++
+		}
+	}
+
+	if count < 800 || count > 1200 {
+		t.Errorf("expected about 1000 documents out of 10000 to be broken, got %d", count)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// referenceFieldName is the name the n-th reference of a field in a custom template is keyed under in the
+// template fields map, counting from zero, so that the chunks preceding each reference do not overwrite each other.
+func referenceFieldName(fieldName string, n int) string {
+	if n == 0 {
+		return fieldName
+	}
+
+	return fmt.Sprintf("%s#ref%d", fieldName, n)
+}
+
+// referenceFieldNames returns the names the references in orderedFields are keyed under in the template fields map.
+func referenceFieldNames(orderedFields []string) []string {
+	counts := make(map[string]int, len(orderedFields))
+	names := make([]string, 0, len(orderedFields))
+	for _, fieldName := range orderedFields {
+		names = append(names, referenceFieldName(fieldName, counts[fieldName]))
+		counts[fieldName]++
+	}
+
+	return names
+}
+
+// memoizedFieldNames returns the fields referenced more than once in orderedFields, whose value is generated
+// once for each event and reused by all their references, unless they reroll.
+func memoizedFieldNames(cfg Config, orderedFields []string) map[string]struct{} {
+	counts := make(map[string]int, len(orderedFields))
+	for _, fieldName := range orderedFields {
+		counts[fieldName]++
+	}
+
+	memoized := make(map[string]struct{})
+	for fieldName, count := range counts {
+		if fieldCfg, _ := cfg.GetField(fieldName); count > 1 && !fieldCfg.Reroll {
+			memoized[fieldName] = struct{}{}
+		}
+	}
+
+	return memoized
+}
+
+// makeMemoStub memoizes the value written by the bound function of the first reference of a field,
+// so that the next references of the field in the event write it again.
+func makeMemoStub(fieldName string, prefixLen int, boundF emitFNotReturn) emitFNotReturn {
+	return func(state *GenState, buf *bytes.Buffer) error {
+		offset := buf.Len()
+		if err := boundF(state, buf); err != nil {
+			return err
+		}
+
+		value := buf.Bytes()[offset:]
+		if len(value) >= prefixLen {
+			value = value[prefixLen:]
+		}
+
+		state.memoValues[fieldName] = string(value)
+		return nil
+	}
+}
+
+// makeReferenceStub writes a reference of a field following the first one, preceded by its own template chunk:
+// the value memoized by the first reference if any, otherwise a new value from the bound function, without the
+// template chunk of the first reference it writes.
+func makeReferenceStub(fieldName string, prefix []byte, firstPrefixLen int, boundF emitFNotReturn) emitFNotReturn {
+	return func(state *GenState, buf *bytes.Buffer) error {
+		buf.Write(prefix)
+		if value, ok := state.memoValues[fieldName].(string); ok {
+			buf.WriteString(value)
+			return nil
+		}
+
+		offset := buf.Len()
+		if err := boundF(state, buf); err != nil {
+			return err
+		}
+
+		b := buf.Bytes()
+		if len(b)-offset >= firstPrefixLen {
+			copy(b[offset:], b[offset+firstPrefixLen:])
+			buf.Truncate(len(b) - firstPrefixLen)
+		}
+
+		return nil
+	}
+}
+
+// makeMemoStubWithReturn memoizes the value returned by the bound function, returning it again for the next
+// references of the field in the event.
+func makeMemoStubWithReturn(fieldName string, boundF EmitF) EmitF {
+	return func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
+		if value, ok := state.memoValues[fieldName]; ok {
+			return value, nil
+		}
+
+		value, err := boundF(state, buf)
+		if err != nil {
+			return value, err
+		}
+
+		state.memoValues[fieldName] = value
+		return value, nil
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// maxDroppedDocuments is the count of documents in a row the middlewares can drop before the generation fails
+const maxDroppedDocuments = 1000
+
+// Middleware processes a document after it has been emitted: it returns the document, possibly modified, or nil to drop it.
+// The document can be modified in place, it is not retained after the middleware returns.
+type Middleware func(doc []byte) ([]byte, error)
+
+// generatorWithMiddlewares is a Generator passing the documents emitted by the wrapped one through the middlewares
+type generatorWithMiddlewares struct {
+	Generator
+	middlewares []Middleware
+	tmp         bytes.Buffer
+}
+
+// WithMiddlewares wraps gen so that each emitted document goes through the middlewares, in order, before being written to the buffer.
+// A document dropped by a middleware is replaced by a new one emitted by gen.
+func WithMiddlewares(gen Generator, middlewares ...Middleware) Generator {
+	if len(middlewares) == 0 {
+		return gen
+	}
+
+	return &generatorWithMiddlewares{Generator: gen, middlewares: middlewares}
+}
+
+func (gen *generatorWithMiddlewares) Emit(state *GenState, buf *bytes.Buffer) error {
+	for dropped := 0; dropped < maxDroppedDocuments; dropped++ {
+		gen.tmp.Reset()
+		if err := gen.Generator.Emit(state, &gen.tmp); err != nil {
+			return err
+		}
+
+		doc := gen.tmp.Bytes()
+		for _, middleware := range gen.middlewares {
+			var err error
+			if doc, err = middleware(doc); err != nil {
+				return err
+			}
+
+			if doc == nil {
+				break
+			}
+		}
+
+		if doc != nil {
+			buf.Write(doc)
+			return nil
+		}
+
+		// the traces of the dropped document must go as well
+		state.SetTracing(state.tracing)
+	}
+
+	return fmt.Errorf("middlewares dropped %d documents in a row", maxDroppedDocuments)
+}
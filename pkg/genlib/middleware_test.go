@@ -0,0 +1,67 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+func Test_WithMiddlewares(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeKeyword,
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: alpha\n  enum: [\"a\", \"b\"]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, state := makeGeneratorWithCustomTemplate(t, cfg, []Field{fld}, []byte(`{"alpha":"{{.alpha}}"}`))
+
+	var passed int
+	g = WithMiddlewares(g,
+		func(doc []byte) ([]byte, error) {
+			if bytes.Contains(doc, []byte(`"b"`)) {
+				return nil, nil
+			}
+
+			return doc, nil
+		},
+		func(doc []byte) ([]byte, error) {
+			passed += 1
+			return bytes.ToUpper(doc), nil
+		},
+	)
+
+	nSpins := 128
+	for i := 0; i < nSpins; i++ {
+		var buf bytes.Buffer
+		if err := g.Emit(state, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if buf.String() != `{"ALPHA":"A"}` {
+			t.Errorf("unexpected document %s", buf.String())
+		}
+	}
+
+	if passed != nSpins {
+		t.Errorf("expected %d documents to reach the last middleware, got %d", nSpins, passed)
+	}
+
+	g = WithMiddlewares(g, func(doc []byte) ([]byte, error) {
+		return nil, errors.New("middleware error")
+	})
+
+	var buf bytes.Buffer
+	if err := g.Emit(state, &buf); err == nil || err.Error() != "middleware error" {
+		t.Errorf("expected middleware error, got %v", err)
+	}
+}
@@ -0,0 +1,456 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// OTLPLogs writes each event as a log record
+	OTLPLogs = "logs"
+	// OTLPMetrics writes the values of the metric fields of each event as data points
+	OTLPMetrics = "metrics"
+)
+
+// otlpScopeName is the name of the instrumentation scope of the OTLP records
+const otlpScopeName = "elastic-integration-corpus-generator-tool"
+
+// OTLPMapping maps the flat field names of the events to OTLP.
+type OTLPMapping struct {
+	// ResourcePrefixes are the prefixes of the fields that are attributes of the resource, like host., the other
+	// fields are attributes of the log record or of the data points
+	ResourcePrefixes []string
+	// Renames are the attribute names of the fields named differently by the OpenTelemetry semantic conventions
+	Renames map[string]string
+}
+
+// DefaultOTLPMapping returns the mapping of the ECS fields to the OpenTelemetry semantic conventions: the fields
+// describing where the events come from, like host.name or kubernetes.pod.name, are attributes of the resource.
+func DefaultOTLPMapping() OTLPMapping {
+	return OTLPMapping{
+		ResourcePrefixes: []string{"agent.", "cloud.", "container.", "host.", "kubernetes.", "orchestrator.", "service."},
+		Renames: map[string]string{
+			"host.os.name":               "os.name",
+			"host.os.type":               "os.type",
+			"host.os.version":            "os.version",
+			"kubernetes.container.name":  "k8s.container.name",
+			"kubernetes.deployment.name": "k8s.deployment.name",
+			"kubernetes.namespace":       "k8s.namespace.name",
+			"kubernetes.node.name":       "k8s.node.name",
+			"kubernetes.pod.name":        "k8s.pod.name",
+			"kubernetes.pod.uid":         "k8s.pod.uid",
+			"service.environment":        "deployment.environment",
+		},
+	}
+}
+
+// NewOTLP returns a Middleware rewriting the JSON documents as OTLP/JSON export requests of signal, either OTLPLogs
+// or OTLPMetrics, one per document, as read by the otlpjsonfile receiver of the OpenTelemetry collector.
+//
+// The @timestamp field is the time of the records. A log record has the message field as its body, log.level as its
+// severity and trace.id and span.id as its trace context. The data points are the values of the fields of flds with
+// a metric_type: gauge fields are gauges, counter fields cumulative monotonic sums. The other fields are attributes
+// of the resource or of the records, according to mapping.
+func NewOTLP(signal string, flds Fields, mapping OTLPMapping) (Middleware, error) {
+	o := otlp{mapping: mapping, types: make(map[string]string, len(flds))}
+	for _, field := range flds {
+		o.types[field.Name] = field.Type
+	}
+
+	var rewrite func(attributes map[string]interface{}) (interface{}, bool)
+	switch signal {
+	case OTLPLogs:
+		rewrite = o.logs
+	case OTLPMetrics:
+		o.metrics = make(map[string]Field)
+		for _, field := range flds {
+			if field.MetricType == "gauge" || field.MetricType == "counter" {
+				o.metrics[field.Name] = field
+			}
+		}
+
+		if len(o.metrics) == 0 {
+			return nil, errors.New("cannot write OTLP metrics: no field has a gauge or counter metric_type")
+		}
+
+		rewrite = o.metricsRequest
+	default:
+		return nil, fmt.Errorf("unknown OTLP signal %s, must be either %s or %s", signal, OTLPLogs, OTLPMetrics)
+	}
+
+	var buf bytes.Buffer
+	return func(doc []byte) ([]byte, error) {
+		decoder := json.NewDecoder(bytes.NewReader(doc))
+		decoder.UseNumber()
+		var v map[string]interface{}
+		if err := decoder.Decode(&v); err != nil {
+			return nil, fmt.Errorf("cannot write as OTLP a document that is not a JSON object: %w", err)
+		}
+
+		attributes := make(map[string]interface{}, len(v))
+		flattenObject(v, "", attributes)
+
+		request, ok := rewrite(attributes)
+		if !ok {
+			return nil, nil
+		}
+
+		buf.Reset()
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(request); err != nil {
+			return nil, err
+		}
+
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+	}, nil
+}
+
+type otlp struct {
+	mapping OTLPMapping
+	// types are the types of the fields by name
+	types map[string]string
+	// metrics are the fields written as metrics by name, with OTLPMetrics only
+	metrics map[string]Field
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+	// IntValue is a string, as the int64 values in OTLP/JSON
+	IntValue    string          `json:"intValue,omitempty"`
+	DoubleValue json.Number     `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano,omitempty"`
+	SeverityNumber int            `json:"severityNumber,omitempty"`
+	SeverityText   string         `json:"severityText,omitempty"`
+	Body           *otlpAnyValue  `json:"body,omitempty"`
+	Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+	TraceID        string         `json:"traceId,omitempty"`
+	SpanID         string         `json:"spanId,omitempty"`
+}
+
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpMetric struct {
+	Name  string     `json:"name"`
+	Unit  string     `json:"unit,omitempty"`
+	Gauge *otlpGauge `json:"gauge,omitempty"`
+	Sum   *otlpSum   `json:"sum,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+	// AggregationTemporality is 2 for cumulative
+	AggregationTemporality int  `json:"aggregationTemporality"`
+	IsMonotonic            bool `json:"isMonotonic"`
+}
+
+type otlpDataPoint struct {
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	TimeUnixNano string         `json:"timeUnixNano,omitempty"`
+	AsInt        string         `json:"asInt,omitempty"`
+	AsDouble     json.Number    `json:"asDouble,omitempty"`
+}
+
+// otlpAggregationTemporalityCumulative is the aggregation temporality of the counter fields
+const otlpAggregationTemporalityCumulative = 2
+
+// otlpSeverityNumbers are the severity numbers of the log.level values
+var otlpSeverityNumbers = map[string]int{
+	"trace":     1,
+	"debug":     5,
+	"info":      9,
+	"notice":    10,
+	"warn":      13,
+	"warning":   13,
+	"error":     17,
+	"err":       17,
+	"critical":  21,
+	"crit":      21,
+	"alert":     22,
+	"fatal":     21,
+	"emergency": 23,
+}
+
+// otlpUnits are the UCUM units of the unit of the fields
+var otlpUnits = map[string]string{
+	"byte":    "By",
+	"percent": "1",
+	"d":       "d",
+	"h":       "h",
+	"m":       "min",
+	"s":       "s",
+	"ms":      "ms",
+	"micros":  "us",
+	"nanos":   "ns",
+}
+
+func (o otlp) logs(attributes map[string]interface{}) (interface{}, bool) {
+	record := otlpLogRecord{TimeUnixNano: otlpTime(attributes)}
+	if message, ok := attributes["message"]; ok {
+		body := o.anyValue("message", message)
+		record.Body = &body
+		delete(attributes, "message")
+	}
+
+	if level, ok := attributes["log.level"].(string); ok {
+		record.SeverityText = level
+		record.SeverityNumber = otlpSeverityNumbers[strings.ToLower(level)]
+		delete(attributes, "log.level")
+	}
+
+	if traceID, ok := attributes["trace.id"].(string); ok {
+		record.TraceID = traceID
+		delete(attributes, "trace.id")
+	}
+
+	if spanID, ok := attributes["span.id"].(string); ok {
+		record.SpanID = spanID
+		delete(attributes, "span.id")
+	}
+
+	resource, recordAttributes := o.splitAttributes(attributes)
+	record.Attributes = recordAttributes
+
+	return otlpLogsRequest{ResourceLogs: []otlpResourceLogs{{
+		Resource: otlpResource{Attributes: resource},
+		ScopeLogs: []otlpScopeLogs{{
+			Scope:      otlpScope{Name: otlpScopeName},
+			LogRecords: []otlpLogRecord{record},
+		}},
+	}}}, true
+}
+
+func (o otlp) metricsRequest(attributes map[string]interface{}) (interface{}, bool) {
+	timeUnixNano := otlpTime(attributes)
+
+	values := make(map[string]json.Number)
+	for name := range o.metrics {
+		value, ok := attributes[name]
+		if !ok {
+			continue
+		}
+
+		delete(attributes, name)
+		if n, ok := value.(json.Number); ok {
+			values[name] = n
+		}
+	}
+
+	// an event without metric values has no data point
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	resource, pointAttributes := o.splitAttributes(attributes)
+	metrics := make([]otlpMetric, 0, len(values))
+	for _, name := range sortedKeys(values) {
+		field := o.metrics[name]
+		point := otlpDataPoint{Attributes: pointAttributes, TimeUnixNano: timeUnixNano}
+		if isOTLPDouble(field.Type, values[name]) {
+			point.AsDouble = values[name]
+		} else {
+			point.AsInt = values[name].String()
+		}
+
+		metric := otlpMetric{Name: name, Unit: otlpUnits[field.Unit]}
+		if field.MetricType == "counter" {
+			metric.Sum = &otlpSum{DataPoints: []otlpDataPoint{point}, AggregationTemporality: otlpAggregationTemporalityCumulative, IsMonotonic: true}
+		} else {
+			metric.Gauge = &otlpGauge{DataPoints: []otlpDataPoint{point}}
+		}
+
+		metrics = append(metrics, metric)
+	}
+
+	return otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: resource},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: otlpScopeName},
+			Metrics: metrics,
+		}},
+	}}}, true
+}
+
+// splitAttributes returns the attributes of the resource and the other ones, sorted by key.
+func (o otlp) splitAttributes(attributes map[string]interface{}) ([]otlpKeyValue, []otlpKeyValue) {
+	var resource, other []otlpKeyValue
+	for _, name := range sortedKeys(attributes) {
+		key := name
+		if renamed, ok := o.mapping.Renames[name]; ok {
+			key = renamed
+		}
+
+		kv := otlpKeyValue{Key: key, Value: o.anyValue(name, attributes[name])}
+		if o.isResource(name) {
+			resource = append(resource, kv)
+		} else {
+			other = append(other, kv)
+		}
+	}
+
+	return resource, other
+}
+
+func (o otlp) isResource(name string) bool {
+	for _, prefix := range o.mapping.ResourcePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// anyValue returns the OTLP value of v, the value of the field name.
+func (o otlp) anyValue(name string, v interface{}) otlpAnyValue {
+	switch v := v.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &v}
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case json.Number:
+		if isOTLPDouble(o.types[name], v) {
+			return otlpAnyValue{DoubleValue: v}
+		}
+
+		return otlpAnyValue{IntValue: v.String()}
+	case []interface{}:
+		values := make([]otlpAnyValue, 0, len(v))
+		for _, value := range v {
+			values = append(values, o.anyValue(name, value))
+		}
+
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	default:
+		// the objects in arrays are written as JSON strings
+		b, _ := json.Marshal(v)
+		s := string(b)
+		return otlpAnyValue{StringValue: &s}
+	}
+}
+
+// isOTLPDouble returns true if n, the value of a field of type fieldType, is a double.
+func isOTLPDouble(fieldType string, n json.Number) bool {
+	switch fieldType {
+	case FieldTypeDouble, FieldTypeFloat, FieldTypeHalfFloat, FieldTypeScaledFloat:
+		return true
+	}
+
+	_, err := n.Int64()
+	return err != nil
+}
+
+// otlpTime returns the time of the @timestamp attribute as nanoseconds since the epoch, removing the attribute,
+// or an empty string if there is none.
+func otlpTime(attributes map[string]interface{}) string {
+	value, ok := attributes["@timestamp"]
+	if !ok {
+		return ""
+	}
+
+	delete(attributes, "@timestamp")
+	switch value := value.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return ""
+		}
+
+		return strconv.FormatInt(t.UnixNano(), 10)
+	case json.Number:
+		// epoch milliseconds
+		millis, err := value.Int64()
+		if err != nil {
+			return ""
+		}
+
+		return strconv.FormatInt(millis*int64(time.Millisecond), 10)
+	default:
+		return ""
+	}
+}
+
+// flattenObject adds the values of obj to flat keyed by their dotted path, dropping the null values.
+func flattenObject(obj map[string]interface{}, path string, flat map[string]interface{}) {
+	for key, value := range obj {
+		switch value := value.(type) {
+		case nil:
+		case map[string]interface{}:
+			flattenObject(value, joinPath(path, key), flat)
+		default:
+			flat[joinPath(path, key)] = value
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,66 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"testing"
+)
+
+func Test_NewOTLP(t *testing.T) {
+	flds := Fields{
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "kubernetes.pod.name", Type: FieldTypeKeyword},
+		{Name: "http.response.status_code", Type: FieldTypeLong},
+		{Name: "system.cpu.total.pct", Type: FieldTypeScaledFloat, MetricType: "gauge", Unit: "percent"},
+		{Name: "system.network.in.bytes", Type: FieldTypeLong, MetricType: "counter", Unit: "byte"},
+	}
+
+	testCases := []struct {
+		signal   string
+		doc      string
+		expected string
+	}{
+		{
+			signal:   OTLPLogs,
+			doc:      `{"@timestamp":"2024-01-01T00:00:01.5Z","message":"GET / 200","log":{"level":"WARN"},"host.name":"web-1","kubernetes":{"pod":{"name":"web-1-abc"}},"http.response.status_code":200,"trace.id":"0af7651916cd43dd8448eb211c80319c","tags":["a","b"],"none":null}`,
+			expected: `{"resourceLogs":[{"resource":{"attributes":[{"key":"host.name","value":{"stringValue":"web-1"}},{"key":"k8s.pod.name","value":{"stringValue":"web-1-abc"}}]},"scopeLogs":[{"scope":{"name":"elastic-integration-corpus-generator-tool"},"logRecords":[{"timeUnixNano":"1704067201500000000","severityNumber":13,"severityText":"WARN","body":{"stringValue":"GET / 200"},"attributes":[{"key":"http.response.status_code","value":{"intValue":"200"}},{"key":"tags","value":{"arrayValue":{"values":[{"stringValue":"a"},{"stringValue":"b"}]}}}],"traceId":"0af7651916cd43dd8448eb211c80319c"}]}]}]}`,
+		},
+		{
+			signal:   OTLPMetrics,
+			doc:      `{"@timestamp":1704067200000,"host":{"name":"web-1"},"system":{"cpu":{"total":{"pct":1}},"network":{"in":{"bytes":1024}}},"event.dataset":"system.cpu"}`,
+			expected: `{"resourceMetrics":[{"resource":{"attributes":[{"key":"host.name","value":{"stringValue":"web-1"}}]},"scopeMetrics":[{"scope":{"name":"elastic-integration-corpus-generator-tool"},"metrics":[{"name":"system.cpu.total.pct","unit":"1","gauge":{"dataPoints":[{"attributes":[{"key":"event.dataset","value":{"stringValue":"system.cpu"}}],"timeUnixNano":"1704067200000000000","asDouble":1}]}},{"name":"system.network.in.bytes","unit":"By","sum":{"dataPoints":[{"attributes":[{"key":"event.dataset","value":{"stringValue":"system.cpu"}}],"timeUnixNano":"1704067200000000000","asInt":"1024"}],"aggregationTemporality":2,"isMonotonic":true}}]}]}]}`,
+		},
+		{
+			// an event without metric values is dropped
+			signal:   OTLPMetrics,
+			doc:      `{"host":{"name":"web-1"}}`,
+			expected: ``,
+		},
+	}
+
+	for _, tc := range testCases {
+		otlp, err := NewOTLP(tc.signal, flds, DefaultOTLPMapping())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		doc, err := otlp([]byte(tc.doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(doc) != tc.expected {
+			t.Errorf("expected %s to be rewritten as OTLP %s to %s, got %s", tc.doc, tc.signal, tc.expected, string(doc))
+		}
+	}
+
+	if _, err := NewOTLP(OTLPMetrics, flds[:3], DefaultOTLPMapping()); err == nil {
+		t.Errorf("expected error for OTLP metrics without metric fields")
+	}
+
+	if _, err := NewOTLP("traces", flds, DefaultOTLPMapping()); err == nil {
+		t.Errorf("expected error for an unknown OTLP signal")
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// templatePartialRegex matches the actions including a partial, like {{ template "header" }} or
+// {{- template "header" . -}}: their left trim marker, the name of the partial and their right trim marker
+var templatePartialRegex = regexp.MustCompile(`{{(-?)\s*template\s+"([^"]+)"(?:\s+\.)?\s*(-?)}}`)
+
+// LoadTemplatePartials loads the partials of the templates from the files of dir, each one named after its file
+// without the extension, like header for header.json. The trailing newlines of the files are dropped, so that a
+// partial does not break an event across lines.
+func LoadTemplatePartials(dir string) (map[string][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	partials := make(map[string][]byte, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if _, ok := partials[name]; ok {
+			return nil, fmt.Errorf("more than one partial named %s in %s", name, dir)
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		partials[name] = bytes.TrimRight(content, "\r\n")
+	}
+
+	return partials, nil
+}
+
+// ExpandTemplatePartials replaces the actions of template including one of partials, like {{ template "header" }},
+// with the partial, its own actions expanded as well: the same fragments can be shared by the templates of many data
+// streams, whatever their type. The trim markers of the actions trim the spaces around them as in the gotext
+// templates. The actions including other templates, like the ones defined by a gotext template itself, are left as
+// they are.
+func ExpandTemplatePartials(template []byte, partials map[string][]byte) ([]byte, error) {
+	return expandTemplatePartials(template, partials, nil)
+}
+
+// expandTemplatePartials expands the partials of template, included by the partials of the stack.
+func expandTemplatePartials(template []byte, partials map[string][]byte, stack []string) ([]byte, error) {
+	matches := templatePartialRegex.FindAllSubmatchIndex(template, -1)
+	if len(matches) == 0 {
+		return template, nil
+	}
+
+	var expanded bytes.Buffer
+	var trimLeading bool
+	last := 0
+	for _, match := range matches {
+		name := string(template[match[4]:match[5]])
+		partial, ok := partials[name]
+		if !ok {
+			continue
+		}
+
+		for _, including := range stack {
+			if including == name {
+				return nil, fmt.Errorf("partial %s includes itself through %s", name, strings.Join(append(stack, name), ", "))
+			}
+		}
+
+		partial, err := expandTemplatePartials(partial, partials, append(stack, name))
+		if err != nil {
+			return nil, err
+		}
+
+		preceding := template[last:match[0]]
+		if trimLeading {
+			preceding = bytes.TrimLeft(preceding, " \t\r\n")
+		}
+
+		if match[3] > match[2] {
+			preceding = bytes.TrimRight(preceding, " \t\r\n")
+		}
+
+		expanded.Write(preceding)
+		expanded.Write(partial)
+		trimLeading = match[7] > match[6]
+		last = match[1]
+	}
+
+	following := template[last:]
+	if trimLeading {
+		following = bytes.TrimLeft(following, " \t\r\n")
+	}
+
+	expanded.Write(following)
+	return expanded.Bytes(), nil
+}
@@ -0,0 +1,73 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ExpandTemplatePartials(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"envelope.json": `"agent":{"type":"filebeat"},{{ template "host" }}` + "\n",
+		"host.tpl":      `"host":{"name":"{{.host.name}}"}`,
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	partials, err := LoadTemplatePartials(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		template string
+		expected string
+	}{
+		{
+			template: `{"message":"{{.message}}",{{ template "envelope" }}}`,
+			expected: `{"message":"{{.message}}","agent":{"type":"filebeat"},"host":{"name":"{{.host.name}}"}}`,
+		},
+		{
+			template: "{\n  {{- template \"host\" . -}}\n}",
+			expected: `{"host":{"name":"{{.host.name}}"}}`,
+		},
+		{
+			// the templates defined by the template itself are not partials
+			template: `{{define "event"}}{}{{end}}{{template "event"}} {{template "host"}}`,
+			expected: `{{define "event"}}{}{{end}}{{template "event"}} "host":{"name":"{{.host.name}}"}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		expanded, err := ExpandTemplatePartials([]byte(testCase.template), partials)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(expanded) != testCase.expected {
+			t.Errorf("expected %s, got %s", testCase.expected, string(expanded))
+		}
+	}
+
+	partials["host"] = []byte(`{{template "envelope"}}`)
+	if _, err := ExpandTemplatePartials([]byte(`{{template "envelope"}}`), partials); err == nil {
+		t.Errorf("expected error for partials including each other")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "host.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadTemplatePartials(dir); err == nil {
+		t.Errorf("expected error for two partials with the same name")
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// escapeFuncs are the functions the values of the fields can be piped to in the templates, like the XML rendered in
+// a JSON string of winlog events with {{.message | xmlEscape | jsonEscape}}: each one escapes in place the bytes of
+// buf after offset, tmp holds the bytes being escaped.
+var escapeFuncs = map[string]func(buf *bytes.Buffer, offset int, tmp *bytes.Buffer){
+	"xmlEscape":  escapeXMLString,
+	"jsonEscape": escapeJSONString,
+}
+
+// escapeFuncNames returns the names of escapeFuncs, sorted.
+func escapeFuncNames() []string {
+	names := make([]string, 0, len(escapeFuncs))
+	for name := range escapeFuncs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// splitPipeline splits the content of a placeholder of a custom template in the name of the field and the
+// functions its value is piped to, if any.
+func splitPipeline(placeholder string) (string, []string) {
+	if !strings.Contains(placeholder, "|") {
+		return placeholder, nil
+	}
+
+	parts := strings.Split(placeholder, "|")
+	pipeline := make([]string, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		pipeline = append(pipeline, strings.TrimSpace(part))
+	}
+
+	return strings.TrimSpace(parts[0]), pipeline
+}
+
+// checkPipeline returns an error if pipeline calls a function that is not an escape function.
+func checkPipeline(pipeline []string) error {
+	for _, funcName := range pipeline {
+		if _, ok := escapeFuncs[funcName]; !ok {
+			return fmt.Errorf("function %q not defined, the values can be piped to %s", funcName, strings.Join(escapeFuncNames(), " and "))
+		}
+	}
+
+	return nil
+}
+
+// isJSONEscaped tells whether the values of fieldName are JSON escaped by default, see escapedFieldNames: the copies
+// of a field are escaped as their source.
+func isJSONEscaped(cfg Config, fields Fields, fieldName string) bool {
+	// the copy_from chains are bounded, in case of a cycle
+	for i := 0; i <= len(fields); i++ {
+		fieldCfg, _ := cfg.GetField(fieldName)
+		if len(fieldCfg.CopyFrom) == 0 {
+			break
+		}
+
+		fieldName = fieldCfg.CopyFrom
+	}
+
+	return len(escapedFieldNames(cfg, fields, []string{fieldName})) > 0
+}
+
+// makePipelineStub pipes the value written by the bound function after its prefix of prefixLen bytes to the escape
+// functions of pipeline, in order. The pipeline replaces the default escaping: the value is JSON unescaped first,
+// when escaped.
+func makePipelineStub(pipeline []string, prefixLen int, escaped bool, boundF emitFNotReturn) emitFNotReturn {
+	return func(state *GenState, buf *bytes.Buffer) error {
+		offset := buf.Len()
+		if err := boundF(state, buf); err != nil {
+			return err
+		}
+
+		if buf.Len()-offset < prefixLen {
+			return nil
+		}
+
+		tmp := state.pool.Get().(*bytes.Buffer)
+		defer state.pool.Put(tmp)
+
+		if escaped && !state.rawValues {
+			unescapeJSONString(buf, offset+prefixLen, tmp)
+		}
+
+		for _, funcName := range pipeline {
+			escapeFuncs[funcName](buf, offset+prefixLen, tmp)
+		}
+
+		return nil
+	}
+}
+
+// pipeString pipes s to the escape function funcName.
+func pipeString(funcName string, s string) string {
+	var buf, tmp bytes.Buffer
+	buf.WriteString(s)
+	escapeFuncs[funcName](&buf, 0, &tmp)
+	return buf.String()
+}
+
+// rawGenerateCalls renames to generateRaw the calls of generate in the parsed template whose value is piped, or
+// passed, to an escape function, like {{generate "message" | xmlEscape}}, so that the escape functions replace the
+// default escaping of the value.
+func rawGenerateCalls(tree *parse.Tree) {
+	isEscape := func(cmd *parse.CommandNode) bool {
+		if len(cmd.Args) == 0 {
+			return false
+		}
+
+		identifier, ok := cmd.Args[0].(*parse.IdentifierNode)
+		if !ok {
+			return false
+		}
+
+		_, ok = escapeFuncs[identifier.Ident]
+		return ok
+	}
+
+	rename := func(cmd *parse.CommandNode) {
+		if len(cmd.Args) != 2 {
+			return
+		}
+
+		if identifier, ok := cmd.Args[0].(*parse.IdentifierNode); ok && identifier.Ident == "generate" {
+			identifier.Ident = "generateRaw"
+		}
+	}
+
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch node := node.(type) {
+		case *parse.ListNode:
+			if node == nil {
+				return
+			}
+
+			for _, n := range node.Nodes {
+				walk(n)
+			}
+		case *parse.ActionNode:
+			walk(node.Pipe)
+		case *parse.IfNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.RangeNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.WithNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.TemplateNode:
+			walk(node.Pipe)
+		case *parse.PipeNode:
+			if node == nil {
+				return
+			}
+
+			for i, cmd := range node.Cmds {
+				// generate "message" | xmlEscape
+				if i+1 < len(node.Cmds) && isEscape(node.Cmds[i+1]) {
+					rename(cmd)
+				}
+
+				// xmlEscape (generate "message")
+				if isEscape(cmd) {
+					for _, arg := range cmd.Args[1:] {
+						if pipe, ok := arg.(*parse.PipeNode); ok && len(pipe.Cmds) == 1 {
+							rename(pipe.Cmds[0])
+						}
+					}
+				}
+
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range node.Args {
+				walk(arg)
+			}
+		}
+	}
+
+	if tree != nil {
+		walk(tree.Root)
+	}
+}
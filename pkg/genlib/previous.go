@@ -0,0 +1,60 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"strings"
+)
+
+// previousPrefix prefixes the references of the templates to the value of a field in the previous event, like
+// {{.previous.system.cpu.total.pct}}, for deltas, cumulative counters and in-order identifiers
+const previousPrefix = "previous."
+
+// previousReferences returns the references of fieldNames to the value of a field in the previous event, keyed by
+// reference, with the name of their field: the names of the fields of fields are not references, even if prefixed.
+func previousReferences(cfg Config, fields Fields, fieldNames []string) map[string]string {
+	references := make(map[string]string)
+	for _, fieldName := range fieldNames {
+		if !strings.HasPrefix(fieldName, previousPrefix) || isField(fields, fieldName) {
+			continue
+		}
+
+		references[fieldName] = cfg.Resolve(strings.TrimPrefix(fieldName, previousPrefix))
+	}
+
+	return references
+}
+
+// isField tells whether fieldName is the name of a field of fields.
+func isField(fields Fields, fieldName string) bool {
+	for _, field := range fields {
+		if field.Name == fieldName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// makePreviousStub writes the template chunk preceding the reference, then the value of fieldName in the previous
+// event, as written, or nothing if it had none, like in the first event.
+func makePreviousStub(fieldName string, prefix []byte) emitFNotReturn {
+	return func(state *GenState, buf *bytes.Buffer) error {
+		buf.Write(prefix)
+		buf.WriteString(state.previousValues[fieldName])
+		return nil
+	}
+}
+
+// previousValue returns the value of fieldName in the previous event, an empty string if it had none, like in the
+// first event.
+func (s *GenState) previousValue(fieldName string) interface{} {
+	if value, ok := s.previousReturns[fieldName]; ok && value != nil {
+		return value
+	}
+
+	return ""
+}
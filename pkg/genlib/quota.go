@@ -0,0 +1,81 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"math/rand"
+)
+
+// SetEvents sets the count of events to emit, the quotas of the values of enum are met by the end of them.
+// Passing zero, the default, draws the values with a quota in the first events until their quotas are met.
+func (s *GenState) SetEvents(n uint64) {
+	s.events = n
+}
+
+// remainingEvents returns the count of events left to emit, the one being emitted included, 0 if unknown.
+func (s *GenState) remainingEvents() uint64 {
+	if s.events <= s.counter {
+		return 0
+	}
+
+	return s.events - s.counter
+}
+
+// newQuotaDraw returns the function drawing the index of a value of the enum of fieldCfg, so that the values with a
+// quota are drawn exactly that many times by the end of the events: in each event one of them is drawn with the
+// probability of their outstanding draws over the remaining events, otherwise one of the other values is drawn,
+// evenly or according to its weight.
+func newQuotaDraw(fieldCfg ConfigField) func(state *GenState) int {
+	var quoted, outstanding, unquoted, weights []int
+	for i, value := range fieldCfg.Enum {
+		if quota, ok := fieldCfg.Quotas[value]; ok {
+			quoted = append(quoted, i)
+			outstanding = append(outstanding, quota)
+			continue
+		}
+
+		weight := 1
+		if len(fieldCfg.Weights) > 0 {
+			weight = fieldCfg.Weights[i]
+		}
+
+		if weight > 0 {
+			unquoted = append(unquoted, i)
+			weights = append(weights, weight)
+		}
+	}
+
+	drawUnquoted := newWeightedDraw(len(unquoted), weights)
+	drawAny := newWeightedDraw(len(fieldCfg.Enum), fieldCfg.Weights)
+
+	return func(state *GenState) int {
+		var total int
+		for _, n := range outstanding {
+			total += n
+		}
+
+		if total > 0 {
+			remaining := state.remainingEvents()
+			if len(unquoted) == 0 || remaining <= uint64(total) || rand.Int63n(int64(remaining)) < int64(total) {
+				draw := rand.Intn(total)
+				for i, n := range outstanding {
+					if draw < n {
+						outstanding[i] -= 1
+						return quoted[i]
+					}
+
+					draw -= n
+				}
+			}
+		}
+
+		if len(unquoted) == 0 {
+			// the quotas are met and there are events left: not to happen when the quotas were checked against them
+			return drawAny()
+		}
+
+		return unquoted[drawUnquoted()]
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"math/rand"
+
+	"github.com/Pallinder/go-randomdata"
+)
+
+// InitGeneratorRandSeed seeds the random sources used by the generators, so that the same seed produces the same values.
+func InitGeneratorRandSeed(seed int64) {
+	rand.Seed(seed)
+	randomdata.CustomRand(rand.New(rand.NewSource(seed)))
+}
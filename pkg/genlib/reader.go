@@ -0,0 +1,163 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The types of the templates of NewCorpusReader, the ones of the --template-type flag
+const (
+	TemplateTypePlaceholder = "placeholder"
+	TemplateTypeGoText      = "gotext"
+	TemplateTypeStructured  = "structured"
+)
+
+// CorpusReaderOption sets an option of a CorpusReader.
+type CorpusReaderOption func(*CorpusReader)
+
+// WithTemplateType compiles the template of the CorpusReader as a template of templateType, one of
+// TemplateTypePlaceholder, the default, TemplateTypeGoText or TemplateTypeStructured.
+func WithTemplateType(templateType string) CorpusReaderOption {
+	return func(r *CorpusReader) {
+		r.templateType = templateType
+	}
+}
+
+// WithMaxEvents stops the CorpusReader after n events, instead of streaming events endlessly: required by the quotas
+// of the config, and by the cardinalities relative to the count of events.
+func WithMaxEvents(n uint64) CorpusReaderOption {
+	return func(r *CorpusReader) {
+		r.maxEvents = n
+	}
+}
+
+// WithReaderMiddlewares passes each event generated by the CorpusReader through the middlewares, see WithMiddlewares.
+func WithReaderMiddlewares(middlewares ...Middleware) CorpusReaderOption {
+	return func(r *CorpusReader) {
+		r.middlewares = append(r.middlewares, middlewares...)
+	}
+}
+
+// WithReferenceTime generates the date fields in the hour before t, instead of the current time, so that the same
+// seed, set with InitGeneratorRandSeed, streams the same events.
+func WithReferenceTime(t time.Time) CorpusReaderOption {
+	return func(r *CorpusReader) {
+		r.referenceTime = t
+	}
+}
+
+// WithRawValues writes the generated string values as they are, instead of escaping them for JSON strings: for
+// templates of events that are not JSON.
+func WithRawValues() CorpusReaderOption {
+	return func(r *CorpusReader) {
+		r.rawValues = true
+	}
+}
+
+// CorpusReader is an io.Reader streaming the events generated from a template, one per line, to embed the generator
+// in other Go tools, like track builders or integration tests, without running the command line tool.
+// It is not safe for concurrent use, and the random sources of the generators are shared by all of them.
+type CorpusReader struct {
+	templateType  string
+	maxEvents     uint64
+	middlewares   []Middleware
+	referenceTime time.Time
+	rawValues     bool
+
+	gen    Generator
+	state  *GenState
+	buf    bytes.Buffer
+	events uint64
+	err    error
+}
+
+// NewCorpusReader returns a CorpusReader streaming the events generated from template with cfg and flds, endlessly
+// unless WithMaxEvents is set. Without a template, the events are JSON objects of all the fields of flds.
+func NewCorpusReader(cfg Config, flds Fields, template []byte, opts ...CorpusReaderOption) (*CorpusReader, error) {
+	r := &CorpusReader{templateType: TemplateTypePlaceholder}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	cfg, err := cfg.ScaleCardinalities(r.maxEvents, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.HasQuotas() {
+		if len(r.middlewares) > 0 {
+			return nil, errors.New("the quotas of the values of enum cannot be met when middlewares drop events")
+		}
+
+		if err := cfg.CheckQuotas(r.maxEvents); err != nil {
+			return nil, err
+		}
+	}
+
+	var gen Generator
+	switch {
+	case len(template) == 0:
+		gen, err = NewGenerator(cfg, flds)
+	case r.templateType == TemplateTypePlaceholder:
+		gen, err = NewGeneratorWithCustomTemplate(template, cfg, flds)
+	case r.templateType == TemplateTypeGoText:
+		gen, err = NewGeneratorWithTextTemplate(template, cfg, flds)
+	case r.templateType == TemplateTypeStructured:
+		gen, err = NewGeneratorWithStructuredTemplate(template, cfg, flds)
+	default:
+		return nil, fmt.Errorf("template type must be one of %s, %s or %s, got %s", TemplateTypePlaceholder, TemplateTypeGoText, TemplateTypeStructured, r.templateType)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.gen = WithMiddlewares(gen, r.middlewares...)
+	r.state = NewGenState()
+	r.state.SetRawValues(r.rawValues)
+	r.state.SetReferenceTime(r.referenceTime)
+	r.state.SetEvents(r.maxEvents)
+	return r, nil
+}
+
+// Read reads the next bytes of the stream of events, io.EOF once the count of events of WithMaxEvents is reached.
+func (r *CorpusReader) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		if r.maxEvents > 0 && r.events >= r.maxEvents {
+			r.err = io.EOF
+			continue
+		}
+
+		if err := r.gen.Emit(r.state, &r.buf); err != nil {
+			r.buf.Reset()
+			r.err = err
+			continue
+		}
+
+		r.buf.WriteByte('\n')
+		r.events += 1
+	}
+
+	return r.buf.Read(p)
+}
+
+// Events returns the count of events generated so far, including the one being read.
+func (r *CorpusReader) Events() uint64 {
+	return r.events
+}
+
+// Close releases the resources of the generator.
+func (r *CorpusReader) Close() error {
+	return r.gen.Close()
+}
@@ -0,0 +1,80 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+func Test_NewCorpusReader(t *testing.T) {
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: event.action\n  enum: [\"start\", \"end\"]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flds := Fields{{Name: "event.action", Type: FieldTypeKeyword}, {Name: "process.pid", Type: FieldTypeLong}}
+	template := []byte(`{"event.action":"{{generate "event.action"}}","process.pid":{{generate "process.pid"}}}`)
+	reader, err := NewCorpusReader(cfg, flds, template, WithTemplateType(TemplateTypeGoText), WithMaxEvents(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events int
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		var event struct {
+			Action string `json:"event.action"`
+			PID    int64  `json:"process.pid"`
+		}
+
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatal(err)
+		}
+
+		if event.Action != "start" && event.Action != "end" {
+			t.Errorf("expected the action from the enum, got %s", event.Action)
+		}
+
+		events += 1
+	}
+
+	if events != 10 || reader.Events() != 10 {
+		t.Errorf("expected 10 events, got %d lines and %d events", events, reader.Events())
+	}
+
+	// the reads smaller than an event go on where the previous one stopped
+	reader, err = NewCorpusReader(cfg, flds, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer reader.Close()
+
+	line, err := bufio.NewReaderSize(io.LimitReader(reader, 1<<16), 16).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !json.Valid([]byte(line)) {
+		t.Errorf("expected a JSON event of all the fields without a template, got %s", line)
+	}
+
+	if _, err := NewCorpusReader(cfg, flds, template, WithTemplateType("mustache")); err == nil {
+		t.Errorf("expected error for an unknown template type")
+	}
+}
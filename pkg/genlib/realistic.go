@@ -0,0 +1,258 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+const (
+	// GeneratorUserAgent generates browser, command line and bot user agents
+	GeneratorUserAgent = "user_agent"
+	// GeneratorURL generates http and https URLs with a path and, sometimes, a query
+	GeneratorURL = "url"
+	// GeneratorHTTPMethod generates HTTP request methods, mostly GET and POST
+	GeneratorHTTPMethod = "http_method"
+	// GeneratorIP generates IPv4 addresses, half of them in private ranges
+	GeneratorIP = "ip"
+	// GeneratorHostname generates host names made of a role and a number, like web-03
+	GeneratorHostname = "hostname"
+	// GeneratorCloudProvider generates the names of cloud providers, as in ECS cloud.provider
+	GeneratorCloudProvider = "cloud_provider"
+	// GeneratorEventCategory generates the allowed values of ECS event.category
+	GeneratorEventCategory = "event_category"
+)
+
+// realisticGenerators are the purpose-built generators of realistic values, by name.
+var realisticGenerators = map[string]func() string{
+	GeneratorUserAgent:     userAgent,
+	GeneratorURL:           randomURL,
+	GeneratorHTTPMethod:    func() string { return weightedChoice(httpMethods, httpMethodWeights) },
+	GeneratorIP:            ipv4,
+	GeneratorHostname:      hostname,
+	GeneratorCloudProvider: func() string { return weightedChoice(cloudProviders, cloudProviderWeights) },
+	GeneratorEventCategory: func() string { return eventCategories[rand.Intn(len(eventCategories))] },
+	GeneratorPersonName:    personName,
+	GeneratorEmail:         email,
+	GeneratorDomain:        domain,
+	GeneratorFilePath:      filePath,
+	GeneratorUUID:          uuid,
+	GeneratorMACAddress:    macAddress,
+	GeneratorSentence:      newSentenceGenerator(0, 0, 0),
+}
+
+// ecsRealisticFields are the well-known ECS fields generated with a realistic generator by ECSRealism.
+var ecsRealisticFields = map[string]string{
+	"user_agent.original":   GeneratorUserAgent,
+	"url.original":          GeneratorURL,
+	"url.full":              GeneratorURL,
+	"http.request.method":   GeneratorHTTPMethod,
+	"source.ip":             GeneratorIP,
+	"destination.ip":        GeneratorIP,
+	"client.ip":             GeneratorIP,
+	"server.ip":             GeneratorIP,
+	"host.ip":               GeneratorIP,
+	"host.name":             GeneratorHostname,
+	"host.hostname":         GeneratorHostname,
+	"cloud.provider":        GeneratorCloudProvider,
+	"event.category":        GeneratorEventCategory,
+	"observer.hostname":     GeneratorHostname,
+	"http.request.referrer": GeneratorURL,
+	"url.domain":            GeneratorDomain,
+	"source.domain":         GeneratorDomain,
+	"destination.domain":    GeneratorDomain,
+	"user.full_name":        GeneratorPersonName,
+	"user.email":            GeneratorEmail,
+	"file.path":             GeneratorFilePath,
+	"host.mac":              GeneratorMACAddress,
+	"source.mac":            GeneratorMACAddress,
+	"destination.mac":       GeneratorMACAddress,
+	"message":               GeneratorSentence,
+	"error.message":         GeneratorSentence,
+}
+
+var (
+	httpMethods          = []string{"GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS"}
+	httpMethodWeights    = []int{70, 18, 5, 3, 2, 1, 1}
+	cloudProviders       = []string{"aws", "azure", "gcp", "digitalocean"}
+	cloudProviderWeights = []int{50, 25, 20, 5}
+	eventCategories      = []string{"authentication", "configuration", "database", "driver", "email", "file", "host", "iam", "intrusion_detection", "malware", "network", "package", "process", "registry", "session", "threat", "vulnerability", "web"}
+	hostRoles            = []string{"web", "app", "api", "db", "cache", "worker", "proxy", "auth"}
+	topLevelDomains      = []string{"com", "org", "net", "io"}
+	platforms            = []string{"Windows NT 10.0; Win64; x64", "Macintosh; Intel Mac OS X 10_15_7", "X11; Linux x86_64", "iPhone; CPU iPhone OS 16_5 like Mac OS X", "Linux; Android 13; Pixel 7"}
+)
+
+// RealisticGenerators returns the names of the realistic generators, sorted.
+func RealisticGenerators() []string {
+	names := make([]string, 0, len(realisticGenerators))
+	for name := range realisticGenerators {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// ECSRealism returns cfg with the well-known ECS fields of flds, like user_agent.original or source.ip,
+// set to be generated with a realistic generator, unless their config already sets how their values are generated or
+// a custom emitter is registered for them, see RegisterEmitter.
+func ECSRealism(cfg Config, flds Fields) Config {
+	for _, field := range flds {
+		generator, ok := ecsRealisticFields[field.Name]
+		if !ok || !appliesRealisticGenerator(field) {
+			continue
+		}
+
+		fieldCfg, _ := cfg.GetField(field.Name)
+		if _, custom := customEmitter(fieldCfg, field); custom {
+			continue
+		}
+
+		if fieldCfg.Value != nil || len(fieldCfg.Enum) > 0 || len(fieldCfg.Generator) > 0 || len(fieldCfg.CIDR) > 0 || fieldCfg.IPv6Percentage > 0 {
+			continue
+		}
+
+		fieldCfg.Name = field.Name
+		fieldCfg.Generator = generator
+		cfg = cfg.WithField(fieldCfg)
+	}
+
+	return cfg
+}
+
+// appliesRealisticGenerator tells whether the realistic generators apply to the type of field, the string ones.
+func appliesRealisticGenerator(field Field) bool {
+	switch field.Type {
+	case FieldTypeDate, FieldTypeDouble, FieldTypeFloat, FieldTypeHalfFloat, FieldTypeScaledFloat, FieldTypeInteger, FieldTypeLong, FieldTypeUnsignedLong,
+		FieldTypeConstantKeyword, FieldTypeBool, FieldTypeObject, FieldTypeNested, FieldTypeFlattened, FieldTypeGeoPoint:
+		return false
+	}
+
+	return true
+}
+
+// usesRealisticGenerator tells whether field is generated with the realistic generator of its config: an enum takes precedence.
+func usesRealisticGenerator(fieldCfg ConfigField, field Field) bool {
+	return len(fieldCfg.Generator) > 0 && len(fieldCfg.Enum) == 0 && appliesRealisticGenerator(field)
+}
+
+// realisticGenerator returns the realistic generator of the config of field: the sentence generator gets the
+// settings of the config.
+func realisticGenerator(fieldCfg ConfigField, field Field) (func() string, error) {
+	if fieldCfg.Generator == GeneratorSentence {
+		return newSentenceGenerator(fieldCfg.WordsMin, fieldCfg.WordsMax, fieldCfg.Vocabulary), nil
+	}
+
+	generate, ok := realisticGenerators[fieldCfg.Generator]
+	if !ok {
+		return nil, fmt.Errorf("field %s: unknown generator %s, must be one of %s", field.Name, fieldCfg.Generator, strings.Join(append(RealisticGenerators(), Emitters()...), ", "))
+	}
+
+	return generate, nil
+}
+
+func bindRealistic(prefix []byte, fieldCfg ConfigField, field Field, fieldMap map[string]emitFNotReturn) error {
+	generate, err := realisticGenerator(fieldCfg, field)
+	if err != nil {
+		return err
+	}
+
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
+		buf.Write(prefix)
+		buf.WriteString(generate())
+		return nil
+	}
+
+	return nil
+}
+
+func bindRealisticWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]EmitF) error {
+	generate, err := realisticGenerator(fieldCfg, field)
+	if err != nil {
+		return err
+	}
+
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
+		return generate(), nil
+	}
+
+	return nil
+}
+
+func weightedChoice(values []string, weights []int) string {
+	total := 0
+	for _, weight := range weights {
+		total += weight
+	}
+
+	n := rand.Intn(total)
+	for i, weight := range weights {
+		if n < weight {
+			return values[i]
+		}
+
+		n -= weight
+	}
+
+	return values[len(values)-1]
+}
+
+func userAgent() string {
+	platform := platforms[rand.Intn(len(platforms))]
+	switch rand.Intn(10) {
+	case 0, 1, 2, 3, 4:
+		return fmt.Sprintf("Mozilla/5.0 (%s) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%d.0.%d.%d Safari/537.36", platform, 100+rand.Intn(20), 4000+rand.Intn(2000), rand.Intn(200))
+	case 5, 6:
+		version := 100 + rand.Intn(20)
+		return fmt.Sprintf("Mozilla/5.0 (%s; rv:%d.0) Gecko/20100101 Firefox/%d.0", platform, version, version)
+	case 7:
+		return fmt.Sprintf("Mozilla/5.0 (%s) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/%d.%d Safari/605.1.15", platform, 14+rand.Intn(4), rand.Intn(6))
+	case 8:
+		return fmt.Sprintf("curl/7.%d.%d", 60+rand.Intn(30), rand.Intn(2))
+	default:
+		return "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+	}
+}
+
+func randomURL() string {
+	scheme := "https"
+	if rand.Intn(5) == 0 {
+		scheme = "http"
+	}
+
+	var path strings.Builder
+	for i := rand.Intn(3) + 1; i > 0; i-- {
+		path.WriteString("/")
+		path.WriteString(pick(words))
+	}
+
+	if rand.Intn(3) == 0 {
+		fmt.Fprintf(&path, "?id=%d", rand.Intn(10000))
+	}
+
+	return scheme + "://www." + domain() + path.String()
+}
+
+func ipv4() string {
+	switch rand.Intn(6) {
+	case 0:
+		return fmt.Sprintf("10.%d.%d.%d", rand.Intn(256), rand.Intn(256), 1+rand.Intn(254))
+	case 1:
+		return fmt.Sprintf("192.168.%d.%d", rand.Intn(256), 1+rand.Intn(254))
+	case 2:
+		return fmt.Sprintf("172.%d.%d.%d", 16+rand.Intn(16), rand.Intn(256), 1+rand.Intn(254))
+	default:
+		// public addresses, below the loopback range
+		return fmt.Sprintf("%d.%d.%d.%d", 11+rand.Intn(115), rand.Intn(256), rand.Intn(256), 1+rand.Intn(254))
+	}
+}
+
+func hostname() string {
+	return fmt.Sprintf("%s-%02d", hostRoles[rand.Intn(len(hostRoles))], 1+rand.Intn(20))
+}
@@ -0,0 +1,37 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+// RenameFields returns cfg and flds with the fields renamed by the rename config entries, like the ECS fields of a
+// fields definition renamed to the original vendor fields a template expects: the fields are referenced by the
+// templates and written in the events under their new name. The generators rename the fields themselves, the
+// middlewares given the fields must be given the renamed ones.
+func RenameFields(cfg Config, flds Fields) (Config, Fields) {
+	cfg, renames := cfg.Renamed()
+	if len(renames) == 0 {
+		return cfg, flds
+	}
+
+	renamed := make(Fields, 0, len(flds))
+	for _, field := range flds {
+		if newName, ok := renames[field.Name]; ok {
+			field.Name = newName
+		}
+
+		renamed = append(renamed, field)
+	}
+
+	return cfg, renamed
+}
+
+// resolveFieldNames returns fieldNames with the aliases set by the config resolved to the names of their fields.
+func resolveFieldNames(cfg Config, fieldNames []string) []string {
+	resolved := make([]string, 0, len(fieldNames))
+	for _, fieldName := range fieldNames {
+		resolved = append(resolved, cfg.Resolve(fieldName))
+	}
+
+	return resolved
+}
@@ -0,0 +1,206 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+const (
+	repeatBlockStart = "{{range "
+	repeatBlockEnd   = "{{end}}"
+	// repeatBlockPrefix prefixes the names the repeat blocks are referenced under among the fields of the template:
+	// the NUL byte keeps them apart from the names of the fields
+	repeatBlockPrefix = "\x00range"
+	// defaultRepeatSeparator separates the repetitions of the body of a block, like the objects of a JSON array
+	defaultRepeatSeparator = ","
+)
+
+// repeatBlock is a {{range count=N}}...{{end}} block of a custom template, repeating its body a count of times
+// between min and max, drawn for each event, with the repetitions separated by sep, like the objects of an array:
+//
+//	"answers": [{{range count=1-3}}{"name": "{{.dns.answers.name}}"}{{end}}]
+type repeatBlock struct {
+	// name is the name the block is referenced under among the fields of the template
+	name string
+	// start and end are the offsets of the block in the template, bodyStart the one of its body
+	start, end int
+	bodyStart  int
+	body       []byte
+	min, max   int
+	sep        []byte
+}
+
+// parseRepeatBlocks returns the outermost repeat blocks of template, in order: the blocks nested in their body are
+// parsed with it.
+func parseRepeatBlocks(template []byte) ([]repeatBlock, error) {
+	var blocks []repeatBlock
+	for offset := 0; ; {
+		start := bytes.Index(template[offset:], []byte(repeatBlockStart))
+		if start < 0 {
+			return blocks, nil
+		}
+
+		start += offset
+		headerEnd := bytes.Index(template[start:], []byte("}}"))
+		if headerEnd < 0 {
+			return nil, repeatBlockError(template, start, errors.New("range block not closed by }}"))
+		}
+
+		headerEnd += start
+		block, err := parseRepeatHeader(string(template[start+len(repeatBlockStart) : headerEnd]))
+		if err != nil {
+			return nil, repeatBlockError(template, start, err)
+		}
+
+		block.name = repeatBlockPrefix + strconv.Itoa(len(blocks))
+		block.start = start
+		block.bodyStart = headerEnd + 2
+
+		bodyEnd, err := findRepeatBlockEnd(template, block.bodyStart)
+		if err != nil {
+			return nil, repeatBlockError(template, start, err)
+		}
+
+		block.body = template[block.bodyStart:bodyEnd]
+		block.end = bodyEnd + len(repeatBlockEnd)
+		blocks = append(blocks, block)
+		offset = block.end
+	}
+}
+
+// parseRepeatHeader parses the arguments of a repeat block: count=N or count=MIN-MAX, and optionally sep="...",
+// the quoted separator of the repetitions. They can follow the path of the repeated field, like .dns.answers, as in
+// the range actions of text/template: it is ignored, the fields of the body are referenced by their full names.
+func parseRepeatHeader(header string) (repeatBlock, error) {
+	block := repeatBlock{min: -1, sep: []byte(defaultRepeatSeparator)}
+	rest := strings.TrimSpace(header)
+	if strings.HasPrefix(rest, ".") {
+		_, rest, _ = strings.Cut(rest, " ")
+	}
+
+	for ; rest != ""; rest = strings.TrimSpace(rest) {
+		switch {
+		case strings.HasPrefix(rest, "count="):
+			var count string
+			count, rest, _ = strings.Cut(rest[len("count="):], " ")
+			minCount, maxCount, isRange := strings.Cut(count, "-")
+			if !isRange {
+				maxCount = minCount
+			}
+
+			var err error
+			if block.min, err = strconv.Atoi(minCount); err != nil || block.min < 0 {
+				return repeatBlock{}, fmt.Errorf("range count must be N or MIN-MAX, N, MIN and MAX positive integers, got %s", count)
+			}
+
+			if block.max, err = strconv.Atoi(maxCount); err != nil || block.max < block.min {
+				return repeatBlock{}, fmt.Errorf("range count must be N or MIN-MAX, MAX not less than MIN, got %s", count)
+			}
+		case strings.HasPrefix(rest, "sep="):
+			quoted, err := strconv.QuotedPrefix(rest[len("sep="):])
+			if err != nil {
+				return repeatBlock{}, fmt.Errorf("range sep must be a quoted string, like sep=\", \": %w", err)
+			}
+
+			sep, _ := strconv.Unquote(quoted)
+			block.sep = []byte(sep)
+			rest = rest[len("sep=")+len(quoted):]
+		default:
+			return repeatBlock{}, fmt.Errorf("unknown range argument %s, must be count=N or count=MIN-MAX and sep=\"...\"", rest)
+		}
+	}
+
+	if block.min < 0 {
+		return repeatBlock{}, errors.New("range requires a count, like count=3 or count=1-5")
+	}
+
+	return block, nil
+}
+
+// findRepeatBlockEnd returns the offset of the {{end}} closing the block whose body starts at bodyStart in template,
+// skipping the ones of the blocks nested in the body.
+func findRepeatBlockEnd(template []byte, bodyStart int) (int, error) {
+	depth := 0
+	for offset := bodyStart; ; {
+		end := bytes.Index(template[offset:], []byte(repeatBlockEnd))
+		if end < 0 {
+			return 0, errors.New("range block not closed by {{end}}")
+		}
+
+		end += offset
+		if nested := bytes.Index(template[offset:end], []byte(repeatBlockStart)); nested >= 0 {
+			depth++
+			offset += nested + len(repeatBlockStart)
+			continue
+		}
+
+		if depth == 0 {
+			return end, nil
+		}
+
+		depth--
+		offset = end + len(repeatBlockEnd)
+	}
+}
+
+// repeatBlockError returns err located at the block starting at offset of template.
+func repeatBlockError(template []byte, offset int, err error) *TemplateError {
+	line, column := lineColumn(template, offset)
+	return &TemplateError{Line: line, Column: column, Err: err}
+}
+
+// locate moves the location of err, if a TemplateError located in the body of the block, to template.
+func (block repeatBlock) locate(template []byte, err error) error {
+	var templateErr *TemplateError
+	if !errors.As(err, &templateErr) || templateErr.Line == 0 {
+		return err
+	}
+
+	line, column := lineColumn(template, block.bodyStart)
+	if templateErr.Line == 1 {
+		templateErr.Column += column - 1
+	}
+
+	templateErr.Line += line - 1
+	return err
+}
+
+// isRepeatBlock tells whether the name among the fields of a template is the one of a repeat block.
+func isRepeatBlock(name string) bool {
+	return strings.HasPrefix(name, repeatBlockPrefix)
+}
+
+// makeRepeatStub writes the template chunk preceding the block, then its body repeated, emitted by gen each time,
+// so that the fields of each repetition get their own values.
+func makeRepeatStub(block repeatBlock, template, prefix []byte, gen *GeneratorWithCustomTemplate) emitFNotReturn {
+	return func(state *GenState, buf *bytes.Buffer) error {
+		buf.Write(prefix)
+		count := block.min
+		if block.max > block.min {
+			count += rand.Intn(block.max - block.min + 1)
+		}
+
+		// the repetitions trim their own separators, the pending trimming of the template around the block is restored
+		trim := state.trimSeparator
+		for i := 0; i < count; i++ {
+			if i > 0 {
+				buf.Write(block.sep)
+			}
+
+			if err := gen.emitFields(state, buf); err != nil {
+				return block.locate(template, err)
+			}
+		}
+
+		state.trimSeparator = trim
+		return nil
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ruleFieldName is the name a field is bound under for its i-th rule,
+// so that the values cached by the rule do not mix with the ones of the field.
+func ruleFieldName(fieldName string, i int) string {
+	return fmt.Sprintf("%s#rule%d", fieldName, i)
+}
+
+// resetEventValues forgets the values recorded and memoized, and the entities drawn, for the previous event: the
+// values recorded are kept as the ones of the previous event, for the expressions and the templates.
+func (s *GenState) resetEventValues() {
+	s.previousValues, s.eventValues = s.eventValues, s.previousValues
+	s.previousReturns, s.eventReturnValues = s.eventReturnValues, s.previousReturns
+	for k := range s.eventValues {
+		delete(s.eventValues, k)
+	}
+
+	for k := range s.eventReturnValues {
+		delete(s.eventReturnValues, k)
+	}
+
+	for k := range s.conditionValues {
+		delete(s.conditionValues, k)
+	}
+
+	for k := range s.memoValues {
+		delete(s.memoValues, k)
+	}
+
+	for k := range s.entities {
+		delete(s.entities, k)
+	}
+}
+
+// matchRule returns the index of the first rule whose condition is met in the event being emitted, -1 if none.
+func (s *GenState) matchRule(rules []Rule) int {
+	for i, rule := range rules {
+		if value, ok := s.conditionValues[rule.When.Field]; ok && rule.When.Matches(value) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ruleConditionFields returns the fields referenced by the conditions of the rules of fieldNames.
+func ruleConditionFields(cfg Config, fieldNames []string) map[string]struct{} {
+	ruleConditionFields := make(map[string]struct{})
+	for _, fieldName := range fieldNames {
+		fieldCfg, _ := cfg.GetField(fieldName)
+		for _, rule := range fieldCfg.Rules {
+			ruleConditionFields[rule.When.Field] = struct{}{}
+		}
+	}
+
+	return ruleConditionFields
+}
+
+// conditionFields returns the fields referenced by the conditions of the rules of fieldNames, by the expressions
+// of the derived ones, in the same event or in the previous one, and by the copy_from of the copies.
+func conditionFields(cfg Config, fieldNames []string) map[string]struct{} {
+	conditionFields := make(map[string]struct{})
+	for _, fieldName := range fieldNames {
+		fieldCfg, _ := cfg.GetField(fieldName)
+		for _, rule := range fieldCfg.Rules {
+			conditionFields[rule.When.Field] = struct{}{}
+		}
+
+		for _, operand := range expressionFields(fieldCfg) {
+			conditionFields[operand] = struct{}{}
+		}
+
+		for _, operand := range expressionPreviousFields(fieldCfg) {
+			conditionFields[operand] = struct{}{}
+		}
+
+		if len(fieldCfg.CopyFrom) > 0 {
+			conditionFields[fieldCfg.CopyFrom] = struct{}{}
+		}
+	}
+
+	return conditionFields
+}
+
+// checkRulesOrder ensures that the fields referenced by the conditions of the rules, by the expressions of the
+// derived fields and by the copy_from of the copies, are generated before the field they belong to, since the rules,
+// the expressions and the copies can only look back.
+func checkRulesOrder(template []byte, cfg Config, orderedFields []string) error {
+	seen := make(map[string]struct{}, len(orderedFields))
+	for _, fieldName := range orderedFields {
+		fieldCfg, _ := cfg.GetField(fieldName)
+		for _, rule := range fieldCfg.Rules {
+			if _, ok := seen[rule.When.Field]; !ok {
+				return placeholderError(template, fieldName, 0, fmt.Errorf("rules require field %s to precede it in the template", rule.When.Field))
+			}
+		}
+
+		for _, operand := range expressionFields(fieldCfg) {
+			if _, ok := seen[operand]; !ok {
+				return placeholderError(template, fieldName, 0, fmt.Errorf("expression requires field %s to precede it in the template", operand))
+			}
+		}
+
+		if _, ok := seen[fieldCfg.CopyFrom]; len(fieldCfg.CopyFrom) > 0 && !ok {
+			return placeholderError(template, fieldName, 0, fmt.Errorf("copy_from requires field %s to precede it in the template", fieldCfg.CopyFrom))
+		}
+
+		seen[fieldName] = struct{}{}
+	}
+
+	return nil
+}
+
+// sortFieldsByRules orders the fields so that the fields referenced by the conditions of the rules, by the
+// expressions of the derived fields and by the copy_from of the copies, precede the field they belong to, keeping the
+// original order otherwise.
+func sortFieldsByRules(cfg Config, flds Fields) Fields {
+	byName := make(map[string]Field, len(flds))
+	for _, field := range flds {
+		byName[field.Name] = field
+	}
+
+	sorted := make(Fields, 0, len(flds))
+	visited := make(map[string]struct{}, len(flds))
+	var visit func(field Field)
+	visit = func(field Field) {
+		if _, ok := visited[field.Name]; ok {
+			return
+		}
+
+		visited[field.Name] = struct{}{}
+		fieldCfg, _ := cfg.GetField(field.Name)
+		for _, rule := range fieldCfg.Rules {
+			if dependency, ok := byName[rule.When.Field]; ok {
+				visit(dependency)
+			}
+		}
+
+		for _, operand := range expressionFields(fieldCfg) {
+			if dependency, ok := byName[operand]; ok {
+				visit(dependency)
+			}
+		}
+
+		if dependency, ok := byName[fieldCfg.CopyFrom]; ok {
+			visit(dependency)
+		}
+
+		sorted = append(sorted, field)
+	}
+
+	for _, field := range flds {
+		visit(field)
+	}
+
+	return sorted
+}
+
+func bindRules(cfg Config, field Field, fieldMap map[string]emitFNotReturn, templateFieldMap map[string][]byte) error {
+	fieldCfg, _ := cfg.GetField(field.Name)
+	if len(fieldCfg.Rules) == 0 {
+		return nil
+	}
+
+	// Each rule binds the field with its own config to an alternative emit function
+	alternatives := make([]emitFNotReturn, 0, len(fieldCfg.Rules))
+	for i, rule := range fieldCfg.Rules {
+		ruleField := field
+		ruleField.Name = ruleFieldName(field.Name, i)
+		ruleCfg := rule.Then
+		ruleCfg.Name = ruleField.Name
+
+		ruleFieldMap := make(map[string]emitFNotReturn)
+		ruleTemplateFieldMap := map[string][]byte{ruleField.Name: templateFieldMap[field.Name]}
+		if err := bindField(cfg.WithField(ruleCfg), ruleField, nil, ruleFieldMap, ruleTemplateFieldMap, false); err != nil {
+			return err
+		}
+
+		boundF, ok := ruleFieldMap[ruleField.Name]
+		if !ok {
+			return fmt.Errorf("field %s: rules are not supported for object fields", field.Name)
+		}
+
+		alternatives = append(alternatives, boundF)
+	}
+
+	boundF := fieldMap[field.Name]
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) error {
+		if i := state.matchRule(fieldCfg.Rules); i >= 0 {
+			return alternatives[i](state, buf)
+		}
+
+		return boundF(state, buf)
+	}
+
+	return nil
+}
+
+func bindRulesWithReturn(cfg Config, field Field, fieldMap map[string]EmitF) error {
+	fieldCfg, _ := cfg.GetField(field.Name)
+	if len(fieldCfg.Rules) == 0 {
+		return nil
+	}
+
+	// Each rule binds the field with its own config to an alternative emit function
+	alternatives := make([]EmitF, 0, len(fieldCfg.Rules))
+	for i, rule := range fieldCfg.Rules {
+		ruleField := field
+		ruleField.Name = ruleFieldName(field.Name, i)
+		ruleCfg := rule.Then
+		ruleCfg.Name = ruleField.Name
+
+		ruleFieldMap := make(map[string]EmitF)
+		if err := bindField(cfg.WithField(ruleCfg), ruleField, ruleFieldMap, nil, nil, true); err != nil {
+			return err
+		}
+
+		boundF, ok := ruleFieldMap[ruleField.Name]
+		if !ok {
+			return fmt.Errorf("field %s: rules are not supported for object fields", field.Name)
+		}
+
+		alternatives = append(alternatives, boundF)
+	}
+
+	boundF := fieldMap[field.Name]
+	fieldMap[field.Name] = func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
+		if i := state.matchRule(fieldCfg.Rules); i >= 0 {
+			return alternatives[i](state, buf)
+		}
+
+		return boundF(state, buf)
+	}
+
+	return nil
+}
+
+// makeRecordStub records the value written by the bound function, escaped, for the expressions, the copies and the
+// references to the previous event.
+func makeRecordStub(fieldName string, prefixLen int, boundF emitFNotReturn) emitFNotReturn {
+	return func(state *GenState, buf *bytes.Buffer) error {
+		offset := buf.Len()
+		if err := boundF(state, buf); err != nil {
+			return err
+		}
+
+		value := buf.Bytes()[offset:]
+		if len(value) >= prefixLen {
+			value = value[prefixLen:]
+		}
+
+		state.eventValues[fieldName] = string(value)
+		return nil
+	}
+}
+
+// makeConditionStub records the value written by the bound function for the rules of the fields following it to
+// check it: it wraps the bound function before the escape stub, for the rules to check the value as generated.
+func makeConditionStub(fieldName string, prefixLen int, boundF emitFNotReturn) emitFNotReturn {
+	return func(state *GenState, buf *bytes.Buffer) error {
+		offset := buf.Len()
+		if err := boundF(state, buf); err != nil {
+			return err
+		}
+
+		value := buf.Bytes()[offset:]
+		if len(value) >= prefixLen {
+			value = value[prefixLen:]
+		}
+
+		state.conditionValues[fieldName] = string(value)
+		return nil
+	}
+}
+
+// makeConditionStubWithReturn records the value returned by the bound function for the rules of the fields generated
+// after it to check it: it wraps the bound function before the escape stub, for the rules to check the value as generated.
+func makeConditionStubWithReturn(fieldName string, boundF EmitF) EmitF {
+	return func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
+		value, err := boundF(state, buf)
+		if err != nil {
+			return value, err
+		}
+
+		state.conditionValues[fieldName] = fmt.Sprint(value)
+		return value, nil
+	}
+}
+
+// makeRecordStubWithReturn records the value returned by the bound function, escaped, for the expressions, the copies
+// and the references to the previous event.
+func makeRecordStubWithReturn(fieldName string, boundF EmitF) EmitF {
+	return func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
+		value, err := boundF(state, buf)
+		if err != nil {
+			return value, err
+		}
+
+		state.eventValues[fieldName] = fmt.Sprint(value)
+		state.eventReturnValues[fieldName] = value
+		return value, nil
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// GeneratorSentence generates sentences of words drawn from a vocabulary with the frequencies of natural language
+const GeneratorSentence = "sentence"
+
+const (
+	// DefaultSentenceWordsMin and DefaultSentenceWordsMax bound the count of words of the values of the sentence
+	// generator, unless set otherwise with words_min and words_max
+	DefaultSentenceWordsMin = 5
+	DefaultSentenceWordsMax = 20
+	// DefaultSentenceVocabulary is the count of distinct words of the sentence generator, unless set otherwise with vocabulary
+	DefaultSentenceVocabulary = 1000
+)
+
+// commonWords are the most frequent English words, in order of frequency, the first ones of the vocabularies
+var commonWords = []string{
+	"the", "of", "and", "to", "a", "in", "is", "it", "that", "for", "was", "on", "with", "as", "be", "at", "by", "this",
+	"not", "from", "or", "have", "an", "but", "are", "which", "all", "they", "were", "has", "been", "one", "will", "if",
+	"more", "when", "can", "there", "no", "so", "after", "into", "out", "up", "new", "than", "then", "also", "over", "now",
+}
+
+var (
+	syllableOnsets = []string{"b", "c", "d", "f", "g", "h", "l", "m", "n", "p", "r", "s", "t", "v", "w", "br", "ch", "cl", "gr", "pl", "pr", "sh", "st", "tr"}
+	syllableNuclei = []string{"a", "e", "i", "o", "u", "a", "e", "o", "ea", "io", "ou"}
+	syllableCodas  = []string{"", "", "", "", "l", "n", "r", "s", "t", "nd", "nt", "st"}
+)
+
+// newSentenceGenerator returns a generator of values of wordsMin to wordsMax words, split into sentences, drawn from
+// a vocabulary of vocabulary words. The counts of words are spread around the middle of the bounds, and the words are
+// drawn following Zipf's law, like in natural language: the most frequent word is drawn twice as often as the second
+// one, three times as often as the third one, and so on, so that the values get the tokenization and the compression
+// ratio of real messages. Each setting is the default one if zero.
+func newSentenceGenerator(wordsMin, wordsMax, vocabulary int) func() string {
+	if wordsMin <= 0 {
+		wordsMin = DefaultSentenceWordsMin
+		if wordsMax > 0 && wordsMax < wordsMin {
+			wordsMin = wordsMax
+		}
+	}
+
+	if wordsMax <= 0 {
+		wordsMax = DefaultSentenceWordsMax
+		if wordsMax < wordsMin {
+			wordsMax = wordsMin
+		}
+	}
+
+	if vocabulary <= 0 {
+		vocabulary = DefaultSentenceVocabulary
+	}
+
+	dictionary := sentenceVocabulary(vocabulary)
+	// the cumulative frequencies of the words of the dictionary, by rank
+	cumulative := make([]float64, len(dictionary))
+	var total float64
+	for i := range dictionary {
+		total += 1 / float64(i+1)
+		cumulative[i] = total
+	}
+
+	return func() string {
+		span := wordsMax - wordsMin
+		// the sum of two draws peaks in the middle of the bounds
+		words := wordsMin + (rand.Intn(span+1)+rand.Intn(span+1))/2
+
+		var sentence strings.Builder
+		sentence.Grow(words * 8)
+		for words > 0 {
+			length := 4 + rand.Intn(11)
+			if length > words || words-length < 3 {
+				length = words
+			}
+
+			if sentence.Len() > 0 {
+				sentence.WriteByte(' ')
+			}
+
+			for i := 0; i < length; i++ {
+				word := dictionary[sort.SearchFloat64s(cumulative, rand.Float64()*total)]
+				if i == 0 {
+					sentence.WriteString(strings.ToUpper(word[:1]))
+					sentence.WriteString(word[1:])
+				} else {
+					sentence.WriteByte(' ')
+					sentence.WriteString(word)
+				}
+
+				if i < length-1 && i > 1 && rand.Intn(10) == 0 {
+					sentence.WriteByte(',')
+				}
+			}
+
+			sentence.WriteByte('.')
+			words -= length
+		}
+
+		return sentence.String()
+	}
+}
+
+// sentenceVocabulary returns the size words of the vocabularies of the sentence generator, in order of frequency: the
+// common English words, the embedded words, and made-up words of English-like syllables. The made-up words are drawn
+// from a random source of their own, so that a vocabulary is the same in all the runs.
+func sentenceVocabulary(size int) []string {
+	dictionary := make([]string, 0, size)
+	seen := make(map[string]bool, size)
+	add := func(word string) {
+		if len(dictionary) < size && !seen[word] {
+			seen[word] = true
+			dictionary = append(dictionary, word)
+		}
+	}
+
+	for _, word := range commonWords {
+		add(word)
+	}
+
+	for _, word := range words {
+		add(word)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for len(dictionary) < size {
+		var word strings.Builder
+		// mostly words of two syllables, some of one or three
+		for syllables := 1 + r.Intn(2) + r.Intn(2); syllables > 0; syllables-- {
+			word.WriteString(syllableOnsets[r.Intn(len(syllableOnsets))])
+			word.WriteString(syllableNuclei[r.Intn(len(syllableNuclei))])
+			word.WriteString(syllableCodas[r.Intn(len(syllableCodas))])
+		}
+
+		add(word.String())
+	}
+
+	return dictionary
+}
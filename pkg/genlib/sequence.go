@@ -0,0 +1,231 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/elastic/go-ucfg/yaml"
+)
+
+// DefaultSequenceMaxSpan is the longest time between the first and the last event of a sequence, unless set otherwise
+const DefaultSequenceMaxSpan = time.Minute
+
+// Sequence is a chain of events, like a process starting, connecting to a host and ending, the ones matched by EQL
+// sequence rules: the events of each step get the values of its fields, and the events after the first one get the
+// values of the correlated fields of the first one and a later @timestamp.
+type Sequence struct {
+	Name  string         `config:"name"`
+	Steps []SequenceStep `config:"steps"`
+	// Correlate are the fields with the same values in all the events of a sequence, like process.entity_id
+	Correlate []string `config:"correlate"`
+	// Percentage is the percentage of the events starting a sequence, among the ones not continuing another one
+	Percentage int `config:"percentage"`
+	// IncompletePercentage is the percentage of the sequences stopping before their last step
+	IncompletePercentage int `config:"incomplete_percentage"`
+	// MaxSpan is the longest time between the first and the last event of a sequence, DefaultSequenceMaxSpan if zero
+	MaxSpan time.Duration `config:"max_span"`
+}
+
+// SequenceStep is a step of a Sequence: its event gets the values of Fields, by field name.
+type SequenceStep struct {
+	Fields map[string]interface{} `config:"fields"`
+}
+
+// LoadSequences loads the sequences file at sequencesPath, a list of sequences.
+func LoadSequences(sequencesPath string) ([]Sequence, error) {
+	content, err := os.ReadFile(sequencesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := yaml.NewConfig(content)
+	if err != nil {
+		return nil, fmt.Errorf("sequences %s: %w", sequencesPath, err)
+	}
+
+	var sequences []Sequence
+	if err := cfg.Unpack(&sequences); err != nil {
+		return nil, fmt.Errorf("sequences %s: %w", sequencesPath, err)
+	}
+
+	if err := ValidateSequences(sequences); err != nil {
+		return nil, fmt.Errorf("sequences %s: %w", sequencesPath, err)
+	}
+
+	return sequences, nil
+}
+
+// ValidateSequences checks the settings of sequences: their percentages must sum up to 100 at most.
+func ValidateSequences(sequences []Sequence) error {
+	if len(sequences) == 0 {
+		return errors.New("no sequence")
+	}
+
+	var total int
+	for i, sequence := range sequences {
+		if len(sequence.Name) == 0 {
+			return fmt.Errorf("sequence %d must provide name", i)
+		}
+
+		if len(sequence.Steps) < 2 {
+			return fmt.Errorf("sequence %s must provide two steps at least", sequence.Name)
+		}
+
+		if sequence.Percentage <= 0 || sequence.Percentage > 100 {
+			return fmt.Errorf("sequence %s: percentage must be between 1 and 100", sequence.Name)
+		}
+
+		if sequence.IncompletePercentage < 0 || sequence.IncompletePercentage > 100 {
+			return fmt.Errorf("sequence %s: incomplete_percentage must be between 0 and 100", sequence.Name)
+		}
+
+		if sequence.MaxSpan < 0 || (sequence.MaxSpan > 0 && sequence.stepSpan() < time.Millisecond) {
+			return fmt.Errorf("sequence %s: max_span must be positive, a millisecond per step at least", sequence.Name)
+		}
+
+		total += sequence.Percentage
+	}
+
+	if total > 100 {
+		return fmt.Errorf("the percentages of the sequences sum up to %d, more than 100", total)
+	}
+
+	return nil
+}
+
+// stepSpan returns the longest time between two steps of the sequence.
+func (s Sequence) stepSpan() time.Duration {
+	maxSpan := s.MaxSpan
+	if maxSpan == 0 {
+		maxSpan = DefaultSequenceMaxSpan
+	}
+
+	return maxSpan / time.Duration(len(s.Steps)-1)
+}
+
+// NewSequences returns a Middleware rewriting the JSON events as the steps of sequences: each event continues one of
+// the open sequences half of the times, and otherwise starts one of sequences according to their percentages.
+// A sequence starting is incomplete according to its incomplete_percentage, stopping at a random step before its
+// last one. The @timestamp of an event continuing a sequence is kept if it follows the one of the previous step by
+// at most the span of a step, max_span divided by the count of steps after the first one, and moved after the
+// previous step within that span otherwise.
+//
+// The events neither starting nor continuing a sequence are left as they are.
+func NewSequences(sequences []Sequence) Middleware {
+	var open []*sequenceState
+	var buf bytes.Buffer
+	return func(doc []byte) ([]byte, error) {
+		decoder := json.NewDecoder(bytes.NewReader(doc))
+		decoder.UseNumber()
+		var event map[string]interface{}
+		if err := decoder.Decode(&event); err != nil {
+			return nil, fmt.Errorf("cannot rewrite as a step of a sequence a document that is not a JSON object: %w", err)
+		}
+
+		if len(open) > 0 && rand.Intn(2) == 0 {
+			i := rand.Intn(len(open))
+			if open[i].next(event) {
+				open = append(open[:i], open[i+1:]...)
+			}
+		} else if state := startSequence(sequences, event); state != nil {
+			if state.step < state.steps {
+				open = append(open, state)
+			}
+		} else {
+			return doc, nil
+		}
+
+		buf.Reset()
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(event); err != nil {
+			return nil, err
+		}
+
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+	}
+}
+
+// sequenceState is an open sequence: the index of its next step, the count of its steps, the values of its
+// correlated fields and the timestamp of its last step.
+type sequenceState struct {
+	sequence   Sequence
+	step       int
+	steps      int
+	correlated map[string]interface{}
+	timestamp  time.Time
+}
+
+// startSequence draws the sequence event starts, if any, and rewrites event as its first step. It returns the state
+// of the sequence, nil if none starts.
+func startSequence(sequences []Sequence, event map[string]interface{}) *sequenceState {
+	draw := rand.Intn(100)
+	for _, sequence := range sequences {
+		if draw >= sequence.Percentage {
+			draw -= sequence.Percentage
+			continue
+		}
+
+		state := &sequenceState{sequence: sequence, steps: len(sequence.Steps), correlated: make(map[string]interface{})}
+		if rand.Intn(100) < sequence.IncompletePercentage {
+			state.steps = 1 + rand.Intn(len(sequence.Steps)-1)
+		}
+
+		setFields(event, sequence.Steps[0].Fields)
+		for _, name := range sequence.Correlate {
+			if obj, key, found := lookupPath(event, name); found {
+				state.correlated[name] = obj[key]
+			}
+		}
+
+		if obj, key, found := lookupPath(event, "@timestamp"); found {
+			state.timestamp, _ = parseTimestamp(obj[key])
+		}
+
+		state.step = 1
+		return state
+	}
+
+	return nil
+}
+
+// next rewrites event as the next step of the sequence, telling whether it was the last one.
+func (s *sequenceState) next(event map[string]interface{}) bool {
+	setFields(event, s.sequence.Steps[s.step].Fields)
+	setFields(event, s.correlated)
+
+	if obj, key, found := lookupPath(event, "@timestamp"); found && !s.timestamp.IsZero() {
+		t, ok := parseTimestamp(obj[key])
+		span := s.sequence.stepSpan()
+		if !ok || !t.After(s.timestamp) || t.Sub(s.timestamp) > span {
+			t = s.timestamp.Add(time.Millisecond + time.Duration(rand.Int63n(int64(span-time.Millisecond)+1)))
+			obj[key] = formatTimestamp(t, obj[key])
+		}
+
+		s.timestamp = t
+	}
+
+	s.step += 1
+	return s.step == s.steps
+}
+
+// setFields sets the values of fields in event, by dotted path, adding the ones not in event as dotted keys.
+func setFields(event map[string]interface{}, fields map[string]interface{}) {
+	for _, name := range sortedKeys(fields) {
+		if obj, key, found := lookupPath(event, name); found {
+			obj[key] = fields[name]
+			continue
+		}
+
+		event[name] = fields[name]
+	}
+}
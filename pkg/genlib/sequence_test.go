@@ -0,0 +1,117 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_NewSequences(t *testing.T) {
+	sequencesPath := filepath.Join(t.TempDir(), "sequences.yml")
+	content := `- name: process
+  correlate: [process.entity_id]
+  percentage: 100
+  incomplete_percentage: 20
+  max_span: 30s
+  steps:
+    - fields:
+        event.action: start
+    - fields:
+        event.action: connect
+        network.direction: egress
+    - fields:
+        event.action: end
+`
+	if err := os.WriteFile(sequencesPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sequences, err := LoadSequences(sequencesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	InitGeneratorRandSeed(42)
+	middleware := NewSequences(sequences)
+	actions := make(map[string][]string)
+	timestamps := make(map[string]time.Time)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 1000; i++ {
+		// the generated timestamps go backwards, the ones of the steps after the first are moved forward
+		doc := fmt.Sprintf(`{"@timestamp":"%s","event":{"action":"generated"},"process.entity_id":"%d"}`, start.Add(-time.Duration(i)*time.Second).Format(time.RFC3339), i)
+		rewritten, err := middleware([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var event struct {
+			Timestamp time.Time `json:"@timestamp"`
+			Event     struct {
+				Action string `json:"action"`
+			} `json:"event"`
+			EntityID  string `json:"process.entity_id"`
+			Direction string `json:"network.direction"`
+		}
+
+		if err := json.Unmarshal(rewritten, &event); err != nil {
+			t.Fatal(err)
+		}
+
+		if event.Event.Action == "generated" {
+			t.Fatalf("expected every event to be a step of a sequence, got %s", string(rewritten))
+		}
+
+		if event.Event.Action == "connect" && event.Direction != "egress" {
+			t.Errorf("expected the connect step to set network.direction, got %s", string(rewritten))
+		}
+
+		if previous, ok := timestamps[event.EntityID]; ok && (!event.Timestamp.After(previous) || event.Timestamp.Sub(previous) > 15*time.Second) {
+			t.Errorf("expected step %s of sequence %s within 15s after %s, got %s", event.Event.Action, event.EntityID, previous, event.Timestamp)
+		}
+
+		timestamps[event.EntityID] = event.Timestamp
+		actions[event.EntityID] = append(actions[event.EntityID], event.Event.Action)
+	}
+
+	var complete, incomplete int
+	for entityID, steps := range actions {
+		for i, action := range steps {
+			if expected := []string{"start", "connect", "end"}[i]; action != expected {
+				t.Fatalf("expected step %d of sequence %s to be %s, got %v", i, entityID, expected, steps)
+			}
+		}
+
+		if len(steps) == 3 {
+			complete += 1
+		} else {
+			incomplete += 1
+		}
+	}
+
+	if complete == 0 || incomplete == 0 {
+		t.Errorf("expected both complete and incomplete sequences, got %d and %d", complete, incomplete)
+	}
+
+	if _, err := middleware([]byte("GET / 200")); err == nil {
+		t.Errorf("expected error for a document that is not a JSON object")
+	}
+
+	for _, invalid := range [][]Sequence{
+		nil,
+		{{Name: "single", Steps: make([]SequenceStep, 1), Percentage: 10}},
+		{{Name: "zero", Steps: make([]SequenceStep, 2)}},
+		{{Name: "a", Steps: make([]SequenceStep, 2), Percentage: 60}, {Name: "b", Steps: make([]SequenceStep, 2), Percentage: 60}},
+		{{Name: "short", Steps: make([]SequenceStep, 3), Percentage: 10, MaxSpan: time.Millisecond}},
+	} {
+		if err := ValidateSequences(invalid); err == nil {
+			t.Errorf("expected error for sequences %+v", invalid)
+		}
+	}
+}
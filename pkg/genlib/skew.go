@@ -0,0 +1,37 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+// newSkewedIndex returns the function drawing the index of one of n values, or entities, following skew, nil if skew
+// is not set.
+func newSkewedIndex(skew config.Skew, n int) func() int {
+	if !skew.IsSet() || n <= 0 {
+		return nil
+	}
+
+	cumulative := skew.Weights(n)
+	for i := 1; i < n; i++ {
+		cumulative[i] += cumulative[i-1]
+	}
+
+	total := cumulative[n-1]
+	return func() int {
+		// the values with no weight are never drawn: the first value whose cumulated weight exceeds the draw is
+		x := rand.Float64() * total
+		idx := sort.Search(n, func(i int) bool { return cumulative[i] > x })
+		if idx == n {
+			idx = n - 1
+		}
+
+		return idx
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// NewSyntheticSource returns a Middleware rewriting the JSON documents the way Elasticsearch reconstructs them with
+// synthetic _source, so that a corpus can be compared to the documents returned by an index using it:
+//   - the dotted field names are expanded into objects, and the keys of the objects are sorted
+//   - the null values and the empty objects and arrays are removed
+//   - the arrays of objects are merged into an object of arrays, unless the field is nested
+//   - the arrays of values are flattened and sorted, the ones of strings are deduplicated as well, and the arrays
+//     of a single value are replaced by the value
+//   - the numbers are rounded to the precision of the type of their field in flds, like float or long
+//
+// The geo_point values are left as they are.
+func NewSyntheticSource(flds Fields) Middleware {
+	s := syntheticSource{types: make(map[string]string, len(flds))}
+	for _, field := range flds {
+		if strings.HasSuffix(field.Name, ".*") {
+			s.types[field.Name] = field.ObjectType
+		} else {
+			s.types[field.Name] = field.Type
+		}
+	}
+
+	var buf bytes.Buffer
+	return func(doc []byte) ([]byte, error) {
+		decoder := json.NewDecoder(bytes.NewReader(doc))
+		decoder.UseNumber()
+		var v map[string]interface{}
+		if err := decoder.Decode(&v); err != nil {
+			return nil, fmt.Errorf("cannot rewrite for synthetic source a document that is not a JSON object: %w", err)
+		}
+
+		normalized, _ := s.normalize(v, "")
+		if normalized == nil {
+			normalized = map[string]interface{}{}
+		}
+
+		buf.Reset()
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(normalized); err != nil {
+			return nil, err
+		}
+
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+	}
+}
+
+type syntheticSource struct {
+	// types are the types of the fields by name, the object type for the fields of objects, like labels.*
+	types map[string]string
+}
+
+// fieldType returns the type of the field at path, or of the values of the object it belongs to.
+func (s syntheticSource) fieldType(path string) string {
+	if fieldType, ok := s.types[path]; ok {
+		return fieldType
+	}
+
+	if i := strings.LastIndexByte(path, '.'); i > 0 {
+		return s.types[path[:i]+".*"]
+	}
+
+	return ""
+}
+
+// normalize returns v, the value of the field at path, as reconstructed by synthetic _source, or false if the field
+// is removed.
+func (s syntheticSource) normalize(v interface{}, path string) (interface{}, bool) {
+	fieldType := s.fieldType(path)
+	if fieldType == FieldTypeGeoPoint {
+		return v, v != nil
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return nil, false
+	case map[string]interface{}:
+		return s.normalizeObject(v, path)
+	case []interface{}:
+		return s.normalizeArray(v, path, fieldType)
+	case json.Number:
+		return normalizeSyntheticNumber(v, fieldType), true
+	default:
+		return v, true
+	}
+}
+
+func (s syntheticSource) normalizeObject(obj map[string]interface{}, path string) (interface{}, bool) {
+	normalized := make(map[string]interface{}, len(obj))
+	for key, value := range expandDottedKeys(obj) {
+		if value, ok := s.normalize(value, joinPath(path, key)); ok {
+			normalized[key] = value
+		}
+	}
+
+	return normalized, len(normalized) > 0
+}
+
+func (s syntheticSource) normalizeArray(arr []interface{}, path, fieldType string) (interface{}, bool) {
+	values := flattenArray(arr, nil)
+
+	objects := 0
+	for _, value := range values {
+		if _, ok := value.(map[string]interface{}); ok {
+			objects++
+		}
+	}
+
+	if objects == len(values) && objects > 0 && fieldType != FieldTypeNested {
+		return s.normalize(mergeObjects(values), path)
+	}
+
+	normalized := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		if value, ok := s.normalize(value, path); ok {
+			normalized = append(normalized, value)
+		}
+	}
+
+	if objects == 0 {
+		normalized = sortLeafValues(normalized)
+	}
+
+	switch len(normalized) {
+	case 0:
+		return nil, false
+	case 1:
+		return normalized[0], true
+	default:
+		return normalized, true
+	}
+}
+
+// expandDottedKeys returns obj with its dotted keys expanded into nested objects, merged with the existing ones.
+// A dotted key conflicting with a value that is not an object is left as it is.
+func expandDottedKeys(obj map[string]interface{}) map[string]interface{} {
+	expanded := make(map[string]interface{}, len(obj))
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+
+	// shorter keys first, so that the objects are in place before the dotted keys are merged into them
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := obj[key]
+		parent, name, found := strings.Cut(key, ".")
+		if !found {
+			if existing, ok := expanded[key].(map[string]interface{}); ok {
+				if value, ok := value.(map[string]interface{}); ok {
+					for k, v := range value {
+						existing[k] = v
+					}
+
+					continue
+				}
+			}
+
+			expanded[key] = value
+			continue
+		}
+
+		nested, ok := expanded[parent].(map[string]interface{})
+		if !ok {
+			if _, exists := expanded[parent]; exists {
+				expanded[key] = value
+				continue
+			}
+
+			nested = make(map[string]interface{})
+			expanded[parent] = nested
+		}
+
+		nested[name] = value
+	}
+
+	return expanded
+}
+
+// flattenArray appends the values of arr to values, flattening the nested arrays.
+func flattenArray(arr []interface{}, values []interface{}) []interface{} {
+	for _, value := range arr {
+		if nested, ok := value.([]interface{}); ok {
+			values = flattenArray(nested, values)
+		} else {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// mergeObjects merges the objects into one, whose keys hold the arrays of the values of the key in the objects.
+func mergeObjects(objects []interface{}) map[string]interface{} {
+	merged := make(map[string]interface{})
+	for _, obj := range objects {
+		for key, value := range expandDottedKeys(obj.(map[string]interface{})) {
+			values, _ := merged[key].([]interface{})
+			merged[key] = append(values, value)
+		}
+	}
+
+	return merged
+}
+
+// sortLeafValues sorts the values of an array of numbers, strings or booleans, deduplicating the strings.
+// The arrays mixing them are left as they are.
+func sortLeafValues(values []interface{}) []interface{} {
+	if len(values) < 2 {
+		return values
+	}
+
+	switch values[0].(type) {
+	case json.Number:
+		numbers := make([]float64, 0, len(values))
+		for _, value := range values {
+			n, ok := value.(json.Number)
+			if !ok {
+				return values
+			}
+
+			f, _ := n.Float64()
+			numbers = append(numbers, f)
+		}
+
+		sort.Stable(numberValues{values: values, numbers: numbers})
+	case string:
+		for _, value := range values {
+			if _, ok := value.(string); !ok {
+				return values
+			}
+		}
+
+		sort.SliceStable(values, func(i, j int) bool {
+			return values[i].(string) < values[j].(string)
+		})
+
+		deduplicated := values[:1]
+		for _, value := range values[1:] {
+			if value != deduplicated[len(deduplicated)-1] {
+				deduplicated = append(deduplicated, value)
+			}
+		}
+
+		values = deduplicated
+	case bool:
+		for _, value := range values {
+			if _, ok := value.(bool); !ok {
+				return values
+			}
+		}
+
+		sort.SliceStable(values, func(i, j int) bool {
+			return !values[i].(bool) && values[j].(bool)
+		})
+	}
+
+	return values
+}
+
+// numberValues sorts the json.Number values by their numbers.
+type numberValues struct {
+	values  []interface{}
+	numbers []float64
+}
+
+func (n numberValues) Len() int           { return len(n.values) }
+func (n numberValues) Less(i, j int) bool { return n.numbers[i] < n.numbers[j] }
+func (n numberValues) Swap(i, j int) {
+	n.values[i], n.values[j] = n.values[j], n.values[i]
+	n.numbers[i], n.numbers[j] = n.numbers[j], n.numbers[i]
+}
+
+// normalizeSyntheticNumber rounds n to the precision of fieldType: the integer types drop the decimal part,
+// float and half_float are rounded to 32 and 16 bits floats.
+func normalizeSyntheticNumber(n json.Number, fieldType string) json.Number {
+	switch fieldType {
+	case FieldTypeLong, FieldTypeInteger, "short", "byte", FieldTypeUnsignedLong:
+		if _, err := n.Int64(); err == nil {
+			return n
+		}
+
+		f, err := n.Float64()
+		if err != nil {
+			return n
+		}
+
+		return json.Number(fmt.Sprintf("%d", int64(f)))
+	case FieldTypeFloat, FieldTypeHalfFloat:
+		f, err := n.Float64()
+		if err != nil {
+			return n
+		}
+
+		if fieldType == FieldTypeHalfFloat {
+			f = roundHalfFloat(f)
+		}
+
+		b, err := json.Marshal(float32(f))
+		if err != nil {
+			return n
+		}
+
+		return json.Number(b)
+	default:
+		return n
+	}
+}
+
+// roundHalfFloat rounds f to the 11 significant bits of a 16 bits float.
+func roundHalfFloat(f float64) float64 {
+	if f == 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return f
+	}
+
+	frac, exp := math.Frexp(f)
+	return math.Ldexp(math.RoundToEven(frac*(1<<11))/(1<<11), exp)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
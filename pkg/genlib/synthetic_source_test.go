@@ -0,0 +1,50 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"testing"
+)
+
+func Test_NewSyntheticSource(t *testing.T) {
+	flds := Fields{
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "event.duration", Type: FieldTypeLong},
+		{Name: "cpu.pct", Type: FieldTypeFloat},
+		{Name: "cpu.load", Type: FieldTypeHalfFloat},
+		{Name: "metrics.*", Type: FieldTypeObject, ObjectType: FieldTypeLong},
+		{Name: "process.threads", Type: FieldTypeNested},
+		{Name: "location", Type: FieldTypeGeoPoint},
+	}
+
+	testCases := []struct {
+		doc      string
+		expected string
+	}{
+		{doc: `{"host.name":"web-1","event":{"duration":12.7},"@timestamp":"2024-01-01T00:00:00Z"}`, expected: `{"@timestamp":"2024-01-01T00:00:00Z","event":{"duration":12},"host":{"name":"web-1"}}`},
+		{doc: `{"tags":["b","a","b",null],"ports":[443,[80,443]],"flags":[true,false]}`, expected: `{"flags":[false,true],"ports":[80,443,443],"tags":["a","b"]}`},
+		{doc: `{"cpu":{"pct":0.1234567891,"load":3.14159}}`, expected: `{"cpu":{"load":3.140625,"pct":0.12345679}}`},
+		{doc: `{"metrics.count":2.5,"other":2.5}`, expected: `{"metrics":{"count":2},"other":2.5}`},
+		{doc: `{"user":[{"name":"bob"},{"name":"alice","id":1}],"single":["x"],"empty":[],"none":null,"obj":{"a":null}}`, expected: `{"single":"x","user":{"id":1,"name":["alice","bob"]}}`},
+		{doc: `{"process":{"threads":[{"id":2},{"id":1}]}}`, expected: `{"process":{"threads":[{"id":2},{"id":1}]}}`},
+		{doc: `{"location":[{"lon":2,"lat":1},{"lon":4,"lat":3}],"message":"a < b"}`, expected: `{"location":[{"lat":1,"lon":2},{"lat":3,"lon":4}],"message":"a < b"}`},
+	}
+
+	syntheticSource := NewSyntheticSource(flds)
+	for _, tc := range testCases {
+		doc, err := syntheticSource([]byte(tc.doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(doc) != tc.expected {
+			t.Errorf("expected %s to be rewritten to %s, got %s", tc.doc, tc.expected, string(doc))
+		}
+	}
+
+	if _, err := syntheticSource([]byte(`not json`)); err == nil {
+		t.Errorf("expected error for a document that is not JSON")
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// textTemplateErrorRegex splits the errors of text/template in: line, column if any, and message
+	textTemplateErrorRegex = regexp.MustCompile(`(?s)^template: [^:]*:(\d+):(?:(\d+):)? (.*)$`)
+	// textTemplateExecRegex splits the context of the execution errors of text/template in: node and message
+	textTemplateExecRegex = regexp.MustCompile(`(?s)^executing "[^"]*" at <(.*?)>: (.*)$`)
+	// textTemplateFieldRegex matches the field referenced by the node of an execution error
+	textTemplateFieldRegex = regexp.MustCompile(`^(?:generate(?:Raw)?|meta) "([^"]+)"`)
+	// yamlErrorRegex splits the errors of YAML templates in: line and message
+	yamlErrorRegex = regexp.MustCompile(`(?s)^yaml: line (\d+): (.*)$`)
+	// quotedTokenRegex matches the token quoted in the message of a parse error, like the name of an undefined function
+	quotedTokenRegex = regexp.MustCompile(`"([^"]+)"`)
+)
+
+// TemplateError is an error parsing a template or generating an event from it, located at the line and column
+// of the template, and at the placeholder of the field it concerns, where known.
+type TemplateError struct {
+	// Name is the name of the template, like its path, see SetTemplateName
+	Name string
+	// Line and Column start at 1, they are zero where unknown
+	Line   int
+	Column int
+	Field  string
+	Err    error
+}
+
+func (e *TemplateError) Error() string {
+	var b strings.Builder
+	b.WriteString("template")
+	if e.Name != "" {
+		b.WriteString(" ")
+		b.WriteString(e.Name)
+	}
+
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%d", e.Line)
+		if e.Column > 0 {
+			fmt.Fprintf(&b, ":%d", e.Column)
+		}
+	}
+
+	b.WriteString(": ")
+	if e.Field != "" {
+		fmt.Fprintf(&b, "field %s: ", e.Field)
+	}
+
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// SetTemplateName names the template err is located in, like after its path, if err is a TemplateError.
+func SetTemplateName(err error, name string) error {
+	var templateErr *TemplateError
+	if errors.As(err, &templateErr) {
+		templateErr.Name = name
+	}
+
+	return err
+}
+
+// placeholderError returns err located at the n-th placeholder of fieldName in template, counting from zero.
+func placeholderError(template []byte, fieldName string, n int, err error) *TemplateError {
+	line, column := placeholderLocation(template, fieldName, n)
+	return &TemplateError{Line: line, Column: column, Field: fieldName, Err: err}
+}
+
+// placeholderLocation returns the line and the column of the n-th placeholder of fieldName in template,
+// counting from zero, or zeros if not found.
+func placeholderLocation(template []byte, fieldName string, n int) (int, int) {
+	placeholderRegex := regexp.MustCompile(`{{\s*\.` + regexp.QuoteMeta(fieldName) + `\s*(?:\|[^}]*)?}}`)
+	locs := placeholderRegex.FindAllIndex(template, n+1)
+	if len(locs) <= n {
+		return 0, 0
+	}
+
+	return lineColumn(template, locs[n][0])
+}
+
+// lineColumn returns the line and the column of the byte at offset of template.
+func lineColumn(template []byte, offset int) (int, int) {
+	line := 1 + bytes.Count(template[:offset], []byte("\n"))
+	column := offset + 1
+	if i := bytes.LastIndexByte(template[:offset], '\n'); i >= 0 {
+		column = offset - i
+	}
+
+	return line, column
+}
+
+// newTextTemplateError converts an error of text/template to a TemplateError: the column of parse errors, that
+// text/template does not report, is the one of the token quoted in the message, if found in an action of the line.
+func newTextTemplateError(template []byte, err error) error {
+	match := textTemplateErrorRegex.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+
+	templateErr := &TemplateError{Err: errors.New(match[3])}
+	templateErr.Line, _ = strconv.Atoi(match[1])
+
+	if exec := textTemplateExecRegex.FindStringSubmatch(match[3]); exec != nil {
+		// the columns of text/template start at 0
+		if column, err := strconv.Atoi(match[2]); err == nil {
+			templateErr.Column = column + 1
+		}
+
+		templateErr.Err = errors.New(exec[2])
+		if field := textTemplateFieldRegex.FindStringSubmatch(exec[1]); field != nil {
+			templateErr.Field = field[1]
+		}
+
+		return templateErr
+	}
+
+	lines := bytes.Split(template, []byte("\n"))
+	token := quotedTokenRegex.FindStringSubmatch(match[3])
+	if token == nil || templateErr.Line > len(lines) {
+		return templateErr
+	}
+
+	// the token is looked for in the actions of the line only
+	line := lines[templateErr.Line-1]
+	for offset := 0; ; {
+		start := bytes.Index(line[offset:], []byte("{{"))
+		if start < 0 {
+			break
+		}
+
+		start += offset
+		end := bytes.Index(line[start:], []byte("}}"))
+		if end < 0 {
+			end = len(line)
+		} else {
+			end += start
+		}
+
+		if i := bytes.Index(line[start:end], []byte(token[1])); i >= 0 {
+			templateErr.Column = start + i + 1
+			break
+		}
+
+		offset = end
+	}
+
+	return templateErr
+}
+
+// newYAMLTemplateError converts an error parsing a YAML or JSON template to a TemplateError.
+func newYAMLTemplateError(err error) error {
+	templateErr := &TemplateError{Err: fmt.Errorf("not a valid YAML or JSON document: %w", err)}
+	if match := yamlErrorRegex.FindStringSubmatch(err.Error()); match != nil {
+		templateErr.Line, _ = strconv.Atoi(match[1])
+		templateErr.Err = fmt.Errorf("not a valid YAML or JSON document: %s", match[2])
+	}
+
+	return templateErr
+}
@@ -0,0 +1,99 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"fmt"
+	"math/rand"
+	"text/template"
+	"time"
+)
+
+// addTextTemplateFuncs adds to templateFns the functions provided by the generator on top of the sprig ones.
+// The random functions use the generators random source, so that the same seed produces the same values.
+func addTextTemplateFuncs(templateFns template.FuncMap, gen *GeneratorWithTextTemplate, fields Fields) {
+	templateFns["timeDuration"] = func(duration int64) time.Duration {
+		return time.Duration(duration)
+	}
+
+	templateFns["formatTime"] = func(layout string, t time.Time) string {
+		return t.Format(layout)
+	}
+
+	templateFns["uuidv4"] = randUUIDv4
+
+	templateFns["randChoice"] = func(values ...interface{}) (interface{}, error) {
+		if len(values) == 0 {
+			return nil, fmt.Errorf("randChoice requires at least one value")
+		}
+
+		return values[rand.Intn(len(values))], nil
+	}
+
+	templateFns["randInt"] = func(min, max int) (int, error) {
+		if min >= max {
+			return 0, fmt.Errorf("randInt requires min to be lower than max")
+		}
+
+		return min + rand.Intn(max-min), nil
+	}
+
+	// xmlEscape and jsonEscape escape their argument, see escapeFuncs: the values of generate piped to them are not
+	// JSON escaped by default, see rawGenerateCalls
+	for funcName := range escapeFuncs {
+		funcName := funcName
+		templateFns[funcName] = func(v interface{}) string {
+			return pipeString(funcName, fmt.Sprint(v))
+		}
+	}
+
+	// eventTime and eventIndex read the state of the event being emitted
+	templateFns["eventTime"] = func() time.Time {
+		return gen.state.nearTime()
+	}
+
+	templateFns["eventIndex"] = func() uint64 {
+		return gen.state.counter
+	}
+
+	byName := make(map[string]Field, len(fields))
+	for _, field := range fields {
+		byName[field.Name] = field
+	}
+
+	// meta returns the metadata of a field in the fields definition, like its unit
+	templateFns["meta"] = func(fieldName, key string) (string, error) {
+		field, ok := byName[fieldName]
+		if !ok {
+			return "", fmt.Errorf("meta: field %s not in the fields definition", fieldName)
+		}
+
+		switch key {
+		case "type":
+			return field.Type, nil
+		case "description":
+			return field.Description, nil
+		case "unit":
+			return field.Unit, nil
+		case "metric_type":
+			return field.MetricType, nil
+		default:
+			return "", fmt.Errorf("meta: key must be one of type, description, unit and metric_type, got %s", key)
+		}
+	}
+}
+
+// randUUIDv4 returns a random UUID version 4
+func randUUIDv4() string {
+	var u [16]byte
+	for i := range u {
+		u[i] = byte(rand.Intn(256))
+	}
+
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
@@ -0,0 +1,74 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"time"
+)
+
+// appendTimestamp appends t to b formatted with FieldTypeTimeLayout, as t.AppendFormat does: formatting the layout
+// of the date fields of every event by hand takes a fraction of the time of the generic formatting.
+func appendTimestamp(b []byte, t time.Time) []byte {
+	year, month, day := t.Date()
+	if year < 0 || year > 9999 {
+		return t.AppendFormat(b, FieldTypeTimeLayout)
+	}
+
+	hour, min, sec := t.Clock()
+	b = appendDigits(b, year, 4)
+	b = append(b, '-')
+	b = appendDigits(b, int(month), 2)
+	b = append(b, '-')
+	b = appendDigits(b, day, 2)
+	b = append(b, 'T')
+	b = appendDigits(b, hour, 2)
+	b = append(b, ':')
+	b = appendDigits(b, min, 2)
+	b = append(b, ':')
+	b = appendDigits(b, sec, 2)
+
+	// microseconds, truncated, without the trailing zeros
+	if micros := t.Nanosecond() / 1000; micros > 0 {
+		digits := 6
+		for micros%10 == 0 {
+			micros /= 10
+			digits--
+		}
+
+		b = append(b, '.')
+		b = appendDigits(b, micros, digits)
+	}
+
+	_, offset := t.Zone()
+	if offset == 0 {
+		return append(b, 'Z')
+	}
+
+	sign := byte('+')
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+
+	b = append(b, sign)
+	b = appendDigits(b, offset/3600, 2)
+	b = append(b, ':')
+	return appendDigits(b, offset%3600/60, 2)
+}
+
+// appendDigits appends to b the n least significant decimal digits of v, zero padded.
+func appendDigits(b []byte, v, n int) []byte {
+	start := len(b)
+	for i := 0; i < n; i++ {
+		b = append(b, '0')
+	}
+
+	for i := len(b) - 1; i >= start; i-- {
+		b[i] = byte('0' + v%10)
+		v /= 10
+	}
+
+	return b
+}
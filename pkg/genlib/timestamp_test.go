@@ -0,0 +1,39 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func Test_AppendTimestamp(t *testing.T) {
+	zones := []*time.Location{time.UTC, time.FixedZone("", 5*3600+30*60), time.FixedZone("", -8*3600), time.FixedZone("", -(3*3600 + 15*60))}
+	times := []time.Time{
+		time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		time.Date(2024, 12, 31, 23, 59, 59, 999999999, time.UTC),
+		time.Date(2024, 6, 1, 0, 0, 0, 120000, time.UTC),
+		time.Date(2024, 6, 1, 0, 0, 0, 999, time.UTC),
+		time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(12345, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(-1, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		times = append(times, time.Unix(r.Int63n(1<<35), r.Int63n(int64(time.Second))))
+	}
+
+	for _, tm := range times {
+		for _, zone := range zones {
+			tm := tm.In(zone)
+			expected := tm.Format(FieldTypeTimeLayout)
+			if got := string(appendTimestamp(nil, tm)); got != expected {
+				t.Errorf("expected %s, got %s", expected, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// FieldTrace describes how the value of a field has been generated in an event.
+type FieldTrace struct {
+	Field   string `json:"field"`
+	Emitter string `json:"emitter"`
+	// PoolEntry is the index of the value in the pool of a field with cardinality
+	PoolEntry *int `json:"pool_entry,omitempty"`
+	// Draw is the random index drawn for a field with enum
+	Draw *int `json:"draw,omitempty"`
+	// Sparse is null or omitted for a field whose value has been replaced with null or omitted by null_percentage or
+	// omit_percentage, its value is empty then
+	Sparse string `json:"sparse,omitempty"`
+	Value  string `json:"value"`
+
+	// valueTraced is set when Value has been traced before the stub of the JSON member of the field wrapped it
+	valueTraced bool
+}
+
+const (
+	// TraceSparseNull is the Sparse of the traces of the fields whose value has been replaced with null
+	TraceSparseNull = "null"
+	// TraceSparseOmitted is the Sparse of the traces of the fields whose value has been omitted
+	TraceSparseOmitted = "omitted"
+)
+
+// SetTracing enables or disables the tracing of the fields for the next emitted event.
+func (s *GenState) SetTracing(enabled bool) {
+	s.tracing = enabled
+	s.traces = s.traces[:0]
+	for k := range s.traceAnnotations {
+		delete(s.traceAnnotations, k)
+	}
+}
+
+// Traces returns the traces of the fields of the last emitted event, if tracing was enabled.
+func (s *GenState) Traces() []FieldTrace {
+	return s.traces
+}
+
+func (s *GenState) tracePoolEntry(fieldName string, entry int) {
+	if !s.tracing {
+		return
+	}
+
+	// a copy of entry, so that it is not moved to the heap when tracing is disabled
+	poolEntry := entry
+	annotation := s.traceAnnotations[fieldName]
+	annotation.PoolEntry = &poolEntry
+	s.traceAnnotations[fieldName] = annotation
+}
+
+func (s *GenState) traceDraw(fieldName string, draw int) {
+	if !s.tracing {
+		return
+	}
+
+	// a copy of draw, so that it is not moved to the heap when tracing is disabled
+	drawn := draw
+	annotation := s.traceAnnotations[fieldName]
+	annotation.Draw = &drawn
+	s.traceAnnotations[fieldName] = annotation
+}
+
+// traceSparse records that the value of fieldName has been replaced with null or omitted, as drawn by drawSparse.
+func (s *GenState) traceSparse(fieldName string, sparse int) {
+	if !s.tracing || sparse == sparseValue {
+		return
+	}
+
+	annotation := s.traceAnnotations[fieldName]
+	annotation.Sparse = TraceSparseNull
+	if sparse == sparseOmit {
+		annotation.Sparse = TraceSparseOmitted
+	}
+
+	s.traceAnnotations[fieldName] = annotation
+}
+
+// traceValue records the value of fieldName before the stub of its JSON member writes it along with the member.
+func (s *GenState) traceValue(fieldName, value string) {
+	annotation := s.traceAnnotations[fieldName]
+	annotation.Value = value
+	annotation.valueTraced = true
+	s.traceAnnotations[fieldName] = annotation
+}
+
+func (s *GenState) addTrace(fieldName, emitter, value string) {
+	trace := s.traceAnnotations[fieldName]
+	delete(s.traceAnnotations, fieldName)
+
+	trace.Field = fieldName
+	trace.Emitter = emitter
+	switch {
+	case len(trace.Sparse) > 0:
+		trace.Value = ""
+	case !trace.valueTraced:
+		trace.Value = value
+	}
+
+	trace.valueTraced = false
+	s.traces = append(s.traces, trace)
+}
+
+// emitterName describes the emitter bindField selects for the field.
+func emitterName(cfg Config, field Field) string {
+	if len(field.Value) > 0 {
+		return "static"
+	}
+
+	fieldCfg, _ := cfg.GetField(field.Name)
+	if fieldCfg.Value != nil {
+		return "static.config"
+	}
+
+	if len(fieldCfg.CopyFrom) > 0 {
+		return "copy"
+	}
+
+	if len(fieldCfg.Expression) > 0 {
+		return "derived"
+	}
+
+	name := field.Type
+	if emitter, ok := customEmitter(fieldCfg, field); ok {
+		name = "emitter." + emitter.Name
+	} else if usesRealisticGenerator(fieldCfg, field) {
+		name = "generator." + fieldCfg.Generator
+	} else {
+		switch field.Type {
+		case FieldTypeKeyword:
+			if len(fieldCfg.Enum) > 0 {
+				name += ".enum"
+			} else if len(field.Example) > 0 {
+				name += ".example"
+			}
+		case FieldTypeDouble, FieldTypeFloat, FieldTypeHalfFloat, FieldTypeScaledFloat, FieldTypeInteger, FieldTypeLong, FieldTypeUnsignedLong:
+			if fieldCfg.Fuzziness > 0 {
+				name += ".fuzziness"
+			}
+		case FieldTypeIP:
+			if len(fieldCfg.CIDR) > 0 {
+				name += ".cidr"
+			}
+		case FieldTypeGeoPoint:
+			if len(fieldCfg.GeoClusters) > 0 {
+				name += ".clusters"
+			}
+		case "":
+			name = "words"
+		}
+	}
+
+	if fieldCfg.Cardinality.Values() > 0 {
+		name = "cardinality." + name
+	}
+
+	if len(fieldCfg.Rules) > 0 {
+		name = "rules." + name
+	}
+
+	if isArrayField(fieldCfg, field) {
+		name = "array." + name
+	}
+
+	if isSparse(fieldCfg) {
+		name = "sparse." + name
+	}
+
+	return name
+}
+
+func makeTraceStub(fieldName, emitter string, prefixLen int, boundF emitFNotReturn) emitFNotReturn {
+	return func(state *GenState, buf *bytes.Buffer) error {
+		offset := buf.Len()
+		if err := boundF(state, buf); err != nil {
+			return err
+		}
+
+		value := buf.Bytes()[offset:]
+		if len(value) >= prefixLen {
+			value = value[prefixLen:]
+		}
+
+		state.addTrace(fieldName, emitter, string(value))
+		return nil
+	}
+}
+
+// makeTraceValueStub traces the value written by the bound function of a field whose JSON member is written by its
+// stub, see makeSparseStub, so that the trace of the field is its value without the member.
+func makeTraceValueStub(fieldName string, boundF emitFNotReturn) emitFNotReturn {
+	return func(state *GenState, buf *bytes.Buffer) error {
+		offset := buf.Len()
+		if err := boundF(state, buf); err != nil {
+			return err
+		}
+
+		if state.tracing {
+			state.traceValue(fieldName, string(buf.Bytes()[offset:]))
+		}
+
+		return nil
+	}
+}
+
+func makeTraceStubWithReturn(fieldName, emitter string, boundF EmitF) EmitF {
+	return func(state *GenState, buf *bytes.Buffer) (interface{}, error) {
+		value, err := boundF(state, buf)
+		if err != nil {
+			return value, err
+		}
+
+		state.addTrace(fieldName, emitter, fmt.Sprint(value))
+		return value, nil
+	}
+}
+
+// traceEmitters returns the emitter name of each field in fields.
+// Fields bound under a different name, like object keys, are given the emitter of their object.
+func traceEmitters(cfg Config, fields Fields, fieldNames []string) map[string]string {
+	emitters := make(map[string]string, len(fieldNames))
+	for _, field := range fields {
+		emitters[field.Name] = emitterName(cfg, field)
+	}
+
+	for _, fieldName := range fieldNames {
+		if _, ok := emitters[fieldName]; !ok {
+			emitters[fieldName] = "object"
+		}
+	}
+
+	return emitters
+}
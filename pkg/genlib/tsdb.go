@@ -0,0 +1,349 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"go.uber.org/multierr"
+)
+
+// DefaultTimeSeries is the count of time series of the events generated for a TSDB data stream, unless set otherwise
+const DefaultTimeSeries = 100
+
+// SortDimensionsFirst returns flds with the dimension fields first, sorted by name, and then the other fields
+// in their order: the events generated from the fields have their dimensions serialized in a stable order.
+func SortDimensionsFirst(flds Fields) Fields {
+	sorted := make(Fields, len(flds))
+	copy(sorted, flds)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Dimension != sorted[j].Dimension {
+			return sorted[i].Dimension
+		}
+
+		return sorted[i].Dimension && sorted[i].Name < sorted[j].Name
+	})
+
+	return sorted
+}
+
+// CheckDimensions returns an error for each dimension field of flds that is not referenced by the template of gen,
+// or that is configured to be null or omitted in some events: TSDB rejects the events missing a dimension.
+func CheckDimensions(cfg Config, gen Generator, flds Fields) error {
+	referenced := gen.Fields()
+
+	var errs []error
+	for _, field := range flds {
+		if !field.Dimension {
+			continue
+		}
+
+		if !isReferenced(field, referenced) {
+			errs = append(errs, fmt.Errorf("field %s: dimension not referenced by the template", field.Name))
+			continue
+		}
+
+		// the keys of object dimensions are dimensions as well
+		names := []string{field.Name}
+		for _, referencedField := range referenced {
+			if strings.HasPrefix(referencedField.Field.Name, replacer.Replace(field.Name)+".") {
+				names = append(names, referencedField.Field.Name)
+			}
+		}
+
+		for _, name := range names {
+			fieldCfg, _ := cfg.GetField(name)
+			if fieldCfg.NullPercentage > 0 || fieldCfg.OmitPercentage > 0 {
+				errs = append(errs, fmt.Errorf("field %s: dimension cannot have null_percentage or omit_percentage", name))
+			}
+		}
+	}
+
+	return multierr.Combine(errs...)
+}
+
+// TimeSeriesDimensions returns cfg with a cardinality of series for the dimension fields of flds, or the configured
+// object_keys of the object dimension fields, with neither a value, a cardinality nor an entity: the values of the dimensions
+// are drawn from pools cycled through together, so that the events belong to at most series time series.
+func TimeSeriesDimensions(cfg Config, flds Fields, series int) Config {
+	for _, field := range flds {
+		if !field.Dimension {
+			continue
+		}
+
+		// the cardinality of the object fields applies to their keys
+		names := []string{field.Name}
+		if strings.HasSuffix(field.Name, ".*") {
+			fieldCfg, _ := cfg.GetField(field.Name)
+			names = names[:0]
+			for _, objectKey := range fieldCfg.ObjectKeys {
+				names = append(names, replacer.Replace(field.Name)+"."+objectKey)
+			}
+		}
+
+		for _, name := range names {
+			// the attributes of entities are set by the count of entities
+			fieldCfg, _ := cfg.GetField(name)
+			if fieldCfg.Value != nil || fieldCfg.Cardinality.Values() > 0 || len(fieldCfg.Entity) > 0 {
+				continue
+			}
+
+			fieldCfg.Name = name
+			fieldCfg.Cardinality = config.Cardinality{Distinct: series}
+			cfg = cfg.WithField(fieldCfg)
+		}
+	}
+
+	return cfg
+}
+
+// NewTimeSeries returns a Middleware giving the metric fields of flds the semantics of their metric_type within the
+// time series of the JSON events, the ones with the same values of the dimension fields:
+//   - the values of the counter fields never decrease, the absolute value of the generated ones is added to the
+//     previous value of the time series, which starts over once it overflows
+//   - the values of the gauge fields move halfway from the previous value of the time series to the generated one
+//   - the @timestamp of the events of a time series increases by a millisecond at least, so that none of them is
+//     rejected as a duplicate
+//
+// The events without anything to rewrite are left as they are.
+func NewTimeSeries(flds Fields) Middleware {
+	ts := timeSeries{series: make(map[string]*seriesState)}
+	for _, field := range flds {
+		switch {
+		case field.Dimension:
+			ts.dimensions = append(ts.dimensions, field.Name)
+		case field.MetricType == "counter" || field.MetricType == "gauge":
+			if !strings.HasSuffix(field.Name, ".*") {
+				ts.metrics = append(ts.metrics, field)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	return func(doc []byte) ([]byte, error) {
+		decoder := json.NewDecoder(bytes.NewReader(doc))
+		decoder.UseNumber()
+		var event map[string]interface{}
+		if err := decoder.Decode(&event); err != nil {
+			return nil, fmt.Errorf("cannot rewrite as a time series sample a document that is not a JSON object: %w", err)
+		}
+
+		if !ts.rewrite(event) {
+			return doc, nil
+		}
+
+		buf.Reset()
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(event); err != nil {
+			return nil, err
+		}
+
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+	}
+}
+
+type timeSeries struct {
+	dimensions []string
+	metrics    Fields
+	series     map[string]*seriesState
+}
+
+// seriesState is the last timestamp and the last values of the metric fields of a time series.
+type seriesState struct {
+	timestamp time.Time
+	values    map[string]json.Number
+}
+
+// rewrite rewrites the metrics and the timestamp of event, telling whether any of them changed.
+func (ts timeSeries) rewrite(event map[string]interface{}) bool {
+	key := ts.seriesKey(event)
+	state, ok := ts.series[key]
+	if !ok {
+		state = &seriesState{values: make(map[string]json.Number)}
+		ts.series[key] = state
+	}
+
+	var changed bool
+	for _, field := range ts.metrics {
+		obj, name, found := lookupPath(event, field.Name)
+		if !found {
+			continue
+		}
+
+		value, isNumber := obj[name].(json.Number)
+		if !isNumber {
+			continue
+		}
+
+		previous, seen := state.values[field.Name]
+		switch {
+		case field.MetricType == "counter":
+			// the counters start from zero
+			if !seen {
+				previous = "0"
+			}
+
+			value = addCounter(previous, value)
+		case seen:
+			value = moveGauge(previous, value)
+		}
+
+		if value != obj[name] {
+			obj[name] = value
+			changed = true
+		}
+
+		state.values[field.Name] = value
+	}
+
+	obj, name, found := lookupPath(event, "@timestamp")
+	if !found {
+		return changed
+	}
+
+	t, ok := parseTimestamp(obj[name])
+	if !ok {
+		return changed
+	}
+
+	if !state.timestamp.IsZero() && !t.After(state.timestamp) {
+		t = state.timestamp.Add(time.Millisecond)
+		obj[name] = formatTimestamp(t, obj[name])
+		changed = true
+	}
+
+	state.timestamp = t
+	return changed
+}
+
+// seriesKey returns the key of the time series of event, made of the values of its dimension fields.
+func (ts timeSeries) seriesKey(event map[string]interface{}) string {
+	flat := make(map[string]interface{})
+	flattenObject(event, "", flat)
+
+	var key strings.Builder
+	for _, name := range sortedKeys(flat) {
+		for _, dimension := range ts.dimensions {
+			if name == dimension || strings.HasPrefix(name, replacer.Replace(dimension)+".") {
+				fmt.Fprintf(&key, "%s=%v\x00", name, flat[name])
+				break
+			}
+		}
+	}
+
+	return key.String()
+}
+
+// addCounter returns the counter value following previous, incremented by the absolute value of generated.
+func addCounter(previous, generated json.Number) json.Number {
+	p, errP := previous.Int64()
+	g, errG := generated.Int64()
+	if errP == nil && errG == nil {
+		if g < 0 {
+			g = -g
+		}
+
+		// the counter starts over once it overflows
+		if g < 0 || p > math.MaxInt64-g {
+			return json.Number(strconv.FormatInt(g, 10))
+		}
+
+		return json.Number(strconv.FormatInt(p+g, 10))
+	}
+
+	pf, _ := previous.Float64()
+	gf, _ := generated.Float64()
+	return formatNumber(pf+math.Abs(gf), decimals(previous, generated))
+}
+
+// moveGauge returns the gauge value halfway between previous and generated.
+func moveGauge(previous, generated json.Number) json.Number {
+	p, errP := previous.Int64()
+	g, errG := generated.Int64()
+	if errP == nil && errG == nil {
+		// halved first not to overflow
+		return json.Number(strconv.FormatInt(p/2+g/2+(p%2+g%2)/2, 10))
+	}
+
+	pf, _ := previous.Float64()
+	gf, _ := generated.Float64()
+	return formatNumber(pf/2+gf/2, decimals(generated))
+}
+
+// decimals returns the highest count of decimal places of numbers, -1 if one of them has an exponent.
+func decimals(numbers ...json.Number) int {
+	var d int
+	for _, n := range numbers {
+		s := n.String()
+		if strings.ContainsAny(s, "eE") {
+			return -1
+		}
+
+		if i := strings.IndexByte(s, '.'); i >= 0 && len(s)-i-1 > d {
+			d = len(s) - i - 1
+		}
+	}
+
+	return d
+}
+
+// formatNumber formats f with d decimal places, the ones needed to represent it if d is -1.
+func formatNumber(f float64, d int) json.Number {
+	return json.Number(strconv.FormatFloat(f, 'f', d, 64))
+}
+
+// parseTimestamp returns the time of a timestamp of a JSON event, either an RFC3339 string or epoch milliseconds.
+func parseTimestamp(value interface{}) (time.Time, bool) {
+	switch value := value.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, value)
+		return t, err == nil
+	case json.Number:
+		millis, err := value.Int64()
+		return time.UnixMilli(millis), err == nil
+	}
+
+	return time.Time{}, false
+}
+
+// formatTimestamp returns t formatted like the timestamp previous, either an RFC3339 string or epoch milliseconds.
+func formatTimestamp(t time.Time, previous interface{}) interface{} {
+	if _, isString := previous.(string); isString {
+		return t.Format(FieldTypeTimeLayout)
+	}
+
+	return json.Number(strconv.FormatInt(t.UnixMilli(), 10))
+}
+
+// lookupPath returns the object holding the value at the dotted path of event, and its key in the object, whether
+// the path is made of nested objects, of a dotted key or of both.
+func lookupPath(obj map[string]interface{}, path string) (map[string]interface{}, string, bool) {
+	if _, ok := obj[path]; ok {
+		return obj, path, true
+	}
+
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+
+		if child, ok := obj[path[:i]].(map[string]interface{}); ok {
+			if found, key, ok := lookupPath(child, path[i+1:]); ok {
+				return found, key, true
+			}
+		}
+	}
+
+	return nil, "", false
+}
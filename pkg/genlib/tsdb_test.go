@@ -0,0 +1,81 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+func Test_NewTimeSeries(t *testing.T) {
+	flds := Fields{
+		{Name: "@timestamp", Type: FieldTypeDate},
+		{Name: "host.name", Type: FieldTypeKeyword, Dimension: true},
+		{Name: "network.in.bytes", Type: FieldTypeLong, MetricType: "counter"},
+		{Name: "system.cpu.pct", Type: FieldTypeDouble, MetricType: "gauge"},
+	}
+
+	timeSeries := NewTimeSeries(flds)
+	testCases := []struct {
+		doc      string
+		expected string
+	}{
+		{
+			doc:      `{"@timestamp":"2024-01-01T00:00:10Z","host":{"name":"a"},"network.in.bytes":10,"system":{"cpu":{"pct":0.5}}}`,
+			expected: `{"@timestamp":"2024-01-01T00:00:10Z","host":{"name":"a"},"network.in.bytes":10,"system":{"cpu":{"pct":0.5}}}`,
+		},
+		{
+			// another time series
+			doc:      `{"@timestamp":"2024-01-01T00:00:05Z","host":{"name":"b"},"network.in.bytes":-3,"system":{"cpu":{"pct":0.1}}}`,
+			expected: `{"@timestamp":"2024-01-01T00:00:05Z","host":{"name":"b"},"network.in.bytes":3,"system":{"cpu":{"pct":0.1}}}`,
+		},
+		{
+			doc:      `{"@timestamp":"2024-01-01T00:00:01Z","host":{"name":"a"},"network.in.bytes":-5,"system":{"cpu":{"pct":0.9}}}`,
+			expected: `{"@timestamp":"2024-01-01T00:00:10.001Z","host":{"name":"a"},"network.in.bytes":15,"system":{"cpu":{"pct":0.7}}}`,
+		},
+		{
+			doc:      `{"@timestamp":"2024-01-01T00:00:20Z","host":{"name":"b"},"network.in.bytes":4,"system":{"cpu":{"pct":0.3}}}`,
+			expected: `{"@timestamp":"2024-01-01T00:00:20Z","host":{"name":"b"},"network.in.bytes":7,"system":{"cpu":{"pct":0.2}}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		doc, err := timeSeries([]byte(tc.doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(doc) != tc.expected {
+			t.Errorf("expected %s to be rewritten as %s, got %s", tc.doc, tc.expected, string(doc))
+		}
+	}
+
+	if _, err := timeSeries([]byte("GET / 200")); err == nil {
+		t.Errorf("expected error for a document that is not a JSON object")
+	}
+}
+
+func Test_TimeSeriesDimensions(t *testing.T) {
+	flds := Fields{
+		{Name: "host.name", Type: FieldTypeKeyword, Dimension: true},
+		{Name: "service.name", Type: FieldTypeKeyword, Dimension: true},
+		{Name: "labels.*", Type: FieldTypeObject, ObjectType: FieldTypeKeyword, Dimension: true},
+		{Name: "message", Type: FieldTypeKeyword},
+	}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("- name: service.name\n  cardinality:\n    distinct: 3\n- name: labels.*\n  object_keys: [\"env\"]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = TimeSeriesDimensions(cfg, flds, 10)
+	for name, expected := range map[string]int{"host.name": 10, "service.name": 3, "labels.env": 10, "labels.*": 0, "message": 0} {
+		fieldCfg, _ := cfg.GetField(name)
+		if fieldCfg.Cardinality.Values() != expected {
+			t.Errorf("expected cardinality %d for field %s, got %d", expected, name, fieldCfg.Cardinality.Values())
+		}
+	}
+}
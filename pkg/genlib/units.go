@@ -0,0 +1,90 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"math/rand"
+)
+
+// numericUnit is the kind of the unit of a numeric field, bounding the values generated for it.
+type numericUnit int
+
+const (
+	unitNone numericUnit = iota
+	// unitBytes values are non-negative
+	unitBytes
+	// unitPercent values are in 0-1 for floating point fields and in 0-100 for integer ones
+	unitPercent
+	// unitDuration values are positive
+	unitDuration
+)
+
+// maxIntPercent is the max value of the integer fields whose unit is percent
+const maxIntPercent = 100
+
+// fieldUnit returns the kind of the unit of field, as in the fields definition of the package spec.
+// The percent unit is ignored when a range is set in the config, since the range defines the values then.
+func fieldUnit(fieldCfg ConfigField, field Field) numericUnit {
+	switch field.Unit {
+	case "byte", "bytes":
+		return unitBytes
+	case "percent":
+		if fieldCfg.Range.IsSet() {
+			return unitNone
+		}
+
+		return unitPercent
+	case "d", "h", "m", "s", "ms", "micros", "nanos":
+		return unitDuration
+	}
+
+	return unitNone
+}
+
+// containsInt tells whether an integer value is in the bounds of the unit.
+func (u numericUnit) containsInt(v int) bool {
+	switch u {
+	case unitBytes:
+		return v >= 0
+	case unitPercent:
+		return v >= 0 && v <= maxIntPercent
+	case unitDuration:
+		return v > 0
+	}
+
+	return true
+}
+
+// containsFloat tells whether a floating point value is in the bounds of the unit.
+func (u numericUnit) containsFloat(v float64) bool {
+	switch u {
+	case unitBytes:
+		return v >= 0
+	case unitPercent:
+		return v >= 0 && v <= 1
+	case unitDuration:
+		return v > 0
+	}
+
+	return true
+}
+
+// makeFloatFunc returns the generator of the values of a floating point field.
+func makeFloatFunc(fieldCfg ConfigField, field Field) func() float64 {
+	if fieldUnit(fieldCfg, field) == unitPercent {
+		return rand.Float64
+	}
+
+	if bounds := fieldCfg.Range; bounds.Bounded {
+		return func() float64 {
+			return bounds.Min + rand.Float64()*(bounds.Max-bounds.Min)
+		}
+	}
+
+	dummyFunc := makeIntFunc(fieldCfg, field)
+	return func() float64 {
+		return float64(dummyFunc()) / rand.Float64()
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+)
+
+// errAborted fails the writes to an asyncSink once aborted.
+var errAborted = errors.New("sink aborted")
+
+// asyncRequest is either a buffer to write to the sink, or a flush of the sink to acknowledge on flushed.
+type asyncRequest struct {
+	buf     *bytes.Buffer
+	flushed chan error
+}
+
+// asyncSink is a Sink writing to another one from a dedicated goroutine: the writes are gathered in a buffer that
+// is queued once full, and the next one is filled meanwhile.
+type asyncSink struct {
+	Sink
+	buffers    int
+	bufferSize int
+
+	current *bytes.Buffer
+	queue   chan asyncRequest
+	free    chan *bytes.Buffer
+	done    chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewAsync returns a Sink writing to s from a dedicated goroutine through a queue of buffers of bufferSize bytes,
+// so that a slow destination does not stall the generation until all the buffers are full. The writes are never
+// split across buffers, s is written whole events. The errors of s are returned by the writes following them, Flush
+// and Close. It must not wrap an EventWriter, whose events are written one by one.
+func NewAsync(s Sink, buffers, bufferSize int) Sink {
+	if buffers < 2 {
+		buffers = 2
+	}
+
+	return &asyncSink{Sink: s, buffers: buffers, bufferSize: bufferSize}
+}
+
+// Open opens the underlying sink. The goroutine writing to it is started once the first buffer is queued, so that
+// none is left running by a sink that is opened and never written.
+func (s *asyncSink) Open(ctx context.Context, name string) error {
+	if err := s.Sink.Open(ctx, name); err != nil {
+		return err
+	}
+
+	s.err = nil
+	s.queue, s.done = nil, nil
+	s.free = make(chan *bytes.Buffer, s.buffers)
+	for i := 1; i < s.buffers; i++ {
+		s.free <- bytes.NewBuffer(make([]byte, 0, s.bufferSize))
+	}
+
+	s.current = bytes.NewBuffer(make([]byte, 0, s.bufferSize))
+	return nil
+}
+
+// start starts the goroutine writing to the underlying sink, unless started already.
+func (s *asyncSink) start() {
+	if s.queue != nil {
+		return
+	}
+
+	s.queue = make(chan asyncRequest, s.buffers)
+	s.done = make(chan struct{})
+	go s.run()
+}
+
+// stop stops the goroutine writing to the underlying sink, if started, once the queued requests are handled.
+func (s *asyncSink) stop() {
+	if s.queue == nil {
+		return
+	}
+
+	close(s.queue)
+	<-s.done
+	s.queue, s.done = nil, nil
+}
+
+// run writes the queued buffers to the underlying sink, until the queue is closed.
+func (s *asyncSink) run() {
+	defer close(s.done)
+	for req := range s.queue {
+		if req.buf != nil {
+			if s.error() == nil {
+				if _, err := s.Sink.Write(req.buf.Bytes()); err != nil {
+					s.setError(err)
+				}
+			}
+
+			req.buf.Reset()
+			s.free <- req.buf
+		}
+
+		if req.flushed != nil {
+			if s.error() == nil {
+				if err := s.Sink.Flush(); err != nil {
+					s.setError(err)
+				}
+			}
+
+			req.flushed <- s.error()
+		}
+	}
+}
+
+// Write adds p to the current buffer, queueing it first if p does not fit.
+func (s *asyncSink) Write(p []byte) (int, error) {
+	if err := s.error(); err != nil {
+		return 0, err
+	}
+
+	if s.current.Len() > 0 && s.current.Len()+len(p) > s.bufferSize {
+		s.queueCurrent()
+	}
+
+	return s.current.Write(p)
+}
+
+// queueCurrent queues the current buffer, waiting for a free one to fill next.
+func (s *asyncSink) queueCurrent() {
+	s.start()
+	s.queue <- asyncRequest{buf: s.current}
+	s.current = <-s.free
+}
+
+// Flush queues the current buffer and waits for all the queued ones to be written and the underlying sink flushed.
+func (s *asyncSink) Flush() error {
+	if s.current.Len() > 0 {
+		s.queueCurrent()
+	}
+
+	// nothing has been queued yet
+	if s.queue == nil {
+		return s.Sink.Flush()
+	}
+
+	flushed := make(chan error)
+	s.queue <- asyncRequest{flushed: flushed}
+	return <-flushed
+}
+
+// Close flushes the sink, stops the goroutine writing to the underlying sink and closes it.
+func (s *asyncSink) Close() error {
+	err := s.Flush()
+	s.stop()
+
+	if closeErr := s.Sink.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Abort stops the goroutine writing to the underlying sink, discarding the buffers not written yet, and aborts the
+// underlying sink, see Abort.
+func (s *asyncSink) Abort() error {
+	s.setError(errAborted)
+	s.stop()
+	return Abort(s.Sink)
+}
+
+func (s *asyncSink) error() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *asyncSink) setError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
@@ -0,0 +1,62 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"bufio"
+	"context"
+)
+
+// bufferedSink is a Sink gathering the writes to another one in batches.
+type bufferedSink struct {
+	Sink
+	size int
+	w    *bufio.Writer
+}
+
+// NewBuffered returns a Sink writing to s in batches of size bytes, instead of once per event, to cut the overhead of
+// the system calls of the files. The batches are written when full, and on Flush and Close. It must not wrap an
+// EventWriter, whose events are written one by one.
+func NewBuffered(s Sink, size int) Sink {
+	return &bufferedSink{Sink: s, size: size}
+}
+
+// Open opens the underlying sink and starts a new batch.
+func (s *bufferedSink) Open(ctx context.Context, name string) error {
+	if err := s.Sink.Open(ctx, name); err != nil {
+		return err
+	}
+
+	if s.w == nil {
+		s.w = bufio.NewWriterSize(s.Sink, s.size)
+	} else {
+		s.w.Reset(s.Sink)
+	}
+
+	return nil
+}
+
+func (s *bufferedSink) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+// Flush writes the current batch to the underlying sink and flushes it.
+func (s *bufferedSink) Flush() error {
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+
+	return s.Sink.Flush()
+}
+
+// Close writes the current batch and closes the underlying sink.
+func (s *bufferedSink) Close() error {
+	err := s.w.Flush()
+	if closeErr := s.Sink.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,69 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// bulkResponse is the part of the response of the bulk API telling which actions failed
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// NewElasticsearch returns a Sink sending the corpora, with their bulk request actions, to the bulk API of the
+// Elasticsearch at baseURL, keeping its query parameters, like refresh. The requests fail if any of their actions fails.
+func NewElasticsearch(baseURL string, opts ...HTTPOption) (Sink, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/_bulk"
+	return newHTTPSink(u.String(), checkBulkResponse, opts...)
+}
+
+// checkBulkResponse returns an error reporting the first failed action of a bulk request, if any.
+func checkBulkResponse(body []byte) error {
+	var resp bulkResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("cannot parse bulk response: %w", err)
+	}
+
+	if !resp.Errors {
+		return nil
+	}
+
+	var failed int
+	var firstErr error
+	for _, item := range resp.Items {
+		for op, result := range item {
+			if result.Status < 300 {
+				continue
+			}
+
+			failed++
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s action failed with status %d: %s: %s", op, result.Status, result.Error.Type, result.Error.Reason)
+			}
+		}
+	}
+
+	if failed == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%d of %d bulk actions failed, the first one: %w", failed, len(resp.Items), firstErr)
+}
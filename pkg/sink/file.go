@@ -0,0 +1,108 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/spf13/afero"
+)
+
+var dirPerm = os.FileMode(0770)
+var filePerm = os.FileMode(0660)
+
+// fileSink is a Sink writing each corpus to its own file in a folder.
+type fileSink struct {
+	fs   afero.Fs
+	dir  string
+	file afero.File
+	// appendAt is the size the existing file is truncated at before appending to it, -1 to create the file
+	appendAt int64
+}
+
+// NewFile returns a Sink writing each corpus to the file named after it in dir, created if missing.
+func NewFile(fs afero.Fs, dir string) Sink {
+	return &fileSink{fs: fs, dir: dir, appendAt: -1}
+}
+
+// NewAppendFile returns a Sink appending each corpus to the existing file named after it in dir, truncated at
+// size first: to resume the writing of a corpus whose first size bytes are known to be complete.
+func NewAppendFile(fs afero.Fs, dir string, size int64) Sink {
+	return &fileSink{fs: fs, dir: dir, appendAt: size}
+}
+
+// Open creates the file named name, truncating it if it exists, or opens it for appending to it.
+func (s *fileSink) Open(ctx context.Context, name string) error {
+	if s.appendAt >= 0 {
+		return s.openAppend(name)
+	}
+
+	if err := s.fs.MkdirAll(s.dir, dirPerm); err != nil {
+		return fmt.Errorf("cannot generate corpus location folder: %v", err)
+	}
+
+	file, err := s.fs.OpenFile(path.Join(s.dir, name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, filePerm)
+	if err != nil {
+		return err
+	}
+
+	s.file = file
+	return nil
+}
+
+// openAppend opens the existing file named name, truncated at the size to append from.
+func (s *fileSink) openAppend(name string) error {
+	file, err := s.fs.OpenFile(path.Join(s.dir, name), os.O_WRONLY, filePerm)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err == nil && info.Size() < s.appendAt {
+		err = fmt.Errorf("cannot append to %s from %d bytes, it has %d bytes only", file.Name(), s.appendAt, info.Size())
+	}
+
+	if err == nil {
+		err = file.Truncate(s.appendAt)
+	}
+
+	if err == nil {
+		_, err = file.Seek(s.appendAt, io.SeekStart)
+	}
+
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	s.file = file
+	return nil
+}
+
+func (s *fileSink) Write(p []byte) (int, error) {
+	return s.file.Write(p)
+}
+
+// Flush does nothing, the writes are not buffered.
+func (s *fileSink) Flush() error {
+	return nil
+}
+
+// Name returns the path of the file of the corpus.
+func (s *fileSink) Name() string {
+	if s.file == nil {
+		return ""
+	}
+
+	return s.file.Name()
+}
+
+func (s *fileSink) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,82 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"compress/gzip"
+	"context"
+)
+
+// GzipExtension is the extension added to the name of the corpora compressed by the sinks of NewGzip
+const GzipExtension = ".gz"
+
+// GzipOption is an option of the sinks of NewGzip.
+type GzipOption func(*gzipSink)
+
+// WithGzipLevel compresses with level, from gzip.BestSpeed to gzip.BestCompression, instead of gzip.DefaultCompression.
+func WithGzipLevel(level int) GzipOption {
+	return func(s *gzipSink) {
+		s.level = level
+	}
+}
+
+// gzipSink is a Sink compressing the corpus written to another one.
+type gzipSink struct {
+	Sink
+	level int
+	zw    *gzip.Writer
+}
+
+// NewGzip returns a Sink writing the corpora compressed with gzip to s, named with GzipExtension.
+func NewGzip(s Sink, opts ...GzipOption) Sink {
+	gs := &gzipSink{Sink: s, level: gzip.DefaultCompression}
+	for _, opt := range opts {
+		opt(gs)
+	}
+
+	return gs
+}
+
+func (s *gzipSink) Open(ctx context.Context, name string) error {
+	if err := s.Sink.Open(ctx, name+GzipExtension); err != nil {
+		return err
+	}
+
+	if s.zw != nil {
+		s.zw.Reset(s.Sink)
+		return nil
+	}
+
+	zw, err := gzip.NewWriterLevel(s.Sink, s.level)
+	if err != nil {
+		return err
+	}
+
+	s.zw = zw
+	return nil
+}
+
+func (s *gzipSink) Write(p []byte) (int, error) {
+	return s.zw.Write(p)
+}
+
+// Flush writes the pending compressed data to the underlying sink and flushes it.
+func (s *gzipSink) Flush() error {
+	if err := s.zw.Flush(); err != nil {
+		return err
+	}
+
+	return s.Sink.Flush()
+}
+
+// Close writes the gzip footer and closes the underlying sink.
+func (s *gzipSink) Close() error {
+	err := s.zw.Close()
+	if closeErr := s.Sink.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+
+	return err
+}
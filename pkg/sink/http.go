@@ -0,0 +1,155 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// defaultBatchSize is the size of the requests of the HTTP sinks, unless set with WithBatchSize
+const defaultBatchSize = 5 << 20
+
+// maxErrorBodySize is the size of the body of the failed responses reported in the errors
+const maxErrorBodySize = 512
+
+// HTTPOption is an option of the sinks of NewHTTP and NewElasticsearch.
+type HTTPOption func(*httpSink)
+
+// WithHeader sets the header key of the requests to value, like Authorization to ApiKey followed by a key.
+func WithHeader(key, value string) HTTPOption {
+	return func(s *httpSink) {
+		s.header.Set(key, value)
+	}
+}
+
+// WithBatchSize sends a request each time the events written reach size bytes, instead of 5MB.
+func WithBatchSize(size int) HTTPOption {
+	return func(s *httpSink) {
+		s.batchSize = size
+	}
+}
+
+// WithClient sends the requests with client, instead of http.DefaultClient.
+func WithClient(client *http.Client) HTTPOption {
+	return func(s *httpSink) {
+		s.client = client
+	}
+}
+
+// httpSink is a Sink sending the events in the body of POST requests, in batches.
+type httpSink struct {
+	url       *url.URL
+	header    http.Header
+	batchSize int
+	client    *http.Client
+	// checkResponse checks the body of a successful response, if set
+	checkResponse func(body []byte) error
+
+	batch bytes.Buffer
+}
+
+// NewHTTP returns a Sink sending the events of all the corpora to the endpoint at u, as the newline delimited body
+// of POST requests of about 5MB. The credentials in u, if any, are sent with basic authentication.
+func NewHTTP(u string, opts ...HTTPOption) (Sink, error) {
+	return newHTTPSink(u, nil, opts...)
+}
+
+func newHTTPSink(u string, checkResponse func(body []byte) error, opts ...HTTPOption) (*httpSink, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("url %s: must start with http:// or https://", parsed.Redacted())
+	}
+
+	s := &httpSink{
+		url:           parsed,
+		header:        http.Header{"Content-Type": []string{"application/x-ndjson"}},
+		batchSize:     defaultBatchSize,
+		client:        http.DefaultClient,
+		checkResponse: checkResponse,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+// Open does nothing, the events of all the corpora are sent to the same endpoint.
+func (s *httpSink) Open(ctx context.Context, name string) error {
+	return nil
+}
+
+// Write adds p, whole events, to the batch, sending it once full.
+func (s *httpSink) Write(p []byte) (int, error) {
+	n, _ := s.batch.Write(p)
+	if s.batch.Len() < s.batchSize {
+		return n, nil
+	}
+
+	return n, s.Flush()
+}
+
+// Flush sends the pending events.
+func (s *httpSink) Flush() error {
+	if s.batch.Len() == 0 {
+		return nil
+	}
+
+	// the batch is sent even once the generation is interrupted, to keep the events generated so far
+	req, err := http.NewRequest(http.MethodPost, s.url.String(), bytes.NewReader(s.batch.Bytes()))
+	if err != nil {
+		return err
+	}
+
+	req.Header = s.header.Clone()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot send events to %s: %w", s.Name(), err)
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("cannot send events to %s: %w", s.Name(), err)
+	}
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		if len(body) > maxErrorBodySize {
+			body = body[:maxErrorBodySize]
+		}
+
+		return fmt.Errorf("cannot send events to %s: %s: %s", s.Name(), resp.Status, body)
+	}
+
+	if s.checkResponse != nil {
+		if err := s.checkResponse(body); err != nil {
+			return fmt.Errorf("cannot send events to %s: %w", s.Name(), err)
+		}
+	}
+
+	s.batch.Reset()
+	return nil
+}
+
+// Name returns the url of the endpoint, without the password.
+func (s *httpSink) Name() string {
+	return s.url.Redacted()
+}
+
+// Close sends the pending events.
+func (s *httpSink) Close() error {
+	return s.Flush()
+}
@@ -0,0 +1,233 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// LumberjackScheme is the scheme of the address of a lumberjack input, like lumberjack://localhost:5044
+const LumberjackScheme = "lumberjack://"
+
+const (
+	lumberjackVersion         byte = '2'
+	lumberjackWindowFrame     byte = 'W'
+	lumberjackJSONFrame       byte = 'J'
+	lumberjackCompressedFrame byte = 'C'
+	lumberjackAckFrame        byte = 'A'
+)
+
+// lumberjackSink is a Sink shipping the events to a Logstash or Elastic Agent input speaking the lumberjack v2 protocol.
+// The events are sent in compressed windows of batchSize events, each acknowledged by the input before sending the next one.
+type lumberjackSink struct {
+	address   string
+	batchSize int
+	timeout   time.Duration
+
+	conn   net.Conn
+	reader *bufio.Reader
+	batch  [][]byte
+
+	// frames and zw are reused across windows, as well as the buffers of the events of the batch
+	frames bytes.Buffer
+	zw     *zlib.Writer
+}
+
+// NewLumberjack returns a Sink shipping the events to the lumberjack input at address, in windows of batchSize events.
+// The connection is established on the first window, timeout applies to connecting and to each window being acknowledged.
+// JSON object events are shipped as they are, other events as the message field of an object.
+func NewLumberjack(address string, batchSize int, timeout time.Duration) Sink {
+	return &lumberjackSink{
+		address:   address,
+		batchSize: batchSize,
+		timeout:   timeout,
+	}
+}
+
+// Open does nothing: the connection is established on the first window and the events of all the corpora are
+// shipped to the same input.
+func (s *lumberjackSink) Open(ctx context.Context, name string) error {
+	return nil
+}
+
+func (s *lumberjackSink) Name() string {
+	return LumberjackScheme + s.address
+}
+
+// Write ships p as a single event.
+func (s *lumberjackSink) Write(p []byte) (int, error) {
+	if err := s.WriteEvent(bytes.TrimSuffix(p, []byte("\n"))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (s *lumberjackSink) WriteEvent(event []byte) error {
+	var payload []byte
+	if len(s.batch) < cap(s.batch) {
+		payload = s.batch[:len(s.batch)+1][len(s.batch)][:0]
+	}
+
+	if json.Valid(event) && bytes.HasPrefix(bytes.TrimSpace(event), []byte("{")) {
+		payload = append(payload, event...)
+	} else {
+		message, err := json.Marshal(map[string]string{"message": string(event)})
+		if err != nil {
+			return err
+		}
+
+		payload = append(payload, message...)
+	}
+
+	s.batch = append(s.batch, payload)
+	if len(s.batch) < s.batchSize {
+		return nil
+	}
+
+	return s.Flush()
+}
+
+// Flush sends the pending events, waiting for the input to acknowledge them.
+func (s *lumberjackSink) Flush() error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+
+	if s.conn == nil {
+		conn, err := net.DialTimeout("tcp", s.address, s.timeout)
+		if err != nil {
+			return fmt.Errorf("cannot connect to lumberjack input: %w", err)
+		}
+
+		s.conn = conn
+		s.reader = bufio.NewReader(conn)
+	}
+
+	// the connection is left in an unknown state by a failed window, the next one is sent on a new connection
+	if err := s.conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		_ = s.disconnect()
+		return err
+	}
+
+	if err := s.sendWindow(); err != nil {
+		_ = s.disconnect()
+		return fmt.Errorf("cannot ship events to lumberjack input: %w", err)
+	}
+
+	if err := s.awaitAck(uint32(len(s.batch))); err != nil {
+		_ = s.disconnect()
+		return fmt.Errorf("cannot ship events to lumberjack input: %w", err)
+	}
+
+	s.batch = s.batch[:0]
+	return nil
+}
+
+// sendWindow writes the window frame followed by the compressed frame of the JSON frames of the events, sequenced from 1.
+func (s *lumberjackSink) sendWindow() error {
+	s.frames.Reset()
+	if s.zw == nil {
+		s.zw = zlib.NewWriter(&s.frames)
+	} else {
+		s.zw.Reset(&s.frames)
+	}
+
+	zw := s.zw
+	var header [12]byte
+	for i, payload := range s.batch {
+		header[0], header[1] = lumberjackVersion, lumberjackJSONFrame
+		binary.BigEndian.PutUint32(header[2:], uint32(i+1))
+		binary.BigEndian.PutUint32(header[6:], uint32(len(payload)))
+		if _, err := zw.Write(header[:10]); err != nil {
+			return err
+		}
+
+		if _, err := zw.Write(payload); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	header[0], header[1] = lumberjackVersion, lumberjackWindowFrame
+	binary.BigEndian.PutUint32(header[2:], uint32(len(s.batch)))
+	header[6], header[7] = lumberjackVersion, lumberjackCompressedFrame
+	binary.BigEndian.PutUint32(header[8:], uint32(s.frames.Len()))
+
+	if _, err := (&net.Buffers{header[:], s.frames.Bytes()}).WriteTo(s.conn); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// awaitAck reads the ack frames until the last event of the window is acknowledged:
+// the input can send partial acks, or acks of the previous sequence number as keepalive.
+func (s *lumberjackSink) awaitAck(last uint32) error {
+	frame := make([]byte, 6)
+	for {
+		if _, err := io.ReadFull(s.reader, frame); err != nil {
+			return err
+		}
+
+		if frame[0] != lumberjackVersion || frame[1] != lumberjackAckFrame {
+			return fmt.Errorf("unexpected frame %q", frame[:2])
+		}
+
+		if binary.BigEndian.Uint32(frame[2:]) == last {
+			return nil
+		}
+	}
+}
+
+// Close sends the pending events and closes the connection: the next corpus is shipped on a new one, without the
+// events left pending by a failure.
+func (s *lumberjackSink) Close() error {
+	err := s.Flush()
+	s.batch = s.batch[:0]
+	if closeErr := s.disconnect(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// disconnect closes the connection, if any, for the next window to establish a new one.
+func (s *lumberjackSink) disconnect() error {
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn, s.reader = nil, nil
+	return err
+}
+
+// ParseLumberjackAddress returns the host:port address of a lumberjack://host:port url.
+func ParseLumberjackAddress(u string) (string, error) {
+	if !strings.HasPrefix(u, LumberjackScheme) {
+		return "", fmt.Errorf("lumberjack url %s: must start with %s", u, LumberjackScheme)
+	}
+
+	address := strings.TrimPrefix(u, LumberjackScheme)
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return "", fmt.Errorf("lumberjack url %s: must be in the %shost:port form", u, LumberjackScheme)
+	}
+
+	return address, nil
+}
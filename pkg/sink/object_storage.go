@@ -0,0 +1,415 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// S3Scheme is the scheme of the urls of Amazon S3 buckets, like s3://bucket/prefix/
+	S3Scheme = "s3://"
+	// GCSScheme is the scheme of the urls of Google Cloud Storage buckets, like gs://bucket/prefix/
+	GCSScheme = "gs://"
+)
+
+const (
+	// defaultPartSize is the size of the parts of the multipart uploads, unless set with WithPartSize
+	defaultPartSize = 8 << 20
+	// maxParts is the count of parts of a multipart upload allowed by S3 and Cloud Storage
+	maxParts = 10000
+	// partSizeDoubling is the count of parts after which the size of the parts doubles, so that the objects can grow
+	// beyond maxParts times the part size
+	partSizeDoubling = 1000
+)
+
+// ObjectStorageOption is an option of the sink of NewObjectStorage.
+type ObjectStorageOption func(*objectStorageSink)
+
+// WithCredentials signs the requests with the access key accessKeyID and secretAccessKey, and the session token
+// sessionToken if not empty: the AWS credentials for S3, an HMAC key for Cloud Storage. Without credentials the
+// requests are anonymous.
+func WithCredentials(accessKeyID, secretAccessKey, sessionToken string) ObjectStorageOption {
+	return func(s *objectStorageSink) {
+		s.accessKeyID = accessKeyID
+		s.secretAccessKey = secretAccessKey
+		s.sessionToken = sessionToken
+	}
+}
+
+// WithRegion sets the region of the bucket, instead of us-east-1 for S3 and auto for Cloud Storage.
+func WithRegion(region string) ObjectStorageOption {
+	return func(s *objectStorageSink) {
+		s.region = region
+	}
+}
+
+// WithEndpoint sends the requests to the S3 compatible service at endpoint, like a MinIO server, addressing the
+// buckets by path, instead of to the endpoint of the scheme of the url.
+func WithEndpoint(endpoint *url.URL) ObjectStorageOption {
+	return func(s *objectStorageSink) {
+		s.endpoint = endpoint
+	}
+}
+
+// WithPartSize uploads the objects in parts of size bytes, instead of 8MB. S3 requires 5MB at least.
+func WithPartSize(size int) ObjectStorageOption {
+	return func(s *objectStorageSink) {
+		s.partSize = size
+	}
+}
+
+// WithStorageClient sends the requests with client, instead of http.DefaultClient.
+func WithStorageClient(client *http.Client) ObjectStorageOption {
+	return func(s *objectStorageSink) {
+		s.client = client
+	}
+}
+
+// objectStorageSink is a Sink streaming each corpus to its own object of a bucket, through a multipart upload of
+// the S3 API, the XML API of Cloud Storage being compatible with it. The parts are uploaded as they fill up, so that
+// the corpus is neither written to disk nor held in memory, and the object is created once the corpus is closed.
+type objectStorageSink struct {
+	scheme   string
+	bucket   string
+	prefix   string
+	endpoint *url.URL
+	region   string
+	partSize int
+	client   *http.Client
+
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+
+	key      string
+	uploadID string
+	parts    []completedPart
+	part     bytes.Buffer
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// NewObjectStorage returns a Sink writing each corpus to the object named after it under the prefix of u, either
+// s3://bucket/prefix/ or gs://bucket/prefix/, through a multipart upload.
+func NewObjectStorage(u string, opts ...ObjectStorageOption) (Sink, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &objectStorageSink{
+		scheme:   parsed.Scheme + "://",
+		bucket:   parsed.Host,
+		prefix:   strings.TrimPrefix(parsed.Path, "/"),
+		partSize: defaultPartSize,
+		client:   http.DefaultClient,
+	}
+
+	switch s.scheme {
+	case S3Scheme:
+		s.region = "us-east-1"
+	case GCSScheme:
+		s.region = "auto"
+	default:
+		return nil, fmt.Errorf("url %s: must start with %s or %s", u, S3Scheme, GCSScheme)
+	}
+
+	if s.bucket == "" {
+		return nil, fmt.Errorf("url %s: must provide a bucket", u)
+	}
+
+	if s.prefix != "" && !strings.HasSuffix(s.prefix, "/") {
+		s.prefix += "/"
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.partSize <= 0 {
+		return nil, errors.New("the size of the parts must be positive")
+	}
+
+	return s, nil
+}
+
+// Open starts the multipart upload of the object named name under the prefix.
+func (s *objectStorageSink) Open(ctx context.Context, name string) error {
+	s.key = s.prefix + name
+	s.uploadID = ""
+	s.parts = s.parts[:0]
+	s.part.Reset()
+
+	body, _, err := s.do(http.MethodPost, url.Values{"uploads": []string{""}}, nil)
+	if err != nil {
+		return fmt.Errorf("cannot start the upload of %s: %w", s.Name(), err)
+	}
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+
+	if err := xml.Unmarshal(body, &result); err != nil || result.UploadID == "" {
+		return fmt.Errorf("cannot start the upload of %s: unexpected response %s", s.Name(), truncate(body))
+	}
+
+	s.uploadID = result.UploadID
+	return nil
+}
+
+// Write adds p to the part being filled, uploading it once full.
+func (s *objectStorageSink) Write(p []byte) (int, error) {
+	n, _ := s.part.Write(p)
+	if s.part.Len() < s.partSize<<(len(s.parts)/partSizeDoubling) {
+		return n, nil
+	}
+
+	return n, s.uploadPart()
+}
+
+// Flush does nothing: the parts but the last one must be of 5MB at least, and the object is not available
+// until the upload completes anyway.
+func (s *objectStorageSink) Flush() error {
+	return nil
+}
+
+// Name returns the url of the object.
+func (s *objectStorageSink) Name() string {
+	return s.scheme + s.bucket + "/" + s.key
+}
+
+// Close uploads the last part and completes the upload, creating the object. The upload is aborted if it cannot
+// complete, so that its parts are not left behind in the bucket.
+func (s *objectStorageSink) Close() error {
+	err := s.uploadPart()
+	if err == nil {
+		err = s.complete()
+	}
+
+	if err != nil {
+		if abortErr := s.Abort(); abortErr != nil {
+			return fmt.Errorf("%w, and %v", err, abortErr)
+		}
+	}
+
+	return err
+}
+
+// Abort aborts the upload, deleting the parts uploaded so far: the object is not created.
+func (s *objectStorageSink) Abort() error {
+	if _, _, err := s.do(http.MethodDelete, url.Values{"uploadId": []string{s.uploadID}}, nil); err != nil {
+		return fmt.Errorf("cannot abort the upload of %s: %w", s.Name(), err)
+	}
+
+	return nil
+}
+
+// uploadPart uploads the part being filled, even if empty when it is the only one.
+func (s *objectStorageSink) uploadPart() error {
+	if s.part.Len() == 0 && len(s.parts) > 0 {
+		return nil
+	}
+
+	number := len(s.parts) + 1
+	if number > maxParts {
+		return fmt.Errorf("cannot upload %s: more than %d parts", s.Name(), maxParts)
+	}
+
+	query := url.Values{"partNumber": []string{strconv.Itoa(number)}, "uploadId": []string{s.uploadID}}
+	_, header, err := s.do(http.MethodPut, query, s.part.Bytes())
+	if err != nil {
+		return fmt.Errorf("cannot upload part %d of %s: %w", number, s.Name(), err)
+	}
+
+	s.parts = append(s.parts, completedPart{PartNumber: number, ETag: header.Get("ETag")})
+	s.part.Reset()
+	return nil
+}
+
+// complete completes the upload with its parts.
+func (s *objectStorageSink) complete() error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: s.parts})
+	if err != nil {
+		return err
+	}
+
+	response, _, err := s.do(http.MethodPost, url.Values{"uploadId": []string{s.uploadID}}, body)
+	if err != nil {
+		return fmt.Errorf("cannot complete the upload of %s: %w", s.Name(), err)
+	}
+
+	// the failures of the completion can be reported once the response has started, with a 200 status
+	if bytes.Contains(response, []byte("<Error>")) {
+		return fmt.Errorf("cannot complete the upload of %s: %s", s.Name(), truncate(response))
+	}
+
+	return nil
+}
+
+// do sends the request of method for the object with query and body, returning the body and the header of the
+// response. The requests are sent even once the generation is interrupted, to keep the events generated so far.
+func (s *objectStorageSink) do(method string, query url.Values, body []byte) ([]byte, http.Header, error) {
+	req, err := http.NewRequest(method, s.objectURL(query), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sum := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(sum[:]), time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer resp.Body.Close()
+
+	response, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, nil, fmt.Errorf("%s: %s", resp.Status, truncate(response))
+	}
+
+	return response, resp.Header, nil
+}
+
+// objectURL returns the url of the object with query: addressed by path with WithEndpoint, by virtual host on S3.
+func (s *objectStorageSink) objectURL(query url.Values) string {
+	key := uriEncode(s.key, false)
+	var u string
+	switch {
+	case s.endpoint != nil:
+		u = strings.TrimSuffix(s.endpoint.String(), "/") + "/" + uriEncode(s.bucket, true) + "/" + key
+	case s.scheme == GCSScheme:
+		u = "https://storage.googleapis.com/" + uriEncode(s.bucket, true) + "/" + key
+	default:
+		u = "https://" + s.bucket + ".s3." + s.region + ".amazonaws.com/" + key
+	}
+
+	return u + "?" + canonicalQuery(query)
+}
+
+// sign signs req with the AWS Signature Version 4, accepted by Cloud Storage with HMAC keys as well, unless there
+// are no credentials. The signed headers are the host and the headers of req.
+func (s *objectStorageSink) sign(req *http.Request, payloadHash string, now time.Time) {
+	if s.accessKeyID == "" {
+		return
+	}
+
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + s.secretAccessKey)
+	for _, part := range []string{now.Format("20060102"), s.region, "s3", "aws4_request", stringToSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.accessKeyID, scope, signedHeaders, hex.EncodeToString(key)))
+}
+
+// canonicalQuery returns query sorted by key and encoded the way the signature requires.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes the bytes of s but the unreserved characters, and the slashes unless encodeSlash.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// truncate truncates the body of a response reported in an error.
+func truncate(body []byte) []byte {
+	if len(body) > maxErrorBodySize {
+		return body[:maxErrorBodySize]
+	}
+
+	return body
+}
@@ -0,0 +1,46 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package sink provides the destinations a corpus is written to: files, compressed or not, writers, HTTP endpoints,
+// Elasticsearch and lumberjack inputs. Other destinations can be supplied by implementing Sink.
+package sink
+
+import (
+	"context"
+	"io"
+)
+
+// Sink is the destination a corpus is written to. It is opened for each corpus, then written the events one per
+// write, each preceded by its bulk request action if any, flushed when the events written so far must be made
+// available, like while waiting for the next event in live mode, and closed at the end of the corpus.
+type Sink interface {
+	// Open opens the destination of the corpus named name, like the name of its file
+	Open(ctx context.Context, name string) error
+	io.Writer
+	Flush() error
+	// Name identifies the destination, like the path of a file
+	Name() string
+	Close() error
+}
+
+// EventWriter is implemented by the sinks shipping the events rather than writing a corpus:
+// they are written each event on its own, without the bulk request action and the trailing newline.
+type EventWriter interface {
+	WriteEvent(event []byte) error
+}
+
+// Aborter is implemented by the sinks that can discard the corpus written so far, like the multipart uploads of the
+// object storage sink: they are aborted instead of closed when the generation of the corpus fails.
+type Aborter interface {
+	Abort() error
+}
+
+// Abort aborts s if it is an Aborter, and closes it otherwise.
+func Abort(s Sink) error {
+	if aborter, ok := s.(Aborter); ok {
+		return aborter.Abort()
+	}
+
+	return s.Close()
+}
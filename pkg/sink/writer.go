@@ -0,0 +1,34 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"bufio"
+	"context"
+	"io"
+)
+
+// writerSink is a buffered Sink writing to an io.Writer that it does not own.
+type writerSink struct {
+	*bufio.Writer
+	name string
+}
+
+// NewWriter returns a Sink writing all the corpora to w, named name: closing the sink flushes it, without closing w.
+func NewWriter(name string, w io.Writer) Sink {
+	return writerSink{Writer: bufio.NewWriter(w), name: name}
+}
+
+func (s writerSink) Open(ctx context.Context, name string) error {
+	return nil
+}
+
+func (s writerSink) Name() string {
+	return s.name
+}
+
+func (s writerSink) Close() error {
+	return s.Flush()
+}